@@ -3,7 +3,7 @@
 // gogenerate is a cache-based wrapper around go generate directives.
 //
 // Usage:
-//         gogenerate [-p n] [-r n] [-trace] [-skipCache] [-tags 'tag list'] [packages]
+//         gogenerate [-p n] [-r n] [-trace] [-skipCache] [-watch] [-check] [-prune [-n]] [-json] [-tags 'tag list'] [packages]
 //
 // gogenerate runs go generate directives found in packages according to the
 // reverse dependency graph implied by those packages' imports, and the
@@ -30,14 +30,40 @@
 // gogenerate runs, and can appear multiple times.
 //
 // The -p flag controls the concurrency level of gogenerate. By default will
-// assume a -p value of GOMAXPROCS. go generate directives only ever run in serial
-// and never concurrently with other work (this may be relaxed in the future to
-// allow concurrent execution of go generate directives). A -p value of 1 implies
+// assume a -p value of GOMAXPROCS. The go generate directives of different
+// packages run concurrently, provided neither package's directives write to the
+// directory of the other package, or of a package that the other depends on. The
+// directives of a given package only ever run in serial. A -p value of 1 implies
 // serial execution of work in a well defined order.
 //
 // The -trace flag outputs a log of work being executed by gogenerate. It is most
 // useful when specified along with -p 1 (else the order of execution of work is
-// not well defined).
+// not well defined). The output of go generate directives is shown when -trace is
+// specified, each line prefixed with the position of the directive responsible.
+// Output from work that runs concurrently is written in package order.
+//
+// The -json flag causes gogenerate to write a stream of JSON events to stdout,
+// similar in spirit to go test -json. Events describe packages being scheduled,
+// cache hits and misses, go generate directives starting and ending, the files
+// generated by each iteration in a package, and the number of iterations taken
+// for a package to reach a fixed point. With -graph, the dependency graph is
+// written as an event. With -json, the output of go generate directives is
+// reported in events rather than with -trace. Each event is a JSON object with
+// the following fields, omitted where not relevant:
+//
+//   Time      the time of the event
+//   Action    schedule, cache-hit, cache-miss, start, end, fail, generated,
+//             fixedpoint, graph or prune, and with -check stale, missing or
+//             orphaned
+//   Package   the import path of the package
+//   Directive the position (file:line) of the directive
+//   Args      the arguments of the directive
+//   Iteration the iteration in the package (for fixedpoint, the number taken)
+//   ActionID  the hex-encoded cache key checked for the iteration
+//   Files     the files generated by the iteration
+//   Elapsed   the time taken to run the directive, in seconds
+//   Output    the combined stdout and stderr of the directive
+//   Edges     the edges (From and To) of the dependency graph
 //
 // The -skipCache flag causes gogenerate to skip checking for cache hits.
 // Consequently, all generators are run, regardless of cache state, until a fixed
@@ -45,11 +71,37 @@
 // such, this flag can be used to heal a broken cache, i.e. correct the delta for
 // a given cache key.
 //
-// Note: at present, gogenerate does not understand the GOFLAGS environment
-// variable.  Neither does it pass the effective build tags via GOFLAGS to each go
-// generate directive. For more details see:
-//
-// https://github.com/golang/go/issues/26849#issuecomment-460301061
+// The -watch flag causes gogenerate to keep running once generation is complete,
+// watching for changes to files in the directories of packages in the main
+// module, and the directories matched by -infiles: and -outdir: flags. When files
+// change, the packages affected and their reverse dependencies are regenerated.
+// An error during regeneration is reported, and gogenerate continues to watch.
+// Packages that are added whilst gogenerate is watching are not considered.
+//
+// The -check flag causes gogenerate to check whether the generated files of
+// packages are up to date, without changing the working tree. gogenerate reports
+// each generated file that is stale (its contents differ from what generation
+// would produce), missing (generation would produce it) or orphaned (no go
+// generate directive produces it), and exits with a non-zero exit code if there
+// are any. Where the cache holds a zero delta for a package, it is known to be up
+// to date. Otherwise, gogenerate generates a scratch copy of the main module in
+// order to compare the results. The latter is only possible in module mode.
+//
+// The -prune flag causes gogenerate to remove generated files that no go generate
+// directive produces before generating: files in the directories to which
+// directives write that are not named for any of those directives, and files
+// that directives produced in previous runs but no longer do, including those in
+// -outdir: directories. For the latter, gogenerate records in its cache the
+// artefacts produced by each directive in each run. Only the directives of the
+// packages matched by the packages argument are considered. The -n flag causes
+// -prune to list the files that would be removed without removing them.
+//
+// gogenerate understands the -tags, -mod and -modfile flags in the GOFLAGS
+// environment variable, consistent with the go command; -tags on the command line
+// takes precedence over -tags in GOFLAGS. Each go generate directive is run with
+// GOFLAGS set to reflect the effective build tags, along with the other flags in
+// GOFLAGS. Because a generator may be sensitive to any of these, GOFLAGS, and the
+// contents of any -modfile, form part of the cache key for generation.
 //
 // go generate directives can take three forms:
 //
@@ -94,18 +146,32 @@
 //
 // gogenerate also understands a special form of directive:
 //
-//   //go:generate:gogenerate [cond] break
+//   //go:generate:gogenerate [cond] ... command [args]
+//
+// Such special directives include zero or more [cond] prefixes. If all of the
+// conditions are satisfied, the command is run. Note, if spaces are required in
+// [cond] it must be double-quoted. The commands are:
 //
-// This special directives include a [cond] prefix. At present, a single command
-// is understood: break. If the preceding conditions are satisfied, no further
-// go:generate directives are exectuted in this iteration. Note, if spaces are
-// required in [cond] it must be double-quoted.
+//   break         no further go:generate directives are executed in this
+//                 iteration
+//   skip-next     the next directive is not executed
+//   fail ["msg"]  generation of the package fails, with the optional message
 //
 // The predefined conditions are:
 //
-//   [exists:file] for whether the (relative) file path exists
-//   [exec:prog]   for whether prog is available for execution (found by
-//                 exec.LookPath)
+//   [exists:file]    for whether the (relative) file path exists
+//   [exec:prog]      for whether prog is available for execution (found by
+//                    exec.LookPath)
+//   [env:NAME]       for whether the environment variable NAME is set
+//   [env:NAME=value] for whether the environment variable NAME is set to value
+//   [goos:x]         for whether the target operating system is x
+//   [goarch:x]       for whether the target architecture is x
+//   [tag:x]          for whether x is one of the build tags, provided via -tags
+//                    or GOFLAGS
+//   [mod:path]       for whether the module path is in the build list of the
+//                    main module (never the case in GOPATH mode)
+//
+// A condition is negated by a ! prefix, for example [!exists:file].
 //
 // Where the third form of go generate directive is used, it may be necessary to
 // declare tool dependencies in your main module. For more information on how to
@@ -118,13 +184,21 @@
 // details on how to configure its location. Setting GOGENERATECACHE overrides the
 // default.
 //
+// Setting GOGENERATECACHEURL to the URL of a server shares the results of
+// generation via that server, for example between developers and CI, in addition
+// to the local cache. The server speaks a simple HTTP protocol, keyed by the
+// hex-encoded action ID of a generation: GET url/id responds with the archive for
+// id (or 404 if there is none), and PUT url/id stores the request body as the
+// archive for id.
+//
+// Requests to the server time out after a minute. Failing to store an archive on
+// the server is not fatal, because the archive is already stored locally; a
+// warning is printed instead.
+//
 // TODO
 //
 // The following is a rough list of TODOs for gogenerate:
 //
-// 	* add support for parsing of GOFLAGS
-// 	* add support for setting of GOFLAGS for go generate directives
-// 	* consider supporting concurrent execution of go generate directives
 // 	* define semantics for when generated files are removed by a generator
 // 	* add full tests for cgo
 package main
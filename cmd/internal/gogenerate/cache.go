@@ -0,0 +1,184 @@
+package gogenerate
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rogpeppe/go-internal/cache"
+)
+
+// artefactCache is a cache of the archives that result from generating a
+// package, keyed by the action ID of that generation.
+type artefactCache interface {
+	// GetFile returns the name of a file containing the archive for id, or an
+	// error if there is no such archive.
+	GetFile(id cache.ActionID) (string, error)
+
+	// Put stores the archive read from r as the archive for id.
+	Put(id cache.ActionID, r io.ReadSeeker) error
+
+	// Trim removes archives from the cache that have not been used recently.
+	Trim()
+}
+
+// localCache is an artefactCache backed by a directory on the local
+// filesystem.
+type localCache struct {
+	c *cache.Cache
+}
+
+var _ artefactCache = (*localCache)(nil)
+
+func openLocalCache(dir string) (*localCache, error) {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, fmt.Errorf("failed to create build cache dir %v: %v", dir, err)
+	}
+	c, err := cache.Open(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open build cache dir: %v", err)
+	}
+	return &localCache{c: c}, nil
+}
+
+func (l *localCache) GetFile(id cache.ActionID) (string, error) {
+	fp, _, err := l.c.GetFile(id)
+	return fp, err
+}
+
+func (l *localCache) Put(id cache.ActionID, r io.ReadSeeker) error {
+	_, _, err := l.c.Put(id, r)
+	return err
+}
+
+func (l *localCache) Trim() {
+	l.c.Trim()
+}
+
+// httpCache is an artefactCache that shares archives via a server that
+// speaks a simple HTTP protocol, keyed by action ID. Archives are also stored
+// in a local cache, which is consulted first. Where id is the hex-encoded
+// action ID, the protocol is:
+//
+//	GET url/id   responds with the archive for id, or 404 if there is none
+//	PUT url/id   stores the request body as the archive for id
+//
+// A failure to store an archive on the server is not fatal, because the
+// archive is already stored locally; instead a warning is written.
+type httpCache struct {
+	url      string
+	client   *http.Client
+	local    artefactCache
+	tempDir  string
+	warnings io.Writer
+}
+
+var _ artefactCache = (*httpCache)(nil)
+
+// httpCacheTimeout is the time limit for a request to the server of an
+// httpCache, such that an unresponsive server does not block generation
+const httpCacheTimeout = time.Minute
+
+// newHTTPCache returns an httpCache for the server at url, that stores
+// archives in local. Archives are downloaded via temporary files in tempDir.
+// Warnings are written to warnings.
+func newHTTPCache(url string, local artefactCache, tempDir string, warnings io.Writer) *httpCache {
+	return &httpCache{
+		url:      strings.TrimSuffix(url, "/"),
+		client:   &http.Client{Timeout: httpCacheTimeout},
+		local:    local,
+		tempDir:  tempDir,
+		warnings: warnings,
+	}
+}
+
+func (h *httpCache) idURL(id cache.ActionID) string {
+	return fmt.Sprintf("%v/%x", h.url, id)
+}
+
+func (h *httpCache) GetFile(id cache.ActionID) (string, error) {
+	if fp, err := h.local.GetFile(id); err == nil {
+		return fp, nil
+	}
+
+	u := h.idURL(id)
+	resp, err := h.client.Get(u)
+	if err != nil {
+		return "", fmt.Errorf("failed to get %v: %v", u, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get %v: %v", u, resp.Status)
+	}
+
+	tf, err := ioutil.TempFile(h.tempDir, "gogenerate-cache")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %v", err)
+	}
+	defer os.Remove(tf.Name())
+	defer tf.Close()
+
+	if _, err := io.Copy(tf, resp.Body); err != nil {
+		return "", fmt.Errorf("failed to read response from %v: %v", u, err)
+	}
+	if _, err := tf.Seek(0, io.SeekStart); err != nil {
+		return "", fmt.Errorf("failed to seek in %v: %v", tf.Name(), err)
+	}
+	if err := h.local.Put(id, tf); err != nil {
+		return "", fmt.Errorf("failed to put archive from %v: %v", u, err)
+	}
+
+	return h.local.GetFile(id)
+}
+
+func (h *httpCache) Put(id cache.ActionID, r io.ReadSeeker) error {
+	if err := h.local.Put(id, r); err != nil {
+		return err
+	}
+
+	if err := h.put(id, r); err != nil {
+		fmt.Fprintf(h.warnings, "warning: %v\n", err)
+	}
+
+	return nil
+}
+
+// put stores the archive r for id on the server.
+func (h *httpCache) put(id cache.ActionID, r io.ReadSeeker) error {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return fmt.Errorf("failed to determine archive size: %v", err)
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek archive: %v", err)
+	}
+
+	u := h.idURL(id)
+	// r is not ours to close
+	req, err := http.NewRequest(http.MethodPut, u, ioutil.NopCloser(r))
+	if err != nil {
+		return fmt.Errorf("failed to create request for %v: %v", u, err)
+	}
+	req.ContentLength = size
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to put %v: %v", u, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("failed to put %v: %v", u, resp.Status)
+	}
+
+	return nil
+}
+
+func (h *httpCache) Trim() {
+	h.local.Trim()
+}
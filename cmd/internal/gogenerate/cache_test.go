@@ -0,0 +1,146 @@
+package gogenerate
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rogpeppe/go-internal/cache"
+)
+
+// cacheServer is an in-process stand-in for a server that speaks the protocol
+// of httpCache
+type cacheServer struct {
+	mu       sync.Mutex
+	archives map[string][]byte
+
+	// failPut causes PUT requests to fail
+	failPut bool
+}
+
+func (c *cacheServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id := strings.TrimPrefix(r.URL.Path, "/")
+
+	switch r.Method {
+	case http.MethodGet:
+		b, ok := c.archives[id]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(b)
+	case http.MethodPut:
+		if c.failPut {
+			http.Error(w, "nope", http.StatusInternalServerError)
+			return
+		}
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		c.archives[id] = b
+	default:
+		http.Error(w, "unsupported method", http.StatusMethodNotAllowed)
+	}
+}
+
+func TestHTTPCache(t *testing.T) {
+	td, err := ioutil.TempDir("", "gogenerate-TestHTTPCache")
+	if err != nil {
+		t.Fatalf("failed to create TempDir: %v", err)
+	}
+	defer os.RemoveAll(td)
+
+	cs := &cacheServer{
+		archives: make(map[string][]byte),
+	}
+	srv := httptest.NewServer(cs)
+	defer srv.Close()
+
+	// two caches, each with its own local cache, sharing the same server; as
+	// if on two machines
+	newCache := func(name string) *httpCache {
+		l, err := openLocalCache(filepath.Join(td, name))
+		if err != nil {
+			t.Fatalf("failed to open local cache: %v", err)
+		}
+		return newHTTPCache(srv.URL+"/", l, td, ioutil.Discard)
+	}
+	c1 := newCache("c1")
+	c2 := newCache("c2")
+
+	id := cache.ActionID(cache.NewHash("test").Sum())
+	archive := []byte("this is an archive")
+
+	if _, err := c2.GetFile(id); err == nil {
+		t.Fatalf("expected a miss for an empty cache")
+	}
+
+	if err := c1.Put(id, bytes.NewReader(archive)); err != nil {
+		t.Fatalf("failed to put archive: %v", err)
+	}
+
+	if len(cs.archives) != 1 {
+		t.Fatalf("expected server to have 1 archive; got %v", len(cs.archives))
+	}
+
+	fp, err := c2.GetFile(id)
+	if err != nil {
+		t.Fatalf("failed to get archive: %v", err)
+	}
+	if got, err := ioutil.ReadFile(fp); err != nil || !bytes.Equal(got, archive) {
+		t.Fatalf("expected archive %q; got %q, %v", archive, got, err)
+	}
+
+	// the archive is now local to c2
+	srv.Close()
+
+	if _, err := c2.GetFile(id); err != nil {
+		t.Fatalf("expected archive to be in local cache: %v", err)
+	}
+}
+
+func TestHTTPCachePutFailure(t *testing.T) {
+	td, err := ioutil.TempDir("", "gogenerate-TestHTTPCachePutFailure")
+	if err != nil {
+		t.Fatalf("failed to create TempDir: %v", err)
+	}
+	defer os.RemoveAll(td)
+
+	cs := &cacheServer{
+		archives: make(map[string][]byte),
+		failPut:  true,
+	}
+	srv := httptest.NewServer(cs)
+	defer srv.Close()
+
+	l, err := openLocalCache(filepath.Join(td, "cache"))
+	if err != nil {
+		t.Fatalf("failed to open local cache: %v", err)
+	}
+	var warnings bytes.Buffer
+	c := newHTTPCache(srv.URL, l, td, &warnings)
+
+	id := cache.ActionID(cache.NewHash("test").Sum())
+
+	// the archive is stored locally, so the failure is only a warning
+	if err := c.Put(id, bytes.NewReader([]byte("archive"))); err != nil {
+		t.Fatalf("failed to put archive: %v", err)
+	}
+	if !strings.Contains(warnings.String(), "500 Internal Server Error") {
+		t.Fatalf("expected a warning about the failed put; got %q", warnings.String())
+	}
+	if _, err := l.GetFile(id); err != nil {
+		t.Fatalf("expected archive in local cache: %v", err)
+	}
+}
@@ -0,0 +1,341 @@
+package gogenerate
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	coregogenerate "myitcv.io/gogenerate"
+)
+
+// The kinds of problem reported by check
+const (
+	// problemStale is a generated file the contents of which differ from
+	// those generation would produce
+	problemStale = "stale"
+
+	// problemMissing is a file generation would produce that does not exist
+	problemMissing = "missing"
+
+	// problemOrphaned is a generated file that no go generate directive
+	// produces
+	problemOrphaned = "orphaned"
+)
+
+type checkProblem struct {
+	pkg  string
+	kind string
+	file string
+}
+
+// genDir is a directory to which the packages being generated write.
+type genDir struct {
+	// pkg is the import path of the package to which the directory belongs,
+	// else the package with a directive that writes to the directory
+	pkg string
+
+	// names are the names of the directives that write to the directory
+	names map[string]bool
+}
+
+// check reports the generated files of the packages being generated that are
+// stale, missing or orphaned, without changing the working tree. Packages
+// for which a zero delta is found in the cache are at a fixed point, and so
+// up to date. If that cannot be determined for every package, the main module
+// is generated in a scratch copy, and the generated files of the copy
+// compared with those in the working tree. check returns an error if any
+// problems are found.
+func (g *gogenerate) check(work []dep) error {
+	g.checking = true
+	g.unchecked = make(map[*pkg]bool)
+	g.doWork(work)
+
+	dirs := g.genDirs()
+	problems := g.orphans(dirs)
+	if len(g.unchecked) > 0 {
+		problems = append(problems, g.checkScratch(dirs)...)
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+
+	sort.Slice(problems, func(i, j int) bool {
+		lhs, rhs := problems[i], problems[j]
+		if lhs.pkg != rhs.pkg {
+			return lhs.pkg < rhs.pkg
+		}
+		if lhs.kind != rhs.kind {
+			return lhs.kind < rhs.kind
+		}
+		return lhs.file < rhs.file
+	})
+
+	if *fJSON {
+		for i := 0; i < len(problems); {
+			e := event{Action: problems[i].kind, Package: problems[i].pkg}
+			for ; i < len(problems) && problems[i].pkg == e.Package && problems[i].kind == e.Action; i++ {
+				e.Files = append(e.Files, problems[i].file)
+			}
+			emit(os.Stdout, e)
+		}
+	} else {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("failed to determine working directory: %v", err)
+		}
+		for _, p := range problems {
+			fn := p.file
+			if rel, err := filepath.Rel(cwd, fn); err == nil {
+				fn = rel
+			}
+			fmt.Printf("%v: %v %v\n", p.pkg, p.kind, fn)
+		}
+	}
+
+	return fmt.Errorf("generated files are not up to date")
+}
+
+// genDirs returns the directories of the packages being generated, along
+// with the output directories of their directives.
+func (g *gogenerate) genDirs() map[string]*genDir {
+	res := make(map[string]*genDir)
+	add := func(dir string, p *pkg) *genDir {
+		owner := p.ImportPath
+		if op, ok := g.dirLookup[dir]; ok && op.generate {
+			owner = op.ImportPath
+		}
+		gd, ok := res[dir]
+		if !ok {
+			gd = &genDir{pkg: owner, names: make(map[string]bool)}
+			res[dir] = gd
+		} else if owner < gd.pkg {
+			// be deterministic where several packages write to a directory
+			// that belongs to none of them
+			gd.pkg = owner
+		}
+		return gd
+	}
+	for _, d := range g.allDeps() {
+		p, ok := d.(*pkg)
+		if !ok || !p.generate {
+			continue
+		}
+		add(p.Dir, p)
+		for _, d := range p.dirs {
+			if d.gen == nil {
+				continue
+			}
+			// as understood by coregogenerate.AnyFileGeneratedBy
+			name := filepath.Base(d.gen.DirectiveName())
+			add(p.Dir, p).names[name] = true
+			for _, od := range d.outDirs {
+				add(od, p).names[name] = true
+			}
+		}
+	}
+	return res
+}
+
+// generatedFiles returns the generated files in dir, mapped to the name of
+// the directive that generates each.
+func (g *gogenerate) generatedFiles(dir string) map[string]string {
+	res := make(map[string]string)
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return res
+		}
+		g.fatalf("failed to list contents of %v: %v", dir, err)
+	}
+	for _, fi := range fis {
+		if !fi.Mode().IsRegular() {
+			continue
+		}
+		if name, _, ok := coregogenerate.AnyFileIsGenerated(fi.Name()); ok {
+			res[filepath.Join(dir, fi.Name())] = name
+		}
+	}
+	return res
+}
+
+// orphans returns the generated files in dirs that are not generated by any
+// of the directives that write to the directory containing them.
+func (g *gogenerate) orphans(dirs map[string]*genDir) []checkProblem {
+	var res []checkProblem
+	for dir, gd := range dirs {
+		for fn, name := range g.generatedFiles(dir) {
+			if !gd.names[name] {
+				res = append(res, checkProblem{pkg: gd.pkg, kind: problemOrphaned, file: fn})
+			}
+		}
+	}
+	return res
+}
+
+// checkScratch generates the main module in a scratch copy, and returns the
+// generated files in dirs that are stale or missing as a result.
+func (g *gogenerate) checkScratch(dirs map[string]*genDir) []checkProblem {
+	var unchecked []string
+	for p := range g.unchecked {
+		unchecked = append(unchecked, p.ImportPath)
+	}
+	sort.Strings(unchecked)
+	if g.mainMod == "" {
+		g.fatalf("cannot check %v without generating, which is only supported in module mode", strings.Join(unchecked, ", "))
+	}
+	logTrace(g.traceOut, "checking %v in scratch copy", unchecked)
+
+	root := filepath.Dir(g.mainMod)
+	toScratch := func(scratch, path string) string {
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return ""
+		}
+		return filepath.Join(scratch, rel)
+	}
+
+	scratch := filepath.Join(g.tempDir, "check")
+	if err := copyTree(root, scratch); err != nil {
+		g.fatalf("failed to create scratch copy of %v: %v", root, err)
+	}
+	// so that the directories reported by go list in the copy are
+	// consistent with scratch
+	scratch, err := filepath.EvalSymlinks(scratch)
+	if err != nil {
+		g.fatalf("failed to evaluate symlinks in %v: %v", scratch, err)
+	}
+	for dir := range dirs {
+		if toScratch(scratch, dir) == "" {
+			g.fatalf("cannot check %v which is outside the main module %v", dir, root)
+		}
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		g.fatalf("failed to determine working directory: %v", err)
+	}
+	scwd := toScratch(scratch, cwd)
+	if scwd == "" {
+		g.fatalf("working directory %v is outside the main module %v", cwd, root)
+	}
+	var patts []string
+	for _, patt := range g.cliPatts {
+		if filepath.IsAbs(patt) {
+			if sp := toScratch(scratch, patt); sp != "" {
+				patt = sp
+			}
+		}
+		patts = append(patts, patt)
+	}
+	if err := os.Chdir(scwd); err != nil {
+		g.fatalf("failed to change to scratch directory %v: %v", scwd, err)
+	}
+	defer os.Chdir(cwd)
+
+	sg := newGogenerate(g.GOOS, g.GOARCH, g.tagsMap, g.tags, g.cache, g.tempDir)
+	sg.cliPatts = patts
+	sg.mainMod = filepath.Join(scratch, "go.mod")
+	sg.selfHash = g.selfHash
+	sg.goHash = g.goHash
+	sg.goFlags = g.goFlags
+	sg.doWork(sg.load())
+
+	sdirs := sg.genDirs()
+	var res []checkProblem
+	for dir, gd := range dirs {
+		sdir := toScratch(scratch, dir)
+		got := g.generatedFiles(dir)
+		want := make(map[string]bool)
+		if sgd, ok := sdirs[sdir]; ok {
+			for sfn, name := range sg.generatedFiles(sdir) {
+				if !sgd.names[name] {
+					// an orphan in the copy too
+					continue
+				}
+				fn := filepath.Join(dir, filepath.Base(sfn))
+				want[fn] = true
+				if _, ok := got[fn]; !ok {
+					res = append(res, checkProblem{pkg: gd.pkg, kind: problemMissing, file: fn})
+					continue
+				}
+				if !sameContents(fn, sfn) {
+					res = append(res, checkProblem{pkg: gd.pkg, kind: problemStale, file: fn})
+				}
+			}
+		}
+		for fn, name := range got {
+			// orphans that are not generated by any directive have already
+			// been reported; these are removed by generation
+			if gd.names[name] && !want[fn] {
+				res = append(res, checkProblem{pkg: gd.pkg, kind: problemOrphaned, file: fn})
+			}
+		}
+	}
+	return res
+}
+
+// sameContents reports whether the files a and b have the same contents.
+func sameContents(a, b string) bool {
+	ac, err := ioutil.ReadFile(a)
+	if err != nil {
+		return false
+	}
+	bc, err := ioutil.ReadFile(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(ac, bc)
+}
+
+// copyTree copies the directory tree rooted at src to dst, skipping .git
+// directories and dst itself should it be within src.
+func copyTree(src, dst string) error {
+	return filepath.Walk(src, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		switch {
+		case fi.IsDir():
+			if path == dst || path != src && fi.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return os.MkdirAll(target, 0777)
+		case fi.Mode()&os.ModeSymlink != 0:
+			l, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(l, target)
+		case fi.Mode().IsRegular():
+			return copyFile(path, target, fi.Mode().Perm())
+		}
+		return nil
+	})
+}
+
+func copyFile(src, dst string, perm os.FileMode) error {
+	sf, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer sf.Close()
+	df, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(df, sf); err != nil {
+		df.Close()
+		return err
+	}
+	return df.Close()
+}
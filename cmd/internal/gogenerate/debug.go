@@ -2,6 +2,7 @@ package gogenerate
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"time"
 )
@@ -29,11 +30,14 @@ func logTiming(format string, args ...interface{}) {
 	}
 }
 
-func logTrace(format string, args ...interface{}) {
+// logTrace writes trace output to w. Trace output that results from work for
+// a dep is written to the output for that dep, because such work can happen
+// concurrently with other work.
+func logTrace(w io.Writer, format string, args ...interface{}) {
 	if format[len(format)-1] != '\n' {
 		format += "\n"
 	}
 	if *fTrace {
-		fmt.Fprintf(os.Stderr, format, args...)
+		fmt.Fprintf(w, format, args...)
 	}
 }
@@ -8,6 +8,7 @@ type directive struct {
 	line        int
 	args        []string
 	gen         generator
+	special     *specialDirective
 	outDirs     []string
 	inFilePatts []string
 }
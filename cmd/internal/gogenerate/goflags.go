@@ -0,0 +1,34 @@
+package gogenerate
+
+import (
+	"strings"
+
+	coregogenerate "myitcv.io/gogenerate"
+)
+
+// goFlags are the flags in GOFLAGS: those understood by gogenerate, along with
+// the other flags, which are passed through to go generate directives.
+type goFlags coregogenerate.GoFlags
+
+// parseGoFlags parses the value of GOFLAGS; see coregogenerate.ParseGoFlags.
+func parseGoFlags(s string) (goFlags, error) {
+	gf, err := coregogenerate.ParseGoFlags(s)
+	return goFlags(gf), err
+}
+
+// String returns the value of GOFLAGS with which go generate directives are
+// run, such that they see the effective build tags.
+func (gf goFlags) String() string {
+	var res []string
+	res = append(res, gf.Other...)
+	if len(gf.Tags) > 0 {
+		res = append(res, "-tags="+strings.Join(gf.Tags, ","))
+	}
+	if gf.Mod != "" {
+		res = append(res, "-mod="+gf.Mod)
+	}
+	if gf.ModFile != "" {
+		res = append(res, "-modfile="+gf.ModFile)
+	}
+	return strings.Join(res, " ")
+}
@@ -3,7 +3,6 @@ package gogenerate
 import (
 	"bytes"
 	"encoding/json"
-	"errors"
 	"flag"
 	"fmt"
 	"go/parser"
@@ -64,6 +63,11 @@ var (
 	fGraph            = flagSet.Bool("graph", false, "dump dependency graph")
 	fWorkP            = flagSet.Int("p", runtime.NumCPU(), "the number of bits of work that can be run in parallel")
 	fMaxGenIterations = flagSet.Int("r", 10, "maximum number of generation iterations per package")
+	fWatch            = flagSet.Bool("watch", false, "watch for changes and regenerate affected packages")
+	fJSON             = flagSet.Bool("json", false, "write a stream of JSON events to stdout")
+	fCheck            = flagSet.Bool("check", false, "check generated files are up to date without changing them")
+	fPrune            = flagSet.Bool("prune", false, "remove generated files that no directive produces")
+	fDryRun           = flagSet.Bool("n", false, "with -prune, print the files that would be removed without removing them")
 	fTags             tagsFlag
 
 	// isProgram indicates whether we are running via a testscript test or not. In case
@@ -151,6 +155,18 @@ func mainerr() (reterr error) {
 		return fmt.Errorf("value for -p must be at least 1")
 	}
 
+	if *fCheck && *fWatch {
+		return fmt.Errorf("-check and -watch cannot be used together")
+	}
+
+	if *fPrune && (*fCheck || *fWatch) {
+		return fmt.Errorf("-prune cannot be used with -check or -watch")
+	}
+
+	if *fDryRun && !*fPrune {
+		return fmt.Errorf("-n can only be used with -prune")
+	}
+
 	mm, err := mainMod()
 	if err != nil {
 		return fmt.Errorf("failed to determine main module: %v", err)
@@ -168,9 +184,25 @@ func mainerr() (reterr error) {
 		defer os.RemoveAll(td)
 	}
 
-	sort.Slice(fTags, func(i, j int) bool {
-		return fTags[i] < fTags[j]
-	})
+	goFlags, err := parseGoFlags(os.Getenv("GOFLAGS"))
+	if err != nil {
+		return err
+	}
+	if goFlags.ModFile != "" && !filepath.IsAbs(goFlags.ModFile) {
+		// directives are run in the directory of their package, hence the
+		// -modfile passed to them must not be relative to ours
+		cwd, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("failed to determine working directory: %v", err)
+		}
+		goFlags.ModFile = filepath.Join(cwd, goFlags.ModFile)
+	}
+	// as with the go command, -tags on the command line takes precedence
+	// over -tags in GOFLAGS
+	if len(fTags) > 0 {
+		goFlags.Tags = append([]string(nil), fTags...)
+		sort.Strings(goFlags.Tags)
+	}
 
 	artefactsCacheDir := os.Getenv("GOGENERATECACHE")
 	if artefactsCacheDir == "" {
@@ -180,15 +212,10 @@ func mainerr() (reterr error) {
 		}
 		artefactsCacheDir = filepath.Join(ucd, "gogenerate-artefacts")
 	}
-	if err := os.MkdirAll(artefactsCacheDir, 0777); err != nil {
-		return fmt.Errorf("failed to create build cache dir %v: %v", artefactsCacheDir, err)
-	}
-
-	artefactsCache, err := cache.Open(artefactsCacheDir)
+	localCache, err := openLocalCache(artefactsCacheDir)
 	if err != nil {
-		return fmt.Errorf("failed to open build cache dir: %v", err)
+		return err
 	}
-	defer artefactsCache.Trim()
 
 	td, err := ioutil.TempDir("", "gogenerate-workings")
 	if err != nil {
@@ -196,6 +223,12 @@ func mainerr() (reterr error) {
 	}
 	defer os.RemoveAll(td)
 
+	var artefactsCache artefactCache = localCache
+	if u := os.Getenv("GOGENERATECACHEURL"); u != "" {
+		artefactsCache = newHTTPCache(u, localCache, td, os.Stderr)
+	}
+	defer artefactsCache.Trim()
+
 	var tags []string
 	tagsMap := make(map[string]bool)
 	goos := os.Getenv("GOOS")
@@ -208,30 +241,16 @@ func mainerr() (reterr error) {
 	}
 	tagsMap[goos] = true
 	tagsMap[goarch] = true
-	for _, t := range fTags {
+	for _, t := range goFlags.Tags {
 		tagsMap[t] = true
 		tags = append(tags, t)
 	}
-	// TODO add support for parsing GOFLAGS to extract -tags values
-	// TODO once we get a resolution on https://github.com/golang/go/issues/26849#issuecomment-460301061
-	// we can then set GOFLAGS for in the environment passed to each generator
 
-	gogenerate := &gogenerate{
-		pkgLookup:         make(map[string]*pkg),
-		dirLookup:         make(map[string]*pkg),
-		gobinModLookup:    make(map[string]*gobinModDep),
-		gobinGlobalLookup: make(map[string]string),
-		gobinGlobalCache:  make(map[string]*gobinGlobalDep),
-		commLookup:        make(map[string]*commandDep),
-		GOOS:              goos,
-		GOARCH:            goarch,
-		tagsMap:           tagsMap,
-		tags:              tags,
-		cache:             artefactsCache,
-		tempDir:           td,
-	}
+	gogenerate := newGogenerate(goos, goarch, tagsMap, tags, artefactsCache, td)
+	gogenerate.goFlags = goFlags
 	gogenerate.cliPatts = flagSet.Args()
 	gogenerate.mainMod = mm
+	gogenerate.records = localCache
 
 	if !*fDebug {
 		defer func() {
@@ -261,11 +280,49 @@ func mainerr() (reterr error) {
 	gogenerate.hashFile(goHash, "", gopath)
 	gogenerate.goHash = goHash.Sum()
 
-	gogenerate.run()
+	work := gogenerate.load()
+
+	if *fWatch {
+		return gogenerate.watch(work)
+	}
+
+	if *fCheck {
+		return gogenerate.check(work)
+	}
+
+	if *fPrune {
+		work = gogenerate.prune(work)
+		if *fDryRun {
+			// a dry run only lists the files that would be pruned
+			return reterr
+		}
+	}
+
+	gogenerate.doWork(work)
+
+	gogenerate.writeRecords()
 
 	return reterr
 }
 
+func newGogenerate(goos, goarch string, tagsMap map[string]bool, tags []string, cache artefactCache, tempDir string) *gogenerate {
+	return &gogenerate{
+		pkgLookup:         make(map[string]*pkg),
+		dirLookup:         make(map[string]*pkg),
+		gobinModLookup:    make(map[string]*gobinModDep),
+		gobinGlobalLookup: make(map[string]string),
+		gobinGlobalCache:  make(map[string]*gobinGlobalDep),
+		commLookup:        make(map[string]*commandDep),
+		GOOS:              goos,
+		GOARCH:            goarch,
+		tagsMap:           tagsMap,
+		tags:              tags,
+		cache:             cache,
+		tempDir:           tempDir,
+		traceOut:          os.Stderr,
+	}
+}
+
 func mainMod() (string, error) {
 	// TODO performance: instead of exec-ing we could work this out ourselves (~16ms)
 	var stderr bytes.Buffer
@@ -309,10 +366,39 @@ type gogenerate struct {
 	// tags is just the build tags provided via GOFLAGS or -tags
 	tags []string
 
-	cache *cache.Cache
+	// goFlags are the flags parsed from GOFLAGS, with the effective build
+	// tags. goFlags.String() is the value of GOFLAGS with which go generate
+	// directives are run.
+	goFlags goFlags
+
+	cache artefactCache
+
+	// records is the cache in which records of the artefacts produced by
+	// directives are kept. Such records are specific to this machine, hence
+	// are kept in the local cache only. nil if records are not to be kept.
+	records artefactCache
 
 	tempDir string
 
+	// checking indicates that generate should only check, via the cache,
+	// whether packages are at a fixed point, recording in unchecked those
+	// for which that cannot be determined.
+	checking  bool
+	unchecked map[*pkg]bool
+
+	// mu guards the dependency graph and lookup maps above during a round of
+	// work, during which work for deps happens concurrently.
+	mu sync.Mutex
+
+	// traceOut is where trace output is written. Whilst mu is held during a
+	// round of work, it is the output of the dep for which work is being done.
+	traceOut io.Writer
+
+	// buildListMods is the set of module paths in the build list of the main
+	// module, as of the contents of its go.mod file buildListGoMod.
+	buildListMods  map[string]bool
+	buildListGoMod []byte
+
 	// self is the filepath to self
 	selfHash [hashSize]byte
 
@@ -342,7 +428,11 @@ func (g *gogenerate) allDeps() []dep {
 	return res
 }
 
-func (g *gogenerate) generate(w *pkg) (moreWork []dep) {
+// generate runs the go generate directives of w until a fixed point is
+// reached. It must be called with g.mu held; g.mu is released whilst each
+// directive runs, hence directives in other packages can run concurrently.
+// Trace output and the output of directives is written to out.
+func (g *gogenerate) generate(w *pkg, out *depOutput) (moreWork []dep) {
 	for {
 		if w.genCount == *fMaxGenIterations {
 			g.fatalf("hit max number of iterations (%v) for %v", *fMaxGenIterations, w.ImportPath)
@@ -399,9 +489,14 @@ func (g *gogenerate) generate(w *pkg) (moreWork []dep) {
 		fmt.Fprintf(hw, "gogenerate %v", g.selfHash)
 		fmt.Fprintf(hw, "go %v", g.goHash)
 		fmt.Fprintf(hw, "goos %v goarch %v\n", g.GOOS, g.GOARCH)
-		// we add tags to the generate hash because we can't know a generator
-		// will use them.
-		fmt.Fprintf(hw, "tags: %v\n", fTags)
+		// we add tags, and GOFLAGS more generally, to the generate hash
+		// because we can't know a generator will use them.
+		fmt.Fprintf(hw, "tags: %v\n", g.tags)
+		fmt.Fprintf(hw, "GOFLAGS: %v\n", g.goFlags)
+		if g.goFlags.ModFile != "" {
+			// the effect of -modfile is a function of the file's contents
+			g.hashFile(hw, "", g.goFlags.ModFile)
+		}
 		fmt.Fprintf(hw, "Deps:\n")
 		g.hashDeps(hw, w)
 		fmt.Fprintf(hw, "Directives:\n")
@@ -409,8 +504,13 @@ func (g *gogenerate) generate(w *pkg) (moreWork []dep) {
 		dirNames := make(map[string]map[generator]bool)
 		for _, d := range w.dirs {
 			fmt.Fprintf(hw, "%v\n", d.HashString())
-			if d.gen == nil {
-				// we simply hash the special gogenerate directive
+			if d.special != nil {
+				// the conditions of special gogenerate directives are a
+				// function of more than the directive, e.g. the environment,
+				// hence we also hash their outcome
+				for _, c := range d.special.conds {
+					fmt.Fprintf(hw, "%v: %v\n", c, g.condHolds(w, c))
+				}
 				continue
 			}
 			gens := dirNames[d.gen.DirectiveName()]
@@ -433,15 +533,33 @@ func (g *gogenerate) generate(w *pkg) (moreWork []dep) {
 		// for pre. Unclear whether there would be any benefit from so doing
 		pre := g.hashOutDirs(outDirOrder, w, hw, dirNames)
 
+		if g.checking {
+			// a zero-length archive means a zero delta, i.e. the package is
+			// at a fixed point
+			id := fmt.Sprintf("%x", hw.Sum())
+			if fp, err := g.cacheGetFile(hw.Sum(), out); err == nil && !*fskipCache {
+				emit(&out.stdout, event{Action: actionCacheHit, Package: w.ImportPath, Iteration: w.genCount, ActionID: id})
+				if fi, err := os.Stat(fp); err == nil && fi.Size() == 0 {
+					break
+				}
+			} else {
+				emit(&out.stdout, event{Action: actionCacheMiss, Package: w.ImportPath, Iteration: w.genCount, ActionID: id})
+			}
+			logTrace(g.traceOut, "unchecked %v", w)
+			g.unchecked[w] = true
+			break
+		}
+
 		if *fskipCache {
 			goto CacheMiss
 		}
 
-		if fp, _, err := g.cache.GetFile(hw.Sum()); err == nil {
+		if fp, err := g.cacheGetFile(hw.Sum(), out); err == nil {
 			r, err := newArchiveReader(fp)
 			if err != nil {
 				goto CacheMiss
 			}
+			var files []string
 			for {
 				fn, err := r.ExtractFile()
 				if err != nil {
@@ -451,10 +569,15 @@ func (g *gogenerate) generate(w *pkg) (moreWork []dep) {
 					goto CacheMiss
 				}
 				deltaDirs[filepath.Dir(fn)] = true
+				files = append(files, fn)
 			}
 			if err := r.Close(); err != nil {
 				goto CacheMiss
 			}
+			emit(&out.stdout, event{Action: actionCacheHit, Package: w.ImportPath, Iteration: w.genCount, ActionID: fmt.Sprintf("%x", hw.Sum())})
+			if len(files) > 0 {
+				emit(&out.stdout, event{Action: actionGenerated, Package: w.ImportPath, Iteration: w.genCount, Files: files})
+			}
 			if len(deltaDirs) == 0 {
 				// zero delta to apply; we are done
 				break
@@ -470,48 +593,46 @@ func (g *gogenerate) generate(w *pkg) (moreWork []dep) {
 		// if we get here we had a cache miss so we are going to have to run
 		// go generate
 
-		logTrace("generate %v", w)
+		emit(&out.stdout, event{Action: actionCacheMiss, Package: w.ImportPath, Iteration: w.genCount, ActionID: fmt.Sprintf("%x", hw.Sum())})
+		logTrace(g.traceOut, "generate %v", w)
 	RangeDirs:
-		for _, d := range w.dirs {
-			if d.gen == nil {
-				// special gogenerate directive. We know the only possible command for now
-				// is break. Verify the conditions are satisfied and break if so,
-				// else continue to the next dir
-				dirArgs := d.args
-				for strings.HasPrefix(dirArgs[0], "[") && strings.HasSuffix(dirArgs[0], "]") {
-					cond := dirArgs[0]
-					cond = cond[1 : len(cond)-1]
-					cond = strings.TrimSpace(cond)
-					dirArgs = dirArgs[1:]
-					want := true
-					if strings.HasPrefix(cond, "!") {
-						want = false
-						cond = strings.TrimSpace(cond[1:])
+		for i := 0; i < len(w.dirs); i++ {
+			d := w.dirs[i]
+			if d.special != nil {
+				// special gogenerate directive. Run the command if the
+				// conditions are satisfied, else continue to the next dir
+				if !g.specialHolds(w, d.special) {
+					continue
+				}
+				switch d.special.cmd {
+				case specialBreak:
+					break RangeDirs
+				case specialSkipNext:
+					i++
+				case specialFail:
+					var msg string
+					if len(d.special.args) > 0 {
+						msg = d.special.args[0]
 					}
-					switch {
-					case strings.HasPrefix(cond, "exists:"):
-						fn := strings.TrimSpace(strings.TrimPrefix(cond, "exists:"))
-						fn = filepath.Join(w.Dir, fn)
-						_, err := os.Stat(fn)
-						if want != (err == nil) {
-							continue RangeDirs
-						}
-					case strings.HasPrefix(cond, "exec:"):
-						fn := strings.TrimSpace(strings.TrimPrefix(cond, "exec:"))
-						_, err := exec.LookPath(fn)
-						if want != (err == nil) {
-							continue RangeDirs
-						}
-					default:
-						panic("should not be here; we checked the conditions earlier")
+					emit(&out.stdout, event{Action: actionFail, Package: w.ImportPath, Directive: fmt.Sprintf("%v:%v", d.file, d.line), Args: d.args, Iteration: w.genCount, Output: msg})
+					if msg != "" {
+						msg = ": " + msg
 					}
+					g.fatalf("generation of %v failed (%v:%v)%v", w.ImportPath, d.file, d.line, msg)
+				default:
+					panic(fmt.Errorf("should not be here; we checked the command %v earlier", d.special.cmd))
 				}
-				break RangeDirs
+				continue
 			}
 			cmd := exec.Command(d.args[0], d.args[1:]...)
-			if *fTrace {
-				cmd.Stdout = os.Stdout
-				cmd.Stderr = os.Stderr
+			var stdout, stderr *prefixWriter
+			if *fTrace && !*fJSON {
+				// attribute output to the directive
+				prefix := fmt.Sprintf("%v:%v: ", path.Join(w.ImportPath, d.file), d.line)
+				stdout = newPrefixWriter(&out.stdout, prefix)
+				stderr = newPrefixWriter(&out.stderr, prefix)
+				cmd.Stdout = stdout
+				cmd.Stderr = stderr
 			}
 			cmd.Dir = w.Dir
 			cmd.Env = append(os.Environ(),
@@ -521,6 +642,7 @@ func (g *gogenerate) generate(w *pkg) (moreWork []dep) {
 				"GOLINE="+strconv.Itoa(d.line),
 				"GOPACKAGE="+d.pkgName,
 				"DOLLAR="+"$",
+				"GOFLAGS="+g.goFlags.String(),
 			)
 
 			var traceArgs string
@@ -535,23 +657,45 @@ func (g *gogenerate) generate(w *pkg) (moreWork []dep) {
 				traceArgs = strings.Join(pargs, " ")
 				line := fmt.Sprintf("run generator: %v", traceArgs)
 				logTiming(line)
-				logTrace(line)
+				logTrace(g.traceOut, line)
 			}
 
-			var out []byte
+			pos := fmt.Sprintf("%v:%v", d.file, d.line)
+			emit(&out.stdout, event{Action: actionStart, Package: w.ImportPath, Directive: pos, Args: d.args, Iteration: w.genCount})
+
+			var cmdOut []byte
 			var err error
-			if *fTrace {
+			g.unlock()
+			start := time.Now()
+			if stdout != nil {
 				err = cmd.Run()
+				stdout.Close()
+				stderr.Close()
 			} else {
-				out, err = cmd.CombinedOutput()
+				cmdOut, err = cmd.CombinedOutput()
+			}
+			elapsed := time.Since(start)
+			g.lock(out)
+			ev := event{
+				Action:    actionEnd,
+				Package:   w.ImportPath,
+				Directive: pos,
+				Args:      d.args,
+				Iteration: w.genCount,
+				Elapsed:   elapsed.Seconds(),
+				Output:    string(cmdOut),
+			}
+			if err != nil {
+				ev.Action = actionFail
 			}
+			emit(&out.stdout, ev)
 			if err != nil {
-				g.fatalf("failed to run %v in %v: %v\n%s", strings.Join(cmd.Args, " "), w.Dir, err, out)
+				g.fatalf("failed to run %v in %v (%v:%v): %v\n%s", strings.Join(cmd.Args, " "), w.Dir, d.file, d.line, err, cmdOut)
 			}
 			if *fTrace || *fTraceTime {
 				line := fmt.Sprintf("ran generator: %v", traceArgs)
 				logTiming(line)
-				logTrace(line)
+				logTrace(g.traceOut, line)
 			}
 		}
 
@@ -569,20 +713,23 @@ func (g *gogenerate) generate(w *pkg) (moreWork []dep) {
 				deltaDirs[filepath.Dir(fn)] = true
 			}
 		}
+		sort.Strings(delta)
+		if len(delta) > 0 {
+			emit(&out.stdout, event{Action: actionGenerated, Package: w.ImportPath, Iteration: w.genCount, Files: delta})
+		}
 		if len(delta) == 0 {
-			if err := g.cachePutArchive(hw.Sum(), ar); err != nil {
+			if err := g.cachePutArchive(hw.Sum(), ar, out); err != nil {
 				g.fatalf("failed to put zero-length archive: %v", err)
 			}
 			break
 		}
 
-		sort.Strings(delta)
 		for _, f := range delta {
 			if err := ar.PutFile(f); err != nil {
 				g.fatalf("failed to put %v into archive: %v", f, err)
 			}
 		}
-		if err := g.cachePutArchive(hw.Sum(), ar); err != nil {
+		if err := g.cachePutArchive(hw.Sum(), ar, out); err != nil {
 			g.fatalf("failed to write archive to cache: %v", err)
 		}
 
@@ -591,6 +738,9 @@ func (g *gogenerate) generate(w *pkg) (moreWork []dep) {
 		}
 		return
 	}
+	if !g.unchecked[w] {
+		emit(&out.stdout, event{Action: actionFixedPoint, Package: w.ImportPath, Iteration: w.genCount})
+	}
 	return
 }
 
@@ -656,7 +806,7 @@ func (g *gogenerate) list(patts []string, opts ...string) ([]*Package, error) {
 
 	cmd := exec.Command("go", "list")
 	if *fTrace {
-		cmd.Stderr = os.Stderr
+		cmd.Stderr = g.traceOut
 	}
 
 	hasDeps := false
@@ -722,8 +872,9 @@ func (g *gogenerate) list(patts []string, opts ...string) ([]*Package, error) {
 	return res, nil
 }
 
-// load loads the packages resolved by patts and their deps
-func (g *gogenerate) run() {
+// load loads the packages resolved by patts and their deps, returning the
+// initial work
+func (g *gogenerate) load() []dep {
 	logTiming("start run")
 	// Resolve patterns to pkgs
 	pkgs, err := g.list(g.cliPatts, "-deps", "-test")
@@ -799,7 +950,7 @@ func (g *gogenerate) run() {
 	logTiming("initial loadMisses complete")
 
 	// At this point we should have a complete dependency graph, including the generators.
-	// Find roots and start work
+	// Find roots
 	var work []dep
 	for _, d := range g.allDeps() {
 		if d.Ready() {
@@ -807,10 +958,32 @@ func (g *gogenerate) run() {
 		}
 	}
 
+	return work
+}
+
+// doWork does the work in work, and the work that results, until all deps are
+// done.
+func (g *gogenerate) doWork(work []dep) {
 	logTiming("start work")
 
 	for len(work) > 0 {
-		if *fGraph {
+		if *fGraph && *fJSON {
+			var edges []edge
+			for _, d := range g.allDeps() {
+				if p, isPkg := d.(*pkg); !isPkg || !p.Standard {
+					for rd := range d.Deps().rdeps {
+						edges = append(edges, edge{From: d.String(), To: rd.String()})
+					}
+				}
+			}
+			sort.Slice(edges, func(i, j int) bool {
+				if edges[i].From != edges[j].From {
+					return edges[i].From < edges[j].From
+				}
+				return edges[i].To < edges[j].To
+			})
+			emit(os.Stdout, event{Action: actionGraph, Edges: edges})
+		} else if *fGraph {
 			fmt.Printf("digraph { ")
 			for _, d := range g.allDeps() {
 				if p, isPkg := d.(*pkg); !isPkg || !p.Standard {
@@ -841,22 +1014,52 @@ func (g *gogenerate) run() {
 		if *fWorkP == 1 {
 			sortDeps(work)
 		}
+		//
+		// Directives in different packages can run concurrently, so long as
+		// neither package's directives write to the output directories of the
+		// other, or cause any other work in the round to be undone. Work that
+		// conflicts with work already in the round is deferred to a later round.
 		todo := make(map[dep]bool)
-		var haveGenerate bool
-		for i := 0; ; i++ {
-			if i == len(work) || haveGenerate || len(todo) == *fWorkP {
-				work = work[i:]
+		claimedDirs := make(map[string]bool)
+		claimedDeps := make(map[dep]bool)
+		var deferred []dep
+	Work:
+		for i, w := range work {
+			if len(todo) == *fWorkP {
+				deferred = append(deferred, work[i:]...)
 				break
 			}
-			w := work[i]
-			if !w.Ready() || w.Done() {
+			if !w.Ready() || w.Done() || todo[w] {
 				continue
 			}
-			if p, ok := w.(*pkg); ok && p.generate {
-				haveGenerate = true
+			if claimedDeps[w] {
+				deferred = append(deferred, w)
+				continue
+			}
+			if p, ok := w.(*pkg); ok && p.generate && len(p.dirs) > 0 {
+				dirs, deps := g.generateClaims(p)
+				for od := range dirs {
+					if claimedDirs[od] {
+						deferred = append(deferred, w)
+						continue Work
+					}
+				}
+				for d := range deps {
+					if claimedDeps[d] || todo[d] {
+						deferred = append(deferred, w)
+						continue Work
+					}
+				}
+				for od := range dirs {
+					claimedDirs[od] = true
+				}
+				for d := range deps {
+					claimedDeps[d] = true
+				}
 			}
 			todo[w] = true
 		}
+		work = deferred
 		var todoOrder []dep
 		for w := range todo {
 			todoOrder = append(todoOrder, w)
@@ -864,18 +1067,35 @@ func (g *gogenerate) run() {
 
 		var wg sync.WaitGroup
 
-		// TODO performance: this is not necessary
+		// sorted such that output is deterministic
 		sortDeps(todoOrder)
 
 		for _, w := range todoOrder {
+			if p, ok := w.(*pkg); ok && p.generate {
+				emit(os.Stdout, event{Action: actionSchedule, Package: p.ImportPath})
+			}
+		}
+
+		outs := make([]depOutput, len(todoOrder))
+		errs := make([]interface{}, len(todoOrder))
+
+		for i, w := range todoOrder {
 			wg.Add(1)
-			func(w dep) {
+			go func(w dep, out *depOutput, err *interface{}) {
 				defer wg.Done()
+				if !*fDebug {
+					// propagate failures to the main goroutine
+					defer func() {
+						*err = recover()
+					}()
+				}
+				g.lock(out)
+				defer g.unlock()
 				switch w := w.(type) {
 				case *pkg:
 					if w.generate {
 						if len(w.dirs) > 0 {
-							moreWork := g.generate(w)
+							moreWork := g.generate(w, out)
 							debugf("more work: %v\n", moreWork)
 							if len(moreWork) != 0 {
 								work = append(work, moreWork...)
@@ -889,7 +1109,7 @@ func (g *gogenerate) run() {
 						return
 					}
 					if !w.Standard {
-						logTrace("hash %v", w)
+						logTrace(g.traceOut, "hash %v", w)
 					}
 					hw := newHash("## pkg " + w.ImportPath)
 					fmt.Fprintf(hw, "## pkg %v\n", w.ImportPath)
@@ -914,7 +1134,7 @@ func (g *gogenerate) run() {
 					}
 					w.hash = hw.Sum()
 				case *commandDep:
-					logTrace("hash commandDep %v", w)
+					logTrace(g.traceOut, "hash commandDep %v", w)
 					hw := newHash("## commandDep " + w.name)
 					fp, err := exec.LookPath(w.name)
 					if err != nil {
@@ -923,17 +1143,25 @@ func (g *gogenerate) run() {
 					g.hashFile(hw, "", fp)
 					w.hash = hw.Sum()
 				case *gobinGlobalDep:
-					logTrace("hash gobinGlobalDep %v", w)
+					logTrace(g.traceOut, "hash gobinGlobalDep %v", w)
 					hw := newHash("## gobinGlobalDep " + w.targetPath)
 					g.hashFile(hw, "", w.targetPath)
 					w.hash = hw.Sum()
 				case *gobinModDep:
-					logTrace("hash gobinModDep %v", w)
+					logTrace(g.traceOut, "hash gobinModDep %v", w)
 					w.hash = w.pkg.hash
 				}
-			}(w)
+			}(w, &outs[i], &errs[i])
 		}
 		wg.Wait()
+		for i := range outs {
+			outs[i].flush()
+		}
+		for _, err := range errs {
+			if err != nil {
+				panic(err)
+			}
+		}
 		logTiming("round complete %v", todoOrder)
 		for _, w := range todoOrder {
 			if w.Done() {
@@ -954,6 +1182,54 @@ func (g *gogenerate) run() {
 	}
 }
 
+// lock acquires g.mu in order to do work for a dep, the output of which is
+// written to out.
+func (g *gogenerate) lock(out *depOutput) {
+	g.mu.Lock()
+	g.traceOut = &out.stderr
+}
+
+func (g *gogenerate) unlock() {
+	g.traceOut = os.Stderr
+	g.mu.Unlock()
+}
+
+// generateClaims returns the directories to which the directives of p write,
+// and the deps that are undone as a consequence of p being generated: the
+// packages in those directories and their transitive reverse dependencies.
+// The directives of two packages can run concurrently if their claims do not
+// overlap.
+func (g *gogenerate) generateClaims(p *pkg) (map[string]bool, map[dep]bool) {
+	dirs := map[string]bool{p.Dir: true}
+	for _, d := range p.dirs {
+		for _, od := range d.outDirs {
+			dirs[od] = true
+		}
+	}
+	deps := make(map[dep]bool)
+	work := []dep{p}
+	for od := range dirs {
+		if odp, ok := g.dirLookup[od]; ok {
+			work = append(work, odp)
+			if odp.x != nil {
+				work = append(work, odp.x)
+			}
+		}
+	}
+	var w dep
+	for len(work) > 0 {
+		w, work = work[0], work[1:]
+		if deps[w] {
+			continue
+		}
+		deps[w] = true
+		for rd := range w.Deps().rdeps {
+			work = append(work, rd)
+		}
+	}
+	return dirs, deps
+}
+
 func (g *gogenerate) addDepsFromImports(p *pkg) {
 	seen := make(map[string]bool)
 	var imports []string
@@ -989,7 +1265,18 @@ func (g *gogenerate) newArchive() *archiveWriter {
 	return ar
 }
 
-func (g *gogenerate) cachePutArchive(id cache.ActionID, ar *archiveWriter) error {
+// cacheGetFile returns the name of a file containing the archive for id. It
+// must be called with g.mu held, on behalf of the dep whose output is out;
+// g.mu is released whilst the cache, which might be remote, is consulted.
+func (g *gogenerate) cacheGetFile(id cache.ActionID, out *depOutput) (string, error) {
+	g.unlock()
+	defer g.lock(out)
+	return g.cache.GetFile(id)
+}
+
+// cachePutArchive stores ar in the cache as the archive for id. As with
+// cacheGetFile, g.mu is released whilst the cache is written.
+func (g *gogenerate) cachePutArchive(id cache.ActionID, ar *archiveWriter, out *depOutput) error {
 	if err := ar.Close(); err != nil {
 		return fmt.Errorf("failed to close archive: %v", ar)
 	}
@@ -997,7 +1284,10 @@ func (g *gogenerate) cachePutArchive(id cache.ActionID, ar *archiveWriter) error
 	if err != nil {
 		return fmt.Errorf("failed to open archive %v for reading: %v", ar.file.Name(), err)
 	}
-	if _, _, err := g.cache.Put(id, f); err != nil {
+	defer f.Close()
+	g.unlock()
+	defer g.lock(out)
+	if err := g.cache.Put(id, f); err != nil {
 		return fmt.Errorf("failed to write archive to cache: %v", err)
 	}
 	return nil
@@ -1356,13 +1646,20 @@ func (g *gogenerate) refreshDirectiveDeps(p *pkg, misses missingDeps) {
 			}
 			p.dirs = append(p.dirs, dir)
 
-			if prefix == gogeneratePrefix {
+			// a special gogenerate directive is identified by its prefix, or
+			// by a condition in a regular go:generate directive
+			if prefix == gogeneratePrefix || strings.HasPrefix(dirArgs[0], "[") {
+				special, err := parseSpecialDirective(dirArgs)
+				if err != nil {
+					return fmt.Errorf("invalid special gogenerate directive: %v", err)
+				}
+				dir.special = special
 				return nil
 			}
 
 			// regular go:generate directive
 			gen, err := g.resolveDir(p.Dir, dirArgs)
-			if err != nil && err != gogeneratedir {
+			if err != nil {
 				return fmt.Errorf("failed to resolve directive: %v", err)
 			}
 			dir.gen = gen
@@ -1431,6 +1728,26 @@ func (g *gogenerate) undo(d dep) {
 	}
 }
 
+// refresh undoes the packages ps, and refreshes their imports and, for those
+// being generated, the dependencies of their directives, such that changes to
+// the files in their directories are reflected in the dependency graph.
+func (g *gogenerate) refresh(ps []*pkg) {
+	importMisses := make(missingDeps)
+	dirMisses := make(missingDeps)
+	for _, p := range ps {
+		if p.isXTest {
+			// refreshed along with the package it tests
+			p = g.dirLookup[p.Dir]
+		}
+		g.undo(p)
+		g.refreshImports(p, importMisses)
+		if p.generate {
+			g.refreshDirectiveDeps(p, dirMisses)
+		}
+	}
+	g.loadMisses(importMisses, dirMisses)
+}
+
 func (g *gogenerate) hashFile(hw io.Writer, dir, file string) {
 	fp := file
 	if !filepath.IsAbs(file) {
@@ -1604,7 +1921,7 @@ func (g *gogenerate) inFiles(p *pkg) []string {
 		res = append(res, f)
 	}
 	sort.Strings(res)
-	logTrace("infiles for %v: %v\n", p.ImportPath, res)
+	logTrace(g.traceOut, "infiles for %v: %v\n", p.ImportPath, res)
 	return res
 }
 
@@ -1777,37 +2094,7 @@ func (g *gogenerate) resolveDir(dir string, dirArgs []string) (generator, error)
 		}
 	case a0 == "go":
 		return nil, fmt.Errorf("do not yet know how to handle go command-based directives")
-	case a0[0] == '[':
-		// special gogenerate directive is identified by at least one condition
-		// followed by a single command. At the moment, the single command
-		// we understand is break
-		for strings.HasPrefix(dirArgs[0], "[") && strings.HasSuffix(dirArgs[0], "]") {
-			cond := dirArgs[0]
-			cond = cond[1 : len(cond)-1]
-			cond = strings.TrimSpace(cond)
-			dirArgs = dirArgs[1:]
-			if strings.HasPrefix(cond, "!") {
-				cond = strings.TrimSpace(cond[1:])
-			}
-			switch {
-			case strings.HasPrefix(cond, "exists:"):
-			case strings.HasPrefix(cond, "exec:"):
-			default:
-				return nil, fmt.Errorf("unknown condition in special gogenerate directive")
-			}
-		}
-		if len(dirArgs) != 1 {
-			return nil, fmt.Errorf("missing command in special gogenerate directive")
-		}
-		// If we add further commands, be sure to add the corresponding logic in
-		// *gogenerate.generate (which currently assumes only break exists)
-		if dirArgs[0] != "break" {
-			return nil, fmt.Errorf("unknown command %q in special gogenerate directive", dirArgs[0])
-		}
-		return nil, gogeneratedir
 	default:
 		return g.resolveCommandDep(dirArgs[0]), nil
 	}
 }
-
-var gogeneratedir = errors.New("directive is a special gogenerate directive")
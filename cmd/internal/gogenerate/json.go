@@ -0,0 +1,90 @@
+package gogenerate
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// event is an event in the stream of JSON events written to stdout by
+// gogenerate -json. The stream is similar in spirit to that of go test -json.
+type event struct {
+	Time   time.Time
+	Action string
+
+	// Package is the import path of the package to which the event relates
+	Package string `json:",omitempty"`
+
+	// Directive is the position (file:line) of the go generate directive to
+	// which the event relates, and Args its arguments
+	Directive string   `json:",omitempty"`
+	Args      []string `json:",omitempty"`
+
+	// Iteration is the iteration of the fixed-point loop in which the event
+	// occurred, or for a fixedpoint event the number of iterations taken
+	Iteration int `json:",omitempty"`
+
+	// ActionID is the key of the cache entry checked for the iteration
+	ActionID string `json:",omitempty"`
+
+	// Files are the files generated in the iteration
+	Files []string `json:",omitempty"`
+
+	// Elapsed is the time taken to run the directive, in seconds
+	Elapsed float64 `json:",omitempty"`
+
+	// Output is the combined stdout and stderr of the directive
+	Output string `json:",omitempty"`
+
+	// Edges is the dependency graph, each edge from a dep to a reverse dep
+	Edges []edge `json:",omitempty"`
+}
+
+type edge struct {
+	From string
+	To   string
+}
+
+// The actions of events
+const (
+	// actionSchedule is a package being scheduled for work
+	actionSchedule = "schedule"
+
+	// actionCacheHit and actionCacheMiss are the result of checking the cache
+	// for an iteration of generating a package
+	actionCacheHit  = "cache-hit"
+	actionCacheMiss = "cache-miss"
+
+	// actionStart, actionEnd and actionFail are a go generate directive being
+	// run, and then succeeding or failing
+	actionStart = "start"
+	actionEnd   = "end"
+	actionFail  = "fail"
+
+	// actionGenerated is the files generated by an iteration, whether from
+	// the cache or by running directives
+	actionGenerated = "generated"
+
+	// actionFixedPoint is the generation of a package reaching a fixed point
+	actionFixedPoint = "fixedpoint"
+
+	// actionGraph is the dependency graph, written with -graph
+	actionGraph = "graph"
+
+	// actionPrune is the files of a package removed by -prune, or that would
+	// be removed with -n
+	actionPrune = "prune"
+
+	// the kinds of problem reported by -check are also actions; see
+	// problemStale, problemMissing and problemOrphaned
+)
+
+// emit writes e to w if -json was specified.
+func emit(w io.Writer, e event) {
+	if !*fJSON {
+		return
+	}
+	e.Time = time.Now()
+	// errors writing to stdout or a buffer are not interesting
+	json.NewEncoder(w).Encode(e)
+}
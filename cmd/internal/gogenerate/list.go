@@ -2,7 +2,6 @@ package gogenerate
 
 import (
 	"fmt"
-	"os"
 	"strings"
 )
 
@@ -97,14 +96,6 @@ func deriveXTest(p *Package) *Package {
 
 func adjustTagsFlag(opts []string) ([]string, error) {
 	var other, tags []string
-	if gf := os.Getenv("GOFLAGS"); gf != "" {
-		gfs := strings.Fields(gf)
-		for _, f := range gfs {
-			if strings.HasPrefix(f, "-tags=") {
-				tags = append(tags, strings.TrimPrefix(f, "-tags="))
-			}
-		}
-	}
 	for i := 0; i < len(opts); i += 1 {
 		o := opts[i]
 		if strings.HasPrefix(o, "-tags=") {
@@ -0,0 +1,67 @@
+package gogenerate
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// depOutput collects the output that results from doing the work for a dep.
+// Work for deps happens concurrently; the output is written to os.Stdout and
+// os.Stderr once a round of work completes, in the order in which the deps are
+// sorted, such that output is deterministic for a given set of work.
+type depOutput struct {
+	stdout bytes.Buffer
+	stderr bytes.Buffer
+}
+
+func (d *depOutput) flush() {
+	os.Stdout.Write(d.stdout.Bytes())
+	os.Stderr.Write(d.stderr.Bytes())
+}
+
+// prefixWriter is an io.Writer that prefixes each line written to w with
+// prefix. It is used to attribute the output of a go generate directive to
+// that directive.
+type prefixWriter struct {
+	w      io.Writer
+	prefix string
+
+	// midLine is true if the last byte written was not a newline
+	midLine bool
+}
+
+func newPrefixWriter(w io.Writer, prefix string) *prefixWriter {
+	return &prefixWriter{
+		w:      w,
+		prefix: prefix,
+	}
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	var buf bytes.Buffer
+	for _, c := range b {
+		if !p.midLine {
+			buf.WriteString(p.prefix)
+			p.midLine = true
+		}
+		buf.WriteByte(c)
+		if c == '\n' {
+			p.midLine = false
+		}
+	}
+	if _, err := p.w.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Close terminates any partial line that has been written.
+func (p *prefixWriter) Close() error {
+	if !p.midLine {
+		return nil
+	}
+	p.midLine = false
+	_, err := p.w.Write([]byte("\n"))
+	return err
+}
@@ -0,0 +1,260 @@
+package gogenerate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/rogpeppe/go-internal/cache"
+
+	coregogenerate "myitcv.io/gogenerate"
+)
+
+// record is a record of the artefacts produced by the go generate directives
+// of a package, kept in the local cache between runs such that artefacts
+// that are no longer produced can be pruned.
+type record struct {
+	Directives []recordDirective
+
+	// Stale are artefacts recorded in a previous run that are no longer
+	// produced by any directive, but that still exist
+	Stale []string `json:",omitempty"`
+}
+
+type recordDirective struct {
+	// Pos is the position (file:line) of the directive
+	Pos string
+
+	// Name is the name of the directive, as used in the names of the files
+	// it generates
+	Name string
+
+	Files []string
+}
+
+// files returns all the files in r.
+func (r record) files() []string {
+	var res []string
+	for _, d := range r.Directives {
+		res = append(res, d.Files...)
+	}
+	return append(res, r.Stale...)
+}
+
+// recordID returns the cache key for the record of p.
+func recordID(p *pkg) cache.ActionID {
+	hw := newHash("## record " + p.ImportPath)
+	fmt.Fprintf(hw, "dir: %v\n", p.Dir)
+	return hw.Sum()
+}
+
+// currentRecord returns the record of the artefacts that currently exist for
+// the go generate directives of p.
+func (g *gogenerate) currentRecord(p *pkg) record {
+	var res record
+	for _, d := range p.dirs {
+		if d.gen == nil {
+			continue
+		}
+		rd := recordDirective{
+			Pos:  fmt.Sprintf("%v:%v", d.file, d.line),
+			Name: filepath.Base(d.gen.DirectiveName()),
+		}
+		for _, dir := range append([]string{p.Dir}, d.outDirs...) {
+			for fn, name := range g.generatedFiles(dir) {
+				if name == rd.Name {
+					rd.Files = append(rd.Files, fn)
+				}
+			}
+		}
+		sort.Strings(rd.Files)
+		res.Directives = append(res.Directives, rd)
+	}
+	return res
+}
+
+// previousRecord returns the record of p from the previous run, and false if
+// there is none.
+func (g *gogenerate) previousRecord(p *pkg) (record, bool) {
+	var res record
+	fp, err := g.records.GetFile(recordID(p))
+	if err != nil {
+		return res, false
+	}
+	c, err := ioutil.ReadFile(fp)
+	if err != nil {
+		return res, false
+	}
+	if err := json.Unmarshal(c, &res); err != nil {
+		return res, false
+	}
+	return res, true
+}
+
+// generatePkgs returns the packages being generated, sorted by import path.
+func (g *gogenerate) generatePkgs() []*pkg {
+	var res []*pkg
+	for _, d := range g.allDeps() {
+		if p, ok := d.(*pkg); ok && p.generate {
+			res = append(res, p)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].ImportPath < res[j].ImportPath
+	})
+	return res
+}
+
+// produced returns the set of files currently produced by the go generate
+// directives of the packages being generated.
+func (g *gogenerate) produced() map[string]bool {
+	res := make(map[string]bool)
+	for _, p := range g.generatePkgs() {
+		for _, fn := range g.currentRecord(p).files() {
+			res[fn] = true
+		}
+	}
+	return res
+}
+
+// noLongerProduced returns the files in the previous record of p that are
+// not in produced but still exist.
+func (g *gogenerate) noLongerProduced(p *pkg, produced map[string]bool) []string {
+	prev, ok := g.previousRecord(p)
+	if !ok {
+		return nil
+	}
+	var res []string
+	for _, fn := range prev.files() {
+		if produced[fn] {
+			continue
+		}
+		if _, _, ok := coregogenerate.AnyFileIsGenerated(fn); !ok {
+			continue
+		}
+		if fi, err := os.Stat(fn); err == nil && fi.Mode().IsRegular() {
+			res = append(res, fn)
+		}
+	}
+	return res
+}
+
+// writeRecords writes the record of each of the packages being generated to
+// the local cache, carrying forward artefacts that are no longer produced
+// but that still exist.
+func (g *gogenerate) writeRecords() {
+	if g.records == nil {
+		return
+	}
+	produced := g.produced()
+	for _, p := range g.generatePkgs() {
+		r := g.currentRecord(p)
+		r.Stale = g.noLongerProduced(p, produced)
+		sort.Strings(r.Stale)
+		c, err := json.Marshal(r)
+		if err != nil {
+			g.fatalf("failed to marshal record for %v: %v", p.ImportPath, err)
+		}
+		if err := g.records.Put(recordID(p), bytes.NewReader(c)); err != nil {
+			g.fatalf("failed to write record for %v: %v", p.ImportPath, err)
+		}
+	}
+}
+
+// prune removes the generated files that no go generate directive of the
+// packages being generated produces: files in the directories to which those
+// directives write that are not named for any of them, along with files that
+// directives produced in previous runs, including to -outdir: directories
+// that are no longer written to. prune happens before generation, hence
+// returns the work to do given the files removed. With -n, the files are
+// listed but not removed.
+func (g *gogenerate) prune(work []dep) []dep {
+	byPkg := make(map[string]map[string]bool)
+	add := func(pkg, fn string) {
+		fns := byPkg[pkg]
+		if fns == nil {
+			fns = make(map[string]bool)
+			byPkg[pkg] = fns
+		}
+		fns[fn] = true
+	}
+	for _, o := range g.orphans(g.genDirs()) {
+		add(o.pkg, o.file)
+	}
+	produced := g.produced()
+	for _, p := range g.generatePkgs() {
+		for _, fn := range g.noLongerProduced(p, produced) {
+			add(p.ImportPath, fn)
+		}
+	}
+
+	var pkgs []string
+	for pkg := range byPkg {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		g.fatalf("failed to determine working directory: %v", err)
+	}
+	seen := make(map[string]bool)
+	affected := make(map[*pkg]bool)
+	for _, pkg := range pkgs {
+		var files []string
+		for fn := range byPkg[pkg] {
+			// a file can be both an orphan and recorded against a package
+			if !seen[fn] {
+				seen[fn] = true
+				files = append(files, fn)
+			}
+		}
+		if len(files) == 0 {
+			continue
+		}
+		sort.Strings(files)
+		emit(os.Stdout, event{Action: actionPrune, Package: pkg, Files: files})
+		for _, fn := range files {
+			if *fDryRun {
+				if !*fJSON {
+					if rel, err := filepath.Rel(cwd, fn); err == nil {
+						fn = rel
+					}
+					fmt.Printf("rm %v\n", fn)
+				}
+				continue
+			}
+			logTrace(g.traceOut, "prune %v", fn)
+			if err := os.Remove(fn); err != nil {
+				g.fatalf("failed to prune %v: %v", fn, err)
+			}
+			if p, ok := g.dirLookup[filepath.Dir(fn)]; ok {
+				affected[p] = true
+			}
+		}
+	}
+
+	if len(affected) == 0 {
+		return work
+	}
+	var ps []*pkg
+	for p := range affected {
+		ps = append(ps, p)
+	}
+	sort.Slice(ps, func(i, j int) bool {
+		return ps[i].ImportPath < ps[j].ImportPath
+	})
+	g.refresh(ps)
+
+	work = nil
+	for _, d := range g.allDeps() {
+		if d.Ready() && !d.Done() {
+			work = append(work, d)
+		}
+	}
+	return work
+}
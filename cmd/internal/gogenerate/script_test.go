@@ -4,11 +4,13 @@ import (
 	"flag"
 	"fmt"
 	"go/build"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/rogpeppe/go-internal/goproxytest"
 	"github.com/rogpeppe/go-internal/gotooltest"
@@ -65,7 +67,8 @@ func TestScripts(t *testing.T) {
 	p := testscript.Params{
 		Dir: "testdata",
 		Cmds: map[string]func(ts *testscript.TestScript, neg bool, args []string){
-			"rmglob": rmglob,
+			"rmglob":   rmglob,
+			"waitfile": waitfile,
 		},
 		Setup: func(e *testscript.Env) error {
 			var newEnv []string
@@ -131,3 +134,27 @@ func rmglob(ts *testscript.TestScript, neg bool, args []string) {
 		}
 	}
 }
+
+// waitfile waits for the contents of a file to be identical to those of
+// another file, for example whilst gogenerate -watch runs in the background.
+func waitfile(ts *testscript.TestScript, neg bool, args []string) {
+	if neg {
+		ts.Fatalf("waitfile does not support negation")
+	}
+	if len(args) != 2 {
+		ts.Fatalf("usage: waitfile file want")
+	}
+	want := ts.ReadFile(args[1])
+	got := ts.MkAbs(args[0])
+	timeout := time.After(10 * time.Second)
+	for {
+		if c, err := ioutil.ReadFile(got); err == nil && string(c) == want {
+			return
+		}
+		select {
+		case <-timeout:
+			ts.Fatalf("timed out waiting for %v to be identical to %v", args[0], args[1])
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
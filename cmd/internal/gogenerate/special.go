@@ -0,0 +1,215 @@
+package gogenerate
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// specialDirective is a parsed special gogenerate directive:
+//
+//	//go:generate:gogenerate [cond] ... command [args]
+//
+// The command is run if all of the conditions are satisfied.
+type specialDirective struct {
+	conds []specialCond
+	cmd   string
+	args  []string
+}
+
+// The commands of special gogenerate directives
+const (
+	// specialBreak stops the execution of the remaining directives in this
+	// iteration
+	specialBreak = "break"
+
+	// specialSkipNext skips the next directive
+	specialSkipNext = "skip-next"
+
+	// specialFail fails the generation of the package, with an optional
+	// message
+	specialFail = "fail"
+)
+
+// specialCond is a condition of a special gogenerate directive, of the form
+// [kind:arg], or [!kind:arg] where negated.
+type specialCond struct {
+	neg  bool
+	kind string
+	arg  string
+}
+
+// The kinds of condition of special gogenerate directives
+const (
+	// condExists is whether the (relative) file path arg exists
+	condExists = "exists"
+
+	// condExec is whether arg is available for execution
+	condExec = "exec"
+
+	// condEnv is whether the environment variable arg is set, or where arg
+	// is of the form NAME=value, whether NAME is set to value
+	condEnv = "env"
+
+	// condGOOS and condGOARCH are whether the target operating system and
+	// architecture are arg
+	condGOOS   = "goos"
+	condGOARCH = "goarch"
+
+	// condTag is whether arg is one of the build tags
+	condTag = "tag"
+
+	// condMod is whether the module arg is in the build list of the main
+	// module
+	condMod = "mod"
+)
+
+func (c specialCond) String() string {
+	var neg string
+	if c.neg {
+		neg = "!"
+	}
+	return fmt.Sprintf("[%v%v:%v]", neg, c.kind, c.arg)
+}
+
+// parseSpecialDirective parses the arguments of a special gogenerate
+// directive.
+func parseSpecialDirective(args []string) (*specialDirective, error) {
+	res := new(specialDirective)
+	for len(args) > 0 && strings.HasPrefix(args[0], "[") && strings.HasSuffix(args[0], "]") {
+		cond := args[0]
+		args = args[1:]
+		c, err := parseSpecialCond(strings.TrimSpace(cond[1 : len(cond)-1]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid condition %v: %v", cond, err)
+		}
+		res.conds = append(res.conds, c)
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("missing command")
+	}
+	res.cmd, res.args = args[0], args[1:]
+	switch res.cmd {
+	case specialBreak, specialSkipNext:
+		if len(res.args) != 0 {
+			return nil, fmt.Errorf("%v takes no arguments", res.cmd)
+		}
+	case specialFail:
+		if len(res.args) > 1 {
+			return nil, fmt.Errorf("%v takes an optional message; quote a message that contains spaces", res.cmd)
+		}
+	default:
+		return nil, fmt.Errorf("unknown command %q", res.cmd)
+	}
+	return res, nil
+}
+
+func parseSpecialCond(cond string) (specialCond, error) {
+	var res specialCond
+	if strings.HasPrefix(cond, "!") {
+		res.neg = true
+		cond = strings.TrimSpace(cond[1:])
+	}
+	i := strings.Index(cond, ":")
+	if i == -1 {
+		return res, fmt.Errorf("expected kind:arg")
+	}
+	res.kind = strings.TrimSpace(cond[:i])
+	res.arg = strings.TrimSpace(cond[i+1:])
+	switch res.kind {
+	case condExists, condExec, condEnv, condGOOS, condGOARCH, condTag, condMod:
+	default:
+		return res, fmt.Errorf("unknown kind of condition %q", res.kind)
+	}
+	if res.arg == "" {
+		return res, fmt.Errorf("missing argument")
+	}
+	return res, nil
+}
+
+// specialHolds reports whether the conditions of the special gogenerate
+// directive sd, declared in p, are satisfied.
+func (g *gogenerate) specialHolds(p *pkg, sd *specialDirective) bool {
+	for _, c := range sd.conds {
+		if !g.condHolds(p, c) {
+			return false
+		}
+	}
+	return true
+}
+
+// condHolds reports whether the condition c, of a special gogenerate
+// directive declared in p, is satisfied.
+func (g *gogenerate) condHolds(p *pkg, c specialCond) bool {
+	var res bool
+	switch c.kind {
+	case condExists:
+		_, err := os.Stat(filepath.Join(p.Dir, c.arg))
+		res = err == nil
+	case condExec:
+		_, err := exec.LookPath(c.arg)
+		res = err == nil
+	case condEnv:
+		if i := strings.Index(c.arg, "="); i != -1 {
+			v, ok := os.LookupEnv(c.arg[:i])
+			res = ok && v == c.arg[i+1:]
+		} else {
+			_, res = os.LookupEnv(c.arg)
+		}
+	case condGOOS:
+		res = g.GOOS == c.arg
+	case condGOARCH:
+		res = g.GOARCH == c.arg
+	case condTag:
+		for _, t := range g.tags {
+			if t == c.arg {
+				res = true
+				break
+			}
+		}
+	case condMod:
+		res = g.buildList()[c.arg]
+	default:
+		panic(fmt.Errorf("should not be here; we checked the condition %v earlier", c))
+	}
+	return res != c.neg
+}
+
+// buildList returns the set of module paths in the build list of the main
+// module, which is empty in GOPATH mode. The build list is cached for as long
+// as the contents of the main module's go.mod file remain the same, because
+// go generate directives can add requirements.
+func (g *gogenerate) buildList() map[string]bool {
+	if g.mainMod == "" {
+		return nil
+	}
+	gomod, err := ioutil.ReadFile(g.mainMod)
+	if err != nil {
+		g.fatalf("failed to read %v: %v", g.mainMod, err)
+	}
+	if g.buildListMods != nil && bytes.Equal(gomod, g.buildListGoMod) {
+		return g.buildListMods
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("go", "list", "-m", "-f={{.Path}}", "all")
+	cmd.Stderr = &stderr
+	if *fTrace {
+		cmd.Stderr = io.MultiWriter(&stderr, g.traceOut)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		g.fatalf("failed to run %v: %v\n%s", strings.Join(cmd.Args, " "), err, stderr.Bytes())
+	}
+	g.buildListMods = make(map[string]bool)
+	for _, m := range strings.Fields(string(out)) {
+		g.buildListMods[m] = true
+	}
+	g.buildListGoMod = gomod
+	return g.buildListMods
+}
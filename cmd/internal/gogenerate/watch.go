@@ -0,0 +1,285 @@
+package gogenerate
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"time"
+
+	fsnotify "gopkg.in/fsnotify/fsnotify.v1"
+
+	coregogenerate "myitcv.io/gogenerate"
+)
+
+// watchQuiet is the duration of the window within which changes to files are
+// batched together before regeneration starts
+const watchQuiet = 100 * time.Millisecond
+
+// watch does the initial work in work, then watches the directories of the
+// packages in the main module, along with the directories of files that are
+// inputs to or outputs of go generate directives, and regenerates the packages
+// affected by changes to files in those directories. watch returns when
+// interrupted.
+func (g *gogenerate) watch(work []dep) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create watcher: %v", err)
+	}
+	defer w.Close()
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	watched := make(map[string]bool)
+	var changed map[string]bool
+
+	for {
+		// the set of directories to watch can change as a result of
+		// regeneration
+		dirs := g.watchDirs()
+		for d := range dirs {
+			if watched[d] {
+				continue
+			}
+			if err := w.Add(d); err != nil {
+				return fmt.Errorf("failed to watch %v: %v", d, err)
+			}
+		}
+		for d := range watched {
+			if !dirs[d] {
+				w.Remove(d)
+			}
+		}
+		watched = dirs
+
+		// events are compared against a snapshot of the files in the watched
+		// directories taken before regeneration, in which generated files are
+		// updated afterwards. Hence changes to files during regeneration are
+		// not missed, and the writing of generated files is ignored.
+		files := g.snapshotDirs(dirs, nil)
+
+		g.regenerate(work, changed)
+
+		for fn := range files {
+			if g.isGenerated(fn) {
+				delete(files, fn)
+			}
+		}
+		for fn, h := range g.snapshotDirs(dirs, g.isGenerated) {
+			files[fn] = h
+		}
+
+		changed = make(map[string]bool)
+		var quiet <-chan time.Time
+	Events:
+		for {
+			select {
+			case <-interrupt:
+				return nil
+			case err := <-w.Errors:
+				return fmt.Errorf("failed to watch for changes: %v", err)
+			case e := <-w.Events:
+				h, ok := g.snapshotFile(e.Name)
+				if prev, seen := files[e.Name]; seen == ok && prev == h {
+					continue
+				}
+				changed[e.Name] = true
+				quiet = time.After(watchQuiet)
+			case <-quiet:
+				break Events
+			}
+		}
+		work = nil
+	}
+}
+
+// regenerate undoes the packages affected by changes to the files in changed
+// before doing work and the work that results, i.e. regenerating those
+// packages and their reverse dependencies. An error during regeneration is
+// reported but does not stop gogenerate from watching for further changes.
+func (g *gogenerate) regenerate(work []dep, changed map[string]bool) {
+	if !*fDebug {
+		defer func() {
+			if err := recover(); err != nil {
+				gogenerateerr, ok := err.(gogenerateerror)
+				if !ok {
+					panic(fmt.Errorf("got something other than an error: %v [%T]", err, err))
+				}
+				fmt.Fprintln(os.Stderr, gogenerateerr)
+			}
+		}()
+	}
+
+	if len(changed) > 0 {
+		var files []string
+		for fn := range changed {
+			files = append(files, fn)
+		}
+		sort.Strings(files)
+
+		logTrace(g.traceOut, "changed: %v", files)
+
+		affected := make(map[dep]bool)
+		for _, fn := range files {
+			if p, ok := g.dirLookup[filepath.Dir(fn)]; ok {
+				affected[p] = true
+			}
+			for _, d := range g.allDeps() {
+				if p, ok := d.(*pkg); ok && p.generate && g.isInFile(p, fn) {
+					affected[p] = true
+				}
+			}
+		}
+
+		var affectedOrder []dep
+		for d := range affected {
+			affectedOrder = append(affectedOrder, d)
+		}
+		sortDeps(affectedOrder)
+
+		var ps []*pkg
+		for _, d := range affectedOrder {
+			ps = append(ps, d.(*pkg))
+		}
+		g.refresh(ps)
+	}
+
+	// work that failed in a previous round of regeneration is retried
+	for _, d := range g.allDeps() {
+		if p, ok := d.(*pkg); ok {
+			p.genCount = 0
+		}
+		if d.Ready() && !d.Done() {
+			work = append(work, d)
+		}
+	}
+
+	g.doWork(work)
+	g.writeRecords()
+}
+
+// watchDirs returns the existing directories to watch: the directories of the
+// packages in the main module (or, in GOPATH mode, all non-standard library
+// packages), and the directories in which the go generate directives of the
+// packages being generated find input files and write output files.
+func (g *gogenerate) watchDirs() map[string]bool {
+	res := make(map[string]bool)
+	for _, d := range g.allDeps() {
+		p, ok := d.(*pkg)
+		if !ok || p.Standard {
+			continue
+		}
+		if g.mainMod == "" || p.Module != nil && p.Module.GoMod == g.mainMod {
+			res[p.Dir] = true
+		}
+		if !p.generate {
+			continue
+		}
+		for _, d := range p.dirs {
+			for _, od := range d.outDirs {
+				res[od] = true
+			}
+		}
+		for _, patt := range g.inFilePatts(p) {
+			res[filepath.Dir(patt)] = true
+		}
+	}
+	for dir := range res {
+		// the directory of an input file pattern can itself be a pattern, and
+		// directories can be removed
+		if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+			delete(res, dir)
+		}
+	}
+	return res
+}
+
+// inFilePatts returns the absolute glob patterns of the input files declared
+// by the go generate directives of p via -infiles: flags.
+func (g *gogenerate) inFilePatts(p *pkg) []string {
+	var res []string
+	for _, d := range p.dirs {
+		for _, patt := range d.inFilePatts {
+			if !filepath.IsAbs(patt) {
+				patt = filepath.Join(p.Dir, patt)
+			}
+			res = append(res, patt)
+		}
+	}
+	return res
+}
+
+// isInFile reports whether fn is matched by one of the input file patterns of
+// the go generate directives of p.
+func (g *gogenerate) isInFile(p *pkg, fn string) bool {
+	for _, patt := range g.inFilePatts(p) {
+		if ok, _ := filepath.Match(patt, fn); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isGenerated reports whether fn is a file generated by one of the go
+// generate directives of the packages being generated.
+func (g *gogenerate) isGenerated(fn string) bool {
+	for _, d := range g.allDeps() {
+		p, ok := d.(*pkg)
+		if !ok || !p.generate {
+			continue
+		}
+		for _, d := range p.dirs {
+			if d.gen != nil && coregogenerate.AnyFileGeneratedBy(filepath.Base(fn), d.gen.DirectiveName()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// snapshotDirs returns the hashes of the files in dirs, limited to those for
+// which include returns true if it is not nil.
+func (g *gogenerate) snapshotDirs(dirs map[string]bool, include func(fn string) bool) map[string][hashSize]byte {
+	res := make(map[string][hashSize]byte)
+	for dir := range dirs {
+		fis, err := ioutil.ReadDir(dir)
+		if err != nil {
+			// the directory has been removed; anything that depends on it
+			// will be reported when next we regenerate
+			continue
+		}
+		for _, fi := range fis {
+			if !fi.Mode().IsRegular() {
+				continue
+			}
+			fn := filepath.Join(dir, fi.Name())
+			if include != nil && !include(fn) {
+				continue
+			}
+			if h, ok := g.snapshotFile(fn); ok {
+				res[fn] = h
+			}
+		}
+	}
+	return res
+}
+
+// snapshotFile returns the hash of the file fn, and false if fn is not a
+// regular file, including if it does not exist.
+func (g *gogenerate) snapshotFile(fn string) ([hashSize]byte, bool) {
+	fi, err := os.Stat(fn)
+	if err != nil || !fi.Mode().IsRegular() {
+		return nilHash, false
+	}
+	c, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nilHash, false
+	}
+	h := newHash("## watch " + fn)
+	h.Write(c)
+	return h.Sum(), true
+}
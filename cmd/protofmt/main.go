@@ -1,61 +1,201 @@
 // Copyright (c) 2016 Paul Jolly <paul@myitcv.org.uk>, all rights reserved.
 // Use of this document is governed by a license found in the LICENSE document.
 
+// protofmt formats proto files. It is purely syntactic: imports are not read
+// and type names are not resolved, so each file is formatted on its own.
+//
+// Without any file arguments protofmt formats its standard input. Directory
+// arguments are walked for .proto files. The -I flag, which earlier versions of
+// protofmt used to find imports, is still accepted but ignored.
 package main
 
 import (
+	"bytes"
 	"flag"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 
 	"myitcv.io/protobuf"
 	protofmt "myitcv.io/protobuf/fmt"
 )
 
 var (
-	fHelpShort   = flag.Bool("h", false, "Show usage text (same as --help).")
-	fHelpLong    = flag.Bool("help", false, "Show usage text (same as -h).")
-	fImportPaths = protobuf.ImportPaths([]string{"."})
+	fHelpShort = flag.Bool("h", false, "Show usage text (same as --help).")
+	fHelpLong  = flag.Bool("help", false, "Show usage text (same as -h).")
+	fList      = flag.Bool("l", false, "List files whose formatting differs from protofmt's; exit with status 1 if there are any.")
+	fWrite     = flag.Bool("w", false, "Write the result to (source) file instead of stdout.")
+	fDiff      = flag.Bool("d", false, "Display diffs instead of rewriting files.")
+
+	// fImportPaths is ignored: protofmt does not read imports
+	fImportPaths protobuf.ImportPaths
 )
 
 func init() {
-	flag.Var(&fImportPaths, "I", "Path to search for imports (flag can be used multiple times)")
+	flag.Var(&fImportPaths, "I", "Ignored; protofmt does not read imports. Accepted for compatibility (flag can be used multiple times).")
 }
 
-// TODO - this command should not require import paths etc... should be purely syntactical...
-// for another day
+const (
+	// exitUnformatted is the exit code in list mode if any file is not
+	// formatted
+	exitUnformatted = 1
+
+	// exitError is the exit code if any file could not be formatted
+	exitError = 2
+)
+
+var exitCode = 0
+
 func main() {
 	flag.Usage = usage
 	flag.Parse()
-	if *fHelpShort || *fHelpLong || flag.NArg() == 0 {
+	if *fHelpShort || *fHelpLong {
 		flag.Usage()
-		os.Exit(1)
+		os.Exit(exitError)
+	}
+
+	if flag.NArg() == 0 {
+		if *fWrite {
+			fatalf("cannot use -w with standard input")
+		}
+		if err := processFile("<standard input>", os.Stdin, os.Stdout); err != nil {
+			report(err)
+		}
+		os.Exit(exitCode)
 	}
 
-	for _, p := range fImportPaths {
-		fi, err := os.Stat(p)
-		if err != nil || !fi.IsDir() {
-			fatalf("Import dir does not exist (as a directory)")
+	for _, path := range flag.Args() {
+		fi, err := os.Stat(path)
+		switch {
+		case err != nil:
+			report(err)
+		case fi.IsDir():
+			walkDir(path)
+		default:
+			if err := processFile(path, nil, os.Stdout); err != nil {
+				report(err)
+			}
 		}
 	}
+	os.Exit(exitCode)
+}
 
-	if len(flag.Args()) == 0 {
-		fatalf("Need to specify at least one file to parse")
+// processFile formats the file filename, the contents of which are read from
+// in if it is not nil. The result, a listing or a diff, depending on the
+// flags, is written to out.
+func processFile(filename string, in io.Reader, out io.Writer) error {
+	if in == nil {
+		f, err := os.Open(filename)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
 	}
 
-	f := &protofmt.Formatter{
-		Output: os.Stdout,
+	src, err := ioutil.ReadAll(in)
+	if err != nil {
+		return err
+	}
+
+	res, err := protofmt.Source(filename, src)
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(src, res) {
+		// formatting has changed
+		if *fList {
+			fmt.Fprintln(out, filename)
+			if exitCode == 0 {
+				exitCode = exitUnformatted
+			}
+		}
+		if *fWrite {
+			fi, err := os.Stat(filename)
+			if err != nil {
+				return err
+			}
+			if err := ioutil.WriteFile(filename, res, fi.Mode().Perm()); err != nil {
+				return err
+			}
+		}
+		if *fDiff {
+			d, err := diff(src, res, filename)
+			if err != nil {
+				return fmt.Errorf("computing diff: %v", err)
+			}
+			fmt.Fprintf(out, "diff -u %s %s\n", filepath.ToSlash(filename+".orig"), filepath.ToSlash(filename))
+			out.Write(d)
+		}
 	}
 
-	f.Fmt(flag.Args(), fImportPaths)
+	if !*fList && !*fWrite && !*fDiff {
+		_, err = out.Write(res)
+	}
+
+	return err
+}
+
+func walkDir(path string) {
+	filepath.Walk(path, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			report(err)
+			return nil
+		}
+		if fi.IsDir() || strings.HasPrefix(fi.Name(), ".") || filepath.Ext(path) != ".proto" {
+			return nil
+		}
+		if err := processFile(path, nil, os.Stdout); err != nil {
+			report(err)
+		}
+		return nil
+	})
+}
+
+// diff returns a unified diff of b1 and b2, the original and formatted
+// contents of filename, as computed by diff -u.
+func diff(b1, b2 []byte, filename string) ([]byte, error) {
+	dir, err := ioutil.TempDir("", "protofmt")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	f1 := filepath.Join(dir, "orig")
+	f2 := filepath.Join(dir, "formatted")
+	if err := ioutil.WriteFile(f1, b1, 0666); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(f2, b2, 0666); err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command("diff", "-u", "-L", filename+".orig", "-L", filename, f1, f2)
+	data, err := cmd.CombinedOutput()
+	if len(data) > 0 {
+		// diff exits with a non-zero status when the files don't match;
+		// ignore that failure as long as we get output
+		return data, nil
+	}
+	return nil, err
 }
 
 func usage() {
-	fmt.Fprintf(os.Stderr, "Usage:  %s [options] <foo.proto> ...\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Usage:  %s [flags] [path ...]\n", os.Args[0])
 	flag.PrintDefaults()
 }
 
+func report(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	exitCode = exitError
+}
+
 func fatalf(format string, args ...interface{}) {
 	fmt.Fprintf(os.Stderr, format+"\n", args...)
-	os.Exit(1)
+	os.Exit(exitError)
 }
@@ -5,11 +5,11 @@ package main
 
 import (
 	"bytes"
-	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
-	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	protofmt "myitcv.io/protobuf/fmt"
@@ -36,47 +36,77 @@ func (t *MainTest) TearDownTest(c *C) {
 	}
 }
 
-func (t *MainTest) TestStdoutOutput(c *C) {
-	eVar := "PROTOBUF_INCLUDE"
-	pbInclude, ok := os.LookupEnv(eVar)
-	if !ok {
-		log.Fatalf("Could not find %v in ENV", eVar)
-	}
+func (t *MainTest) TestSyntactic(c *C) {
+	src, err := ioutil.ReadFile("_testFiles/basic.proto")
+	c.Assert(err, IsNil)
 
-	ob := bytes.NewBuffer(nil)
+	// basic.proto imports files that cannot be found without import paths
+	res, err := protofmt.Source("basic.proto", src)
+	c.Assert(err, IsNil)
 
-	f := &protofmt.Formatter{
-		Output: ob,
-	}
+	want, err := ioutil.ReadFile("_testFiles/basic.proto.formatted")
+	c.Assert(err, IsNil)
+	c.Assert(string(res), Equals, string(want))
+}
 
-	files := []string{"_testFiles/basic.proto"}
-	importPaths := []string{"_testFiles/", pbInclude}
+func (t *MainTest) TestListWriteDiff(c *C) {
+	defer func() {
+		*fList, *fWrite, *fDiff = false, false, false
+		exitCode = 0
+	}()
 
-	f.Fmt(files, importPaths)
+	src, err := ioutil.ReadFile("_testFiles/basic.proto")
+	c.Assert(err, IsNil)
+	formatted, err := ioutil.ReadFile("_testFiles/basic.proto.formatted")
+	c.Assert(err, IsNil)
 
-	cmpBytes, err := ioutil.ReadFile("_testFiles/basic.proto.formatted")
-	if err != nil {
-		panic(err)
-	}
-
-	equal := bytes.Equal(ob.Bytes(), cmpBytes)
-
-	if !equal {
-		cmd := exec.Command("diff", "-u", "-", "_testFiles/basic.proto.formatted")
-		cmd.Stdin = ob
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		err := cmd.Run()
-		if err != nil {
-			panic(err)
-		}
-		c.Fail()
-	} else {
-		fmt.Println("===============")
-		fmt.Println(string(ob.Bytes()))
-		fmt.Println("===============")
-	}
+	fn := filepath.Join(t.dir, "basic.proto")
+	err = ioutil.WriteFile(fn, src, 0666)
+	c.Assert(err, IsNil)
 
+	// list
+	*fList = true
+	ob := bytes.NewBuffer(nil)
+	err = processFile(fn, nil, ob)
+	c.Assert(err, IsNil)
+	c.Assert(ob.String(), Equals, fn+"\n")
+	c.Assert(exitCode, Equals, exitUnformatted)
+	*fList = false
+	exitCode = 0
+
+	// diff
+	*fDiff = true
+	ob.Reset()
+	err = processFile(fn, nil, ob)
+	c.Assert(err, IsNil)
+	c.Assert(strings.HasPrefix(ob.String(), "diff -u "+fn+".orig "+fn+"\n--- "+fn+".orig\n+++ "+fn+"\n@@ "), Equals, true)
+	*fDiff = false
+
+	// write
+	*fWrite = true
+	ob.Reset()
+	err = processFile(fn, nil, ob)
+	c.Assert(err, IsNil)
+	c.Assert(ob.Len(), Equals, 0)
+	got, err := ioutil.ReadFile(fn)
+	c.Assert(err, IsNil)
+	c.Assert(string(got), Equals, string(formatted))
+	*fWrite = false
+
+	// list again, now the file is formatted
+	*fList = true
+	ob.Reset()
+	err = processFile(fn, nil, ob)
+	c.Assert(err, IsNil)
+	c.Assert(ob.Len(), Equals, 0)
+	c.Assert(exitCode, Equals, 0)
+	*fList = false
+
+	// standard input
+	ob.Reset()
+	err = processFile("<standard input>", bytes.NewReader(src), ob)
+	c.Assert(err, IsNil)
+	c.Assert(ob.String(), Equals, string(formatted))
 }
 
 func tmpDir(prefix string) string {
@@ -0,0 +1,483 @@
+// Copyright (c) 2016 Paul Jolly <paul@myitcv.org.uk>, all rights reserved.
+// Use of this document is governed by a license found in the LICENSE document.
+
+package gogenerate
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/imports"
+)
+
+// A Generator is a go generate generator that generates files for the package
+// in which its directive appears. Generator takes care of the boilerplate
+// common to such generators: reading the go generate environment, ensuring
+// the generator runs once per package, loading the package, naming, commenting
+// and formatting the generated files, and declaring inputs and outputs to
+// gogenerate via flags. A generator is then a single function, Generate, from
+// the loaded package to the files it generates:
+//
+//	var g = &gogenerate.Generator{
+//		Name:       "myGen",
+//		ImportPath: "example.com/cmd/myGen",
+//		Generate:   generate,
+//	}
+//
+//	func main() {
+//		g.Main()
+//	}
+//
+// A Generator must not be copied after first use.
+type Generator struct {
+	// Name is the name of the generator, typically the base name of its
+	// main package, as used in the names of the files it generates
+	Name string
+
+	// ImportPath is the import path of the main package of the generator,
+	// used to identify its directives when run via gobin. If empty, Name is
+	// used instead.
+	ImportPath string
+
+	// Flags is the flag set from which the generator's flags are parsed. If
+	// nil, flag.CommandLine is used.
+	Flags *flag.FlagSet
+
+	// Mode is the mode with which the package is loaded, in addition to that
+	// required by Generator. If zero, the package is loaded with syntax and
+	// type information.
+	Mode packages.LoadMode
+
+	// Tests indicates whether the package is loaded with its test files, but
+	// not its external test files.
+	Tests bool
+
+	// Generate generates files for pkg, which is loaded without the files
+	// previously generated by the generator. Type errors, which may well
+	// result from the absence of such files, are not fatal, but are reported
+	// in pkg.Errors.
+	Generate func(pkg *packages.Package) ([]File, error)
+
+	fs       *flag.FlagSet
+	fLicense *string
+	fLog     *string
+	license  string
+}
+
+// A File is a file generated by a Generator.
+type File struct {
+	// Name is the name part of the generated file name as understood by
+	// NameFile, and may be empty
+	Name string
+
+	// Test indicates whether the file is a Go test file
+	Test bool
+
+	// Ext is the extension of the file, including the leading dot. If empty,
+	// the file is a Go file, hence ".go". A Go file is prefixed with a
+	// generated code header and any license header, and then formatted with
+	// goimports.
+	Ext string
+
+	// Dir is the directory to which the file is written, relative to the
+	// directory of the package. If empty, the file is written to the
+	// directory of the package. A directory other than that of the package
+	// should be declared via OutDirFlag, so that gogenerate knows the
+	// generator writes to it.
+	Dir string
+
+	Contents []byte
+}
+
+// FileName returns the name of f as generated by cmd, relative to the
+// directory of the package.
+func (f File) FileName(cmd string) string {
+	cmd = filepath.Base(cmd)
+
+	var res string
+
+	switch {
+	case f.Ext != "" && f.Ext != ".go":
+		res = nameBase(f.Name, cmd) + f.Ext
+	case f.Test:
+		res = NameTestFile(f.Name, cmd)
+	default:
+		res = NameFile(f.Name, cmd)
+	}
+
+	return filepath.Join(f.Dir, res)
+}
+
+func (f File) isGo() bool {
+	return f.Ext == "" || f.Ext == ".go"
+}
+
+func (g *Generator) flags() *flag.FlagSet {
+	if g.fs != nil {
+		return g.fs
+	}
+
+	g.fs = g.Flags
+	if g.fs == nil {
+		g.fs = flag.CommandLine
+	}
+	g.fLicense = LicenseFileFlag(g.fs)
+	g.fLog = LogFlag(g.fs)
+
+	return g.fs
+}
+
+// InFilesFlag defines a flag named FlagInFilesPrefix+key with the default
+// value and usage, the value of which is a glob pattern of files the
+// generator reads. gogenerate treats such files as inputs to the generator.
+func (g *Generator) InFilesFlag(key, value, usage string) *string {
+	return g.flags().String(FlagInFilesPrefix+key, value, usage)
+}
+
+// OutDirFlag defines a flag named FlagOutDirPrefix+key with the default value
+// and usage, the value of which is a directory to which the generator writes.
+// gogenerate treats files generated by the generator in such a directory as
+// outputs of the generator. The files the generator writes for a package to
+// a directory other than that of the package are recorded in the file
+// gen_<Name>.outputs in the directory of the package, such that only they are
+// removed when no longer generated. Like the files it records, that file is
+// generated and should be committed.
+func (g *Generator) OutDirFlag(key, value, usage string) *string {
+	return g.flags().String(FlagOutDirPrefix+key, value, usage)
+}
+
+// Infof logs according to format if the log level is LogInfo.
+func (g *Generator) Infof(format string, args ...interface{}) {
+	g.flags()
+	if *g.fLog == string(LogInfo) {
+		log.Printf(format, args...)
+	}
+}
+
+// Main runs the generator, and exits with a non-zero exit code if generation
+// fails. Main is typically called from the main function of a generator.
+func (g *Generator) Main() {
+	log.SetFlags(0)
+	log.SetPrefix(g.Name + ": ")
+
+	if err := g.Run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Run parses the generator's flags if they have not already been parsed, and
+// then, if the directive being run is the first directive for the generator
+// in the package, loads the package and writes the files Generate returns.
+// Files previously generated by the generator in the directories written to
+// that are no longer generated are removed.
+func (g *Generator) Run() error {
+	if g.Name == "" {
+		return fmt.Errorf("generator has no name")
+	}
+	if g.Generate == nil {
+		return fmt.Errorf("generator %v has no Generate function", g.Name)
+	}
+
+	fs := g.flags()
+	if !fs.Parsed() {
+		if err := fs.Parse(os.Args[1:]); err != nil {
+			return err
+		}
+	}
+
+	DefaultLogLevel(g.fLog, LogFatal)
+
+	envFile, ok := os.LookupEnv(GOFILE)
+	if !ok {
+		return fmt.Errorf("env not correct; missing %v", GOFILE)
+	}
+
+	if _, ok := os.LookupEnv(GOPACKAGE); !ok {
+		return fmt.Errorf("env not correct; missing %v", GOPACKAGE)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("unable to get working directory: %v", err)
+	}
+
+	cmd := g.ImportPath
+	if cmd == "" {
+		cmd = g.Name
+	}
+
+	tags, err := BuildTags()
+	if err != nil {
+		return err
+	}
+
+	dirFiles, err := FilesContainingCmd(wd, cmd, tags)
+	if err != nil {
+		return fmt.Errorf("could not determine if we are the first file: %v", err)
+	}
+
+	if len(dirFiles) == 0 {
+		return fmt.Errorf("cannot find any files containing the %v directive", cmd)
+	}
+
+	if dirFiles[envFile] > 1 {
+		return fmt.Errorf("expected a single occurrence of %v directive in %v. Got: %v", cmd, envFile, dirFiles)
+	}
+
+	var files []string
+	for fn := range dirFiles {
+		files = append(files, fn)
+	}
+	sort.Strings(files)
+
+	if files[0] != envFile {
+		g.Infof("skipping %v; %v is the first file containing the %v directive", envFile, files[0], cmd)
+		return nil
+	}
+
+	g.license, err = CommentLicenseHeader(g.fLicense)
+	if err != nil {
+		return fmt.Errorf("could not comment license file: %v", err)
+	}
+
+	pkg, err := g.load(wd, os.Getenv(GOPACKAGE))
+	if err != nil {
+		return err
+	}
+
+	gen, err := g.Generate(pkg)
+	if err != nil {
+		return err
+	}
+
+	return g.write(wd, gen)
+}
+
+// load loads the package named name in dir, without the Go files previously
+// generated by g, because they might well be stale or invalid. Type errors are
+// not fatal, because they might well result from the absence of those files.
+func (g *Generator) load(dir, name string) (*packages.Package, error) {
+	mode := g.Mode
+	if mode == 0 {
+		// with NeedDeps, dependencies are type checked from source rather
+		// than loaded from export data, which would require the package
+		// itself to compile
+		mode = packages.NeedSyntax | packages.NeedTypes | packages.NeedTypesInfo | packages.NeedDeps
+	}
+	mode |= packages.NeedName | packages.NeedFiles | packages.NeedImports
+
+	overlay := make(map[string][]byte)
+	fset := token.NewFileSet()
+	for fn := range g.generated(dir) {
+		if !strings.HasSuffix(fn, ".go") {
+			continue
+		}
+		// preserve the package clause so that test files remain in the
+		// same package
+		pkgName := name
+		if f, err := parser.ParseFile(fset, fn, nil, parser.PackageClauseOnly); err == nil {
+			pkgName = f.Name.Name
+		}
+		overlay[fn] = []byte("package " + pkgName + "\n")
+	}
+
+	conf := &packages.Config{
+		Mode:    mode,
+		Dir:     dir,
+		Tests:   g.Tests,
+		Overlay: overlay,
+	}
+
+	pkgs, err := packages.Load(conf, ".")
+	if err != nil {
+		return nil, fmt.Errorf("could not load package in dir %v: %v", dir, err)
+	}
+
+	var pkg *packages.Package
+	for _, p := range pkgs {
+		if p.Name != name {
+			continue
+		}
+		switch p.ID {
+		case p.PkgPath:
+			if pkg == nil {
+				pkg = p
+			}
+		case p.PkgPath + " [" + p.PkgPath + ".test]":
+			// the package with its test files
+			pkg = p
+		}
+	}
+	if pkg == nil {
+		return nil, fmt.Errorf("could not find package %v in dir %v", name, dir)
+	}
+
+	var msgs []string
+	for _, e := range pkg.Errors {
+		if e.Kind != packages.TypeError {
+			msgs = append(msgs, e.Error())
+		}
+	}
+	if len(msgs) > 0 {
+		return nil, fmt.Errorf("could not load package in dir %v:\n%v", dir, strings.Join(msgs, "\n"))
+	}
+
+	return pkg, nil
+}
+
+// generated returns the files in dir that were generated by g.
+func (g *Generator) generated(dir string) map[string]bool {
+	res := make(map[string]bool)
+
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return res
+	}
+
+	for _, fi := range fis {
+		if fi.Mode().IsRegular() && AnyFileGeneratedBy(fi.Name(), g.Name) {
+			res[filepath.Join(dir, fi.Name())] = true
+		}
+	}
+
+	return res
+}
+
+// outputsExt is the extension of the file, in the directory of a package, in
+// which a Generator records the files it wrote for the package to other
+// directories. Other packages may well share those directories, so only the
+// files recorded are removed when no longer generated.
+const outputsExt = ".outputs"
+
+// write writes files, relative to dir, and removes files previously generated
+// by g for the package in dir that are not in files: those generated by g in
+// dir, and those recorded in the outputs file of g in dir.
+func (g *Generator) write(dir string, files []File) error {
+	outputs := filepath.Join(dir, nameBase("", g.Name)+outputsExt)
+
+	written := map[string]bool{outputs: true}
+	var others []string
+
+	for _, f := range files {
+		rel := f.FileName(g.Name)
+		fn := filepath.Join(dir, rel)
+		if written[fn] {
+			return fmt.Errorf("file %v generated more than once", fn)
+		}
+		written[fn] = true
+		if filepath.Dir(fn) != dir {
+			others = append(others, filepath.ToSlash(rel))
+		}
+
+		toWrite := f.Contents
+
+		if f.isGo() {
+			buf := bytes.NewBuffer(nil)
+			fmt.Fprintf(buf, "// Code generated by %v. DO NOT EDIT.\n\n", g.Name)
+			buf.WriteString(g.license)
+			buf.Write(f.Contents)
+
+			res, err := imports.Process(fn, buf.Bytes(), nil)
+			if err != nil {
+				return fmt.Errorf("could not format %v: %v\n%s", fn, err, buf.Bytes())
+			}
+			toWrite = res
+		}
+
+		if err := g.writeFile(fn, toWrite); err != nil {
+			return err
+		}
+	}
+
+	remove := g.generated(dir)
+	if prev, err := ioutil.ReadFile(outputs); err == nil {
+		for _, rel := range strings.Fields(string(prev)) {
+			remove[filepath.Join(dir, filepath.FromSlash(rel))] = true
+		}
+	}
+
+	if len(others) > 0 {
+		sort.Strings(others)
+		if err := g.writeFile(outputs, []byte(strings.Join(others, "\n")+"\n")); err != nil {
+			return err
+		}
+	} else {
+		written[outputs] = false
+	}
+
+	for fn := range remove {
+		if written[fn] {
+			continue
+		}
+		if err := os.Remove(fn); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return fmt.Errorf("could not remove %v: %v", fn, err)
+		}
+		g.Infof("removed %v", fn)
+	}
+
+	return nil
+}
+
+// writeFile writes contents to fn, creating its directory as required, unless
+// fn already has those contents.
+func (g *Generator) writeFile(fn string, contents []byte) error {
+	// avoid touching files that have not changed
+	if prev, err := ioutil.ReadFile(fn); err == nil && bytes.Equal(prev, contents) {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fn), 0777); err != nil {
+		return fmt.Errorf("could not create directory for %v: %v", fn, err)
+	}
+
+	if err := ioutil.WriteFile(fn, contents, 0666); err != nil {
+		return fmt.Errorf("could not write %v: %v", fn, err)
+	}
+
+	g.Infof("wrote %v", fn)
+
+	return nil
+}
+
+// BuildTags returns the build tags satisfied for a go generate directive: the
+// target operating system and architecture, as given by GOOS and GOARCH, and
+// the build tags given by the -tags flag in GOFLAGS.
+func BuildTags() (map[string]bool, error) {
+	res := make(map[string]bool)
+
+	goos := os.Getenv(GOOS)
+	if goos == "" {
+		goos = runtime.GOOS
+	}
+	res[goos] = true
+
+	goarch := os.Getenv(GOARCH)
+	if goarch == "" {
+		goarch = runtime.GOARCH
+	}
+	res[goarch] = true
+
+	gf, err := ParseGoFlags(os.Getenv("GOFLAGS"))
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range gf.Tags {
+		res[t] = true
+	}
+
+	return res, nil
+}
@@ -0,0 +1,52 @@
+package gogenerate
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// constGen is a Generator used by the script tests. It generates a Go file
+// that declares the names of the exported constants of a package, and
+// optionally a text file of those names in the directory given by
+// -outdir:names
+var constGen = &Generator{
+	Name:  "constGen",
+	Flags: flag.NewFlagSet("constGen", flag.ContinueOnError),
+}
+
+var fConstGenNames = constGen.OutDirFlag("names", "", "directory to which to write the names of constants")
+
+func init() {
+	constGen.Generate = genConstNames
+}
+
+func genConstNames(pkg *packages.Package) ([]File, error) {
+	var names []string
+	scope := pkg.Types.Scope()
+	for _, n := range scope.Names() {
+		if c, ok := scope.Lookup(n).(*types.Const); ok && c.Exported() {
+			names = append(names, fmt.Sprintf("%q", n))
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "package %v\n\nvar ConstNames = []string{%v}\n", pkg.Name, strings.Join(names, ", "))
+
+	res := []File{{Name: "consts", Contents: buf.Bytes()}}
+
+	if *fConstGenNames != "" {
+		res = append(res, File{
+			Name:     pkg.Name,
+			Ext:      ".txt",
+			Dir:      *fConstGenNames,
+			Contents: []byte(strings.Join(names, "\n") + "\n"),
+		})
+	}
+
+	return res, nil
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2016 Paul Jolly <paul@myitcv.org.uk>, all rights reserved.
+// Use of this document is governed by a license found in the LICENSE document.
+
+package gogenerate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// GoFlags are the flags in the value of GOFLAGS, as parsed by ParseGoFlags.
+type GoFlags struct {
+	// Tags are the build tags given by -tags, sorted, and TagsSet indicates
+	// whether -tags was given at all.
+	Tags    []string
+	TagsSet bool
+
+	// Mod and ModFile are the values of -mod and -modfile.
+	Mod     string
+	ModFile string
+
+	// Other are the other flags, as they appear in GOFLAGS.
+	Other []string
+}
+
+// ParseGoFlags parses s, the value of GOFLAGS, consistently with the go
+// command: a space-separated list of flags, each of the form -flag=value, or
+// -flag for boolean flags. Where a flag appears more than once, the last
+// value wins. The value of -tags is a comma-separated list of build tags, or
+// a space-separated list, which can only provide a single tag in GOFLAGS.
+func ParseGoFlags(s string) (GoFlags, error) {
+	var res GoFlags
+	for _, f := range strings.Fields(s) {
+		if !strings.HasPrefix(f, "-") || f == "-" || f == "--" {
+			return res, fmt.Errorf("parsing $GOFLAGS: non-flag %q", f)
+		}
+		name := strings.TrimPrefix(strings.TrimPrefix(f, "-"), "-")
+		var value string
+		var hasValue bool
+		if i := strings.Index(name, "="); i != -1 {
+			name, value, hasValue = name[:i], name[i+1:], true
+		}
+		switch name {
+		case "tags", "mod", "modfile":
+			if !hasValue {
+				return res, fmt.Errorf("parsing $GOFLAGS: flag -%v requires an argument", name)
+			}
+		}
+		switch name {
+		case "tags":
+			res.Tags = splitTags(value)
+			res.TagsSet = true
+		case "mod":
+			res.Mod = value
+		case "modfile":
+			res.ModFile = value
+		default:
+			res.Other = append(res.Other, f)
+		}
+	}
+	return res, nil
+}
+
+// splitTags splits the value of a -tags flag, consistently with the go
+// command, into a sorted list of build tags.
+func splitTags(v string) []string {
+	var res []string
+	sep := strings.Fields
+	if strings.Contains(v, ",") {
+		sep = func(s string) []string {
+			return strings.Split(s, ",")
+		}
+	}
+	for _, t := range sep(v) {
+		if t = strings.TrimSpace(t); t != "" {
+			res = append(res, t)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
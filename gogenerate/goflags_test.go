@@ -0,0 +1,41 @@
+// Copyright (c) 2016 Paul Jolly <paul@myitcv.org.uk>, all rights reserved.
+// Use of this document is governed by a license found in the LICENSE document.
+
+package gogenerate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseGoFlags(t *testing.T) {
+	tests := []struct {
+		in   string
+		want GoFlags
+		err  string
+	}{
+		{"", GoFlags{}, ""},
+		{"-tags=b,a -v", GoFlags{Tags: []string{"a", "b"}, TagsSet: true, Other: []string{"-v"}}, ""},
+		{"-tags=a -tags=b", GoFlags{Tags: []string{"b"}, TagsSet: true}, ""},
+		{"--tags= -mod=vendor -modfile=alt.mod", GoFlags{TagsSet: true, Mod: "vendor", ModFile: "alt.mod"}, ""},
+		{"-tags", GoFlags{}, "parsing $GOFLAGS: flag -tags requires an argument"},
+		{"tags=a", GoFlags{}, `parsing $GOFLAGS: non-flag "tags=a"`},
+	}
+
+	for _, test := range tests {
+		got, err := ParseGoFlags(test.in)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("ParseGoFlags(%q) gave error %v; want %q", test.in, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseGoFlags(%q) gave unexpected error: %v", test.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseGoFlags(%q) = %+v; want %+v", test.in, got, test.want)
+		}
+	}
+}
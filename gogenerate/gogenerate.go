@@ -4,7 +4,8 @@
 // Package gogenerate exposes some of the unexported internals of the go generate command as a convenience
 // for the authors of go generate generators. See https://github.com/myitcv/gogenerate/wiki/Go-Generate-Notes
 // for further notes on such generators. It also exposes some convenience functions that might be useful
-// to authors of generators
+// to authors of generators, along with Generator, which takes care of the boilerplate common to
+// generators that generate files for the package in which their directive appears.
 //
 package gogenerate
 
@@ -48,6 +48,10 @@ func TestMain(m *testing.M) {
 
 			return 0
 		},
+		"constGen": func() int {
+			constGen.Main()
+			return 0
+		},
 	}))
 }
 
@@ -0,0 +1,415 @@
+// Copyright (c) 2016 Paul Jolly <paul@myitcv.org.uk>, all rights reserved.
+// Use of this document is governed by a license found in the LICENSE document.
+
+package main
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"myitcv.io/immutable/util"
+)
+
+const (
+	// binaryTag is the key of the struct tag that gives the tag number of a
+	// field of a struct template with the binary option
+	binaryTag = "binary"
+
+	wireImportPath = "myitcv.io/immutable/wire"
+)
+
+// binaryCodec describes how values of a type are encoded and decoded by the
+// generated MarshalBinary and UnmarshalBinary methods
+type binaryCodec struct {
+	// method is the name of the wire.Encoder method used to encode values,
+	// and (but for Marshaler, decoded by Unmarshaler) of the wire.Decoder
+	// method used to decode them
+	method string
+
+	// conv is the type to which values are converted to be encoded, in the
+	// case of the basic kinds
+	conv string
+
+	// typ is the type of values as it appears in the source
+	typ string
+
+	// imm is true if the type is an immutable type, i.e. a pointer that may
+	// be nil
+	imm bool
+}
+
+// binaryCodec returns the codec for values of type t, which appears in the
+// source at pos as exp.
+func (o *output) binaryCodec(pos token.Position, t types.Type, exp string) binaryCodec {
+	res := binaryCodec{
+		method: "Value",
+		typ:    exp,
+	}
+
+	switch o.isImm(t, exp).(type) {
+	case util.ImmTypeStruct, util.ImmTypeMap, util.ImmTypeSet, util.ImmTypeSlice:
+		if tmpl, ok := o.immTmpls[exp]; ok {
+			if !tmpl.options().binary {
+				fatalf("%v: %v must also have the %v option", pos, strings.TrimPrefix(exp, "*"), optBinary)
+			}
+		} else if typeIsInvalid(t) || types.NewMethodSet(t).Lookup(nil, "MarshalBinary") == nil {
+			fatalf("%v: %v was not generated with the %v option", pos, exp, optBinary)
+		}
+
+		res.method = "Marshaler"
+		res.imm = true
+
+		return res
+	}
+
+	if typeIsInvalid(t) {
+		return res
+	}
+
+	if types.NewMethodSet(t).Lookup(nil, "MarshalBinary") != nil &&
+		types.NewMethodSet(types.NewPointer(t)).Lookup(nil, "UnmarshalBinary") != nil {
+		res.method = "Marshaler"
+		return res
+	}
+
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch i := u.Info(); {
+		case i&types.IsBoolean != 0:
+			res.method, res.conv = "Bool", "bool"
+		case i&types.IsUnsigned != 0:
+			res.method, res.conv = "Uint", "uint64"
+		case i&types.IsInteger != 0:
+			res.method, res.conv = "Int", "int64"
+		case i&types.IsFloat != 0:
+			res.method, res.conv = "Float", "float64"
+		case i&types.IsString != 0:
+			res.method, res.conv = "String", "string"
+		}
+	case *types.Slice:
+		if b, ok := u.Elem().(*types.Basic); ok && b.Kind() == types.Byte {
+			res.method, res.conv = "Bytes", "[]byte"
+		}
+	}
+
+	return res
+}
+
+// encode returns a statement that encodes v as field tag using the
+// wire.Encoder e.
+func (c binaryCodec) encode(e string, tag uint64, v string) string {
+	switch {
+	case c.conv == c.typ && c.conv != "":
+		return fmt.Sprintf("%v.%v(%v, %v)", e, c.method, tag, v)
+	case c.conv != "":
+		return fmt.Sprintf("%v.%v(%v, %v(%v))", e, c.method, tag, c.conv, v)
+	case c.imm:
+		return fmt.Sprintf("if %v != nil {\n%v.%v(%v, %v)\n}", v, e, c.method, tag, v)
+	default:
+		return fmt.Sprintf("%v.%v(%v, %v)", e, c.method, tag, v)
+	}
+}
+
+// decode returns a statement that decodes the current field of the
+// wire.Decoder d into dst.
+func (c binaryCodec) decode(d string, dst string) string {
+	method := c.method
+	if method == "Marshaler" {
+		method = "Unmarshaler"
+	}
+
+	switch {
+	case c.conv == c.typ && c.conv != "":
+		return fmt.Sprintf("%v = %v.%v()", dst, d, method)
+	case c.conv != "":
+		return fmt.Sprintf("%v = %v(%v.%v())", dst, c.typ, d, method)
+	case c.imm:
+		return fmt.Sprintf("%v = new(%v)\n%v.%v(%v)", dst, strings.TrimPrefix(c.typ, "*"), d, method, dst)
+	default:
+		return fmt.Sprintf("%v.%v(&%v)", d, method, dst)
+	}
+}
+
+// binaryField describes a field of an immutable struct for the purposes of
+// generating its MarshalBinary and UnmarshalBinary methods
+type binaryField struct {
+	Tag    uint64
+	Encode string
+	Decode string
+}
+
+// binaryFieldTag returns the tag number given by the binary key of the struct
+// tag of a field (including quotes), and false if there is none.
+func binaryFieldTag(pos token.Position, tag string) (uint64, bool) {
+	if tag == "" {
+		return 0, false
+	}
+
+	st, err := strconv.Unquote(tag)
+	if err != nil {
+		fatalf("%v: invalid struct tag %v: %v", pos, tag, err)
+	}
+
+	v, ok := reflect.StructTag(st).Lookup(binaryTag)
+	if !ok {
+		return 0, false
+	}
+
+	n, err := strconv.ParseUint(v, 10, 32)
+	if err != nil || n == 0 {
+		fatalf("%v: %v tag must be a positive integer; got %q", pos, binaryTag, v)
+	}
+
+	return n, true
+}
+
+// genStructBinary generates MarshalBinary and UnmarshalBinary for the struct
+// s.
+func (o *output) genStructBinary(s *immStruct, fields []binaryField) {
+	o.extraImports[wireImportPath] = true
+
+	tmpl := struct {
+		Name   string
+		Fields []binaryField
+	}{
+		Name:   s.name,
+		Fields: fields,
+	}
+
+	o.pt(`
+	// MarshalBinary implements encoding.BinaryMarshaler. The fields of the template
+	// for {{.Name}} with a binary struct tag are encoded, each identified by its tag
+	// number. See myitcv.io/immutable/wire for details of the format.
+	func (s *{{.Name}}) MarshalBinary() ([]byte, error) {
+		var e wire.Encoder
+
+		if s == nil {
+			return e.Result()
+		}
+	{{range .Fields}}
+		{{.Encode}}
+	{{- end}}
+
+		return e.Result()
+	}
+
+	// UnmarshalBinary implements encoding.BinaryUnmarshaler. Fields with tag
+	// numbers unknown to the template for {{.Name}} are ignored, and fields of s
+	// that are not present in b are left unchanged.
+	func (s *{{.Name}}) UnmarshalBinary(b []byte) error {
+		d := wire.NewDecoder(b)
+
+		for d.Next() {
+	{{- if .Fields}}
+			switch d.Tag() {
+	{{- range .Fields}}
+			case {{.Tag}}:
+				{{.Decode}}
+	{{- end}}
+			}
+	{{- end}}
+		}
+
+		return d.Err()
+	}
+	`, exporter(s.name), tmpl)
+}
+
+// genMapBinary generates MarshalBinary and UnmarshalBinary for the map m.
+func (o *output) genMapBinary(m *immMap) {
+	o.extraImports[wireImportPath] = true
+
+	pos := m.fset.Position(m.syn.Pos())
+
+	keyType := o.exprString(m.syn.Key)
+	valType := o.exprString(m.syn.Value)
+
+	kc := o.binaryCodec(pos, m.typ.Key(), keyType)
+	vc := o.binaryCodec(pos, m.typ.Elem(), valType)
+
+	tmpl := struct {
+		Name      string
+		KeyType   string
+		ValType   string
+		EncodeKey string
+		EncodeVal string
+		DecodeKey string
+		DecodeVal string
+		Ordered   bool
+	}{
+		Name:      m.name,
+		KeyType:   keyType,
+		ValType:   valType,
+		EncodeKey: kc.encode("e", 1, "k"),
+		EncodeVal: vc.encode("e", 2, "v"),
+		DecodeKey: kc.decode("ed", "k"),
+		DecodeVal: vc.decode("ed", "v"),
+		Ordered:   m.opts.ordered,
+	}
+
+	o.pt(`
+	// MarshalBinary implements encoding.BinaryMarshaler. Each entry of m is
+	// encoded as a message of its key and value. See myitcv.io/immutable/wire for
+	// details of the format.
+	func (m *{{.Name}}) MarshalBinary() ([]byte, error) {
+		var e wire.Encoder
+
+		if m == nil {
+			return e.Result()
+		}
+	{{if .Ordered}}
+		for _, k := range m.theKeys {
+			v := m.theMap[k]
+	{{- else}}
+		for k, v := range m.Range() {
+	{{- end}}
+			e.Message(1, func(e *wire.Encoder) {
+				{{.EncodeKey}}
+				{{.EncodeVal}}
+			})
+		}
+
+		return e.Result()
+	}
+
+	// UnmarshalBinary implements encoding.BinaryUnmarshaler. m is set to an
+	// immutable map containing the decoded entries.
+	func (m *{{.Name}}) UnmarshalBinary(b []byte) error {
+		d := wire.NewDecoder(b)
+
+		res := {{Export "New"}}{{Capitalise .Name}}(func(mi *{{.Name}}) {
+			for d.Next() {
+				if d.Tag() != 1 {
+					continue
+				}
+
+				var k {{.KeyType}}
+				var v {{.ValType}}
+
+				ed := d.Message()
+				for ed.Next() {
+					switch ed.Tag() {
+					case 1:
+						{{.DecodeKey}}
+					case 2:
+						{{.DecodeVal}}
+					}
+				}
+
+				mi.Set(k, v)
+			}
+		})
+
+		if err := d.Err(); err != nil {
+			return err
+		}
+
+		*m = *res
+
+		return nil
+	}
+	`, exporter(m.name), tmpl)
+}
+
+// genSliceBinary generates MarshalBinary and UnmarshalBinary for the slice s.
+func (o *output) genSliceBinary(s *immSlice) {
+	o.extraImports[wireImportPath] = true
+
+	typ := o.exprString(s.syn.Elt)
+	c := o.binaryCodec(s.fset.Position(s.syn.Pos()), s.typ.Elem(), typ)
+
+	o.genElemsBinary(s.name, typ, c, "slice", "in order")
+}
+
+// genSetBinary generates MarshalBinary and UnmarshalBinary for the set s.
+func (o *output) genSetBinary(s *immSet) {
+	o.extraImports[wireImportPath] = true
+
+	typ := o.exprString(s.syn.Key)
+	c := o.binaryCodec(s.fset.Position(s.syn.Pos()), s.typ.Key(), typ)
+
+	o.genElemsBinary(s.name, typ, c, "set", "in no particular order")
+}
+
+// genElemsBinary generates MarshalBinary and UnmarshalBinary for the slice or
+// set (kind) name, the elements of which are of type typ.
+func (o *output) genElemsBinary(name, typ string, c binaryCodec, kind, order string) {
+	tmpl := struct {
+		Name   string
+		Type   string
+		Kind   string
+		Order  string
+		Set    bool
+		Encode string
+		Decode string
+	}{
+		Name:   name,
+		Type:   typ,
+		Kind:   kind,
+		Order:  order,
+		Set:    kind == "set",
+		Encode: c.encode("e", 1, "v"),
+		Decode: c.decode("ed", "v"),
+	}
+
+	o.pt(`
+	// MarshalBinary implements encoding.BinaryMarshaler. The elements of m are
+	// encoded {{.Order}}, each as a message. See myitcv.io/immutable/wire for
+	// details of the format.
+	func (m *{{.Name}}) MarshalBinary() ([]byte, error) {
+		var e wire.Encoder
+
+		if m == nil {
+			return e.Result()
+		}
+	{{if .Set}}
+		for v := range m.Range() {
+	{{- else}}
+		for _, v := range m.Range() {
+	{{- end}}
+			e.Message(1, func(e *wire.Encoder) {
+				{{.Encode}}
+			})
+		}
+
+		return e.Result()
+	}
+
+	// UnmarshalBinary implements encoding.BinaryUnmarshaler. m is set to an
+	// immutable {{.Kind}} containing the decoded elements.
+	func (m *{{.Name}}) UnmarshalBinary(b []byte) error {
+		var vs []{{.Type}}
+
+		d := wire.NewDecoder(b)
+
+		for d.Next() {
+			if d.Tag() != 1 {
+				continue
+			}
+
+			var v {{.Type}}
+
+			ed := d.Message()
+			for ed.Next() {
+				if ed.Tag() == 1 {
+					{{.Decode}}
+				}
+			}
+
+			vs = append(vs, v)
+		}
+
+		if err := d.Err(); err != nil {
+			return err
+		}
+
+		*m = *{{Export "New"}}{{Capitalise .Name}}(vs...)
+
+		return nil
+	}
+	`, exporter(name), tmpl)
+}
@@ -0,0 +1,530 @@
+// Copyright (c) 2016 Paul Jolly <paul@myitcv.org.uk>, all rights reserved.
+// Use of this document is governed by a license found in the LICENSE document.
+
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"strings"
+
+	"myitcv.io/immutable/util"
+)
+
+const (
+	// diffTypeSuffix is appended to the name of an immutable type to give the
+	// name of its change set type
+	diffTypeSuffix = "Diff"
+
+	// the names of the fields common to all change set types
+	diffReplacedField = "Replaced"
+	diffValueField    = "Value"
+)
+
+// diffField describes a field of an immutable struct for the purposes of
+// generating its Diff and Patch methods
+type diffField struct {
+	// the name of the field in the generated struct
+	Field string
+
+	// the name of the field in the template, and hence in the change set
+	Name string
+
+	// the type of the field, in case the field is not itself diffable, or
+	// the change set type of the field's type
+	Type string
+
+	// whether the field's type has a Diff method
+	Diff bool
+
+	// NotEqual is an expression that is true if the field values in s and
+	// other differ
+	NotEqual string
+}
+
+// diffType returns the change set type of the type t (which appears in the
+// source as exp and is classified by isImm as imm), and whether values of
+// type t can be diffed. The immutable types that can be diffed are those
+// whose IsDeeplyNonMutable method is traversed by that of a containing
+// struct, with the exception of interfaces and types that are simply
+// immutable, neither of which have a Diff method.
+func (o *output) diffType(imm util.ImmType, t types.Type, exp string) (string, bool) {
+	switch imm.(type) {
+	case util.ImmTypeStruct, util.ImmTypeMap, util.ImmTypeSet, util.ImmTypeSlice:
+	default:
+		return "", false
+	}
+
+	dt := strings.TrimPrefix(exp, "*") + diffTypeSuffix
+
+	// types we are generating will have a Diff method regardless of what
+	// any existing generated code in the package says
+	if _, ok := o.immTmpls[exp]; ok {
+		return dt, true
+	}
+
+	if typeIsInvalid(t) {
+		return "", false
+	}
+
+	// types generated by earlier versions of immutableGen will not have a
+	// Diff method
+	if types.NewMethodSet(t).Lookup(nil, "Diff") == nil {
+		return "", false
+	}
+
+	return dt, true
+}
+
+// notEqual returns an expression that is true if a and b, both of type t,
+// are not equal. Where values of type t are not comparable, reflect.DeepEqual
+// is used.
+func (o *output) notEqual(t types.Type, a, b string) string {
+	if typeIsInvalid(t) || types.Comparable(t) {
+		return fmt.Sprintf("%v != %v", a, b)
+	}
+
+	o.extraImports["reflect"] = true
+
+	return fmt.Sprintf("!reflect.DeepEqual(%v, %v)", a, b)
+}
+
+func (o *output) genStructDiff(s *immStruct, fields []diffField) {
+	for _, f := range fields {
+		if f.Name == diffReplacedField || f.Name == diffValueField {
+			fatalf("%v: field name %v clashes with the generated %v%v type", s.fset.Position(s.syn.Pos()), f.Name, s.name, diffTypeSuffix)
+		}
+	}
+
+	exp := exporter(s.name)
+
+	tmpl := struct {
+		Name    string
+		Fields  []diffField
+		Special bool
+	}{
+		Name:    s.name,
+		Fields:  fields,
+		Special: s.special != notSpecial,
+	}
+
+	o.pt(`
+	// {{.Name}}Diff is the change set between two {{.Name}} values, as returned by
+	// {{.Name}}.Diff. If Replaced is true then the value was replaced wholesale by
+	// Value (which may be nil) and no other fields are set. Otherwise a non-nil
+	// field holds either the new value of the corresponding field or, where the
+	// field is itself of an immutable type, the change set for that field.
+	{{- if .Special}}
+	// The Key of a {{.Name}} is not part of the change set: its Uuid identifies
+	// the entity and its Version is maintained by {{.Name}} itself.
+	{{- end}}
+	type {{.Name}}Diff struct {
+		Replaced bool
+		Value    *{{.Name}}
+
+	{{range .Fields}}
+		{{.Name}} *{{.Type}}
+	{{- end}}
+	}
+
+	// Diff returns the change set required to turn s into other, or nil if there
+	// is no difference.
+	{{- if .Special}} Values with different Key Uuids are different entities, and
+	// other replaces s wholesale. Values with the same Key Uuid and Version are
+	// the same version of the same entity, and have no difference.
+	{{- end}}
+	func (s *{{.Name}}) Diff(other *{{.Name}}) *{{.Name}}Diff {
+		if s == other {
+			return nil
+		}
+
+		if s == nil || other == nil {
+			return &{{.Name}}Diff{Replaced: true, Value: other}
+		}
+	{{if .Special}}
+		if s.field_Key.Uuid != other.field_Key.Uuid {
+			return &{{.Name}}Diff{Replaced: true, Value: other}
+		}
+
+		if s.field_Key.Version == other.field_Key.Version {
+			return nil
+		}
+	{{end}}
+		var res {{.Name}}Diff
+		changed := false
+	{{range .Fields}}
+		{{- if .Diff}}
+		if d := s.{{.Field}}.Diff(other.{{.Field}}); d != nil {
+			res.{{.Name}} = d
+			changed = true
+		}
+		{{- else}}
+		if {{.NotEqual}} {
+			v := other.{{.Field}}
+			res.{{.Name}} = &v
+			changed = true
+		}
+		{{- end}}
+	{{end}}
+		if !changed {
+			return nil
+		}
+
+		return &res
+	}
+
+	// Patch returns the result of applying the change set d, as returned by Diff,
+	// to s.
+	{{- if .Special}} As with any other change to s, the Version of the Key of the
+	// result is bumped.
+	{{- end}}
+	func (s *{{.Name}}) Patch(d *{{.Name}}Diff) *{{.Name}} {
+		if d == nil {
+			return s
+		}
+
+		if d.Replaced {
+			return d.Value
+		}
+
+		if s == nil {
+			s = new({{.Name}})
+		}
+
+		return s.WithMutable(func(si *{{.Name}}) {
+		{{- range .Fields}}
+			{{- if .Diff}}
+			if d.{{.Name}} != nil {
+				si.{{.Field}} = si.{{.Field}}.Patch(d.{{.Name}})
+			}
+			{{- else}}
+			if d.{{.Name}} != nil {
+				si.{{.Field}} = *d.{{.Name}}
+			}
+			{{- end}}
+		{{- end}}
+		})
+	}
+	`, exp, tmpl)
+}
+
+func (o *output) genMapDiff(m *immMap) {
+	keyType := o.exprString(m.syn.Key)
+	valType := o.exprString(m.syn.Value)
+	valDiffType, valDiff := o.diffType(o.isImm(m.typ.Elem(), valType), m.typ.Elem(), valType)
+
+	tmpl := struct {
+		Name        string
+		KeyType     string
+		ValType     string
+		ValDiffType string
+		ValDiff     bool
+		NotEqual    string
+	}{
+		Name:        m.name,
+		KeyType:     keyType,
+		ValType:     valType,
+		ValDiffType: valDiffType,
+		ValDiff:     valDiff,
+		NotEqual:    o.notEqual(m.typ.Elem(), "v", "ov"),
+	}
+
+	o.pt(`
+	// {{.Name}}Diff is the change set between two {{.Name}} values, as returned by
+	// {{.Name}}.Diff. If Replaced is true then the value was replaced wholesale by
+	// Value (which may be nil) and no other fields are set.
+	type {{.Name}}Diff struct {
+		Replaced bool
+		Value    *{{.Name}}
+
+		// Set holds the entries that were added{{if not .ValDiff}} or changed{{end}}
+		Set map[{{.KeyType}}]{{.ValType}}
+	{{if .ValDiff}}
+		// Changed holds the change sets of the entries that were changed
+		Changed map[{{.KeyType}}]*{{.ValDiffType}}
+	{{end}}
+		// Del holds the keys of the entries that were deleted, in no particular
+		// order
+		Del []{{.KeyType}}
+	}
+
+	// Diff returns the change set required to turn m into other, or nil if there
+	// is no difference.
+	func (m *{{.Name}}) Diff(other *{{.Name}}) *{{.Name}}Diff {
+		if m == other {
+			return nil
+		}
+
+		if m == nil || other == nil {
+			return &{{.Name}}Diff{Replaced: true, Value: other}
+		}
+
+		res := &{{.Name}}Diff{
+			Set: make(map[{{.KeyType}}]{{.ValType}}),
+	{{- if .ValDiff}}
+			Changed: make(map[{{.KeyType}}]*{{.ValDiffType}}),
+	{{- end}}
+		}
+
+		for k := range m.Range() {
+			if _, ok := other.Get(k); !ok {
+				res.Del = append(res.Del, k)
+			}
+		}
+
+		for k, ov := range other.Range() {
+			v, ok := m.Get(k)
+			if !ok {
+				res.Set[k] = ov
+				continue
+			}
+	{{if .ValDiff}}
+			if d := v.Diff(ov); d != nil {
+				res.Changed[k] = d
+			}
+	{{- else}}
+			if {{.NotEqual}} {
+				res.Set[k] = ov
+			}
+	{{- end}}
+		}
+
+		if len(res.Set) == 0 && len(res.Del) == 0{{if .ValDiff}} && len(res.Changed) == 0{{end}} {
+			return nil
+		}
+
+		return res
+	}
+
+	// Patch returns the result of applying the change set d, as returned by Diff,
+	// to m.
+	func (m *{{.Name}}) Patch(d *{{.Name}}Diff) *{{.Name}} {
+		if d == nil {
+			return m
+		}
+
+		if d.Replaced {
+			return d.Value
+		}
+
+		if m == nil {
+			m = {{Export "New"}}{{Capitalise .Name}}()
+		}
+
+		return m.WithMutable(func(mi *{{.Name}}) {
+			for _, k := range d.Del {
+				mi.Del(k)
+			}
+
+			for k, v := range d.Set {
+				mi.Set(k, v)
+			}
+	{{- if .ValDiff}}
+
+			for k, vd := range d.Changed {
+				v, _ := mi.Get(k)
+				mi.Set(k, v.Patch(vd))
+			}
+	{{- end}}
+		})
+	}
+	`, exporter(m.name), tmpl)
+}
+
+func (o *output) genSliceDiff(s *immSlice) {
+	valType := o.exprString(s.syn.Elt)
+	valDiffType, valDiff := o.diffType(o.isImm(s.typ.Elem(), valType), s.typ.Elem(), valType)
+
+	tmpl := struct {
+		Name        string
+		ValType     string
+		ValDiffType string
+		ValDiff     bool
+		NotEqual    string
+	}{
+		Name:        s.name,
+		ValType:     valType,
+		ValDiffType: valDiffType,
+		ValDiff:     valDiff,
+		NotEqual:    o.notEqual(s.typ.Elem(), "v", "ov"),
+	}
+
+	o.pt(`
+	// {{.Name}}Diff is the change set between two {{.Name}} values, as returned by
+	// {{.Name}}.Diff. If Replaced is true then the value was replaced wholesale by
+	// Value (which may be nil) and no other fields are set.
+	type {{.Name}}Diff struct {
+		Replaced bool
+		Value    *{{.Name}}
+
+		// Len is the length of the new value; elements beyond Len are truncated
+		// and elements are appended to reach Len as required
+		Len int
+
+		// Set holds the elements, by index, that were added{{if not .ValDiff}} or changed{{end}}
+		Set map[int]{{.ValType}}
+	{{if .ValDiff}}
+		// Changed holds the change sets, by index, of the elements that were
+		// changed
+		Changed map[int]*{{.ValDiffType}}
+	{{end}}
+	}
+
+	// Diff returns the change set required to turn m into other, or nil if there
+	// is no difference. Elements are compared by index.
+	func (m *{{.Name}}) Diff(other *{{.Name}}) *{{.Name}}Diff {
+		if m == other {
+			return nil
+		}
+
+		if m == nil || other == nil {
+			return &{{.Name}}Diff{Replaced: true, Value: other}
+		}
+
+		res := &{{.Name}}Diff{
+			Len: other.Len(),
+			Set: make(map[int]{{.ValType}}),
+	{{- if .ValDiff}}
+			Changed: make(map[int]*{{.ValDiffType}}),
+	{{- end}}
+		}
+
+		for i := 0; i < other.Len(); i++ {
+			ov := other.Get(i)
+			if i >= m.Len() {
+				res.Set[i] = ov
+				continue
+			}
+
+			v := m.Get(i)
+	{{if .ValDiff}}
+			if d := v.Diff(ov); d != nil {
+				res.Changed[i] = d
+			}
+	{{- else}}
+			if {{.NotEqual}} {
+				res.Set[i] = ov
+			}
+	{{- end}}
+		}
+
+		if m.Len() == other.Len() && len(res.Set) == 0{{if .ValDiff}} && len(res.Changed) == 0{{end}} {
+			return nil
+		}
+
+		return res
+	}
+
+	// Patch returns the result of applying the change set d, as returned by Diff,
+	// to m.
+	func (m *{{.Name}}) Patch(d *{{.Name}}Diff) *{{.Name}} {
+		if d == nil {
+			return m
+		}
+
+		if d.Replaced {
+			return d.Value
+		}
+
+		if m == nil {
+			m = new({{.Name}})
+		}
+
+		return m.WithMutable(func(mi *{{.Name}}) {
+			if l := mi.Len(); l > d.Len {
+				mi.Slice(0, d.Len)
+			} else if l < d.Len {
+				mi.Append(make([]{{.ValType}}, d.Len-l)...)
+			}
+
+			for i, v := range d.Set {
+				mi.Set(i, v)
+			}
+	{{- if .ValDiff}}
+
+			for i, vd := range d.Changed {
+				mi.Set(i, mi.Get(i).Patch(vd))
+			}
+	{{- end}}
+		})
+	}
+	`, exporter(s.name), tmpl)
+}
+
+func (o *output) genSetDiff(s *immSet) {
+	tmpl := struct {
+		Name string
+		Type string
+	}{
+		Name: s.name,
+		Type: o.exprString(s.syn.Key),
+	}
+
+	o.pt(`
+	// {{.Name}}Diff is the change set between two {{.Name}} values, as returned by
+	// {{.Name}}.Diff. If Replaced is true then the value was replaced wholesale by
+	// Value (which may be nil) and no other fields are set.
+	type {{.Name}}Diff struct {
+		Replaced bool
+		Value    *{{.Name}}
+
+		// Add and Remove hold the elements that were added and removed
+		// respectively, in no particular order
+		Add    []{{.Type}}
+		Remove []{{.Type}}
+	}
+
+	// Diff returns the change set required to turn m into other, or nil if there
+	// is no difference.
+	func (m *{{.Name}}) Diff(other *{{.Name}}) *{{.Name}}Diff {
+		if m == other {
+			return nil
+		}
+
+		if m == nil || other == nil {
+			return &{{.Name}}Diff{Replaced: true, Value: other}
+		}
+
+		res := new({{.Name}}Diff)
+
+		for v := range other.Range() {
+			if !m.Contains(v) {
+				res.Add = append(res.Add, v)
+			}
+		}
+
+		for v := range m.Range() {
+			if !other.Contains(v) {
+				res.Remove = append(res.Remove, v)
+			}
+		}
+
+		if len(res.Add) == 0 && len(res.Remove) == 0 {
+			return nil
+		}
+
+		return res
+	}
+
+	// Patch returns the result of applying the change set d, as returned by Diff,
+	// to m.
+	func (m *{{.Name}}) Patch(d *{{.Name}}Diff) *{{.Name}} {
+		if d == nil {
+			return m
+		}
+
+		if d.Replaced {
+			return d.Value
+		}
+
+		if m == nil {
+			m = {{Export "New"}}{{Capitalise .Name}}()
+		}
+
+		return m.WithMutable(func(mi *{{.Name}}) {
+			mi.Remove(d.Remove...)
+			mi.Add(d.Add...)
+		})
+	}
+	`, exporter(s.name), tmpl)
+}
@@ -147,6 +147,10 @@ type output struct {
 
 	output *bytes.Buffer
 
+	// extraImports are the import paths, in addition to those of the
+	// templates, required by the file we are generating
+	extraImports map[string]bool
+
 	immTmpls map[string]immTmpl
 
 	// a convenience map of all the imm types we will be generating in this
@@ -171,6 +175,7 @@ type fileTmpls struct {
 	imports map[*ast.ImportSpec]struct{}
 
 	maps    []*immMap
+	sets    []*immSet
 	slices  []*immSlice
 	structs []*immStruct
 }
@@ -281,10 +286,61 @@ func (o *output) gatherImmTypes() {
 				file: file,
 				pkg:  pkgPath,
 				dec:  gd,
+				opts: parseTmplOpts(gd, ts),
+			}
+
+			if _, ok := typ.Underlying().(*types.Map); !ok && comm.opts.hamt {
+				fatalf("%v: option %v is only valid on map templates", fset.Position(ts.Pos()), optHamt)
+			}
+
+			if _, ok := typ.Underlying().(*types.Slice); !ok && comm.opts.vector {
+				fatalf("%v: option %v is only valid on slice templates", fset.Position(ts.Pos()), optVector)
+			}
+
+			if comm.opts.ordered {
+				u, ok := typ.Underlying().(*types.Map)
+				if !ok {
+					fatalf("%v: option %v is only valid on map templates", fset.Position(ts.Pos()), optOrdered)
+				}
+				if comm.opts.hamt {
+					fatalf("%v: option %v cannot be combined with option %v", fset.Position(ts.Pos()), optOrdered, optHamt)
+				}
+				if comm.opts.order != "" {
+					checkOrderFunc(fset.Position(ts.Pos()), typ.Obj().Pkg(), comm.opts.order, u.Key())
+				}
+			}
+
+			if comm.opts.set {
+				u, ok := typ.Underlying().(*types.Map)
+				if !ok {
+					fatalf("%v: option %v is only valid on map templates", fset.Position(ts.Pos()), optSet)
+				}
+				if st, ok := u.Elem().(*types.Struct); !ok || st.NumFields() != 0 {
+					fatalf("%v: option %v is only valid on templates of the form map[T]struct{}", fset.Position(ts.Pos()), optSet)
+				}
+				if comm.opts.hamt || comm.opts.ordered {
+					fatalf("%v: option %v cannot be combined with other options", fset.Position(ts.Pos()), optSet)
+				}
 			}
 
 			switch u := typ.Underlying().(type) {
 			case *types.Map:
+				if comm.opts.set {
+					s := &immSet{
+						commonImm: comm,
+						name:      name,
+						typ:       u,
+						syn:       ts.Type.(*ast.MapType),
+					}
+					g.sets = append(g.sets, s)
+					o.immTypes["*"+name] = util.ImmTypeSet{}
+					o.immTmpls["*"+name] = s
+
+					ast.Walk(impf, ts.Type)
+
+					break
+				}
+
 				m := &immMap{
 					commonImm: comm,
 					name:      name,
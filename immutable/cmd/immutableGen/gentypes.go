@@ -10,6 +10,7 @@ import (
 	"go/token"
 	"io/ioutil"
 	"os/exec"
+	"sort"
 	"strings"
 
 	"myitcv.io/gogenerate"
@@ -19,10 +20,22 @@ func (o *output) genImmTypes() {
 	for f, v := range o.files {
 		o.curFile = f
 
-		if len(v.maps) == 0 && len(v.slices) == 0 && len(v.structs) == 0 {
+		if len(v.maps) == 0 && len(v.sets) == 0 && len(v.slices) == 0 && len(v.structs) == 0 {
 			continue
 		}
 
+		// generate the declarations first so that we know which additional
+		// imports they require
+		o.output = bytes.NewBuffer(nil)
+		o.extraImports = make(map[string]bool)
+
+		o.genImmMaps(v.maps)
+		o.genImmSets(v.sets)
+		o.genImmSlices(v.slices)
+		o.genImmStructs(v.structs)
+
+		decls := o.output
+
 		o.output = bytes.NewBuffer(nil)
 
 		o.pfln("// Code generated by %v. DO NOT EDIT.", immutableGenCmd)
@@ -43,6 +56,30 @@ func (o *output) genImmTypes() {
 		o.pln("import (")
 
 		o.pln("\"myitcv.io/immutable\"")
+
+		for _, m := range v.maps {
+			if m.opts.hamt {
+				o.pln("\"myitcv.io/immutable/hamt\"")
+				break
+			}
+		}
+
+		for _, s := range v.slices {
+			if s.opts.vector {
+				o.pln("\"myitcv.io/immutable/vector\"")
+				break
+			}
+		}
+
+		var extra []string
+		for i := range o.extraImports {
+			extra = append(extra, i)
+		}
+		sort.Strings(extra)
+
+		for _, i := range extra {
+			o.pfln("%q", i)
+		}
 		o.pln()
 
 		for i := range v.imports {
@@ -57,9 +94,7 @@ func (o *output) genImmTypes() {
 
 		o.pln("")
 
-		o.genImmMaps(v.maps)
-		o.genImmSlices(v.slices)
-		o.genImmStructs(v.structs)
+		o.output.Write(decls.Bytes())
 
 		source := o.output.Bytes()
 
@@ -91,9 +126,16 @@ func (o *output) genImmTypes() {
 	}
 }
 
-func (o *output) printImmPreamble(name string, node ast.Node) {
+// printImmPreamble prints the doc comment of the immutable type name,
+// followed by its template. The comment starts on a line of its own, after
+// whatever was generated for the previous type, so that gofmt formats it as
+// a doc comment.
+func (o *output) printImmPreamble(name string, doc *ast.CommentGroup, node ast.Node) {
 	fset := o.fset
 
+	o.pln()
+	o.printCommentGroup(doc)
+
 	if st, ok := node.(*ast.StructType); ok {
 
 		// we need to do some manipulation
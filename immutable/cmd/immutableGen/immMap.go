@@ -25,23 +25,40 @@ func (o *output) genImmMaps(maps []*immMap) {
 			VarName string
 			KeyType string
 			ValType string
+			Order   string
 		}{
 			Name:    m.name,
 			VarName: genVarName(m.name),
 			KeyType: o.exprString(m.syn.Key),
 			ValType: o.exprString(m.syn.Value),
+			Order:   m.opts.order,
 		}
 
 		exp := exporter(m.name)
 
-		o.printCommentGroup(m.dec.Doc)
-		o.printImmPreamble(m.name, m.syn)
+		o.printImmPreamble(m.name, m.dec.Doc, m.syn)
 
 		// start of struct
 		o.pfln("type %v struct {", m.name)
 		o.pln("")
 
-		o.pfln("theMap map[%v]%v", blanks.KeyType, blanks.ValType)
+		mapTmpl := immMapTmpl
+		if m.opts.hamt {
+			if _, ok := m.typ.Key().Underlying().(*types.Interface); ok {
+				fatalf("%v: option %v does not support interface key types", m.fset.Position(m.syn.Pos()), optHamt)
+			}
+			mapTmpl = immHamtMapTmpl
+			o.pfln("theMap *hamt.Map[%v, %v]", blanks.KeyType, blanks.ValType)
+		} else {
+			o.pfln("theMap map[%v]%v", blanks.KeyType, blanks.ValType)
+		}
+		if m.opts.ordered {
+			mapTmpl = immOrderedMapTmpl
+			o.pfln("theKeys []%v", blanks.KeyType)
+			if m.opts.order != "" {
+				o.extraImports["sort"] = true
+			}
+		}
 		o.pln("mutable bool")
 		o.pfln("__tmpl *%v%v", immutable.ImmTypeTmplPrefix, m.name)
 
@@ -50,7 +67,7 @@ func (o *output) genImmMaps(maps []*immMap) {
 
 		tmpl := template.New("immmap")
 		tmpl.Funcs(exp)
-		_, err := tmpl.Parse(immMapTmpl)
+		_, err := tmpl.Parse(mapTmpl)
 		if err != nil {
 			fatalf("failed to parse immutable map template: %v", err)
 		}
@@ -109,45 +126,87 @@ func (o *output) genImmMaps(maps []*immMap) {
 
 			`, exp, m.name)
 
-			switch {
-			case keyIsImmOk && valIsImmOk:
-				o.pt(`
-				for k, v := range s.theMap {
-				`, exp, m.name)
-			case keyIsImmOk:
-				o.pt(`
-				for k := range s.theMap {
-				`, exp, m.name)
-			case valIsImmOk:
-				o.pt(`
-				for _, v := range s.theMap {
-				`, exp, m.name)
-			}
+			if m.opts.hamt {
+				o.genHamtMapIsDeeplyNonMutable(blanks.KeyType, blanks.ValType, keyIsImmOk, valIsImmOk)
+			} else {
+				switch {
+				case keyIsImmOk && valIsImmOk:
+					o.pt(`
+					for k, v := range s.theMap {
+					`, exp, m.name)
+				case keyIsImmOk:
+					o.pt(`
+					for k := range s.theMap {
+					`, exp, m.name)
+				case valIsImmOk:
+					o.pt(`
+					for _, v := range s.theMap {
+					`, exp, m.name)
+				}
 
-			if keyIsImmOk {
-				o.pt(`
-				if k != nil && !k.IsDeeplyNonMutable(seen) {
-					return false
+				if keyIsImmOk {
+					o.pt(`
+					if k != nil && !k.IsDeeplyNonMutable(seen) {
+						return false
+					}
+					`, exp, m.name)
+				}
+
+				if valIsImmOk {
+					o.pt(`
+					if v != nil && !v.IsDeeplyNonMutable(seen) {
+						return false
+					}
+					`, exp, m.name)
 				}
-				`, exp, m.name)
-			}
 
-			if valIsImmOk {
 				o.pt(`
-				if v != nil && !v.IsDeeplyNonMutable(seen) {
-					return false
 				}
 				`, exp, m.name)
 			}
-
-			o.pt(`
-			}
-			`, exp, m.name)
 		}
 
 		o.pt(`
 			return true
 		}
 		`, exp, m.name)
+
+		o.genMapDiff(m)
+		o.genMapJSON(m)
+
+		if m.opts.binary {
+			o.genMapBinary(m)
+		}
+	}
+}
+
+// genHamtMapIsDeeplyNonMutable generates the body of the IsDeeplyNonMutable
+// check over the entries of a hamt-backed map; the *hamt.Map is ranged over
+// directly rather than via Range() to avoid building a Go map.
+func (o *output) genHamtMapIsDeeplyNonMutable(keyType, valType string, keyIsImmOk, valIsImmOk bool) {
+	o.pfln("res := true")
+	o.pfln("s.theMap.Range(func(k %v, v %v) bool {", keyType, valType)
+
+	if keyIsImmOk {
+		o.pln(`if k != nil && !k.IsDeeplyNonMutable(seen) {
+			res = false
+			return false
+		}
+		`)
+	}
+
+	if valIsImmOk {
+		o.pln(`if v != nil && !v.IsDeeplyNonMutable(seen) {
+			res = false
+			return false
+		}
+		`)
 	}
+
+	o.pln(`return true
+	})
+
+	if !res {
+		return false
+	}`)
 }
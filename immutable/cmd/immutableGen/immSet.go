@@ -0,0 +1,117 @@
+package main
+
+import (
+	"go/ast"
+	"go/types"
+	"text/template"
+
+	"myitcv.io/immutable"
+	"myitcv.io/immutable/util"
+)
+
+type immSet struct {
+	commonImm
+
+	// the name of the type to generate; not the pointer version
+	name string
+	syn  *ast.MapType
+	typ  *types.Map
+}
+
+func (o *output) genImmSets(sets []*immSet) {
+	for _, s := range sets {
+		blanks := struct {
+			Name    string
+			VarName string
+			Type    string
+		}{
+			Name:    s.name,
+			VarName: genVarName(s.name),
+			Type:    o.exprString(s.syn.Key),
+		}
+
+		exp := exporter(s.name)
+
+		o.printImmPreamble(s.name, s.dec.Doc, s.syn)
+
+		// start of struct
+		o.pfln("type %v struct {", s.name)
+		o.pln("")
+
+		o.pfln("theSet map[%v]struct{}", blanks.Type)
+		o.pln("mutable bool")
+		o.pfln("__tmpl *%v%v", immutable.ImmTypeTmplPrefix, s.name)
+
+		// end of struct
+		o.pfln("}")
+
+		tmpl := template.New("immset")
+		tmpl.Funcs(exp)
+		_, err := tmpl.Parse(immSetTmpl)
+		if err != nil {
+			fatalf("failed to parse immutable set template: %v", err)
+		}
+
+		err = tmpl.Execute(o.output, blanks)
+		if err != nil {
+			fatalf("failed to execute immutable set template: %v", err)
+		}
+
+		o.pt(`
+		func (s *{{.}}) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
+			if s == nil {
+				return true
+			}
+
+			if s.Mutable() {
+				return false
+			}
+		`, exp, s.name)
+
+		valIsImm := o.isImm(s.typ.Key(), blanks.Type)
+
+		valIsImmOk := false
+
+		switch valIsImm.(type) {
+		case nil, util.ImmTypeBasic:
+		default:
+			valIsImmOk = true
+		}
+
+		if valIsImmOk {
+			o.pt(`
+			if s.Len() == 0 {
+				return true
+			}
+
+			if seen == nil {
+				return s.IsDeeplyNonMutable(make(map[interface{}]bool))
+			}
+
+			if seen[s] {
+				return true
+			}
+
+			seen[s] = true
+
+			for v := range s.theSet {
+				if v != nil && !v.IsDeeplyNonMutable(seen) {
+					return false
+				}
+			}
+			`, exp, s.name)
+		}
+
+		o.pt(`
+			return true
+		}
+		`, exp, s.name)
+
+		o.genSetDiff(s)
+		o.genSetJSON(s)
+
+		if s.opts.binary {
+			o.genSetBinary(s)
+		}
+	}
+}
@@ -31,14 +31,19 @@ func (o *output) genImmSlices(slices []*immSlice) {
 
 		exp := exporter(s.name)
 
-		o.printCommentGroup(s.dec.Doc)
-		o.printImmPreamble(s.name, s.syn)
+		o.printImmPreamble(s.name, s.dec.Doc, s.syn)
 
 		// start of struct
 		o.pfln("type %v struct {", s.name)
 		o.pln("")
 
-		o.pfln("theSlice []%v", blanks.Type)
+		sliceTmpl := immSliceTmpl
+		if s.opts.vector {
+			sliceTmpl = immVectorSliceTmpl
+			o.pfln("theSlice *vector.Vector[%v]", blanks.Type)
+		} else {
+			o.pfln("theSlice []%v", blanks.Type)
+		}
 		o.pln("mutable bool")
 		o.pfln("__tmpl *%v%v", immutable.ImmTypeTmplPrefix, s.name)
 
@@ -47,7 +52,7 @@ func (o *output) genImmSlices(slices []*immSlice) {
 
 		tmpl := template.New("immslice")
 		tmpl.Funcs(exp)
-		_, err := tmpl.Parse(immSliceTmpl)
+		_, err := tmpl.Parse(sliceTmpl)
 		if err != nil {
 			fatalf("failed to parse immutable slice template: %v", err)
 		}
@@ -94,23 +99,43 @@ func (o *output) genImmSlices(slices []*immSlice) {
 
 			seen[s] = true
 
-			for _, v := range s.theSlice {
 			`, exp, s.name)
 
-			o.pt(`
-				if v != nil && !v.IsDeeplyNonMutable(seen) {
+			if s.opts.vector {
+				o.pfln("res := true")
+				o.pfln("s.theSlice.Range(func(_ int, v %v) bool {", blanks.Type)
+				o.pln(`if v != nil && !v.IsDeeplyNonMutable(seen) {
+						res = false
+						return false
+					}
+
+					return true
+				})
+
+				if !res {
 					return false
+				}`)
+			} else {
+				o.pt(`
+				for _, v := range s.theSlice {
+					if v != nil && !v.IsDeeplyNonMutable(seen) {
+						return false
+					}
 				}
-			`, exp, s.name)
-
-			o.pt(`
+				`, exp, s.name)
 			}
-			`, exp, s.name)
 		}
 
 		o.pt(`
 			return true
 		}
 		`, exp, s.name)
+
+		o.genSliceDiff(s)
+		o.genSliceJSON(s)
+
+		if s.opts.binary {
+			o.genSliceBinary(s)
+		}
 	}
 }
@@ -24,12 +24,20 @@ type commonImm struct {
 
 	// the template declaration
 	dec *ast.GenDecl
+
+	// the options set on the template
+	opts tmplOpts
 }
 
 func (c *commonImm) isImmTmpl() {}
 
+func (c *commonImm) options() tmplOpts {
+	return c.opts
+}
+
 type immTmpl interface {
 	isImmTmpl()
+	options() tmplOpts
 }
 
 type immStruct struct {
@@ -66,8 +74,7 @@ func (o *output) genImmStructs(structs []*immStruct) {
 
 	for _, s := range structs {
 
-		o.printCommentGroup(s.dec.Doc)
-		o.printImmPreamble(s.name, s.syn)
+		o.printImmPreamble(s.name, s.dec.Doc, s.syn)
 
 		// start of struct
 		o.pfln("type %v struct {", s.name)
@@ -77,6 +84,11 @@ func (o *output) genImmStructs(structs []*immStruct) {
 		o.pln("")
 
 		var fields []genField
+		var diffFields []diffField
+		var jsonFields []jsonField
+		var binaryFields []binaryField
+
+		binaryTags := make(map[uint64]string)
 
 		for _, f := range s.fields {
 
@@ -92,7 +104,53 @@ func (o *output) genImmStructs(structs []*immStruct) {
 			}
 			typ := o.exprString(f.field.Type)
 
-			isImm := o.isImm(o.info.TypeOf(f.field.Type), typ)
+			ftyp := o.info.TypeOf(f.field.Type)
+			isImm := o.isImm(ftyp, typ)
+
+			// the Key of a special struct is not diffed: its Uuid identifies
+			// the value and its Version is bumped by any change
+			if s.special == notSpecial || f.name != "Key" {
+				df := diffField{
+					Field: name,
+					Name:  f.name,
+					Type:  typ,
+				}
+				if dt, ok := o.diffType(isImm, ftyp, typ); ok {
+					df.Type = dt
+					df.Diff = true
+				} else {
+					df.NotEqual = o.notEqual(ftyp, "s."+name, "other."+name)
+				}
+				diffFields = append(diffFields, df)
+			}
+
+			if token.IsExported(f.name) {
+				jsonFields = append(jsonFields, jsonField{
+					Field: name,
+					Name:  f.name,
+					Type:  typ,
+					Tag:   tag,
+				})
+			}
+
+			if s.opts.binary {
+				pos := s.fset.Position(f.field.Pos())
+
+				if n, ok := binaryFieldTag(pos, tag); ok {
+					if other, ok := binaryTags[n]; ok {
+						fatalf("%v: fields %v and %v have the same %v tag %v", pos, other, f.name, binaryTag, n)
+					}
+					binaryTags[n] = f.name
+
+					c := o.binaryCodec(pos, ftyp, typ)
+
+					binaryFields = append(binaryFields, binaryField{
+						Tag:    n,
+						Encode: c.encode("e", n, "s."+name),
+						Decode: c.decode("d", "s."+name),
+					})
+				}
+			}
 
 			fields = append(fields, genField{
 				Field: name,
@@ -202,7 +260,7 @@ func (o *output) genImmStructs(structs []*immStruct) {
 				continue
 			}
 			switch f.IsImm.(type) {
-			case util.ImmTypeSlice, util.ImmTypeStruct, util.ImmTypeMap, util.ImmTypeImplsIntf, util.ImmTypeSimple:
+			case util.ImmTypeSlice, util.ImmTypeStruct, util.ImmTypeMap, util.ImmTypeSet, util.ImmTypeImplsIntf, util.ImmTypeSimple:
 
 				tmpl := struct {
 					FieldName string
@@ -228,6 +286,13 @@ func (o *output) genImmStructs(structs []*immStruct) {
 		}
 		`, exp, s.name)
 
+		o.genStructDiff(s, diffFields)
+		o.genStructJSON(s, jsonFields)
+
+		if s.opts.binary {
+			o.genStructBinary(s, binaryFields)
+		}
+
 		var mns []string
 		for n := range s.methods {
 			mns = append(mns, n)
@@ -252,6 +317,7 @@ func (o *output) genImmStructs(structs []*immStruct) {
 
 			exp := exporter(n)
 
+			o.pln()
 			o.printCommentGroup(f.doc)
 
 			o.pt(`
@@ -0,0 +1,147 @@
+package coretest_test
+
+import (
+	"encoding"
+	"testing"
+	"time"
+
+	"myitcv.io/immutable/cmd/immutableGen/internal/coretest"
+)
+
+func binaryRoundTrip(t *testing.T, from encoding.BinaryMarshaler, to encoding.BinaryUnmarshaler) {
+	t.Helper()
+
+	b, err := from.MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+
+	if err := to.UnmarshalBinary(b); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	nested := new(coretest.BinStruct).SetName(paul).SetAge(-5)
+
+	s1 := new(coretest.BinStruct).WithMutable(func(s *coretest.BinStruct) {
+		s.SetName(peter)
+		s.SetAge(42)
+		s.SetCount(7)
+		s.SetScore(-1.5)
+		s.SetActive(true)
+		s.SetData([]byte{0, 1, 2})
+		s.SetWhen(time.Unix(1234567890, 0).UTC())
+		s.SetTags([]string{"a", "b"})
+		s.SetUuid(99)
+
+		s.SetSlice(coretest.NewBinSlice("", peter, paul))
+		s.SetVectorSlice(coretest.NewBinVectorSlice(3, 0, 1))
+		s.SetMap(coretest.NewBinMap(func(m *coretest.BinMap) {
+			m.Set(paul, nested)
+			m.Set(peter, nil)
+		}))
+		s.SetHamtMap(coretest.NewBinHamtMap(func(m *coretest.BinHamtMap) {
+			m.Set(1, true)
+			m.Set(0, false)
+		}))
+		s.SetOrderedMap(coretest.NewBinOrderedMap(func(m *coretest.BinOrderedMap) {
+			m.Set("z", 1)
+			m.Set("a", 2)
+			m.Set("m", 0)
+		}))
+		s.SetSortedMap(coretest.NewBinSortedMap(func(m *coretest.BinSortedMap) {
+			m.Set(3, "c")
+			m.Set(1, "a")
+		}))
+		s.SetSet(coretest.NewBinSet(peter, paul, ""))
+		s.SetNested(nested)
+		s.SetStructs(coretest.NewBinStructs(nested, nil, new(coretest.BinStruct)))
+
+		s.SetNotEncoded("not encoded")
+	})
+
+	s2 := new(coretest.BinStruct)
+	binaryRoundTrip(t, s1, s2)
+
+	if v := s2.NotEncoded(); v != "" {
+		t.Fatalf("expected field without binary tag not to be encoded; got %q", v)
+	}
+
+	if d := s1.SetNotEncoded("").Diff(s2); d != nil {
+		t.Fatalf("expected round trip to give an equal value; got diff %+v", d)
+	}
+
+	if !s2.IsDeeplyNonMutable(nil) {
+		t.Fatalf("unmarshalled value should not be mutable")
+	}
+
+	// insertion order is preserved...
+	if ks := s2.OrderedMap().Keys(); len(ks) != 3 || ks[0] != "z" || ks[1] != "a" || ks[2] != "m" {
+		t.Fatalf("expected ordered map keys [z a m]; got %v", ks)
+	}
+
+	// ... as are nil and zero elements
+	if v := s2.Structs(); v.Len() != 3 || v.Get(1) != nil || v.Get(2) == nil {
+		t.Fatalf("expected nil and zero elements to be preserved; got %v", v.Range())
+	}
+
+	if v, ok := s2.Map().Get(peter); !ok || v != nil {
+		t.Fatalf("expected nil map value to be preserved; got %v, %v", v, ok)
+	}
+}
+
+func TestBinaryRoundTripEmpty(t *testing.T) {
+	s1 := new(coretest.BinStruct)
+
+	s2 := new(coretest.BinStruct)
+	binaryRoundTrip(t, s1, s2)
+
+	if d := s1.Diff(s2); d != nil {
+		t.Fatalf("expected round trip to give an equal value; got diff %+v", d)
+	}
+
+	m := coretest.NewBinMap(func(m *coretest.BinMap) {
+		m.Set(peter, nil)
+	})
+	binaryRoundTrip(t, coretest.NewBinMap(), m)
+
+	if m.Len() != 0 {
+		t.Fatalf("expected empty map; got %v", m.Range())
+	}
+}
+
+func TestBinarySchemaEvolution(t *testing.T) {
+	v1 := new(coretest.BinV1).SetName(peter).SetAge(42)
+
+	// a field removed in V2 is ignored, and a field added is zero
+	v2 := new(coretest.BinV2)
+	binaryRoundTrip(t, v1, v2)
+
+	if v2.Name() != peter || v2.Email() != "" || v2.Tags() != nil {
+		t.Fatalf("unexpected value %v, %q, %v", v2.Name(), v2.Email(), v2.Tags())
+	}
+
+	// and vice versa
+	v2 = v2.SetEmail("peter@example.com").SetTags(coretest.NewBinSlice(paul))
+
+	v1 = new(coretest.BinV1)
+	binaryRoundTrip(t, v2, v1)
+
+	if v1.Name() != peter || v1.Age() != 0 {
+		t.Fatalf("unexpected value %v, %v", v1.Name(), v1.Age())
+	}
+}
+
+func TestBinaryTruncated(t *testing.T) {
+	s1 := new(coretest.BinStruct).SetName(peter).SetSlice(coretest.NewBinSlice(paul))
+
+	b, err := s1.MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+
+	if err := new(coretest.BinStruct).UnmarshalBinary(b[:len(b)-1]); err == nil {
+		t.Fatalf("expected an error decoding truncated data")
+	}
+}
@@ -1,10 +1,12 @@
 package coretest
 
 import (
+	"strings"
 	"time"
 
 	"myitcv.io/immutable"
 	"myitcv.io/immutable/cmd/immutableGen/internal/coretest/pkga"
+	"myitcv.io/sorter"
 )
 
 //go:generate gobin -m -run myitcv.io/immutable/cmd/immutableGen -licenseFile license.txt -G "echo \"hello world\""
@@ -69,6 +71,54 @@ type _Imm_AS []*A
 
 type _Imm_AM map[*A]*A
 
+// TextKey implements encoding.TextMarshaler and encoding.TextUnmarshaler
+type TextKey struct {
+	First, Last string
+}
+
+func (k TextKey) MarshalText() ([]byte, error) {
+	return []byte(k.First + " " + k.Last), nil
+}
+
+func (k *TextKey) UnmarshalText(b []byte) error {
+	k.First, k.Last, _ = strings.Cut(string(b), " ")
+	return nil
+}
+
+type _Imm_TextKeyMap map[TextKey]int
+
+// a comment about MyHamtMap
+// immutableGen:hamt
+type _Imm_MyHamtMap map[string]int
+
+// immutableGen:hamt
+type _Imm_AHM map[*A]*A
+
+// a comment about MyOrderedMap
+// immutableGen:ordered
+type _Imm_MyOrderedMap map[string]int
+
+// immutableGen:ordered orderByKey
+type _Imm_MySortedMap map[int]string
+
+func orderByKey(ks []int, i, j int) sorter.Ordered {
+	return ks[i] < ks[j]
+}
+
+// a comment about MySet
+// immutableGen:set
+type _Imm_MySet map[string]struct{}
+
+// immutableGen:set
+type _Imm_ASet map[*A]struct{}
+
+// a comment about MyVectorSlice
+// immutableGen:vector
+type _Imm_MyVectorSlice []string
+
+// immutableGen:vector
+type _Imm_AVS []*A
+
 type Blah interface {
 	immutable.Immutable
 }
@@ -133,3 +183,75 @@ type _Imm_Other struct {
 
 func main() {
 }
+
+// types for testing binary encoding
+
+// immutableGen:binary
+type _Imm_BinStruct struct {
+	Name   string       `binary:"1"`
+	Age    int          `binary:"2"`
+	Count  uint8        `binary:"3"`
+	Score  float64      `binary:"4"`
+	Active bool         `binary:"5"`
+	Data   []byte       `binary:"6"`
+	When   time.Time    `binary:"7"`
+	Tags   []string     `binary:"8"`
+	Uuid   MyStructUuid `binary:"9"`
+
+	Slice       *BinSlice       `binary:"20"`
+	VectorSlice *BinVectorSlice `binary:"21"`
+	Map         *BinMap         `binary:"22"`
+	HamtMap     *BinHamtMap     `binary:"23"`
+	OrderedMap  *BinOrderedMap  `binary:"24"`
+	SortedMap   *BinSortedMap   `binary:"25"`
+	Set         *BinSet         `binary:"26"`
+	Nested      *BinStruct      `binary:"27"`
+	Structs     *BinStructs     `binary:"28"`
+
+	NotEncoded string
+}
+
+// immutableGen:binary
+type _Imm_BinSlice []string
+
+// immutableGen:binary
+// immutableGen:vector
+type _Imm_BinVectorSlice []int
+
+// immutableGen:binary
+type _Imm_BinMap map[string]*BinStruct
+
+// immutableGen:binary
+// immutableGen:hamt
+type _Imm_BinHamtMap map[int]bool
+
+// immutableGen:binary
+// immutableGen:ordered
+type _Imm_BinOrderedMap map[string]int
+
+// immutableGen:binary
+// immutableGen:ordered orderByKey
+type _Imm_BinSortedMap map[int]string
+
+// immutableGen:binary
+// immutableGen:set
+type _Imm_BinSet map[string]struct{}
+
+// immutableGen:binary
+type _Imm_BinStructs []*BinStruct
+
+// BinV1 and BinV2 are two versions of the same template, used to test that
+// the binary encoding tolerates the addition and removal of fields
+
+// immutableGen:binary
+type _Imm_BinV1 struct {
+	Name string `binary:"1"`
+	Age  int    `binary:"2"`
+}
+
+// immutableGen:binary
+type _Imm_BinV2 struct {
+	Name  string    `binary:"1"`
+	Email string    `binary:"3"`
+	Tags  *BinSlice `binary:"4"`
+}
@@ -0,0 +1,187 @@
+package coretest_test
+
+import (
+	"testing"
+
+	"myitcv.io/immutable/cmd/immutableGen/internal/coretest"
+)
+
+func TestStructDiff(t *testing.T) {
+	s1 := new(coretest.MyStruct).SetKey(coretest.MyStructKey{Uuid: 1})
+	s2 := s1.SetName(peter)
+
+	if d := s1.Diff(s1); d != nil {
+		t.Fatalf("expected no diff between s1 and itself; got %+v", d)
+	}
+
+	d := s1.Diff(s2)
+	if d == nil {
+		t.Fatalf("expected diff between s1 and s2")
+	}
+
+	if d.Replaced {
+		t.Fatalf("expected diff to not be a replacement")
+	}
+
+	if d.Name == nil || *d.Name != peter {
+		t.Fatalf("expected Name to have changed to %q; got %v", peter, d.Name)
+	}
+
+	s3 := s1.Patch(d)
+
+	if s3.Name() != peter {
+		t.Fatalf("expected patched Name to be %q; got %q", peter, s3.Name())
+	}
+
+	if k := s3.Key(); k.Uuid != s1.Key().Uuid || k.Version != s1.Key().Version+1 {
+		t.Fatalf("expected patched Key to be a new version of %v; got %v", s1.Key(), k)
+	}
+
+	if s3.Mutable() {
+		t.Fatalf("patched value should not be mutable")
+	}
+
+	if s1.Name() != "" {
+		t.Fatalf("s1 should be unchanged by Patch")
+	}
+
+	// the same Uuid and Version are the same version of the same entity
+	if d := s2.Diff(s1.SetName(paul)); d != nil {
+		t.Fatalf("expected no diff between values with the same Key; got %+v", d)
+	}
+
+	// a change to Version alone is not reported
+	if d := s1.Diff(s1.SetKey(coretest.MyStructKey{Uuid: 1, Version: 5})); d != nil {
+		t.Fatalf("expected no diff for a change of Version; got %+v", d)
+	}
+
+	// different Uuids are a replacement
+	s4 := s2.SetKey(coretest.MyStructKey{Uuid: 2})
+
+	if d := s2.Diff(s4); d == nil || !d.Replaced || d.Value != s4 {
+		t.Fatalf("expected diff to be a replacement by s4; got %+v", d)
+	}
+
+	if d := s2.Diff(nil); d == nil || !d.Replaced || d.Value != nil {
+		t.Fatalf("expected diff to be a replacement by nil; got %+v", d)
+	}
+}
+
+func TestNestedStructDiff(t *testing.T) {
+	a1 := new(coretest.A).SetA(new(coretest.A).SetName(paul))
+	a2 := a1.SetA(a1.A().SetName(peter))
+
+	d := a1.Diff(a2)
+	if d == nil || d.A == nil {
+		t.Fatalf("expected a nested diff for A; got %+v", d)
+	}
+
+	if d.Name != nil {
+		t.Fatalf("did not expect Name to have changed")
+	}
+
+	if d.A.Name == nil || *d.A.Name != peter {
+		t.Fatalf("expected nested Name to have changed to %q; got %v", peter, d.A.Name)
+	}
+
+	if v := a1.Patch(d).A().Name(); v != peter {
+		t.Fatalf("expected patched nested Name to be %q; got %q", peter, v)
+	}
+}
+
+func TestMapDiff(t *testing.T) {
+	m1 := coretest.NewMyMap(func(m *coretest.MyMap) {
+		m.Set(paul, 1)
+		m.Set(peter, 2)
+	})
+	m2 := m1.Del(paul).Set(peter, age42).Set("john", 3)
+
+	d := m1.Diff(m2)
+	if d == nil {
+		t.Fatalf("expected diff between m1 and m2")
+	}
+
+	if len(d.Del) != 1 || d.Del[0] != paul {
+		t.Fatalf("expected %q to have been deleted; got %v", paul, d.Del)
+	}
+
+	if len(d.Set) != 2 || d.Set[peter] != age42 || d.Set["john"] != 3 {
+		t.Fatalf("unexpected Set %v", d.Set)
+	}
+
+	m3 := m1.Patch(d)
+
+	if d := m3.Diff(m2); d != nil {
+		t.Fatalf("expected patched value to equal m2; got diff %+v", d)
+	}
+
+	if d := m2.Diff(m2.Set(peter, age42)); d != nil {
+		t.Fatalf("expected no diff for an unchanged value; got %+v", d)
+	}
+}
+
+func TestHamtMapDiff(t *testing.T) {
+	m1 := coretest.NewMyHamtMap().Set(paul, 1)
+	m2 := m1.Set(peter, 2)
+
+	d := m1.Diff(m2)
+	if d == nil || len(d.Set) != 1 || d.Set[peter] != 2 {
+		t.Fatalf("unexpected diff %+v", d)
+	}
+
+	if d := m1.Patch(d).Diff(m2); d != nil {
+		t.Fatalf("expected patched value to equal m2; got diff %+v", d)
+	}
+}
+
+func TestNestedMapDiff(t *testing.T) {
+	k := new(coretest.A)
+
+	m1 := coretest.NewAM().Set(k, new(coretest.A).SetName(paul))
+	m2 := m1.Set(k, new(coretest.A).SetName(peter))
+
+	d := m1.Diff(m2)
+	if d == nil || len(d.Changed) != 1 {
+		t.Fatalf("expected a single changed entry; got %+v", d)
+	}
+
+	if v, _ := m1.Patch(d).Get(k); v.Name() != peter {
+		t.Fatalf("expected patched entry to have Name %q; got %q", peter, v.Name())
+	}
+}
+
+func TestSliceDiff(t *testing.T) {
+	s1 := coretest.NewMySlice(paul, peter, "john")
+	s2 := coretest.NewMySlice(paul, "ringo")
+
+	d := s1.Diff(s2)
+	if d == nil || d.Len != 2 || len(d.Set) != 1 || d.Set[1] != "ringo" {
+		t.Fatalf("unexpected diff %+v", d)
+	}
+
+	if d := s1.Patch(d).Diff(s2); d != nil {
+		t.Fatalf("expected patched value to equal s2; got diff %+v", d)
+	}
+
+	if d := s2.Patch(s2.Diff(s1)).Diff(s1); d != nil {
+		t.Fatalf("expected patched value to equal s1; got diff %+v", d)
+	}
+
+	if d := s1.Diff(coretest.NewMySlice(paul, peter, "john")); d != nil {
+		t.Fatalf("expected no diff for equal values; got %+v", d)
+	}
+}
+
+func TestVectorSliceDiff(t *testing.T) {
+	s1 := coretest.NewMyVectorSlice(paul, peter)
+	s2 := s1.Append("john")
+
+	d := s1.Diff(s2)
+	if d == nil || d.Len != 3 || d.Set[2] != "john" {
+		t.Fatalf("unexpected diff %+v", d)
+	}
+
+	if d := s1.Patch(d).Diff(s2); d != nil {
+		t.Fatalf("expected patched value to equal s2; got diff %+v", d)
+	}
+}
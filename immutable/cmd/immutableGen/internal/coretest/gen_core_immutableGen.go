@@ -8,7 +8,13 @@ package coretest
 //immutableVet:skipFile
 
 import (
+	"encoding/json"
 	"myitcv.io/immutable"
+	"myitcv.io/immutable/hamt"
+	"myitcv.io/immutable/vector"
+	"myitcv.io/immutable/wire"
+	"reflect"
+	"sort"
 
 	"myitcv.io/immutable/cmd/immutableGen/internal/coretest/pkga"
 	"myitcv.io/immutable/cmd/immutableGen/internal/coretest/pkgb"
@@ -19,8 +25,7 @@ import (
 //
 // MyMap is an immutable type and has the following template:
 //
-// 	map[string]int
-//
+//	map[string]int
 type MyMap struct {
 	theMap  map[string]int
 	mutable bool
@@ -171,11 +176,122 @@ func (s *MyMap) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	return true
 }
 
-//
+// MyMapDiff is the change set between two MyMap values, as returned by
+// MyMap.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set.
+type MyMapDiff struct {
+	Replaced bool
+	Value    *MyMap
+
+	// Set holds the entries that were added or changed
+	Set map[string]int
+
+	// Del holds the keys of the entries that were deleted, in no particular
+	// order
+	Del []string
+}
+
+// Diff returns the change set required to turn m into other, or nil if there
+// is no difference.
+func (m *MyMap) Diff(other *MyMap) *MyMapDiff {
+	if m == other {
+		return nil
+	}
+
+	if m == nil || other == nil {
+		return &MyMapDiff{Replaced: true, Value: other}
+	}
+
+	res := &MyMapDiff{
+		Set: make(map[string]int),
+	}
+
+	for k := range m.Range() {
+		if _, ok := other.Get(k); !ok {
+			res.Del = append(res.Del, k)
+		}
+	}
+
+	for k, ov := range other.Range() {
+		v, ok := m.Get(k)
+		if !ok {
+			res.Set[k] = ov
+			continue
+		}
+
+		if v != ov {
+			res.Set[k] = ov
+		}
+	}
+
+	if len(res.Set) == 0 && len(res.Del) == 0 {
+		return nil
+	}
+
+	return res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to m.
+func (m *MyMap) Patch(d *MyMapDiff) *MyMap {
+	if d == nil {
+		return m
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if m == nil {
+		m = NewMyMap()
+	}
+
+	return m.WithMutable(func(mi *MyMap) {
+		for _, k := range d.Del {
+			mi.Del(k)
+		}
+
+		for k, v := range d.Set {
+			mi.Set(k, v)
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. m is marshalled as a JSON object.
+func (m *MyMap) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+
+	if m.Len() == 0 {
+		return []byte("{}"), nil
+	}
+
+	return json.Marshal(m.Range())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. m is set to an immutable map
+// containing the unmarshalled entries. Because *m is replaced, m should be a
+// new value rather than one shared with other code.
+func (m *MyMap) UnmarshalJSON(b []byte) error {
+	var v map[string]int
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*m = *NewMyMap(func(mi *MyMap) {
+		for k, e := range v {
+			mi.Set(k, e)
+		}
+	})
+
+	return nil
+}
+
 // AM is an immutable type and has the following template:
 //
-// 	map[*A]*A
-//
+//	map[*A]*A
 type AM struct {
 	theMap  map[*A]*A
 	mutable bool
@@ -348,203 +464,190 @@ func (s *AM) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	return true
 }
 
-// a comment about Slice
-//
-// MySlice is an immutable type and has the following template:
-//
-// 	[]string
-//
-type MySlice struct {
-	theSlice []string
-	mutable  bool
-	__tmpl   *_Imm_MySlice
-}
+// AMDiff is the change set between two AM values, as returned by
+// AM.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set.
+type AMDiff struct {
+	Replaced bool
+	Value    *AM
 
-var _ immutable.Immutable = new(MySlice)
-var _ = new(MySlice).__tmpl
+	// Set holds the entries that were added
+	Set map[*A]*A
 
-func NewMySlice(s ...string) *MySlice {
-	c := make([]string, len(s))
-	copy(c, s)
+	// Changed holds the change sets of the entries that were changed
+	Changed map[*A]*ADiff
 
-	return &MySlice{
-		theSlice: c,
-	}
+	// Del holds the keys of the entries that were deleted, in no particular
+	// order
+	Del []*A
 }
 
-func NewMySliceLen(l int) *MySlice {
-	c := make([]string, l)
-
-	return &MySlice{
-		theSlice: c,
+// Diff returns the change set required to turn m into other, or nil if there
+// is no difference.
+func (m *AM) Diff(other *AM) *AMDiff {
+	if m == other {
+		return nil
 	}
-}
-
-func (m *MySlice) Mutable() bool {
-	return m.mutable
-}
 
-func (m *MySlice) Len() int {
-	if m == nil {
-		return 0
+	if m == nil || other == nil {
+		return &AMDiff{Replaced: true, Value: other}
 	}
 
-	return len(m.theSlice)
-}
-
-func (m *MySlice) Get(i int) string {
-	return m.theSlice[i]
-}
-
-func (m *MySlice) AsMutable() *MySlice {
-	if m == nil {
-		return nil
+	res := &AMDiff{
+		Set:     make(map[*A]*A),
+		Changed: make(map[*A]*ADiff),
 	}
 
-	if m.Mutable() {
-		return m
+	for k := range m.Range() {
+		if _, ok := other.Get(k); !ok {
+			res.Del = append(res.Del, k)
+		}
 	}
 
-	res := m.dup()
-	res.mutable = true
-
-	return res
-}
-
-func (m *MySlice) dup() *MySlice {
-	resSlice := make([]string, len(m.theSlice))
+	for k, ov := range other.Range() {
+		v, ok := m.Get(k)
+		if !ok {
+			res.Set[k] = ov
+			continue
+		}
 
-	for i := range m.theSlice {
-		resSlice[i] = m.theSlice[i]
+		if d := v.Diff(ov); d != nil {
+			res.Changed[k] = d
+		}
 	}
 
-	res := &MySlice{
-		theSlice: resSlice,
+	if len(res.Set) == 0 && len(res.Del) == 0 && len(res.Changed) == 0 {
+		return nil
 	}
 
 	return res
 }
 
-func (m *MySlice) AsImmutable(v *MySlice) *MySlice {
-	if m == nil {
-		return nil
-	}
-
-	if v == m {
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to m.
+func (m *AM) Patch(d *AMDiff) *AM {
+	if d == nil {
 		return m
 	}
 
-	m.mutable = false
-	return m
-}
+	if d.Replaced {
+		return d.Value
+	}
 
-func (m *MySlice) Range() []string {
 	if m == nil {
-		return nil
+		m = NewAM()
 	}
 
-	return m.theSlice
-}
+	return m.WithMutable(func(mi *AM) {
+		for _, k := range d.Del {
+			mi.Del(k)
+		}
 
-func (m *MySlice) WithMutable(f func(mi *MySlice)) *MySlice {
-	res := m.AsMutable()
-	f(res)
-	res = res.AsImmutable(m)
+		for k, v := range d.Set {
+			mi.Set(k, v)
+		}
 
-	return res
+		for k, vd := range d.Changed {
+			v, _ := mi.Get(k)
+			mi.Set(k, v.Patch(vd))
+		}
+	})
 }
 
-func (m *MySlice) WithImmutable(f func(mi *MySlice)) *MySlice {
-	prev := m.mutable
-	m.mutable = false
-	f(m)
-	m.mutable = prev
+// MarshalJSON implements json.Marshaler. Because encoding/json does not
+// support keys of type *A, m is marshalled as a JSON array of objects
+// with Key and Value fields.
+func (m *AM) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
 
-	return m
-}
+	v := make([]struct {
+		Key   *A
+		Value *A
+	}, 0, m.Len())
 
-func (m *MySlice) Set(i int, v string) *MySlice {
-	if m.mutable {
-		m.theSlice[i] = v
-		return m
+	for k, e := range m.Range() {
+		v = append(v, struct {
+			Key   *A
+			Value *A
+		}{k, e})
 	}
 
-	res := m.dup()
-	res.theSlice[i] = v
-
-	return res
+	return json.Marshal(v)
 }
 
-func (m *MySlice) Append(v ...string) *MySlice {
-	if m.mutable {
-		m.theSlice = append(m.theSlice, v...)
-		return m
+// UnmarshalJSON implements json.Unmarshaler. m is set to an immutable map
+// containing the unmarshalled entries. Because *m is replaced, m should be a
+// new value rather than one shared with other code.
+func (m *AM) UnmarshalJSON(b []byte) error {
+	var v []struct {
+		Key   *A
+		Value *A
 	}
 
-	res := m.dup()
-	res.theSlice = append(res.theSlice, v...)
-
-	return res
-}
-func (s *MySlice) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
-	if s == nil {
-		return true
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
 	}
 
-	if s.Mutable() {
-		return false
-	}
-	return true
+	*m = *NewAM(func(mi *AM) {
+		for _, e := range v {
+			mi.Set(e.Key, e.Value)
+		}
+	})
+
+	return nil
 }
 
+// TextKeyMap is an immutable type and has the following template:
 //
-// AS is an immutable type and has the following template:
-//
-// 	[]*A
-//
-type AS struct {
-	theSlice []*A
-	mutable  bool
-	__tmpl   *_Imm_AS
+//	map[TextKey]int
+type TextKeyMap struct {
+	theMap  map[TextKey]int
+	mutable bool
+	__tmpl  *_Imm_TextKeyMap
 }
 
-var _ immutable.Immutable = new(AS)
-var _ = new(AS).__tmpl
-
-func NewAS(s ...*A) *AS {
-	c := make([]*A, len(s))
-	copy(c, s)
+var _ immutable.Immutable = new(TextKeyMap)
+var _ = new(TextKeyMap).__tmpl
 
-	return &AS{
-		theSlice: c,
+func NewTextKeyMap(inits ...func(m *TextKeyMap)) *TextKeyMap {
+	res := NewTextKeyMapCap(0)
+	if len(inits) == 0 {
+		return res
 	}
-}
 
-func NewASLen(l int) *AS {
-	c := make([]*A, l)
+	return res.WithMutable(func(m *TextKeyMap) {
+		for _, i := range inits {
+			i(m)
+		}
+	})
+}
 
-	return &AS{
-		theSlice: c,
+func NewTextKeyMapCap(l int) *TextKeyMap {
+	return &TextKeyMap{
+		theMap: make(map[TextKey]int, l),
 	}
 }
 
-func (m *AS) Mutable() bool {
+func (m *TextKeyMap) Mutable() bool {
 	return m.mutable
 }
 
-func (m *AS) Len() int {
+func (m *TextKeyMap) Len() int {
 	if m == nil {
 		return 0
 	}
 
-	return len(m.theSlice)
+	return len(m.theMap)
 }
 
-func (m *AS) Get(i int) *A {
-	return m.theSlice[i]
+func (m *TextKeyMap) Get(k TextKey) (int, bool) {
+	v, ok := m.theMap[k]
+	return v, ok
 }
 
-func (m *AS) AsMutable() *AS {
+func (m *TextKeyMap) AsMutable() *TextKeyMap {
 	if m == nil {
 		return nil
 	}
@@ -559,21 +662,21 @@ func (m *AS) AsMutable() *AS {
 	return res
 }
 
-func (m *AS) dup() *AS {
-	resSlice := make([]*A, len(m.theSlice))
+func (m *TextKeyMap) dup() *TextKeyMap {
+	resMap := make(map[TextKey]int, len(m.theMap))
 
-	for i := range m.theSlice {
-		resSlice[i] = m.theSlice[i]
+	for k := range m.theMap {
+		resMap[k] = m.theMap[k]
 	}
 
-	res := &AS{
-		theSlice: resSlice,
+	res := &TextKeyMap{
+		theMap: resMap,
 	}
 
 	return res
 }
 
-func (m *AS) AsImmutable(v *AS) *AS {
+func (m *TextKeyMap) AsImmutable(v *TextKeyMap) *TextKeyMap {
 	if m == nil {
 		return nil
 	}
@@ -586,54 +689,4608 @@ func (m *AS) AsImmutable(v *AS) *AS {
 	return m
 }
 
-func (m *AS) Range() []*A {
+func (m *TextKeyMap) Range() map[TextKey]int {
 	if m == nil {
 		return nil
 	}
 
-	return m.theSlice
+	return m.theMap
 }
 
-func (m *AS) WithMutable(f func(mi *AS)) *AS {
-	res := m.AsMutable()
+func (mr *TextKeyMap) WithMutable(f func(t *TextKeyMap)) *TextKeyMap {
+	res := mr.AsMutable()
 	f(res)
-	res = res.AsImmutable(m)
+	res = res.AsImmutable(mr)
 
 	return res
 }
 
-func (m *AS) WithImmutable(f func(mi *AS)) *AS {
-	prev := m.mutable
-	m.mutable = false
-	f(m)
-	m.mutable = prev
+func (mr *TextKeyMap) WithImmutable(f func(t *TextKeyMap)) *TextKeyMap {
+	prev := mr.mutable
+	mr.mutable = false
+	f(mr)
+	mr.mutable = prev
 
-	return m
+	return mr
 }
 
-func (m *AS) Set(i int, v *A) *AS {
+func (m *TextKeyMap) Set(k TextKey, v int) *TextKeyMap {
 	if m.mutable {
-		m.theSlice[i] = v
+		m.theMap[k] = v
 		return m
 	}
 
 	res := m.dup()
-	res.theSlice[i] = v
+	res.theMap[k] = v
 
 	return res
 }
 
-func (m *AS) Append(v ...*A) *AS {
+func (m *TextKeyMap) Del(k TextKey) *TextKeyMap {
+	if _, ok := m.theMap[k]; !ok {
+		return m
+	}
+
 	if m.mutable {
-		m.theSlice = append(m.theSlice, v...)
+		delete(m.theMap, k)
 		return m
 	}
 
 	res := m.dup()
-	res.theSlice = append(res.theSlice, v...)
+	delete(res.theMap, k)
 
 	return res
 }
+func (s *TextKeyMap) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
+	if s == nil {
+		return true
+	}
+
+	if s.Mutable() {
+		return false
+	}
+	return true
+}
+
+// TextKeyMapDiff is the change set between two TextKeyMap values, as returned by
+// TextKeyMap.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set.
+type TextKeyMapDiff struct {
+	Replaced bool
+	Value    *TextKeyMap
+
+	// Set holds the entries that were added or changed
+	Set map[TextKey]int
+
+	// Del holds the keys of the entries that were deleted, in no particular
+	// order
+	Del []TextKey
+}
+
+// Diff returns the change set required to turn m into other, or nil if there
+// is no difference.
+func (m *TextKeyMap) Diff(other *TextKeyMap) *TextKeyMapDiff {
+	if m == other {
+		return nil
+	}
+
+	if m == nil || other == nil {
+		return &TextKeyMapDiff{Replaced: true, Value: other}
+	}
+
+	res := &TextKeyMapDiff{
+		Set: make(map[TextKey]int),
+	}
+
+	for k := range m.Range() {
+		if _, ok := other.Get(k); !ok {
+			res.Del = append(res.Del, k)
+		}
+	}
+
+	for k, ov := range other.Range() {
+		v, ok := m.Get(k)
+		if !ok {
+			res.Set[k] = ov
+			continue
+		}
+
+		if v != ov {
+			res.Set[k] = ov
+		}
+	}
+
+	if len(res.Set) == 0 && len(res.Del) == 0 {
+		return nil
+	}
+
+	return res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to m.
+func (m *TextKeyMap) Patch(d *TextKeyMapDiff) *TextKeyMap {
+	if d == nil {
+		return m
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if m == nil {
+		m = NewTextKeyMap()
+	}
+
+	return m.WithMutable(func(mi *TextKeyMap) {
+		for _, k := range d.Del {
+			mi.Del(k)
+		}
+
+		for k, v := range d.Set {
+			mi.Set(k, v)
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. m is marshalled as a JSON object.
+func (m *TextKeyMap) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+
+	if m.Len() == 0 {
+		return []byte("{}"), nil
+	}
+
+	return json.Marshal(m.Range())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. m is set to an immutable map
+// containing the unmarshalled entries. Because *m is replaced, m should be a
+// new value rather than one shared with other code.
+func (m *TextKeyMap) UnmarshalJSON(b []byte) error {
+	var v map[TextKey]int
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*m = *NewTextKeyMap(func(mi *TextKeyMap) {
+		for k, e := range v {
+			mi.Set(k, e)
+		}
+	})
+
+	return nil
+}
+
+// a comment about MyHamtMap
+//
+// MyHamtMap is an immutable type and has the following template:
+//
+//	map[string]int
+type MyHamtMap struct {
+	theMap  *hamt.Map[string, int]
+	mutable bool
+	__tmpl  *_Imm_MyHamtMap
+}
+
+var _ immutable.Immutable = new(MyHamtMap)
+var _ = new(MyHamtMap).__tmpl
+
+func NewMyHamtMap(inits ...func(m *MyHamtMap)) *MyHamtMap {
+	res := NewMyHamtMapCap(0)
+	if len(inits) == 0 {
+		return res
+	}
+
+	return res.WithMutable(func(m *MyHamtMap) {
+		for _, i := range inits {
+			i(m)
+		}
+	})
+}
+
+// NewMyHamtMapCap is provided for API compatibility with
+// immutable maps backed by a Go map; l is ignored.
+func NewMyHamtMapCap(l int) *MyHamtMap {
+	return &MyHamtMap{}
+}
+
+func (m *MyHamtMap) Mutable() bool {
+	return m.mutable
+}
+
+func (m *MyHamtMap) Len() int {
+	if m == nil {
+		return 0
+	}
+
+	return m.theMap.Len()
+}
+
+func (m *MyHamtMap) Get(k string) (int, bool) {
+	if m == nil {
+		var v int
+		return v, false
+	}
+
+	return m.theMap.Get(k)
+}
+
+func (m *MyHamtMap) AsMutable() *MyHamtMap {
+	if m == nil {
+		return nil
+	}
+
+	if m.Mutable() {
+		return m
+	}
+
+	res := m.dup()
+	res.mutable = true
+
+	return res
+}
+
+func (m *MyHamtMap) dup() *MyHamtMap {
+	res := &MyHamtMap{
+		theMap: m.theMap,
+	}
+
+	return res
+}
+
+func (m *MyHamtMap) AsImmutable(v *MyHamtMap) *MyHamtMap {
+	if m == nil {
+		return nil
+	}
+
+	if v == m {
+		return m
+	}
+
+	m.mutable = false
+	return m
+}
+
+// Range returns a Go map containing the entries of m. Because the map is
+// built on each call it is O(n); use RangeFunc to avoid the allocation.
+func (m *MyHamtMap) Range() map[string]int {
+	if m == nil {
+		return nil
+	}
+
+	res := make(map[string]int, m.theMap.Len())
+
+	m.theMap.Range(func(k string, v int) bool {
+		res[k] = v
+		return true
+	})
+
+	return res
+}
+
+// RangeFunc calls f for each entry in m, stopping if f returns false.
+func (m *MyHamtMap) RangeFunc(f func(k string, v int) bool) {
+	if m == nil {
+		return
+	}
+
+	m.theMap.Range(f)
+}
+
+func (mr *MyHamtMap) WithMutable(f func(m *MyHamtMap)) *MyHamtMap {
+	res := mr.AsMutable()
+	f(res)
+	res = res.AsImmutable(mr)
+
+	return res
+}
+
+func (mr *MyHamtMap) WithImmutable(f func(m *MyHamtMap)) *MyHamtMap {
+	prev := mr.mutable
+	mr.mutable = false
+	f(mr)
+	mr.mutable = prev
+
+	return mr
+}
+
+func (m *MyHamtMap) Set(k string, v int) *MyHamtMap {
+	if m.mutable {
+		m.theMap = m.theMap.Set(k, v)
+		return m
+	}
+
+	res := m.dup()
+	res.theMap = res.theMap.Set(k, v)
+
+	return res
+}
+
+func (m *MyHamtMap) Del(k string) *MyHamtMap {
+	if _, ok := m.theMap.Get(k); !ok {
+		return m
+	}
+
+	if m.mutable {
+		m.theMap = m.theMap.Del(k)
+		return m
+	}
+
+	res := m.dup()
+	res.theMap = res.theMap.Del(k)
+
+	return res
+}
+func (s *MyHamtMap) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
+	if s == nil {
+		return true
+	}
+
+	if s.Mutable() {
+		return false
+	}
+	return true
+}
+
+// MyHamtMapDiff is the change set between two MyHamtMap values, as returned by
+// MyHamtMap.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set.
+type MyHamtMapDiff struct {
+	Replaced bool
+	Value    *MyHamtMap
+
+	// Set holds the entries that were added or changed
+	Set map[string]int
+
+	// Del holds the keys of the entries that were deleted, in no particular
+	// order
+	Del []string
+}
+
+// Diff returns the change set required to turn m into other, or nil if there
+// is no difference.
+func (m *MyHamtMap) Diff(other *MyHamtMap) *MyHamtMapDiff {
+	if m == other {
+		return nil
+	}
+
+	if m == nil || other == nil {
+		return &MyHamtMapDiff{Replaced: true, Value: other}
+	}
+
+	res := &MyHamtMapDiff{
+		Set: make(map[string]int),
+	}
+
+	for k := range m.Range() {
+		if _, ok := other.Get(k); !ok {
+			res.Del = append(res.Del, k)
+		}
+	}
+
+	for k, ov := range other.Range() {
+		v, ok := m.Get(k)
+		if !ok {
+			res.Set[k] = ov
+			continue
+		}
+
+		if v != ov {
+			res.Set[k] = ov
+		}
+	}
+
+	if len(res.Set) == 0 && len(res.Del) == 0 {
+		return nil
+	}
+
+	return res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to m.
+func (m *MyHamtMap) Patch(d *MyHamtMapDiff) *MyHamtMap {
+	if d == nil {
+		return m
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if m == nil {
+		m = NewMyHamtMap()
+	}
+
+	return m.WithMutable(func(mi *MyHamtMap) {
+		for _, k := range d.Del {
+			mi.Del(k)
+		}
+
+		for k, v := range d.Set {
+			mi.Set(k, v)
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. m is marshalled as a JSON object.
+func (m *MyHamtMap) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+
+	if m.Len() == 0 {
+		return []byte("{}"), nil
+	}
+
+	return json.Marshal(m.Range())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. m is set to an immutable map
+// containing the unmarshalled entries. Because *m is replaced, m should be a
+// new value rather than one shared with other code.
+func (m *MyHamtMap) UnmarshalJSON(b []byte) error {
+	var v map[string]int
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*m = *NewMyHamtMap(func(mi *MyHamtMap) {
+		for k, e := range v {
+			mi.Set(k, e)
+		}
+	})
+
+	return nil
+}
+
+// AHM is an immutable type and has the following template:
+//
+//	map[*A]*A
+type AHM struct {
+	theMap  *hamt.Map[*A, *A]
+	mutable bool
+	__tmpl  *_Imm_AHM
+}
+
+var _ immutable.Immutable = new(AHM)
+var _ = new(AHM).__tmpl
+
+func NewAHM(inits ...func(m *AHM)) *AHM {
+	res := NewAHMCap(0)
+	if len(inits) == 0 {
+		return res
+	}
+
+	return res.WithMutable(func(m *AHM) {
+		for _, i := range inits {
+			i(m)
+		}
+	})
+}
+
+// NewAHMCap is provided for API compatibility with
+// immutable maps backed by a Go map; l is ignored.
+func NewAHMCap(l int) *AHM {
+	return &AHM{}
+}
+
+func (m *AHM) Mutable() bool {
+	return m.mutable
+}
+
+func (m *AHM) Len() int {
+	if m == nil {
+		return 0
+	}
+
+	return m.theMap.Len()
+}
+
+func (m *AHM) Get(k *A) (*A, bool) {
+	if m == nil {
+		var v *A
+		return v, false
+	}
+
+	return m.theMap.Get(k)
+}
+
+func (m *AHM) AsMutable() *AHM {
+	if m == nil {
+		return nil
+	}
+
+	if m.Mutable() {
+		return m
+	}
+
+	res := m.dup()
+	res.mutable = true
+
+	return res
+}
+
+func (m *AHM) dup() *AHM {
+	res := &AHM{
+		theMap: m.theMap,
+	}
+
+	return res
+}
+
+func (m *AHM) AsImmutable(v *AHM) *AHM {
+	if m == nil {
+		return nil
+	}
+
+	if v == m {
+		return m
+	}
+
+	m.mutable = false
+	return m
+}
+
+// Range returns a Go map containing the entries of m. Because the map is
+// built on each call it is O(n); use RangeFunc to avoid the allocation.
+func (m *AHM) Range() map[*A]*A {
+	if m == nil {
+		return nil
+	}
+
+	res := make(map[*A]*A, m.theMap.Len())
+
+	m.theMap.Range(func(k *A, v *A) bool {
+		res[k] = v
+		return true
+	})
+
+	return res
+}
+
+// RangeFunc calls f for each entry in m, stopping if f returns false.
+func (m *AHM) RangeFunc(f func(k *A, v *A) bool) {
+	if m == nil {
+		return
+	}
+
+	m.theMap.Range(f)
+}
+
+func (mr *AHM) WithMutable(f func(a *AHM)) *AHM {
+	res := mr.AsMutable()
+	f(res)
+	res = res.AsImmutable(mr)
+
+	return res
+}
+
+func (mr *AHM) WithImmutable(f func(a *AHM)) *AHM {
+	prev := mr.mutable
+	mr.mutable = false
+	f(mr)
+	mr.mutable = prev
+
+	return mr
+}
+
+func (m *AHM) Set(k *A, v *A) *AHM {
+	if m.mutable {
+		m.theMap = m.theMap.Set(k, v)
+		return m
+	}
+
+	res := m.dup()
+	res.theMap = res.theMap.Set(k, v)
+
+	return res
+}
+
+func (m *AHM) Del(k *A) *AHM {
+	if _, ok := m.theMap.Get(k); !ok {
+		return m
+	}
+
+	if m.mutable {
+		m.theMap = m.theMap.Del(k)
+		return m
+	}
+
+	res := m.dup()
+	res.theMap = res.theMap.Del(k)
+
+	return res
+}
+func (s *AHM) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
+	if s == nil {
+		return true
+	}
+
+	if s.Mutable() {
+		return false
+	}
+	if s.Len() == 0 {
+		return true
+	}
+
+	if seen == nil {
+		return s.IsDeeplyNonMutable(make(map[interface{}]bool))
+	}
+
+	if seen[s] {
+		return true
+	}
+
+	seen[s] = true
+
+	res := true
+	s.theMap.Range(func(k *A, v *A) bool {
+		if k != nil && !k.IsDeeplyNonMutable(seen) {
+			res = false
+			return false
+		}
+
+		if v != nil && !v.IsDeeplyNonMutable(seen) {
+			res = false
+			return false
+		}
+
+		return true
+	})
+
+	if !res {
+		return false
+	}
+	return true
+}
+
+// AHMDiff is the change set between two AHM values, as returned by
+// AHM.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set.
+type AHMDiff struct {
+	Replaced bool
+	Value    *AHM
+
+	// Set holds the entries that were added
+	Set map[*A]*A
+
+	// Changed holds the change sets of the entries that were changed
+	Changed map[*A]*ADiff
+
+	// Del holds the keys of the entries that were deleted, in no particular
+	// order
+	Del []*A
+}
+
+// Diff returns the change set required to turn m into other, or nil if there
+// is no difference.
+func (m *AHM) Diff(other *AHM) *AHMDiff {
+	if m == other {
+		return nil
+	}
+
+	if m == nil || other == nil {
+		return &AHMDiff{Replaced: true, Value: other}
+	}
+
+	res := &AHMDiff{
+		Set:     make(map[*A]*A),
+		Changed: make(map[*A]*ADiff),
+	}
+
+	for k := range m.Range() {
+		if _, ok := other.Get(k); !ok {
+			res.Del = append(res.Del, k)
+		}
+	}
+
+	for k, ov := range other.Range() {
+		v, ok := m.Get(k)
+		if !ok {
+			res.Set[k] = ov
+			continue
+		}
+
+		if d := v.Diff(ov); d != nil {
+			res.Changed[k] = d
+		}
+	}
+
+	if len(res.Set) == 0 && len(res.Del) == 0 && len(res.Changed) == 0 {
+		return nil
+	}
+
+	return res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to m.
+func (m *AHM) Patch(d *AHMDiff) *AHM {
+	if d == nil {
+		return m
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if m == nil {
+		m = NewAHM()
+	}
+
+	return m.WithMutable(func(mi *AHM) {
+		for _, k := range d.Del {
+			mi.Del(k)
+		}
+
+		for k, v := range d.Set {
+			mi.Set(k, v)
+		}
+
+		for k, vd := range d.Changed {
+			v, _ := mi.Get(k)
+			mi.Set(k, v.Patch(vd))
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. Because encoding/json does not
+// support keys of type *A, m is marshalled as a JSON array of objects
+// with Key and Value fields.
+func (m *AHM) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+
+	v := make([]struct {
+		Key   *A
+		Value *A
+	}, 0, m.Len())
+
+	for k, e := range m.Range() {
+		v = append(v, struct {
+			Key   *A
+			Value *A
+		}{k, e})
+	}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. m is set to an immutable map
+// containing the unmarshalled entries. Because *m is replaced, m should be a
+// new value rather than one shared with other code.
+func (m *AHM) UnmarshalJSON(b []byte) error {
+	var v []struct {
+		Key   *A
+		Value *A
+	}
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*m = *NewAHM(func(mi *AHM) {
+		for _, e := range v {
+			mi.Set(e.Key, e.Value)
+		}
+	})
+
+	return nil
+}
+
+// a comment about MyOrderedMap
+//
+// MyOrderedMap is an immutable type and has the following template:
+//
+//	map[string]int
+type MyOrderedMap struct {
+	theMap  map[string]int
+	theKeys []string
+	mutable bool
+	__tmpl  *_Imm_MyOrderedMap
+}
+
+var _ immutable.Immutable = new(MyOrderedMap)
+var _ = new(MyOrderedMap).__tmpl
+
+func NewMyOrderedMap(inits ...func(m *MyOrderedMap)) *MyOrderedMap {
+	res := NewMyOrderedMapCap(0)
+	if len(inits) == 0 {
+		return res
+	}
+
+	return res.WithMutable(func(m *MyOrderedMap) {
+		for _, i := range inits {
+			i(m)
+		}
+	})
+}
+
+func NewMyOrderedMapCap(l int) *MyOrderedMap {
+	return &MyOrderedMap{
+		theMap:  make(map[string]int, l),
+		theKeys: make([]string, 0, l),
+	}
+}
+
+func (m *MyOrderedMap) Mutable() bool {
+	return m.mutable
+}
+
+func (m *MyOrderedMap) Len() int {
+	if m == nil {
+		return 0
+	}
+
+	return len(m.theMap)
+}
+
+func (m *MyOrderedMap) Get(k string) (int, bool) {
+	v, ok := m.theMap[k]
+	return v, ok
+}
+
+func (m *MyOrderedMap) AsMutable() *MyOrderedMap {
+	if m == nil {
+		return nil
+	}
+
+	if m.Mutable() {
+		return m
+	}
+
+	res := m.dup()
+	res.mutable = true
+
+	return res
+}
+
+func (m *MyOrderedMap) dup() *MyOrderedMap {
+	resMap := make(map[string]int, len(m.theMap))
+
+	for k := range m.theMap {
+		resMap[k] = m.theMap[k]
+	}
+
+	resKeys := make([]string, len(m.theKeys))
+	copy(resKeys, m.theKeys)
+
+	res := &MyOrderedMap{
+		theMap:  resMap,
+		theKeys: resKeys,
+	}
+
+	return res
+}
+
+func (m *MyOrderedMap) AsImmutable(v *MyOrderedMap) *MyOrderedMap {
+	if m == nil {
+		return nil
+	}
+
+	if v == m {
+		return m
+	}
+
+	m.mutable = false
+	return m
+}
+
+// Range returns the Go map underlying m. Although m is ordered, iteration over
+// the result of Range is, as for any Go map, in random order; use RangeFunc,
+// Keys or Values to iterate in order.
+func (m *MyOrderedMap) Range() map[string]int {
+	if m == nil {
+		return nil
+	}
+
+	return m.theMap
+}
+
+// RangeFunc calls f for each entry in m in order, stopping if f returns
+// false.
+func (m *MyOrderedMap) RangeFunc(f func(k string, v int) bool) {
+	if m == nil {
+		return
+	}
+
+	for _, k := range m.theKeys {
+		if !f(k, m.theMap[k]) {
+			return
+		}
+	}
+}
+
+// Keys returns the keys of m in order.
+func (m *MyOrderedMap) Keys() []string {
+	if m == nil {
+		return nil
+	}
+
+	res := make([]string, len(m.theKeys))
+	copy(res, m.theKeys)
+
+	return res
+}
+
+// Values returns the values of m in the order of their keys.
+func (m *MyOrderedMap) Values() []int {
+	if m == nil {
+		return nil
+	}
+
+	res := make([]int, len(m.theKeys))
+
+	for i, k := range m.theKeys {
+		res[i] = m.theMap[k]
+	}
+
+	return res
+}
+
+// First returns the first entry in m, and false if m is empty.
+func (m *MyOrderedMap) First() (string, int, bool) {
+	return m.entry(0)
+}
+
+// Last returns the last entry in m, and false if m is empty.
+func (m *MyOrderedMap) Last() (string, int, bool) {
+	return m.entry(m.Len() - 1)
+}
+
+func (m *MyOrderedMap) entry(i int) (string, int, bool) {
+	if i < 0 || i >= m.Len() {
+		var k string
+		var v int
+		return k, v, false
+	}
+
+	k := m.theKeys[i]
+
+	return k, m.theMap[k], true
+}
+
+func (mr *MyOrderedMap) WithMutable(f func(m *MyOrderedMap)) *MyOrderedMap {
+	res := mr.AsMutable()
+	f(res)
+	res = res.AsImmutable(mr)
+
+	return res
+}
+
+func (mr *MyOrderedMap) WithImmutable(f func(m *MyOrderedMap)) *MyOrderedMap {
+	prev := mr.mutable
+	mr.mutable = false
+	f(mr)
+	mr.mutable = prev
+
+	return mr
+}
+
+func (m *MyOrderedMap) Set(k string, v int) *MyOrderedMap {
+	if m.mutable {
+		m.set(k, v)
+		return m
+	}
+
+	res := m.dup()
+	res.set(k, v)
+
+	return res
+}
+
+func (m *MyOrderedMap) set(k string, v int) {
+	if _, ok := m.theMap[k]; !ok {
+		m.theKeys = append(m.theKeys, k)
+	}
+
+	m.theMap[k] = v
+}
+
+func (m *MyOrderedMap) Del(k string) *MyOrderedMap {
+	if _, ok := m.theMap[k]; !ok {
+		return m
+	}
+
+	if m.mutable {
+		m.del(k)
+		return m
+	}
+
+	res := m.dup()
+	res.del(k)
+
+	return res
+}
+
+func (m *MyOrderedMap) del(k string) {
+	delete(m.theMap, k)
+
+	i := 0
+	for m.theKeys[i] != k {
+		i++
+	}
+
+	copy(m.theKeys[i:], m.theKeys[i+1:])
+
+	var zero string
+	m.theKeys[len(m.theKeys)-1] = zero
+	m.theKeys = m.theKeys[:len(m.theKeys)-1]
+}
+func (s *MyOrderedMap) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
+	if s == nil {
+		return true
+	}
+
+	if s.Mutable() {
+		return false
+	}
+	return true
+}
+
+// MyOrderedMapDiff is the change set between two MyOrderedMap values, as returned by
+// MyOrderedMap.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set.
+type MyOrderedMapDiff struct {
+	Replaced bool
+	Value    *MyOrderedMap
+
+	// Set holds the entries that were added or changed
+	Set map[string]int
+
+	// Del holds the keys of the entries that were deleted, in no particular
+	// order
+	Del []string
+}
+
+// Diff returns the change set required to turn m into other, or nil if there
+// is no difference.
+func (m *MyOrderedMap) Diff(other *MyOrderedMap) *MyOrderedMapDiff {
+	if m == other {
+		return nil
+	}
+
+	if m == nil || other == nil {
+		return &MyOrderedMapDiff{Replaced: true, Value: other}
+	}
+
+	res := &MyOrderedMapDiff{
+		Set: make(map[string]int),
+	}
+
+	for k := range m.Range() {
+		if _, ok := other.Get(k); !ok {
+			res.Del = append(res.Del, k)
+		}
+	}
+
+	for k, ov := range other.Range() {
+		v, ok := m.Get(k)
+		if !ok {
+			res.Set[k] = ov
+			continue
+		}
+
+		if v != ov {
+			res.Set[k] = ov
+		}
+	}
+
+	if len(res.Set) == 0 && len(res.Del) == 0 {
+		return nil
+	}
+
+	return res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to m.
+func (m *MyOrderedMap) Patch(d *MyOrderedMapDiff) *MyOrderedMap {
+	if d == nil {
+		return m
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if m == nil {
+		m = NewMyOrderedMap()
+	}
+
+	return m.WithMutable(func(mi *MyOrderedMap) {
+		for _, k := range d.Del {
+			mi.Del(k)
+		}
+
+		for k, v := range d.Set {
+			mi.Set(k, v)
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. In order to preserve the order of
+// its entries, m is marshalled as a JSON array of objects with Key and Value
+// fields.
+func (m *MyOrderedMap) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+
+	v := make([]struct {
+		Key   string
+		Value int
+	}, 0, m.Len())
+
+	for _, k := range m.theKeys {
+		e := m.theMap[k]
+		v = append(v, struct {
+			Key   string
+			Value int
+		}{k, e})
+	}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. m is set to an immutable map
+// containing the unmarshalled entries. Because *m is replaced, m should be a
+// new value rather than one shared with other code.
+func (m *MyOrderedMap) UnmarshalJSON(b []byte) error {
+	var v []struct {
+		Key   string
+		Value int
+	}
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*m = *NewMyOrderedMap(func(mi *MyOrderedMap) {
+		for _, e := range v {
+			mi.Set(e.Key, e.Value)
+		}
+	})
+
+	return nil
+}
+
+// MySortedMap is an immutable type and has the following template:
+//
+//	map[int]string
+type MySortedMap struct {
+	theMap  map[int]string
+	theKeys []int
+	mutable bool
+	__tmpl  *_Imm_MySortedMap
+}
+
+var _ immutable.Immutable = new(MySortedMap)
+var _ = new(MySortedMap).__tmpl
+
+func NewMySortedMap(inits ...func(m *MySortedMap)) *MySortedMap {
+	res := NewMySortedMapCap(0)
+	if len(inits) == 0 {
+		return res
+	}
+
+	return res.WithMutable(func(m *MySortedMap) {
+		for _, i := range inits {
+			i(m)
+		}
+	})
+}
+
+func NewMySortedMapCap(l int) *MySortedMap {
+	return &MySortedMap{
+		theMap:  make(map[int]string, l),
+		theKeys: make([]int, 0, l),
+	}
+}
+
+func (m *MySortedMap) Mutable() bool {
+	return m.mutable
+}
+
+func (m *MySortedMap) Len() int {
+	if m == nil {
+		return 0
+	}
+
+	return len(m.theMap)
+}
+
+func (m *MySortedMap) Get(k int) (string, bool) {
+	v, ok := m.theMap[k]
+	return v, ok
+}
+
+func (m *MySortedMap) AsMutable() *MySortedMap {
+	if m == nil {
+		return nil
+	}
+
+	if m.Mutable() {
+		return m
+	}
+
+	res := m.dup()
+	res.mutable = true
+
+	return res
+}
+
+func (m *MySortedMap) dup() *MySortedMap {
+	resMap := make(map[int]string, len(m.theMap))
+
+	for k := range m.theMap {
+		resMap[k] = m.theMap[k]
+	}
+
+	resKeys := make([]int, len(m.theKeys))
+	copy(resKeys, m.theKeys)
+
+	res := &MySortedMap{
+		theMap:  resMap,
+		theKeys: resKeys,
+	}
+
+	return res
+}
+
+func (m *MySortedMap) AsImmutable(v *MySortedMap) *MySortedMap {
+	if m == nil {
+		return nil
+	}
+
+	if v == m {
+		return m
+	}
+
+	m.mutable = false
+	return m
+}
+
+// Range returns the Go map underlying m. Although m is ordered, iteration over
+// the result of Range is, as for any Go map, in random order; use RangeFunc,
+// Keys or Values to iterate in order.
+func (m *MySortedMap) Range() map[int]string {
+	if m == nil {
+		return nil
+	}
+
+	return m.theMap
+}
+
+// RangeFunc calls f for each entry in m in order, stopping if f returns
+// false.
+func (m *MySortedMap) RangeFunc(f func(k int, v string) bool) {
+	if m == nil {
+		return
+	}
+
+	for _, k := range m.theKeys {
+		if !f(k, m.theMap[k]) {
+			return
+		}
+	}
+}
+
+// Keys returns the keys of m in order.
+func (m *MySortedMap) Keys() []int {
+	if m == nil {
+		return nil
+	}
+
+	res := make([]int, len(m.theKeys))
+	copy(res, m.theKeys)
+
+	return res
+}
+
+// Values returns the values of m in the order of their keys.
+func (m *MySortedMap) Values() []string {
+	if m == nil {
+		return nil
+	}
+
+	res := make([]string, len(m.theKeys))
+
+	for i, k := range m.theKeys {
+		res[i] = m.theMap[k]
+	}
+
+	return res
+}
+
+// First returns the first entry in m, and false if m is empty.
+func (m *MySortedMap) First() (int, string, bool) {
+	return m.entry(0)
+}
+
+// Last returns the last entry in m, and false if m is empty.
+func (m *MySortedMap) Last() (int, string, bool) {
+	return m.entry(m.Len() - 1)
+}
+
+func (m *MySortedMap) entry(i int) (int, string, bool) {
+	if i < 0 || i >= m.Len() {
+		var k int
+		var v string
+		return k, v, false
+	}
+
+	k := m.theKeys[i]
+
+	return k, m.theMap[k], true
+}
+
+// Floor returns the entry in m with the greatest key less than or equal to
+// k, and false if there is no such entry.
+func (m *MySortedMap) Floor(k int) (int, string, bool) {
+	i := m.search(k)
+	if i < m.Len() && !m.less(k, m.theKeys[i]) {
+		return m.entry(i)
+	}
+
+	return m.entry(i - 1)
+}
+
+// Ceiling returns the entry in m with the least key greater than or equal to
+// k, and false if there is no such entry.
+func (m *MySortedMap) Ceiling(k int) (int, string, bool) {
+	return m.entry(m.search(k))
+}
+
+// less reports whether a is ordered before b according to orderByKey
+func (m *MySortedMap) less(a, b int) bool {
+	ks := [2]int{a, b}
+	return bool(orderByKey(ks[:], 0, 1))
+}
+
+// search returns the index of the first key in m that is not ordered before
+// k, or m.Len() if there is no such key.
+func (m *MySortedMap) search(k int) int {
+	if m == nil {
+		return 0
+	}
+
+	return sort.Search(len(m.theKeys), func(i int) bool {
+		return !m.less(m.theKeys[i], k)
+	})
+}
+
+func (mr *MySortedMap) WithMutable(f func(m *MySortedMap)) *MySortedMap {
+	res := mr.AsMutable()
+	f(res)
+	res = res.AsImmutable(mr)
+
+	return res
+}
+
+func (mr *MySortedMap) WithImmutable(f func(m *MySortedMap)) *MySortedMap {
+	prev := mr.mutable
+	mr.mutable = false
+	f(mr)
+	mr.mutable = prev
+
+	return mr
+}
+
+func (m *MySortedMap) Set(k int, v string) *MySortedMap {
+	if m.mutable {
+		m.set(k, v)
+		return m
+	}
+
+	res := m.dup()
+	res.set(k, v)
+
+	return res
+}
+
+func (m *MySortedMap) set(k int, v string) {
+	if _, ok := m.theMap[k]; !ok {
+		i := m.search(k)
+		m.theKeys = append(m.theKeys, k)
+		copy(m.theKeys[i+1:], m.theKeys[i:])
+		m.theKeys[i] = k
+	}
+
+	m.theMap[k] = v
+}
+
+func (m *MySortedMap) Del(k int) *MySortedMap {
+	if _, ok := m.theMap[k]; !ok {
+		return m
+	}
+
+	if m.mutable {
+		m.del(k)
+		return m
+	}
+
+	res := m.dup()
+	res.del(k)
+
+	return res
+}
+
+func (m *MySortedMap) del(k int) {
+	delete(m.theMap, k)
+
+	// keys that are neither ordered before nor after k follow the first such
+	// key
+	i := m.search(k)
+	for m.theKeys[i] != k {
+		i++
+	}
+
+	copy(m.theKeys[i:], m.theKeys[i+1:])
+
+	var zero int
+	m.theKeys[len(m.theKeys)-1] = zero
+	m.theKeys = m.theKeys[:len(m.theKeys)-1]
+}
+func (s *MySortedMap) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
+	if s == nil {
+		return true
+	}
+
+	if s.Mutable() {
+		return false
+	}
+	return true
+}
+
+// MySortedMapDiff is the change set between two MySortedMap values, as returned by
+// MySortedMap.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set.
+type MySortedMapDiff struct {
+	Replaced bool
+	Value    *MySortedMap
+
+	// Set holds the entries that were added or changed
+	Set map[int]string
+
+	// Del holds the keys of the entries that were deleted, in no particular
+	// order
+	Del []int
+}
+
+// Diff returns the change set required to turn m into other, or nil if there
+// is no difference.
+func (m *MySortedMap) Diff(other *MySortedMap) *MySortedMapDiff {
+	if m == other {
+		return nil
+	}
+
+	if m == nil || other == nil {
+		return &MySortedMapDiff{Replaced: true, Value: other}
+	}
+
+	res := &MySortedMapDiff{
+		Set: make(map[int]string),
+	}
+
+	for k := range m.Range() {
+		if _, ok := other.Get(k); !ok {
+			res.Del = append(res.Del, k)
+		}
+	}
+
+	for k, ov := range other.Range() {
+		v, ok := m.Get(k)
+		if !ok {
+			res.Set[k] = ov
+			continue
+		}
+
+		if v != ov {
+			res.Set[k] = ov
+		}
+	}
+
+	if len(res.Set) == 0 && len(res.Del) == 0 {
+		return nil
+	}
+
+	return res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to m.
+func (m *MySortedMap) Patch(d *MySortedMapDiff) *MySortedMap {
+	if d == nil {
+		return m
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if m == nil {
+		m = NewMySortedMap()
+	}
+
+	return m.WithMutable(func(mi *MySortedMap) {
+		for _, k := range d.Del {
+			mi.Del(k)
+		}
+
+		for k, v := range d.Set {
+			mi.Set(k, v)
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. m is marshalled as a JSON object.
+func (m *MySortedMap) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+
+	if m.Len() == 0 {
+		return []byte("{}"), nil
+	}
+
+	return json.Marshal(m.Range())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. m is set to an immutable map
+// containing the unmarshalled entries. Because *m is replaced, m should be a
+// new value rather than one shared with other code.
+func (m *MySortedMap) UnmarshalJSON(b []byte) error {
+	var v map[int]string
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*m = *NewMySortedMap(func(mi *MySortedMap) {
+		for k, e := range v {
+			mi.Set(k, e)
+		}
+	})
+
+	return nil
+}
+
+// BinMap is an immutable type and has the following template:
+//
+//	map[string]*BinStruct
+type BinMap struct {
+	theMap  map[string]*BinStruct
+	mutable bool
+	__tmpl  *_Imm_BinMap
+}
+
+var _ immutable.Immutable = new(BinMap)
+var _ = new(BinMap).__tmpl
+
+func NewBinMap(inits ...func(m *BinMap)) *BinMap {
+	res := NewBinMapCap(0)
+	if len(inits) == 0 {
+		return res
+	}
+
+	return res.WithMutable(func(m *BinMap) {
+		for _, i := range inits {
+			i(m)
+		}
+	})
+}
+
+func NewBinMapCap(l int) *BinMap {
+	return &BinMap{
+		theMap: make(map[string]*BinStruct, l),
+	}
+}
+
+func (m *BinMap) Mutable() bool {
+	return m.mutable
+}
+
+func (m *BinMap) Len() int {
+	if m == nil {
+		return 0
+	}
+
+	return len(m.theMap)
+}
+
+func (m *BinMap) Get(k string) (*BinStruct, bool) {
+	v, ok := m.theMap[k]
+	return v, ok
+}
+
+func (m *BinMap) AsMutable() *BinMap {
+	if m == nil {
+		return nil
+	}
+
+	if m.Mutable() {
+		return m
+	}
+
+	res := m.dup()
+	res.mutable = true
+
+	return res
+}
+
+func (m *BinMap) dup() *BinMap {
+	resMap := make(map[string]*BinStruct, len(m.theMap))
+
+	for k := range m.theMap {
+		resMap[k] = m.theMap[k]
+	}
+
+	res := &BinMap{
+		theMap: resMap,
+	}
+
+	return res
+}
+
+func (m *BinMap) AsImmutable(v *BinMap) *BinMap {
+	if m == nil {
+		return nil
+	}
+
+	if v == m {
+		return m
+	}
+
+	m.mutable = false
+	return m
+}
+
+func (m *BinMap) Range() map[string]*BinStruct {
+	if m == nil {
+		return nil
+	}
+
+	return m.theMap
+}
+
+func (mr *BinMap) WithMutable(f func(b *BinMap)) *BinMap {
+	res := mr.AsMutable()
+	f(res)
+	res = res.AsImmutable(mr)
+
+	return res
+}
+
+func (mr *BinMap) WithImmutable(f func(b *BinMap)) *BinMap {
+	prev := mr.mutable
+	mr.mutable = false
+	f(mr)
+	mr.mutable = prev
+
+	return mr
+}
+
+func (m *BinMap) Set(k string, v *BinStruct) *BinMap {
+	if m.mutable {
+		m.theMap[k] = v
+		return m
+	}
+
+	res := m.dup()
+	res.theMap[k] = v
+
+	return res
+}
+
+func (m *BinMap) Del(k string) *BinMap {
+	if _, ok := m.theMap[k]; !ok {
+		return m
+	}
+
+	if m.mutable {
+		delete(m.theMap, k)
+		return m
+	}
+
+	res := m.dup()
+	delete(res.theMap, k)
+
+	return res
+}
+func (s *BinMap) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
+	if s == nil {
+		return true
+	}
+
+	if s.Mutable() {
+		return false
+	}
+	if s.Len() == 0 {
+		return true
+	}
+
+	if seen == nil {
+		return s.IsDeeplyNonMutable(make(map[interface{}]bool))
+	}
+
+	if seen[s] {
+		return true
+	}
+
+	seen[s] = true
+
+	for _, v := range s.theMap {
+		if v != nil && !v.IsDeeplyNonMutable(seen) {
+			return false
+		}
+	}
+	return true
+}
+
+// BinMapDiff is the change set between two BinMap values, as returned by
+// BinMap.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set.
+type BinMapDiff struct {
+	Replaced bool
+	Value    *BinMap
+
+	// Set holds the entries that were added
+	Set map[string]*BinStruct
+
+	// Changed holds the change sets of the entries that were changed
+	Changed map[string]*BinStructDiff
+
+	// Del holds the keys of the entries that were deleted, in no particular
+	// order
+	Del []string
+}
+
+// Diff returns the change set required to turn m into other, or nil if there
+// is no difference.
+func (m *BinMap) Diff(other *BinMap) *BinMapDiff {
+	if m == other {
+		return nil
+	}
+
+	if m == nil || other == nil {
+		return &BinMapDiff{Replaced: true, Value: other}
+	}
+
+	res := &BinMapDiff{
+		Set:     make(map[string]*BinStruct),
+		Changed: make(map[string]*BinStructDiff),
+	}
+
+	for k := range m.Range() {
+		if _, ok := other.Get(k); !ok {
+			res.Del = append(res.Del, k)
+		}
+	}
+
+	for k, ov := range other.Range() {
+		v, ok := m.Get(k)
+		if !ok {
+			res.Set[k] = ov
+			continue
+		}
+
+		if d := v.Diff(ov); d != nil {
+			res.Changed[k] = d
+		}
+	}
+
+	if len(res.Set) == 0 && len(res.Del) == 0 && len(res.Changed) == 0 {
+		return nil
+	}
+
+	return res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to m.
+func (m *BinMap) Patch(d *BinMapDiff) *BinMap {
+	if d == nil {
+		return m
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if m == nil {
+		m = NewBinMap()
+	}
+
+	return m.WithMutable(func(mi *BinMap) {
+		for _, k := range d.Del {
+			mi.Del(k)
+		}
+
+		for k, v := range d.Set {
+			mi.Set(k, v)
+		}
+
+		for k, vd := range d.Changed {
+			v, _ := mi.Get(k)
+			mi.Set(k, v.Patch(vd))
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. m is marshalled as a JSON object.
+func (m *BinMap) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+
+	if m.Len() == 0 {
+		return []byte("{}"), nil
+	}
+
+	return json.Marshal(m.Range())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. m is set to an immutable map
+// containing the unmarshalled entries. Because *m is replaced, m should be a
+// new value rather than one shared with other code.
+func (m *BinMap) UnmarshalJSON(b []byte) error {
+	var v map[string]*BinStruct
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*m = *NewBinMap(func(mi *BinMap) {
+		for k, e := range v {
+			mi.Set(k, e)
+		}
+	})
+
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler. Each entry of m is
+// encoded as a message of its key and value. See myitcv.io/immutable/wire for
+// details of the format.
+func (m *BinMap) MarshalBinary() ([]byte, error) {
+	var e wire.Encoder
+
+	if m == nil {
+		return e.Result()
+	}
+
+	for k, v := range m.Range() {
+		e.Message(1, func(e *wire.Encoder) {
+			e.String(1, k)
+			if v != nil {
+				e.Marshaler(2, v)
+			}
+		})
+	}
+
+	return e.Result()
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. m is set to an
+// immutable map containing the decoded entries.
+func (m *BinMap) UnmarshalBinary(b []byte) error {
+	d := wire.NewDecoder(b)
+
+	res := NewBinMap(func(mi *BinMap) {
+		for d.Next() {
+			if d.Tag() != 1 {
+				continue
+			}
+
+			var k string
+			var v *BinStruct
+
+			ed := d.Message()
+			for ed.Next() {
+				switch ed.Tag() {
+				case 1:
+					k = ed.String()
+				case 2:
+					v = new(BinStruct)
+					ed.Unmarshaler(v)
+				}
+			}
+
+			mi.Set(k, v)
+		}
+	})
+
+	if err := d.Err(); err != nil {
+		return err
+	}
+
+	*m = *res
+
+	return nil
+}
+
+// BinHamtMap is an immutable type and has the following template:
+//
+//	map[int]bool
+type BinHamtMap struct {
+	theMap  *hamt.Map[int, bool]
+	mutable bool
+	__tmpl  *_Imm_BinHamtMap
+}
+
+var _ immutable.Immutable = new(BinHamtMap)
+var _ = new(BinHamtMap).__tmpl
+
+func NewBinHamtMap(inits ...func(m *BinHamtMap)) *BinHamtMap {
+	res := NewBinHamtMapCap(0)
+	if len(inits) == 0 {
+		return res
+	}
+
+	return res.WithMutable(func(m *BinHamtMap) {
+		for _, i := range inits {
+			i(m)
+		}
+	})
+}
+
+// NewBinHamtMapCap is provided for API compatibility with
+// immutable maps backed by a Go map; l is ignored.
+func NewBinHamtMapCap(l int) *BinHamtMap {
+	return &BinHamtMap{}
+}
+
+func (m *BinHamtMap) Mutable() bool {
+	return m.mutable
+}
+
+func (m *BinHamtMap) Len() int {
+	if m == nil {
+		return 0
+	}
+
+	return m.theMap.Len()
+}
+
+func (m *BinHamtMap) Get(k int) (bool, bool) {
+	if m == nil {
+		var v bool
+		return v, false
+	}
+
+	return m.theMap.Get(k)
+}
+
+func (m *BinHamtMap) AsMutable() *BinHamtMap {
+	if m == nil {
+		return nil
+	}
+
+	if m.Mutable() {
+		return m
+	}
+
+	res := m.dup()
+	res.mutable = true
+
+	return res
+}
+
+func (m *BinHamtMap) dup() *BinHamtMap {
+	res := &BinHamtMap{
+		theMap: m.theMap,
+	}
+
+	return res
+}
+
+func (m *BinHamtMap) AsImmutable(v *BinHamtMap) *BinHamtMap {
+	if m == nil {
+		return nil
+	}
+
+	if v == m {
+		return m
+	}
+
+	m.mutable = false
+	return m
+}
+
+// Range returns a Go map containing the entries of m. Because the map is
+// built on each call it is O(n); use RangeFunc to avoid the allocation.
+func (m *BinHamtMap) Range() map[int]bool {
+	if m == nil {
+		return nil
+	}
+
+	res := make(map[int]bool, m.theMap.Len())
+
+	m.theMap.Range(func(k int, v bool) bool {
+		res[k] = v
+		return true
+	})
+
+	return res
+}
+
+// RangeFunc calls f for each entry in m, stopping if f returns false.
+func (m *BinHamtMap) RangeFunc(f func(k int, v bool) bool) {
+	if m == nil {
+		return
+	}
+
+	m.theMap.Range(f)
+}
+
+func (mr *BinHamtMap) WithMutable(f func(b *BinHamtMap)) *BinHamtMap {
+	res := mr.AsMutable()
+	f(res)
+	res = res.AsImmutable(mr)
+
+	return res
+}
+
+func (mr *BinHamtMap) WithImmutable(f func(b *BinHamtMap)) *BinHamtMap {
+	prev := mr.mutable
+	mr.mutable = false
+	f(mr)
+	mr.mutable = prev
+
+	return mr
+}
+
+func (m *BinHamtMap) Set(k int, v bool) *BinHamtMap {
+	if m.mutable {
+		m.theMap = m.theMap.Set(k, v)
+		return m
+	}
+
+	res := m.dup()
+	res.theMap = res.theMap.Set(k, v)
+
+	return res
+}
+
+func (m *BinHamtMap) Del(k int) *BinHamtMap {
+	if _, ok := m.theMap.Get(k); !ok {
+		return m
+	}
+
+	if m.mutable {
+		m.theMap = m.theMap.Del(k)
+		return m
+	}
+
+	res := m.dup()
+	res.theMap = res.theMap.Del(k)
+
+	return res
+}
+func (s *BinHamtMap) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
+	if s == nil {
+		return true
+	}
+
+	if s.Mutable() {
+		return false
+	}
+	return true
+}
+
+// BinHamtMapDiff is the change set between two BinHamtMap values, as returned by
+// BinHamtMap.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set.
+type BinHamtMapDiff struct {
+	Replaced bool
+	Value    *BinHamtMap
+
+	// Set holds the entries that were added or changed
+	Set map[int]bool
+
+	// Del holds the keys of the entries that were deleted, in no particular
+	// order
+	Del []int
+}
+
+// Diff returns the change set required to turn m into other, or nil if there
+// is no difference.
+func (m *BinHamtMap) Diff(other *BinHamtMap) *BinHamtMapDiff {
+	if m == other {
+		return nil
+	}
+
+	if m == nil || other == nil {
+		return &BinHamtMapDiff{Replaced: true, Value: other}
+	}
+
+	res := &BinHamtMapDiff{
+		Set: make(map[int]bool),
+	}
+
+	for k := range m.Range() {
+		if _, ok := other.Get(k); !ok {
+			res.Del = append(res.Del, k)
+		}
+	}
+
+	for k, ov := range other.Range() {
+		v, ok := m.Get(k)
+		if !ok {
+			res.Set[k] = ov
+			continue
+		}
+
+		if v != ov {
+			res.Set[k] = ov
+		}
+	}
+
+	if len(res.Set) == 0 && len(res.Del) == 0 {
+		return nil
+	}
+
+	return res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to m.
+func (m *BinHamtMap) Patch(d *BinHamtMapDiff) *BinHamtMap {
+	if d == nil {
+		return m
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if m == nil {
+		m = NewBinHamtMap()
+	}
+
+	return m.WithMutable(func(mi *BinHamtMap) {
+		for _, k := range d.Del {
+			mi.Del(k)
+		}
+
+		for k, v := range d.Set {
+			mi.Set(k, v)
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. m is marshalled as a JSON object.
+func (m *BinHamtMap) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+
+	if m.Len() == 0 {
+		return []byte("{}"), nil
+	}
+
+	return json.Marshal(m.Range())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. m is set to an immutable map
+// containing the unmarshalled entries. Because *m is replaced, m should be a
+// new value rather than one shared with other code.
+func (m *BinHamtMap) UnmarshalJSON(b []byte) error {
+	var v map[int]bool
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*m = *NewBinHamtMap(func(mi *BinHamtMap) {
+		for k, e := range v {
+			mi.Set(k, e)
+		}
+	})
+
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler. Each entry of m is
+// encoded as a message of its key and value. See myitcv.io/immutable/wire for
+// details of the format.
+func (m *BinHamtMap) MarshalBinary() ([]byte, error) {
+	var e wire.Encoder
+
+	if m == nil {
+		return e.Result()
+	}
+
+	for k, v := range m.Range() {
+		e.Message(1, func(e *wire.Encoder) {
+			e.Int(1, int64(k))
+			e.Bool(2, v)
+		})
+	}
+
+	return e.Result()
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. m is set to an
+// immutable map containing the decoded entries.
+func (m *BinHamtMap) UnmarshalBinary(b []byte) error {
+	d := wire.NewDecoder(b)
+
+	res := NewBinHamtMap(func(mi *BinHamtMap) {
+		for d.Next() {
+			if d.Tag() != 1 {
+				continue
+			}
+
+			var k int
+			var v bool
+
+			ed := d.Message()
+			for ed.Next() {
+				switch ed.Tag() {
+				case 1:
+					k = int(ed.Int())
+				case 2:
+					v = ed.Bool()
+				}
+			}
+
+			mi.Set(k, v)
+		}
+	})
+
+	if err := d.Err(); err != nil {
+		return err
+	}
+
+	*m = *res
+
+	return nil
+}
+
+// BinOrderedMap is an immutable type and has the following template:
+//
+//	map[string]int
+type BinOrderedMap struct {
+	theMap  map[string]int
+	theKeys []string
+	mutable bool
+	__tmpl  *_Imm_BinOrderedMap
+}
+
+var _ immutable.Immutable = new(BinOrderedMap)
+var _ = new(BinOrderedMap).__tmpl
+
+func NewBinOrderedMap(inits ...func(m *BinOrderedMap)) *BinOrderedMap {
+	res := NewBinOrderedMapCap(0)
+	if len(inits) == 0 {
+		return res
+	}
+
+	return res.WithMutable(func(m *BinOrderedMap) {
+		for _, i := range inits {
+			i(m)
+		}
+	})
+}
+
+func NewBinOrderedMapCap(l int) *BinOrderedMap {
+	return &BinOrderedMap{
+		theMap:  make(map[string]int, l),
+		theKeys: make([]string, 0, l),
+	}
+}
+
+func (m *BinOrderedMap) Mutable() bool {
+	return m.mutable
+}
+
+func (m *BinOrderedMap) Len() int {
+	if m == nil {
+		return 0
+	}
+
+	return len(m.theMap)
+}
+
+func (m *BinOrderedMap) Get(k string) (int, bool) {
+	v, ok := m.theMap[k]
+	return v, ok
+}
+
+func (m *BinOrderedMap) AsMutable() *BinOrderedMap {
+	if m == nil {
+		return nil
+	}
+
+	if m.Mutable() {
+		return m
+	}
+
+	res := m.dup()
+	res.mutable = true
+
+	return res
+}
+
+func (m *BinOrderedMap) dup() *BinOrderedMap {
+	resMap := make(map[string]int, len(m.theMap))
+
+	for k := range m.theMap {
+		resMap[k] = m.theMap[k]
+	}
+
+	resKeys := make([]string, len(m.theKeys))
+	copy(resKeys, m.theKeys)
+
+	res := &BinOrderedMap{
+		theMap:  resMap,
+		theKeys: resKeys,
+	}
+
+	return res
+}
+
+func (m *BinOrderedMap) AsImmutable(v *BinOrderedMap) *BinOrderedMap {
+	if m == nil {
+		return nil
+	}
+
+	if v == m {
+		return m
+	}
+
+	m.mutable = false
+	return m
+}
+
+// Range returns the Go map underlying m. Although m is ordered, iteration over
+// the result of Range is, as for any Go map, in random order; use RangeFunc,
+// Keys or Values to iterate in order.
+func (m *BinOrderedMap) Range() map[string]int {
+	if m == nil {
+		return nil
+	}
+
+	return m.theMap
+}
+
+// RangeFunc calls f for each entry in m in order, stopping if f returns
+// false.
+func (m *BinOrderedMap) RangeFunc(f func(k string, v int) bool) {
+	if m == nil {
+		return
+	}
+
+	for _, k := range m.theKeys {
+		if !f(k, m.theMap[k]) {
+			return
+		}
+	}
+}
+
+// Keys returns the keys of m in order.
+func (m *BinOrderedMap) Keys() []string {
+	if m == nil {
+		return nil
+	}
+
+	res := make([]string, len(m.theKeys))
+	copy(res, m.theKeys)
+
+	return res
+}
+
+// Values returns the values of m in the order of their keys.
+func (m *BinOrderedMap) Values() []int {
+	if m == nil {
+		return nil
+	}
+
+	res := make([]int, len(m.theKeys))
+
+	for i, k := range m.theKeys {
+		res[i] = m.theMap[k]
+	}
+
+	return res
+}
+
+// First returns the first entry in m, and false if m is empty.
+func (m *BinOrderedMap) First() (string, int, bool) {
+	return m.entry(0)
+}
+
+// Last returns the last entry in m, and false if m is empty.
+func (m *BinOrderedMap) Last() (string, int, bool) {
+	return m.entry(m.Len() - 1)
+}
+
+func (m *BinOrderedMap) entry(i int) (string, int, bool) {
+	if i < 0 || i >= m.Len() {
+		var k string
+		var v int
+		return k, v, false
+	}
+
+	k := m.theKeys[i]
+
+	return k, m.theMap[k], true
+}
+
+func (mr *BinOrderedMap) WithMutable(f func(b *BinOrderedMap)) *BinOrderedMap {
+	res := mr.AsMutable()
+	f(res)
+	res = res.AsImmutable(mr)
+
+	return res
+}
+
+func (mr *BinOrderedMap) WithImmutable(f func(b *BinOrderedMap)) *BinOrderedMap {
+	prev := mr.mutable
+	mr.mutable = false
+	f(mr)
+	mr.mutable = prev
+
+	return mr
+}
+
+func (m *BinOrderedMap) Set(k string, v int) *BinOrderedMap {
+	if m.mutable {
+		m.set(k, v)
+		return m
+	}
+
+	res := m.dup()
+	res.set(k, v)
+
+	return res
+}
+
+func (m *BinOrderedMap) set(k string, v int) {
+	if _, ok := m.theMap[k]; !ok {
+		m.theKeys = append(m.theKeys, k)
+	}
+
+	m.theMap[k] = v
+}
+
+func (m *BinOrderedMap) Del(k string) *BinOrderedMap {
+	if _, ok := m.theMap[k]; !ok {
+		return m
+	}
+
+	if m.mutable {
+		m.del(k)
+		return m
+	}
+
+	res := m.dup()
+	res.del(k)
+
+	return res
+}
+
+func (m *BinOrderedMap) del(k string) {
+	delete(m.theMap, k)
+
+	i := 0
+	for m.theKeys[i] != k {
+		i++
+	}
+
+	copy(m.theKeys[i:], m.theKeys[i+1:])
+
+	var zero string
+	m.theKeys[len(m.theKeys)-1] = zero
+	m.theKeys = m.theKeys[:len(m.theKeys)-1]
+}
+func (s *BinOrderedMap) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
+	if s == nil {
+		return true
+	}
+
+	if s.Mutable() {
+		return false
+	}
+	return true
+}
+
+// BinOrderedMapDiff is the change set between two BinOrderedMap values, as returned by
+// BinOrderedMap.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set.
+type BinOrderedMapDiff struct {
+	Replaced bool
+	Value    *BinOrderedMap
+
+	// Set holds the entries that were added or changed
+	Set map[string]int
+
+	// Del holds the keys of the entries that were deleted, in no particular
+	// order
+	Del []string
+}
+
+// Diff returns the change set required to turn m into other, or nil if there
+// is no difference.
+func (m *BinOrderedMap) Diff(other *BinOrderedMap) *BinOrderedMapDiff {
+	if m == other {
+		return nil
+	}
+
+	if m == nil || other == nil {
+		return &BinOrderedMapDiff{Replaced: true, Value: other}
+	}
+
+	res := &BinOrderedMapDiff{
+		Set: make(map[string]int),
+	}
+
+	for k := range m.Range() {
+		if _, ok := other.Get(k); !ok {
+			res.Del = append(res.Del, k)
+		}
+	}
+
+	for k, ov := range other.Range() {
+		v, ok := m.Get(k)
+		if !ok {
+			res.Set[k] = ov
+			continue
+		}
+
+		if v != ov {
+			res.Set[k] = ov
+		}
+	}
+
+	if len(res.Set) == 0 && len(res.Del) == 0 {
+		return nil
+	}
+
+	return res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to m.
+func (m *BinOrderedMap) Patch(d *BinOrderedMapDiff) *BinOrderedMap {
+	if d == nil {
+		return m
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if m == nil {
+		m = NewBinOrderedMap()
+	}
+
+	return m.WithMutable(func(mi *BinOrderedMap) {
+		for _, k := range d.Del {
+			mi.Del(k)
+		}
+
+		for k, v := range d.Set {
+			mi.Set(k, v)
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. In order to preserve the order of
+// its entries, m is marshalled as a JSON array of objects with Key and Value
+// fields.
+func (m *BinOrderedMap) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+
+	v := make([]struct {
+		Key   string
+		Value int
+	}, 0, m.Len())
+
+	for _, k := range m.theKeys {
+		e := m.theMap[k]
+		v = append(v, struct {
+			Key   string
+			Value int
+		}{k, e})
+	}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. m is set to an immutable map
+// containing the unmarshalled entries. Because *m is replaced, m should be a
+// new value rather than one shared with other code.
+func (m *BinOrderedMap) UnmarshalJSON(b []byte) error {
+	var v []struct {
+		Key   string
+		Value int
+	}
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*m = *NewBinOrderedMap(func(mi *BinOrderedMap) {
+		for _, e := range v {
+			mi.Set(e.Key, e.Value)
+		}
+	})
+
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler. Each entry of m is
+// encoded as a message of its key and value. See myitcv.io/immutable/wire for
+// details of the format.
+func (m *BinOrderedMap) MarshalBinary() ([]byte, error) {
+	var e wire.Encoder
+
+	if m == nil {
+		return e.Result()
+	}
+
+	for _, k := range m.theKeys {
+		v := m.theMap[k]
+		e.Message(1, func(e *wire.Encoder) {
+			e.String(1, k)
+			e.Int(2, int64(v))
+		})
+	}
+
+	return e.Result()
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. m is set to an
+// immutable map containing the decoded entries.
+func (m *BinOrderedMap) UnmarshalBinary(b []byte) error {
+	d := wire.NewDecoder(b)
+
+	res := NewBinOrderedMap(func(mi *BinOrderedMap) {
+		for d.Next() {
+			if d.Tag() != 1 {
+				continue
+			}
+
+			var k string
+			var v int
+
+			ed := d.Message()
+			for ed.Next() {
+				switch ed.Tag() {
+				case 1:
+					k = ed.String()
+				case 2:
+					v = int(ed.Int())
+				}
+			}
+
+			mi.Set(k, v)
+		}
+	})
+
+	if err := d.Err(); err != nil {
+		return err
+	}
+
+	*m = *res
+
+	return nil
+}
+
+// BinSortedMap is an immutable type and has the following template:
+//
+//	map[int]string
+type BinSortedMap struct {
+	theMap  map[int]string
+	theKeys []int
+	mutable bool
+	__tmpl  *_Imm_BinSortedMap
+}
+
+var _ immutable.Immutable = new(BinSortedMap)
+var _ = new(BinSortedMap).__tmpl
+
+func NewBinSortedMap(inits ...func(m *BinSortedMap)) *BinSortedMap {
+	res := NewBinSortedMapCap(0)
+	if len(inits) == 0 {
+		return res
+	}
+
+	return res.WithMutable(func(m *BinSortedMap) {
+		for _, i := range inits {
+			i(m)
+		}
+	})
+}
+
+func NewBinSortedMapCap(l int) *BinSortedMap {
+	return &BinSortedMap{
+		theMap:  make(map[int]string, l),
+		theKeys: make([]int, 0, l),
+	}
+}
+
+func (m *BinSortedMap) Mutable() bool {
+	return m.mutable
+}
+
+func (m *BinSortedMap) Len() int {
+	if m == nil {
+		return 0
+	}
+
+	return len(m.theMap)
+}
+
+func (m *BinSortedMap) Get(k int) (string, bool) {
+	v, ok := m.theMap[k]
+	return v, ok
+}
+
+func (m *BinSortedMap) AsMutable() *BinSortedMap {
+	if m == nil {
+		return nil
+	}
+
+	if m.Mutable() {
+		return m
+	}
+
+	res := m.dup()
+	res.mutable = true
+
+	return res
+}
+
+func (m *BinSortedMap) dup() *BinSortedMap {
+	resMap := make(map[int]string, len(m.theMap))
+
+	for k := range m.theMap {
+		resMap[k] = m.theMap[k]
+	}
+
+	resKeys := make([]int, len(m.theKeys))
+	copy(resKeys, m.theKeys)
+
+	res := &BinSortedMap{
+		theMap:  resMap,
+		theKeys: resKeys,
+	}
+
+	return res
+}
+
+func (m *BinSortedMap) AsImmutable(v *BinSortedMap) *BinSortedMap {
+	if m == nil {
+		return nil
+	}
+
+	if v == m {
+		return m
+	}
+
+	m.mutable = false
+	return m
+}
+
+// Range returns the Go map underlying m. Although m is ordered, iteration over
+// the result of Range is, as for any Go map, in random order; use RangeFunc,
+// Keys or Values to iterate in order.
+func (m *BinSortedMap) Range() map[int]string {
+	if m == nil {
+		return nil
+	}
+
+	return m.theMap
+}
+
+// RangeFunc calls f for each entry in m in order, stopping if f returns
+// false.
+func (m *BinSortedMap) RangeFunc(f func(k int, v string) bool) {
+	if m == nil {
+		return
+	}
+
+	for _, k := range m.theKeys {
+		if !f(k, m.theMap[k]) {
+			return
+		}
+	}
+}
+
+// Keys returns the keys of m in order.
+func (m *BinSortedMap) Keys() []int {
+	if m == nil {
+		return nil
+	}
+
+	res := make([]int, len(m.theKeys))
+	copy(res, m.theKeys)
+
+	return res
+}
+
+// Values returns the values of m in the order of their keys.
+func (m *BinSortedMap) Values() []string {
+	if m == nil {
+		return nil
+	}
+
+	res := make([]string, len(m.theKeys))
+
+	for i, k := range m.theKeys {
+		res[i] = m.theMap[k]
+	}
+
+	return res
+}
+
+// First returns the first entry in m, and false if m is empty.
+func (m *BinSortedMap) First() (int, string, bool) {
+	return m.entry(0)
+}
+
+// Last returns the last entry in m, and false if m is empty.
+func (m *BinSortedMap) Last() (int, string, bool) {
+	return m.entry(m.Len() - 1)
+}
+
+func (m *BinSortedMap) entry(i int) (int, string, bool) {
+	if i < 0 || i >= m.Len() {
+		var k int
+		var v string
+		return k, v, false
+	}
+
+	k := m.theKeys[i]
+
+	return k, m.theMap[k], true
+}
+
+// Floor returns the entry in m with the greatest key less than or equal to
+// k, and false if there is no such entry.
+func (m *BinSortedMap) Floor(k int) (int, string, bool) {
+	i := m.search(k)
+	if i < m.Len() && !m.less(k, m.theKeys[i]) {
+		return m.entry(i)
+	}
+
+	return m.entry(i - 1)
+}
+
+// Ceiling returns the entry in m with the least key greater than or equal to
+// k, and false if there is no such entry.
+func (m *BinSortedMap) Ceiling(k int) (int, string, bool) {
+	return m.entry(m.search(k))
+}
+
+// less reports whether a is ordered before b according to orderByKey
+func (m *BinSortedMap) less(a, b int) bool {
+	ks := [2]int{a, b}
+	return bool(orderByKey(ks[:], 0, 1))
+}
+
+// search returns the index of the first key in m that is not ordered before
+// k, or m.Len() if there is no such key.
+func (m *BinSortedMap) search(k int) int {
+	if m == nil {
+		return 0
+	}
+
+	return sort.Search(len(m.theKeys), func(i int) bool {
+		return !m.less(m.theKeys[i], k)
+	})
+}
+
+func (mr *BinSortedMap) WithMutable(f func(b *BinSortedMap)) *BinSortedMap {
+	res := mr.AsMutable()
+	f(res)
+	res = res.AsImmutable(mr)
+
+	return res
+}
+
+func (mr *BinSortedMap) WithImmutable(f func(b *BinSortedMap)) *BinSortedMap {
+	prev := mr.mutable
+	mr.mutable = false
+	f(mr)
+	mr.mutable = prev
+
+	return mr
+}
+
+func (m *BinSortedMap) Set(k int, v string) *BinSortedMap {
+	if m.mutable {
+		m.set(k, v)
+		return m
+	}
+
+	res := m.dup()
+	res.set(k, v)
+
+	return res
+}
+
+func (m *BinSortedMap) set(k int, v string) {
+	if _, ok := m.theMap[k]; !ok {
+		i := m.search(k)
+		m.theKeys = append(m.theKeys, k)
+		copy(m.theKeys[i+1:], m.theKeys[i:])
+		m.theKeys[i] = k
+	}
+
+	m.theMap[k] = v
+}
+
+func (m *BinSortedMap) Del(k int) *BinSortedMap {
+	if _, ok := m.theMap[k]; !ok {
+		return m
+	}
+
+	if m.mutable {
+		m.del(k)
+		return m
+	}
+
+	res := m.dup()
+	res.del(k)
+
+	return res
+}
+
+func (m *BinSortedMap) del(k int) {
+	delete(m.theMap, k)
+
+	// keys that are neither ordered before nor after k follow the first such
+	// key
+	i := m.search(k)
+	for m.theKeys[i] != k {
+		i++
+	}
+
+	copy(m.theKeys[i:], m.theKeys[i+1:])
+
+	var zero int
+	m.theKeys[len(m.theKeys)-1] = zero
+	m.theKeys = m.theKeys[:len(m.theKeys)-1]
+}
+func (s *BinSortedMap) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
+	if s == nil {
+		return true
+	}
+
+	if s.Mutable() {
+		return false
+	}
+	return true
+}
+
+// BinSortedMapDiff is the change set between two BinSortedMap values, as returned by
+// BinSortedMap.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set.
+type BinSortedMapDiff struct {
+	Replaced bool
+	Value    *BinSortedMap
+
+	// Set holds the entries that were added or changed
+	Set map[int]string
+
+	// Del holds the keys of the entries that were deleted, in no particular
+	// order
+	Del []int
+}
+
+// Diff returns the change set required to turn m into other, or nil if there
+// is no difference.
+func (m *BinSortedMap) Diff(other *BinSortedMap) *BinSortedMapDiff {
+	if m == other {
+		return nil
+	}
+
+	if m == nil || other == nil {
+		return &BinSortedMapDiff{Replaced: true, Value: other}
+	}
+
+	res := &BinSortedMapDiff{
+		Set: make(map[int]string),
+	}
+
+	for k := range m.Range() {
+		if _, ok := other.Get(k); !ok {
+			res.Del = append(res.Del, k)
+		}
+	}
+
+	for k, ov := range other.Range() {
+		v, ok := m.Get(k)
+		if !ok {
+			res.Set[k] = ov
+			continue
+		}
+
+		if v != ov {
+			res.Set[k] = ov
+		}
+	}
+
+	if len(res.Set) == 0 && len(res.Del) == 0 {
+		return nil
+	}
+
+	return res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to m.
+func (m *BinSortedMap) Patch(d *BinSortedMapDiff) *BinSortedMap {
+	if d == nil {
+		return m
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if m == nil {
+		m = NewBinSortedMap()
+	}
+
+	return m.WithMutable(func(mi *BinSortedMap) {
+		for _, k := range d.Del {
+			mi.Del(k)
+		}
+
+		for k, v := range d.Set {
+			mi.Set(k, v)
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. m is marshalled as a JSON object.
+func (m *BinSortedMap) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+
+	if m.Len() == 0 {
+		return []byte("{}"), nil
+	}
+
+	return json.Marshal(m.Range())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. m is set to an immutable map
+// containing the unmarshalled entries. Because *m is replaced, m should be a
+// new value rather than one shared with other code.
+func (m *BinSortedMap) UnmarshalJSON(b []byte) error {
+	var v map[int]string
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*m = *NewBinSortedMap(func(mi *BinSortedMap) {
+		for k, e := range v {
+			mi.Set(k, e)
+		}
+	})
+
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler. Each entry of m is
+// encoded as a message of its key and value. See myitcv.io/immutable/wire for
+// details of the format.
+func (m *BinSortedMap) MarshalBinary() ([]byte, error) {
+	var e wire.Encoder
+
+	if m == nil {
+		return e.Result()
+	}
+
+	for _, k := range m.theKeys {
+		v := m.theMap[k]
+		e.Message(1, func(e *wire.Encoder) {
+			e.Int(1, int64(k))
+			e.String(2, v)
+		})
+	}
+
+	return e.Result()
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. m is set to an
+// immutable map containing the decoded entries.
+func (m *BinSortedMap) UnmarshalBinary(b []byte) error {
+	d := wire.NewDecoder(b)
+
+	res := NewBinSortedMap(func(mi *BinSortedMap) {
+		for d.Next() {
+			if d.Tag() != 1 {
+				continue
+			}
+
+			var k int
+			var v string
+
+			ed := d.Message()
+			for ed.Next() {
+				switch ed.Tag() {
+				case 1:
+					k = int(ed.Int())
+				case 2:
+					v = ed.String()
+				}
+			}
+
+			mi.Set(k, v)
+		}
+	})
+
+	if err := d.Err(); err != nil {
+		return err
+	}
+
+	*m = *res
+
+	return nil
+}
+
+// a comment about MySet
+//
+// MySet is an immutable type and has the following template:
+//
+//	map[string]struct{}
+type MySet struct {
+	theSet  map[string]struct{}
+	mutable bool
+	__tmpl  *_Imm_MySet
+}
+
+var _ immutable.Immutable = new(MySet)
+var _ = new(MySet).__tmpl
+
+func NewMySet(vs ...string) *MySet {
+	res := NewMySetCap(len(vs))
+
+	for _, v := range vs {
+		res.theSet[v] = struct{}{}
+	}
+
+	return res
+}
+
+func NewMySetCap(l int) *MySet {
+	return &MySet{
+		theSet: make(map[string]struct{}, l),
+	}
+}
+
+func (m *MySet) Mutable() bool {
+	return m.mutable
+}
+
+func (m *MySet) Len() int {
+	if m == nil {
+		return 0
+	}
+
+	return len(m.theSet)
+}
+
+func (m *MySet) Contains(v string) bool {
+	if m == nil {
+		return false
+	}
+
+	_, ok := m.theSet[v]
+	return ok
+}
+
+func (m *MySet) AsMutable() *MySet {
+	if m == nil {
+		return nil
+	}
+
+	if m.Mutable() {
+		return m
+	}
+
+	res := m.dup()
+	res.mutable = true
+
+	return res
+}
+
+func (m *MySet) dup() *MySet {
+	resSet := make(map[string]struct{}, len(m.theSet))
+
+	for v := range m.theSet {
+		resSet[v] = struct{}{}
+	}
+
+	res := &MySet{
+		theSet: resSet,
+	}
+
+	return res
+}
+
+func (m *MySet) AsImmutable(v *MySet) *MySet {
+	if m == nil {
+		return nil
+	}
+
+	if v == m {
+		return m
+	}
+
+	m.mutable = false
+	return m
+}
+
+func (m *MySet) Range() map[string]struct{} {
+	if m == nil {
+		return nil
+	}
+
+	return m.theSet
+}
+
+func (mr *MySet) WithMutable(f func(m *MySet)) *MySet {
+	res := mr.AsMutable()
+	f(res)
+	res = res.AsImmutable(mr)
+
+	return res
+}
+
+func (mr *MySet) WithImmutable(f func(m *MySet)) *MySet {
+	prev := mr.mutable
+	mr.mutable = false
+	f(mr)
+	mr.mutable = prev
+
+	return mr
+}
+
+func (m *MySet) Add(vs ...string) *MySet {
+	if !m.mutable {
+		return m.WithMutable(func(mi *MySet) {
+			mi.Add(vs...)
+		})
+	}
+
+	for _, v := range vs {
+		m.theSet[v] = struct{}{}
+	}
+
+	return m
+}
+
+func (m *MySet) Remove(vs ...string) *MySet {
+	if !m.mutable {
+		return m.WithMutable(func(mi *MySet) {
+			mi.Remove(vs...)
+		})
+	}
+
+	for _, v := range vs {
+		delete(m.theSet, v)
+	}
+
+	return m
+}
+
+// Union returns the set of elements in either m or o.
+func (m *MySet) Union(o *MySet) *MySet {
+	if !m.mutable {
+		return m.WithMutable(func(mi *MySet) {
+			mi.Union(o)
+		})
+	}
+
+	for v := range o.Range() {
+		m.theSet[v] = struct{}{}
+	}
+
+	return m
+}
+
+// Intersect returns the set of elements in both m and o.
+func (m *MySet) Intersect(o *MySet) *MySet {
+	if !m.mutable {
+		return m.WithMutable(func(mi *MySet) {
+			mi.Intersect(o)
+		})
+	}
+
+	for v := range m.theSet {
+		if !o.Contains(v) {
+			delete(m.theSet, v)
+		}
+	}
+
+	return m
+}
+
+// Difference returns the set of elements in m that are not in o.
+func (m *MySet) Difference(o *MySet) *MySet {
+	if !m.mutable {
+		return m.WithMutable(func(mi *MySet) {
+			mi.Difference(o)
+		})
+	}
+
+	for v := range o.Range() {
+		delete(m.theSet, v)
+	}
+
+	return m
+}
+
+// IsSubset returns whether every element of m is also an element of o.
+func (m *MySet) IsSubset(o *MySet) bool {
+	if m.Len() > o.Len() {
+		return false
+	}
+
+	for v := range m.Range() {
+		if !o.Contains(v) {
+			return false
+		}
+	}
+
+	return true
+}
+func (s *MySet) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
+	if s == nil {
+		return true
+	}
+
+	if s.Mutable() {
+		return false
+	}
+	return true
+}
+
+// MySetDiff is the change set between two MySet values, as returned by
+// MySet.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set.
+type MySetDiff struct {
+	Replaced bool
+	Value    *MySet
+
+	// Add and Remove hold the elements that were added and removed
+	// respectively, in no particular order
+	Add    []string
+	Remove []string
+}
+
+// Diff returns the change set required to turn m into other, or nil if there
+// is no difference.
+func (m *MySet) Diff(other *MySet) *MySetDiff {
+	if m == other {
+		return nil
+	}
+
+	if m == nil || other == nil {
+		return &MySetDiff{Replaced: true, Value: other}
+	}
+
+	res := new(MySetDiff)
+
+	for v := range other.Range() {
+		if !m.Contains(v) {
+			res.Add = append(res.Add, v)
+		}
+	}
+
+	for v := range m.Range() {
+		if !other.Contains(v) {
+			res.Remove = append(res.Remove, v)
+		}
+	}
+
+	if len(res.Add) == 0 && len(res.Remove) == 0 {
+		return nil
+	}
+
+	return res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to m.
+func (m *MySet) Patch(d *MySetDiff) *MySet {
+	if d == nil {
+		return m
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if m == nil {
+		m = NewMySet()
+	}
+
+	return m.WithMutable(func(mi *MySet) {
+		mi.Remove(d.Remove...)
+		mi.Add(d.Add...)
+	})
+}
+
+// MarshalJSON implements json.Marshaler. m is marshalled as a JSON array of
+// its elements, in no particular order.
+func (m *MySet) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+
+	v := make([]string, 0, m.Len())
+
+	for e := range m.Range() {
+		v = append(v, e)
+	}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. m is set to an immutable set
+// containing the unmarshalled elements. Because *m is replaced, m should be a
+// new value rather than one shared with other code.
+func (m *MySet) UnmarshalJSON(b []byte) error {
+	var v []string
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*m = *NewMySet(v...)
+
+	return nil
+}
+
+// ASet is an immutable type and has the following template:
+//
+//	map[*A]struct{}
+type ASet struct {
+	theSet  map[*A]struct{}
+	mutable bool
+	__tmpl  *_Imm_ASet
+}
+
+var _ immutable.Immutable = new(ASet)
+var _ = new(ASet).__tmpl
+
+func NewASet(vs ...*A) *ASet {
+	res := NewASetCap(len(vs))
+
+	for _, v := range vs {
+		res.theSet[v] = struct{}{}
+	}
+
+	return res
+}
+
+func NewASetCap(l int) *ASet {
+	return &ASet{
+		theSet: make(map[*A]struct{}, l),
+	}
+}
+
+func (m *ASet) Mutable() bool {
+	return m.mutable
+}
+
+func (m *ASet) Len() int {
+	if m == nil {
+		return 0
+	}
+
+	return len(m.theSet)
+}
+
+func (m *ASet) Contains(v *A) bool {
+	if m == nil {
+		return false
+	}
+
+	_, ok := m.theSet[v]
+	return ok
+}
+
+func (m *ASet) AsMutable() *ASet {
+	if m == nil {
+		return nil
+	}
+
+	if m.Mutable() {
+		return m
+	}
+
+	res := m.dup()
+	res.mutable = true
+
+	return res
+}
+
+func (m *ASet) dup() *ASet {
+	resSet := make(map[*A]struct{}, len(m.theSet))
+
+	for v := range m.theSet {
+		resSet[v] = struct{}{}
+	}
+
+	res := &ASet{
+		theSet: resSet,
+	}
+
+	return res
+}
+
+func (m *ASet) AsImmutable(v *ASet) *ASet {
+	if m == nil {
+		return nil
+	}
+
+	if v == m {
+		return m
+	}
+
+	m.mutable = false
+	return m
+}
+
+func (m *ASet) Range() map[*A]struct{} {
+	if m == nil {
+		return nil
+	}
+
+	return m.theSet
+}
+
+func (mr *ASet) WithMutable(f func(a *ASet)) *ASet {
+	res := mr.AsMutable()
+	f(res)
+	res = res.AsImmutable(mr)
+
+	return res
+}
+
+func (mr *ASet) WithImmutable(f func(a *ASet)) *ASet {
+	prev := mr.mutable
+	mr.mutable = false
+	f(mr)
+	mr.mutable = prev
+
+	return mr
+}
+
+func (m *ASet) Add(vs ...*A) *ASet {
+	if !m.mutable {
+		return m.WithMutable(func(mi *ASet) {
+			mi.Add(vs...)
+		})
+	}
+
+	for _, v := range vs {
+		m.theSet[v] = struct{}{}
+	}
+
+	return m
+}
+
+func (m *ASet) Remove(vs ...*A) *ASet {
+	if !m.mutable {
+		return m.WithMutable(func(mi *ASet) {
+			mi.Remove(vs...)
+		})
+	}
+
+	for _, v := range vs {
+		delete(m.theSet, v)
+	}
+
+	return m
+}
+
+// Union returns the set of elements in either m or o.
+func (m *ASet) Union(o *ASet) *ASet {
+	if !m.mutable {
+		return m.WithMutable(func(mi *ASet) {
+			mi.Union(o)
+		})
+	}
+
+	for v := range o.Range() {
+		m.theSet[v] = struct{}{}
+	}
+
+	return m
+}
+
+// Intersect returns the set of elements in both m and o.
+func (m *ASet) Intersect(o *ASet) *ASet {
+	if !m.mutable {
+		return m.WithMutable(func(mi *ASet) {
+			mi.Intersect(o)
+		})
+	}
+
+	for v := range m.theSet {
+		if !o.Contains(v) {
+			delete(m.theSet, v)
+		}
+	}
+
+	return m
+}
+
+// Difference returns the set of elements in m that are not in o.
+func (m *ASet) Difference(o *ASet) *ASet {
+	if !m.mutable {
+		return m.WithMutable(func(mi *ASet) {
+			mi.Difference(o)
+		})
+	}
+
+	for v := range o.Range() {
+		delete(m.theSet, v)
+	}
+
+	return m
+}
+
+// IsSubset returns whether every element of m is also an element of o.
+func (m *ASet) IsSubset(o *ASet) bool {
+	if m.Len() > o.Len() {
+		return false
+	}
+
+	for v := range m.Range() {
+		if !o.Contains(v) {
+			return false
+		}
+	}
+
+	return true
+}
+func (s *ASet) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
+	if s == nil {
+		return true
+	}
+
+	if s.Mutable() {
+		return false
+	}
+	if s.Len() == 0 {
+		return true
+	}
+
+	if seen == nil {
+		return s.IsDeeplyNonMutable(make(map[interface{}]bool))
+	}
+
+	if seen[s] {
+		return true
+	}
+
+	seen[s] = true
+
+	for v := range s.theSet {
+		if v != nil && !v.IsDeeplyNonMutable(seen) {
+			return false
+		}
+	}
+	return true
+}
+
+// ASetDiff is the change set between two ASet values, as returned by
+// ASet.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set.
+type ASetDiff struct {
+	Replaced bool
+	Value    *ASet
+
+	// Add and Remove hold the elements that were added and removed
+	// respectively, in no particular order
+	Add    []*A
+	Remove []*A
+}
+
+// Diff returns the change set required to turn m into other, or nil if there
+// is no difference.
+func (m *ASet) Diff(other *ASet) *ASetDiff {
+	if m == other {
+		return nil
+	}
+
+	if m == nil || other == nil {
+		return &ASetDiff{Replaced: true, Value: other}
+	}
+
+	res := new(ASetDiff)
+
+	for v := range other.Range() {
+		if !m.Contains(v) {
+			res.Add = append(res.Add, v)
+		}
+	}
+
+	for v := range m.Range() {
+		if !other.Contains(v) {
+			res.Remove = append(res.Remove, v)
+		}
+	}
+
+	if len(res.Add) == 0 && len(res.Remove) == 0 {
+		return nil
+	}
+
+	return res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to m.
+func (m *ASet) Patch(d *ASetDiff) *ASet {
+	if d == nil {
+		return m
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if m == nil {
+		m = NewASet()
+	}
+
+	return m.WithMutable(func(mi *ASet) {
+		mi.Remove(d.Remove...)
+		mi.Add(d.Add...)
+	})
+}
+
+// MarshalJSON implements json.Marshaler. m is marshalled as a JSON array of
+// its elements, in no particular order.
+func (m *ASet) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+
+	v := make([]*A, 0, m.Len())
+
+	for e := range m.Range() {
+		v = append(v, e)
+	}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. m is set to an immutable set
+// containing the unmarshalled elements. Because *m is replaced, m should be a
+// new value rather than one shared with other code.
+func (m *ASet) UnmarshalJSON(b []byte) error {
+	var v []*A
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*m = *NewASet(v...)
+
+	return nil
+}
+
+// BinSet is an immutable type and has the following template:
+//
+//	map[string]struct{}
+type BinSet struct {
+	theSet  map[string]struct{}
+	mutable bool
+	__tmpl  *_Imm_BinSet
+}
+
+var _ immutable.Immutable = new(BinSet)
+var _ = new(BinSet).__tmpl
+
+func NewBinSet(vs ...string) *BinSet {
+	res := NewBinSetCap(len(vs))
+
+	for _, v := range vs {
+		res.theSet[v] = struct{}{}
+	}
+
+	return res
+}
+
+func NewBinSetCap(l int) *BinSet {
+	return &BinSet{
+		theSet: make(map[string]struct{}, l),
+	}
+}
+
+func (m *BinSet) Mutable() bool {
+	return m.mutable
+}
+
+func (m *BinSet) Len() int {
+	if m == nil {
+		return 0
+	}
+
+	return len(m.theSet)
+}
+
+func (m *BinSet) Contains(v string) bool {
+	if m == nil {
+		return false
+	}
+
+	_, ok := m.theSet[v]
+	return ok
+}
+
+func (m *BinSet) AsMutable() *BinSet {
+	if m == nil {
+		return nil
+	}
+
+	if m.Mutable() {
+		return m
+	}
+
+	res := m.dup()
+	res.mutable = true
+
+	return res
+}
+
+func (m *BinSet) dup() *BinSet {
+	resSet := make(map[string]struct{}, len(m.theSet))
+
+	for v := range m.theSet {
+		resSet[v] = struct{}{}
+	}
+
+	res := &BinSet{
+		theSet: resSet,
+	}
+
+	return res
+}
+
+func (m *BinSet) AsImmutable(v *BinSet) *BinSet {
+	if m == nil {
+		return nil
+	}
+
+	if v == m {
+		return m
+	}
+
+	m.mutable = false
+	return m
+}
+
+func (m *BinSet) Range() map[string]struct{} {
+	if m == nil {
+		return nil
+	}
+
+	return m.theSet
+}
+
+func (mr *BinSet) WithMutable(f func(b *BinSet)) *BinSet {
+	res := mr.AsMutable()
+	f(res)
+	res = res.AsImmutable(mr)
+
+	return res
+}
+
+func (mr *BinSet) WithImmutable(f func(b *BinSet)) *BinSet {
+	prev := mr.mutable
+	mr.mutable = false
+	f(mr)
+	mr.mutable = prev
+
+	return mr
+}
+
+func (m *BinSet) Add(vs ...string) *BinSet {
+	if !m.mutable {
+		return m.WithMutable(func(mi *BinSet) {
+			mi.Add(vs...)
+		})
+	}
+
+	for _, v := range vs {
+		m.theSet[v] = struct{}{}
+	}
+
+	return m
+}
+
+func (m *BinSet) Remove(vs ...string) *BinSet {
+	if !m.mutable {
+		return m.WithMutable(func(mi *BinSet) {
+			mi.Remove(vs...)
+		})
+	}
+
+	for _, v := range vs {
+		delete(m.theSet, v)
+	}
+
+	return m
+}
+
+// Union returns the set of elements in either m or o.
+func (m *BinSet) Union(o *BinSet) *BinSet {
+	if !m.mutable {
+		return m.WithMutable(func(mi *BinSet) {
+			mi.Union(o)
+		})
+	}
+
+	for v := range o.Range() {
+		m.theSet[v] = struct{}{}
+	}
+
+	return m
+}
+
+// Intersect returns the set of elements in both m and o.
+func (m *BinSet) Intersect(o *BinSet) *BinSet {
+	if !m.mutable {
+		return m.WithMutable(func(mi *BinSet) {
+			mi.Intersect(o)
+		})
+	}
+
+	for v := range m.theSet {
+		if !o.Contains(v) {
+			delete(m.theSet, v)
+		}
+	}
+
+	return m
+}
+
+// Difference returns the set of elements in m that are not in o.
+func (m *BinSet) Difference(o *BinSet) *BinSet {
+	if !m.mutable {
+		return m.WithMutable(func(mi *BinSet) {
+			mi.Difference(o)
+		})
+	}
+
+	for v := range o.Range() {
+		delete(m.theSet, v)
+	}
+
+	return m
+}
+
+// IsSubset returns whether every element of m is also an element of o.
+func (m *BinSet) IsSubset(o *BinSet) bool {
+	if m.Len() > o.Len() {
+		return false
+	}
+
+	for v := range m.Range() {
+		if !o.Contains(v) {
+			return false
+		}
+	}
+
+	return true
+}
+func (s *BinSet) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
+	if s == nil {
+		return true
+	}
+
+	if s.Mutable() {
+		return false
+	}
+	return true
+}
+
+// BinSetDiff is the change set between two BinSet values, as returned by
+// BinSet.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set.
+type BinSetDiff struct {
+	Replaced bool
+	Value    *BinSet
+
+	// Add and Remove hold the elements that were added and removed
+	// respectively, in no particular order
+	Add    []string
+	Remove []string
+}
+
+// Diff returns the change set required to turn m into other, or nil if there
+// is no difference.
+func (m *BinSet) Diff(other *BinSet) *BinSetDiff {
+	if m == other {
+		return nil
+	}
+
+	if m == nil || other == nil {
+		return &BinSetDiff{Replaced: true, Value: other}
+	}
+
+	res := new(BinSetDiff)
+
+	for v := range other.Range() {
+		if !m.Contains(v) {
+			res.Add = append(res.Add, v)
+		}
+	}
+
+	for v := range m.Range() {
+		if !other.Contains(v) {
+			res.Remove = append(res.Remove, v)
+		}
+	}
+
+	if len(res.Add) == 0 && len(res.Remove) == 0 {
+		return nil
+	}
+
+	return res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to m.
+func (m *BinSet) Patch(d *BinSetDiff) *BinSet {
+	if d == nil {
+		return m
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if m == nil {
+		m = NewBinSet()
+	}
+
+	return m.WithMutable(func(mi *BinSet) {
+		mi.Remove(d.Remove...)
+		mi.Add(d.Add...)
+	})
+}
+
+// MarshalJSON implements json.Marshaler. m is marshalled as a JSON array of
+// its elements, in no particular order.
+func (m *BinSet) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+
+	v := make([]string, 0, m.Len())
+
+	for e := range m.Range() {
+		v = append(v, e)
+	}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. m is set to an immutable set
+// containing the unmarshalled elements. Because *m is replaced, m should be a
+// new value rather than one shared with other code.
+func (m *BinSet) UnmarshalJSON(b []byte) error {
+	var v []string
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*m = *NewBinSet(v...)
+
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler. The elements of m are
+// encoded in no particular order, each as a message. See myitcv.io/immutable/wire for
+// details of the format.
+func (m *BinSet) MarshalBinary() ([]byte, error) {
+	var e wire.Encoder
+
+	if m == nil {
+		return e.Result()
+	}
+
+	for v := range m.Range() {
+		e.Message(1, func(e *wire.Encoder) {
+			e.String(1, v)
+		})
+	}
+
+	return e.Result()
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. m is set to an
+// immutable set containing the decoded elements.
+func (m *BinSet) UnmarshalBinary(b []byte) error {
+	var vs []string
+
+	d := wire.NewDecoder(b)
+
+	for d.Next() {
+		if d.Tag() != 1 {
+			continue
+		}
+
+		var v string
+
+		ed := d.Message()
+		for ed.Next() {
+			if ed.Tag() == 1 {
+				v = ed.String()
+			}
+		}
+
+		vs = append(vs, v)
+	}
+
+	if err := d.Err(); err != nil {
+		return err
+	}
+
+	*m = *NewBinSet(vs...)
+
+	return nil
+}
+
+// a comment about Slice
+//
+// MySlice is an immutable type and has the following template:
+//
+//	[]string
+type MySlice struct {
+	theSlice []string
+	mutable  bool
+	__tmpl   *_Imm_MySlice
+}
+
+var _ immutable.Immutable = new(MySlice)
+var _ = new(MySlice).__tmpl
+
+func NewMySlice(s ...string) *MySlice {
+	c := make([]string, len(s))
+	copy(c, s)
+
+	return &MySlice{
+		theSlice: c,
+	}
+}
+
+func NewMySliceLen(l int) *MySlice {
+	c := make([]string, l)
+
+	return &MySlice{
+		theSlice: c,
+	}
+}
+
+func (m *MySlice) Mutable() bool {
+	return m.mutable
+}
+
+func (m *MySlice) Len() int {
+	if m == nil {
+		return 0
+	}
+
+	return len(m.theSlice)
+}
+
+func (m *MySlice) Get(i int) string {
+	return m.theSlice[i]
+}
+
+func (m *MySlice) AsMutable() *MySlice {
+	if m == nil {
+		return nil
+	}
+
+	if m.Mutable() {
+		return m
+	}
+
+	res := m.dup()
+	res.mutable = true
+
+	return res
+}
+
+func (m *MySlice) dup() *MySlice {
+	resSlice := make([]string, len(m.theSlice))
+
+	for i := range m.theSlice {
+		resSlice[i] = m.theSlice[i]
+	}
+
+	res := &MySlice{
+		theSlice: resSlice,
+	}
+
+	return res
+}
+
+func (m *MySlice) AsImmutable(v *MySlice) *MySlice {
+	if m == nil {
+		return nil
+	}
+
+	if v == m {
+		return m
+	}
+
+	m.mutable = false
+	return m
+}
+
+func (m *MySlice) Range() []string {
+	if m == nil {
+		return nil
+	}
+
+	return m.theSlice
+}
+
+func (m *MySlice) WithMutable(f func(mi *MySlice)) *MySlice {
+	res := m.AsMutable()
+	f(res)
+	res = res.AsImmutable(m)
+
+	return res
+}
+
+func (m *MySlice) WithImmutable(f func(mi *MySlice)) *MySlice {
+	prev := m.mutable
+	m.mutable = false
+	f(m)
+	m.mutable = prev
+
+	return m
+}
+
+func (m *MySlice) Set(i int, v string) *MySlice {
+	if m.mutable {
+		m.theSlice[i] = v
+		return m
+	}
+
+	res := m.dup()
+	res.theSlice[i] = v
+
+	return res
+}
+
+func (m *MySlice) Append(v ...string) *MySlice {
+	if m.mutable {
+		m.theSlice = append(m.theSlice, v...)
+		return m
+	}
+
+	res := m.dup()
+	res.theSlice = append(res.theSlice, v...)
+
+	return res
+}
+
+func (m *MySlice) Slice(i, j int) *MySlice {
+	if m.mutable {
+		m.theSlice = m.theSlice[i:j]
+		return m
+	}
+
+	resSlice := make([]string, j-i)
+	copy(resSlice, m.theSlice[i:j])
+
+	res := &MySlice{
+		theSlice: resSlice,
+	}
+
+	return res
+}
+
+func (m *MySlice) Concat(o *MySlice) *MySlice {
+	return m.Append(o.Range()...)
+}
+func (s *MySlice) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
+	if s == nil {
+		return true
+	}
+
+	if s.Mutable() {
+		return false
+	}
+	return true
+}
+
+// MySliceDiff is the change set between two MySlice values, as returned by
+// MySlice.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set.
+type MySliceDiff struct {
+	Replaced bool
+	Value    *MySlice
+
+	// Len is the length of the new value; elements beyond Len are truncated
+	// and elements are appended to reach Len as required
+	Len int
+
+	// Set holds the elements, by index, that were added or changed
+	Set map[int]string
+}
+
+// Diff returns the change set required to turn m into other, or nil if there
+// is no difference. Elements are compared by index.
+func (m *MySlice) Diff(other *MySlice) *MySliceDiff {
+	if m == other {
+		return nil
+	}
+
+	if m == nil || other == nil {
+		return &MySliceDiff{Replaced: true, Value: other}
+	}
+
+	res := &MySliceDiff{
+		Len: other.Len(),
+		Set: make(map[int]string),
+	}
+
+	for i := 0; i < other.Len(); i++ {
+		ov := other.Get(i)
+		if i >= m.Len() {
+			res.Set[i] = ov
+			continue
+		}
+
+		v := m.Get(i)
+
+		if v != ov {
+			res.Set[i] = ov
+		}
+	}
+
+	if m.Len() == other.Len() && len(res.Set) == 0 {
+		return nil
+	}
+
+	return res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to m.
+func (m *MySlice) Patch(d *MySliceDiff) *MySlice {
+	if d == nil {
+		return m
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if m == nil {
+		m = new(MySlice)
+	}
+
+	return m.WithMutable(func(mi *MySlice) {
+		if l := mi.Len(); l > d.Len {
+			mi.Slice(0, d.Len)
+		} else if l < d.Len {
+			mi.Append(make([]string, d.Len-l)...)
+		}
+
+		for i, v := range d.Set {
+			mi.Set(i, v)
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. m is marshalled as a JSON array.
+func (m *MySlice) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+
+	if m.Len() == 0 {
+		return []byte("[]"), nil
+	}
+
+	return json.Marshal(m.Range())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. m is set to an immutable slice
+// containing the unmarshalled elements. Because *m is replaced, m should be a
+// new value rather than one shared with other code.
+func (m *MySlice) UnmarshalJSON(b []byte) error {
+	var v []string
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*m = *NewMySlice(v...)
+
+	return nil
+}
+
+// AS is an immutable type and has the following template:
+//
+//	[]*A
+type AS struct {
+	theSlice []*A
+	mutable  bool
+	__tmpl   *_Imm_AS
+}
+
+var _ immutable.Immutable = new(AS)
+var _ = new(AS).__tmpl
+
+func NewAS(s ...*A) *AS {
+	c := make([]*A, len(s))
+	copy(c, s)
+
+	return &AS{
+		theSlice: c,
+	}
+}
+
+func NewASLen(l int) *AS {
+	c := make([]*A, l)
+
+	return &AS{
+		theSlice: c,
+	}
+}
+
+func (m *AS) Mutable() bool {
+	return m.mutable
+}
+
+func (m *AS) Len() int {
+	if m == nil {
+		return 0
+	}
+
+	return len(m.theSlice)
+}
+
+func (m *AS) Get(i int) *A {
+	return m.theSlice[i]
+}
+
+func (m *AS) AsMutable() *AS {
+	if m == nil {
+		return nil
+	}
+
+	if m.Mutable() {
+		return m
+	}
+
+	res := m.dup()
+	res.mutable = true
+
+	return res
+}
+
+func (m *AS) dup() *AS {
+	resSlice := make([]*A, len(m.theSlice))
+
+	for i := range m.theSlice {
+		resSlice[i] = m.theSlice[i]
+	}
+
+	res := &AS{
+		theSlice: resSlice,
+	}
+
+	return res
+}
+
+func (m *AS) AsImmutable(v *AS) *AS {
+	if m == nil {
+		return nil
+	}
+
+	if v == m {
+		return m
+	}
+
+	m.mutable = false
+	return m
+}
+
+func (m *AS) Range() []*A {
+	if m == nil {
+		return nil
+	}
+
+	return m.theSlice
+}
+
+func (m *AS) WithMutable(f func(mi *AS)) *AS {
+	res := m.AsMutable()
+	f(res)
+	res = res.AsImmutable(m)
+
+	return res
+}
+
+func (m *AS) WithImmutable(f func(mi *AS)) *AS {
+	prev := m.mutable
+	m.mutable = false
+	f(m)
+	m.mutable = prev
+
+	return m
+}
+
+func (m *AS) Set(i int, v *A) *AS {
+	if m.mutable {
+		m.theSlice[i] = v
+		return m
+	}
+
+	res := m.dup()
+	res.theSlice[i] = v
+
+	return res
+}
+
+func (m *AS) Append(v ...*A) *AS {
+	if m.mutable {
+		m.theSlice = append(m.theSlice, v...)
+		return m
+	}
+
+	res := m.dup()
+	res.theSlice = append(res.theSlice, v...)
+
+	return res
+}
+
+func (m *AS) Slice(i, j int) *AS {
+	if m.mutable {
+		m.theSlice = m.theSlice[i:j]
+		return m
+	}
+
+	resSlice := make([]*A, j-i)
+	copy(resSlice, m.theSlice[i:j])
+
+	res := &AS{
+		theSlice: resSlice,
+	}
+
+	return res
+}
+
+func (m *AS) Concat(o *AS) *AS {
+	return m.Append(o.Range()...)
+}
 func (s *AS) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	if s == nil {
 		return true
@@ -642,9 +5299,2724 @@ func (s *AS) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	if s.Mutable() {
 		return false
 	}
-	if s.Len() == 0 {
-		return true
-	}
+	if s.Len() == 0 {
+		return true
+	}
+
+	if seen == nil {
+		return s.IsDeeplyNonMutable(make(map[interface{}]bool))
+	}
+
+	if seen[s] {
+		return true
+	}
+
+	seen[s] = true
+
+	for _, v := range s.theSlice {
+		if v != nil && !v.IsDeeplyNonMutable(seen) {
+			return false
+		}
+	}
+	return true
+}
+
+// ASDiff is the change set between two AS values, as returned by
+// AS.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set.
+type ASDiff struct {
+	Replaced bool
+	Value    *AS
+
+	// Len is the length of the new value; elements beyond Len are truncated
+	// and elements are appended to reach Len as required
+	Len int
+
+	// Set holds the elements, by index, that were added
+	Set map[int]*A
+
+	// Changed holds the change sets, by index, of the elements that were
+	// changed
+	Changed map[int]*ADiff
+}
+
+// Diff returns the change set required to turn m into other, or nil if there
+// is no difference. Elements are compared by index.
+func (m *AS) Diff(other *AS) *ASDiff {
+	if m == other {
+		return nil
+	}
+
+	if m == nil || other == nil {
+		return &ASDiff{Replaced: true, Value: other}
+	}
+
+	res := &ASDiff{
+		Len:     other.Len(),
+		Set:     make(map[int]*A),
+		Changed: make(map[int]*ADiff),
+	}
+
+	for i := 0; i < other.Len(); i++ {
+		ov := other.Get(i)
+		if i >= m.Len() {
+			res.Set[i] = ov
+			continue
+		}
+
+		v := m.Get(i)
+
+		if d := v.Diff(ov); d != nil {
+			res.Changed[i] = d
+		}
+	}
+
+	if m.Len() == other.Len() && len(res.Set) == 0 && len(res.Changed) == 0 {
+		return nil
+	}
+
+	return res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to m.
+func (m *AS) Patch(d *ASDiff) *AS {
+	if d == nil {
+		return m
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if m == nil {
+		m = new(AS)
+	}
+
+	return m.WithMutable(func(mi *AS) {
+		if l := mi.Len(); l > d.Len {
+			mi.Slice(0, d.Len)
+		} else if l < d.Len {
+			mi.Append(make([]*A, d.Len-l)...)
+		}
+
+		for i, v := range d.Set {
+			mi.Set(i, v)
+		}
+
+		for i, vd := range d.Changed {
+			mi.Set(i, mi.Get(i).Patch(vd))
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. m is marshalled as a JSON array.
+func (m *AS) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+
+	if m.Len() == 0 {
+		return []byte("[]"), nil
+	}
+
+	return json.Marshal(m.Range())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. m is set to an immutable slice
+// containing the unmarshalled elements. Because *m is replaced, m should be a
+// new value rather than one shared with other code.
+func (m *AS) UnmarshalJSON(b []byte) error {
+	var v []*A
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*m = *NewAS(v...)
+
+	return nil
+}
+
+// a comment about MyVectorSlice
+//
+// MyVectorSlice is an immutable type and has the following template:
+//
+//	[]string
+type MyVectorSlice struct {
+	theSlice *vector.Vector[string]
+	mutable  bool
+	__tmpl   *_Imm_MyVectorSlice
+}
+
+var _ immutable.Immutable = new(MyVectorSlice)
+var _ = new(MyVectorSlice).__tmpl
+
+func NewMyVectorSlice(s ...string) *MyVectorSlice {
+	return &MyVectorSlice{
+		theSlice: vector.New(s...),
+	}
+}
+
+func NewMyVectorSliceLen(l int) *MyVectorSlice {
+	return &MyVectorSlice{
+		theSlice: vector.New(make([]string, l)...),
+	}
+}
+
+func (m *MyVectorSlice) Mutable() bool {
+	return m.mutable
+}
+
+func (m *MyVectorSlice) Len() int {
+	if m == nil {
+		return 0
+	}
+
+	return m.theSlice.Len()
+}
+
+func (m *MyVectorSlice) Get(i int) string {
+	return m.theSlice.Get(i)
+}
+
+func (m *MyVectorSlice) AsMutable() *MyVectorSlice {
+	if m == nil {
+		return nil
+	}
+
+	if m.Mutable() {
+		return m
+	}
+
+	res := m.dup()
+	res.mutable = true
+
+	return res
+}
+
+func (m *MyVectorSlice) dup() *MyVectorSlice {
+	res := &MyVectorSlice{
+		theSlice: m.theSlice,
+	}
+
+	return res
+}
+
+func (m *MyVectorSlice) AsImmutable(v *MyVectorSlice) *MyVectorSlice {
+	if m == nil {
+		return nil
+	}
+
+	if v == m {
+		return m
+	}
+
+	m.mutable = false
+	return m
+}
+
+// Range returns a Go slice containing the elements of m. Because the slice
+// is built on each call it is O(n); use RangeFunc to avoid the allocation.
+func (m *MyVectorSlice) Range() []string {
+	if m == nil {
+		return nil
+	}
+
+	return m.theSlice.ToSlice()
+}
+
+// RangeFunc calls f for each index and element in m in order, stopping if f
+// returns false.
+func (m *MyVectorSlice) RangeFunc(f func(i int, v string) bool) {
+	if m == nil {
+		return
+	}
+
+	m.theSlice.Range(f)
+}
+
+func (m *MyVectorSlice) WithMutable(f func(mi *MyVectorSlice)) *MyVectorSlice {
+	res := m.AsMutable()
+	f(res)
+	res = res.AsImmutable(m)
+
+	return res
+}
+
+func (m *MyVectorSlice) WithImmutable(f func(mi *MyVectorSlice)) *MyVectorSlice {
+	prev := m.mutable
+	m.mutable = false
+	f(m)
+	m.mutable = prev
+
+	return m
+}
+
+func (m *MyVectorSlice) Set(i int, v string) *MyVectorSlice {
+	if m.mutable {
+		m.theSlice = m.theSlice.Set(i, v)
+		return m
+	}
+
+	res := m.dup()
+	res.theSlice = res.theSlice.Set(i, v)
+
+	return res
+}
+
+func (m *MyVectorSlice) Append(v ...string) *MyVectorSlice {
+	if m.mutable {
+		m.theSlice = m.theSlice.Append(v...)
+		return m
+	}
+
+	res := m.dup()
+	res.theSlice = res.theSlice.Append(v...)
+
+	return res
+}
+
+func (m *MyVectorSlice) Slice(i, j int) *MyVectorSlice {
+	if m.mutable {
+		m.theSlice = m.theSlice.Slice(i, j)
+		return m
+	}
+
+	res := m.dup()
+	res.theSlice = res.theSlice.Slice(i, j)
+
+	return res
+}
+
+func (m *MyVectorSlice) Concat(o *MyVectorSlice) *MyVectorSlice {
+	var os *vector.Vector[string]
+	if o != nil {
+		os = o.theSlice
+	}
+
+	if m.mutable {
+		m.theSlice = m.theSlice.Concat(os)
+		return m
+	}
+
+	res := m.dup()
+	res.theSlice = res.theSlice.Concat(os)
+
+	return res
+}
+func (s *MyVectorSlice) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
+	if s == nil {
+		return true
+	}
+
+	if s.Mutable() {
+		return false
+	}
+	return true
+}
+
+// MyVectorSliceDiff is the change set between two MyVectorSlice values, as returned by
+// MyVectorSlice.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set.
+type MyVectorSliceDiff struct {
+	Replaced bool
+	Value    *MyVectorSlice
+
+	// Len is the length of the new value; elements beyond Len are truncated
+	// and elements are appended to reach Len as required
+	Len int
+
+	// Set holds the elements, by index, that were added or changed
+	Set map[int]string
+}
+
+// Diff returns the change set required to turn m into other, or nil if there
+// is no difference. Elements are compared by index.
+func (m *MyVectorSlice) Diff(other *MyVectorSlice) *MyVectorSliceDiff {
+	if m == other {
+		return nil
+	}
+
+	if m == nil || other == nil {
+		return &MyVectorSliceDiff{Replaced: true, Value: other}
+	}
+
+	res := &MyVectorSliceDiff{
+		Len: other.Len(),
+		Set: make(map[int]string),
+	}
+
+	for i := 0; i < other.Len(); i++ {
+		ov := other.Get(i)
+		if i >= m.Len() {
+			res.Set[i] = ov
+			continue
+		}
+
+		v := m.Get(i)
+
+		if v != ov {
+			res.Set[i] = ov
+		}
+	}
+
+	if m.Len() == other.Len() && len(res.Set) == 0 {
+		return nil
+	}
+
+	return res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to m.
+func (m *MyVectorSlice) Patch(d *MyVectorSliceDiff) *MyVectorSlice {
+	if d == nil {
+		return m
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if m == nil {
+		m = new(MyVectorSlice)
+	}
+
+	return m.WithMutable(func(mi *MyVectorSlice) {
+		if l := mi.Len(); l > d.Len {
+			mi.Slice(0, d.Len)
+		} else if l < d.Len {
+			mi.Append(make([]string, d.Len-l)...)
+		}
+
+		for i, v := range d.Set {
+			mi.Set(i, v)
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. m is marshalled as a JSON array.
+func (m *MyVectorSlice) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+
+	if m.Len() == 0 {
+		return []byte("[]"), nil
+	}
+
+	return json.Marshal(m.Range())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. m is set to an immutable slice
+// containing the unmarshalled elements. Because *m is replaced, m should be a
+// new value rather than one shared with other code.
+func (m *MyVectorSlice) UnmarshalJSON(b []byte) error {
+	var v []string
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*m = *NewMyVectorSlice(v...)
+
+	return nil
+}
+
+// AVS is an immutable type and has the following template:
+//
+//	[]*A
+type AVS struct {
+	theSlice *vector.Vector[*A]
+	mutable  bool
+	__tmpl   *_Imm_AVS
+}
+
+var _ immutable.Immutable = new(AVS)
+var _ = new(AVS).__tmpl
+
+func NewAVS(s ...*A) *AVS {
+	return &AVS{
+		theSlice: vector.New(s...),
+	}
+}
+
+func NewAVSLen(l int) *AVS {
+	return &AVS{
+		theSlice: vector.New(make([]*A, l)...),
+	}
+}
+
+func (m *AVS) Mutable() bool {
+	return m.mutable
+}
+
+func (m *AVS) Len() int {
+	if m == nil {
+		return 0
+	}
+
+	return m.theSlice.Len()
+}
+
+func (m *AVS) Get(i int) *A {
+	return m.theSlice.Get(i)
+}
+
+func (m *AVS) AsMutable() *AVS {
+	if m == nil {
+		return nil
+	}
+
+	if m.Mutable() {
+		return m
+	}
+
+	res := m.dup()
+	res.mutable = true
+
+	return res
+}
+
+func (m *AVS) dup() *AVS {
+	res := &AVS{
+		theSlice: m.theSlice,
+	}
+
+	return res
+}
+
+func (m *AVS) AsImmutable(v *AVS) *AVS {
+	if m == nil {
+		return nil
+	}
+
+	if v == m {
+		return m
+	}
+
+	m.mutable = false
+	return m
+}
+
+// Range returns a Go slice containing the elements of m. Because the slice
+// is built on each call it is O(n); use RangeFunc to avoid the allocation.
+func (m *AVS) Range() []*A {
+	if m == nil {
+		return nil
+	}
+
+	return m.theSlice.ToSlice()
+}
+
+// RangeFunc calls f for each index and element in m in order, stopping if f
+// returns false.
+func (m *AVS) RangeFunc(f func(i int, v *A) bool) {
+	if m == nil {
+		return
+	}
+
+	m.theSlice.Range(f)
+}
+
+func (m *AVS) WithMutable(f func(mi *AVS)) *AVS {
+	res := m.AsMutable()
+	f(res)
+	res = res.AsImmutable(m)
+
+	return res
+}
+
+func (m *AVS) WithImmutable(f func(mi *AVS)) *AVS {
+	prev := m.mutable
+	m.mutable = false
+	f(m)
+	m.mutable = prev
+
+	return m
+}
+
+func (m *AVS) Set(i int, v *A) *AVS {
+	if m.mutable {
+		m.theSlice = m.theSlice.Set(i, v)
+		return m
+	}
+
+	res := m.dup()
+	res.theSlice = res.theSlice.Set(i, v)
+
+	return res
+}
+
+func (m *AVS) Append(v ...*A) *AVS {
+	if m.mutable {
+		m.theSlice = m.theSlice.Append(v...)
+		return m
+	}
+
+	res := m.dup()
+	res.theSlice = res.theSlice.Append(v...)
+
+	return res
+}
+
+func (m *AVS) Slice(i, j int) *AVS {
+	if m.mutable {
+		m.theSlice = m.theSlice.Slice(i, j)
+		return m
+	}
+
+	res := m.dup()
+	res.theSlice = res.theSlice.Slice(i, j)
+
+	return res
+}
+
+func (m *AVS) Concat(o *AVS) *AVS {
+	var os *vector.Vector[*A]
+	if o != nil {
+		os = o.theSlice
+	}
+
+	if m.mutable {
+		m.theSlice = m.theSlice.Concat(os)
+		return m
+	}
+
+	res := m.dup()
+	res.theSlice = res.theSlice.Concat(os)
+
+	return res
+}
+func (s *AVS) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
+	if s == nil {
+		return true
+	}
+
+	if s.Mutable() {
+		return false
+	}
+	if s.Len() == 0 {
+		return true
+	}
+
+	if seen == nil {
+		return s.IsDeeplyNonMutable(make(map[interface{}]bool))
+	}
+
+	if seen[s] {
+		return true
+	}
+
+	seen[s] = true
+
+	res := true
+	s.theSlice.Range(func(_ int, v *A) bool {
+		if v != nil && !v.IsDeeplyNonMutable(seen) {
+			res = false
+			return false
+		}
+
+		return true
+	})
+
+	if !res {
+		return false
+	}
+	return true
+}
+
+// AVSDiff is the change set between two AVS values, as returned by
+// AVS.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set.
+type AVSDiff struct {
+	Replaced bool
+	Value    *AVS
+
+	// Len is the length of the new value; elements beyond Len are truncated
+	// and elements are appended to reach Len as required
+	Len int
+
+	// Set holds the elements, by index, that were added
+	Set map[int]*A
+
+	// Changed holds the change sets, by index, of the elements that were
+	// changed
+	Changed map[int]*ADiff
+}
+
+// Diff returns the change set required to turn m into other, or nil if there
+// is no difference. Elements are compared by index.
+func (m *AVS) Diff(other *AVS) *AVSDiff {
+	if m == other {
+		return nil
+	}
+
+	if m == nil || other == nil {
+		return &AVSDiff{Replaced: true, Value: other}
+	}
+
+	res := &AVSDiff{
+		Len:     other.Len(),
+		Set:     make(map[int]*A),
+		Changed: make(map[int]*ADiff),
+	}
+
+	for i := 0; i < other.Len(); i++ {
+		ov := other.Get(i)
+		if i >= m.Len() {
+			res.Set[i] = ov
+			continue
+		}
+
+		v := m.Get(i)
+
+		if d := v.Diff(ov); d != nil {
+			res.Changed[i] = d
+		}
+	}
+
+	if m.Len() == other.Len() && len(res.Set) == 0 && len(res.Changed) == 0 {
+		return nil
+	}
+
+	return res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to m.
+func (m *AVS) Patch(d *AVSDiff) *AVS {
+	if d == nil {
+		return m
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if m == nil {
+		m = new(AVS)
+	}
+
+	return m.WithMutable(func(mi *AVS) {
+		if l := mi.Len(); l > d.Len {
+			mi.Slice(0, d.Len)
+		} else if l < d.Len {
+			mi.Append(make([]*A, d.Len-l)...)
+		}
+
+		for i, v := range d.Set {
+			mi.Set(i, v)
+		}
+
+		for i, vd := range d.Changed {
+			mi.Set(i, mi.Get(i).Patch(vd))
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. m is marshalled as a JSON array.
+func (m *AVS) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+
+	if m.Len() == 0 {
+		return []byte("[]"), nil
+	}
+
+	return json.Marshal(m.Range())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. m is set to an immutable slice
+// containing the unmarshalled elements. Because *m is replaced, m should be a
+// new value rather than one shared with other code.
+func (m *AVS) UnmarshalJSON(b []byte) error {
+	var v []*A
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*m = *NewAVS(v...)
+
+	return nil
+}
+
+// BinSlice is an immutable type and has the following template:
+//
+//	[]string
+type BinSlice struct {
+	theSlice []string
+	mutable  bool
+	__tmpl   *_Imm_BinSlice
+}
+
+var _ immutable.Immutable = new(BinSlice)
+var _ = new(BinSlice).__tmpl
+
+func NewBinSlice(s ...string) *BinSlice {
+	c := make([]string, len(s))
+	copy(c, s)
+
+	return &BinSlice{
+		theSlice: c,
+	}
+}
+
+func NewBinSliceLen(l int) *BinSlice {
+	c := make([]string, l)
+
+	return &BinSlice{
+		theSlice: c,
+	}
+}
+
+func (m *BinSlice) Mutable() bool {
+	return m.mutable
+}
+
+func (m *BinSlice) Len() int {
+	if m == nil {
+		return 0
+	}
+
+	return len(m.theSlice)
+}
+
+func (m *BinSlice) Get(i int) string {
+	return m.theSlice[i]
+}
+
+func (m *BinSlice) AsMutable() *BinSlice {
+	if m == nil {
+		return nil
+	}
+
+	if m.Mutable() {
+		return m
+	}
+
+	res := m.dup()
+	res.mutable = true
+
+	return res
+}
+
+func (m *BinSlice) dup() *BinSlice {
+	resSlice := make([]string, len(m.theSlice))
+
+	for i := range m.theSlice {
+		resSlice[i] = m.theSlice[i]
+	}
+
+	res := &BinSlice{
+		theSlice: resSlice,
+	}
+
+	return res
+}
+
+func (m *BinSlice) AsImmutable(v *BinSlice) *BinSlice {
+	if m == nil {
+		return nil
+	}
+
+	if v == m {
+		return m
+	}
+
+	m.mutable = false
+	return m
+}
+
+func (m *BinSlice) Range() []string {
+	if m == nil {
+		return nil
+	}
+
+	return m.theSlice
+}
+
+func (m *BinSlice) WithMutable(f func(mi *BinSlice)) *BinSlice {
+	res := m.AsMutable()
+	f(res)
+	res = res.AsImmutable(m)
+
+	return res
+}
+
+func (m *BinSlice) WithImmutable(f func(mi *BinSlice)) *BinSlice {
+	prev := m.mutable
+	m.mutable = false
+	f(m)
+	m.mutable = prev
+
+	return m
+}
+
+func (m *BinSlice) Set(i int, v string) *BinSlice {
+	if m.mutable {
+		m.theSlice[i] = v
+		return m
+	}
+
+	res := m.dup()
+	res.theSlice[i] = v
+
+	return res
+}
+
+func (m *BinSlice) Append(v ...string) *BinSlice {
+	if m.mutable {
+		m.theSlice = append(m.theSlice, v...)
+		return m
+	}
+
+	res := m.dup()
+	res.theSlice = append(res.theSlice, v...)
+
+	return res
+}
+
+func (m *BinSlice) Slice(i, j int) *BinSlice {
+	if m.mutable {
+		m.theSlice = m.theSlice[i:j]
+		return m
+	}
+
+	resSlice := make([]string, j-i)
+	copy(resSlice, m.theSlice[i:j])
+
+	res := &BinSlice{
+		theSlice: resSlice,
+	}
+
+	return res
+}
+
+func (m *BinSlice) Concat(o *BinSlice) *BinSlice {
+	return m.Append(o.Range()...)
+}
+func (s *BinSlice) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
+	if s == nil {
+		return true
+	}
+
+	if s.Mutable() {
+		return false
+	}
+	return true
+}
+
+// BinSliceDiff is the change set between two BinSlice values, as returned by
+// BinSlice.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set.
+type BinSliceDiff struct {
+	Replaced bool
+	Value    *BinSlice
+
+	// Len is the length of the new value; elements beyond Len are truncated
+	// and elements are appended to reach Len as required
+	Len int
+
+	// Set holds the elements, by index, that were added or changed
+	Set map[int]string
+}
+
+// Diff returns the change set required to turn m into other, or nil if there
+// is no difference. Elements are compared by index.
+func (m *BinSlice) Diff(other *BinSlice) *BinSliceDiff {
+	if m == other {
+		return nil
+	}
+
+	if m == nil || other == nil {
+		return &BinSliceDiff{Replaced: true, Value: other}
+	}
+
+	res := &BinSliceDiff{
+		Len: other.Len(),
+		Set: make(map[int]string),
+	}
+
+	for i := 0; i < other.Len(); i++ {
+		ov := other.Get(i)
+		if i >= m.Len() {
+			res.Set[i] = ov
+			continue
+		}
+
+		v := m.Get(i)
+
+		if v != ov {
+			res.Set[i] = ov
+		}
+	}
+
+	if m.Len() == other.Len() && len(res.Set) == 0 {
+		return nil
+	}
+
+	return res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to m.
+func (m *BinSlice) Patch(d *BinSliceDiff) *BinSlice {
+	if d == nil {
+		return m
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if m == nil {
+		m = new(BinSlice)
+	}
+
+	return m.WithMutable(func(mi *BinSlice) {
+		if l := mi.Len(); l > d.Len {
+			mi.Slice(0, d.Len)
+		} else if l < d.Len {
+			mi.Append(make([]string, d.Len-l)...)
+		}
+
+		for i, v := range d.Set {
+			mi.Set(i, v)
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. m is marshalled as a JSON array.
+func (m *BinSlice) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+
+	if m.Len() == 0 {
+		return []byte("[]"), nil
+	}
+
+	return json.Marshal(m.Range())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. m is set to an immutable slice
+// containing the unmarshalled elements. Because *m is replaced, m should be a
+// new value rather than one shared with other code.
+func (m *BinSlice) UnmarshalJSON(b []byte) error {
+	var v []string
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*m = *NewBinSlice(v...)
+
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler. The elements of m are
+// encoded in order, each as a message. See myitcv.io/immutable/wire for
+// details of the format.
+func (m *BinSlice) MarshalBinary() ([]byte, error) {
+	var e wire.Encoder
+
+	if m == nil {
+		return e.Result()
+	}
+
+	for _, v := range m.Range() {
+		e.Message(1, func(e *wire.Encoder) {
+			e.String(1, v)
+		})
+	}
+
+	return e.Result()
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. m is set to an
+// immutable slice containing the decoded elements.
+func (m *BinSlice) UnmarshalBinary(b []byte) error {
+	var vs []string
+
+	d := wire.NewDecoder(b)
+
+	for d.Next() {
+		if d.Tag() != 1 {
+			continue
+		}
+
+		var v string
+
+		ed := d.Message()
+		for ed.Next() {
+			if ed.Tag() == 1 {
+				v = ed.String()
+			}
+		}
+
+		vs = append(vs, v)
+	}
+
+	if err := d.Err(); err != nil {
+		return err
+	}
+
+	*m = *NewBinSlice(vs...)
+
+	return nil
+}
+
+// BinVectorSlice is an immutable type and has the following template:
+//
+//	[]int
+type BinVectorSlice struct {
+	theSlice *vector.Vector[int]
+	mutable  bool
+	__tmpl   *_Imm_BinVectorSlice
+}
+
+var _ immutable.Immutable = new(BinVectorSlice)
+var _ = new(BinVectorSlice).__tmpl
+
+func NewBinVectorSlice(s ...int) *BinVectorSlice {
+	return &BinVectorSlice{
+		theSlice: vector.New(s...),
+	}
+}
+
+func NewBinVectorSliceLen(l int) *BinVectorSlice {
+	return &BinVectorSlice{
+		theSlice: vector.New(make([]int, l)...),
+	}
+}
+
+func (m *BinVectorSlice) Mutable() bool {
+	return m.mutable
+}
+
+func (m *BinVectorSlice) Len() int {
+	if m == nil {
+		return 0
+	}
+
+	return m.theSlice.Len()
+}
+
+func (m *BinVectorSlice) Get(i int) int {
+	return m.theSlice.Get(i)
+}
+
+func (m *BinVectorSlice) AsMutable() *BinVectorSlice {
+	if m == nil {
+		return nil
+	}
+
+	if m.Mutable() {
+		return m
+	}
+
+	res := m.dup()
+	res.mutable = true
+
+	return res
+}
+
+func (m *BinVectorSlice) dup() *BinVectorSlice {
+	res := &BinVectorSlice{
+		theSlice: m.theSlice,
+	}
+
+	return res
+}
+
+func (m *BinVectorSlice) AsImmutable(v *BinVectorSlice) *BinVectorSlice {
+	if m == nil {
+		return nil
+	}
+
+	if v == m {
+		return m
+	}
+
+	m.mutable = false
+	return m
+}
+
+// Range returns a Go slice containing the elements of m. Because the slice
+// is built on each call it is O(n); use RangeFunc to avoid the allocation.
+func (m *BinVectorSlice) Range() []int {
+	if m == nil {
+		return nil
+	}
+
+	return m.theSlice.ToSlice()
+}
+
+// RangeFunc calls f for each index and element in m in order, stopping if f
+// returns false.
+func (m *BinVectorSlice) RangeFunc(f func(i int, v int) bool) {
+	if m == nil {
+		return
+	}
+
+	m.theSlice.Range(f)
+}
+
+func (m *BinVectorSlice) WithMutable(f func(mi *BinVectorSlice)) *BinVectorSlice {
+	res := m.AsMutable()
+	f(res)
+	res = res.AsImmutable(m)
+
+	return res
+}
+
+func (m *BinVectorSlice) WithImmutable(f func(mi *BinVectorSlice)) *BinVectorSlice {
+	prev := m.mutable
+	m.mutable = false
+	f(m)
+	m.mutable = prev
+
+	return m
+}
+
+func (m *BinVectorSlice) Set(i int, v int) *BinVectorSlice {
+	if m.mutable {
+		m.theSlice = m.theSlice.Set(i, v)
+		return m
+	}
+
+	res := m.dup()
+	res.theSlice = res.theSlice.Set(i, v)
+
+	return res
+}
+
+func (m *BinVectorSlice) Append(v ...int) *BinVectorSlice {
+	if m.mutable {
+		m.theSlice = m.theSlice.Append(v...)
+		return m
+	}
+
+	res := m.dup()
+	res.theSlice = res.theSlice.Append(v...)
+
+	return res
+}
+
+func (m *BinVectorSlice) Slice(i, j int) *BinVectorSlice {
+	if m.mutable {
+		m.theSlice = m.theSlice.Slice(i, j)
+		return m
+	}
+
+	res := m.dup()
+	res.theSlice = res.theSlice.Slice(i, j)
+
+	return res
+}
+
+func (m *BinVectorSlice) Concat(o *BinVectorSlice) *BinVectorSlice {
+	var os *vector.Vector[int]
+	if o != nil {
+		os = o.theSlice
+	}
+
+	if m.mutable {
+		m.theSlice = m.theSlice.Concat(os)
+		return m
+	}
+
+	res := m.dup()
+	res.theSlice = res.theSlice.Concat(os)
+
+	return res
+}
+func (s *BinVectorSlice) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
+	if s == nil {
+		return true
+	}
+
+	if s.Mutable() {
+		return false
+	}
+	return true
+}
+
+// BinVectorSliceDiff is the change set between two BinVectorSlice values, as returned by
+// BinVectorSlice.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set.
+type BinVectorSliceDiff struct {
+	Replaced bool
+	Value    *BinVectorSlice
+
+	// Len is the length of the new value; elements beyond Len are truncated
+	// and elements are appended to reach Len as required
+	Len int
+
+	// Set holds the elements, by index, that were added or changed
+	Set map[int]int
+}
+
+// Diff returns the change set required to turn m into other, or nil if there
+// is no difference. Elements are compared by index.
+func (m *BinVectorSlice) Diff(other *BinVectorSlice) *BinVectorSliceDiff {
+	if m == other {
+		return nil
+	}
+
+	if m == nil || other == nil {
+		return &BinVectorSliceDiff{Replaced: true, Value: other}
+	}
+
+	res := &BinVectorSliceDiff{
+		Len: other.Len(),
+		Set: make(map[int]int),
+	}
+
+	for i := 0; i < other.Len(); i++ {
+		ov := other.Get(i)
+		if i >= m.Len() {
+			res.Set[i] = ov
+			continue
+		}
+
+		v := m.Get(i)
+
+		if v != ov {
+			res.Set[i] = ov
+		}
+	}
+
+	if m.Len() == other.Len() && len(res.Set) == 0 {
+		return nil
+	}
+
+	return res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to m.
+func (m *BinVectorSlice) Patch(d *BinVectorSliceDiff) *BinVectorSlice {
+	if d == nil {
+		return m
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if m == nil {
+		m = new(BinVectorSlice)
+	}
+
+	return m.WithMutable(func(mi *BinVectorSlice) {
+		if l := mi.Len(); l > d.Len {
+			mi.Slice(0, d.Len)
+		} else if l < d.Len {
+			mi.Append(make([]int, d.Len-l)...)
+		}
+
+		for i, v := range d.Set {
+			mi.Set(i, v)
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. m is marshalled as a JSON array.
+func (m *BinVectorSlice) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+
+	if m.Len() == 0 {
+		return []byte("[]"), nil
+	}
+
+	return json.Marshal(m.Range())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. m is set to an immutable slice
+// containing the unmarshalled elements. Because *m is replaced, m should be a
+// new value rather than one shared with other code.
+func (m *BinVectorSlice) UnmarshalJSON(b []byte) error {
+	var v []int
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*m = *NewBinVectorSlice(v...)
+
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler. The elements of m are
+// encoded in order, each as a message. See myitcv.io/immutable/wire for
+// details of the format.
+func (m *BinVectorSlice) MarshalBinary() ([]byte, error) {
+	var e wire.Encoder
+
+	if m == nil {
+		return e.Result()
+	}
+
+	for _, v := range m.Range() {
+		e.Message(1, func(e *wire.Encoder) {
+			e.Int(1, int64(v))
+		})
+	}
+
+	return e.Result()
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. m is set to an
+// immutable slice containing the decoded elements.
+func (m *BinVectorSlice) UnmarshalBinary(b []byte) error {
+	var vs []int
+
+	d := wire.NewDecoder(b)
+
+	for d.Next() {
+		if d.Tag() != 1 {
+			continue
+		}
+
+		var v int
+
+		ed := d.Message()
+		for ed.Next() {
+			if ed.Tag() == 1 {
+				v = int(ed.Int())
+			}
+		}
+
+		vs = append(vs, v)
+	}
+
+	if err := d.Err(); err != nil {
+		return err
+	}
+
+	*m = *NewBinVectorSlice(vs...)
+
+	return nil
+}
+
+// BinStructs is an immutable type and has the following template:
+//
+//	[]*BinStruct
+type BinStructs struct {
+	theSlice []*BinStruct
+	mutable  bool
+	__tmpl   *_Imm_BinStructs
+}
+
+var _ immutable.Immutable = new(BinStructs)
+var _ = new(BinStructs).__tmpl
+
+func NewBinStructs(s ...*BinStruct) *BinStructs {
+	c := make([]*BinStruct, len(s))
+	copy(c, s)
+
+	return &BinStructs{
+		theSlice: c,
+	}
+}
+
+func NewBinStructsLen(l int) *BinStructs {
+	c := make([]*BinStruct, l)
+
+	return &BinStructs{
+		theSlice: c,
+	}
+}
+
+func (m *BinStructs) Mutable() bool {
+	return m.mutable
+}
+
+func (m *BinStructs) Len() int {
+	if m == nil {
+		return 0
+	}
+
+	return len(m.theSlice)
+}
+
+func (m *BinStructs) Get(i int) *BinStruct {
+	return m.theSlice[i]
+}
+
+func (m *BinStructs) AsMutable() *BinStructs {
+	if m == nil {
+		return nil
+	}
+
+	if m.Mutable() {
+		return m
+	}
+
+	res := m.dup()
+	res.mutable = true
+
+	return res
+}
+
+func (m *BinStructs) dup() *BinStructs {
+	resSlice := make([]*BinStruct, len(m.theSlice))
+
+	for i := range m.theSlice {
+		resSlice[i] = m.theSlice[i]
+	}
+
+	res := &BinStructs{
+		theSlice: resSlice,
+	}
+
+	return res
+}
+
+func (m *BinStructs) AsImmutable(v *BinStructs) *BinStructs {
+	if m == nil {
+		return nil
+	}
+
+	if v == m {
+		return m
+	}
+
+	m.mutable = false
+	return m
+}
+
+func (m *BinStructs) Range() []*BinStruct {
+	if m == nil {
+		return nil
+	}
+
+	return m.theSlice
+}
+
+func (m *BinStructs) WithMutable(f func(mi *BinStructs)) *BinStructs {
+	res := m.AsMutable()
+	f(res)
+	res = res.AsImmutable(m)
+
+	return res
+}
+
+func (m *BinStructs) WithImmutable(f func(mi *BinStructs)) *BinStructs {
+	prev := m.mutable
+	m.mutable = false
+	f(m)
+	m.mutable = prev
+
+	return m
+}
+
+func (m *BinStructs) Set(i int, v *BinStruct) *BinStructs {
+	if m.mutable {
+		m.theSlice[i] = v
+		return m
+	}
+
+	res := m.dup()
+	res.theSlice[i] = v
+
+	return res
+}
+
+func (m *BinStructs) Append(v ...*BinStruct) *BinStructs {
+	if m.mutable {
+		m.theSlice = append(m.theSlice, v...)
+		return m
+	}
+
+	res := m.dup()
+	res.theSlice = append(res.theSlice, v...)
+
+	return res
+}
+
+func (m *BinStructs) Slice(i, j int) *BinStructs {
+	if m.mutable {
+		m.theSlice = m.theSlice[i:j]
+		return m
+	}
+
+	resSlice := make([]*BinStruct, j-i)
+	copy(resSlice, m.theSlice[i:j])
+
+	res := &BinStructs{
+		theSlice: resSlice,
+	}
+
+	return res
+}
+
+func (m *BinStructs) Concat(o *BinStructs) *BinStructs {
+	return m.Append(o.Range()...)
+}
+func (s *BinStructs) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
+	if s == nil {
+		return true
+	}
+
+	if s.Mutable() {
+		return false
+	}
+	if s.Len() == 0 {
+		return true
+	}
+
+	if seen == nil {
+		return s.IsDeeplyNonMutable(make(map[interface{}]bool))
+	}
+
+	if seen[s] {
+		return true
+	}
+
+	seen[s] = true
+
+	for _, v := range s.theSlice {
+		if v != nil && !v.IsDeeplyNonMutable(seen) {
+			return false
+		}
+	}
+	return true
+}
+
+// BinStructsDiff is the change set between two BinStructs values, as returned by
+// BinStructs.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set.
+type BinStructsDiff struct {
+	Replaced bool
+	Value    *BinStructs
+
+	// Len is the length of the new value; elements beyond Len are truncated
+	// and elements are appended to reach Len as required
+	Len int
+
+	// Set holds the elements, by index, that were added
+	Set map[int]*BinStruct
+
+	// Changed holds the change sets, by index, of the elements that were
+	// changed
+	Changed map[int]*BinStructDiff
+}
+
+// Diff returns the change set required to turn m into other, or nil if there
+// is no difference. Elements are compared by index.
+func (m *BinStructs) Diff(other *BinStructs) *BinStructsDiff {
+	if m == other {
+		return nil
+	}
+
+	if m == nil || other == nil {
+		return &BinStructsDiff{Replaced: true, Value: other}
+	}
+
+	res := &BinStructsDiff{
+		Len:     other.Len(),
+		Set:     make(map[int]*BinStruct),
+		Changed: make(map[int]*BinStructDiff),
+	}
+
+	for i := 0; i < other.Len(); i++ {
+		ov := other.Get(i)
+		if i >= m.Len() {
+			res.Set[i] = ov
+			continue
+		}
+
+		v := m.Get(i)
+
+		if d := v.Diff(ov); d != nil {
+			res.Changed[i] = d
+		}
+	}
+
+	if m.Len() == other.Len() && len(res.Set) == 0 && len(res.Changed) == 0 {
+		return nil
+	}
+
+	return res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to m.
+func (m *BinStructs) Patch(d *BinStructsDiff) *BinStructs {
+	if d == nil {
+		return m
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if m == nil {
+		m = new(BinStructs)
+	}
+
+	return m.WithMutable(func(mi *BinStructs) {
+		if l := mi.Len(); l > d.Len {
+			mi.Slice(0, d.Len)
+		} else if l < d.Len {
+			mi.Append(make([]*BinStruct, d.Len-l)...)
+		}
+
+		for i, v := range d.Set {
+			mi.Set(i, v)
+		}
+
+		for i, vd := range d.Changed {
+			mi.Set(i, mi.Get(i).Patch(vd))
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. m is marshalled as a JSON array.
+func (m *BinStructs) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+
+	if m.Len() == 0 {
+		return []byte("[]"), nil
+	}
+
+	return json.Marshal(m.Range())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. m is set to an immutable slice
+// containing the unmarshalled elements. Because *m is replaced, m should be a
+// new value rather than one shared with other code.
+func (m *BinStructs) UnmarshalJSON(b []byte) error {
+	var v []*BinStruct
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*m = *NewBinStructs(v...)
+
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler. The elements of m are
+// encoded in order, each as a message. See myitcv.io/immutable/wire for
+// details of the format.
+func (m *BinStructs) MarshalBinary() ([]byte, error) {
+	var e wire.Encoder
+
+	if m == nil {
+		return e.Result()
+	}
+
+	for _, v := range m.Range() {
+		e.Message(1, func(e *wire.Encoder) {
+			if v != nil {
+				e.Marshaler(1, v)
+			}
+		})
+	}
+
+	return e.Result()
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. m is set to an
+// immutable slice containing the decoded elements.
+func (m *BinStructs) UnmarshalBinary(b []byte) error {
+	var vs []*BinStruct
+
+	d := wire.NewDecoder(b)
+
+	for d.Next() {
+		if d.Tag() != 1 {
+			continue
+		}
+
+		var v *BinStruct
+
+		ed := d.Message()
+		for ed.Next() {
+			if ed.Tag() == 1 {
+				v = new(BinStruct)
+				ed.Unmarshaler(v)
+			}
+		}
+
+		vs = append(vs, v)
+	}
+
+	if err := d.Err(); err != nil {
+		return err
+	}
+
+	*m = *NewBinStructs(vs...)
+
+	return nil
+}
+
+// a comment about myStruct
+//
+// MyStruct is an immutable type and has the following template:
+//
+//	struct {
+//		Key	MyStructKey
+//
+//		Name, surname	string
+//		age		int
+//
+//		string
+//
+//		fieldWithoutTag	bool
+//	}
+type MyStruct struct {
+	field_Key             MyStructKey
+	field_Name            string `tag:"value"`
+	field_surname         string `tag:"value"`
+	field_age             int    `tag:"age"`
+	anonfield_string      string
+	field_fieldWithoutTag bool
+
+	mutable bool
+	__tmpl  *_Imm_MyStruct
+}
+
+var _ immutable.Immutable = new(MyStruct)
+var _ = new(MyStruct).__tmpl
+
+func (s *MyStruct) AsMutable() *MyStruct {
+	if s.Mutable() {
+		return s
+	}
+
+	res := *s
+	res.field_Key.Version++
+	res.mutable = true
+	return &res
+}
+
+func (s *MyStruct) AsImmutable(v *MyStruct) *MyStruct {
+	if s == nil {
+		return nil
+	}
+
+	if s == v {
+		return s
+	}
+
+	s.mutable = false
+	return s
+}
+
+func (s *MyStruct) Mutable() bool {
+	return s.mutable
+}
+
+func (s *MyStruct) WithMutable(f func(si *MyStruct)) *MyStruct {
+	res := s.AsMutable()
+	f(res)
+	res = res.AsImmutable(s)
+
+	return res
+}
+
+func (s *MyStruct) WithImmutable(f func(si *MyStruct)) *MyStruct {
+	prev := s.mutable
+	s.mutable = false
+	f(s)
+	s.mutable = prev
+
+	return s
+}
+
+func (s *MyStruct) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
+	if s == nil {
+		return true
+	}
+
+	if s.Mutable() {
+		return false
+	}
+
+	if seen == nil {
+		return s.IsDeeplyNonMutable(make(map[interface{}]bool))
+	}
+
+	if seen[s] {
+		return true
+	}
+
+	seen[s] = true
+	return true
+}
+
+// MyStructDiff is the change set between two MyStruct values, as returned by
+// MyStruct.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set. Otherwise a non-nil
+// field holds either the new value of the corresponding field or, where the
+// field is itself of an immutable type, the change set for that field.
+// The Key of a MyStruct is not part of the change set: its Uuid identifies
+// the entity and its Version is maintained by MyStruct itself.
+type MyStructDiff struct {
+	Replaced bool
+	Value    *MyStruct
+
+	Name            *string
+	surname         *string
+	age             *int
+	string          *string
+	fieldWithoutTag *bool
+}
+
+// Diff returns the change set required to turn s into other, or nil if there
+// is no difference. Values with different Key Uuids are different entities, and
+// other replaces s wholesale. Values with the same Key Uuid and Version are
+// the same version of the same entity, and have no difference.
+func (s *MyStruct) Diff(other *MyStruct) *MyStructDiff {
+	if s == other {
+		return nil
+	}
+
+	if s == nil || other == nil {
+		return &MyStructDiff{Replaced: true, Value: other}
+	}
+
+	if s.field_Key.Uuid != other.field_Key.Uuid {
+		return &MyStructDiff{Replaced: true, Value: other}
+	}
+
+	if s.field_Key.Version == other.field_Key.Version {
+		return nil
+	}
+
+	var res MyStructDiff
+	changed := false
+
+	if s.field_Name != other.field_Name {
+		v := other.field_Name
+		res.Name = &v
+		changed = true
+	}
+
+	if s.field_surname != other.field_surname {
+		v := other.field_surname
+		res.surname = &v
+		changed = true
+	}
+
+	if s.field_age != other.field_age {
+		v := other.field_age
+		res.age = &v
+		changed = true
+	}
+
+	if s.anonfield_string != other.anonfield_string {
+		v := other.anonfield_string
+		res.string = &v
+		changed = true
+	}
+
+	if s.field_fieldWithoutTag != other.field_fieldWithoutTag {
+		v := other.field_fieldWithoutTag
+		res.fieldWithoutTag = &v
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return &res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to s. As with any other change to s, the Version of the Key of the
+// result is bumped.
+func (s *MyStruct) Patch(d *MyStructDiff) *MyStruct {
+	if d == nil {
+		return s
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if s == nil {
+		s = new(MyStruct)
+	}
+
+	return s.WithMutable(func(si *MyStruct) {
+		if d.Name != nil {
+			si.field_Name = *d.Name
+		}
+		if d.surname != nil {
+			si.field_surname = *d.surname
+		}
+		if d.age != nil {
+			si.field_age = *d.age
+		}
+		if d.string != nil {
+			si.anonfield_string = *d.string
+		}
+		if d.fieldWithoutTag != nil {
+			si.field_fieldWithoutTag = *d.fieldWithoutTag
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. The exported fields of the template
+// for MyStruct are marshalled according to their names and tags.
+func (s *MyStruct) MarshalJSON() ([]byte, error) {
+	if s == nil {
+		return []byte("null"), nil
+	}
+
+	v := struct {
+		Key  MyStructKey
+		Name string `tag:"value"`
+	}{
+		Key:  s.field_Key,
+		Name: s.field_Name,
+	}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The exported fields of the
+// template for MyStruct are unmarshalled according to their names and tags;
+// other fields, and those absent from the JSON, are left unchanged. The result
+// is built via WithMutable, so its Key has a new Version unless
+// the JSON sets the Key. Because json.Unmarshaler stores the result in
+// *s, s should be a new value rather than one shared with other code.
+func (s *MyStruct) UnmarshalJSON(b []byte) error {
+	v := struct {
+		Key  MyStructKey
+		Name string `tag:"value"`
+	}{
+		Key:  s.field_Key,
+		Name: s.field_Name,
+	}
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*s = *s.WithMutable(func(si *MyStruct) {
+		if v.Key != s.field_Key {
+			si.field_Key = v.Key
+		}
+		si.field_Name = v.Name
+	})
+
+	return nil
+}
+
+func (s *MyStruct) Key() MyStructKey {
+	return s.field_Key
+}
+
+// SetKey is the setter for Key()
+func (s *MyStruct) SetKey(n MyStructKey) *MyStruct {
+	if s.mutable {
+		s.field_Key = n
+		return s
+	}
+
+	res := *s
+	res.field_Key.Version++
+	res.field_Key = n
+	return &res
+}
+
+// my field comment
+//somethingspecial
+/*
+
+	Heelo
+
+*/
+func (s *MyStruct) Name() string {
+	return s.field_Name
+}
+
+// SetName is the setter for Name()
+func (s *MyStruct) SetName(n string) *MyStruct {
+	if s.mutable {
+		s.field_Name = n
+		return s
+	}
+
+	res := *s
+	res.field_Key.Version++
+	res.field_Name = n
+	return &res
+}
+
+func (s *MyStruct) age() int {
+	return s.field_age
+}
+
+// setAge is the setter for Age()
+func (s *MyStruct) setAge(n int) *MyStruct {
+	if s.mutable {
+		s.field_age = n
+		return s
+	}
+
+	res := *s
+	res.field_Key.Version++
+	res.field_age = n
+	return &res
+}
+
+func (s *MyStruct) fieldWithoutTag() bool {
+	return s.field_fieldWithoutTag
+}
+
+// setFieldWithoutTag is the setter for FieldWithoutTag()
+func (s *MyStruct) setFieldWithoutTag(n bool) *MyStruct {
+	if s.mutable {
+		s.field_fieldWithoutTag = n
+		return s
+	}
+
+	res := *s
+	res.field_Key.Version++
+	res.field_fieldWithoutTag = n
+	return &res
+}
+
+func (s *MyStruct) string() string {
+	return s.anonfield_string
+}
+
+// setString is the setter for String()
+func (s *MyStruct) setString(n string) *MyStruct {
+	if s.mutable {
+		s.anonfield_string = n
+		return s
+	}
+
+	res := *s
+	res.field_Key.Version++
+	res.anonfield_string = n
+	return &res
+}
+
+// my field comment
+//somethingspecial
+/*
+
+	Heelo
+
+*/
+func (s *MyStruct) surname() string {
+	return s.field_surname
+}
+
+// setSurname is the setter for Surname()
+func (s *MyStruct) setSurname(n string) *MyStruct {
+	if s.mutable {
+		s.field_surname = n
+		return s
+	}
+
+	res := *s
+	res.field_Key.Version++
+	res.field_surname = n
+	return &res
+}
+
+// MySpecialStruct is an immutable type and has the following template:
+//
+//	struct {
+//		Key	MySpecialStructKey
+//
+//		Name	string
+//	}
+type MySpecialStruct struct {
+	field_Key  MySpecialStructKey
+	field_Name string
+
+	mutable bool
+	__tmpl  *_Imm_MySpecialStruct
+}
+
+var _ immutable.Immutable = new(MySpecialStruct)
+var _ = new(MySpecialStruct).__tmpl
+
+func (s *MySpecialStruct) AsMutable() *MySpecialStruct {
+	if s.Mutable() {
+		return s
+	}
+
+	res := *s
+	res.field_Key.BumpVersion()
+	res.mutable = true
+	return &res
+}
+
+func (s *MySpecialStruct) AsImmutable(v *MySpecialStruct) *MySpecialStruct {
+	if s == nil {
+		return nil
+	}
+
+	if s == v {
+		return s
+	}
+
+	s.mutable = false
+	return s
+}
+
+func (s *MySpecialStruct) Mutable() bool {
+	return s.mutable
+}
+
+func (s *MySpecialStruct) WithMutable(f func(si *MySpecialStruct)) *MySpecialStruct {
+	res := s.AsMutable()
+	f(res)
+	res = res.AsImmutable(s)
+
+	return res
+}
+
+func (s *MySpecialStruct) WithImmutable(f func(si *MySpecialStruct)) *MySpecialStruct {
+	prev := s.mutable
+	s.mutable = false
+	f(s)
+	s.mutable = prev
+
+	return s
+}
+
+func (s *MySpecialStruct) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
+	if s == nil {
+		return true
+	}
+
+	if s.Mutable() {
+		return false
+	}
+
+	if seen == nil {
+		return s.IsDeeplyNonMutable(make(map[interface{}]bool))
+	}
+
+	if seen[s] {
+		return true
+	}
+
+	seen[s] = true
+	return true
+}
+
+// MySpecialStructDiff is the change set between two MySpecialStruct values, as returned by
+// MySpecialStruct.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set. Otherwise a non-nil
+// field holds either the new value of the corresponding field or, where the
+// field is itself of an immutable type, the change set for that field.
+// The Key of a MySpecialStruct is not part of the change set: its Uuid identifies
+// the entity and its Version is maintained by MySpecialStruct itself.
+type MySpecialStructDiff struct {
+	Replaced bool
+	Value    *MySpecialStruct
+
+	Name *string
+}
+
+// Diff returns the change set required to turn s into other, or nil if there
+// is no difference. Values with different Key Uuids are different entities, and
+// other replaces s wholesale. Values with the same Key Uuid and Version are
+// the same version of the same entity, and have no difference.
+func (s *MySpecialStruct) Diff(other *MySpecialStruct) *MySpecialStructDiff {
+	if s == other {
+		return nil
+	}
+
+	if s == nil || other == nil {
+		return &MySpecialStructDiff{Replaced: true, Value: other}
+	}
+
+	if s.field_Key.Uuid != other.field_Key.Uuid {
+		return &MySpecialStructDiff{Replaced: true, Value: other}
+	}
+
+	if s.field_Key.Version == other.field_Key.Version {
+		return nil
+	}
+
+	var res MySpecialStructDiff
+	changed := false
+
+	if s.field_Name != other.field_Name {
+		v := other.field_Name
+		res.Name = &v
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return &res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to s. As with any other change to s, the Version of the Key of the
+// result is bumped.
+func (s *MySpecialStruct) Patch(d *MySpecialStructDiff) *MySpecialStruct {
+	if d == nil {
+		return s
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if s == nil {
+		s = new(MySpecialStruct)
+	}
+
+	return s.WithMutable(func(si *MySpecialStruct) {
+		if d.Name != nil {
+			si.field_Name = *d.Name
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. The exported fields of the template
+// for MySpecialStruct are marshalled according to their names and tags.
+func (s *MySpecialStruct) MarshalJSON() ([]byte, error) {
+	if s == nil {
+		return []byte("null"), nil
+	}
+
+	v := struct {
+		Key  MySpecialStructKey
+		Name string
+	}{
+		Key:  s.field_Key,
+		Name: s.field_Name,
+	}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The exported fields of the
+// template for MySpecialStruct are unmarshalled according to their names and tags;
+// other fields, and those absent from the JSON, are left unchanged. The result
+// is built via WithMutable, so its Key has a new Version unless
+// the JSON sets the Key. Because json.Unmarshaler stores the result in
+// *s, s should be a new value rather than one shared with other code.
+func (s *MySpecialStruct) UnmarshalJSON(b []byte) error {
+	v := struct {
+		Key  MySpecialStructKey
+		Name string
+	}{
+		Key:  s.field_Key,
+		Name: s.field_Name,
+	}
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*s = *s.WithMutable(func(si *MySpecialStruct) {
+		if v.Key != s.field_Key {
+			si.field_Key = v.Key
+		}
+		si.field_Name = v.Name
+	})
+
+	return nil
+}
+
+func (s *MySpecialStruct) Key() MySpecialStructKey {
+	return s.field_Key
+}
+
+// SetKey is the setter for Key()
+func (s *MySpecialStruct) SetKey(n MySpecialStructKey) *MySpecialStruct {
+	if s.mutable {
+		s.field_Key = n
+		return s
+	}
+
+	res := *s
+	res.field_Key.BumpVersion()
+	res.field_Key = n
+	return &res
+}
+
+func (s *MySpecialStruct) Name() string {
+	return s.field_Name
+}
+
+// SetName is the setter for Name()
+func (s *MySpecialStruct) SetName(n string) *MySpecialStruct {
+	if s.mutable {
+		s.field_Name = n
+		return s
+	}
+
+	res := *s
+	res.field_Key.BumpVersion()
+	res.field_Name = n
+	return &res
+}
+
+// A is an immutable type and has the following template:
+//
+//	struct {
+//		Name	string
+//		A	*A
+//
+//		Blah
+//	}
+type A struct {
+	field_Name     string
+	field_A        *A
+	anonfield_Blah Blah
+
+	mutable bool
+	__tmpl  *_Imm_A
+}
+
+var _ immutable.Immutable = new(A)
+var _ = new(A).__tmpl
+
+func (s *A) AsMutable() *A {
+	if s.Mutable() {
+		return s
+	}
+
+	res := *s
+	res.mutable = true
+	return &res
+}
+
+func (s *A) AsImmutable(v *A) *A {
+	if s == nil {
+		return nil
+	}
+
+	if s == v {
+		return s
+	}
+
+	s.mutable = false
+	return s
+}
+
+func (s *A) Mutable() bool {
+	return s.mutable
+}
+
+func (s *A) WithMutable(f func(si *A)) *A {
+	res := s.AsMutable()
+	f(res)
+	res = res.AsImmutable(s)
+
+	return res
+}
+
+func (s *A) WithImmutable(f func(si *A)) *A {
+	prev := s.mutable
+	s.mutable = false
+	f(s)
+	s.mutable = prev
+
+	return s
+}
+
+func (s *A) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
+	if s == nil {
+		return true
+	}
+
+	if s.Mutable() {
+		return false
+	}
+
+	if seen == nil {
+		return s.IsDeeplyNonMutable(make(map[interface{}]bool))
+	}
+
+	if seen[s] {
+		return true
+	}
+
+	seen[s] = true
+	{
+		v := s.field_A
+
+		if v != nil && !v.IsDeeplyNonMutable(seen) {
+			return false
+		}
+	}
+	{
+		v := s.anonfield_Blah
+
+		if v != nil && !v.IsDeeplyNonMutable(seen) {
+			return false
+		}
+	}
+	return true
+}
+
+// ADiff is the change set between two A values, as returned by
+// A.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set. Otherwise a non-nil
+// field holds either the new value of the corresponding field or, where the
+// field is itself of an immutable type, the change set for that field.
+type ADiff struct {
+	Replaced bool
+	Value    *A
+
+	Name *string
+	A    *ADiff
+	Blah *Blah
+}
+
+// Diff returns the change set required to turn s into other, or nil if there
+// is no difference.
+func (s *A) Diff(other *A) *ADiff {
+	if s == other {
+		return nil
+	}
+
+	if s == nil || other == nil {
+		return &ADiff{Replaced: true, Value: other}
+	}
+
+	var res ADiff
+	changed := false
+
+	if s.field_Name != other.field_Name {
+		v := other.field_Name
+		res.Name = &v
+		changed = true
+	}
+
+	if d := s.field_A.Diff(other.field_A); d != nil {
+		res.A = d
+		changed = true
+	}
+
+	if s.anonfield_Blah != other.anonfield_Blah {
+		v := other.anonfield_Blah
+		res.Blah = &v
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return &res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to s.
+func (s *A) Patch(d *ADiff) *A {
+	if d == nil {
+		return s
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if s == nil {
+		s = new(A)
+	}
+
+	return s.WithMutable(func(si *A) {
+		if d.Name != nil {
+			si.field_Name = *d.Name
+		}
+		if d.A != nil {
+			si.field_A = si.field_A.Patch(d.A)
+		}
+		if d.Blah != nil {
+			si.anonfield_Blah = *d.Blah
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. The exported fields of the template
+// for A are marshalled according to their names and tags.
+func (s *A) MarshalJSON() ([]byte, error) {
+	if s == nil {
+		return []byte("null"), nil
+	}
+
+	v := struct {
+		Name string
+		A    *A
+		Blah Blah
+	}{
+		Name: s.field_Name,
+		A:    s.field_A,
+		Blah: s.anonfield_Blah,
+	}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The exported fields of the
+// template for A are unmarshalled according to their names and tags;
+// other fields, and those absent from the JSON, are left unchanged. The result
+// is built via WithMutable. Because json.Unmarshaler stores the result in
+// *s, s should be a new value rather than one shared with other code.
+func (s *A) UnmarshalJSON(b []byte) error {
+	v := struct {
+		Name string
+		A    *A
+		Blah Blah
+	}{
+		Name: s.field_Name,
+		A:    s.field_A,
+		Blah: s.anonfield_Blah,
+	}
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*s = *s.WithMutable(func(si *A) {
+		si.field_Name = v.Name
+		si.field_A = v.A
+		si.anonfield_Blah = v.Blah
+	})
+
+	return nil
+}
+
+func (s *A) A() *A {
+	return s.field_A
+}
+
+// SetA is the setter for A()
+func (s *A) SetA(n *A) *A {
+	if s.mutable {
+		s.field_A = n
+		return s
+	}
+
+	res := *s
+	res.field_A = n
+	return &res
+}
+
+func (s *A) Blah() Blah {
+	return s.anonfield_Blah
+}
+
+// SetBlah is the setter for Blah()
+func (s *A) SetBlah(n Blah) *A {
+	if s.mutable {
+		s.anonfield_Blah = n
+		return s
+	}
+
+	res := *s
+	res.anonfield_Blah = n
+	return &res
+}
+
+func (s *A) Name() string {
+	return s.field_Name
+}
+
+// SetName is the setter for Name()
+func (s *A) SetName(n string) *A {
+	if s.mutable {
+		s.field_Name = n
+		return s
+	}
+
+	res := *s
+	res.field_Name = n
+	return &res
+}
+
+// BlahUse is an immutable type and has the following template:
+//
+//	struct {
+//		Blah
+//	}
+type BlahUse struct {
+	anonfield_Blah Blah
+
+	mutable bool
+	__tmpl  *_Imm_BlahUse
+}
+
+var _ immutable.Immutable = new(BlahUse)
+var _ = new(BlahUse).__tmpl
+
+func (s *BlahUse) AsMutable() *BlahUse {
+	if s.Mutable() {
+		return s
+	}
+
+	res := *s
+	res.mutable = true
+	return &res
+}
+
+func (s *BlahUse) AsImmutable(v *BlahUse) *BlahUse {
+	if s == nil {
+		return nil
+	}
+
+	if s == v {
+		return s
+	}
+
+	s.mutable = false
+	return s
+}
+
+func (s *BlahUse) Mutable() bool {
+	return s.mutable
+}
+
+func (s *BlahUse) WithMutable(f func(si *BlahUse)) *BlahUse {
+	res := s.AsMutable()
+	f(res)
+	res = res.AsImmutable(s)
+
+	return res
+}
+
+func (s *BlahUse) WithImmutable(f func(si *BlahUse)) *BlahUse {
+	prev := s.mutable
+	s.mutable = false
+	f(s)
+	s.mutable = prev
+
+	return s
+}
+
+func (s *BlahUse) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
+	if s == nil {
+		return true
+	}
+
+	if s.Mutable() {
+		return false
+	}
 
 	if seen == nil {
 		return s.IsDeeplyNonMutable(make(map[interface{}]bool))
@@ -655,8 +8027,9 @@ func (s *AS) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	}
 
 	seen[s] = true
+	{
+		v := s.anonfield_Blah
 
-	for _, v := range s.theSlice {
 		if v != nil && !v.IsDeeplyNonMutable(seen) {
 			return false
 		}
@@ -664,48 +8037,391 @@ func (s *AS) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	return true
 }
 
-// a comment about myStruct
-//
-// MyStruct is an immutable type and has the following template:
-//
-// 	struct {
-// 		Key	MyStructKey
-//
-// 		Name, surname	string
-// 		age		int
+// BlahUseDiff is the change set between two BlahUse values, as returned by
+// BlahUse.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set. Otherwise a non-nil
+// field holds either the new value of the corresponding field or, where the
+// field is itself of an immutable type, the change set for that field.
+type BlahUseDiff struct {
+	Replaced bool
+	Value    *BlahUse
+
+	Blah *Blah
+}
+
+// Diff returns the change set required to turn s into other, or nil if there
+// is no difference.
+func (s *BlahUse) Diff(other *BlahUse) *BlahUseDiff {
+	if s == other {
+		return nil
+	}
+
+	if s == nil || other == nil {
+		return &BlahUseDiff{Replaced: true, Value: other}
+	}
+
+	var res BlahUseDiff
+	changed := false
+
+	if s.anonfield_Blah != other.anonfield_Blah {
+		v := other.anonfield_Blah
+		res.Blah = &v
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return &res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to s.
+func (s *BlahUse) Patch(d *BlahUseDiff) *BlahUse {
+	if d == nil {
+		return s
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if s == nil {
+		s = new(BlahUse)
+	}
+
+	return s.WithMutable(func(si *BlahUse) {
+		if d.Blah != nil {
+			si.anonfield_Blah = *d.Blah
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. The exported fields of the template
+// for BlahUse are marshalled according to their names and tags.
+func (s *BlahUse) MarshalJSON() ([]byte, error) {
+	if s == nil {
+		return []byte("null"), nil
+	}
+
+	v := struct {
+		Blah Blah
+	}{
+		Blah: s.anonfield_Blah,
+	}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The exported fields of the
+// template for BlahUse are unmarshalled according to their names and tags;
+// other fields, and those absent from the JSON, are left unchanged. The result
+// is built via WithMutable. Because json.Unmarshaler stores the result in
+// *s, s should be a new value rather than one shared with other code.
+func (s *BlahUse) UnmarshalJSON(b []byte) error {
+	v := struct {
+		Blah Blah
+	}{
+		Blah: s.anonfield_Blah,
+	}
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*s = *s.WithMutable(func(si *BlahUse) {
+		si.anonfield_Blah = v.Blah
+	})
+
+	return nil
+}
+
+func (s *BlahUse) Blah() Blah {
+	return s.anonfield_Blah
+}
+
+// SetBlah is the setter for Blah()
+func (s *BlahUse) SetBlah(n Blah) *BlahUse {
+	if s.mutable {
+		s.anonfield_Blah = n
+		return s
+	}
+
+	res := *s
+	res.anonfield_Blah = n
+	return &res
+}
+
+// Clash1 is an immutable type and has the following template:
 //
-// 		string
+//	struct {
+//		Clash		string
+//		NoClash1	string
+//	}
+type Clash1 struct {
+	field_Clash    string
+	field_NoClash1 string
+
+	mutable bool
+	__tmpl  *_Imm_Clash1
+}
+
+var _ immutable.Immutable = new(Clash1)
+var _ = new(Clash1).__tmpl
+
+func (s *Clash1) AsMutable() *Clash1 {
+	if s.Mutable() {
+		return s
+	}
+
+	res := *s
+	res.mutable = true
+	return &res
+}
+
+func (s *Clash1) AsImmutable(v *Clash1) *Clash1 {
+	if s == nil {
+		return nil
+	}
+
+	if s == v {
+		return s
+	}
+
+	s.mutable = false
+	return s
+}
+
+func (s *Clash1) Mutable() bool {
+	return s.mutable
+}
+
+func (s *Clash1) WithMutable(f func(si *Clash1)) *Clash1 {
+	res := s.AsMutable()
+	f(res)
+	res = res.AsImmutable(s)
+
+	return res
+}
+
+func (s *Clash1) WithImmutable(f func(si *Clash1)) *Clash1 {
+	prev := s.mutable
+	s.mutable = false
+	f(s)
+	s.mutable = prev
+
+	return s
+}
+
+func (s *Clash1) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
+	if s == nil {
+		return true
+	}
+
+	if s.Mutable() {
+		return false
+	}
+
+	if seen == nil {
+		return s.IsDeeplyNonMutable(make(map[interface{}]bool))
+	}
+
+	if seen[s] {
+		return true
+	}
+
+	seen[s] = true
+	return true
+}
+
+// Clash1Diff is the change set between two Clash1 values, as returned by
+// Clash1.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set. Otherwise a non-nil
+// field holds either the new value of the corresponding field or, where the
+// field is itself of an immutable type, the change set for that field.
+type Clash1Diff struct {
+	Replaced bool
+	Value    *Clash1
+
+	Clash    *string
+	NoClash1 *string
+}
+
+// Diff returns the change set required to turn s into other, or nil if there
+// is no difference.
+func (s *Clash1) Diff(other *Clash1) *Clash1Diff {
+	if s == other {
+		return nil
+	}
+
+	if s == nil || other == nil {
+		return &Clash1Diff{Replaced: true, Value: other}
+	}
+
+	var res Clash1Diff
+	changed := false
+
+	if s.field_Clash != other.field_Clash {
+		v := other.field_Clash
+		res.Clash = &v
+		changed = true
+	}
+
+	if s.field_NoClash1 != other.field_NoClash1 {
+		v := other.field_NoClash1
+		res.NoClash1 = &v
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return &res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to s.
+func (s *Clash1) Patch(d *Clash1Diff) *Clash1 {
+	if d == nil {
+		return s
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if s == nil {
+		s = new(Clash1)
+	}
+
+	return s.WithMutable(func(si *Clash1) {
+		if d.Clash != nil {
+			si.field_Clash = *d.Clash
+		}
+		if d.NoClash1 != nil {
+			si.field_NoClash1 = *d.NoClash1
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. The exported fields of the template
+// for Clash1 are marshalled according to their names and tags.
+func (s *Clash1) MarshalJSON() ([]byte, error) {
+	if s == nil {
+		return []byte("null"), nil
+	}
+
+	v := struct {
+		Clash    string
+		NoClash1 string
+	}{
+		Clash:    s.field_Clash,
+		NoClash1: s.field_NoClash1,
+	}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The exported fields of the
+// template for Clash1 are unmarshalled according to their names and tags;
+// other fields, and those absent from the JSON, are left unchanged. The result
+// is built via WithMutable. Because json.Unmarshaler stores the result in
+// *s, s should be a new value rather than one shared with other code.
+func (s *Clash1) UnmarshalJSON(b []byte) error {
+	v := struct {
+		Clash    string
+		NoClash1 string
+	}{
+		Clash:    s.field_Clash,
+		NoClash1: s.field_NoClash1,
+	}
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*s = *s.WithMutable(func(si *Clash1) {
+		si.field_Clash = v.Clash
+		si.field_NoClash1 = v.NoClash1
+	})
+
+	return nil
+}
+
+func (s *Clash1) Clash() string {
+	return s.field_Clash
+}
+
+// SetClash is the setter for Clash()
+func (s *Clash1) SetClash(n string) *Clash1 {
+	if s.mutable {
+		s.field_Clash = n
+		return s
+	}
+
+	res := *s
+	res.field_Clash = n
+	return &res
+}
+
+func (s *Clash1) NoClash1() string {
+	return s.field_NoClash1
+}
+
+// SetNoClash1 is the setter for NoClash1()
+func (s *Clash1) SetNoClash1(n string) *Clash1 {
+	if s.mutable {
+		s.field_NoClash1 = n
+		return s
+	}
+
+	res := *s
+	res.field_NoClash1 = n
+	return &res
+}
+
+// types for testing embedding
 //
-// 		fieldWithoutTag	bool
-// 	}
+// Embed1 is an immutable type and has the following template:
 //
-type MyStruct struct {
-	field_Key             MyStructKey
-	field_Name            string `tag:"value"`
-	field_surname         string `tag:"value"`
-	field_age             int    `tag:"age"`
-	anonfield_string      string
-	field_fieldWithoutTag bool
+//	struct {
+//		Name	string
+//		*Embed2
+//		*pkga.PkgA
+//		*Clash1
+//		*pkga.Clash2
+//		NonImmStruct
+//		pkga.NonImmStructA
+//	}
+type Embed1 struct {
+	field_Name              string
+	anonfield_Embed2        *Embed2
+	anonfield_PkgA          *pkga.PkgA
+	anonfield_Clash1        *Clash1
+	anonfield_Clash2        *pkga.Clash2
+	anonfield_NonImmStruct  NonImmStruct
+	anonfield_NonImmStructA pkga.NonImmStructA
 
 	mutable bool
-	__tmpl  *_Imm_MyStruct
+	__tmpl  *_Imm_Embed1
 }
 
-var _ immutable.Immutable = new(MyStruct)
-var _ = new(MyStruct).__tmpl
+var _ immutable.Immutable = new(Embed1)
+var _ = new(Embed1).__tmpl
 
-func (s *MyStruct) AsMutable() *MyStruct {
+func (s *Embed1) AsMutable() *Embed1 {
 	if s.Mutable() {
 		return s
 	}
 
 	res := *s
-	res.field_Key.Version++
 	res.mutable = true
 	return &res
 }
 
-func (s *MyStruct) AsImmutable(v *MyStruct) *MyStruct {
+func (s *Embed1) AsImmutable(v *Embed1) *Embed1 {
 	if s == nil {
 		return nil
 	}
@@ -718,11 +8434,11 @@ func (s *MyStruct) AsImmutable(v *MyStruct) *MyStruct {
 	return s
 }
 
-func (s *MyStruct) Mutable() bool {
+func (s *Embed1) Mutable() bool {
 	return s.mutable
 }
 
-func (s *MyStruct) WithMutable(f func(si *MyStruct)) *MyStruct {
+func (s *Embed1) WithMutable(f func(si *Embed1)) *Embed1 {
 	res := s.AsMutable()
 	f(res)
 	res = res.AsImmutable(s)
@@ -730,7 +8446,7 @@ func (s *MyStruct) WithMutable(f func(si *MyStruct)) *MyStruct {
 	return res
 }
 
-func (s *MyStruct) WithImmutable(f func(si *MyStruct)) *MyStruct {
+func (s *Embed1) WithImmutable(f func(si *Embed1)) *Embed1 {
 	prev := s.mutable
 	s.mutable = false
 	f(s)
@@ -739,7 +8455,7 @@ func (s *MyStruct) WithImmutable(f func(si *MyStruct)) *MyStruct {
 	return s
 }
 
-func (s *MyStruct) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
+func (s *Embed1) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	if s == nil {
 		return true
 	}
@@ -757,153 +8473,489 @@ func (s *MyStruct) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	}
 
 	seen[s] = true
+	{
+		v := s.anonfield_Embed2
+
+		if v != nil && !v.IsDeeplyNonMutable(seen) {
+			return false
+		}
+	}
+	{
+		v := s.anonfield_PkgA
+
+		if v != nil && !v.IsDeeplyNonMutable(seen) {
+			return false
+		}
+	}
+	{
+		v := s.anonfield_Clash1
+
+		if v != nil && !v.IsDeeplyNonMutable(seen) {
+			return false
+		}
+	}
+	{
+		v := s.anonfield_Clash2
+
+		if v != nil && !v.IsDeeplyNonMutable(seen) {
+			return false
+		}
+	}
 	return true
 }
-func (s *MyStruct) Key() MyStructKey {
-	return s.field_Key
+
+// Embed1Diff is the change set between two Embed1 values, as returned by
+// Embed1.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set. Otherwise a non-nil
+// field holds either the new value of the corresponding field or, where the
+// field is itself of an immutable type, the change set for that field.
+type Embed1Diff struct {
+	Replaced bool
+	Value    *Embed1
+
+	Name          *string
+	Embed2        *Embed2Diff
+	PkgA          *pkga.PkgADiff
+	Clash1        *Clash1Diff
+	Clash2        *pkga.Clash2Diff
+	NonImmStruct  *NonImmStruct
+	NonImmStructA *pkga.NonImmStructA
+}
+
+// Diff returns the change set required to turn s into other, or nil if there
+// is no difference.
+func (s *Embed1) Diff(other *Embed1) *Embed1Diff {
+	if s == other {
+		return nil
+	}
+
+	if s == nil || other == nil {
+		return &Embed1Diff{Replaced: true, Value: other}
+	}
+
+	var res Embed1Diff
+	changed := false
+
+	if s.field_Name != other.field_Name {
+		v := other.field_Name
+		res.Name = &v
+		changed = true
+	}
+
+	if d := s.anonfield_Embed2.Diff(other.anonfield_Embed2); d != nil {
+		res.Embed2 = d
+		changed = true
+	}
+
+	if d := s.anonfield_PkgA.Diff(other.anonfield_PkgA); d != nil {
+		res.PkgA = d
+		changed = true
+	}
+
+	if d := s.anonfield_Clash1.Diff(other.anonfield_Clash1); d != nil {
+		res.Clash1 = d
+		changed = true
+	}
+
+	if d := s.anonfield_Clash2.Diff(other.anonfield_Clash2); d != nil {
+		res.Clash2 = d
+		changed = true
+	}
+
+	if s.anonfield_NonImmStruct != other.anonfield_NonImmStruct {
+		v := other.anonfield_NonImmStruct
+		res.NonImmStruct = &v
+		changed = true
+	}
+
+	if s.anonfield_NonImmStructA != other.anonfield_NonImmStructA {
+		v := other.anonfield_NonImmStructA
+		res.NonImmStructA = &v
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return &res
 }
 
-// SetKey is the setter for Key()
-func (s *MyStruct) SetKey(n MyStructKey) *MyStruct {
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to s.
+func (s *Embed1) Patch(d *Embed1Diff) *Embed1 {
+	if d == nil {
+		return s
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if s == nil {
+		s = new(Embed1)
+	}
+
+	return s.WithMutable(func(si *Embed1) {
+		if d.Name != nil {
+			si.field_Name = *d.Name
+		}
+		if d.Embed2 != nil {
+			si.anonfield_Embed2 = si.anonfield_Embed2.Patch(d.Embed2)
+		}
+		if d.PkgA != nil {
+			si.anonfield_PkgA = si.anonfield_PkgA.Patch(d.PkgA)
+		}
+		if d.Clash1 != nil {
+			si.anonfield_Clash1 = si.anonfield_Clash1.Patch(d.Clash1)
+		}
+		if d.Clash2 != nil {
+			si.anonfield_Clash2 = si.anonfield_Clash2.Patch(d.Clash2)
+		}
+		if d.NonImmStruct != nil {
+			si.anonfield_NonImmStruct = *d.NonImmStruct
+		}
+		if d.NonImmStructA != nil {
+			si.anonfield_NonImmStructA = *d.NonImmStructA
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. The exported fields of the template
+// for Embed1 are marshalled according to their names and tags.
+func (s *Embed1) MarshalJSON() ([]byte, error) {
+	if s == nil {
+		return []byte("null"), nil
+	}
+
+	v := struct {
+		Name          string
+		Embed2        *Embed2
+		PkgA          *pkga.PkgA
+		Clash1        *Clash1
+		Clash2        *pkga.Clash2
+		NonImmStruct  NonImmStruct
+		NonImmStructA pkga.NonImmStructA
+	}{
+		Name:          s.field_Name,
+		Embed2:        s.anonfield_Embed2,
+		PkgA:          s.anonfield_PkgA,
+		Clash1:        s.anonfield_Clash1,
+		Clash2:        s.anonfield_Clash2,
+		NonImmStruct:  s.anonfield_NonImmStruct,
+		NonImmStructA: s.anonfield_NonImmStructA,
+	}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The exported fields of the
+// template for Embed1 are unmarshalled according to their names and tags;
+// other fields, and those absent from the JSON, are left unchanged. The result
+// is built via WithMutable. Because json.Unmarshaler stores the result in
+// *s, s should be a new value rather than one shared with other code.
+func (s *Embed1) UnmarshalJSON(b []byte) error {
+	v := struct {
+		Name          string
+		Embed2        *Embed2
+		PkgA          *pkga.PkgA
+		Clash1        *Clash1
+		Clash2        *pkga.Clash2
+		NonImmStruct  NonImmStruct
+		NonImmStructA pkga.NonImmStructA
+	}{
+		Name:          s.field_Name,
+		Embed2:        s.anonfield_Embed2,
+		PkgA:          s.anonfield_PkgA,
+		Clash1:        s.anonfield_Clash1,
+		Clash2:        s.anonfield_Clash2,
+		NonImmStruct:  s.anonfield_NonImmStruct,
+		NonImmStructA: s.anonfield_NonImmStructA,
+	}
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*s = *s.WithMutable(func(si *Embed1) {
+		si.field_Name = v.Name
+		si.anonfield_Embed2 = v.Embed2
+		si.anonfield_PkgA = v.PkgA
+		si.anonfield_Clash1 = v.Clash1
+		si.anonfield_Clash2 = v.Clash2
+		si.anonfield_NonImmStruct = v.NonImmStruct
+		si.anonfield_NonImmStructA = v.NonImmStructA
+	})
+
+	return nil
+}
+
+func (s *Embed1) Address() string {
+	return s.PkgA().Address()
+}
+func (s *Embed1) SetAddress(n string) *Embed1 {
+	v1 := s.PkgA().SetAddress(n)
+	v0 := s.SetPkgA(v1)
+	return v0
+}
+
+func (s *Embed1) Age() int {
+	return s.Embed2().Age()
+}
+func (s *Embed1) SetAge(n int) *Embed1 {
+	v1 := s.Embed2().SetAge(n)
+	v0 := s.SetEmbed2(v1)
+	return v0
+}
+
+func (s *Embed1) Clash1() *Clash1 {
+	return s.anonfield_Clash1
+}
+
+// SetClash1 is the setter for Clash1()
+func (s *Embed1) SetClash1(n *Clash1) *Embed1 {
 	if s.mutable {
-		s.field_Key = n
+		s.anonfield_Clash1 = n
 		return s
 	}
 
 	res := *s
-	res.field_Key.Version++
-	res.field_Key = n
+	res.anonfield_Clash1 = n
 	return &res
 }
 
-// my field comment
-//somethingspecial
-/*
+func (s *Embed1) Clash2() *pkga.Clash2 {
+	return s.anonfield_Clash2
+}
 
-	Heelo
+// SetClash2 is the setter for Clash2()
+func (s *Embed1) SetClash2(n *pkga.Clash2) *Embed1 {
+	if s.mutable {
+		s.anonfield_Clash2 = n
+		return s
+	}
 
-*/
-func (s *MyStruct) Name() string {
+	res := *s
+	res.anonfield_Clash2 = n
+	return &res
+}
+
+func (s *Embed1) Embed2() *Embed2 {
+	return s.anonfield_Embed2
+}
+
+// SetEmbed2 is the setter for Embed2()
+func (s *Embed1) SetEmbed2(n *Embed2) *Embed1 {
+	if s.mutable {
+		s.anonfield_Embed2 = n
+		return s
+	}
+
+	res := *s
+	res.anonfield_Embed2 = n
+	return &res
+}
+
+func (s *Embed1) Name() string {
 	return s.field_Name
 }
 
 // SetName is the setter for Name()
-func (s *MyStruct) SetName(n string) *MyStruct {
+func (s *Embed1) SetName(n string) *Embed1 {
 	if s.mutable {
 		s.field_Name = n
 		return s
 	}
 
 	res := *s
-	res.field_Key.Version++
 	res.field_Name = n
 	return &res
 }
-func (s *MyStruct) age() int {
-	return s.field_age
+
+func (s *Embed1) NoClash1() string {
+	return s.Clash1().NoClash1()
+}
+func (s *Embed1) SetNoClash1(n string) *Embed1 {
+	v1 := s.Clash1().SetNoClash1(n)
+	v0 := s.SetClash1(v1)
+	return v0
 }
 
-// setAge is the setter for Age()
-func (s *MyStruct) setAge(n int) *MyStruct {
+func (s *Embed1) NoClash2() string {
+	return s.Clash2().NoClash2()
+}
+func (s *Embed1) SetNoClash2(n string) *Embed1 {
+	v1 := s.Clash2().SetNoClash2(n)
+	v0 := s.SetClash2(v1)
+	return v0
+}
+
+func (s *Embed1) NonImmStruct() NonImmStruct {
+	return s.anonfield_NonImmStruct
+}
+
+// SetNonImmStruct is the setter for NonImmStruct()
+func (s *Embed1) SetNonImmStruct(n NonImmStruct) *Embed1 {
 	if s.mutable {
-		s.field_age = n
+		s.anonfield_NonImmStruct = n
+		return s
+	}
+
+	res := *s
+	res.anonfield_NonImmStruct = n
+	return &res
+}
+
+func (s *Embed1) NonImmStructA() pkga.NonImmStructA {
+	return s.anonfield_NonImmStructA
+}
+
+// SetNonImmStructA is the setter for NonImmStructA()
+func (s *Embed1) SetNonImmStructA(n pkga.NonImmStructA) *Embed1 {
+	if s.mutable {
+		s.anonfield_NonImmStructA = n
 		return s
 	}
 
-	res := *s
-	res.field_Key.Version++
-	res.field_age = n
-	return &res
+	res := *s
+	res.anonfield_NonImmStructA = n
+	return &res
+}
+
+func (s *Embed1) Now() time.Time {
+	return s.NonImmStruct().Now
+}
+func (s *Embed1) SetNow(n time.Time) *Embed1 {
+	v1 := s.NonImmStruct()
+	v1.Now = n
+	v0 := s.SetNonImmStruct(v1)
+	return v0
+}
+
+func (s *Embed1) NowA() time.Time {
+	return s.NonImmStructA().NowA
+}
+func (s *Embed1) SetNowA(n time.Time) *Embed1 {
+	v1 := s.NonImmStructA()
+	v1.NowA = n
+	v0 := s.SetNonImmStructA(v1)
+	return v0
+}
+
+func (s *Embed1) Other() *Other {
+	return s.NonImmStruct().Other
+}
+func (s *Embed1) SetOther(n *Other) *Embed1 {
+	v1 := s.NonImmStruct()
+	v1.Other = n
+	v0 := s.SetNonImmStruct(v1)
+	return v0
+}
+
+func (s *Embed1) OtherA() *pkga.OtherA {
+	return s.NonImmStructA().OtherA
+}
+func (s *Embed1) SetOtherA(n *pkga.OtherA) *Embed1 {
+	v1 := s.NonImmStructA()
+	v1.OtherA = n
+	v0 := s.SetNonImmStructA(v1)
+	return v0
+}
+
+func (s *Embed1) OtherName() string {
+	return s.NonImmStruct().Other.OtherName()
+}
+func (s *Embed1) SetOtherName(n string) *Embed1 {
+	v2 := s.NonImmStruct().Other.SetOtherName(n)
+	v1 := s.NonImmStruct()
+	v1.Other = v2
+	v0 := s.SetNonImmStruct(v1)
+	return v0
+}
+
+func (s *Embed1) OtherNameA() string {
+	return s.NonImmStructA().OtherA.OtherNameA()
 }
-func (s *MyStruct) fieldWithoutTag() bool {
-	return s.field_fieldWithoutTag
+func (s *Embed1) SetOtherNameA(n string) *Embed1 {
+	v2 := s.NonImmStructA().OtherA.SetOtherNameA(n)
+	v1 := s.NonImmStructA()
+	v1.OtherA = v2
+	v0 := s.SetNonImmStructA(v1)
+	return v0
 }
 
-// setFieldWithoutTag is the setter for FieldWithoutTag()
-func (s *MyStruct) setFieldWithoutTag(n bool) *MyStruct {
-	if s.mutable {
-		s.field_fieldWithoutTag = n
-		return s
-	}
-
-	res := *s
-	res.field_Key.Version++
-	res.field_fieldWithoutTag = n
-	return &res
-}
-func (s *MyStruct) string() string {
-	return s.anonfield_string
+func (s *Embed1) PkgA() *pkga.PkgA {
+	return s.anonfield_PkgA
 }
 
-// setString is the setter for String()
-func (s *MyStruct) setString(n string) *MyStruct {
+// SetPkgA is the setter for PkgA()
+func (s *Embed1) SetPkgA(n *pkga.PkgA) *Embed1 {
 	if s.mutable {
-		s.anonfield_string = n
+		s.anonfield_PkgA = n
 		return s
 	}
 
 	res := *s
-	res.field_Key.Version++
-	res.anonfield_string = n
+	res.anonfield_PkgA = n
 	return &res
 }
 
-// my field comment
-//somethingspecial
-/*
-
-	Heelo
-
-*/
-func (s *MyStruct) surname() string {
-	return s.field_surname
+func (s *Embed1) PkgB() *pkgb.PkgB {
+	return s.PkgA().PkgB()
+}
+func (s *Embed1) SetPkgB(n *pkgb.PkgB) *Embed1 {
+	v1 := s.PkgA().SetPkgB(n)
+	v0 := s.SetPkgA(v1)
+	return v0
 }
 
-// setSurname is the setter for Surname()
-func (s *MyStruct) setSurname(n string) *MyStruct {
-	if s.mutable {
-		s.field_surname = n
-		return s
-	}
+func (s *Embed1) Postcode() string {
+	return s.PkgA().PkgB().Postcode()
+}
+func (s *Embed1) SetPostcode(n string) *Embed1 {
+	v2 := s.PkgA().PkgB().SetPostcode(n)
+	v1 := s.PkgA().SetPkgB(v2)
+	v0 := s.SetPkgA(v1)
+	return v0
+}
 
-	res := *s
-	res.field_Key.Version++
-	res.field_surname = n
-	return &res
+func (s *Embed1) otherdetails() string {
+	return s.Embed2().otherdetails()
+}
+func (s *Embed1) setOtherdetails(n string) *Embed1 {
+	v1 := s.Embed2().setOtherdetails(n)
+	v0 := s.SetEmbed2(v1)
+	return v0
 }
 
+// Embed2 is an immutable type and has the following template:
 //
-// MySpecialStruct is an immutable type and has the following template:
-//
-// 	struct {
-// 		Key	MySpecialStructKey
-//
-// 		Name	string
-// 	}
-//
-type MySpecialStruct struct {
-	field_Key  MySpecialStructKey
-	field_Name string
+//	struct {
+//		Age		int
+//		otherdetails	string
+//	}
+type Embed2 struct {
+	field_Age          int
+	field_otherdetails string
 
 	mutable bool
-	__tmpl  *_Imm_MySpecialStruct
+	__tmpl  *_Imm_Embed2
 }
 
-var _ immutable.Immutable = new(MySpecialStruct)
-var _ = new(MySpecialStruct).__tmpl
+var _ immutable.Immutable = new(Embed2)
+var _ = new(Embed2).__tmpl
 
-func (s *MySpecialStruct) AsMutable() *MySpecialStruct {
+func (s *Embed2) AsMutable() *Embed2 {
 	if s.Mutable() {
 		return s
 	}
 
 	res := *s
-	res.field_Key.BumpVersion()
 	res.mutable = true
 	return &res
 }
 
-func (s *MySpecialStruct) AsImmutable(v *MySpecialStruct) *MySpecialStruct {
+func (s *Embed2) AsImmutable(v *Embed2) *Embed2 {
 	if s == nil {
 		return nil
 	}
@@ -916,11 +8968,11 @@ func (s *MySpecialStruct) AsImmutable(v *MySpecialStruct) *MySpecialStruct {
 	return s
 }
 
-func (s *MySpecialStruct) Mutable() bool {
+func (s *Embed2) Mutable() bool {
 	return s.mutable
 }
 
-func (s *MySpecialStruct) WithMutable(f func(si *MySpecialStruct)) *MySpecialStruct {
+func (s *Embed2) WithMutable(f func(si *Embed2)) *Embed2 {
 	res := s.AsMutable()
 	f(res)
 	res = res.AsImmutable(s)
@@ -928,7 +8980,7 @@ func (s *MySpecialStruct) WithMutable(f func(si *MySpecialStruct)) *MySpecialStr
 	return res
 }
 
-func (s *MySpecialStruct) WithImmutable(f func(si *MySpecialStruct)) *MySpecialStruct {
+func (s *Embed2) WithImmutable(f func(si *Embed2)) *Embed2 {
 	prev := s.mutable
 	s.mutable = false
 	f(s)
@@ -937,7 +8989,7 @@ func (s *MySpecialStruct) WithImmutable(f func(si *MySpecialStruct)) *MySpecialS
 	return s
 }
 
-func (s *MySpecialStruct) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
+func (s *Embed2) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	if s == nil {
 		return true
 	}
@@ -957,203 +9009,165 @@ func (s *MySpecialStruct) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	seen[s] = true
 	return true
 }
-func (s *MySpecialStruct) Key() MySpecialStructKey {
-	return s.field_Key
-}
 
-// SetKey is the setter for Key()
-func (s *MySpecialStruct) SetKey(n MySpecialStructKey) *MySpecialStruct {
-	if s.mutable {
-		s.field_Key = n
-		return s
-	}
+// Embed2Diff is the change set between two Embed2 values, as returned by
+// Embed2.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set. Otherwise a non-nil
+// field holds either the new value of the corresponding field or, where the
+// field is itself of an immutable type, the change set for that field.
+type Embed2Diff struct {
+	Replaced bool
+	Value    *Embed2
 
-	res := *s
-	res.field_Key.BumpVersion()
-	res.field_Key = n
-	return &res
-}
-func (s *MySpecialStruct) Name() string {
-	return s.field_Name
+	Age          *int
+	otherdetails *string
 }
 
-// SetName is the setter for Name()
-func (s *MySpecialStruct) SetName(n string) *MySpecialStruct {
-	if s.mutable {
-		s.field_Name = n
-		return s
+// Diff returns the change set required to turn s into other, or nil if there
+// is no difference.
+func (s *Embed2) Diff(other *Embed2) *Embed2Diff {
+	if s == other {
+		return nil
 	}
 
-	res := *s
-	res.field_Key.BumpVersion()
-	res.field_Name = n
-	return &res
-}
+	if s == nil || other == nil {
+		return &Embed2Diff{Replaced: true, Value: other}
+	}
 
-//
-// A is an immutable type and has the following template:
-//
-// 	struct {
-// 		Name	string
-// 		A	*A
-//
-// 		Blah
-// 	}
-//
-type A struct {
-	field_Name     string
-	field_A        *A
-	anonfield_Blah Blah
+	var res Embed2Diff
+	changed := false
 
-	mutable bool
-	__tmpl  *_Imm_A
-}
+	if s.field_Age != other.field_Age {
+		v := other.field_Age
+		res.Age = &v
+		changed = true
+	}
 
-var _ immutable.Immutable = new(A)
-var _ = new(A).__tmpl
+	if s.field_otherdetails != other.field_otherdetails {
+		v := other.field_otherdetails
+		res.otherdetails = &v
+		changed = true
+	}
 
-func (s *A) AsMutable() *A {
-	if s.Mutable() {
-		return s
+	if !changed {
+		return nil
 	}
 
-	res := *s
-	res.mutable = true
 	return &res
 }
 
-func (s *A) AsImmutable(v *A) *A {
-	if s == nil {
-		return nil
-	}
-
-	if s == v {
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to s.
+func (s *Embed2) Patch(d *Embed2Diff) *Embed2 {
+	if d == nil {
 		return s
 	}
 
-	s.mutable = false
-	return s
-}
-
-func (s *A) Mutable() bool {
-	return s.mutable
-}
-
-func (s *A) WithMutable(f func(si *A)) *A {
-	res := s.AsMutable()
-	f(res)
-	res = res.AsImmutable(s)
-
-	return res
-}
-
-func (s *A) WithImmutable(f func(si *A)) *A {
-	prev := s.mutable
-	s.mutable = false
-	f(s)
-	s.mutable = prev
-
-	return s
-}
+	if d.Replaced {
+		return d.Value
+	}
 
-func (s *A) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	if s == nil {
-		return true
+		s = new(Embed2)
 	}
 
-	if s.Mutable() {
-		return false
-	}
+	return s.WithMutable(func(si *Embed2) {
+		if d.Age != nil {
+			si.field_Age = *d.Age
+		}
+		if d.otherdetails != nil {
+			si.field_otherdetails = *d.otherdetails
+		}
+	})
+}
 
-	if seen == nil {
-		return s.IsDeeplyNonMutable(make(map[interface{}]bool))
+// MarshalJSON implements json.Marshaler. The exported fields of the template
+// for Embed2 are marshalled according to their names and tags.
+func (s *Embed2) MarshalJSON() ([]byte, error) {
+	if s == nil {
+		return []byte("null"), nil
 	}
 
-	if seen[s] {
-		return true
+	v := struct {
+		Age int
+	}{
+		Age: s.field_Age,
 	}
 
-	seen[s] = true
-	{
-		v := s.field_A
+	return json.Marshal(v)
+}
 
-		if v != nil && !v.IsDeeplyNonMutable(seen) {
-			return false
-		}
+// UnmarshalJSON implements json.Unmarshaler. The exported fields of the
+// template for Embed2 are unmarshalled according to their names and tags;
+// other fields, and those absent from the JSON, are left unchanged. The result
+// is built via WithMutable. Because json.Unmarshaler stores the result in
+// *s, s should be a new value rather than one shared with other code.
+func (s *Embed2) UnmarshalJSON(b []byte) error {
+	v := struct {
+		Age int
+	}{
+		Age: s.field_Age,
 	}
-	{
-		v := s.anonfield_Blah
 
-		if v != nil && !v.IsDeeplyNonMutable(seen) {
-			return false
-		}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
 	}
-	return true
-}
-func (s *A) A() *A {
-	return s.field_A
-}
 
-// SetA is the setter for A()
-func (s *A) SetA(n *A) *A {
-	if s.mutable {
-		s.field_A = n
-		return s
-	}
+	*s = *s.WithMutable(func(si *Embed2) {
+		si.field_Age = v.Age
+	})
 
-	res := *s
-	res.field_A = n
-	return &res
+	return nil
 }
-func (s *A) Blah() Blah {
-	return s.anonfield_Blah
+
+func (s *Embed2) Age() int {
+	return s.field_Age
 }
 
-// SetBlah is the setter for Blah()
-func (s *A) SetBlah(n Blah) *A {
+// SetAge is the setter for Age()
+func (s *Embed2) SetAge(n int) *Embed2 {
 	if s.mutable {
-		s.anonfield_Blah = n
+		s.field_Age = n
 		return s
 	}
 
 	res := *s
-	res.anonfield_Blah = n
+	res.field_Age = n
 	return &res
 }
-func (s *A) Name() string {
-	return s.field_Name
+
+func (s *Embed2) otherdetails() string {
+	return s.field_otherdetails
 }
 
-// SetName is the setter for Name()
-func (s *A) SetName(n string) *A {
+// setOtherdetails is the setter for Otherdetails()
+func (s *Embed2) setOtherdetails(n string) *Embed2 {
 	if s.mutable {
-		s.field_Name = n
+		s.field_otherdetails = n
 		return s
 	}
 
 	res := *s
-	res.field_Name = n
+	res.field_otherdetails = n
 	return &res
 }
 
+// Other is an immutable type and has the following template:
 //
-// BlahUse is an immutable type and has the following template:
-//
-// 	struct {
-// 		Blah
-// 	}
-//
-type BlahUse struct {
-	anonfield_Blah Blah
+//	struct {
+//		OtherName string
+//	}
+type Other struct {
+	field_OtherName string
 
 	mutable bool
-	__tmpl  *_Imm_BlahUse
+	__tmpl  *_Imm_Other
 }
 
-var _ immutable.Immutable = new(BlahUse)
-var _ = new(BlahUse).__tmpl
+var _ immutable.Immutable = new(Other)
+var _ = new(Other).__tmpl
 
-func (s *BlahUse) AsMutable() *BlahUse {
+func (s *Other) AsMutable() *Other {
 	if s.Mutable() {
 		return s
 	}
@@ -1163,7 +9177,7 @@ func (s *BlahUse) AsMutable() *BlahUse {
 	return &res
 }
 
-func (s *BlahUse) AsImmutable(v *BlahUse) *BlahUse {
+func (s *Other) AsImmutable(v *Other) *Other {
 	if s == nil {
 		return nil
 	}
@@ -1176,11 +9190,11 @@ func (s *BlahUse) AsImmutable(v *BlahUse) *BlahUse {
 	return s
 }
 
-func (s *BlahUse) Mutable() bool {
+func (s *Other) Mutable() bool {
 	return s.mutable
 }
 
-func (s *BlahUse) WithMutable(f func(si *BlahUse)) *BlahUse {
+func (s *Other) WithMutable(f func(si *Other)) *Other {
 	res := s.AsMutable()
 	f(res)
 	res = res.AsImmutable(s)
@@ -1188,7 +9202,7 @@ func (s *BlahUse) WithMutable(f func(si *BlahUse)) *BlahUse {
 	return res
 }
 
-func (s *BlahUse) WithImmutable(f func(si *BlahUse)) *BlahUse {
+func (s *Other) WithImmutable(f func(si *Other)) *Other {
 	prev := s.mutable
 	s.mutable = false
 	f(s)
@@ -1197,7 +9211,7 @@ func (s *BlahUse) WithImmutable(f func(si *BlahUse)) *BlahUse {
 	return s
 }
 
-func (s *BlahUse) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
+func (s *Other) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	if s == nil {
 		return true
 	}
@@ -1215,51 +9229,179 @@ func (s *BlahUse) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	}
 
 	seen[s] = true
-	{
-		v := s.anonfield_Blah
+	return true
+}
 
-		if v != nil && !v.IsDeeplyNonMutable(seen) {
-			return false
+// OtherDiff is the change set between two Other values, as returned by
+// Other.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set. Otherwise a non-nil
+// field holds either the new value of the corresponding field or, where the
+// field is itself of an immutable type, the change set for that field.
+type OtherDiff struct {
+	Replaced bool
+	Value    *Other
+
+	OtherName *string
+}
+
+// Diff returns the change set required to turn s into other, or nil if there
+// is no difference.
+func (s *Other) Diff(other *Other) *OtherDiff {
+	if s == other {
+		return nil
+	}
+
+	if s == nil || other == nil {
+		return &OtherDiff{Replaced: true, Value: other}
+	}
+
+	var res OtherDiff
+	changed := false
+
+	if s.field_OtherName != other.field_OtherName {
+		v := other.field_OtherName
+		res.OtherName = &v
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return &res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to s.
+func (s *Other) Patch(d *OtherDiff) *Other {
+	if d == nil {
+		return s
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if s == nil {
+		s = new(Other)
+	}
+
+	return s.WithMutable(func(si *Other) {
+		if d.OtherName != nil {
+			si.field_OtherName = *d.OtherName
 		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. The exported fields of the template
+// for Other are marshalled according to their names and tags.
+func (s *Other) MarshalJSON() ([]byte, error) {
+	if s == nil {
+		return []byte("null"), nil
 	}
-	return true
+
+	v := struct {
+		OtherName string
+	}{
+		OtherName: s.field_OtherName,
+	}
+
+	return json.Marshal(v)
 }
-func (s *BlahUse) Blah() Blah {
-	return s.anonfield_Blah
+
+// UnmarshalJSON implements json.Unmarshaler. The exported fields of the
+// template for Other are unmarshalled according to their names and tags;
+// other fields, and those absent from the JSON, are left unchanged. The result
+// is built via WithMutable. Because json.Unmarshaler stores the result in
+// *s, s should be a new value rather than one shared with other code.
+func (s *Other) UnmarshalJSON(b []byte) error {
+	v := struct {
+		OtherName string
+	}{
+		OtherName: s.field_OtherName,
+	}
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*s = *s.WithMutable(func(si *Other) {
+		si.field_OtherName = v.OtherName
+	})
+
+	return nil
 }
 
-// SetBlah is the setter for Blah()
-func (s *BlahUse) SetBlah(n Blah) *BlahUse {
+func (s *Other) OtherName() string {
+	return s.field_OtherName
+}
+
+// SetOtherName is the setter for OtherName()
+func (s *Other) SetOtherName(n string) *Other {
 	if s.mutable {
-		s.anonfield_Blah = n
+		s.field_OtherName = n
 		return s
 	}
 
 	res := *s
-	res.anonfield_Blah = n
+	res.field_OtherName = n
 	return &res
 }
 
+// BinStruct is an immutable type and has the following template:
 //
-// Clash1 is an immutable type and has the following template:
+//	struct {
+//		Name	string
+//		Age	int
+//		Count	uint8
+//		Score	float64
+//		Active	bool
+//		Data	[]byte
+//		When	time.Time
+//		Tags	[]string
+//		Uuid	MyStructUuid
 //
-// 	struct {
-// 		Clash		string
-// 		NoClash1	string
-// 	}
+//		Slice		*BinSlice
+//		VectorSlice	*BinVectorSlice
+//		Map		*BinMap
+//		HamtMap		*BinHamtMap
+//		OrderedMap	*BinOrderedMap
+//		SortedMap	*BinSortedMap
+//		Set		*BinSet
+//		Nested		*BinStruct
+//		Structs		*BinStructs
 //
-type Clash1 struct {
-	field_Clash    string
-	field_NoClash1 string
+//		NotEncoded	string
+//	}
+type BinStruct struct {
+	field_Name        string          `binary:"1"`
+	field_Age         int             `binary:"2"`
+	field_Count       uint8           `binary:"3"`
+	field_Score       float64         `binary:"4"`
+	field_Active      bool            `binary:"5"`
+	field_Data        []byte          `binary:"6"`
+	field_When        time.Time       `binary:"7"`
+	field_Tags        []string        `binary:"8"`
+	field_Uuid        MyStructUuid    `binary:"9"`
+	field_Slice       *BinSlice       `binary:"20"`
+	field_VectorSlice *BinVectorSlice `binary:"21"`
+	field_Map         *BinMap         `binary:"22"`
+	field_HamtMap     *BinHamtMap     `binary:"23"`
+	field_OrderedMap  *BinOrderedMap  `binary:"24"`
+	field_SortedMap   *BinSortedMap   `binary:"25"`
+	field_Set         *BinSet         `binary:"26"`
+	field_Nested      *BinStruct      `binary:"27"`
+	field_Structs     *BinStructs     `binary:"28"`
+	field_NotEncoded  string
 
 	mutable bool
-	__tmpl  *_Imm_Clash1
+	__tmpl  *_Imm_BinStruct
 }
 
-var _ immutable.Immutable = new(Clash1)
-var _ = new(Clash1).__tmpl
+var _ immutable.Immutable = new(BinStruct)
+var _ = new(BinStruct).__tmpl
 
-func (s *Clash1) AsMutable() *Clash1 {
+func (s *BinStruct) AsMutable() *BinStruct {
 	if s.Mutable() {
 		return s
 	}
@@ -1269,7 +9411,7 @@ func (s *Clash1) AsMutable() *Clash1 {
 	return &res
 }
 
-func (s *Clash1) AsImmutable(v *Clash1) *Clash1 {
+func (s *BinStruct) AsImmutable(v *BinStruct) *BinStruct {
 	if s == nil {
 		return nil
 	}
@@ -1282,11 +9424,11 @@ func (s *Clash1) AsImmutable(v *Clash1) *Clash1 {
 	return s
 }
 
-func (s *Clash1) Mutable() bool {
+func (s *BinStruct) Mutable() bool {
 	return s.mutable
 }
 
-func (s *Clash1) WithMutable(f func(si *Clash1)) *Clash1 {
+func (s *BinStruct) WithMutable(f func(si *BinStruct)) *BinStruct {
 	res := s.AsMutable()
 	f(res)
 	res = res.AsImmutable(s)
@@ -1294,7 +9436,7 @@ func (s *Clash1) WithMutable(f func(si *Clash1)) *Clash1 {
 	return res
 }
 
-func (s *Clash1) WithImmutable(f func(si *Clash1)) *Clash1 {
+func (s *BinStruct) WithImmutable(f func(si *BinStruct)) *BinStruct {
 	prev := s.mutable
 	s.mutable = false
 	f(s)
@@ -1303,7 +9445,7 @@ func (s *Clash1) WithImmutable(f func(si *Clash1)) *Clash1 {
 	return s
 }
 
-func (s *Clash1) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
+func (s *BinStruct) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	if s == nil {
 		return true
 	}
@@ -1321,400 +9463,864 @@ func (s *Clash1) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	}
 
 	seen[s] = true
+	{
+		v := s.field_Slice
+
+		if v != nil && !v.IsDeeplyNonMutable(seen) {
+			return false
+		}
+	}
+	{
+		v := s.field_VectorSlice
+
+		if v != nil && !v.IsDeeplyNonMutable(seen) {
+			return false
+		}
+	}
+	{
+		v := s.field_Map
+
+		if v != nil && !v.IsDeeplyNonMutable(seen) {
+			return false
+		}
+	}
+	{
+		v := s.field_HamtMap
+
+		if v != nil && !v.IsDeeplyNonMutable(seen) {
+			return false
+		}
+	}
+	{
+		v := s.field_OrderedMap
+
+		if v != nil && !v.IsDeeplyNonMutable(seen) {
+			return false
+		}
+	}
+	{
+		v := s.field_SortedMap
+
+		if v != nil && !v.IsDeeplyNonMutable(seen) {
+			return false
+		}
+	}
+	{
+		v := s.field_Set
+
+		if v != nil && !v.IsDeeplyNonMutable(seen) {
+			return false
+		}
+	}
+	{
+		v := s.field_Nested
+
+		if v != nil && !v.IsDeeplyNonMutable(seen) {
+			return false
+		}
+	}
+	{
+		v := s.field_Structs
+
+		if v != nil && !v.IsDeeplyNonMutable(seen) {
+			return false
+		}
+	}
 	return true
 }
-func (s *Clash1) Clash() string {
-	return s.field_Clash
+
+// BinStructDiff is the change set between two BinStruct values, as returned by
+// BinStruct.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set. Otherwise a non-nil
+// field holds either the new value of the corresponding field or, where the
+// field is itself of an immutable type, the change set for that field.
+type BinStructDiff struct {
+	Replaced bool
+	Value    *BinStruct
+
+	Name        *string
+	Age         *int
+	Count       *uint8
+	Score       *float64
+	Active      *bool
+	Data        *[]byte
+	When        *time.Time
+	Tags        *[]string
+	Uuid        *MyStructUuid
+	Slice       *BinSliceDiff
+	VectorSlice *BinVectorSliceDiff
+	Map         *BinMapDiff
+	HamtMap     *BinHamtMapDiff
+	OrderedMap  *BinOrderedMapDiff
+	SortedMap   *BinSortedMapDiff
+	Set         *BinSetDiff
+	Nested      *BinStructDiff
+	Structs     *BinStructsDiff
+	NotEncoded  *string
+}
+
+// Diff returns the change set required to turn s into other, or nil if there
+// is no difference.
+func (s *BinStruct) Diff(other *BinStruct) *BinStructDiff {
+	if s == other {
+		return nil
+	}
+
+	if s == nil || other == nil {
+		return &BinStructDiff{Replaced: true, Value: other}
+	}
+
+	var res BinStructDiff
+	changed := false
+
+	if s.field_Name != other.field_Name {
+		v := other.field_Name
+		res.Name = &v
+		changed = true
+	}
+
+	if s.field_Age != other.field_Age {
+		v := other.field_Age
+		res.Age = &v
+		changed = true
+	}
+
+	if s.field_Count != other.field_Count {
+		v := other.field_Count
+		res.Count = &v
+		changed = true
+	}
+
+	if s.field_Score != other.field_Score {
+		v := other.field_Score
+		res.Score = &v
+		changed = true
+	}
+
+	if s.field_Active != other.field_Active {
+		v := other.field_Active
+		res.Active = &v
+		changed = true
+	}
+
+	if !reflect.DeepEqual(s.field_Data, other.field_Data) {
+		v := other.field_Data
+		res.Data = &v
+		changed = true
+	}
+
+	if s.field_When != other.field_When {
+		v := other.field_When
+		res.When = &v
+		changed = true
+	}
+
+	if !reflect.DeepEqual(s.field_Tags, other.field_Tags) {
+		v := other.field_Tags
+		res.Tags = &v
+		changed = true
+	}
+
+	if s.field_Uuid != other.field_Uuid {
+		v := other.field_Uuid
+		res.Uuid = &v
+		changed = true
+	}
+
+	if d := s.field_Slice.Diff(other.field_Slice); d != nil {
+		res.Slice = d
+		changed = true
+	}
+
+	if d := s.field_VectorSlice.Diff(other.field_VectorSlice); d != nil {
+		res.VectorSlice = d
+		changed = true
+	}
+
+	if d := s.field_Map.Diff(other.field_Map); d != nil {
+		res.Map = d
+		changed = true
+	}
+
+	if d := s.field_HamtMap.Diff(other.field_HamtMap); d != nil {
+		res.HamtMap = d
+		changed = true
+	}
+
+	if d := s.field_OrderedMap.Diff(other.field_OrderedMap); d != nil {
+		res.OrderedMap = d
+		changed = true
+	}
+
+	if d := s.field_SortedMap.Diff(other.field_SortedMap); d != nil {
+		res.SortedMap = d
+		changed = true
+	}
+
+	if d := s.field_Set.Diff(other.field_Set); d != nil {
+		res.Set = d
+		changed = true
+	}
+
+	if d := s.field_Nested.Diff(other.field_Nested); d != nil {
+		res.Nested = d
+		changed = true
+	}
+
+	if d := s.field_Structs.Diff(other.field_Structs); d != nil {
+		res.Structs = d
+		changed = true
+	}
+
+	if s.field_NotEncoded != other.field_NotEncoded {
+		v := other.field_NotEncoded
+		res.NotEncoded = &v
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return &res
 }
 
-// SetClash is the setter for Clash()
-func (s *Clash1) SetClash(n string) *Clash1 {
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to s.
+func (s *BinStruct) Patch(d *BinStructDiff) *BinStruct {
+	if d == nil {
+		return s
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if s == nil {
+		s = new(BinStruct)
+	}
+
+	return s.WithMutable(func(si *BinStruct) {
+		if d.Name != nil {
+			si.field_Name = *d.Name
+		}
+		if d.Age != nil {
+			si.field_Age = *d.Age
+		}
+		if d.Count != nil {
+			si.field_Count = *d.Count
+		}
+		if d.Score != nil {
+			si.field_Score = *d.Score
+		}
+		if d.Active != nil {
+			si.field_Active = *d.Active
+		}
+		if d.Data != nil {
+			si.field_Data = *d.Data
+		}
+		if d.When != nil {
+			si.field_When = *d.When
+		}
+		if d.Tags != nil {
+			si.field_Tags = *d.Tags
+		}
+		if d.Uuid != nil {
+			si.field_Uuid = *d.Uuid
+		}
+		if d.Slice != nil {
+			si.field_Slice = si.field_Slice.Patch(d.Slice)
+		}
+		if d.VectorSlice != nil {
+			si.field_VectorSlice = si.field_VectorSlice.Patch(d.VectorSlice)
+		}
+		if d.Map != nil {
+			si.field_Map = si.field_Map.Patch(d.Map)
+		}
+		if d.HamtMap != nil {
+			si.field_HamtMap = si.field_HamtMap.Patch(d.HamtMap)
+		}
+		if d.OrderedMap != nil {
+			si.field_OrderedMap = si.field_OrderedMap.Patch(d.OrderedMap)
+		}
+		if d.SortedMap != nil {
+			si.field_SortedMap = si.field_SortedMap.Patch(d.SortedMap)
+		}
+		if d.Set != nil {
+			si.field_Set = si.field_Set.Patch(d.Set)
+		}
+		if d.Nested != nil {
+			si.field_Nested = si.field_Nested.Patch(d.Nested)
+		}
+		if d.Structs != nil {
+			si.field_Structs = si.field_Structs.Patch(d.Structs)
+		}
+		if d.NotEncoded != nil {
+			si.field_NotEncoded = *d.NotEncoded
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. The exported fields of the template
+// for BinStruct are marshalled according to their names and tags.
+func (s *BinStruct) MarshalJSON() ([]byte, error) {
+	if s == nil {
+		return []byte("null"), nil
+	}
+
+	v := struct {
+		Name        string          `binary:"1"`
+		Age         int             `binary:"2"`
+		Count       uint8           `binary:"3"`
+		Score       float64         `binary:"4"`
+		Active      bool            `binary:"5"`
+		Data        []byte          `binary:"6"`
+		When        time.Time       `binary:"7"`
+		Tags        []string        `binary:"8"`
+		Uuid        MyStructUuid    `binary:"9"`
+		Slice       *BinSlice       `binary:"20"`
+		VectorSlice *BinVectorSlice `binary:"21"`
+		Map         *BinMap         `binary:"22"`
+		HamtMap     *BinHamtMap     `binary:"23"`
+		OrderedMap  *BinOrderedMap  `binary:"24"`
+		SortedMap   *BinSortedMap   `binary:"25"`
+		Set         *BinSet         `binary:"26"`
+		Nested      *BinStruct      `binary:"27"`
+		Structs     *BinStructs     `binary:"28"`
+		NotEncoded  string
+	}{
+		Name:        s.field_Name,
+		Age:         s.field_Age,
+		Count:       s.field_Count,
+		Score:       s.field_Score,
+		Active:      s.field_Active,
+		Data:        s.field_Data,
+		When:        s.field_When,
+		Tags:        s.field_Tags,
+		Uuid:        s.field_Uuid,
+		Slice:       s.field_Slice,
+		VectorSlice: s.field_VectorSlice,
+		Map:         s.field_Map,
+		HamtMap:     s.field_HamtMap,
+		OrderedMap:  s.field_OrderedMap,
+		SortedMap:   s.field_SortedMap,
+		Set:         s.field_Set,
+		Nested:      s.field_Nested,
+		Structs:     s.field_Structs,
+		NotEncoded:  s.field_NotEncoded,
+	}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The exported fields of the
+// template for BinStruct are unmarshalled according to their names and tags;
+// other fields, and those absent from the JSON, are left unchanged. The result
+// is built via WithMutable. Because json.Unmarshaler stores the result in
+// *s, s should be a new value rather than one shared with other code.
+func (s *BinStruct) UnmarshalJSON(b []byte) error {
+	v := struct {
+		Name        string          `binary:"1"`
+		Age         int             `binary:"2"`
+		Count       uint8           `binary:"3"`
+		Score       float64         `binary:"4"`
+		Active      bool            `binary:"5"`
+		Data        []byte          `binary:"6"`
+		When        time.Time       `binary:"7"`
+		Tags        []string        `binary:"8"`
+		Uuid        MyStructUuid    `binary:"9"`
+		Slice       *BinSlice       `binary:"20"`
+		VectorSlice *BinVectorSlice `binary:"21"`
+		Map         *BinMap         `binary:"22"`
+		HamtMap     *BinHamtMap     `binary:"23"`
+		OrderedMap  *BinOrderedMap  `binary:"24"`
+		SortedMap   *BinSortedMap   `binary:"25"`
+		Set         *BinSet         `binary:"26"`
+		Nested      *BinStruct      `binary:"27"`
+		Structs     *BinStructs     `binary:"28"`
+		NotEncoded  string
+	}{
+		Name:        s.field_Name,
+		Age:         s.field_Age,
+		Count:       s.field_Count,
+		Score:       s.field_Score,
+		Active:      s.field_Active,
+		Data:        s.field_Data,
+		When:        s.field_When,
+		Tags:        s.field_Tags,
+		Uuid:        s.field_Uuid,
+		Slice:       s.field_Slice,
+		VectorSlice: s.field_VectorSlice,
+		Map:         s.field_Map,
+		HamtMap:     s.field_HamtMap,
+		OrderedMap:  s.field_OrderedMap,
+		SortedMap:   s.field_SortedMap,
+		Set:         s.field_Set,
+		Nested:      s.field_Nested,
+		Structs:     s.field_Structs,
+		NotEncoded:  s.field_NotEncoded,
+	}
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*s = *s.WithMutable(func(si *BinStruct) {
+		si.field_Name = v.Name
+		si.field_Age = v.Age
+		si.field_Count = v.Count
+		si.field_Score = v.Score
+		si.field_Active = v.Active
+		si.field_Data = v.Data
+		si.field_When = v.When
+		si.field_Tags = v.Tags
+		si.field_Uuid = v.Uuid
+		si.field_Slice = v.Slice
+		si.field_VectorSlice = v.VectorSlice
+		si.field_Map = v.Map
+		si.field_HamtMap = v.HamtMap
+		si.field_OrderedMap = v.OrderedMap
+		si.field_SortedMap = v.SortedMap
+		si.field_Set = v.Set
+		si.field_Nested = v.Nested
+		si.field_Structs = v.Structs
+		si.field_NotEncoded = v.NotEncoded
+	})
+
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler. The fields of the template
+// for BinStruct with a binary struct tag are encoded, each identified by its tag
+// number. See myitcv.io/immutable/wire for details of the format.
+func (s *BinStruct) MarshalBinary() ([]byte, error) {
+	var e wire.Encoder
+
+	if s == nil {
+		return e.Result()
+	}
+
+	e.String(1, s.field_Name)
+	e.Int(2, int64(s.field_Age))
+	e.Uint(3, uint64(s.field_Count))
+	e.Float(4, s.field_Score)
+	e.Bool(5, s.field_Active)
+	e.Bytes(6, s.field_Data)
+	e.Marshaler(7, s.field_When)
+	e.Value(8, s.field_Tags)
+	e.Uint(9, uint64(s.field_Uuid))
+	if s.field_Slice != nil {
+		e.Marshaler(20, s.field_Slice)
+	}
+	if s.field_VectorSlice != nil {
+		e.Marshaler(21, s.field_VectorSlice)
+	}
+	if s.field_Map != nil {
+		e.Marshaler(22, s.field_Map)
+	}
+	if s.field_HamtMap != nil {
+		e.Marshaler(23, s.field_HamtMap)
+	}
+	if s.field_OrderedMap != nil {
+		e.Marshaler(24, s.field_OrderedMap)
+	}
+	if s.field_SortedMap != nil {
+		e.Marshaler(25, s.field_SortedMap)
+	}
+	if s.field_Set != nil {
+		e.Marshaler(26, s.field_Set)
+	}
+	if s.field_Nested != nil {
+		e.Marshaler(27, s.field_Nested)
+	}
+	if s.field_Structs != nil {
+		e.Marshaler(28, s.field_Structs)
+	}
+
+	return e.Result()
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. Fields with tag
+// numbers unknown to the template for BinStruct are ignored, and fields of s
+// that are not present in b are left unchanged.
+func (s *BinStruct) UnmarshalBinary(b []byte) error {
+	d := wire.NewDecoder(b)
+
+	for d.Next() {
+		switch d.Tag() {
+		case 1:
+			s.field_Name = d.String()
+		case 2:
+			s.field_Age = int(d.Int())
+		case 3:
+			s.field_Count = uint8(d.Uint())
+		case 4:
+			s.field_Score = d.Float()
+		case 5:
+			s.field_Active = d.Bool()
+		case 6:
+			s.field_Data = d.Bytes()
+		case 7:
+			d.Unmarshaler(&s.field_When)
+		case 8:
+			d.Value(&s.field_Tags)
+		case 9:
+			s.field_Uuid = MyStructUuid(d.Uint())
+		case 20:
+			s.field_Slice = new(BinSlice)
+			d.Unmarshaler(s.field_Slice)
+		case 21:
+			s.field_VectorSlice = new(BinVectorSlice)
+			d.Unmarshaler(s.field_VectorSlice)
+		case 22:
+			s.field_Map = new(BinMap)
+			d.Unmarshaler(s.field_Map)
+		case 23:
+			s.field_HamtMap = new(BinHamtMap)
+			d.Unmarshaler(s.field_HamtMap)
+		case 24:
+			s.field_OrderedMap = new(BinOrderedMap)
+			d.Unmarshaler(s.field_OrderedMap)
+		case 25:
+			s.field_SortedMap = new(BinSortedMap)
+			d.Unmarshaler(s.field_SortedMap)
+		case 26:
+			s.field_Set = new(BinSet)
+			d.Unmarshaler(s.field_Set)
+		case 27:
+			s.field_Nested = new(BinStruct)
+			d.Unmarshaler(s.field_Nested)
+		case 28:
+			s.field_Structs = new(BinStructs)
+			d.Unmarshaler(s.field_Structs)
+		}
+	}
+
+	return d.Err()
+}
+
+func (s *BinStruct) Active() bool {
+	return s.field_Active
+}
+
+// SetActive is the setter for Active()
+func (s *BinStruct) SetActive(n bool) *BinStruct {
 	if s.mutable {
-		s.field_Clash = n
+		s.field_Active = n
 		return s
 	}
 
 	res := *s
-	res.field_Clash = n
+	res.field_Active = n
 	return &res
 }
-func (s *Clash1) NoClash1() string {
-	return s.field_NoClash1
+
+func (s *BinStruct) Age() int {
+	return s.field_Age
 }
 
-// SetNoClash1 is the setter for NoClash1()
-func (s *Clash1) SetNoClash1(n string) *Clash1 {
+// SetAge is the setter for Age()
+func (s *BinStruct) SetAge(n int) *BinStruct {
 	if s.mutable {
-		s.field_NoClash1 = n
+		s.field_Age = n
 		return s
 	}
 
 	res := *s
-	res.field_NoClash1 = n
+	res.field_Age = n
 	return &res
 }
 
-// types for testing embedding
-//
-// Embed1 is an immutable type and has the following template:
-//
-// 	struct {
-// 		Name	string
-// 		*Embed2
-// 		*pkga.PkgA
-// 		*Clash1
-// 		*pkga.Clash2
-// 		NonImmStruct
-// 		pkga.NonImmStructA
-// 	}
-//
-type Embed1 struct {
-	field_Name              string
-	anonfield_Embed2        *Embed2
-	anonfield_PkgA          *pkga.PkgA
-	anonfield_Clash1        *Clash1
-	anonfield_Clash2        *pkga.Clash2
-	anonfield_NonImmStruct  NonImmStruct
-	anonfield_NonImmStructA pkga.NonImmStructA
-
-	mutable bool
-	__tmpl  *_Imm_Embed1
+func (s *BinStruct) Count() uint8 {
+	return s.field_Count
 }
 
-var _ immutable.Immutable = new(Embed1)
-var _ = new(Embed1).__tmpl
-
-func (s *Embed1) AsMutable() *Embed1 {
-	if s.Mutable() {
+// SetCount is the setter for Count()
+func (s *BinStruct) SetCount(n uint8) *BinStruct {
+	if s.mutable {
+		s.field_Count = n
 		return s
 	}
 
 	res := *s
-	res.mutable = true
+	res.field_Count = n
 	return &res
 }
 
-func (s *Embed1) AsImmutable(v *Embed1) *Embed1 {
-	if s == nil {
-		return nil
-	}
+func (s *BinStruct) Data() []byte {
+	return s.field_Data
+}
 
-	if s == v {
+// SetData is the setter for Data()
+func (s *BinStruct) SetData(n []byte) *BinStruct {
+	if s.mutable {
+		s.field_Data = n
 		return s
 	}
 
-	s.mutable = false
-	return s
+	res := *s
+	res.field_Data = n
+	return &res
 }
 
-func (s *Embed1) Mutable() bool {
-	return s.mutable
+func (s *BinStruct) HamtMap() *BinHamtMap {
+	return s.field_HamtMap
 }
 
-func (s *Embed1) WithMutable(f func(si *Embed1)) *Embed1 {
-	res := s.AsMutable()
-	f(res)
-	res = res.AsImmutable(s)
+// SetHamtMap is the setter for HamtMap()
+func (s *BinStruct) SetHamtMap(n *BinHamtMap) *BinStruct {
+	if s.mutable {
+		s.field_HamtMap = n
+		return s
+	}
 
-	return res
+	res := *s
+	res.field_HamtMap = n
+	return &res
 }
 
-func (s *Embed1) WithImmutable(f func(si *Embed1)) *Embed1 {
-	prev := s.mutable
-	s.mutable = false
-	f(s)
-	s.mutable = prev
-
-	return s
+func (s *BinStruct) Map() *BinMap {
+	return s.field_Map
 }
 
-func (s *Embed1) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
-	if s == nil {
-		return true
+// SetMap is the setter for Map()
+func (s *BinStruct) SetMap(n *BinMap) *BinStruct {
+	if s.mutable {
+		s.field_Map = n
+		return s
 	}
 
-	if s.Mutable() {
-		return false
-	}
+	res := *s
+	res.field_Map = n
+	return &res
+}
 
-	if seen == nil {
-		return s.IsDeeplyNonMutable(make(map[interface{}]bool))
-	}
+func (s *BinStruct) Name() string {
+	return s.field_Name
+}
 
-	if seen[s] {
-		return true
+// SetName is the setter for Name()
+func (s *BinStruct) SetName(n string) *BinStruct {
+	if s.mutable {
+		s.field_Name = n
+		return s
 	}
 
-	seen[s] = true
-	{
-		v := s.anonfield_Embed2
-
-		if v != nil && !v.IsDeeplyNonMutable(seen) {
-			return false
-		}
-	}
-	{
-		v := s.anonfield_PkgA
+	res := *s
+	res.field_Name = n
+	return &res
+}
 
-		if v != nil && !v.IsDeeplyNonMutable(seen) {
-			return false
-		}
-	}
-	{
-		v := s.anonfield_Clash1
+func (s *BinStruct) Nested() *BinStruct {
+	return s.field_Nested
+}
 
-		if v != nil && !v.IsDeeplyNonMutable(seen) {
-			return false
-		}
+// SetNested is the setter for Nested()
+func (s *BinStruct) SetNested(n *BinStruct) *BinStruct {
+	if s.mutable {
+		s.field_Nested = n
+		return s
 	}
-	{
-		v := s.anonfield_Clash2
 
-		if v != nil && !v.IsDeeplyNonMutable(seen) {
-			return false
-		}
-	}
-	return true
-}
-func (s *Embed1) Address() string {
-	return s.PkgA().Address()
-}
-func (s *Embed1) SetAddress(n string) *Embed1 {
-	v1 := s.PkgA().SetAddress(n)
-	v0 := s.SetPkgA(v1)
-	return v0
-}
-func (s *Embed1) Age() int {
-	return s.Embed2().Age()
-}
-func (s *Embed1) SetAge(n int) *Embed1 {
-	v1 := s.Embed2().SetAge(n)
-	v0 := s.SetEmbed2(v1)
-	return v0
+	res := *s
+	res.field_Nested = n
+	return &res
 }
-func (s *Embed1) Clash1() *Clash1 {
-	return s.anonfield_Clash1
+
+func (s *BinStruct) NotEncoded() string {
+	return s.field_NotEncoded
 }
 
-// SetClash1 is the setter for Clash1()
-func (s *Embed1) SetClash1(n *Clash1) *Embed1 {
+// SetNotEncoded is the setter for NotEncoded()
+func (s *BinStruct) SetNotEncoded(n string) *BinStruct {
 	if s.mutable {
-		s.anonfield_Clash1 = n
+		s.field_NotEncoded = n
 		return s
 	}
 
 	res := *s
-	res.anonfield_Clash1 = n
+	res.field_NotEncoded = n
 	return &res
 }
-func (s *Embed1) Clash2() *pkga.Clash2 {
-	return s.anonfield_Clash2
+
+func (s *BinStruct) OrderedMap() *BinOrderedMap {
+	return s.field_OrderedMap
 }
 
-// SetClash2 is the setter for Clash2()
-func (s *Embed1) SetClash2(n *pkga.Clash2) *Embed1 {
+// SetOrderedMap is the setter for OrderedMap()
+func (s *BinStruct) SetOrderedMap(n *BinOrderedMap) *BinStruct {
 	if s.mutable {
-		s.anonfield_Clash2 = n
+		s.field_OrderedMap = n
 		return s
 	}
 
 	res := *s
-	res.anonfield_Clash2 = n
+	res.field_OrderedMap = n
 	return &res
 }
-func (s *Embed1) Embed2() *Embed2 {
-	return s.anonfield_Embed2
+
+func (s *BinStruct) Score() float64 {
+	return s.field_Score
 }
 
-// SetEmbed2 is the setter for Embed2()
-func (s *Embed1) SetEmbed2(n *Embed2) *Embed1 {
+// SetScore is the setter for Score()
+func (s *BinStruct) SetScore(n float64) *BinStruct {
 	if s.mutable {
-		s.anonfield_Embed2 = n
+		s.field_Score = n
 		return s
 	}
 
 	res := *s
-	res.anonfield_Embed2 = n
+	res.field_Score = n
 	return &res
 }
-func (s *Embed1) Name() string {
-	return s.field_Name
+
+func (s *BinStruct) Set() *BinSet {
+	return s.field_Set
 }
 
-// SetName is the setter for Name()
-func (s *Embed1) SetName(n string) *Embed1 {
+// SetSet is the setter for Set()
+func (s *BinStruct) SetSet(n *BinSet) *BinStruct {
 	if s.mutable {
-		s.field_Name = n
+		s.field_Set = n
 		return s
 	}
 
 	res := *s
-	res.field_Name = n
+	res.field_Set = n
 	return &res
 }
-func (s *Embed1) NoClash1() string {
-	return s.Clash1().NoClash1()
-}
-func (s *Embed1) SetNoClash1(n string) *Embed1 {
-	v1 := s.Clash1().SetNoClash1(n)
-	v0 := s.SetClash1(v1)
-	return v0
-}
-func (s *Embed1) NoClash2() string {
-	return s.Clash2().NoClash2()
-}
-func (s *Embed1) SetNoClash2(n string) *Embed1 {
-	v1 := s.Clash2().SetNoClash2(n)
-	v0 := s.SetClash2(v1)
-	return v0
-}
-func (s *Embed1) NonImmStruct() NonImmStruct {
-	return s.anonfield_NonImmStruct
+
+func (s *BinStruct) Slice() *BinSlice {
+	return s.field_Slice
 }
 
-// SetNonImmStruct is the setter for NonImmStruct()
-func (s *Embed1) SetNonImmStruct(n NonImmStruct) *Embed1 {
+// SetSlice is the setter for Slice()
+func (s *BinStruct) SetSlice(n *BinSlice) *BinStruct {
 	if s.mutable {
-		s.anonfield_NonImmStruct = n
+		s.field_Slice = n
 		return s
 	}
 
 	res := *s
-	res.anonfield_NonImmStruct = n
+	res.field_Slice = n
 	return &res
 }
-func (s *Embed1) NonImmStructA() pkga.NonImmStructA {
-	return s.anonfield_NonImmStructA
+
+func (s *BinStruct) SortedMap() *BinSortedMap {
+	return s.field_SortedMap
 }
 
-// SetNonImmStructA is the setter for NonImmStructA()
-func (s *Embed1) SetNonImmStructA(n pkga.NonImmStructA) *Embed1 {
+// SetSortedMap is the setter for SortedMap()
+func (s *BinStruct) SetSortedMap(n *BinSortedMap) *BinStruct {
 	if s.mutable {
-		s.anonfield_NonImmStructA = n
+		s.field_SortedMap = n
 		return s
 	}
 
 	res := *s
-	res.anonfield_NonImmStructA = n
+	res.field_SortedMap = n
 	return &res
 }
-func (s *Embed1) Now() time.Time {
-	return s.NonImmStruct().Now
-}
-func (s *Embed1) SetNow(n time.Time) *Embed1 {
-	v1 := s.NonImmStruct()
-	v1.Now = n
-	v0 := s.SetNonImmStruct(v1)
-	return v0
-}
-func (s *Embed1) NowA() time.Time {
-	return s.NonImmStructA().NowA
-}
-func (s *Embed1) SetNowA(n time.Time) *Embed1 {
-	v1 := s.NonImmStructA()
-	v1.NowA = n
-	v0 := s.SetNonImmStructA(v1)
-	return v0
-}
-func (s *Embed1) Other() *Other {
-	return s.NonImmStruct().Other
-}
-func (s *Embed1) SetOther(n *Other) *Embed1 {
-	v1 := s.NonImmStruct()
-	v1.Other = n
-	v0 := s.SetNonImmStruct(v1)
-	return v0
-}
-func (s *Embed1) OtherA() *pkga.OtherA {
-	return s.NonImmStructA().OtherA
-}
-func (s *Embed1) SetOtherA(n *pkga.OtherA) *Embed1 {
-	v1 := s.NonImmStructA()
-	v1.OtherA = n
-	v0 := s.SetNonImmStructA(v1)
-	return v0
-}
-func (s *Embed1) OtherName() string {
-	return s.NonImmStruct().Other.OtherName()
-}
-func (s *Embed1) SetOtherName(n string) *Embed1 {
-	v2 := s.NonImmStruct().Other.SetOtherName(n)
-	v1 := s.NonImmStruct()
-	v1.Other = v2
-	v0 := s.SetNonImmStruct(v1)
-	return v0
-}
-func (s *Embed1) OtherNameA() string {
-	return s.NonImmStructA().OtherA.OtherNameA()
+
+func (s *BinStruct) Structs() *BinStructs {
+	return s.field_Structs
 }
-func (s *Embed1) SetOtherNameA(n string) *Embed1 {
-	v2 := s.NonImmStructA().OtherA.SetOtherNameA(n)
-	v1 := s.NonImmStructA()
-	v1.OtherA = v2
-	v0 := s.SetNonImmStructA(v1)
-	return v0
+
+// SetStructs is the setter for Structs()
+func (s *BinStruct) SetStructs(n *BinStructs) *BinStruct {
+	if s.mutable {
+		s.field_Structs = n
+		return s
+	}
+
+	res := *s
+	res.field_Structs = n
+	return &res
 }
-func (s *Embed1) PkgA() *pkga.PkgA {
-	return s.anonfield_PkgA
+
+func (s *BinStruct) Tags() []string {
+	return s.field_Tags
 }
 
-// SetPkgA is the setter for PkgA()
-func (s *Embed1) SetPkgA(n *pkga.PkgA) *Embed1 {
+// SetTags is the setter for Tags()
+func (s *BinStruct) SetTags(n []string) *BinStruct {
 	if s.mutable {
-		s.anonfield_PkgA = n
+		s.field_Tags = n
 		return s
 	}
 
 	res := *s
-	res.anonfield_PkgA = n
+	res.field_Tags = n
 	return &res
 }
-func (s *Embed1) PkgB() *pkgb.PkgB {
-	return s.PkgA().PkgB()
+
+func (s *BinStruct) Uuid() MyStructUuid {
+	return s.field_Uuid
 }
-func (s *Embed1) SetPkgB(n *pkgb.PkgB) *Embed1 {
-	v1 := s.PkgA().SetPkgB(n)
-	v0 := s.SetPkgA(v1)
-	return v0
+
+// SetUuid is the setter for Uuid()
+func (s *BinStruct) SetUuid(n MyStructUuid) *BinStruct {
+	if s.mutable {
+		s.field_Uuid = n
+		return s
+	}
+
+	res := *s
+	res.field_Uuid = n
+	return &res
 }
-func (s *Embed1) Postcode() string {
-	return s.PkgA().PkgB().Postcode()
+
+func (s *BinStruct) VectorSlice() *BinVectorSlice {
+	return s.field_VectorSlice
 }
-func (s *Embed1) SetPostcode(n string) *Embed1 {
-	v2 := s.PkgA().PkgB().SetPostcode(n)
-	v1 := s.PkgA().SetPkgB(v2)
-	v0 := s.SetPkgA(v1)
-	return v0
+
+// SetVectorSlice is the setter for VectorSlice()
+func (s *BinStruct) SetVectorSlice(n *BinVectorSlice) *BinStruct {
+	if s.mutable {
+		s.field_VectorSlice = n
+		return s
+	}
+
+	res := *s
+	res.field_VectorSlice = n
+	return &res
 }
-func (s *Embed1) otherdetails() string {
-	return s.Embed2().otherdetails()
+
+func (s *BinStruct) When() time.Time {
+	return s.field_When
 }
-func (s *Embed1) setOtherdetails(n string) *Embed1 {
-	v1 := s.Embed2().setOtherdetails(n)
-	v0 := s.SetEmbed2(v1)
-	return v0
+
+// SetWhen is the setter for When()
+func (s *BinStruct) SetWhen(n time.Time) *BinStruct {
+	if s.mutable {
+		s.field_When = n
+		return s
+	}
+
+	res := *s
+	res.field_When = n
+	return &res
 }
 
+// BinV1 is an immutable type and has the following template:
 //
-// Embed2 is an immutable type and has the following template:
-//
-// 	struct {
-// 		Age		int
-// 		otherdetails	string
-// 	}
-//
-type Embed2 struct {
-	field_Age          int
-	field_otherdetails string
+//	struct {
+//		Name	string
+//		Age	int
+//	}
+type BinV1 struct {
+	field_Name string `binary:"1"`
+	field_Age  int    `binary:"2"`
 
 	mutable bool
-	__tmpl  *_Imm_Embed2
+	__tmpl  *_Imm_BinV1
 }
 
-var _ immutable.Immutable = new(Embed2)
-var _ = new(Embed2).__tmpl
+var _ immutable.Immutable = new(BinV1)
+var _ = new(BinV1).__tmpl
 
-func (s *Embed2) AsMutable() *Embed2 {
+func (s *BinV1) AsMutable() *BinV1 {
 	if s.Mutable() {
 		return s
 	}
@@ -1724,7 +10330,7 @@ func (s *Embed2) AsMutable() *Embed2 {
 	return &res
 }
 
-func (s *Embed2) AsImmutable(v *Embed2) *Embed2 {
+func (s *BinV1) AsImmutable(v *BinV1) *BinV1 {
 	if s == nil {
 		return nil
 	}
@@ -1737,11 +10343,11 @@ func (s *Embed2) AsImmutable(v *Embed2) *Embed2 {
 	return s
 }
 
-func (s *Embed2) Mutable() bool {
+func (s *BinV1) Mutable() bool {
 	return s.mutable
 }
 
-func (s *Embed2) WithMutable(f func(si *Embed2)) *Embed2 {
+func (s *BinV1) WithMutable(f func(si *BinV1)) *BinV1 {
 	res := s.AsMutable()
 	f(res)
 	res = res.AsImmutable(s)
@@ -1749,7 +10355,7 @@ func (s *Embed2) WithMutable(f func(si *Embed2)) *Embed2 {
 	return res
 }
 
-func (s *Embed2) WithImmutable(f func(si *Embed2)) *Embed2 {
+func (s *BinV1) WithImmutable(f func(si *BinV1)) *BinV1 {
 	prev := s.mutable
 	s.mutable = false
 	f(s)
@@ -1758,7 +10364,7 @@ func (s *Embed2) WithImmutable(f func(si *Embed2)) *Embed2 {
 	return s
 }
 
-func (s *Embed2) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
+func (s *BinV1) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	if s == nil {
 		return true
 	}
@@ -1778,12 +10384,162 @@ func (s *Embed2) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	seen[s] = true
 	return true
 }
-func (s *Embed2) Age() int {
+
+// BinV1Diff is the change set between two BinV1 values, as returned by
+// BinV1.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set. Otherwise a non-nil
+// field holds either the new value of the corresponding field or, where the
+// field is itself of an immutable type, the change set for that field.
+type BinV1Diff struct {
+	Replaced bool
+	Value    *BinV1
+
+	Name *string
+	Age  *int
+}
+
+// Diff returns the change set required to turn s into other, or nil if there
+// is no difference.
+func (s *BinV1) Diff(other *BinV1) *BinV1Diff {
+	if s == other {
+		return nil
+	}
+
+	if s == nil || other == nil {
+		return &BinV1Diff{Replaced: true, Value: other}
+	}
+
+	var res BinV1Diff
+	changed := false
+
+	if s.field_Name != other.field_Name {
+		v := other.field_Name
+		res.Name = &v
+		changed = true
+	}
+
+	if s.field_Age != other.field_Age {
+		v := other.field_Age
+		res.Age = &v
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return &res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to s.
+func (s *BinV1) Patch(d *BinV1Diff) *BinV1 {
+	if d == nil {
+		return s
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if s == nil {
+		s = new(BinV1)
+	}
+
+	return s.WithMutable(func(si *BinV1) {
+		if d.Name != nil {
+			si.field_Name = *d.Name
+		}
+		if d.Age != nil {
+			si.field_Age = *d.Age
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. The exported fields of the template
+// for BinV1 are marshalled according to their names and tags.
+func (s *BinV1) MarshalJSON() ([]byte, error) {
+	if s == nil {
+		return []byte("null"), nil
+	}
+
+	v := struct {
+		Name string `binary:"1"`
+		Age  int    `binary:"2"`
+	}{
+		Name: s.field_Name,
+		Age:  s.field_Age,
+	}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The exported fields of the
+// template for BinV1 are unmarshalled according to their names and tags;
+// other fields, and those absent from the JSON, are left unchanged. The result
+// is built via WithMutable. Because json.Unmarshaler stores the result in
+// *s, s should be a new value rather than one shared with other code.
+func (s *BinV1) UnmarshalJSON(b []byte) error {
+	v := struct {
+		Name string `binary:"1"`
+		Age  int    `binary:"2"`
+	}{
+		Name: s.field_Name,
+		Age:  s.field_Age,
+	}
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*s = *s.WithMutable(func(si *BinV1) {
+		si.field_Name = v.Name
+		si.field_Age = v.Age
+	})
+
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler. The fields of the template
+// for BinV1 with a binary struct tag are encoded, each identified by its tag
+// number. See myitcv.io/immutable/wire for details of the format.
+func (s *BinV1) MarshalBinary() ([]byte, error) {
+	var e wire.Encoder
+
+	if s == nil {
+		return e.Result()
+	}
+
+	e.String(1, s.field_Name)
+	e.Int(2, int64(s.field_Age))
+
+	return e.Result()
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. Fields with tag
+// numbers unknown to the template for BinV1 are ignored, and fields of s
+// that are not present in b are left unchanged.
+func (s *BinV1) UnmarshalBinary(b []byte) error {
+	d := wire.NewDecoder(b)
+
+	for d.Next() {
+		switch d.Tag() {
+		case 1:
+			s.field_Name = d.String()
+		case 2:
+			s.field_Age = int(d.Int())
+		}
+	}
+
+	return d.Err()
+}
+
+func (s *BinV1) Age() int {
 	return s.field_Age
 }
 
 // SetAge is the setter for Age()
-func (s *Embed2) SetAge(n int) *Embed2 {
+func (s *BinV1) SetAge(n int) *BinV1 {
 	if s.mutable {
 		s.field_Age = n
 		return s
@@ -1793,40 +10549,43 @@ func (s *Embed2) SetAge(n int) *Embed2 {
 	res.field_Age = n
 	return &res
 }
-func (s *Embed2) otherdetails() string {
-	return s.field_otherdetails
+
+func (s *BinV1) Name() string {
+	return s.field_Name
 }
 
-// setOtherdetails is the setter for Otherdetails()
-func (s *Embed2) setOtherdetails(n string) *Embed2 {
+// SetName is the setter for Name()
+func (s *BinV1) SetName(n string) *BinV1 {
 	if s.mutable {
-		s.field_otherdetails = n
+		s.field_Name = n
 		return s
 	}
 
 	res := *s
-	res.field_otherdetails = n
+	res.field_Name = n
 	return &res
 }
 
+// BinV2 is an immutable type and has the following template:
 //
-// Other is an immutable type and has the following template:
-//
-// 	struct {
-// 		OtherName string
-// 	}
-//
-type Other struct {
-	field_OtherName string
+//	struct {
+//		Name	string
+//		Email	string
+//		Tags	*BinSlice
+//	}
+type BinV2 struct {
+	field_Name  string    `binary:"1"`
+	field_Email string    `binary:"3"`
+	field_Tags  *BinSlice `binary:"4"`
 
 	mutable bool
-	__tmpl  *_Imm_Other
+	__tmpl  *_Imm_BinV2
 }
 
-var _ immutable.Immutable = new(Other)
-var _ = new(Other).__tmpl
+var _ immutable.Immutable = new(BinV2)
+var _ = new(BinV2).__tmpl
 
-func (s *Other) AsMutable() *Other {
+func (s *BinV2) AsMutable() *BinV2 {
 	if s.Mutable() {
 		return s
 	}
@@ -1836,7 +10595,7 @@ func (s *Other) AsMutable() *Other {
 	return &res
 }
 
-func (s *Other) AsImmutable(v *Other) *Other {
+func (s *BinV2) AsImmutable(v *BinV2) *BinV2 {
 	if s == nil {
 		return nil
 	}
@@ -1849,11 +10608,11 @@ func (s *Other) AsImmutable(v *Other) *Other {
 	return s
 }
 
-func (s *Other) Mutable() bool {
+func (s *BinV2) Mutable() bool {
 	return s.mutable
 }
 
-func (s *Other) WithMutable(f func(si *Other)) *Other {
+func (s *BinV2) WithMutable(f func(si *BinV2)) *BinV2 {
 	res := s.AsMutable()
 	f(res)
 	res = res.AsImmutable(s)
@@ -1861,7 +10620,7 @@ func (s *Other) WithMutable(f func(si *Other)) *Other {
 	return res
 }
 
-func (s *Other) WithImmutable(f func(si *Other)) *Other {
+func (s *BinV2) WithImmutable(f func(si *BinV2)) *BinV2 {
 	prev := s.mutable
 	s.mutable = false
 	f(s)
@@ -1870,7 +10629,7 @@ func (s *Other) WithImmutable(f func(si *Other)) *Other {
 	return s
 }
 
-func (s *Other) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
+func (s *BinV2) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	if s == nil {
 		return true
 	}
@@ -1888,20 +10647,229 @@ func (s *Other) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	}
 
 	seen[s] = true
+	{
+		v := s.field_Tags
+
+		if v != nil && !v.IsDeeplyNonMutable(seen) {
+			return false
+		}
+	}
 	return true
 }
-func (s *Other) OtherName() string {
-	return s.field_OtherName
+
+// BinV2Diff is the change set between two BinV2 values, as returned by
+// BinV2.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set. Otherwise a non-nil
+// field holds either the new value of the corresponding field or, where the
+// field is itself of an immutable type, the change set for that field.
+type BinV2Diff struct {
+	Replaced bool
+	Value    *BinV2
+
+	Name  *string
+	Email *string
+	Tags  *BinSliceDiff
 }
 
-// SetOtherName is the setter for OtherName()
-func (s *Other) SetOtherName(n string) *Other {
+// Diff returns the change set required to turn s into other, or nil if there
+// is no difference.
+func (s *BinV2) Diff(other *BinV2) *BinV2Diff {
+	if s == other {
+		return nil
+	}
+
+	if s == nil || other == nil {
+		return &BinV2Diff{Replaced: true, Value: other}
+	}
+
+	var res BinV2Diff
+	changed := false
+
+	if s.field_Name != other.field_Name {
+		v := other.field_Name
+		res.Name = &v
+		changed = true
+	}
+
+	if s.field_Email != other.field_Email {
+		v := other.field_Email
+		res.Email = &v
+		changed = true
+	}
+
+	if d := s.field_Tags.Diff(other.field_Tags); d != nil {
+		res.Tags = d
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return &res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to s.
+func (s *BinV2) Patch(d *BinV2Diff) *BinV2 {
+	if d == nil {
+		return s
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if s == nil {
+		s = new(BinV2)
+	}
+
+	return s.WithMutable(func(si *BinV2) {
+		if d.Name != nil {
+			si.field_Name = *d.Name
+		}
+		if d.Email != nil {
+			si.field_Email = *d.Email
+		}
+		if d.Tags != nil {
+			si.field_Tags = si.field_Tags.Patch(d.Tags)
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. The exported fields of the template
+// for BinV2 are marshalled according to their names and tags.
+func (s *BinV2) MarshalJSON() ([]byte, error) {
+	if s == nil {
+		return []byte("null"), nil
+	}
+
+	v := struct {
+		Name  string    `binary:"1"`
+		Email string    `binary:"3"`
+		Tags  *BinSlice `binary:"4"`
+	}{
+		Name:  s.field_Name,
+		Email: s.field_Email,
+		Tags:  s.field_Tags,
+	}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The exported fields of the
+// template for BinV2 are unmarshalled according to their names and tags;
+// other fields, and those absent from the JSON, are left unchanged. The result
+// is built via WithMutable. Because json.Unmarshaler stores the result in
+// *s, s should be a new value rather than one shared with other code.
+func (s *BinV2) UnmarshalJSON(b []byte) error {
+	v := struct {
+		Name  string    `binary:"1"`
+		Email string    `binary:"3"`
+		Tags  *BinSlice `binary:"4"`
+	}{
+		Name:  s.field_Name,
+		Email: s.field_Email,
+		Tags:  s.field_Tags,
+	}
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*s = *s.WithMutable(func(si *BinV2) {
+		si.field_Name = v.Name
+		si.field_Email = v.Email
+		si.field_Tags = v.Tags
+	})
+
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler. The fields of the template
+// for BinV2 with a binary struct tag are encoded, each identified by its tag
+// number. See myitcv.io/immutable/wire for details of the format.
+func (s *BinV2) MarshalBinary() ([]byte, error) {
+	var e wire.Encoder
+
+	if s == nil {
+		return e.Result()
+	}
+
+	e.String(1, s.field_Name)
+	e.String(3, s.field_Email)
+	if s.field_Tags != nil {
+		e.Marshaler(4, s.field_Tags)
+	}
+
+	return e.Result()
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. Fields with tag
+// numbers unknown to the template for BinV2 are ignored, and fields of s
+// that are not present in b are left unchanged.
+func (s *BinV2) UnmarshalBinary(b []byte) error {
+	d := wire.NewDecoder(b)
+
+	for d.Next() {
+		switch d.Tag() {
+		case 1:
+			s.field_Name = d.String()
+		case 3:
+			s.field_Email = d.String()
+		case 4:
+			s.field_Tags = new(BinSlice)
+			d.Unmarshaler(s.field_Tags)
+		}
+	}
+
+	return d.Err()
+}
+
+func (s *BinV2) Email() string {
+	return s.field_Email
+}
+
+// SetEmail is the setter for Email()
+func (s *BinV2) SetEmail(n string) *BinV2 {
 	if s.mutable {
-		s.field_OtherName = n
+		s.field_Email = n
 		return s
 	}
 
 	res := *s
-	res.field_OtherName = n
+	res.field_Email = n
+	return &res
+}
+
+func (s *BinV2) Name() string {
+	return s.field_Name
+}
+
+// SetName is the setter for Name()
+func (s *BinV2) SetName(n string) *BinV2 {
+	if s.mutable {
+		s.field_Name = n
+		return s
+	}
+
+	res := *s
+	res.field_Name = n
+	return &res
+}
+
+func (s *BinV2) Tags() *BinSlice {
+	return s.field_Tags
+}
+
+// SetTags is the setter for Tags()
+func (s *BinV2) SetTags(n *BinSlice) *BinV2 {
+	if s.mutable {
+		s.field_Tags = n
+		return s
+	}
+
+	res := *s
+	res.field_Tags = n
 	return &res
 }
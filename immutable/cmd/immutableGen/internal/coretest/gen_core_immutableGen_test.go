@@ -8,18 +8,17 @@ package coretest_test
 //immutableVet:skipFile
 
 import (
+	"encoding/json"
 	"myitcv.io/immutable"
 )
 
-//
 // xtestA is an immutable type and has the following template:
 //
-// 	struct {
-// 		*XTestB
-//
-// 		age	int
-// 	}
+//	struct {
+//		*XTestB
 //
+//		age	int
+//	}
 type xtestA struct {
 	anonfield_XTestB *XTestB
 	field_age        int
@@ -102,6 +101,116 @@ func (s *xtestA) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	}
 	return true
 }
+
+// xtestADiff is the change set between two xtestA values, as returned by
+// xtestA.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set. Otherwise a non-nil
+// field holds either the new value of the corresponding field or, where the
+// field is itself of an immutable type, the change set for that field.
+type xtestADiff struct {
+	Replaced bool
+	Value    *xtestA
+
+	XTestB *XTestBDiff
+	age    *int
+}
+
+// Diff returns the change set required to turn s into other, or nil if there
+// is no difference.
+func (s *xtestA) Diff(other *xtestA) *xtestADiff {
+	if s == other {
+		return nil
+	}
+
+	if s == nil || other == nil {
+		return &xtestADiff{Replaced: true, Value: other}
+	}
+
+	var res xtestADiff
+	changed := false
+
+	if d := s.anonfield_XTestB.Diff(other.anonfield_XTestB); d != nil {
+		res.XTestB = d
+		changed = true
+	}
+
+	if s.field_age != other.field_age {
+		v := other.field_age
+		res.age = &v
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return &res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to s.
+func (s *xtestA) Patch(d *xtestADiff) *xtestA {
+	if d == nil {
+		return s
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if s == nil {
+		s = new(xtestA)
+	}
+
+	return s.WithMutable(func(si *xtestA) {
+		if d.XTestB != nil {
+			si.anonfield_XTestB = si.anonfield_XTestB.Patch(d.XTestB)
+		}
+		if d.age != nil {
+			si.field_age = *d.age
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. The exported fields of the template
+// for xtestA are marshalled according to their names and tags.
+func (s *xtestA) MarshalJSON() ([]byte, error) {
+	if s == nil {
+		return []byte("null"), nil
+	}
+
+	v := struct {
+		XTestB *XTestB
+	}{
+		XTestB: s.anonfield_XTestB,
+	}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The exported fields of the
+// template for xtestA are unmarshalled according to their names and tags;
+// other fields, and those absent from the JSON, are left unchanged. The result
+// is built via WithMutable. Because json.Unmarshaler stores the result in
+// *s, s should be a new value rather than one shared with other code.
+func (s *xtestA) UnmarshalJSON(b []byte) error {
+	v := struct {
+		XTestB *XTestB
+	}{
+		XTestB: s.anonfield_XTestB,
+	}
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*s = *s.WithMutable(func(si *xtestA) {
+		si.anonfield_XTestB = v.XTestB
+	})
+
+	return nil
+}
+
 func (s *xtestA) Name() string {
 	return s.XTestB().Name()
 }
@@ -110,6 +219,7 @@ func (s *xtestA) SetName(n string) *xtestA {
 	v0 := s.SetXTestB(v1)
 	return v0
 }
+
 func (s *xtestA) XTestB() *XTestB {
 	return s.anonfield_XTestB
 }
@@ -125,6 +235,7 @@ func (s *xtestA) SetXTestB(n *XTestB) *xtestA {
 	res.anonfield_XTestB = n
 	return &res
 }
+
 func (s *xtestA) age() int {
 	return s.field_age
 }
@@ -141,13 +252,11 @@ func (s *xtestA) setAge(n int) *xtestA {
 	return &res
 }
 
-//
 // XTestB is an immutable type and has the following template:
 //
-// 	struct {
-// 		Name string
-// 	}
-//
+//	struct {
+//		Name string
+//	}
 type XTestB struct {
 	field_Name string
 
@@ -222,6 +331,107 @@ func (s *XTestB) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	seen[s] = true
 	return true
 }
+
+// XTestBDiff is the change set between two XTestB values, as returned by
+// XTestB.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set. Otherwise a non-nil
+// field holds either the new value of the corresponding field or, where the
+// field is itself of an immutable type, the change set for that field.
+type XTestBDiff struct {
+	Replaced bool
+	Value    *XTestB
+
+	Name *string
+}
+
+// Diff returns the change set required to turn s into other, or nil if there
+// is no difference.
+func (s *XTestB) Diff(other *XTestB) *XTestBDiff {
+	if s == other {
+		return nil
+	}
+
+	if s == nil || other == nil {
+		return &XTestBDiff{Replaced: true, Value: other}
+	}
+
+	var res XTestBDiff
+	changed := false
+
+	if s.field_Name != other.field_Name {
+		v := other.field_Name
+		res.Name = &v
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return &res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to s.
+func (s *XTestB) Patch(d *XTestBDiff) *XTestB {
+	if d == nil {
+		return s
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if s == nil {
+		s = new(XTestB)
+	}
+
+	return s.WithMutable(func(si *XTestB) {
+		if d.Name != nil {
+			si.field_Name = *d.Name
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. The exported fields of the template
+// for XTestB are marshalled according to their names and tags.
+func (s *XTestB) MarshalJSON() ([]byte, error) {
+	if s == nil {
+		return []byte("null"), nil
+	}
+
+	v := struct {
+		Name string
+	}{
+		Name: s.field_Name,
+	}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The exported fields of the
+// template for XTestB are unmarshalled according to their names and tags;
+// other fields, and those absent from the JSON, are left unchanged. The result
+// is built via WithMutable. Because json.Unmarshaler stores the result in
+// *s, s should be a new value rather than one shared with other code.
+func (s *XTestB) UnmarshalJSON(b []byte) error {
+	v := struct {
+		Name string
+	}{
+		Name: s.field_Name,
+	}
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*s = *s.WithMutable(func(si *XTestB) {
+		si.field_Name = v.Name
+	})
+
+	return nil
+}
+
 func (s *XTestB) Name() string {
 	return s.field_Name
 }
@@ -8,6 +8,7 @@ package coretest
 //immutableVet:skipFile
 
 import (
+	"encoding/json"
 	"myitcv.io/immutable"
 )
 
@@ -15,8 +16,7 @@ import (
 //
 // MyTestMap is an immutable type and has the following template:
 //
-// 	map[string]int
-//
+//	map[string]int
 type MyTestMap struct {
 	theMap  map[string]int
 	mutable bool
@@ -167,12 +167,124 @@ func (s *MyTestMap) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	return true
 }
 
+// MyTestMapDiff is the change set between two MyTestMap values, as returned by
+// MyTestMap.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set.
+type MyTestMapDiff struct {
+	Replaced bool
+	Value    *MyTestMap
+
+	// Set holds the entries that were added or changed
+	Set map[string]int
+
+	// Del holds the keys of the entries that were deleted, in no particular
+	// order
+	Del []string
+}
+
+// Diff returns the change set required to turn m into other, or nil if there
+// is no difference.
+func (m *MyTestMap) Diff(other *MyTestMap) *MyTestMapDiff {
+	if m == other {
+		return nil
+	}
+
+	if m == nil || other == nil {
+		return &MyTestMapDiff{Replaced: true, Value: other}
+	}
+
+	res := &MyTestMapDiff{
+		Set: make(map[string]int),
+	}
+
+	for k := range m.Range() {
+		if _, ok := other.Get(k); !ok {
+			res.Del = append(res.Del, k)
+		}
+	}
+
+	for k, ov := range other.Range() {
+		v, ok := m.Get(k)
+		if !ok {
+			res.Set[k] = ov
+			continue
+		}
+
+		if v != ov {
+			res.Set[k] = ov
+		}
+	}
+
+	if len(res.Set) == 0 && len(res.Del) == 0 {
+		return nil
+	}
+
+	return res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to m.
+func (m *MyTestMap) Patch(d *MyTestMapDiff) *MyTestMap {
+	if d == nil {
+		return m
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if m == nil {
+		m = NewMyTestMap()
+	}
+
+	return m.WithMutable(func(mi *MyTestMap) {
+		for _, k := range d.Del {
+			mi.Del(k)
+		}
+
+		for k, v := range d.Set {
+			mi.Set(k, v)
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. m is marshalled as a JSON object.
+func (m *MyTestMap) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+
+	if m.Len() == 0 {
+		return []byte("{}"), nil
+	}
+
+	return json.Marshal(m.Range())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. m is set to an immutable map
+// containing the unmarshalled entries. Because *m is replaced, m should be a
+// new value rather than one shared with other code.
+func (m *MyTestMap) UnmarshalJSON(b []byte) error {
+	var v map[string]int
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*m = *NewMyTestMap(func(mi *MyTestMap) {
+		for k, e := range v {
+			mi.Set(k, e)
+		}
+	})
+
+	return nil
+}
+
 // a comment about Slice
 //
 // MyTestSlice is an immutable type and has the following template:
 //
-// 	[]*string
-//
+//	[]*string
 type MyTestSlice struct {
 	theSlice []*string
 	mutable  bool
@@ -305,6 +417,26 @@ func (m *MyTestSlice) Append(v ...*string) *MyTestSlice {
 
 	return res
 }
+
+func (m *MyTestSlice) Slice(i, j int) *MyTestSlice {
+	if m.mutable {
+		m.theSlice = m.theSlice[i:j]
+		return m
+	}
+
+	resSlice := make([]*string, j-i)
+	copy(resSlice, m.theSlice[i:j])
+
+	res := &MyTestSlice{
+		theSlice: resSlice,
+	}
+
+	return res
+}
+
+func (m *MyTestSlice) Concat(o *MyTestSlice) *MyTestSlice {
+	return m.Append(o.Range()...)
+}
 func (s *MyTestSlice) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	if s == nil {
 		return true
@@ -316,17 +448,124 @@ func (s *MyTestSlice) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	return true
 }
 
+// MyTestSliceDiff is the change set between two MyTestSlice values, as returned by
+// MyTestSlice.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set.
+type MyTestSliceDiff struct {
+	Replaced bool
+	Value    *MyTestSlice
+
+	// Len is the length of the new value; elements beyond Len are truncated
+	// and elements are appended to reach Len as required
+	Len int
+
+	// Set holds the elements, by index, that were added or changed
+	Set map[int]*string
+}
+
+// Diff returns the change set required to turn m into other, or nil if there
+// is no difference. Elements are compared by index.
+func (m *MyTestSlice) Diff(other *MyTestSlice) *MyTestSliceDiff {
+	if m == other {
+		return nil
+	}
+
+	if m == nil || other == nil {
+		return &MyTestSliceDiff{Replaced: true, Value: other}
+	}
+
+	res := &MyTestSliceDiff{
+		Len: other.Len(),
+		Set: make(map[int]*string),
+	}
+
+	for i := 0; i < other.Len(); i++ {
+		ov := other.Get(i)
+		if i >= m.Len() {
+			res.Set[i] = ov
+			continue
+		}
+
+		v := m.Get(i)
+
+		if v != ov {
+			res.Set[i] = ov
+		}
+	}
+
+	if m.Len() == other.Len() && len(res.Set) == 0 {
+		return nil
+	}
+
+	return res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to m.
+func (m *MyTestSlice) Patch(d *MyTestSliceDiff) *MyTestSlice {
+	if d == nil {
+		return m
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if m == nil {
+		m = new(MyTestSlice)
+	}
+
+	return m.WithMutable(func(mi *MyTestSlice) {
+		if l := mi.Len(); l > d.Len {
+			mi.Slice(0, d.Len)
+		} else if l < d.Len {
+			mi.Append(make([]*string, d.Len-l)...)
+		}
+
+		for i, v := range d.Set {
+			mi.Set(i, v)
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. m is marshalled as a JSON array.
+func (m *MyTestSlice) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+
+	if m.Len() == 0 {
+		return []byte("[]"), nil
+	}
+
+	return json.Marshal(m.Range())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. m is set to an immutable slice
+// containing the unmarshalled elements. Because *m is replaced, m should be a
+// new value rather than one shared with other code.
+func (m *MyTestSlice) UnmarshalJSON(b []byte) error {
+	var v []*string
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*m = *NewMyTestSlice(v...)
+
+	return nil
+}
+
 // a comment about myStruct
 //
 // MyTestStruct is an immutable type and has the following template:
 //
-// 	struct {
-// 		Name, surname	string
-// 		age		int
-//
-// 		fieldWithoutTag	bool
-// 	}
+//	struct {
+//		Name, surname	string
+//		age		int
 //
+//		fieldWithoutTag	bool
+//	}
 type MyTestStruct struct {
 	//somethingspecial
 
@@ -407,6 +646,136 @@ func (s *MyTestStruct) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	return true
 }
 
+// MyTestStructDiff is the change set between two MyTestStruct values, as returned by
+// MyTestStruct.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set. Otherwise a non-nil
+// field holds either the new value of the corresponding field or, where the
+// field is itself of an immutable type, the change set for that field.
+type MyTestStructDiff struct {
+	Replaced bool
+	Value    *MyTestStruct
+
+	Name            *string
+	surname         *string
+	age             *int
+	fieldWithoutTag *bool
+}
+
+// Diff returns the change set required to turn s into other, or nil if there
+// is no difference.
+func (s *MyTestStruct) Diff(other *MyTestStruct) *MyTestStructDiff {
+	if s == other {
+		return nil
+	}
+
+	if s == nil || other == nil {
+		return &MyTestStructDiff{Replaced: true, Value: other}
+	}
+
+	var res MyTestStructDiff
+	changed := false
+
+	if s.field_Name != other.field_Name {
+		v := other.field_Name
+		res.Name = &v
+		changed = true
+	}
+
+	if s.field_surname != other.field_surname {
+		v := other.field_surname
+		res.surname = &v
+		changed = true
+	}
+
+	if s.field_age != other.field_age {
+		v := other.field_age
+		res.age = &v
+		changed = true
+	}
+
+	if s.field_fieldWithoutTag != other.field_fieldWithoutTag {
+		v := other.field_fieldWithoutTag
+		res.fieldWithoutTag = &v
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return &res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to s.
+func (s *MyTestStruct) Patch(d *MyTestStructDiff) *MyTestStruct {
+	if d == nil {
+		return s
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if s == nil {
+		s = new(MyTestStruct)
+	}
+
+	return s.WithMutable(func(si *MyTestStruct) {
+		if d.Name != nil {
+			si.field_Name = *d.Name
+		}
+		if d.surname != nil {
+			si.field_surname = *d.surname
+		}
+		if d.age != nil {
+			si.field_age = *d.age
+		}
+		if d.fieldWithoutTag != nil {
+			si.field_fieldWithoutTag = *d.fieldWithoutTag
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. The exported fields of the template
+// for MyTestStruct are marshalled according to their names and tags.
+func (s *MyTestStruct) MarshalJSON() ([]byte, error) {
+	if s == nil {
+		return []byte("null"), nil
+	}
+
+	v := struct {
+		Name string `tag:"value"`
+	}{
+		Name: s.field_Name,
+	}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The exported fields of the
+// template for MyTestStruct are unmarshalled according to their names and tags;
+// other fields, and those absent from the JSON, are left unchanged. The result
+// is built via WithMutable. Because json.Unmarshaler stores the result in
+// *s, s should be a new value rather than one shared with other code.
+func (s *MyTestStruct) UnmarshalJSON(b []byte) error {
+	v := struct {
+		Name string `tag:"value"`
+	}{
+		Name: s.field_Name,
+	}
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*s = *s.WithMutable(func(si *MyTestStruct) {
+		si.field_Name = v.Name
+	})
+
+	return nil
+}
+
 // my field comment
 //somethingspecial
 /*
@@ -429,6 +798,7 @@ func (s *MyTestStruct) SetName(n string) *MyTestStruct {
 	res.field_Name = n
 	return &res
 }
+
 func (s *MyTestStruct) age() int {
 	return s.field_age
 }
@@ -444,6 +814,7 @@ func (s *MyTestStruct) setAge(n int) *MyTestStruct {
 	res.field_age = n
 	return &res
 }
+
 func (s *MyTestStruct) fieldWithoutTag() bool {
 	return s.field_fieldWithoutTag
 }
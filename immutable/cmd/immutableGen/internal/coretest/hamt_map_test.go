@@ -0,0 +1,203 @@
+package coretest_test
+
+import (
+	"fmt"
+	"testing"
+
+	"myitcv.io/immutable/cmd/immutableGen/internal/coretest"
+)
+
+func TestMyHamtMapWithMutableImmutableReceiver(t *testing.T) {
+	wasMutable := false
+
+	var s3 *coretest.MyHamtMap
+
+	s1 := coretest.NewMyHamtMap()
+	s2 := s1.WithMutable(func(s *coretest.MyHamtMap) {
+		wasMutable = s.Mutable()
+
+		// have some side effect
+		s.Set(peter, age42)
+
+		s3 = s
+	})
+
+	if s1 == s2 {
+		t.Fatalf("s1 and s2 should be different values; they are not")
+	}
+
+	if s3 != s2 {
+		t.Fatalf("s3 and s2 should be same values; they were not")
+	}
+
+	if !wasMutable {
+		t.Fatalf("s should have been mutable; it was not")
+	}
+
+	if s2.Mutable() {
+		t.Fatalf("s2 should not be mutable")
+	}
+
+	if v := s1.Len(); v != 0 {
+		t.Fatalf("length of s1 should be 0; got %v", v)
+	}
+
+	if v, ok := s2.Get(peter); !ok || v != age42 {
+		t.Fatalf("expected s2.Get(%q) to be (%v, %v); got (%v, %v)", peter, age42, true, v, ok)
+	}
+}
+
+func TestMyHamtMapAsMutableMutableReceiver(t *testing.T) {
+	s1 := coretest.NewMyHamtMap().AsMutable()
+	s2 := s1.AsMutable()
+
+	if s1 != s2 {
+		t.Fatalf("s1 and s2 should not be different values; they are")
+	}
+
+	if !s2.Mutable() {
+		t.Fatalf("s2 should be mutable; it is not")
+	}
+}
+
+func TestMyHamtMapPlainConstructorWithInitialiser(t *testing.T) {
+	s1 := coretest.NewMyHamtMap(func(m *coretest.MyHamtMap) {
+		m.Set(peter, age42)
+	})
+
+	if v := s1.Len(); v != 1 {
+		t.Fatalf("length of s1 should be 1; got %v", v)
+	}
+
+	if s1.Mutable() {
+		t.Fatalf("s1 should not be mutable; it is")
+	}
+
+	if v, ok := s1.Get(peter); !ok || v != age42 {
+		t.Fatalf("s1.Get(%q) should be (%v, %v); got (%v, %v)", peter, age42, true, v, ok)
+	}
+}
+
+func TestMyHamtMapSetImmutableReceiver(t *testing.T) {
+	s1 := coretest.NewMyHamtMap()
+	s2 := s1.Set(peter, age42)
+
+	if s1 == s2 {
+		t.Fatalf("s1 and s2 should be different values; they are not")
+	}
+
+	if v, ok := s1.Get(peter); ok {
+		t.Fatalf("expected s1 to not contain %q; but it did with value %v", peter, v)
+	}
+
+	if v, ok := s2.Get(peter); !ok || v != age42 {
+		t.Fatalf("expected s2.Get(%q) to be (%v, %v); got (%v, %v)", peter, age42, true, v, ok)
+	}
+
+	if s2.Mutable() {
+		t.Fatalf("s2 should not be mutable")
+	}
+}
+
+func TestMyHamtMapSetMutableReceiver(t *testing.T) {
+	s1 := coretest.NewMyHamtMap().AsMutable()
+	s2 := s1.Set(peter, age42)
+
+	if s1 != s2 {
+		t.Fatalf("s1 and s2 should be sames values; they are not")
+	}
+
+	if v, ok := s2.Get(peter); !ok || v != age42 {
+		t.Fatalf("expected s2.Get(%q) to be (%v, %v); got (%v, %v)", peter, age42, true, v, ok)
+	}
+}
+
+func TestMyHamtMapDel(t *testing.T) {
+	s1 := coretest.NewMyHamtMap().Set(peter, age42)
+
+	if s2 := s1.Del(paul); s1 != s2 {
+		t.Fatalf("deleting a missing key should return the receiver")
+	}
+
+	s3 := s1.Del(peter)
+
+	if s1 == s3 {
+		t.Fatalf("s1 and s3 should be different values; they are not")
+	}
+
+	if v := s1.Len(); v != 1 {
+		t.Fatalf("length of s1 should be 1; got %v", v)
+	}
+
+	if v := s3.Len(); v != 0 {
+		t.Fatalf("length of s3 should be 0; got %v", v)
+	}
+}
+
+func TestMyHamtMapRange(t *testing.T) {
+	const n = 1000
+
+	var versions []*coretest.MyHamtMap
+
+	s := coretest.NewMyHamtMap()
+	for i := 0; i < n; i++ {
+		s = s.Set(fmt.Sprint(i), i)
+		versions = append(versions, s)
+	}
+
+	for i, v := range versions {
+		if l := v.Len(); l != i+1 {
+			t.Fatalf("version %v should have length %v; got %v", i, i+1, l)
+		}
+	}
+
+	r := s.Range()
+	if len(r) != n {
+		t.Fatalf("expected Range to return %v entries; got %v", n, len(r))
+	}
+
+	for k, v := range r {
+		if k != fmt.Sprint(v) {
+			t.Fatalf("unexpected entry %q => %v", k, v)
+		}
+	}
+
+	count := 0
+	s.RangeFunc(func(k string, v int) bool {
+		count++
+		return count < 10
+	})
+
+	if count != 10 {
+		t.Fatalf("expected RangeFunc to stop after 10 entries; got %v", count)
+	}
+}
+
+func TestHamtMapDeep(t *testing.T) {
+	m1 := coretest.NewAHM()
+
+	if !m1.IsDeeplyNonMutable(nil) {
+		t.Fatalf("m1 should be DeeplyNonMutable")
+	}
+
+	aimm := new(coretest.A)
+
+	m2 := coretest.NewAHM().Set(aimm, aimm)
+
+	if !m2.IsDeeplyNonMutable(nil) {
+		t.Fatalf("m2 should be DeeplyNonMutable")
+	}
+
+	amut := new(coretest.A).AsMutable()
+
+	m3 := coretest.NewAHM().Set(nil, amut)
+	m4 := coretest.NewAHM().Set(amut, nil)
+
+	if m3.IsDeeplyNonMutable(nil) {
+		t.Fatalf("m3 should not be DeeplyNonMutable")
+	}
+
+	if m4.IsDeeplyNonMutable(nil) {
+		t.Fatalf("m4 should not be DeeplyNonMutable")
+	}
+}
@@ -0,0 +1,191 @@
+package coretest_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"myitcv.io/immutable/cmd/immutableGen/internal/coretest"
+)
+
+func TestStructJSON(t *testing.T) {
+	s1 := new(coretest.MyStruct).SetKey(coretest.MyStructKey{Uuid: 5}).SetName(peter)
+
+	b, err := json.Marshal(s1)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+
+	// only exported template fields are marshalled, according to their tags
+	exp := `{"Key":{"Uuid":5,"Version":1},"Name":"peter"}`
+	if string(b) != exp {
+		t.Fatalf("expected %v; got %v", exp, string(b))
+	}
+
+	var s2 *coretest.MyStruct
+	if err := json.Unmarshal(b, &s2); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+
+	if d := s1.Diff(s2); d != nil {
+		t.Fatalf("expected round trip to give an equal value; got diff %+v", d)
+	}
+
+	if s2.Mutable() {
+		t.Fatalf("unmarshalled value should not be mutable")
+	}
+
+	// fields absent from the JSON are unchanged, and the Key is given a new
+	// Version unless the JSON sets it
+	s3 := new(coretest.MyStruct).SetKey(coretest.MyStructKey{Uuid: 5}).SetName(peter)
+
+	s4 := *s3
+	if err := json.Unmarshal([]byte(`{"Name":"paul"}`), &s4); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+
+	if exp := (coretest.MyStructKey{Uuid: 5, Version: 2}); s4.Name() != paul || s4.Key() != exp {
+		t.Fatalf("expected Name %q and Key %v; got %q and %v", paul, exp, s4.Name(), s4.Key())
+	}
+
+	s5 := *s3
+	if err := json.Unmarshal([]byte(`{"Key":{"Uuid":6}}`), &s5); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+
+	if exp := (coretest.MyStructKey{Uuid: 6, Version: 1}); s5.Name() != peter || s5.Key() != exp {
+		t.Fatalf("expected Name %q and Key %v; got %q and %v", peter, exp, s5.Name(), s5.Key())
+	}
+
+	if s3.Name() != peter || s3.Key().Version != 1 {
+		t.Fatalf("s3 should be unchanged by unmarshalling into a copy")
+	}
+}
+
+func TestNestedStructJSON(t *testing.T) {
+	a1 := new(coretest.A).SetName(paul).SetA(new(coretest.A).SetName(peter))
+
+	b, err := json.Marshal(a1)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+
+	exp := `{"Name":"paul","A":{"Name":"peter","A":null,"Blah":null},"Blah":null}`
+	if string(b) != exp {
+		t.Fatalf("expected %v; got %v", exp, string(b))
+	}
+
+	a2 := new(coretest.A)
+	if err := json.Unmarshal(b, a2); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+
+	if v := a2.A().Name(); v != peter {
+		t.Fatalf("expected nested Name to be %q; got %q", peter, v)
+	}
+}
+
+func TestMapJSON(t *testing.T) {
+	m1 := coretest.NewMyMap(func(m *coretest.MyMap) {
+		m.Set(paul, 1)
+		m.Set(peter, age42)
+	})
+
+	b, err := json.Marshal(m1)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+
+	exp := `{"paul":1,"peter":42}`
+	if string(b) != exp {
+		t.Fatalf("expected %v; got %v", exp, string(b))
+	}
+
+	var m2 *coretest.MyMap
+	if err := json.Unmarshal(b, &m2); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+
+	if d := m1.Diff(m2); d != nil {
+		t.Fatalf("expected round trip to give an equal value; got diff %+v", d)
+	}
+
+	if b, _ := json.Marshal(coretest.NewMyHamtMap()); string(b) != "{}" {
+		t.Fatalf("expected empty map to marshal as {}; got %v", string(b))
+	}
+}
+
+func TestNonStringKeyMapJSON(t *testing.T) {
+	m1 := coretest.NewAM().Set(new(coretest.A).SetName(paul), new(coretest.A).SetName(peter))
+
+	b, err := json.Marshal(m1)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+
+	exp := `[{"Key":{"Name":"paul","A":null,"Blah":null},"Value":{"Name":"peter","A":null,"Blah":null}}]`
+	if string(b) != exp {
+		t.Fatalf("expected %v; got %v", exp, string(b))
+	}
+
+	var m2 *coretest.AM
+	if err := json.Unmarshal(b, &m2); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+
+	for k, v := range m2.Range() {
+		if k.Name() != paul || v.Name() != peter {
+			t.Fatalf("unexpected entry %v => %v", k.Name(), v.Name())
+		}
+	}
+}
+
+func TestTextKeyMapJSON(t *testing.T) {
+	m1 := coretest.NewTextKeyMap().Set(coretest.TextKey{First: paul, Last: peter}, age42)
+
+	b, err := json.Marshal(m1)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+
+	// keys that implement encoding.TextMarshaler are encoded as a JSON object
+	exp := `{"paul peter":42}`
+	if string(b) != exp {
+		t.Fatalf("expected %v; got %v", exp, string(b))
+	}
+
+	var m2 *coretest.TextKeyMap
+	if err := json.Unmarshal(b, &m2); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+
+	if d := m1.Diff(m2); d != nil {
+		t.Fatalf("expected round trip to give an equal value; got diff %+v", d)
+	}
+}
+
+func TestSliceJSON(t *testing.T) {
+	s1 := coretest.NewMyVectorSlice(paul, peter)
+
+	b, err := json.Marshal(s1)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+
+	exp := `["paul","peter"]`
+	if string(b) != exp {
+		t.Fatalf("expected %v; got %v", exp, string(b))
+	}
+
+	var s2 *coretest.MyVectorSlice
+	if err := json.Unmarshal(b, &s2); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+
+	if d := s1.Diff(s2); d != nil {
+		t.Fatalf("expected round trip to give an equal value; got diff %+v", d)
+	}
+
+	if b, _ := json.Marshal(new(coretest.MySlice)); string(b) != "[]" {
+		t.Fatalf("expected empty slice to marshal as []; got %v", string(b))
+	}
+}
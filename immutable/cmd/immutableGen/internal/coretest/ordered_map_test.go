@@ -0,0 +1,129 @@
+package coretest_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"myitcv.io/immutable/cmd/immutableGen/internal/coretest"
+)
+
+func TestMyOrderedMapInsertionOrder(t *testing.T) {
+	m1 := coretest.NewMyOrderedMap(func(m *coretest.MyOrderedMap) {
+		m.Set(peter, age42)
+		m.Set(paul, 1)
+		m.Set("john", 2)
+	})
+
+	if v := m1.Keys(); !reflect.DeepEqual(v, []string{peter, paul, "john"}) {
+		t.Fatalf("unexpected keys %v", v)
+	}
+
+	if v := m1.Values(); !reflect.DeepEqual(v, []int{age42, 1, 2}) {
+		t.Fatalf("unexpected values %v", v)
+	}
+
+	// setting an existing key does not change the order
+	m2 := m1.Set(peter, 3).Del(paul).Set(paul, 4)
+
+	if v := m2.Keys(); !reflect.DeepEqual(v, []string{peter, "john", paul}) {
+		t.Fatalf("unexpected keys %v", v)
+	}
+
+	if v := m1.Keys(); !reflect.DeepEqual(v, []string{peter, paul, "john"}) {
+		t.Fatalf("m1 should be unchanged; got keys %v", v)
+	}
+
+	if k, v, ok := m2.First(); !ok || k != peter || v != 3 {
+		t.Fatalf("unexpected First %v, %v, %v", k, v, ok)
+	}
+
+	if k, v, ok := m2.Last(); !ok || k != paul || v != 4 {
+		t.Fatalf("unexpected Last %v, %v, %v", k, v, ok)
+	}
+
+	if _, _, ok := coretest.NewMyOrderedMap().First(); ok {
+		t.Fatalf("expected no First entry for an empty map")
+	}
+
+	var keys []string
+	m2.RangeFunc(func(k string, v int) bool {
+		keys = append(keys, k)
+		return len(keys) < 2
+	})
+
+	if !reflect.DeepEqual(keys, []string{peter, "john"}) {
+		t.Fatalf("unexpected RangeFunc keys %v", keys)
+	}
+}
+
+func TestMyOrderedMapJSON(t *testing.T) {
+	m1 := coretest.NewMyOrderedMap().Set(peter, age42).Set(paul, 1)
+
+	b, err := json.Marshal(m1)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+
+	exp := `[{"Key":"peter","Value":42},{"Key":"paul","Value":1}]`
+	if string(b) != exp {
+		t.Fatalf("expected %v; got %v", exp, string(b))
+	}
+
+	var m2 *coretest.MyOrderedMap
+	if err := json.Unmarshal(b, &m2); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+
+	if v := m2.Keys(); !reflect.DeepEqual(v, m1.Keys()) {
+		t.Fatalf("expected keys %v; got %v", m1.Keys(), v)
+	}
+}
+
+func TestMySortedMap(t *testing.T) {
+	m := coretest.NewMySortedMap(func(m *coretest.MySortedMap) {
+		for _, k := range []int{30, 10, 50, 20, 40} {
+			m.Set(k, "")
+		}
+		m.Del(40)
+	})
+
+	if v := m.Keys(); !reflect.DeepEqual(v, []int{10, 20, 30, 50}) {
+		t.Fatalf("unexpected keys %v", v)
+	}
+
+	if k, _, ok := m.First(); !ok || k != 10 {
+		t.Fatalf("unexpected First %v, %v", k, ok)
+	}
+
+	if k, _, ok := m.Last(); !ok || k != 50 {
+		t.Fatalf("unexpected Last %v, %v", k, ok)
+	}
+
+	type lookup struct {
+		k  int
+		ok bool
+	}
+
+	cases := []struct {
+		k       int
+		floor   lookup
+		ceiling lookup
+	}{
+		{5, lookup{0, false}, lookup{10, true}},
+		{10, lookup{10, true}, lookup{10, true}},
+		{25, lookup{20, true}, lookup{30, true}},
+		{40, lookup{30, true}, lookup{50, true}},
+		{60, lookup{50, true}, lookup{0, false}},
+	}
+
+	for _, c := range cases {
+		if k, _, ok := m.Floor(c.k); (lookup{k, ok}) != c.floor {
+			t.Errorf("Floor(%v): expected %v; got %v", c.k, c.floor, lookup{k, ok})
+		}
+
+		if k, _, ok := m.Ceiling(c.k); (lookup{k, ok}) != c.ceiling {
+			t.Errorf("Ceiling(%v): expected %v; got %v", c.k, c.ceiling, lookup{k, ok})
+		}
+	}
+}
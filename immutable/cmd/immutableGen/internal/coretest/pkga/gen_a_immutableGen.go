@@ -5,19 +5,18 @@ package pkga
 //immutableVet:skipFile
 
 import (
+	"encoding/json"
 	"myitcv.io/immutable"
 
 	"myitcv.io/immutable/cmd/immutableGen/internal/coretest/pkgb"
 )
 
-//
 // PkgA is an immutable type and has the following template:
 //
-// 	struct {
-// 		*pkgb.PkgB
-// 		Address	string
-// 	}
-//
+//	struct {
+//		*pkgb.PkgB
+//		Address	string
+//	}
 type PkgA struct {
 	anonfield_PkgB *pkgb.PkgB
 	field_Address  string
@@ -100,6 +99,121 @@ func (s *PkgA) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	}
 	return true
 }
+
+// PkgADiff is the change set between two PkgA values, as returned by
+// PkgA.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set. Otherwise a non-nil
+// field holds either the new value of the corresponding field or, where the
+// field is itself of an immutable type, the change set for that field.
+type PkgADiff struct {
+	Replaced bool
+	Value    *PkgA
+
+	PkgB    *pkgb.PkgBDiff
+	Address *string
+}
+
+// Diff returns the change set required to turn s into other, or nil if there
+// is no difference.
+func (s *PkgA) Diff(other *PkgA) *PkgADiff {
+	if s == other {
+		return nil
+	}
+
+	if s == nil || other == nil {
+		return &PkgADiff{Replaced: true, Value: other}
+	}
+
+	var res PkgADiff
+	changed := false
+
+	if d := s.anonfield_PkgB.Diff(other.anonfield_PkgB); d != nil {
+		res.PkgB = d
+		changed = true
+	}
+
+	if s.field_Address != other.field_Address {
+		v := other.field_Address
+		res.Address = &v
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return &res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to s.
+func (s *PkgA) Patch(d *PkgADiff) *PkgA {
+	if d == nil {
+		return s
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if s == nil {
+		s = new(PkgA)
+	}
+
+	return s.WithMutable(func(si *PkgA) {
+		if d.PkgB != nil {
+			si.anonfield_PkgB = si.anonfield_PkgB.Patch(d.PkgB)
+		}
+		if d.Address != nil {
+			si.field_Address = *d.Address
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. The exported fields of the template
+// for PkgA are marshalled according to their names and tags.
+func (s *PkgA) MarshalJSON() ([]byte, error) {
+	if s == nil {
+		return []byte("null"), nil
+	}
+
+	v := struct {
+		PkgB    *pkgb.PkgB
+		Address string
+	}{
+		PkgB:    s.anonfield_PkgB,
+		Address: s.field_Address,
+	}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The exported fields of the
+// template for PkgA are unmarshalled according to their names and tags;
+// other fields, and those absent from the JSON, are left unchanged. The result
+// is built via WithMutable. Because json.Unmarshaler stores the result in
+// *s, s should be a new value rather than one shared with other code.
+func (s *PkgA) UnmarshalJSON(b []byte) error {
+	v := struct {
+		PkgB    *pkgb.PkgB
+		Address string
+	}{
+		PkgB:    s.anonfield_PkgB,
+		Address: s.field_Address,
+	}
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*s = *s.WithMutable(func(si *PkgA) {
+		si.anonfield_PkgB = v.PkgB
+		si.field_Address = v.Address
+	})
+
+	return nil
+}
+
 func (s *PkgA) Address() string {
 	return s.field_Address
 }
@@ -115,6 +229,7 @@ func (s *PkgA) SetAddress(n string) *PkgA {
 	res.field_Address = n
 	return &res
 }
+
 func (s *PkgA) PkgB() *pkgb.PkgB {
 	return s.anonfield_PkgB
 }
@@ -130,6 +245,7 @@ func (s *PkgA) SetPkgB(n *pkgb.PkgB) *PkgA {
 	res.anonfield_PkgB = n
 	return &res
 }
+
 func (s *PkgA) Postcode() string {
 	return s.PkgB().Postcode()
 }
@@ -139,14 +255,12 @@ func (s *PkgA) SetPostcode(n string) *PkgA {
 	return v0
 }
 
-//
 // Clash2 is an immutable type and has the following template:
 //
-// 	struct {
-// 		Clash		string
-// 		NoClash2	string
-// 	}
-//
+//	struct {
+//		Clash		string
+//		NoClash2	string
+//	}
 type Clash2 struct {
 	field_Clash    string
 	field_NoClash2 string
@@ -222,6 +336,122 @@ func (s *Clash2) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	seen[s] = true
 	return true
 }
+
+// Clash2Diff is the change set between two Clash2 values, as returned by
+// Clash2.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set. Otherwise a non-nil
+// field holds either the new value of the corresponding field or, where the
+// field is itself of an immutable type, the change set for that field.
+type Clash2Diff struct {
+	Replaced bool
+	Value    *Clash2
+
+	Clash    *string
+	NoClash2 *string
+}
+
+// Diff returns the change set required to turn s into other, or nil if there
+// is no difference.
+func (s *Clash2) Diff(other *Clash2) *Clash2Diff {
+	if s == other {
+		return nil
+	}
+
+	if s == nil || other == nil {
+		return &Clash2Diff{Replaced: true, Value: other}
+	}
+
+	var res Clash2Diff
+	changed := false
+
+	if s.field_Clash != other.field_Clash {
+		v := other.field_Clash
+		res.Clash = &v
+		changed = true
+	}
+
+	if s.field_NoClash2 != other.field_NoClash2 {
+		v := other.field_NoClash2
+		res.NoClash2 = &v
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return &res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to s.
+func (s *Clash2) Patch(d *Clash2Diff) *Clash2 {
+	if d == nil {
+		return s
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if s == nil {
+		s = new(Clash2)
+	}
+
+	return s.WithMutable(func(si *Clash2) {
+		if d.Clash != nil {
+			si.field_Clash = *d.Clash
+		}
+		if d.NoClash2 != nil {
+			si.field_NoClash2 = *d.NoClash2
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. The exported fields of the template
+// for Clash2 are marshalled according to their names and tags.
+func (s *Clash2) MarshalJSON() ([]byte, error) {
+	if s == nil {
+		return []byte("null"), nil
+	}
+
+	v := struct {
+		Clash    string
+		NoClash2 string
+	}{
+		Clash:    s.field_Clash,
+		NoClash2: s.field_NoClash2,
+	}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The exported fields of the
+// template for Clash2 are unmarshalled according to their names and tags;
+// other fields, and those absent from the JSON, are left unchanged. The result
+// is built via WithMutable. Because json.Unmarshaler stores the result in
+// *s, s should be a new value rather than one shared with other code.
+func (s *Clash2) UnmarshalJSON(b []byte) error {
+	v := struct {
+		Clash    string
+		NoClash2 string
+	}{
+		Clash:    s.field_Clash,
+		NoClash2: s.field_NoClash2,
+	}
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*s = *s.WithMutable(func(si *Clash2) {
+		si.field_Clash = v.Clash
+		si.field_NoClash2 = v.NoClash2
+	})
+
+	return nil
+}
+
 func (s *Clash2) Clash() string {
 	return s.field_Clash
 }
@@ -237,6 +467,7 @@ func (s *Clash2) SetClash(n string) *Clash2 {
 	res.field_Clash = n
 	return &res
 }
+
 func (s *Clash2) NoClash2() string {
 	return s.field_NoClash2
 }
@@ -253,13 +484,11 @@ func (s *Clash2) SetNoClash2(n string) *Clash2 {
 	return &res
 }
 
-//
 // OtherA is an immutable type and has the following template:
 //
-// 	struct {
-// 		OtherNameA string
-// 	}
-//
+//	struct {
+//		OtherNameA string
+//	}
 type OtherA struct {
 	field_OtherNameA string
 
@@ -334,6 +563,107 @@ func (s *OtherA) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	seen[s] = true
 	return true
 }
+
+// OtherADiff is the change set between two OtherA values, as returned by
+// OtherA.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set. Otherwise a non-nil
+// field holds either the new value of the corresponding field or, where the
+// field is itself of an immutable type, the change set for that field.
+type OtherADiff struct {
+	Replaced bool
+	Value    *OtherA
+
+	OtherNameA *string
+}
+
+// Diff returns the change set required to turn s into other, or nil if there
+// is no difference.
+func (s *OtherA) Diff(other *OtherA) *OtherADiff {
+	if s == other {
+		return nil
+	}
+
+	if s == nil || other == nil {
+		return &OtherADiff{Replaced: true, Value: other}
+	}
+
+	var res OtherADiff
+	changed := false
+
+	if s.field_OtherNameA != other.field_OtherNameA {
+		v := other.field_OtherNameA
+		res.OtherNameA = &v
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return &res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to s.
+func (s *OtherA) Patch(d *OtherADiff) *OtherA {
+	if d == nil {
+		return s
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if s == nil {
+		s = new(OtherA)
+	}
+
+	return s.WithMutable(func(si *OtherA) {
+		if d.OtherNameA != nil {
+			si.field_OtherNameA = *d.OtherNameA
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. The exported fields of the template
+// for OtherA are marshalled according to their names and tags.
+func (s *OtherA) MarshalJSON() ([]byte, error) {
+	if s == nil {
+		return []byte("null"), nil
+	}
+
+	v := struct {
+		OtherNameA string
+	}{
+		OtherNameA: s.field_OtherNameA,
+	}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The exported fields of the
+// template for OtherA are unmarshalled according to their names and tags;
+// other fields, and those absent from the JSON, are left unchanged. The result
+// is built via WithMutable. Because json.Unmarshaler stores the result in
+// *s, s should be a new value rather than one shared with other code.
+func (s *OtherA) UnmarshalJSON(b []byte) error {
+	v := struct {
+		OtherNameA string
+	}{
+		OtherNameA: s.field_OtherNameA,
+	}
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*s = *s.WithMutable(func(si *OtherA) {
+		si.field_OtherNameA = v.OtherNameA
+	})
+
+	return nil
+}
+
 func (s *OtherA) OtherNameA() string {
 	return s.field_OtherNameA
 }
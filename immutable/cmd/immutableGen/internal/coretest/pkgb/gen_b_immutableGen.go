@@ -5,16 +5,15 @@ package pkgb
 //immutableVet:skipFile
 
 import (
+	"encoding/json"
 	"myitcv.io/immutable"
 )
 
-//
 // PkgB is an immutable type and has the following template:
 //
-// 	struct {
-// 		Postcode string
-// 	}
-//
+//	struct {
+//		Postcode string
+//	}
 type PkgB struct {
 	field_Postcode string
 
@@ -89,6 +88,107 @@ func (s *PkgB) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	seen[s] = true
 	return true
 }
+
+// PkgBDiff is the change set between two PkgB values, as returned by
+// PkgB.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set. Otherwise a non-nil
+// field holds either the new value of the corresponding field or, where the
+// field is itself of an immutable type, the change set for that field.
+type PkgBDiff struct {
+	Replaced bool
+	Value    *PkgB
+
+	Postcode *string
+}
+
+// Diff returns the change set required to turn s into other, or nil if there
+// is no difference.
+func (s *PkgB) Diff(other *PkgB) *PkgBDiff {
+	if s == other {
+		return nil
+	}
+
+	if s == nil || other == nil {
+		return &PkgBDiff{Replaced: true, Value: other}
+	}
+
+	var res PkgBDiff
+	changed := false
+
+	if s.field_Postcode != other.field_Postcode {
+		v := other.field_Postcode
+		res.Postcode = &v
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return &res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to s.
+func (s *PkgB) Patch(d *PkgBDiff) *PkgB {
+	if d == nil {
+		return s
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if s == nil {
+		s = new(PkgB)
+	}
+
+	return s.WithMutable(func(si *PkgB) {
+		if d.Postcode != nil {
+			si.field_Postcode = *d.Postcode
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. The exported fields of the template
+// for PkgB are marshalled according to their names and tags.
+func (s *PkgB) MarshalJSON() ([]byte, error) {
+	if s == nil {
+		return []byte("null"), nil
+	}
+
+	v := struct {
+		Postcode string
+	}{
+		Postcode: s.field_Postcode,
+	}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The exported fields of the
+// template for PkgB are unmarshalled according to their names and tags;
+// other fields, and those absent from the JSON, are left unchanged. The result
+// is built via WithMutable. Because json.Unmarshaler stores the result in
+// *s, s should be a new value rather than one shared with other code.
+func (s *PkgB) UnmarshalJSON(b []byte) error {
+	v := struct {
+		Postcode string
+	}{
+		Postcode: s.field_Postcode,
+	}
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*s = *s.WithMutable(func(si *PkgB) {
+		si.field_Postcode = v.Postcode
+	})
+
+	return nil
+}
+
 func (s *PkgB) Postcode() string {
 	return s.field_Postcode
 }
@@ -0,0 +1,154 @@
+package coretest_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"myitcv.io/immutable/cmd/immutableGen/internal/coretest"
+)
+
+func TestMySetWithMutableImmutableReceiver(t *testing.T) {
+	wasMutable := false
+
+	var s3 *coretest.MySet
+
+	s1 := coretest.NewMySet()
+	s2 := s1.WithMutable(func(s *coretest.MySet) {
+		wasMutable = s.Mutable()
+
+		// have some side effect
+		s.Add(peter)
+
+		s3 = s
+	})
+
+	if s1 == s2 {
+		t.Fatalf("s1 and s2 should be different values; they are not")
+	}
+
+	if s3 != s2 {
+		t.Fatalf("s3 and s2 should be same values; they were not")
+	}
+
+	if !wasMutable {
+		t.Fatalf("s should have been mutable; it was not")
+	}
+
+	if s2.Mutable() {
+		t.Fatalf("s2 should not be mutable")
+	}
+
+	if s1.Contains(peter) {
+		t.Fatalf("s1 should not contain %q", peter)
+	}
+
+	if !s2.Contains(peter) {
+		t.Fatalf("s2 should contain %q", peter)
+	}
+}
+
+func TestMySetAddRemove(t *testing.T) {
+	s1 := coretest.NewMySet(peter)
+	s2 := s1.Add(paul, peter)
+	s3 := s2.Remove(peter)
+
+	if s1.Len() != 1 || s2.Len() != 2 || s3.Len() != 1 {
+		t.Fatalf("unexpected lengths %v, %v, %v", s1.Len(), s2.Len(), s3.Len())
+	}
+
+	if s3.Contains(peter) || !s3.Contains(paul) {
+		t.Fatalf("s3 should contain only %q", paul)
+	}
+
+	if s2.Mutable() || s3.Mutable() {
+		t.Fatalf("results of Add and Remove should not be mutable")
+	}
+}
+
+func TestMySetOps(t *testing.T) {
+	a := coretest.NewMySet(peter, paul)
+	b := coretest.NewMySet(paul, "john")
+
+	check := func(name string, s *coretest.MySet, exp ...string) {
+		t.Helper()
+
+		if s.Len() != len(exp) {
+			t.Fatalf("%v: expected %v elements; got %v", name, len(exp), s.Len())
+		}
+
+		for _, v := range exp {
+			if !s.Contains(v) {
+				t.Fatalf("%v: expected %q to be an element", name, v)
+			}
+		}
+	}
+
+	check("Union", a.Union(b), peter, paul, "john")
+	check("Intersect", a.Intersect(b), paul)
+	check("Difference", a.Difference(b), peter)
+	check("Difference nil", a.Difference(nil), peter, paul)
+
+	// the receivers are unchanged
+	check("a", a, peter, paul)
+	check("b", b, paul, "john")
+
+	if a.IsSubset(b) {
+		t.Fatalf("a should not be a subset of b")
+	}
+
+	if !a.Intersect(b).IsSubset(b) {
+		t.Fatalf("a ∩ b should be a subset of b")
+	}
+
+	if !coretest.NewMySet().IsSubset(nil) {
+		t.Fatalf("the empty set should be a subset of nil")
+	}
+}
+
+func TestMySetDiffAndJSON(t *testing.T) {
+	s1 := coretest.NewMySet(peter, paul)
+	s2 := s1.Remove(peter).Add("john")
+
+	d := s1.Diff(s2)
+	if d == nil || len(d.Add) != 1 || d.Add[0] != "john" || len(d.Remove) != 1 || d.Remove[0] != peter {
+		t.Fatalf("unexpected diff %+v", d)
+	}
+
+	if d := s1.Patch(d).Diff(s2); d != nil {
+		t.Fatalf("expected patched value to equal s2; got diff %+v", d)
+	}
+
+	b, err := json.Marshal(coretest.NewMySet(peter))
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+
+	if exp := `["peter"]`; string(b) != exp {
+		t.Fatalf("expected %v; got %v", exp, string(b))
+	}
+
+	var s3 *coretest.MySet
+	if err := json.Unmarshal([]byte(`["paul","peter"]`), &s3); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+
+	if d := s1.Diff(s3); d != nil {
+		t.Fatalf("expected unmarshalled value to equal s1; got diff %+v", d)
+	}
+}
+
+func TestASetIsDeeplyNonMutable(t *testing.T) {
+	a := new(coretest.A).AsMutable()
+
+	s := coretest.NewASet(a)
+
+	if s.IsDeeplyNonMutable(nil) {
+		t.Fatalf("s should not be deeply non-mutable; it contains a mutable value")
+	}
+
+	a.AsImmutable(nil)
+
+	if !s.IsDeeplyNonMutable(nil) {
+		t.Fatalf("s should be deeply non-mutable")
+	}
+}
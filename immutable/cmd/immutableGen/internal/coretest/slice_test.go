@@ -445,3 +445,28 @@ func TestMySliceAppendMutableReceiver(t *testing.T) {
 		t.Fatalf("s2 should be mutable")
 	}
 }
+
+func TestMySliceSliceConcat(t *testing.T) {
+	s1 := coretest.NewMySlice(paul, peter)
+	s2 := s1.Slice(1, 2)
+	s3 := s2.Concat(s1)
+
+	if v := s1.Len(); v != 2 {
+		t.Fatalf("expected s1.Len() to be 2; got %v", v)
+	}
+
+	if v := s2.Len(); v != 1 || s2.Get(0) != peter {
+		t.Fatalf("expected s2 to be [%q]; got %v", peter, s2.Range())
+	}
+
+	if v := s3.Len(); v != 3 || s3.Get(0) != peter || s3.Get(2) != peter {
+		t.Fatalf("expected s3 to be [%q %q %q]; got %v", peter, paul, peter, s3.Range())
+	}
+
+	// check s2 is not affected by subsequent changes to s1
+	s1.Set(1, paul)
+
+	if v := s2.Get(0); v != peter {
+		t.Fatalf("expected Get(0) to be %q, got %q", peter, v)
+	}
+}
@@ -0,0 +1,178 @@
+package coretest_test
+
+import (
+	"testing"
+
+	"myitcv.io/immutable/cmd/immutableGen/internal/coretest"
+)
+
+func TestMyVectorSliceZeroValue(t *testing.T) {
+	s1 := new(coretest.MyVectorSlice)
+
+	if s1.Mutable() {
+		t.Fatalf("zero value should be immutable")
+	}
+
+	if s1.Len() != 0 {
+		t.Fatalf("zero value should have zero length")
+	}
+
+	setFailed := false
+	func() {
+		defer func() {
+			if recover() != nil {
+				setFailed = true
+			}
+		}()
+
+		s1.Set(0, "test")
+	}()
+
+	if !setFailed {
+		t.Fatalf("should panic when setting on zero value")
+	}
+}
+
+func TestMyVectorSliceWithMutableImmutableReceiver(t *testing.T) {
+	wasMutable := false
+
+	var s3 *coretest.MyVectorSlice
+
+	s1 := coretest.NewMyVectorSlice()
+	s2 := s1.WithMutable(func(s *coretest.MyVectorSlice) {
+		wasMutable = s.Mutable()
+
+		// have some side effect
+		s.Append(peter)
+
+		s3 = s
+	})
+
+	if s1 == s2 {
+		t.Fatalf("s1 and s2 should be different values; they are not")
+	}
+
+	if s3 != s2 {
+		t.Fatalf("s3 and s2 should be same values; they were not")
+	}
+
+	if !wasMutable {
+		t.Fatalf("s should have been mutable; it was not")
+	}
+
+	if s2.Mutable() {
+		t.Fatalf("s2 should not be mutable")
+	}
+
+	if v := s1.Len(); v != 0 {
+		t.Fatalf("expected s1.Len() to be 0; got %v", v)
+	}
+
+	if v := s2.Get(0); v != peter {
+		t.Fatalf("expected Get(0) to be %q, got %q", peter, v)
+	}
+}
+
+func TestMyVectorSliceConstructorLength(t *testing.T) {
+	s1 := coretest.NewMyVectorSliceLen(3)
+
+	if v := s1.Len(); v != 3 {
+		t.Fatalf("expected s1.Len() to be 3; got %v", v)
+	}
+
+	if v := s1.Get(2); v != "" {
+		t.Fatalf("expected Get(2) to be %q, got %q", "", v)
+	}
+}
+
+func TestMyVectorSliceSetImmutableReceiver(t *testing.T) {
+	s1 := coretest.NewMyVectorSlice(paul)
+	s2 := s1.Set(0, peter)
+
+	if s1 == s2 {
+		t.Fatalf("s1 and s2 should be different values; they are not")
+	}
+
+	if v := s1.Get(0); v != paul {
+		t.Fatalf("expected Get(0) to be %q, got %q", paul, v)
+	}
+
+	if v := s2.Get(0); v != peter {
+		t.Fatalf("expected Get(0) to be %q, got %q", peter, v)
+	}
+}
+
+func TestMyVectorSliceAppendMutableReceiver(t *testing.T) {
+	s1 := new(coretest.MyVectorSlice).AsMutable()
+	s2 := s1.Append(peter)
+
+	if s1 != s2 {
+		t.Fatalf("s1 and s2 should not be different values; they are")
+	}
+
+	if v := s2.Get(0); v != peter {
+		t.Fatalf("expected Get(0) to be %q, got %q", peter, v)
+	}
+}
+
+func TestMyVectorSliceBuild(t *testing.T) {
+	const n = 10000
+
+	var versions []*coretest.MyVectorSlice
+
+	s := coretest.NewMyVectorSlice()
+	for i := 0; i < n; i++ {
+		s = s.Append(peter)
+		versions = append(versions, s)
+	}
+
+	for i, v := range versions {
+		if l := v.Len(); l != i+1 {
+			t.Fatalf("version %v should have length %v; got %v", i, i+1, l)
+		}
+	}
+
+	if v := len(s.Range()); v != n {
+		t.Fatalf("expected Range() to have length %v; got %v", n, v)
+	}
+
+	s2 := s.Slice(10, 20).Concat(coretest.NewMyVectorSlice(paul))
+
+	if v := s2.Len(); v != 11 {
+		t.Fatalf("expected s2.Len() to be 11; got %v", v)
+	}
+
+	if v := s2.Get(10); v != paul {
+		t.Fatalf("expected Get(10) to be %q, got %q", paul, v)
+	}
+
+	count := 0
+	s2.RangeFunc(func(i int, v string) bool {
+		count++
+		return true
+	})
+
+	if count != 11 {
+		t.Fatalf("expected RangeFunc to visit 11 elements; got %v", count)
+	}
+}
+
+func TestVectorSliceDeep(t *testing.T) {
+	s1 := coretest.NewAVS()
+
+	if !s1.IsDeeplyNonMutable(nil) {
+		t.Fatalf("s1 should be DeeplyNonMutable")
+	}
+
+	s2 := coretest.NewAVS(new(coretest.A), nil)
+
+	if !s2.IsDeeplyNonMutable(nil) {
+		t.Fatalf("s2 should be DeeplyNonMutable")
+	}
+
+	s3 := coretest.NewAVS(nil, new(coretest.A).AsMutable())
+
+	if s3.IsDeeplyNonMutable(nil) {
+		t.Fatalf("s3 should not be DeeplyNonMutable")
+	}
+}
@@ -0,0 +1,321 @@
+// Copyright (c) 2016 Paul Jolly <paul@myitcv.org.uk>, all rights reserved.
+// Use of this document is governed by a license found in the LICENSE document.
+
+package main
+
+import (
+	"go/types"
+)
+
+// jsonField describes a field of an immutable struct for the purposes of
+// generating its MarshalJSON and UnmarshalJSON methods
+type jsonField struct {
+	// the name of the field in the generated struct
+	Field string
+
+	// the name of the field in the template
+	Name string
+
+	Type string
+
+	// the tag of the field in the template, including quotes, or the empty
+	// string
+	Tag string
+}
+
+// jsonMapKey returns whether encoding/json can encode and decode a Go map
+// with keys of type t as a JSON object, i.e. whether t is a string or integer
+// type or implements encoding.TextMarshaler and encoding.TextUnmarshaler.
+// Immutable maps with other key types are encoded as a JSON array of key value
+// pairs.
+func jsonMapKey(t types.Type) bool {
+	if typeIsInvalid(t) {
+		return false
+	}
+
+	if b, ok := t.Underlying().(*types.Basic); ok {
+		return b.Info()&(types.IsString|types.IsInteger) != 0
+	}
+
+	return hasMethod(t, "MarshalText", 0, 2) && hasMethod(types.NewPointer(t), "UnmarshalText", 1, 1)
+}
+
+// hasMethod returns whether the method set of t includes the method name with
+// the given number of parameters and results.
+func hasMethod(t types.Type, name string, params, results int) bool {
+	sel := types.NewMethodSet(t).Lookup(nil, name)
+	if sel == nil {
+		return false
+	}
+
+	sig, ok := sel.Type().(*types.Signature)
+
+	return ok && sig.Params().Len() == params && sig.Results().Len() == results
+}
+
+// genStructJSON generates MarshalJSON and UnmarshalJSON for the struct s.
+// The methods (un)marshal via an anonymous struct with the exported fields
+// of the template, so field names and tags behave as they would for the
+// template itself, with the exception that embedded fields are (un)marshalled
+// as a field named after the embedded type rather than being inlined.
+func (o *output) genStructJSON(s *immStruct, fields []jsonField) {
+	o.extraImports["encoding/json"] = true
+
+	tmpl := struct {
+		Name    string
+		Fields  []jsonField
+		Special bool
+	}{
+		Name:    s.name,
+		Fields:  fields,
+		Special: s.special != notSpecial,
+	}
+
+	o.pt(`
+	// MarshalJSON implements json.Marshaler. The exported fields of the template
+	// for {{.Name}} are marshalled according to their names and tags.
+	func (s *{{.Name}}) MarshalJSON() ([]byte, error) {
+		if s == nil {
+			return []byte("null"), nil
+		}
+
+		v := struct {
+		{{- range .Fields}}
+			{{.Name}} {{.Type}} {{.Tag}}
+		{{- end}}
+		}{
+		{{- range .Fields}}
+			{{.Name}}: s.{{.Field}},
+		{{- end}}
+		}
+
+		return json.Marshal(v)
+	}
+
+	// UnmarshalJSON implements json.Unmarshaler. The exported fields of the
+	// template for {{.Name}} are unmarshalled according to their names and tags;
+	// other fields, and those absent from the JSON, are left unchanged. The result
+	// is built via WithMutable{{if .Special}}, so its Key has a new Version unless
+	// the JSON sets the Key{{end}}. Because json.Unmarshaler stores the result in
+	// *s, s should be a new value rather than one shared with other code.
+	func (s *{{.Name}}) UnmarshalJSON(b []byte) error {
+		v := struct {
+		{{- range .Fields}}
+			{{.Name}} {{.Type}} {{.Tag}}
+		{{- end}}
+		}{
+		{{- range .Fields}}
+			{{.Name}}: s.{{.Field}},
+		{{- end}}
+		}
+
+		if err := json.Unmarshal(b, &v); err != nil {
+			return err
+		}
+
+		*s = *s.WithMutable(func(si *{{.Name}}) {
+		{{- range .Fields}}
+			{{- if and $.Special (eq .Name "Key")}}
+			if v.Key != s.field_Key {
+				si.field_Key = v.Key
+			}
+			{{- else}}
+			si.{{.Field}} = v.{{.Name}}
+			{{- end}}
+		{{- end}}
+		})
+
+		return nil
+	}
+	`, exporter(s.name), tmpl)
+}
+
+// genMapJSON generates MarshalJSON and UnmarshalJSON for the map m.
+func (o *output) genMapJSON(m *immMap) {
+	o.extraImports["encoding/json"] = true
+
+	tmpl := struct {
+		Name    string
+		KeyType string
+		ValType string
+		Object  bool
+		Ordered bool
+	}{
+		Name:    m.name,
+		KeyType: o.exprString(m.syn.Key),
+		ValType: o.exprString(m.syn.Value),
+		Object:  jsonMapKey(m.typ.Key()),
+		Ordered: m.opts.ordered,
+	}
+
+	// a JSON object is unordered, so the entries of a map ordered by insertion
+	// are marshalled as an array in order to survive a round trip
+	if m.opts.ordered && m.opts.order == "" {
+		tmpl.Object = false
+	}
+
+	o.pt(`
+	{{if .Object -}}
+	// MarshalJSON implements json.Marshaler. m is marshalled as a JSON object.
+	{{- else if .Ordered -}}
+	// MarshalJSON implements json.Marshaler. In order to preserve the order of
+	// its entries, m is marshalled as a JSON array of objects with Key and Value
+	// fields.
+	{{- else -}}
+	// MarshalJSON implements json.Marshaler. Because encoding/json does not
+	// support keys of type {{.KeyType}}, m is marshalled as a JSON array of objects
+	// with Key and Value fields.
+	{{- end}}
+	func (m *{{.Name}}) MarshalJSON() ([]byte, error) {
+		if m == nil {
+			return []byte("null"), nil
+		}
+	{{if .Object}}
+		if m.Len() == 0 {
+			return []byte("{}"), nil
+		}
+
+		return json.Marshal(m.Range())
+	{{- else}}
+		v := make([]struct {
+			Key   {{.KeyType}}
+			Value {{.ValType}}
+		}, 0, m.Len())
+	{{if .Ordered}}
+		for _, k := range m.theKeys {
+			e := m.theMap[k]
+	{{- else}}
+		for k, e := range m.Range() {
+	{{- end}}
+			v = append(v, struct {
+				Key   {{.KeyType}}
+				Value {{.ValType}}
+			}{k, e})
+		}
+
+		return json.Marshal(v)
+	{{- end}}
+	}
+
+	// UnmarshalJSON implements json.Unmarshaler. m is set to an immutable map
+	// containing the unmarshalled entries. Because *m is replaced, m should be a
+	// new value rather than one shared with other code.
+	func (m *{{.Name}}) UnmarshalJSON(b []byte) error {
+	{{- if .Object}}
+		var v map[{{.KeyType}}]{{.ValType}}
+	{{- else}}
+		var v []struct {
+			Key   {{.KeyType}}
+			Value {{.ValType}}
+		}
+	{{- end}}
+
+		if err := json.Unmarshal(b, &v); err != nil {
+			return err
+		}
+
+		*m = *{{Export "New"}}{{Capitalise .Name}}(func(mi *{{.Name}}) {
+	{{- if .Object}}
+			for k, e := range v {
+				mi.Set(k, e)
+			}
+	{{- else}}
+			for _, e := range v {
+				mi.Set(e.Key, e.Value)
+			}
+	{{- end}}
+		})
+
+		return nil
+	}
+	`, exporter(m.name), tmpl)
+}
+
+// genSliceJSON generates MarshalJSON and UnmarshalJSON for the slice s.
+func (o *output) genSliceJSON(s *immSlice) {
+	o.extraImports["encoding/json"] = true
+
+	tmpl := struct {
+		Name string
+		Type string
+	}{
+		Name: s.name,
+		Type: o.exprString(s.syn.Elt),
+	}
+
+	o.pt(`
+	// MarshalJSON implements json.Marshaler. m is marshalled as a JSON array.
+	func (m *{{.Name}}) MarshalJSON() ([]byte, error) {
+		if m == nil {
+			return []byte("null"), nil
+		}
+
+		if m.Len() == 0 {
+			return []byte("[]"), nil
+		}
+
+		return json.Marshal(m.Range())
+	}
+
+	// UnmarshalJSON implements json.Unmarshaler. m is set to an immutable slice
+	// containing the unmarshalled elements. Because *m is replaced, m should be a
+	// new value rather than one shared with other code.
+	func (m *{{.Name}}) UnmarshalJSON(b []byte) error {
+		var v []{{.Type}}
+
+		if err := json.Unmarshal(b, &v); err != nil {
+			return err
+		}
+
+		*m = *{{Export "New"}}{{Capitalise .Name}}(v...)
+
+		return nil
+	}
+	`, exporter(s.name), tmpl)
+}
+
+// genSetJSON generates MarshalJSON and UnmarshalJSON for the set s.
+func (o *output) genSetJSON(s *immSet) {
+	o.extraImports["encoding/json"] = true
+
+	tmpl := struct {
+		Name string
+		Type string
+	}{
+		Name: s.name,
+		Type: o.exprString(s.syn.Key),
+	}
+
+	o.pt(`
+	// MarshalJSON implements json.Marshaler. m is marshalled as a JSON array of
+	// its elements, in no particular order.
+	func (m *{{.Name}}) MarshalJSON() ([]byte, error) {
+		if m == nil {
+			return []byte("null"), nil
+		}
+
+		v := make([]{{.Type}}, 0, m.Len())
+
+		for e := range m.Range() {
+			v = append(v, e)
+		}
+
+		return json.Marshal(v)
+	}
+
+	// UnmarshalJSON implements json.Unmarshaler. m is set to an immutable set
+	// containing the unmarshalled elements. Because *m is replaced, m should be a
+	// new value rather than one shared with other code.
+	func (m *{{.Name}}) UnmarshalJSON(b []byte) error {
+		var v []{{.Type}}
+
+		if err := json.Unmarshal(b, &v); err != nil {
+			return err
+		}
+
+		*m = *{{Export "New"}}{{Capitalise .Name}}(v...)
+
+		return nil
+	}
+	`, exporter(s.name), tmpl)
+}
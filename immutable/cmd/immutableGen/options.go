@@ -0,0 +1,198 @@
+// Copyright (c) 2016 Paul Jolly <paul@myitcv.org.uk>, all rights reserved.
+// Use of this document is governed by a license found in the LICENSE document.
+
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+	"unicode"
+
+	"myitcv.io/sorter"
+)
+
+// tmplOptPrefix is the prefix of the text of a comment line in the doc comment
+// of an _Imm_ template that sets an option for that template, e.g.:
+//
+//	// immutableGen:hamt
+//	type _Imm_MyMap map[string]int
+//
+// The name of the option immediately follows the prefix. The form
+// //immutableGen:hamt is also accepted, but gofmt rewrites it to the above,
+// because immutableGen is not a valid directive name.
+const tmplOptPrefix = "immutableGen:"
+
+const (
+	// optHamt indicates that a map template should be generated using a
+	// persistent hash array mapped trie rather than a Go map.
+	optHamt = "hamt"
+
+	// optVector indicates that a slice template should be generated using a
+	// persistent vector rather than a Go slice.
+	optVector = "vector"
+
+	// optOrdered indicates that a map template should be generated with an
+	// ordered map. By default entries are ordered by insertion; the name of a
+	// sortGen-style order function on the key type may be given as an argument
+	// to order entries by key instead, e.g.:
+	//
+	//	// immutableGen:ordered orderByName
+	//	type _Imm_MyMap map[string]int
+	//
+	//	func orderByName(ks []string, i, j int) sorter.Ordered {
+	//		return ks[i] < ks[j]
+	//	}
+	optOrdered = "ordered"
+
+	// optSet indicates that a template of the form map[T]struct{} should be
+	// generated as an immutable set of T rather than an immutable map.
+	optSet = "set"
+
+	// optBinary indicates that MarshalBinary and UnmarshalBinary methods
+	// should be generated for a template. The fields of a struct template
+	// that are encoded are those with a binary struct tag giving the field's
+	// tag number, e.g.:
+	//
+	//	// immutableGen:binary
+	//	type _Imm_MyStruct struct {
+	//		Name string `binary:"1"`
+	//	}
+	optBinary = "binary"
+)
+
+// tmplOpts are the options set on a template via tmplOptPrefix comments
+type tmplOpts struct {
+	hamt   bool
+	vector bool
+
+	ordered bool
+	set     bool
+	binary  bool
+
+	// order is the name of the order function used to order the keys of an
+	// ordered map, or the empty string for insertion order
+	order string
+}
+
+// parseTmplOpts returns the options set in the doc comments associated with
+// ts. Where ts is not part of a grouped declaration, the doc comment is
+// attached to gd.
+func parseTmplOpts(gd *ast.GenDecl, ts *ast.TypeSpec) tmplOpts {
+	var res tmplOpts
+
+	for _, cg := range []*ast.CommentGroup{gd.Doc, ts.Doc} {
+		if cg == nil {
+			continue
+		}
+
+		for _, c := range cg.List {
+			opt, ok := isTmplOpt(c)
+			if !ok {
+				continue
+			}
+
+			args := strings.Fields(opt)
+			if len(args) == 0 {
+				fatalf("missing option in %v", c.Text)
+			}
+
+			opt, args = args[0], args[1:]
+
+			switch opt {
+			case optHamt, optVector, optSet, optBinary:
+				if len(args) != 0 {
+					fatalf("option %q takes no arguments in %v", opt, c.Text)
+				}
+				switch opt {
+				case optHamt:
+					res.hamt = true
+				case optVector:
+					res.vector = true
+				case optSet:
+					res.set = true
+				case optBinary:
+					res.binary = true
+				}
+			case optOrdered:
+				if len(args) > 1 {
+					fatalf("option %q takes at most one argument in %v", opt, c.Text)
+				}
+				res.ordered = true
+				if len(args) == 1 {
+					res.order = args[0]
+				}
+			default:
+				fatalf("unknown option %q in %v", opt, c.Text)
+			}
+		}
+	}
+
+	return res
+}
+
+// isTmplOpt returns the option set by c, and whether c is a template option
+// comment at all. A comment line such as
+//
+//	// immutableGen: generates immutable types
+//
+// is not, because no option name immediately follows tmplOptPrefix.
+func isTmplOpt(c *ast.Comment) (string, bool) {
+	if !strings.HasPrefix(c.Text, "//") {
+		return "", false
+	}
+
+	text := strings.TrimPrefix(strings.TrimPrefix(c.Text, "//"), " ")
+	if !strings.HasPrefix(text, tmplOptPrefix) {
+		return "", false
+	}
+
+	opt := strings.TrimPrefix(text, tmplOptPrefix)
+	if opt == "" || unicode.IsSpace(rune(opt[0])) {
+		return "", false
+	}
+
+	return strings.TrimSpace(opt), true
+}
+
+// checkOrderFunc verifies that order names a package-level sortGen-style
+// order function on a slice of k, i.e. a function of the form:
+//
+//	func orderByX(ks []K, i, j int) sorter.Ordered
+func checkOrderFunc(pos token.Position, pkg *types.Package, order string, k types.Type) {
+	bad := func() {
+		fatalf("%v: %v must be a function of type func([]%v, int, int) %v.%v", pos, order, types.TypeString(k, types.RelativeTo(pkg)), sorter.PkgName, sorter.OrderedName)
+	}
+
+	f, ok := pkg.Scope().Lookup(order).(*types.Func)
+	if !ok {
+		bad()
+	}
+
+	sig := f.Type().(*types.Signature)
+	if sig.Params().Len() != 3 || sig.Results().Len() != 1 {
+		bad()
+	}
+
+	s, ok := sig.Params().At(0).Type().(*types.Slice)
+	if !ok {
+		bad()
+	}
+
+	// the key type may be an immutable type we have yet to generate
+	if !typeIsInvalid(k) && !typeIsInvalid(s.Elem()) && !types.Identical(s.Elem(), k) {
+		bad()
+	}
+
+	for i := 1; i < 3; i++ {
+		if !types.Identical(sig.Params().At(i).Type(), types.Typ[types.Int]) {
+			bad()
+		}
+	}
+
+	n, ok := sig.Results().At(0).Type().(*types.Named)
+	if !ok || n.Obj().Pkg() == nil || n.Obj().Pkg().Path() != sorter.PkgName || n.Obj().Name() != sorter.OrderedName {
+		bad()
+	}
+}
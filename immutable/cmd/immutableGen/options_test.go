@@ -0,0 +1,33 @@
+// Copyright (c) 2016 Paul Jolly <paul@myitcv.org.uk>, all rights reserved.
+// Use of this document is governed by a license found in the LICENSE document.
+
+package main
+
+import (
+	"go/ast"
+	"testing"
+)
+
+func TestIsTmplOpt(t *testing.T) {
+	tests := []struct {
+		text string
+		opt  string
+		ok   bool
+	}{
+		{"// immutableGen:hamt", "hamt", true},
+		{"//immutableGen:hamt", "hamt", true},
+		{"// immutableGen:ordered orderByKey ", "ordered orderByKey", true},
+		{"// immutableGen: generates immutable types", "", false},
+		{"// immutableGen:", "", false},
+		{"//  immutableGen:hamt", "", false},
+		{"/* immutableGen:hamt */", "", false},
+		{"// a comment about immutableGen:hamt", "", false},
+	}
+
+	for _, test := range tests {
+		opt, ok := isTmplOpt(&ast.Comment{Text: test.text})
+		if opt != test.opt || ok != test.ok {
+			t.Errorf("isTmplOpt(%q) = (%q, %v); want (%q, %v)", test.text, opt, ok, test.opt, test.ok)
+		}
+	}
+}
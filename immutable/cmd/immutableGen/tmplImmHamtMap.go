@@ -0,0 +1,159 @@
+// Copyright (c) 2016 Paul Jolly <paul@myitcv.org.uk>, all rights reserved.
+// Use of this document is governed by a license found in the LICENSE document.
+
+package main
+
+// immHamtMapTmpl is the equivalent of immMapTmpl for templates with the
+// optHamt option. Because a *hamt.Map is itself persistent, dup is O(1) and
+// Set/Del on a non-mutable map are O(log n).
+const immHamtMapTmpl = `
+var _ immutable.Immutable = new({{.Name}})
+var _ = new({{.Name}}).__tmpl
+
+func {{Export "New"}}{{Capitalise .Name}}(inits ...func(m *{{.Name}})) *{{.Name}} {
+	res := {{Export "New"}}{{Capitalise .Name}}Cap(0)
+	if len(inits) == 0 {
+		return res
+	}
+
+	return res.WithMutable(func (m *{{.Name}}) {
+		for _, i := range inits {
+			i(m)
+		}
+	})
+}
+
+// {{Export "New"}}{{Capitalise .Name}}Cap is provided for API compatibility with
+// immutable maps backed by a Go map; l is ignored.
+func {{Export "New"}}{{Capitalise .Name}}Cap(l int) *{{.Name}} {
+	return &{{.Name}}{}
+}
+
+func (m *{{.Name}})Mutable() bool {
+	return m.mutable
+}
+
+func (m *{{.Name}}) Len() int {
+	if m == nil {
+		return 0
+	}
+
+	return m.theMap.Len()
+}
+
+func (m *{{.Name}}) Get(k {{.KeyType}}) ({{.ValType}}, bool) {
+	if m == nil {
+		var v {{.ValType}}
+		return v, false
+	}
+
+	return m.theMap.Get(k)
+}
+
+func (m *{{.Name}}) AsMutable() *{{.Name}} {
+	if m == nil {
+		return nil
+	}
+
+	if m.Mutable() {
+		return m
+	}
+
+	res := m.dup()
+	res.mutable = true
+
+	return res
+}
+
+func (m *{{.Name}}) dup() *{{.Name}} {
+	res := &{{.Name}}{
+		theMap: m.theMap,
+	}
+
+	return res
+}
+
+func (m *{{.Name}}) AsImmutable(v *{{.Name}}) *{{.Name}} {
+	if m == nil {
+		return nil
+	}
+
+	if v == m {
+		return m
+	}
+
+	m.mutable = false
+	return m
+}
+
+// Range returns a Go map containing the entries of m. Because the map is
+// built on each call it is O(n); use RangeFunc to avoid the allocation.
+func (m *{{.Name}}) Range() map[{{.KeyType}}]{{.ValType}} {
+	if m == nil {
+		return nil
+	}
+
+	res := make(map[{{.KeyType}}]{{.ValType}}, m.theMap.Len())
+
+	m.theMap.Range(func(k {{.KeyType}}, v {{.ValType}}) bool {
+		res[k] = v
+		return true
+	})
+
+	return res
+}
+
+// RangeFunc calls f for each entry in m, stopping if f returns false.
+func (m *{{.Name}}) RangeFunc(f func(k {{.KeyType}}, v {{.ValType}}) bool) {
+	if m == nil {
+		return
+	}
+
+	m.theMap.Range(f)
+}
+
+func (mr *{{.Name}}) WithMutable(f func({{.VarName}} *{{.Name}})) *{{.Name}} {
+	res := mr.AsMutable()
+	f(res)
+	res = res.AsImmutable(mr)
+
+	return res
+}
+
+func (mr *{{.Name}}) WithImmutable(f func({{.VarName}} *{{.Name}})) *{{.Name}} {
+	prev := mr.mutable
+	mr.mutable = false
+	f(mr)
+	mr.mutable = prev
+
+	return mr
+}
+
+func (m *{{.Name}}) Set(k {{.KeyType}}, v {{.ValType}}) *{{.Name}} {
+	if m.mutable {
+		m.theMap = m.theMap.Set(k, v)
+		return m
+	}
+
+	res := m.dup()
+	res.theMap = res.theMap.Set(k, v)
+
+	return res
+}
+
+func (m *{{.Name}}) Del(k {{.KeyType}}) *{{.Name}} {
+	if _, ok := m.theMap.Get(k); !ok {
+		return m
+	}
+
+	if m.mutable {
+		m.theMap = m.theMap.Del(k)
+		return m
+	}
+
+	res := m.dup()
+	res.theMap = res.theMap.Del(k)
+
+	return res
+}
+`
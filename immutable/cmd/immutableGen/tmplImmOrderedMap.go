@@ -0,0 +1,285 @@
+// Copyright (c) 2016 Paul Jolly <paul@myitcv.org.uk>, all rights reserved.
+// Use of this document is governed by a license found in the LICENSE document.
+
+package main
+
+// immOrderedMapTmpl is the equivalent of immMapTmpl for templates with the
+// optOrdered option. The keys of the map are additionally held in theKeys,
+// either in insertion order or, where .Order names an order function, in the
+// order defined by that function.
+const immOrderedMapTmpl = `
+var _ immutable.Immutable = new({{.Name}})
+var _ = new({{.Name}}).__tmpl
+
+func {{Export "New"}}{{Capitalise .Name}}(inits ...func(m *{{.Name}})) *{{.Name}} {
+	res := {{Export "New"}}{{Capitalise .Name}}Cap(0)
+	if len(inits) == 0 {
+		return res
+	}
+
+	return res.WithMutable(func (m *{{.Name}}) {
+		for _, i := range inits {
+			i(m)
+		}
+	})
+}
+
+func {{Export "New"}}{{Capitalise .Name}}Cap(l int) *{{.Name}} {
+	return &{{.Name}}{
+		theMap:  make(map[{{.KeyType}}]{{.ValType}}, l),
+		theKeys: make([]{{.KeyType}}, 0, l),
+	}
+}
+
+func (m *{{.Name}})Mutable() bool {
+	return m.mutable
+}
+
+func (m *{{.Name}}) Len() int {
+	if m == nil {
+		return 0
+	}
+
+	return len(m.theMap)
+}
+
+func (m *{{.Name}}) Get(k {{.KeyType}}) ({{.ValType}}, bool) {
+	v, ok := m.theMap[k]
+	return v, ok
+}
+
+func (m *{{.Name}}) AsMutable() *{{.Name}} {
+	if m == nil {
+		return nil
+	}
+
+	if m.Mutable() {
+		return m
+	}
+
+	res := m.dup()
+	res.mutable = true
+
+	return res
+}
+
+func (m *{{.Name}}) dup() *{{.Name}} {
+	resMap := make(map[{{.KeyType}}]{{.ValType}}, len(m.theMap))
+
+	for k := range m.theMap {
+		resMap[k] = m.theMap[k]
+	}
+
+	resKeys := make([]{{.KeyType}}, len(m.theKeys))
+	copy(resKeys, m.theKeys)
+
+	res := &{{.Name}}{
+		theMap:  resMap,
+		theKeys: resKeys,
+	}
+
+	return res
+}
+
+func (m *{{.Name}}) AsImmutable(v *{{.Name}}) *{{.Name}} {
+	if m == nil {
+		return nil
+	}
+
+	if v == m {
+		return m
+	}
+
+	m.mutable = false
+	return m
+}
+
+// Range returns the Go map underlying m. Although m is ordered, iteration over
+// the result of Range is, as for any Go map, in random order; use RangeFunc,
+// Keys or Values to iterate in order.
+func (m *{{.Name}}) Range() map[{{.KeyType}}]{{.ValType}} {
+	if m == nil {
+		return nil
+	}
+
+	return m.theMap
+}
+
+// RangeFunc calls f for each entry in m in order, stopping if f returns
+// false.
+func (m *{{.Name}}) RangeFunc(f func(k {{.KeyType}}, v {{.ValType}}) bool) {
+	if m == nil {
+		return
+	}
+
+	for _, k := range m.theKeys {
+		if !f(k, m.theMap[k]) {
+			return
+		}
+	}
+}
+
+// Keys returns the keys of m in order.
+func (m *{{.Name}}) Keys() []{{.KeyType}} {
+	if m == nil {
+		return nil
+	}
+
+	res := make([]{{.KeyType}}, len(m.theKeys))
+	copy(res, m.theKeys)
+
+	return res
+}
+
+// Values returns the values of m in the order of their keys.
+func (m *{{.Name}}) Values() []{{.ValType}} {
+	if m == nil {
+		return nil
+	}
+
+	res := make([]{{.ValType}}, len(m.theKeys))
+
+	for i, k := range m.theKeys {
+		res[i] = m.theMap[k]
+	}
+
+	return res
+}
+
+// First returns the first entry in m, and false if m is empty.
+func (m *{{.Name}}) First() ({{.KeyType}}, {{.ValType}}, bool) {
+	return m.entry(0)
+}
+
+// Last returns the last entry in m, and false if m is empty.
+func (m *{{.Name}}) Last() ({{.KeyType}}, {{.ValType}}, bool) {
+	return m.entry(m.Len() - 1)
+}
+
+func (m *{{.Name}}) entry(i int) ({{.KeyType}}, {{.ValType}}, bool) {
+	if i < 0 || i >= m.Len() {
+		var k {{.KeyType}}
+		var v {{.ValType}}
+		return k, v, false
+	}
+
+	k := m.theKeys[i]
+
+	return k, m.theMap[k], true
+}
+{{if .Order}}
+// Floor returns the entry in m with the greatest key less than or equal to
+// k, and false if there is no such entry.
+func (m *{{.Name}}) Floor(k {{.KeyType}}) ({{.KeyType}}, {{.ValType}}, bool) {
+	i := m.search(k)
+	if i < m.Len() && !m.less(k, m.theKeys[i]) {
+		return m.entry(i)
+	}
+
+	return m.entry(i - 1)
+}
+
+// Ceiling returns the entry in m with the least key greater than or equal to
+// k, and false if there is no such entry.
+func (m *{{.Name}}) Ceiling(k {{.KeyType}}) ({{.KeyType}}, {{.ValType}}, bool) {
+	return m.entry(m.search(k))
+}
+
+// less reports whether a is ordered before b according to {{.Order}}
+func (m *{{.Name}}) less(a, b {{.KeyType}}) bool {
+	ks := [2]{{.KeyType}}{a, b}
+	return bool({{.Order}}(ks[:], 0, 1))
+}
+
+// search returns the index of the first key in m that is not ordered before
+// k, or m.Len() if there is no such key.
+func (m *{{.Name}}) search(k {{.KeyType}}) int {
+	if m == nil {
+		return 0
+	}
+
+	return sort.Search(len(m.theKeys), func(i int) bool {
+		return !m.less(m.theKeys[i], k)
+	})
+}
+{{end}}
+func (mr *{{.Name}}) WithMutable(f func({{.VarName}} *{{.Name}})) *{{.Name}} {
+	res := mr.AsMutable()
+	f(res)
+	res = res.AsImmutable(mr)
+
+	return res
+}
+
+func (mr *{{.Name}}) WithImmutable(f func({{.VarName}} *{{.Name}})) *{{.Name}} {
+	prev := mr.mutable
+	mr.mutable = false
+	f(mr)
+	mr.mutable = prev
+
+	return mr
+}
+
+func (m *{{.Name}}) Set(k {{.KeyType}}, v {{.ValType}}) *{{.Name}} {
+	if m.mutable {
+		m.set(k, v)
+		return m
+	}
+
+	res := m.dup()
+	res.set(k, v)
+
+	return res
+}
+
+func (m *{{.Name}}) set(k {{.KeyType}}, v {{.ValType}}) {
+	if _, ok := m.theMap[k]; !ok {
+	{{- if .Order}}
+		i := m.search(k)
+		m.theKeys = append(m.theKeys, k)
+		copy(m.theKeys[i+1:], m.theKeys[i:])
+		m.theKeys[i] = k
+	{{- else}}
+		m.theKeys = append(m.theKeys, k)
+	{{- end}}
+	}
+
+	m.theMap[k] = v
+}
+
+func (m *{{.Name}}) Del(k {{.KeyType}}) *{{.Name}} {
+	if _, ok := m.theMap[k]; !ok {
+		return m
+	}
+
+	if m.mutable {
+		m.del(k)
+		return m
+	}
+
+	res := m.dup()
+	res.del(k)
+
+	return res
+}
+
+func (m *{{.Name}}) del(k {{.KeyType}}) {
+	delete(m.theMap, k)
+{{if .Order}}
+	// keys that are neither ordered before nor after k follow the first such
+	// key
+	i := m.search(k)
+{{- else}}
+	i := 0
+{{- end}}
+	for m.theKeys[i] != k {
+		i++
+	}
+
+	copy(m.theKeys[i:], m.theKeys[i+1:])
+
+	var zero {{.KeyType}}
+	m.theKeys[len(m.theKeys)-1] = zero
+	m.theKeys = m.theKeys[:len(m.theKeys)-1]
+}
+`
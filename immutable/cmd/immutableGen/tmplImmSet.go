@@ -0,0 +1,203 @@
+// Copyright (c) 2016 Paul Jolly <paul@myitcv.org.uk>, all rights reserved.
+// Use of this document is governed by a license found in the LICENSE document.
+
+package main
+
+const immSetTmpl = `
+var _ immutable.Immutable = new({{.Name}})
+var _ = new({{.Name}}).__tmpl
+
+func {{Export "New"}}{{Capitalise .Name}}(vs ...{{.Type}}) *{{.Name}} {
+	res := {{Export "New"}}{{Capitalise .Name}}Cap(len(vs))
+
+	for _, v := range vs {
+		res.theSet[v] = struct{}{}
+	}
+
+	return res
+}
+
+func {{Export "New"}}{{Capitalise .Name}}Cap(l int) *{{.Name}} {
+	return &{{.Name}}{
+		theSet: make(map[{{.Type}}]struct{}, l),
+	}
+}
+
+func (m *{{.Name}})Mutable() bool {
+	return m.mutable
+}
+
+func (m *{{.Name}}) Len() int {
+	if m == nil {
+		return 0
+	}
+
+	return len(m.theSet)
+}
+
+func (m *{{.Name}}) Contains(v {{.Type}}) bool {
+	if m == nil {
+		return false
+	}
+
+	_, ok := m.theSet[v]
+	return ok
+}
+
+func (m *{{.Name}}) AsMutable() *{{.Name}} {
+	if m == nil {
+		return nil
+	}
+
+	if m.Mutable() {
+		return m
+	}
+
+	res := m.dup()
+	res.mutable = true
+
+	return res
+}
+
+func (m *{{.Name}}) dup() *{{.Name}} {
+	resSet := make(map[{{.Type}}]struct{}, len(m.theSet))
+
+	for v := range m.theSet {
+		resSet[v] = struct{}{}
+	}
+
+	res := &{{.Name}}{
+		theSet: resSet,
+	}
+
+	return res
+}
+
+func (m *{{.Name}}) AsImmutable(v *{{.Name}}) *{{.Name}} {
+	if m == nil {
+		return nil
+	}
+
+	if v == m {
+		return m
+	}
+
+	m.mutable = false
+	return m
+}
+
+func (m *{{.Name}}) Range() map[{{.Type}}]struct{} {
+	if m == nil {
+		return nil
+	}
+
+	return m.theSet
+}
+
+func (mr *{{.Name}}) WithMutable(f func({{.VarName}} *{{.Name}})) *{{.Name}} {
+	res := mr.AsMutable()
+	f(res)
+	res = res.AsImmutable(mr)
+
+	return res
+}
+
+func (mr *{{.Name}}) WithImmutable(f func({{.VarName}} *{{.Name}})) *{{.Name}} {
+	prev := mr.mutable
+	mr.mutable = false
+	f(mr)
+	mr.mutable = prev
+
+	return mr
+}
+
+func (m *{{.Name}}) Add(vs ...{{.Type}}) *{{.Name}} {
+	if !m.mutable {
+		return m.WithMutable(func(mi *{{.Name}}) {
+			mi.Add(vs...)
+		})
+	}
+
+	for _, v := range vs {
+		m.theSet[v] = struct{}{}
+	}
+
+	return m
+}
+
+func (m *{{.Name}}) Remove(vs ...{{.Type}}) *{{.Name}} {
+	if !m.mutable {
+		return m.WithMutable(func(mi *{{.Name}}) {
+			mi.Remove(vs...)
+		})
+	}
+
+	for _, v := range vs {
+		delete(m.theSet, v)
+	}
+
+	return m
+}
+
+// Union returns the set of elements in either m or o.
+func (m *{{.Name}}) Union(o *{{.Name}}) *{{.Name}} {
+	if !m.mutable {
+		return m.WithMutable(func(mi *{{.Name}}) {
+			mi.Union(o)
+		})
+	}
+
+	for v := range o.Range() {
+		m.theSet[v] = struct{}{}
+	}
+
+	return m
+}
+
+// Intersect returns the set of elements in both m and o.
+func (m *{{.Name}}) Intersect(o *{{.Name}}) *{{.Name}} {
+	if !m.mutable {
+		return m.WithMutable(func(mi *{{.Name}}) {
+			mi.Intersect(o)
+		})
+	}
+
+	for v := range m.theSet {
+		if !o.Contains(v) {
+			delete(m.theSet, v)
+		}
+	}
+
+	return m
+}
+
+// Difference returns the set of elements in m that are not in o.
+func (m *{{.Name}}) Difference(o *{{.Name}}) *{{.Name}} {
+	if !m.mutable {
+		return m.WithMutable(func(mi *{{.Name}}) {
+			mi.Difference(o)
+		})
+	}
+
+	for v := range o.Range() {
+		delete(m.theSet, v)
+	}
+
+	return m
+}
+
+// IsSubset returns whether every element of m is also an element of o.
+func (m *{{.Name}}) IsSubset(o *{{.Name}}) bool {
+	if m.Len() > o.Len() {
+		return false
+	}
+
+	for v := range m.Range() {
+		if !o.Contains(v) {
+			return false
+		}
+	}
+
+	return true
+}
+`
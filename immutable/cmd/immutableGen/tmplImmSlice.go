@@ -130,4 +130,24 @@ func (m *{{.Name}}) Append(v ...{{.Type}}) *{{.Name}} {
 
 	return res
 }
+
+func (m *{{.Name}}) Slice(i, j int) *{{.Name}} {
+	if m.mutable {
+		m.theSlice = m.theSlice[i:j]
+		return m
+	}
+
+	resSlice := make([]{{.Type}}, j-i)
+	copy(resSlice, m.theSlice[i:j])
+
+	res := &{{.Name}}{
+		theSlice: resSlice,
+	}
+
+	return res
+}
+
+func (m *{{.Name}}) Concat(o *{{.Name}}) *{{.Name}} {
+	return m.Append(o.Range()...)
+}
 `
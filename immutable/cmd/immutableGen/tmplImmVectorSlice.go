@@ -0,0 +1,167 @@
+// Copyright (c) 2016 Paul Jolly <paul@myitcv.org.uk>, all rights reserved.
+// Use of this document is governed by a license found in the LICENSE document.
+
+package main
+
+// immVectorSliceTmpl is the equivalent of immSliceTmpl for templates with the
+// optVector option. Because a *vector.Vector is itself persistent, dup is
+// O(1) and Set, Append, Slice and Concat on a non-mutable slice are
+// O(log n).
+const immVectorSliceTmpl = `
+var _ immutable.Immutable = new({{.Name}})
+var _ = new({{.Name}}).__tmpl
+
+func {{Export "New"}}{{Capitalise .Name}}(s ...{{.Type}}) *{{.Name}} {
+	return &{{.Name}}{
+		theSlice: vector.New(s...),
+	}
+}
+
+func {{Export "New"}}{{Capitalise .Name}}Len(l int) *{{.Name}} {
+	return &{{.Name}}{
+		theSlice: vector.New(make([]{{.Type}}, l)...),
+	}
+}
+
+func (m *{{.Name}})Mutable() bool {
+	return m.mutable
+}
+
+func (m *{{.Name}}) Len() int {
+	if m == nil {
+		return 0
+	}
+
+	return m.theSlice.Len()
+}
+
+func (m *{{.Name}}) Get(i int) {{.Type}} {
+	return m.theSlice.Get(i)
+}
+
+func (m *{{.Name}}) AsMutable() *{{.Name}} {
+	if m == nil {
+		return nil
+	}
+
+	if m.Mutable() {
+		return m
+	}
+
+	res := m.dup()
+	res.mutable = true
+
+	return res
+}
+
+func (m *{{.Name}}) dup() *{{.Name}} {
+	res := &{{.Name}}{
+		theSlice: m.theSlice,
+	}
+
+	return res
+}
+
+func (m *{{.Name}}) AsImmutable(v *{{.Name}}) *{{.Name}} {
+	if m == nil {
+		return nil
+	}
+
+	if v == m {
+		return m
+	}
+
+	m.mutable = false
+	return m
+}
+
+// Range returns a Go slice containing the elements of m. Because the slice
+// is built on each call it is O(n); use RangeFunc to avoid the allocation.
+func (m *{{.Name}}) Range() []{{.Type}} {
+	if m == nil {
+		return nil
+	}
+
+	return m.theSlice.ToSlice()
+}
+
+// RangeFunc calls f for each index and element in m in order, stopping if f
+// returns false.
+func (m *{{.Name}}) RangeFunc(f func(i int, v {{.Type}}) bool) {
+	if m == nil {
+		return
+	}
+
+	m.theSlice.Range(f)
+}
+
+func (m *{{.Name}}) WithMutable(f func(mi *{{.Name}})) *{{.Name}} {
+	res := m.AsMutable()
+	f(res)
+	res = res.AsImmutable(m)
+
+	return res
+}
+
+func (m *{{.Name}}) WithImmutable(f func(mi *{{.Name}})) *{{.Name}} {
+	prev := m.mutable
+	m.mutable = false
+	f(m)
+	m.mutable = prev
+
+	return m
+}
+
+func (m *{{.Name}}) Set(i int, v {{.Type}}) *{{.Name}} {
+	if m.mutable {
+		m.theSlice = m.theSlice.Set(i, v)
+		return m
+	}
+
+	res := m.dup()
+	res.theSlice = res.theSlice.Set(i, v)
+
+	return res
+}
+
+func (m *{{.Name}}) Append(v ...{{.Type}}) *{{.Name}} {
+	if m.mutable {
+		m.theSlice = m.theSlice.Append(v...)
+		return m
+	}
+
+	res := m.dup()
+	res.theSlice = res.theSlice.Append(v...)
+
+	return res
+}
+
+func (m *{{.Name}}) Slice(i, j int) *{{.Name}} {
+	if m.mutable {
+		m.theSlice = m.theSlice.Slice(i, j)
+		return m
+	}
+
+	res := m.dup()
+	res.theSlice = res.theSlice.Slice(i, j)
+
+	return res
+}
+
+func (m *{{.Name}}) Concat(o *{{.Name}}) *{{.Name}} {
+	var os *vector.Vector[{{.Type}}]
+	if o != nil {
+		os = o.theSlice
+	}
+
+	if m.mutable {
+		m.theSlice = m.theSlice.Concat(os)
+		return m
+	}
+
+	res := m.dup()
+	res.theSlice = res.theSlice.Concat(os)
+
+	return res
+}
+`
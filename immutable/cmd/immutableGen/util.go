@@ -144,6 +144,9 @@ func (o *output) exprString(e ast.Expr) string {
 func (o *output) printCommentGroup(d *ast.CommentGroup) {
 	if d != nil {
 		for _, c := range d.List {
+			if _, ok := isTmplOpt(c); ok {
+				continue
+			}
 			o.pfln("%v", c.Text)
 		}
 	}
@@ -5,14 +5,327 @@ package _testFiles
 //immutableVet:skipFile
 
 import (
+	"encoding/json"
 	"myitcv.io/immutable"
+	"reflect"
 )
 
+// strSet is an immutable type and has the following template:
 //
+//	map[string]struct{}
+type strSet struct {
+	theSet  map[string]struct{}
+	mutable bool
+	__tmpl  *_Imm_strSet
+}
+
+var _ immutable.Immutable = new(strSet)
+var _ = new(strSet).__tmpl
+
+func newStrSet(vs ...string) *strSet {
+	res := newStrSetCap(len(vs))
+
+	for _, v := range vs {
+		res.theSet[v] = struct{}{}
+	}
+
+	return res
+}
+
+func newStrSetCap(l int) *strSet {
+	return &strSet{
+		theSet: make(map[string]struct{}, l),
+	}
+}
+
+func (m *strSet) Mutable() bool {
+	return m.mutable
+}
+
+func (m *strSet) Len() int {
+	if m == nil {
+		return 0
+	}
+
+	return len(m.theSet)
+}
+
+func (m *strSet) Contains(v string) bool {
+	if m == nil {
+		return false
+	}
+
+	_, ok := m.theSet[v]
+	return ok
+}
+
+func (m *strSet) AsMutable() *strSet {
+	if m == nil {
+		return nil
+	}
+
+	if m.Mutable() {
+		return m
+	}
+
+	res := m.dup()
+	res.mutable = true
+
+	return res
+}
+
+func (m *strSet) dup() *strSet {
+	resSet := make(map[string]struct{}, len(m.theSet))
+
+	for v := range m.theSet {
+		resSet[v] = struct{}{}
+	}
+
+	res := &strSet{
+		theSet: resSet,
+	}
+
+	return res
+}
+
+func (m *strSet) AsImmutable(v *strSet) *strSet {
+	if m == nil {
+		return nil
+	}
+
+	if v == m {
+		return m
+	}
+
+	m.mutable = false
+	return m
+}
+
+func (m *strSet) Range() map[string]struct{} {
+	if m == nil {
+		return nil
+	}
+
+	return m.theSet
+}
+
+func (mr *strSet) WithMutable(f func(s *strSet)) *strSet {
+	res := mr.AsMutable()
+	f(res)
+	res = res.AsImmutable(mr)
+
+	return res
+}
+
+func (mr *strSet) WithImmutable(f func(s *strSet)) *strSet {
+	prev := mr.mutable
+	mr.mutable = false
+	f(mr)
+	mr.mutable = prev
+
+	return mr
+}
+
+func (m *strSet) Add(vs ...string) *strSet {
+	if !m.mutable {
+		return m.WithMutable(func(mi *strSet) {
+			mi.Add(vs...)
+		})
+	}
+
+	for _, v := range vs {
+		m.theSet[v] = struct{}{}
+	}
+
+	return m
+}
+
+func (m *strSet) Remove(vs ...string) *strSet {
+	if !m.mutable {
+		return m.WithMutable(func(mi *strSet) {
+			mi.Remove(vs...)
+		})
+	}
+
+	for _, v := range vs {
+		delete(m.theSet, v)
+	}
+
+	return m
+}
+
+// Union returns the set of elements in either m or o.
+func (m *strSet) Union(o *strSet) *strSet {
+	if !m.mutable {
+		return m.WithMutable(func(mi *strSet) {
+			mi.Union(o)
+		})
+	}
+
+	for v := range o.Range() {
+		m.theSet[v] = struct{}{}
+	}
+
+	return m
+}
+
+// Intersect returns the set of elements in both m and o.
+func (m *strSet) Intersect(o *strSet) *strSet {
+	if !m.mutable {
+		return m.WithMutable(func(mi *strSet) {
+			mi.Intersect(o)
+		})
+	}
+
+	for v := range m.theSet {
+		if !o.Contains(v) {
+			delete(m.theSet, v)
+		}
+	}
+
+	return m
+}
+
+// Difference returns the set of elements in m that are not in o.
+func (m *strSet) Difference(o *strSet) *strSet {
+	if !m.mutable {
+		return m.WithMutable(func(mi *strSet) {
+			mi.Difference(o)
+		})
+	}
+
+	for v := range o.Range() {
+		delete(m.theSet, v)
+	}
+
+	return m
+}
+
+// IsSubset returns whether every element of m is also an element of o.
+func (m *strSet) IsSubset(o *strSet) bool {
+	if m.Len() > o.Len() {
+		return false
+	}
+
+	for v := range m.Range() {
+		if !o.Contains(v) {
+			return false
+		}
+	}
+
+	return true
+}
+func (s *strSet) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
+	if s == nil {
+		return true
+	}
+
+	if s.Mutable() {
+		return false
+	}
+	return true
+}
+
+// strSetDiff is the change set between two strSet values, as returned by
+// strSet.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set.
+type strSetDiff struct {
+	Replaced bool
+	Value    *strSet
+
+	// Add and Remove hold the elements that were added and removed
+	// respectively, in no particular order
+	Add    []string
+	Remove []string
+}
+
+// Diff returns the change set required to turn m into other, or nil if there
+// is no difference.
+func (m *strSet) Diff(other *strSet) *strSetDiff {
+	if m == other {
+		return nil
+	}
+
+	if m == nil || other == nil {
+		return &strSetDiff{Replaced: true, Value: other}
+	}
+
+	res := new(strSetDiff)
+
+	for v := range other.Range() {
+		if !m.Contains(v) {
+			res.Add = append(res.Add, v)
+		}
+	}
+
+	for v := range m.Range() {
+		if !other.Contains(v) {
+			res.Remove = append(res.Remove, v)
+		}
+	}
+
+	if len(res.Add) == 0 && len(res.Remove) == 0 {
+		return nil
+	}
+
+	return res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to m.
+func (m *strSet) Patch(d *strSetDiff) *strSet {
+	if d == nil {
+		return m
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if m == nil {
+		m = newStrSet()
+	}
+
+	return m.WithMutable(func(mi *strSet) {
+		mi.Remove(d.Remove...)
+		mi.Add(d.Add...)
+	})
+}
+
+// MarshalJSON implements json.Marshaler. m is marshalled as a JSON array of
+// its elements, in no particular order.
+func (m *strSet) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+
+	v := make([]string, 0, m.Len())
+
+	for e := range m.Range() {
+		v = append(v, e)
+	}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. m is set to an immutable set
+// containing the unmarshalled elements. Because *m is replaced, m should be a
+// new value rather than one shared with other code.
+func (m *strSet) UnmarshalJSON(b []byte) error {
+	var v []string
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*m = *newStrSet(v...)
+
+	return nil
+}
+
 // intS is an immutable type and has the following template:
 //
-// 	[]int
-//
+//	[]int
 type intS struct {
 	theSlice []int
 	mutable  bool
@@ -145,6 +458,26 @@ func (m *intS) Append(v ...int) *intS {
 
 	return res
 }
+
+func (m *intS) Slice(i, j int) *intS {
+	if m.mutable {
+		m.theSlice = m.theSlice[i:j]
+		return m
+	}
+
+	resSlice := make([]int, j-i)
+	copy(resSlice, m.theSlice[i:j])
+
+	res := &intS{
+		theSlice: resSlice,
+	}
+
+	return res
+}
+
+func (m *intS) Concat(o *intS) *intS {
+	return m.Append(o.Range()...)
+}
 func (s *intS) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	if s == nil {
 		return true
@@ -156,13 +489,119 @@ func (s *intS) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	return true
 }
 
-//
+// intSDiff is the change set between two intS values, as returned by
+// intS.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set.
+type intSDiff struct {
+	Replaced bool
+	Value    *intS
+
+	// Len is the length of the new value; elements beyond Len are truncated
+	// and elements are appended to reach Len as required
+	Len int
+
+	// Set holds the elements, by index, that were added or changed
+	Set map[int]int
+}
+
+// Diff returns the change set required to turn m into other, or nil if there
+// is no difference. Elements are compared by index.
+func (m *intS) Diff(other *intS) *intSDiff {
+	if m == other {
+		return nil
+	}
+
+	if m == nil || other == nil {
+		return &intSDiff{Replaced: true, Value: other}
+	}
+
+	res := &intSDiff{
+		Len: other.Len(),
+		Set: make(map[int]int),
+	}
+
+	for i := 0; i < other.Len(); i++ {
+		ov := other.Get(i)
+		if i >= m.Len() {
+			res.Set[i] = ov
+			continue
+		}
+
+		v := m.Get(i)
+
+		if v != ov {
+			res.Set[i] = ov
+		}
+	}
+
+	if m.Len() == other.Len() && len(res.Set) == 0 {
+		return nil
+	}
+
+	return res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to m.
+func (m *intS) Patch(d *intSDiff) *intS {
+	if d == nil {
+		return m
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if m == nil {
+		m = new(intS)
+	}
+
+	return m.WithMutable(func(mi *intS) {
+		if l := mi.Len(); l > d.Len {
+			mi.Slice(0, d.Len)
+		} else if l < d.Len {
+			mi.Append(make([]int, d.Len-l)...)
+		}
+
+		for i, v := range d.Set {
+			mi.Set(i, v)
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. m is marshalled as a JSON array.
+func (m *intS) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+
+	if m.Len() == 0 {
+		return []byte("[]"), nil
+	}
+
+	return json.Marshal(m.Range())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. m is set to an immutable slice
+// containing the unmarshalled elements. Because *m is replaced, m should be a
+// new value rather than one shared with other code.
+func (m *intS) UnmarshalJSON(b []byte) error {
+	var v []int
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*m = *newIntS(v...)
+
+	return nil
+}
+
 // Dummy is an immutable type and has the following template:
 //
-// 	struct {
-// 		Name string
-// 	}
-//
+//	struct {
+//		Name string
+//	}
 type Dummy struct {
 	field_Name string
 
@@ -237,6 +676,107 @@ func (s *Dummy) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	seen[s] = true
 	return true
 }
+
+// DummyDiff is the change set between two Dummy values, as returned by
+// Dummy.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set. Otherwise a non-nil
+// field holds either the new value of the corresponding field or, where the
+// field is itself of an immutable type, the change set for that field.
+type DummyDiff struct {
+	Replaced bool
+	Value    *Dummy
+
+	Name *string
+}
+
+// Diff returns the change set required to turn s into other, or nil if there
+// is no difference.
+func (s *Dummy) Diff(other *Dummy) *DummyDiff {
+	if s == other {
+		return nil
+	}
+
+	if s == nil || other == nil {
+		return &DummyDiff{Replaced: true, Value: other}
+	}
+
+	var res DummyDiff
+	changed := false
+
+	if s.field_Name != other.field_Name {
+		v := other.field_Name
+		res.Name = &v
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return &res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to s.
+func (s *Dummy) Patch(d *DummyDiff) *Dummy {
+	if d == nil {
+		return s
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if s == nil {
+		s = new(Dummy)
+	}
+
+	return s.WithMutable(func(si *Dummy) {
+		if d.Name != nil {
+			si.field_Name = *d.Name
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. The exported fields of the template
+// for Dummy are marshalled according to their names and tags.
+func (s *Dummy) MarshalJSON() ([]byte, error) {
+	if s == nil {
+		return []byte("null"), nil
+	}
+
+	v := struct {
+		Name string
+	}{
+		Name: s.field_Name,
+	}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The exported fields of the
+// template for Dummy are unmarshalled according to their names and tags;
+// other fields, and those absent from the JSON, are left unchanged. The result
+// is built via WithMutable. Because json.Unmarshaler stores the result in
+// *s, s should be a new value rather than one shared with other code.
+func (s *Dummy) UnmarshalJSON(b []byte) error {
+	v := struct {
+		Name string
+	}{
+		Name: s.field_Name,
+	}
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*s = *s.WithMutable(func(si *Dummy) {
+		si.field_Name = v.Name
+	})
+
+	return nil
+}
+
 func (s *Dummy) Name() string {
 	return s.field_Name
 }
@@ -253,16 +793,14 @@ func (s *Dummy) SetName(n string) *Dummy {
 	return &res
 }
 
-//
 // Dummy2 is an immutable type and has the following template:
 //
-// 	struct {
-// 		name	[]byte
-// 		other	*Dummy3
-// 		mine	MyIntf
-// 		another	MyType
-// 	}
-//
+//	struct {
+//		name	[]byte
+//		other	*Dummy3
+//		mine	MyIntf
+//		another	MyType
+//	}
 type Dummy2 struct {
 	field_name    []byte
 	field_other   *Dummy3
@@ -347,6 +885,129 @@ func (s *Dummy2) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	}
 	return true
 }
+
+// Dummy2Diff is the change set between two Dummy2 values, as returned by
+// Dummy2.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set. Otherwise a non-nil
+// field holds either the new value of the corresponding field or, where the
+// field is itself of an immutable type, the change set for that field.
+type Dummy2Diff struct {
+	Replaced bool
+	Value    *Dummy2
+
+	name    *[]byte
+	other   *Dummy3Diff
+	mine    *MyIntf
+	another *MyType
+}
+
+// Diff returns the change set required to turn s into other, or nil if there
+// is no difference.
+func (s *Dummy2) Diff(other *Dummy2) *Dummy2Diff {
+	if s == other {
+		return nil
+	}
+
+	if s == nil || other == nil {
+		return &Dummy2Diff{Replaced: true, Value: other}
+	}
+
+	var res Dummy2Diff
+	changed := false
+
+	if !reflect.DeepEqual(s.field_name, other.field_name) {
+		v := other.field_name
+		res.name = &v
+		changed = true
+	}
+
+	if d := s.field_other.Diff(other.field_other); d != nil {
+		res.other = d
+		changed = true
+	}
+
+	if s.field_mine != other.field_mine {
+		v := other.field_mine
+		res.mine = &v
+		changed = true
+	}
+
+	if !reflect.DeepEqual(s.field_another, other.field_another) {
+		v := other.field_another
+		res.another = &v
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return &res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to s.
+func (s *Dummy2) Patch(d *Dummy2Diff) *Dummy2 {
+	if d == nil {
+		return s
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if s == nil {
+		s = new(Dummy2)
+	}
+
+	return s.WithMutable(func(si *Dummy2) {
+		if d.name != nil {
+			si.field_name = *d.name
+		}
+		if d.other != nil {
+			si.field_other = si.field_other.Patch(d.other)
+		}
+		if d.mine != nil {
+			si.field_mine = *d.mine
+		}
+		if d.another != nil {
+			si.field_another = *d.another
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. The exported fields of the template
+// for Dummy2 are marshalled according to their names and tags.
+func (s *Dummy2) MarshalJSON() ([]byte, error) {
+	if s == nil {
+		return []byte("null"), nil
+	}
+
+	v := struct {
+	}{}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The exported fields of the
+// template for Dummy2 are unmarshalled according to their names and tags;
+// other fields, and those absent from the JSON, are left unchanged. The result
+// is built via WithMutable. Because json.Unmarshaler stores the result in
+// *s, s should be a new value rather than one shared with other code.
+func (s *Dummy2) UnmarshalJSON(b []byte) error {
+	v := struct {
+	}{}
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*s = *s.WithMutable(func(si *Dummy2) {
+	})
+
+	return nil
+}
+
 func (s *Dummy2) another() MyType {
 	return s.field_another
 }
@@ -362,6 +1023,7 @@ func (s *Dummy2) setAnother(n MyType) *Dummy2 {
 	res.field_another = n
 	return &res
 }
+
 func (s *Dummy2) mine() MyIntf {
 	return s.field_mine
 }
@@ -377,6 +1039,7 @@ func (s *Dummy2) setMine(n MyIntf) *Dummy2 {
 	res.field_mine = n
 	return &res
 }
+
 func (s *Dummy2) name() []byte {
 	return s.field_name
 }
@@ -392,6 +1055,7 @@ func (s *Dummy2) setName(n []byte) *Dummy2 {
 	res.field_name = n
 	return &res
 }
+
 func (s *Dummy2) other() *Dummy3 {
 	return s.field_other
 }
@@ -408,13 +1072,11 @@ func (s *Dummy2) setOther(n *Dummy3) *Dummy2 {
 	return &res
 }
 
-//
 // Dummy3 is an immutable type and has the following template:
 //
-// 	struct {
-// 		other *Dummy2
-// 	}
-//
+//	struct {
+//		other *Dummy2
+//	}
 type Dummy3 struct {
 	field_other *Dummy2
 
@@ -496,6 +1158,99 @@ func (s *Dummy3) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	}
 	return true
 }
+
+// Dummy3Diff is the change set between two Dummy3 values, as returned by
+// Dummy3.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set. Otherwise a non-nil
+// field holds either the new value of the corresponding field or, where the
+// field is itself of an immutable type, the change set for that field.
+type Dummy3Diff struct {
+	Replaced bool
+	Value    *Dummy3
+
+	other *Dummy2Diff
+}
+
+// Diff returns the change set required to turn s into other, or nil if there
+// is no difference.
+func (s *Dummy3) Diff(other *Dummy3) *Dummy3Diff {
+	if s == other {
+		return nil
+	}
+
+	if s == nil || other == nil {
+		return &Dummy3Diff{Replaced: true, Value: other}
+	}
+
+	var res Dummy3Diff
+	changed := false
+
+	if d := s.field_other.Diff(other.field_other); d != nil {
+		res.other = d
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return &res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to s.
+func (s *Dummy3) Patch(d *Dummy3Diff) *Dummy3 {
+	if d == nil {
+		return s
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if s == nil {
+		s = new(Dummy3)
+	}
+
+	return s.WithMutable(func(si *Dummy3) {
+		if d.other != nil {
+			si.field_other = si.field_other.Patch(d.other)
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. The exported fields of the template
+// for Dummy3 are marshalled according to their names and tags.
+func (s *Dummy3) MarshalJSON() ([]byte, error) {
+	if s == nil {
+		return []byte("null"), nil
+	}
+
+	v := struct {
+	}{}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The exported fields of the
+// template for Dummy3 are unmarshalled according to their names and tags;
+// other fields, and those absent from the JSON, are left unchanged. The result
+// is built via WithMutable. Because json.Unmarshaler stores the result in
+// *s, s should be a new value rather than one shared with other code.
+func (s *Dummy3) UnmarshalJSON(b []byte) error {
+	v := struct {
+	}{}
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*s = *s.WithMutable(func(si *Dummy3) {
+	})
+
+	return nil
+}
+
 func (s *Dummy3) other() *Dummy2 {
 	return s.field_other
 }
@@ -73,3 +73,54 @@ var _ MyIntf = MyType([]string{})
 var _ = Dummy{} // ERROR
 
 type Blah = Dummy // ok; use of alias
+
+// immutableGen:set
+type _Imm_strSet map[string]struct{}
+
+var _ strSet          // ERROR
+var _ map[strSet]bool // ERROR
+var _ = &strSet{}     // ERROR
+
+func useSet(s *strSet) {
+	_ = s.Range() // ERROR
+
+	for range s.Range() {
+	}
+}
+
+var escaped *Dummy
+
+func mutations(d *Dummy, d2 *Dummy2, x *intS, s *strSet, ch chan *Dummy) {
+	d.WithMutable(func(dm *Dummy) {
+		escaped = dm // ERROR
+
+		alias := dm
+		escaped = alias // ERROR
+
+		ch <- dm // ERROR
+
+		local := dm
+		local.SetName("ok")
+
+		escaped = dm.AsImmutable(nil)
+	})
+
+	d3 := new(Dummy3).AsMutable()
+	d3 = d3.setOther(d2)
+	d2 = d2.setOther(d3) // ERROR
+
+	d3.AsImmutable(nil)
+	d2 = d2.setOther(d3)
+
+	x.Range()[0] = 5       // ERROR x 2
+	delete(s.Range(), "a") // ERROR x 2
+}
+
+func reassignRange(x *intS) {
+	for _, v := range x.Range() {
+		print(v)
+	}
+
+	r := append([]int{}, x.Range()...)
+	r[0] = 1
+}
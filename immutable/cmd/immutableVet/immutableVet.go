@@ -1,56 +1,32 @@
+// Copyright (c) 2016 Paul Jolly <paul@myitcv.org.uk>, all rights reserved.
+// Use of this document is governed by a license found in the LICENSE document.
+
+// immutableVet checks the correct use of the immutable types generated by
+// immutableGen. It is a driver for the myitcv.io/immutable/immutablevet
+// Analyzer, and can also be used as a vet tool:
+//
+//	go vet -vettool=$(which immutableVet) ./...
 package main
 
 import (
-	"bytes"
 	"flag"
 	"fmt"
-	"go/ast"
-	"go/printer"
 	"go/token"
 	"go/types"
 	"os"
 	"path/filepath"
+	"reflect"
 	"sort"
 	"strings"
 
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/unitchecker"
 	"golang.org/x/tools/go/packages"
-	"myitcv.io/gogenerate"
-	"myitcv.io/immutable"
-	"myitcv.io/immutable/util"
-)
-
-const (
-	skipFileComment = "//" + immutable.CmdImmutableVet + ":skipFile"
+	"myitcv.io/immutable/immutablevet"
 )
 
 var fset = token.NewFileSet()
 
-type immutableVetter struct {
-	pkgs []*packages.Package
-
-	wd string
-
-	skipFiles map[string]bool
-
-	info *types.Info
-
-	// immTmpls is the set of immutable template types in the package
-	// being analysed
-	immTmpls map[types.Type]bool
-
-	// helper field used to hold Range() method calls on immutable types
-	rngs map[*ast.Ident]bool
-
-	// valid composite literals
-	vcls map[*ast.CompositeLit]bool
-
-	errlist []immErr
-}
-
-var typesCache = map[string]bool{
-	"time.Time": true,
-}
-
 type immErr struct {
 	pos token.Position
 	msg string
@@ -58,9 +34,11 @@ type immErr struct {
 
 type errors []immErr
 
-var immIntf *types.Interface
-
 func main() {
+	if isVetTool(os.Args[1:]) {
+		unitchecker.Main(immutablevet.Analyzer)
+	}
+
 	flag.Parse()
 
 	wd, err := os.Getwd()
@@ -79,490 +57,155 @@ func main() {
 	}
 }
 
-func vet(wd string, args []string) []immErr {
-	var emsgs []immErr
-
-	// vetting phase: vet all packages packages passed in through the command line
-	iv := newImmutableVetter(wd, args)
-
-	emsgs = append(emsgs, iv.vetPackages()...)
-
-	for i := range emsgs {
-		rel, err := filepath.Rel(wd, emsgs[i].pos.Filename)
-		if err != nil {
-			fatalf("relative path error, %v", err)
-		}
-
-		emsgs[i].pos.Filename = rel
-	}
-
-	sort.Sort(errors(emsgs))
-
-	return emsgs
-}
-
-func (iv *immutableVetter) ensurePointerTyp(n ast.Node, typ ast.Expr) {
-	if ts, ok := n.(*ast.TypeSpec); ok {
-		if ts.Assign.IsValid() {
-			// we are an alias; this is fine in all cases
-			return
-		}
-	}
-	t := iv.info.Types[typ].Type
-	if t == nil {
-		return
-	}
-	p := types.NewPointer(t)
-	switch util.IsImmType(p).(type) {
-	case util.ImmTypeMap, util.ImmTypeSlice, util.ImmTypeStruct:
-		iv.errorf(n.Pos(), "type should be %v", p)
-	}
-}
-
-func (iv *immutableVetter) Visit(node ast.Node) ast.Visitor {
-
-	switch node := node.(type) {
-	case *ast.File:
-		for _, cg := range node.Comments {
-			for _, c := range cg.List {
-				if c.Text == skipFileComment {
-					iv.skipFiles[fset.Position(node.Pos()).Filename] = true
-					return nil
-				}
-			}
-		}
-	case *ast.ValueSpec:
-		iv.ensurePointerTyp(node, node.Type)
-	case *ast.ArrayType:
-		iv.ensurePointerTyp(node, node.Elt)
-	case *ast.MapType:
-		iv.ensurePointerTyp(node, node.Key)
-		iv.ensurePointerTyp(node, node.Value)
-	case *ast.Field:
-		iv.ensurePointerTyp(node, node.Type)
-	case *ast.UnaryExpr:
-		if node.Op != token.AND {
-			break
-		}
-
-		cl, ok := node.X.(*ast.CompositeLit)
-		if !ok {
-			break
-		}
-
-		t := iv.info.Types[cl.Type].Type
-		p := types.NewPointer(t)
-		switch util.IsImmType(p).(type) {
-		case util.ImmTypeMap, util.ImmTypeSlice, util.ImmTypeStruct:
-			iv.errorf(node.Pos(), "construct using new() or generated constructors")
-			iv.vcls[cl] = true
-		}
-	case *ast.CompositeLit:
-		if ok := iv.vcls[node]; ok {
-			break
-		}
-
-		iv.ensurePointerTyp(node, node.Type)
-	case *ast.TypeSpec:
-		iv.ensurePointerTyp(node, node.Type)
-	case *ast.SelectorExpr:
-		sel, ok := iv.info.Selections[node]
-		if !ok {
-			// this is fine... !ok implies a selector expression
-			// that is a qualified identifier as opposed to a method
-			// field selector
-			break
-		}
-
-		if !isImmListOrMap(sel.Recv()) {
-			break
-		}
-
-		switch node.Sel.Name {
-		case "Range":
-			if _, ok := iv.rngs[node.Sel]; !ok {
-				iv.rngs[node.Sel] = false
-			}
-		}
-	case *ast.RangeStmt:
-		v := node.X
-		ce, ok := v.(*ast.CallExpr)
-		if !ok {
-			break
-		}
-
-		e := ce.Fun
-		se, ok := e.(*ast.SelectorExpr)
-		if !ok {
-			break
-		}
-
-		sel, ok := iv.info.Selections[se]
-		if !ok {
-			// then it must be a qualified identifier
-			break
-		}
-
-		if !isImmListOrMap(sel.Recv()) {
-			break
-		}
-
-		if sel.Kind() != types.MethodVal {
-			break
-		}
-
-		ri := se.Sel
-		if ri.Name != "Range" {
-			break
-		}
-		iv.rngs[ri] = true
-	case *ast.CallExpr:
-		switch fun := node.Fun.(type) {
-		case *ast.Ident:
-			if fun.Name != "append" {
-				break
-			}
-
-			if len(node.Args) != 2 {
-				break
-			}
-
-			e := node.Args[1]
-			ce, ok := e.(*ast.CallExpr)
-			if !ok {
-				break
-			}
-
-			se, ok := ce.Fun.(*ast.SelectorExpr)
-			if !ok {
-				break
-			}
-
-			sel, ok := iv.info.Selections[se]
-			if !ok {
-				break
-			}
-
-			if !isImmListOrMap(sel.Recv()) {
-				break
-			}
-
-			ri := se.Sel
-			if ri.Name != "Range" {
-				break
-			}
-
-			if node.Ellipsis == node.Args[1].End() {
-				iv.rngs[ri] = true
-			}
-		case *ast.SelectorExpr:
-			sel, ok := iv.info.Selections[fun]
-			if !ok {
-				// this is fine... !ok implies a selector expression
-				// that is a qualified identifier as opposed to a method
-				// field selector
-				break
-			}
-
-			if !isImmListOrMap(sel.Recv()) {
-				break
-			}
-
-			if sel.Kind() != types.MethodVal {
-				break
-			}
-
-			ri := fun.Sel
-			if ri.Name != "Append" {
-				break
-			}
-
-			if len(node.Args) != 1 {
-				break
-			}
-
-			if node.Ellipsis == token.NoPos {
-				break
-			}
-
-			ace, ok := node.Args[0].(*ast.CallExpr)
-			if !ok {
-				break
-			}
-
-			{
-				se, ok := ace.Fun.(*ast.SelectorExpr)
-				if !ok {
-					break
-				}
-
-				sel, ok := iv.info.Selections[se]
-				if !ok {
-					// this is fine... !ok implies a selector expression
-					// that is a qualified identifier as opposed to a method
-					// field selector
-					break
-				}
-
-				if !isImmListOrMap(sel.Recv()) {
-					break
-				}
-
-				if sel.Kind() != types.MethodVal {
-					break
-				}
-
-				ri := se.Sel
-				if ri.Name == "Range" {
-					iv.rngs[ri] = true
-				}
-			}
-		}
+// isVetTool reports whether we have been invoked by go vet -vettool, in
+// which case the command line is that understood by unitchecker.
+func isVetTool(args []string) bool {
+	if len(args) == 0 {
+		return false
 	}
-	return iv
-}
 
-func isImmListOrMap(t types.Type) bool {
-	switch util.IsImmType(t).(type) {
-	case util.ImmTypeMap, util.ImmTypeSlice:
+	if len(args) == 1 && (args[0] == "-V=full" || args[0] == "-flags") {
 		return true
 	}
 
-	return false
+	return strings.HasSuffix(args[len(args)-1], ".cfg")
 }
 
-func newImmutableVetter(wd string, args []string) *immutableVetter {
-	ip := "myitcv.io/immutable"
-	allPkgs := append(args, ip)
-
+func vet(wd string, args []string) []immErr {
 	cfg := &packages.Config{
 		Mode:  packages.LoadSyntax,
 		Fset:  fset,
 		Tests: true,
 	}
-	pkgs, err := packages.Load(cfg, allPkgs...)
+	pkgs, err := packages.Load(cfg, args...)
 	if err != nil {
 		fatalf("could not load pacakages %v: %v", pkgs, err)
 	}
 
-	var immPkg *packages.Package
+	d := newDriver()
 
-	vetPkgs := []*packages.Package{}
-	for _, p := range pkgs {
-		if p.PkgPath == ip {
-			immPkg = p
-			continue
+	// analyse packages in dependency order so that the facts of a package
+	// are available to those that import it
+	packages.Visit(pkgs, nil, func(p *packages.Package) {
+		if p.TypesInfo == nil || len(p.Syntax) == 0 {
+			return
 		}
-		vetPkgs = append(vetPkgs, p)
-	}
-
-	if immPkg == nil {
-		fatalf("failed to load immutable package")
-	}
 
-	o := immPkg.Types.Scope().Lookup("Immutable")
-
-	if o == nil {
-		fatalf("failed to find anything called Immutable in pkg scope of %v", ip)
-	}
+		d.analyse(p)
+	})
 
-	tn, ok := o.(*types.TypeName)
-	if !ok {
-		fatalf("Immutable is not a *types.TypeName: %T", o)
-	}
+	emsgs := d.errs
 
-	nmd, ok := tn.Type().(*types.Named)
-	if !ok {
-		fatalf("Immutable type is not a *types.Named: %T", tn.Type())
-	}
+	for i := range emsgs {
+		rel, err := filepath.Rel(wd, emsgs[i].pos.Filename)
+		if err != nil {
+			fatalf("relative path error, %v", err)
+		}
 
-	intf, ok := nmd.Underlying().(*types.Interface)
-	if !ok {
-		fatalf("Underlying type is not a *types.Interface: %T", nmd.Underlying())
+		emsgs[i].pos.Filename = rel
 	}
 
-	immIntf = intf
+	sort.Sort(errors(emsgs))
 
-	return &immutableVetter{
-		pkgs:      vetPkgs,
-		vcls:      make(map[*ast.CompositeLit]bool),
-		wd:        wd,
-		skipFiles: make(map[string]bool),
-	}
+	return emsgs
 }
 
-func (iv *immutableVetter) isImmTmpl(t types.Type) bool {
-	switch t := t.(type) {
-	case *types.Pointer:
-		return iv.isImmTmpl(t.Elem())
-	}
+// driver runs immutablevet.Analyzer on packages loaded via go/packages,
+// holding facts in memory.
+type driver struct {
+	facts    map[objFactKey]analysis.Fact
+	pkgFacts map[pkgFactKey]analysis.Fact
 
-	return iv.immTmpls[t]
+	// seen is used to drop the duplicate diagnostics that result from
+	// analysing both a package and its test variant
+	seen map[immErr]bool
+	errs []immErr
 }
 
-func (iv *immutableVetter) vetPackages() []immErr {
-	for _, pkg := range iv.pkgs {
-		info := pkg.TypesInfo
-
-		iv.rngs = make(map[*ast.Ident]bool)
-		iv.immTmpls = make(map[types.Type]bool)
-		iv.info = info
-
-		for _, f := range pkg.Syntax {
-			for _, d := range f.Decls {
-				gd, ok := d.(*ast.GenDecl)
-				if !ok {
-					continue
-				}
-
-				if gd.Tok != token.TYPE {
-					continue
-				}
-
-				for _, s := range gd.Specs {
-					ts := s.(*ast.TypeSpec)
-
-					_, ok := util.IsImmTmpl(ts)
-					if !ok {
-						continue
-					}
-
-					o := info.ObjectOf(ts.Name)
-					iv.immTmpls[o.Type()] = true
-
-					st, ok := o.Type().(*types.Named).Underlying().(*types.Struct)
-					if !ok {
-						continue
-					}
-
-					for i := 0; i < st.NumFields(); i++ {
-						f := st.Field(i)
-						if !isImmType(f.Type()) {
-							msg := fmt.Sprintf("immutable struct field must be immutable type; %v is not", f.Type())
-							iv.errorf(f.Pos(), msg)
-						}
-					}
-				}
-			}
-		}
-
-		for _, fnode := range pkg.Syntax {
-			ast.Walk(iv, fnode)
-		}
-
-		for exp, t := range info.Types {
-			out := bytes.NewBuffer(nil)
-			printer.Fprint(out, fset, exp)
-
-			switch {
-			case t.IsType():
-				typ := t.Type
-
-				if !iv.isImmTmpl(typ) {
-					continue
-				}
-
-				fn := fset.Position(exp.Pos()).Filename
-
-				if !gogenerate.FileGeneratedBy(fn, immutable.CmdImmutableGen) {
-					iv.errorf(exp.Pos(), "template type %v should never get used", typ)
-				}
-
-			case t.IsValue():
-				p := types.NewPointer(t.Type)
-				switch util.IsImmType(p).(type) {
-				case util.ImmTypeMap:
-				case util.ImmTypeSlice:
-				case util.ImmTypeStruct:
-				default:
-					continue
-				}
-
-				fn := fset.Position(exp.Pos()).Filename
+type objFactKey struct {
+	obj types.Object
+	typ reflect.Type
+}
 
-				if !iv.skipFiles[fn] {
-					iv.errorf(exp.Pos(), "non-pointer value of immutable type %v found", p)
-				}
-			}
+type pkgFactKey struct {
+	pkg *types.Package
+	typ reflect.Type
+}
 
-		}
+func newDriver() *driver {
+	return &driver{
+		facts:    make(map[objFactKey]analysis.Fact),
+		pkgFacts: make(map[pkgFactKey]analysis.Fact),
+		seen:     make(map[immErr]bool),
+	}
+}
 
-		// find selector exprs which access properties of Immutable types
-		for exp, sel := range info.Selections {
-			isField := sel.Kind() == types.FieldVal
-			if !isField {
-				continue
+func (d *driver) analyse(p *packages.Package) {
+	pass := &analysis.Pass{
+		Analyzer:   immutablevet.Analyzer,
+		Fset:       fset,
+		Files:      p.Syntax,
+		OtherFiles: p.OtherFiles,
+		Pkg:        p.Types,
+		TypesInfo:  p.TypesInfo,
+		TypesSizes: p.TypesSizes,
+		ResultOf:   make(map[*analysis.Analyzer]interface{}),
+		Report: func(diag analysis.Diagnostic) {
+			e := immErr{
+				pos: fset.Position(diag.Pos),
+				msg: diag.Message,
 			}
 
-			if util.IsImmType(sel.Recv()) == nil {
-				continue
+			if d.seen[e] {
+				return
 			}
 
-			if iv.skipFiles[fset.Position(exp.X.Pos()).Filename] {
-				continue
+			d.seen[e] = true
+			d.errs = append(d.errs, e)
+		},
+		ImportObjectFact: func(obj types.Object, fact analysis.Fact) bool {
+			return copyFact(d.facts[objFactKey{obj, reflect.TypeOf(fact)}], fact)
+		},
+		ExportObjectFact: func(obj types.Object, fact analysis.Fact) {
+			d.facts[objFactKey{obj, reflect.TypeOf(fact)}] = fact
+		},
+		ImportPackageFact: func(pkg *types.Package, fact analysis.Fact) bool {
+			return copyFact(d.pkgFacts[pkgFactKey{pkg, reflect.TypeOf(fact)}], fact)
+		},
+		ExportPackageFact: func(fact analysis.Fact) {
+			d.pkgFacts[pkgFactKey{p.Types, reflect.TypeOf(fact)}] = fact
+		},
+		AllObjectFacts: func() []analysis.ObjectFact {
+			var res []analysis.ObjectFact
+			for k, f := range d.facts {
+				res = append(res, analysis.ObjectFact{Object: k.obj, Fact: f})
 			}
-
-			oname := sel.Obj().Name()
-			iv.errorf(exp.X.Pos(), "should not be using %v of %v immutable type", oname, sel.Recv())
-		}
-
-		for k, v := range iv.rngs {
-			if v == false {
-				msg := fmt.Sprintf("Range() of immutable type must appear in a range statement or used with an ellipsis as the second argument to append")
-				iv.errorf(k.NamePos, msg)
+			return res
+		},
+		AllPackageFacts: func() []analysis.PackageFact {
+			var res []analysis.PackageFact
+			for k, f := range d.pkgFacts {
+				res = append(res, analysis.PackageFact{Package: k.pkg, Fact: f})
 			}
-		}
+			return res
+		},
 	}
 
-	return iv.errlist
-}
-
-func isImmType(t types.Type) bool {
-	if v, ok := typesCache[t.String()]; ok {
-		return v
+	if _, err := immutablevet.Analyzer.Run(pass); err != nil {
+		fatalf("failed to analyse %v: %v", p.PkgPath, err)
 	}
+}
 
-	switch t := t.(type) {
-	case *types.Named:
-
-		typesCache[t.String()] = true
-
-		v := isImmType(t.Underlying())
-		typesCache[t.String()] = v
-
-		return v
-	case *types.Basic:
-		return true
-	case *types.Map, *types.Slice:
-		return false
-	case *types.Pointer:
-		return util.IsImmType(t) != nil
-	case *types.Struct:
-		for i := 0; i < t.NumFields(); i++ {
-			f := t.Field(i)
-			if !isImmType(f.Type()) {
-				return false
-			}
-		}
-
-		return true
-	case *types.Interface:
-		return types.Implements(t, immIntf)
-	case *types.Signature:
+// copyFact copies the fact from to the fact to, returning false if from is
+// nil.
+func copyFact(from, to analysis.Fact) bool {
+	if from == nil {
 		return false
-	default:
-		fatalf("unable to handle type %T %v", t, t)
 	}
 
-	return false
-}
+	reflect.ValueOf(to).Elem().Set(reflect.ValueOf(from).Elem())
 
-func (iv *immutableVetter) errorf(pos token.Pos, format string, args ...interface{}) {
-	msg := fmt.Sprintf(format, args...)
-	iv.errlist = append(iv.errlist, immErr{
-		pos: fset.Position(pos),
-		msg: msg,
-	})
+	return true
 }
 
 func fatalf(format string, args ...interface{}) {
@@ -39,6 +39,19 @@ _testFiles/test.go:55:7: non-pointer value of immutable type *myitcv.io/immutabl
 _testFiles/test.go:56:8: non-pointer value of immutable type *myitcv.io/immutable/cmd/immutableVet/_testFiles.intS found
 _testFiles/test.go:73:9: non-pointer value of immutable type *myitcv.io/immutable/cmd/immutableVet/_testFiles.Dummy found
 _testFiles/test.go:73:9: type should be *myitcv.io/immutable/cmd/immutableVet/_testFiles.Dummy
+_testFiles/test.go:80:5: type should be *myitcv.io/immutable/cmd/immutableVet/_testFiles.strSet
+_testFiles/test.go:81:7: type should be *myitcv.io/immutable/cmd/immutableVet/_testFiles.strSet
+_testFiles/test.go:82:9: construct using new() or generated constructors
+_testFiles/test.go:82:10: non-pointer value of immutable type *myitcv.io/immutable/cmd/immutableVet/_testFiles.strSet found
+_testFiles/test.go:85:8: Range() of immutable type must appear in a range statement or used with an ellipsis as the second argument to append
+_testFiles/test.go:95:13: mutable value escapes WithMutable callback
+_testFiles/test.go:98:13: mutable value escapes WithMutable callback
+_testFiles/test.go:100:9: mutable value escapes WithMutable callback
+_testFiles/test.go:110:19: mutable value stored in immutable value; use AsImmutable or WithMutable
+_testFiles/test.go:115:2: result of Range() of immutable type must not be written to
+_testFiles/test.go:115:4: Range() of immutable type must appear in a range statement or used with an ellipsis as the second argument to append
+_testFiles/test.go:116:9: result of Range() of immutable type must not be written to
+_testFiles/test.go:116:11: Range() of immutable type must appear in a range statement or used with an ellipsis as the second argument to append
 `
 
 	wd, err := os.Getwd()
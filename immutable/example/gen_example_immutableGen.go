@@ -1,10 +1,15 @@
 // Code generated by immutableGen. DO NOT EDIT.
 
+// Copyright (c) 2016 Paul Jolly <paul@myitcv.org.uk>, all rights reserved.
+// Use of this document is governed by a license found in the LICENSE document.
+
 package example
 
+//go:generate echo "hello world"
 //immutableVet:skipFile
 
 import (
+	"encoding/json"
 	"myitcv.io/immutable"
 )
 
@@ -12,8 +17,7 @@ import (
 //
 // MyMap is an immutable type and has the following template:
 //
-// 	map[string]*MySlice
-//
+//	map[string]*MySlice
 type MyMap struct {
 	theMap  map[string]*MySlice
 	mutable bool
@@ -183,12 +187,133 @@ func (s *MyMap) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	return true
 }
 
+// MyMapDiff is the change set between two MyMap values, as returned by
+// MyMap.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set.
+type MyMapDiff struct {
+	Replaced bool
+	Value    *MyMap
+
+	// Set holds the entries that were added
+	Set map[string]*MySlice
+
+	// Changed holds the change sets of the entries that were changed
+	Changed map[string]*MySliceDiff
+
+	// Del holds the keys of the entries that were deleted, in no particular
+	// order
+	Del []string
+}
+
+// Diff returns the change set required to turn m into other, or nil if there
+// is no difference.
+func (m *MyMap) Diff(other *MyMap) *MyMapDiff {
+	if m == other {
+		return nil
+	}
+
+	if m == nil || other == nil {
+		return &MyMapDiff{Replaced: true, Value: other}
+	}
+
+	res := &MyMapDiff{
+		Set:     make(map[string]*MySlice),
+		Changed: make(map[string]*MySliceDiff),
+	}
+
+	for k := range m.Range() {
+		if _, ok := other.Get(k); !ok {
+			res.Del = append(res.Del, k)
+		}
+	}
+
+	for k, ov := range other.Range() {
+		v, ok := m.Get(k)
+		if !ok {
+			res.Set[k] = ov
+			continue
+		}
+
+		if d := v.Diff(ov); d != nil {
+			res.Changed[k] = d
+		}
+	}
+
+	if len(res.Set) == 0 && len(res.Del) == 0 && len(res.Changed) == 0 {
+		return nil
+	}
+
+	return res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to m.
+func (m *MyMap) Patch(d *MyMapDiff) *MyMap {
+	if d == nil {
+		return m
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if m == nil {
+		m = NewMyMap()
+	}
+
+	return m.WithMutable(func(mi *MyMap) {
+		for _, k := range d.Del {
+			mi.Del(k)
+		}
+
+		for k, v := range d.Set {
+			mi.Set(k, v)
+		}
+
+		for k, vd := range d.Changed {
+			v, _ := mi.Get(k)
+			mi.Set(k, v.Patch(vd))
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. m is marshalled as a JSON object.
+func (m *MyMap) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+
+	if m.Len() == 0 {
+		return []byte("{}"), nil
+	}
+
+	return json.Marshal(m.Range())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. m is set to an immutable map
+// containing the unmarshalled entries. Because *m is replaced, m should be a
+// new value rather than one shared with other code.
+func (m *MyMap) UnmarshalJSON(b []byte) error {
+	var v map[string]*MySlice
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*m = *NewMyMap(func(mi *MyMap) {
+		for k, e := range v {
+			mi.Set(k, e)
+		}
+	})
+
+	return nil
+}
+
 // MySlice will be exported
 //
 // MySlice is an immutable type and has the following template:
 //
-// 	[]*MyMap
-//
+//	[]*MyMap
 type MySlice struct {
 	theSlice []*MyMap
 	mutable  bool
@@ -321,6 +446,26 @@ func (m *MySlice) Append(v ...*MyMap) *MySlice {
 
 	return res
 }
+
+func (m *MySlice) Slice(i, j int) *MySlice {
+	if m.mutable {
+		m.theSlice = m.theSlice[i:j]
+		return m
+	}
+
+	resSlice := make([]*MyMap, j-i)
+	copy(resSlice, m.theSlice[i:j])
+
+	res := &MySlice{
+		theSlice: resSlice,
+	}
+
+	return res
+}
+
+func (m *MySlice) Concat(o *MySlice) *MySlice {
+	return m.Append(o.Range()...)
+}
 func (s *MySlice) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	if s == nil {
 		return true
@@ -351,22 +496,138 @@ func (s *MySlice) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	return true
 }
 
+// MySliceDiff is the change set between two MySlice values, as returned by
+// MySlice.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set.
+type MySliceDiff struct {
+	Replaced bool
+	Value    *MySlice
+
+	// Len is the length of the new value; elements beyond Len are truncated
+	// and elements are appended to reach Len as required
+	Len int
+
+	// Set holds the elements, by index, that were added
+	Set map[int]*MyMap
+
+	// Changed holds the change sets, by index, of the elements that were
+	// changed
+	Changed map[int]*MyMapDiff
+}
+
+// Diff returns the change set required to turn m into other, or nil if there
+// is no difference. Elements are compared by index.
+func (m *MySlice) Diff(other *MySlice) *MySliceDiff {
+	if m == other {
+		return nil
+	}
+
+	if m == nil || other == nil {
+		return &MySliceDiff{Replaced: true, Value: other}
+	}
+
+	res := &MySliceDiff{
+		Len:     other.Len(),
+		Set:     make(map[int]*MyMap),
+		Changed: make(map[int]*MyMapDiff),
+	}
+
+	for i := 0; i < other.Len(); i++ {
+		ov := other.Get(i)
+		if i >= m.Len() {
+			res.Set[i] = ov
+			continue
+		}
+
+		v := m.Get(i)
+
+		if d := v.Diff(ov); d != nil {
+			res.Changed[i] = d
+		}
+	}
+
+	if m.Len() == other.Len() && len(res.Set) == 0 && len(res.Changed) == 0 {
+		return nil
+	}
+
+	return res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to m.
+func (m *MySlice) Patch(d *MySliceDiff) *MySlice {
+	if d == nil {
+		return m
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if m == nil {
+		m = new(MySlice)
+	}
+
+	return m.WithMutable(func(mi *MySlice) {
+		if l := mi.Len(); l > d.Len {
+			mi.Slice(0, d.Len)
+		} else if l < d.Len {
+			mi.Append(make([]*MyMap, d.Len-l)...)
+		}
+
+		for i, v := range d.Set {
+			mi.Set(i, v)
+		}
+
+		for i, vd := range d.Changed {
+			mi.Set(i, mi.Get(i).Patch(vd))
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. m is marshalled as a JSON array.
+func (m *MySlice) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+
+	if m.Len() == 0 {
+		return []byte("[]"), nil
+	}
+
+	return json.Marshal(m.Range())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. m is set to an immutable slice
+// containing the unmarshalled elements. Because *m is replaced, m should be a
+// new value rather than one shared with other code.
+func (m *MySlice) UnmarshalJSON(b []byte) error {
+	var v []*MyMap
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*m = *NewMySlice(v...)
+
+	return nil
+}
+
 // MyStruct will be exported.
 //
 // It is a special type.
 //
 // MyStruct is an immutable type and has the following template:
 //
-// 	struct {
-// 		Name	string
-//
-// 		surname	string
+//	struct {
+//		Name	string
 //
-// 		self	*MyStruct
+//		surname	string
 //
-// 		age	int
-// 	}
+//		self	*MyStruct
 //
+//		age	int
+//	}
 type MyStruct struct {
 	field_Name    string `tag:"value"`
 	field_surname string
@@ -452,6 +713,135 @@ func (s *MyStruct) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	return true
 }
 
+// MyStructDiff is the change set between two MyStruct values, as returned by
+// MyStruct.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set. Otherwise a non-nil
+// field holds either the new value of the corresponding field or, where the
+// field is itself of an immutable type, the change set for that field.
+type MyStructDiff struct {
+	Replaced bool
+	Value    *MyStruct
+
+	Name    *string
+	surname *string
+	self    *MyStructDiff
+	age     *int
+}
+
+// Diff returns the change set required to turn s into other, or nil if there
+// is no difference.
+func (s *MyStruct) Diff(other *MyStruct) *MyStructDiff {
+	if s == other {
+		return nil
+	}
+
+	if s == nil || other == nil {
+		return &MyStructDiff{Replaced: true, Value: other}
+	}
+
+	var res MyStructDiff
+	changed := false
+
+	if s.field_Name != other.field_Name {
+		v := other.field_Name
+		res.Name = &v
+		changed = true
+	}
+
+	if s.field_surname != other.field_surname {
+		v := other.field_surname
+		res.surname = &v
+		changed = true
+	}
+
+	if d := s.field_self.Diff(other.field_self); d != nil {
+		res.self = d
+		changed = true
+	}
+
+	if s.field_age != other.field_age {
+		v := other.field_age
+		res.age = &v
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return &res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to s.
+func (s *MyStruct) Patch(d *MyStructDiff) *MyStruct {
+	if d == nil {
+		return s
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if s == nil {
+		s = new(MyStruct)
+	}
+
+	return s.WithMutable(func(si *MyStruct) {
+		if d.Name != nil {
+			si.field_Name = *d.Name
+		}
+		if d.surname != nil {
+			si.field_surname = *d.surname
+		}
+		if d.self != nil {
+			si.field_self = si.field_self.Patch(d.self)
+		}
+		if d.age != nil {
+			si.field_age = *d.age
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. The exported fields of the template
+// for MyStruct are marshalled according to their names and tags.
+func (s *MyStruct) MarshalJSON() ([]byte, error) {
+	if s == nil {
+		return []byte("null"), nil
+	}
+
+	v := struct {
+		Name string `tag:"value"`
+	}{
+		Name: s.field_Name,
+	}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The exported fields of the
+// template for MyStruct are unmarshalled according to their names and tags;
+// other fields, and those absent from the JSON, are left unchanged. The result
+// is built via WithMutable. Because json.Unmarshaler stores the result in
+// *s, s should be a new value rather than one shared with other code.
+func (s *MyStruct) UnmarshalJSON(b []byte) error {
+	v := struct {
+		Name string `tag:"value"`
+	}{
+		Name: s.field_Name,
+	}
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*s = *s.WithMutable(func(si *MyStruct) {
+		si.field_Name = v.Name
+	})
+
+	return nil
+}
+
 // Name is a field in MyStruct
 func (s *MyStruct) Name() string {
 	return s.field_Name
@@ -485,6 +875,7 @@ func (s *MyStruct) setAge(n int) *MyStruct {
 	res.field_age = n
 	return &res
 }
+
 func (s *MyStruct) self() *MyStruct {
 	return s.field_self
 }
@@ -1,18 +1,21 @@
 // Code generated by immutableGen. DO NOT EDIT.
 
+// Copyright (c) 2016 Paul Jolly <paul@myitcv.org.uk>, all rights reserved.
+// Use of this document is governed by a license found in the LICENSE document.
+
 package example
 
+//go:generate echo "hello world"
 //immutableVet:skipFile
 
 import (
+	"encoding/json"
 	"myitcv.io/immutable"
 )
 
-//
 // myTestMap is an immutable type and has the following template:
 //
-// 	map[string]int
-//
+//	map[string]int
 type myTestMap struct {
 	theMap  map[string]int
 	mutable bool
@@ -162,3 +165,116 @@ func (s *myTestMap) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	}
 	return true
 }
+
+// myTestMapDiff is the change set between two myTestMap values, as returned by
+// myTestMap.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set.
+type myTestMapDiff struct {
+	Replaced bool
+	Value    *myTestMap
+
+	// Set holds the entries that were added or changed
+	Set map[string]int
+
+	// Del holds the keys of the entries that were deleted, in no particular
+	// order
+	Del []string
+}
+
+// Diff returns the change set required to turn m into other, or nil if there
+// is no difference.
+func (m *myTestMap) Diff(other *myTestMap) *myTestMapDiff {
+	if m == other {
+		return nil
+	}
+
+	if m == nil || other == nil {
+		return &myTestMapDiff{Replaced: true, Value: other}
+	}
+
+	res := &myTestMapDiff{
+		Set: make(map[string]int),
+	}
+
+	for k := range m.Range() {
+		if _, ok := other.Get(k); !ok {
+			res.Del = append(res.Del, k)
+		}
+	}
+
+	for k, ov := range other.Range() {
+		v, ok := m.Get(k)
+		if !ok {
+			res.Set[k] = ov
+			continue
+		}
+
+		if v != ov {
+			res.Set[k] = ov
+		}
+	}
+
+	if len(res.Set) == 0 && len(res.Del) == 0 {
+		return nil
+	}
+
+	return res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to m.
+func (m *myTestMap) Patch(d *myTestMapDiff) *myTestMap {
+	if d == nil {
+		return m
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if m == nil {
+		m = newMyTestMap()
+	}
+
+	return m.WithMutable(func(mi *myTestMap) {
+		for _, k := range d.Del {
+			mi.Del(k)
+		}
+
+		for k, v := range d.Set {
+			mi.Set(k, v)
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. m is marshalled as a JSON object.
+func (m *myTestMap) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+
+	if m.Len() == 0 {
+		return []byte("{}"), nil
+	}
+
+	return json.Marshal(m.Range())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. m is set to an immutable map
+// containing the unmarshalled entries. Because *m is replaced, m should be a
+// new value rather than one shared with other code.
+func (m *myTestMap) UnmarshalJSON(b []byte) error {
+	var v map[string]int
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*m = *newMyTestMap(func(mi *myTestMap) {
+		for k, e := range v {
+			mi.Set(k, e)
+		}
+	})
+
+	return nil
+}
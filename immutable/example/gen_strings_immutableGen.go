@@ -1,10 +1,15 @@
 // Code generated by immutableGen. DO NOT EDIT.
 
+// Copyright (c) 2016 Paul Jolly <paul@myitcv.org.uk>, all rights reserved.
+// Use of this document is governed by a license found in the LICENSE document.
+
 package example
 
+//go:generate echo "hello world"
 //immutableVet:skipFile
 
 import (
+	"encoding/json"
 	"myitcv.io/immutable"
 )
 
@@ -13,11 +18,10 @@ import (
 //
 // Person is an immutable type and has the following template:
 //
-// 	struct {
-// 		Name	string
-// 		Age	int
-// 	}
-//
+//	struct {
+//		Name	string
+//		Age	int
+//	}
 type Person struct {
 	field_Name string
 	field_Age  int
@@ -93,6 +97,122 @@ func (s *Person) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	seen[s] = true
 	return true
 }
+
+// PersonDiff is the change set between two Person values, as returned by
+// Person.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set. Otherwise a non-nil
+// field holds either the new value of the corresponding field or, where the
+// field is itself of an immutable type, the change set for that field.
+type PersonDiff struct {
+	Replaced bool
+	Value    *Person
+
+	Name *string
+	Age  *int
+}
+
+// Diff returns the change set required to turn s into other, or nil if there
+// is no difference.
+func (s *Person) Diff(other *Person) *PersonDiff {
+	if s == other {
+		return nil
+	}
+
+	if s == nil || other == nil {
+		return &PersonDiff{Replaced: true, Value: other}
+	}
+
+	var res PersonDiff
+	changed := false
+
+	if s.field_Name != other.field_Name {
+		v := other.field_Name
+		res.Name = &v
+		changed = true
+	}
+
+	if s.field_Age != other.field_Age {
+		v := other.field_Age
+		res.Age = &v
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return &res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to s.
+func (s *Person) Patch(d *PersonDiff) *Person {
+	if d == nil {
+		return s
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if s == nil {
+		s = new(Person)
+	}
+
+	return s.WithMutable(func(si *Person) {
+		if d.Name != nil {
+			si.field_Name = *d.Name
+		}
+		if d.Age != nil {
+			si.field_Age = *d.Age
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. The exported fields of the template
+// for Person are marshalled according to their names and tags.
+func (s *Person) MarshalJSON() ([]byte, error) {
+	if s == nil {
+		return []byte("null"), nil
+	}
+
+	v := struct {
+		Name string
+		Age  int
+	}{
+		Name: s.field_Name,
+		Age:  s.field_Age,
+	}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The exported fields of the
+// template for Person are unmarshalled according to their names and tags;
+// other fields, and those absent from the JSON, are left unchanged. The result
+// is built via WithMutable. Because json.Unmarshaler stores the result in
+// *s, s should be a new value rather than one shared with other code.
+func (s *Person) UnmarshalJSON(b []byte) error {
+	v := struct {
+		Name string
+		Age  int
+	}{
+		Name: s.field_Name,
+		Age:  s.field_Age,
+	}
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*s = *s.WithMutable(func(si *Person) {
+		si.field_Name = v.Name
+		si.field_Age = v.Age
+	})
+
+	return nil
+}
+
 func (s *Person) Age() int {
 	return s.field_Age
 }
@@ -108,6 +228,7 @@ func (s *Person) SetAge(n int) *Person {
 	res.field_Age = n
 	return &res
 }
+
 func (s *Person) Name() string {
 	return s.field_Name
 }
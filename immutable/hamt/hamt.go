@@ -0,0 +1,364 @@
+// Copyright (c) 2016 Paul Jolly <paul@myitcv.org.uk>, all rights reserved.
+// Use of this document is governed by a license found in the LICENSE document.
+
+// Package hamt provides a persistent hash array mapped trie. It is the
+// representation used by immutable maps generated by
+// myitcv.io/immutable/cmd/immutableGen from templates that carry the
+// //immutableGen:hamt marker.
+//
+// A *Map is never modified once created; Set and Del return a new *Map that
+// shares all unchanged nodes with the receiver. A nil *Map is a valid, empty
+// map.
+package hamt
+
+import "math/bits"
+
+const (
+	bitsPerLevel = 5
+	levelMask    = 1<<bitsPerLevel - 1
+)
+
+// Map is a persistent map from keys of type K to values of type V.
+type Map[K comparable, V any] struct {
+	root *node[K, V]
+	len  int
+}
+
+type node[K comparable, V any] struct {
+	// bitmap has bit i set if the entry for hash fragment i is present in
+	// entries. entries is ordered by fragment.
+	bitmap  uint32
+	entries []entry[K, V]
+}
+
+// entry is either a sub node or a leaf; exactly one of sub and leaf is
+// non-nil.
+type entry[K comparable, V any] struct {
+	sub  *node[K, V]
+	leaf *leaf[K, V]
+}
+
+// leaf holds all the key value pairs whose keys have the hash hash. In the
+// common case there is exactly one pair.
+type leaf[K comparable, V any] struct {
+	hash uint64
+	kvs  []kv[K, V]
+}
+
+type kv[K comparable, V any] struct {
+	key K
+	val V
+}
+
+// Len returns the number of entries in m.
+func (m *Map[K, V]) Len() int {
+	if m == nil {
+		return 0
+	}
+
+	return m.len
+}
+
+// Get returns the value associated with k and true if m contains k, or the
+// zero value of V and false otherwise.
+func (m *Map[K, V]) Get(k K) (V, bool) {
+	var zero V
+
+	if m == nil || m.root == nil {
+		return zero, false
+	}
+
+	h := Hash(k)
+	n := m.root
+
+	for shift := uint(0); ; shift += bitsPerLevel {
+		bit := uint32(1) << ((h >> shift) & levelMask)
+		if n.bitmap&bit == 0 {
+			return zero, false
+		}
+
+		e := n.entries[n.index(bit)]
+		if e.sub != nil {
+			n = e.sub
+			continue
+		}
+
+		if e.leaf.hash != h {
+			return zero, false
+		}
+
+		for _, p := range e.leaf.kvs {
+			if p.key == k {
+				return p.val, true
+			}
+		}
+
+		return zero, false
+	}
+}
+
+// Set returns a map that is m with k associated to v.
+func (m *Map[K, V]) Set(k K, v V) *Map[K, V] {
+	h := Hash(k)
+
+	res := &Map[K, V]{}
+
+	if m == nil || m.root == nil {
+		res.root = &node[K, V]{
+			bitmap:  uint32(1) << (h & levelMask),
+			entries: []entry[K, V]{{leaf: newLeaf(h, k, v)}},
+		}
+		res.len = 1
+
+		return res
+	}
+
+	root, added := m.root.set(0, h, k, v)
+
+	res.root = root
+	res.len = m.len
+	if added {
+		res.len++
+	}
+
+	return res
+}
+
+// Del returns a map that is m without k. If m does not contain k then m is
+// returned.
+func (m *Map[K, V]) Del(k K) *Map[K, V] {
+	if m == nil || m.root == nil {
+		return m
+	}
+
+	root, removed := m.root.del(0, Hash(k), k)
+	if !removed {
+		return m
+	}
+
+	if root != nil && len(root.entries) == 0 {
+		root = nil
+	}
+
+	return &Map[K, V]{
+		root: root,
+		len:  m.len - 1,
+	}
+}
+
+// Range calls f for each key value pair in m, stopping if f returns false.
+// The order of iteration is unspecified but stable for a given map.
+func (m *Map[K, V]) Range(f func(k K, v V) bool) {
+	if m == nil || m.root == nil {
+		return
+	}
+
+	m.root.rng(f)
+}
+
+func newLeaf[K comparable, V any](h uint64, k K, v V) *leaf[K, V] {
+	return &leaf[K, V]{
+		hash: h,
+		kvs:  []kv[K, V]{{key: k, val: v}},
+	}
+}
+
+func (n *node[K, V]) index(bit uint32) int {
+	return bits.OnesCount32(n.bitmap & (bit - 1))
+}
+
+func (n *node[K, V]) clone() *node[K, V] {
+	res := &node[K, V]{
+		bitmap:  n.bitmap,
+		entries: make([]entry[K, V], len(n.entries)),
+	}
+	copy(res.entries, n.entries)
+
+	return res
+}
+
+func (n *node[K, V]) set(shift uint, h uint64, k K, v V) (*node[K, V], bool) {
+	bit := uint32(1) << ((h >> shift) & levelMask)
+	i := n.index(bit)
+
+	if n.bitmap&bit == 0 {
+		res := &node[K, V]{
+			bitmap:  n.bitmap | bit,
+			entries: make([]entry[K, V], len(n.entries)+1),
+		}
+		copy(res.entries, n.entries[:i])
+		res.entries[i] = entry[K, V]{leaf: newLeaf(h, k, v)}
+		copy(res.entries[i+1:], n.entries[i:])
+
+		return res, true
+	}
+
+	e := n.entries[i]
+	res := n.clone()
+
+	if e.sub != nil {
+		sub, added := e.sub.set(shift+bitsPerLevel, h, k, v)
+		res.entries[i] = entry[K, V]{sub: sub}
+
+		return res, added
+	}
+
+	if e.leaf.hash == h {
+		l, added := e.leaf.set(k, v)
+		res.entries[i] = entry[K, V]{leaf: l}
+
+		return res, added
+	}
+
+	res.entries[i] = entry[K, V]{
+		sub: merge(shift+bitsPerLevel, e.leaf, newLeaf(h, k, v)),
+	}
+
+	return res, true
+}
+
+// merge returns a node containing the two leaves l1 and l2 which must have
+// different hashes, both of which agree in all hash bits below shift.
+func merge[K comparable, V any](shift uint, l1, l2 *leaf[K, V]) *node[K, V] {
+	i1 := (l1.hash >> shift) & levelMask
+	i2 := (l2.hash >> shift) & levelMask
+
+	if i1 == i2 {
+		return &node[K, V]{
+			bitmap:  uint32(1) << i1,
+			entries: []entry[K, V]{{sub: merge(shift+bitsPerLevel, l1, l2)}},
+		}
+	}
+
+	if i1 > i2 {
+		i1, i2 = i2, i1
+		l1, l2 = l2, l1
+	}
+
+	return &node[K, V]{
+		bitmap:  uint32(1)<<i1 | uint32(1)<<i2,
+		entries: []entry[K, V]{{leaf: l1}, {leaf: l2}},
+	}
+}
+
+func (n *node[K, V]) del(shift uint, h uint64, k K) (*node[K, V], bool) {
+	bit := uint32(1) << ((h >> shift) & levelMask)
+	if n.bitmap&bit == 0 {
+		return n, false
+	}
+
+	i := n.index(bit)
+	e := n.entries[i]
+
+	var repl entry[K, V]
+
+	if e.sub != nil {
+		sub, removed := e.sub.del(shift+bitsPerLevel, h, k)
+		if !removed {
+			return n, false
+		}
+
+		switch {
+		case len(sub.entries) == 0:
+		case len(sub.entries) == 1 && sub.entries[0].leaf != nil:
+			// collapse a sub node that now holds a single leaf
+			repl.leaf = sub.entries[0].leaf
+		default:
+			repl.sub = sub
+		}
+	} else {
+		if e.leaf.hash != h {
+			return n, false
+		}
+
+		l, removed := e.leaf.del(k)
+		if !removed {
+			return n, false
+		}
+
+		repl.leaf = l
+	}
+
+	if repl.sub != nil || repl.leaf != nil {
+		res := n.clone()
+		res.entries[i] = repl
+
+		return res, true
+	}
+
+	res := &node[K, V]{
+		bitmap:  n.bitmap &^ bit,
+		entries: make([]entry[K, V], len(n.entries)-1),
+	}
+	copy(res.entries, n.entries[:i])
+	copy(res.entries[i:], n.entries[i+1:])
+
+	return res, true
+}
+
+func (n *node[K, V]) rng(f func(k K, v V) bool) bool {
+	for _, e := range n.entries {
+		if e.sub != nil {
+			if !e.sub.rng(f) {
+				return false
+			}
+			continue
+		}
+
+		for _, p := range e.leaf.kvs {
+			if !f(p.key, p.val) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func (l *leaf[K, V]) set(k K, v V) (*leaf[K, V], bool) {
+	for i, p := range l.kvs {
+		if p.key == k {
+			res := &leaf[K, V]{
+				hash: l.hash,
+				kvs:  make([]kv[K, V], len(l.kvs)),
+			}
+			copy(res.kvs, l.kvs)
+			res.kvs[i].val = v
+
+			return res, false
+		}
+	}
+
+	res := &leaf[K, V]{
+		hash: l.hash,
+		kvs:  make([]kv[K, V], len(l.kvs), len(l.kvs)+1),
+	}
+	copy(res.kvs, l.kvs)
+	res.kvs = append(res.kvs, kv[K, V]{key: k, val: v})
+
+	return res, true
+}
+
+// del returns the leaf without k, or nil if that leaves the leaf empty.
+func (l *leaf[K, V]) del(k K) (*leaf[K, V], bool) {
+	for i, p := range l.kvs {
+		if p.key != k {
+			continue
+		}
+
+		if len(l.kvs) == 1 {
+			return nil, true
+		}
+
+		res := &leaf[K, V]{
+			hash: l.hash,
+			kvs:  make([]kv[K, V], 0, len(l.kvs)-1),
+		}
+		res.kvs = append(res.kvs, l.kvs[:i]...)
+		res.kvs = append(res.kvs, l.kvs[i+1:]...)
+
+		return res, true
+	}
+
+	return l, false
+}
@@ -0,0 +1,181 @@
+// Copyright (c) 2016 Paul Jolly <paul@myitcv.org.uk>, all rights reserved.
+// Use of this document is governed by a license found in the LICENSE document.
+
+package hamt
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestNilMap(t *testing.T) {
+	var m *Map[string, int]
+
+	if v := m.Len(); v != 0 {
+		t.Fatalf("expected length 0; got %v", v)
+	}
+
+	if v, ok := m.Get("a"); ok {
+		t.Fatalf("expected no value; got %v", v)
+	}
+
+	if v := m.Del("a"); v != m {
+		t.Fatalf("expected Del on nil map to return the receiver")
+	}
+
+	m.Range(func(k string, v int) bool {
+		t.Fatalf("did not expect to range over any values; got %v", k)
+		return true
+	})
+}
+
+func TestPersistence(t *testing.T) {
+	var m1 *Map[string, int]
+
+	m2 := m1.Set("a", 1)
+	m3 := m2.Set("b", 2)
+	m4 := m3.Set("a", 3)
+	m5 := m4.Del("b")
+
+	check := func(m *Map[string, int], exp map[string]int) {
+		t.Helper()
+
+		if v := m.Len(); v != len(exp) {
+			t.Fatalf("expected length %v; got %v", len(exp), v)
+		}
+
+		for k, ev := range exp {
+			if v, ok := m.Get(k); !ok || v != ev {
+				t.Fatalf("expected Get(%q) to be (%v, true); got (%v, %v)", k, ev, v, ok)
+			}
+		}
+	}
+
+	check(m1, map[string]int{})
+	check(m2, map[string]int{"a": 1})
+	check(m3, map[string]int{"a": 1, "b": 2})
+	check(m4, map[string]int{"a": 3, "b": 2})
+	check(m5, map[string]int{"a": 3})
+}
+
+func TestAgainstMap(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	var m *Map[int, int]
+	exp := make(map[int]int)
+
+	for i := 0; i < 20000; i++ {
+		k := r.Intn(2000)
+
+		if r.Intn(3) == 0 {
+			m = m.Del(k)
+			delete(exp, k)
+		} else {
+			m = m.Set(k, i)
+			exp[k] = i
+		}
+	}
+
+	if v := m.Len(); v != len(exp) {
+		t.Fatalf("expected length %v; got %v", len(exp), v)
+	}
+
+	got := make(map[int]int)
+	m.Range(func(k, v int) bool {
+		got[k] = v
+		return true
+	})
+
+	if len(got) != len(exp) {
+		t.Fatalf("expected Range to visit %v entries; visited %v", len(exp), len(got))
+	}
+
+	for k, ev := range exp {
+		if v := got[k]; v != ev {
+			t.Fatalf("expected %v => %v; got %v", k, ev, v)
+		}
+	}
+
+	for k := range exp {
+		m = m.Del(k)
+	}
+
+	if v := m.Len(); v != 0 {
+		t.Fatalf("expected empty map; got length %v", v)
+	}
+}
+
+type collider struct {
+	s string
+}
+
+func TestCollisions(t *testing.T) {
+	var l *leaf[collider, int]
+
+	// force keys into the same leaf to exercise collision handling
+	for i := 0; i < 5; i++ {
+		if l == nil {
+			l = newLeaf(42, collider{fmt.Sprint(i)}, i)
+			continue
+		}
+		l, _ = l.set(collider{fmt.Sprint(i)}, i)
+	}
+
+	n := &node[collider, int]{
+		bitmap:  1 << (42 & levelMask),
+		entries: []entry[collider, int]{{leaf: l}},
+	}
+
+	m := &Map[collider, int]{root: n, len: len(l.kvs)}
+
+	for i := 0; i < 5; i++ {
+		k := collider{fmt.Sprint(i)}
+		h := uint64(42)
+
+		v, ok := lookupHash(m, h, k)
+		if !ok || v != i {
+			t.Fatalf("expected (%v, true) for %v; got (%v, %v)", i, k, v, ok)
+		}
+	}
+
+	l2, removed := l.del(collider{"2"})
+	if !removed || len(l2.kvs) != 4 {
+		t.Fatalf("expected to remove a single entry; got %v %v", removed, l2.kvs)
+	}
+}
+
+// lookupHash is Get with a caller supplied hash
+func lookupHash[K comparable, V any](m *Map[K, V], h uint64, k K) (V, bool) {
+	var zero V
+
+	e := m.root.entries[m.root.index(uint32(1)<<(h&levelMask))]
+	for _, p := range e.leaf.kvs {
+		if p.key == k {
+			return p.val, true
+		}
+	}
+
+	return zero, false
+}
+
+func TestHashConsistent(t *testing.T) {
+	type pair struct {
+		A string
+		B float64
+	}
+
+	if Hash(pair{"a", 0}) != Hash(pair{"a", negZero()}) {
+		t.Fatalf("expected +0 and -0 to hash the same")
+	}
+
+	p := new(int)
+	if Hash(p) != Hash(p) {
+		t.Fatalf("expected a pointer to hash consistently")
+	}
+}
+
+func negZero() float64 {
+	z := 0.0
+	return -z
+}
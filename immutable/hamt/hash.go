@@ -0,0 +1,121 @@
+// Copyright (c) 2016 Paul Jolly <paul@myitcv.org.uk>, all rights reserved.
+// Use of this document is governed by a license found in the LICENSE document.
+
+package hamt
+
+import (
+	"math"
+	"reflect"
+)
+
+const (
+	offset64 = 14695981039346656037
+	prime64  = 1099511628211
+)
+
+// Hash returns a hash of k that is consistent with ==; that is, if k1 == k2
+// then Hash(k1) == Hash(k2). The hash is not stable across versions of this
+// package and must not be persisted.
+//
+// Pointer, channel and map-like values are hashed by identity, interface
+// values by their dynamic type and value, and structs and arrays
+// element-wise.
+func Hash[K comparable](k K) uint64 {
+	switch k := any(k).(type) {
+	case string:
+		return mix(hashString(offset64, k))
+	case int:
+		return mix(uint64(k))
+	case int64:
+		return mix(uint64(k))
+	case int32:
+		return mix(uint64(k))
+	case uint:
+		return mix(uint64(k))
+	case uint64:
+		return mix(k)
+	case uint32:
+		return mix(uint64(k))
+	}
+
+	return mix(hashValue(offset64, reflect.ValueOf(&k).Elem()))
+}
+
+func hashValue(h uint64, v reflect.Value) uint64 {
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return hashUint64(h, 1)
+		}
+		return hashUint64(h, 0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return hashUint64(h, uint64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return hashUint64(h, v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return hashFloat(h, v.Float())
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		return hashFloat(hashFloat(h, real(c)), imag(c))
+	case reflect.String:
+		return hashString(h, v.String())
+	case reflect.Ptr, reflect.Chan, reflect.UnsafePointer:
+		return hashUint64(h, uint64(v.Pointer()))
+	case reflect.Interface:
+		if v.IsNil() {
+			return hashUint64(h, 0)
+		}
+		e := v.Elem()
+		return hashValue(hashString(h, e.Type().String()), e)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			h = hashValue(h, v.Field(i))
+		}
+		return h
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			h = hashValue(h, v.Index(i))
+		}
+		return h
+	}
+
+	// all other kinds are not comparable and so cannot be keys
+	panic("hamt: cannot hash value of type " + v.Type().String())
+}
+
+func hashFloat(h uint64, f float64) uint64 {
+	if f == 0 {
+		// +0 == -0
+		f = 0
+	}
+	return hashUint64(h, math.Float64bits(f))
+}
+
+func hashString(h uint64, s string) uint64 {
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= prime64
+	}
+	return hashUint64(h, uint64(len(s)))
+}
+
+func hashUint64(h uint64, v uint64) uint64 {
+	for i := 0; i < 8; i++ {
+		h ^= v & 0xff
+		h *= prime64
+		v >>= 8
+	}
+	return h
+}
+
+// mix is the splitmix64 finaliser; it spreads the entropy of h across all
+// bits so that the low order bits used by each trie level are well
+// distributed.
+func mix(h uint64) uint64 {
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	h ^= h >> 31
+	return h
+}
@@ -0,0 +1,347 @@
+// Copyright (c) 2016 Paul Jolly <paul@myitcv.org.uk>, all rights reserved.
+// Use of this document is governed by a license found in the LICENSE document.
+
+// Package history provides a bounded undo/redo history of immutable values,
+// i.e. values of types generated by myitcv.io/immutable/cmd/immutableGen.
+//
+// Because an immutable value is never changed once it has been made
+// immutable, a History simply records the values it is given: there is no
+// need to copy a value, or to compute and invert the change from one value to
+// the next.
+//
+// Each value recorded by a History is given a version, drawn from a counter
+// that is incremented with each recorded value. Committing the current
+// value again is a no-op, and so does not change the version of a History.
+// Where values have a Key method, as do generated structs with a special Key
+// field, a value is the current value if its Key has the same Uuid and
+// Version as that of the current value. Otherwise, because any change to an
+// immutable value results in a new pointer, a value is the current value only
+// if it is identical to it.
+// Comparing the version of a History with the version of a named checkpoint
+// therefore tells whether the current value has changed since the
+// checkpoint was taken, for example to determine whether a document needs to
+// be saved.
+//
+// A History is not safe for concurrent use.
+package history
+
+import (
+	"reflect"
+	"time"
+
+	"myitcv.io/immutable"
+)
+
+// DefaultLimit is the maximum number of values recorded by a History created
+// without the Limit option.
+const DefaultLimit = 100
+
+// History is a bounded history of values of the immutable type T, typically
+// a pointer to a generated type. The zero value is not usable; use New.
+type History[T immutable.Immutable] struct {
+	// entries holds the recorded values, oldest first; entries[cur] is the
+	// current value and entries[cur+1:] are the values that can be redone
+	entries []entry[T]
+	cur     int
+
+	// version is the version of the most recently recorded value
+	version uint64
+
+	checkpoints map[string]uint64
+
+	// mergeable is true if the next commit may be merged into the current
+	// entry, i.e. the current entry was the result of a commit and has not
+	// since been the target of an undo, redo, restore or checkpoint
+	mergeable bool
+
+	limit       int
+	mergeWithin time.Duration
+	now         func() time.Time
+}
+
+type entry[T immutable.Immutable] struct {
+	value   T
+	version uint64
+
+	// key and at are the key and time of the last commit to this entry
+	key string
+	at  time.Time
+}
+
+// Option configures a History created by New.
+type Option func(*config)
+
+type config struct {
+	limit       int
+	mergeWithin time.Duration
+	now         func() time.Time
+}
+
+// Limit sets the maximum number of values, including the current value,
+// recorded by a History. Once the limit is reached the oldest values are
+// discarded. A limit of less than 1 is treated as 1.
+func Limit(n int) Option {
+	return func(c *config) {
+		if n < 1 {
+			n = 1
+		}
+		c.limit = n
+	}
+}
+
+// MergeWithin causes consecutive commits with the same key made within d of
+// each other to be merged into a single value in the history, such that a
+// single Undo reverts all of them. This is useful where, for example, each
+// keystroke in a text input results in a commit.
+func MergeWithin(d time.Duration) Option {
+	return func(c *config) {
+		c.mergeWithin = d
+	}
+}
+
+// Clock sets the function used by a History to determine the time of a
+// commit. It defaults to time.Now.
+func Clock(now func() time.Time) Option {
+	return func(c *config) {
+		c.now = now
+	}
+}
+
+// New returns a History whose current value is v.
+func New[T immutable.Immutable](v T, opts ...Option) *History[T] {
+	c := config{
+		limit: DefaultLimit,
+		now:   time.Now,
+	}
+
+	for _, o := range opts {
+		o(&c)
+	}
+
+	mustBeImmutable(v)
+
+	res := &History[T]{
+		limit:       c.limit,
+		mergeWithin: c.mergeWithin,
+		now:         c.now,
+		checkpoints: make(map[string]uint64),
+	}
+
+	res.version++
+	res.entries = append(res.entries, entry[T]{
+		value:   v,
+		version: res.version,
+		at:      res.now(),
+	})
+
+	return res
+}
+
+// Current returns the current value of h.
+func (h *History[T]) Current() T {
+	return h.entries[h.cur].value
+}
+
+// Version returns the version of the current value of h.
+func (h *History[T]) Version() uint64 {
+	return h.entries[h.cur].version
+}
+
+// Commit records v as the current value of h, discarding any values that
+// could have been redone. It is equivalent to CommitKey with an empty key.
+func (h *History[T]) Commit(v T) bool {
+	return h.CommitKey("", v)
+}
+
+// CommitKey records v as the current value of h, discarding any values that
+// could have been redone, and reports whether v was recorded. If v is the
+// current value of h, as described in the package documentation, the commit
+// is a no-op and false is returned. Where h
+// was created with the MergeWithin option, the current value is replaced by
+// v, rather than v being recorded as a new value, if the current value was
+// itself the result of a commit with the same key within the merge window.
+//
+// CommitKey panics if v is mutable.
+func (h *History[T]) CommitKey(key string, v T) bool {
+	mustBeImmutable(v)
+
+	cur := &h.entries[h.cur]
+
+	if same(cur.value, v) {
+		return false
+	}
+
+	now := h.now()
+
+	h.truncate()
+
+	h.version++
+
+	if h.mergeable && h.mergeWithin > 0 && key == cur.key && now.Sub(cur.at) < h.mergeWithin {
+		cur.value = v
+		cur.version = h.version
+		cur.at = now
+
+		return true
+	}
+
+	h.entries = append(h.entries, entry[T]{
+		value:   v,
+		version: h.version,
+		key:     key,
+		at:      now,
+	})
+	h.cur++
+	h.mergeable = true
+
+	if over := len(h.entries) - h.limit; over > 0 {
+		h.entries = append(h.entries[:0], h.entries[over:]...)
+		h.cur -= over
+	}
+
+	return true
+}
+
+// truncate discards the values that could have been redone
+func (h *History[T]) truncate() {
+	var zero entry[T]
+	for i := h.cur + 1; i < len(h.entries); i++ {
+		h.entries[i] = zero
+	}
+
+	h.entries = h.entries[:h.cur+1]
+}
+
+// CanUndo reports whether there is a value to which Undo can revert.
+func (h *History[T]) CanUndo() bool {
+	return h.cur > 0
+}
+
+// CanRedo reports whether there is a value to which Redo can advance.
+func (h *History[T]) CanRedo() bool {
+	return h.cur < len(h.entries)-1
+}
+
+// Undo reverts h to the value before the current value, and returns the new
+// current value. If there is no such value, Undo returns the current value
+// and false.
+func (h *History[T]) Undo() (T, bool) {
+	if !h.CanUndo() {
+		return h.Current(), false
+	}
+
+	return h.moveTo(h.cur - 1), true
+}
+
+// Redo advances h to the value that was most recently undone, and returns
+// the new current value. If there is no such value, Redo returns the current
+// value and false.
+func (h *History[T]) Redo() (T, bool) {
+	if !h.CanRedo() {
+		return h.Current(), false
+	}
+
+	return h.moveTo(h.cur + 1), true
+}
+
+func (h *History[T]) moveTo(i int) T {
+	h.cur = i
+	h.mergeable = false
+
+	return h.Current()
+}
+
+// Checkpoint records the current value of h under name, replacing any
+// existing checkpoint with that name. A subsequent commit is never merged
+// into the checkpointed value.
+func (h *History[T]) Checkpoint(name string) {
+	h.checkpoints[name] = h.Version()
+	h.mergeable = false
+}
+
+// CheckpointVersion returns the version of the value recorded under name,
+// and false if there is no such checkpoint.
+func (h *History[T]) CheckpointVersion(name string) (uint64, bool) {
+	v, ok := h.checkpoints[name]
+	return v, ok
+}
+
+// Restore makes the value recorded under name the current value of h, and
+// returns that value. Values committed after the checkpoint remain available
+// to Redo. If there is no such checkpoint, or the value has since been
+// discarded from h, Restore returns the current value and false.
+func (h *History[T]) Restore(name string) (T, bool) {
+	v, ok := h.checkpoints[name]
+	if !ok {
+		return h.Current(), false
+	}
+
+	for i, e := range h.entries {
+		if e.version == v {
+			return h.moveTo(i), true
+		}
+	}
+
+	return h.Current(), false
+}
+
+// DeleteCheckpoint removes the checkpoint with the given name, if any.
+func (h *History[T]) DeleteCheckpoint(name string) {
+	delete(h.checkpoints, name)
+}
+
+// same reports whether a and b are the same version of a value: if a and b
+// have Keys, whether those Keys have the same Uuid and Version, otherwise
+// whether a and b are identical.
+func same[T immutable.Immutable](a, b T) bool {
+	if immutable.Immutable(a) == immutable.Immutable(b) {
+		return true
+	}
+
+	ua, va, ok := key(a)
+	if !ok {
+		return false
+	}
+
+	ub, vb, ok := key(b)
+
+	return ok && ua == ub && va == vb
+}
+
+// key returns the Uuid and Version of the result of the Key method of v, and
+// false if v is nil or does not have such a method.
+func key(v interface{}) (uuid, version interface{}, ok bool) {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() || (rv.Kind() == reflect.Ptr && rv.IsNil()) {
+		return nil, nil, false
+	}
+
+	m := rv.MethodByName("Key")
+	if !m.IsValid() || m.Type().NumIn() != 0 || m.Type().NumOut() != 1 {
+		return nil, nil, false
+	}
+
+	if kt := m.Type().Out(0); kt.Kind() != reflect.Struct || !kt.Comparable() {
+		return nil, nil, false
+	}
+
+	k := m.Call(nil)[0]
+
+	u, ver := k.FieldByName("Uuid"), k.FieldByName("Version")
+	if !u.IsValid() || !ver.IsValid() || !u.CanInterface() || !ver.CanInterface() {
+		return nil, nil, false
+	}
+
+	return u.Interface(), ver.Interface(), true
+}
+
+func mustBeImmutable[T immutable.Immutable](v T) {
+	// the generated Mutable methods do not handle a nil receiver
+	if rv := reflect.ValueOf(v); !rv.IsValid() || (rv.Kind() == reflect.Ptr && rv.IsNil()) {
+		return
+	}
+
+	if v.Mutable() {
+		panic("history: value is mutable")
+	}
+}
@@ -0,0 +1,228 @@
+// Copyright (c) 2016 Paul Jolly <paul@myitcv.org.uk>, all rights reserved.
+// Use of this document is governed by a license found in the LICENSE document.
+
+package history_test
+
+import (
+	"testing"
+	"time"
+
+	"myitcv.io/immutable/example"
+	"myitcv.io/immutable/history"
+)
+
+func TestUndoRedo(t *testing.T) {
+	v1 := new(example.MyStruct).SetName("a")
+	v2 := v1.SetName("b")
+	v3 := v2.SetName("c")
+
+	h := history.New(v1)
+
+	if h.CanUndo() || h.CanRedo() {
+		t.Fatalf("expected nothing to undo or redo")
+	}
+
+	h.Commit(v2)
+	h.Commit(v3)
+
+	if v, ok := h.Undo(); !ok || v != v2 {
+		t.Fatalf("expected Undo to give v2; got %v, %v", v.Name(), ok)
+	}
+
+	if v, ok := h.Undo(); !ok || v != v1 {
+		t.Fatalf("expected Undo to give v1; got %v, %v", v.Name(), ok)
+	}
+
+	if v, ok := h.Undo(); ok || v != v1 {
+		t.Fatalf("expected Undo to fail and give v1; got %v, %v", v.Name(), ok)
+	}
+
+	if v, ok := h.Redo(); !ok || v != v2 {
+		t.Fatalf("expected Redo to give v2; got %v, %v", v.Name(), ok)
+	}
+
+	// a commit discards the redo values
+	v4 := v2.SetName("d")
+	h.Commit(v4)
+
+	if h.CanRedo() {
+		t.Fatalf("expected nothing to redo after a commit")
+	}
+
+	if v, _ := h.Undo(); v != v2 {
+		t.Fatalf("expected Undo to give v2; got %v", v.Name())
+	}
+}
+
+func TestNoOpCommit(t *testing.T) {
+	v1 := new(example.MyStruct).SetName("a")
+
+	h := history.New(v1)
+	ver := h.Version()
+
+	if h.Commit(v1) {
+		t.Fatalf("expected commit of the current value to be a no-op")
+	}
+
+	if h.Version() != ver || h.CanUndo() {
+		t.Fatalf("expected no-op commit to leave the history unchanged")
+	}
+
+	if !h.Commit(v1.SetName("b")) || h.Version() == ver {
+		t.Fatalf("expected commit of a new value to change the version")
+	}
+}
+
+// doc is an immutable type with a Key, in the manner of a generated struct
+// with a special Key field
+type doc struct {
+	key  docKey
+	text string
+}
+
+type docKey struct {
+	Uuid    uint64
+	Version uint64
+}
+
+func (d *doc) Mutable() bool                                     { return false }
+func (d *doc) IsDeeplyNonMutable(seen map[interface{}]bool) bool { return true }
+func (d *doc) Key() docKey                                       { return d.key }
+
+func TestNoOpCommitKey(t *testing.T) {
+	h := history.New(&doc{key: docKey{Uuid: 1, Version: 1}, text: "a"})
+	ver := h.Version()
+
+	if h.Commit(&doc{key: docKey{Uuid: 1, Version: 1}, text: "a"}) {
+		t.Fatalf("expected commit of a value with the same Key to be a no-op")
+	}
+
+	if h.Version() != ver || h.CanUndo() {
+		t.Fatalf("expected no-op commit to leave the history unchanged")
+	}
+
+	if !h.Commit(&doc{key: docKey{Uuid: 1, Version: 2}, text: "a"}) {
+		t.Fatalf("expected commit of a new Version to be recorded")
+	}
+
+	if !h.Commit(&doc{key: docKey{Uuid: 2, Version: 2}, text: "a"}) {
+		t.Fatalf("expected commit of a new Uuid to be recorded")
+	}
+}
+
+func TestLimit(t *testing.T) {
+	v := new(example.MyStruct)
+
+	h := history.New(v, history.Limit(3))
+
+	for _, n := range []string{"a", "b", "c", "d"} {
+		v = v.SetName(n)
+		h.Commit(v)
+	}
+
+	count := 0
+	for h.CanUndo() {
+		h.Undo()
+		count++
+	}
+
+	if count != 2 {
+		t.Fatalf("expected to be able to undo 2 times; could undo %v", count)
+	}
+
+	if v := h.Current().Name(); v != "b" {
+		t.Fatalf("expected the oldest value to have Name %q; got %q", "b", v)
+	}
+}
+
+func TestMergeWithin(t *testing.T) {
+	now := time.Unix(0, 0)
+	clock := func() time.Time {
+		return now
+	}
+
+	v := new(example.MyStruct)
+
+	h := history.New(v, history.MergeWithin(time.Second), history.Clock(clock))
+
+	// rapid changes with the same key are merged
+	for _, n := range []string{"a", "ab", "abc"} {
+		now = now.Add(100 * time.Millisecond)
+		v = v.SetName(n)
+		h.CommitKey("name", v)
+	}
+
+	// a change with a different key is not
+	now = now.Add(100 * time.Millisecond)
+	h.CommitKey("other", v.SetName("x"))
+
+	// nor is a change outside of the window
+	now = now.Add(2 * time.Second)
+	h.CommitKey("other", v.SetName("y"))
+
+	var names []string
+	for h.CanUndo() {
+		names = append(names, h.Current().Name())
+		h.Undo()
+	}
+
+	exp := []string{"y", "x", "abc"}
+	if len(names) != len(exp) {
+		t.Fatalf("expected values %q; got %q", exp, names)
+	}
+
+	for i := range exp {
+		if names[i] != exp[i] {
+			t.Fatalf("expected values %q; got %q", exp, names)
+		}
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	v1 := new(example.MyStruct).SetName("a")
+
+	h := history.New(v1, history.MergeWithin(time.Hour))
+
+	h.Commit(v1.SetName("b"))
+	h.Checkpoint("saved")
+
+	saved, ok := h.CheckpointVersion("saved")
+	if !ok || saved != h.Version() {
+		t.Fatalf("expected checkpoint version to be the current version")
+	}
+
+	// a commit after a checkpoint is not merged into the checkpointed value
+	h.Commit(v1.SetName("c"))
+	h.Commit(v1.SetName("d"))
+
+	if h.Version() == saved {
+		t.Fatalf("expected version to have changed since the checkpoint")
+	}
+
+	if v, ok := h.Restore("saved"); !ok || v.Name() != "b" {
+		t.Fatalf("expected Restore to give %q; got %q, %v", "b", v.Name(), ok)
+	}
+
+	if h.Version() != saved {
+		t.Fatalf("expected version to be that of the checkpoint")
+	}
+
+	if v, ok := h.Redo(); !ok || v.Name() != "d" {
+		t.Fatalf("expected Redo to give %q; got %q, %v", "d", v.Name(), ok)
+	}
+
+	if _, ok := h.Restore("unknown"); ok {
+		t.Fatalf("expected Restore of an unknown checkpoint to fail")
+	}
+}
+
+func TestMutableValue(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected Commit of a mutable value to panic")
+		}
+	}()
+
+	h := history.New(new(example.MyStruct))
+	h.Commit(new(example.MyStruct).AsMutable())
+}
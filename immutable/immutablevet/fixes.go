@@ -0,0 +1,234 @@
+// Copyright (c) 2016 Paul Jolly <paul@myitcv.org.uk>, all rights reserved.
+// Use of this document is governed by a license found in the LICENSE document.
+
+package immutablevet
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"myitcv.io/immutable"
+)
+
+// pointerFix returns a fix that makes typ a pointer type.
+func pointerFix(typ ast.Expr) []analysis.SuggestedFix {
+	return []analysis.SuggestedFix{{
+		Message: "Use a pointer type",
+		TextEdits: []analysis.TextEdit{{
+			Pos:     typ.Pos(),
+			End:     typ.Pos(),
+			NewText: []byte("*"),
+		}},
+	}}
+}
+
+// newFix returns a fix that replaces &T{} with new(T). There is no fix where
+// the composite literal has elements: the generated setters need to be used
+// instead.
+func newFix(ue *ast.UnaryExpr, cl *ast.CompositeLit) []analysis.SuggestedFix {
+	if len(cl.Elts) != 0 || cl.Type == nil {
+		return nil
+	}
+
+	return []analysis.SuggestedFix{{
+		Message: "Use new()",
+		TextEdits: []analysis.TextEdit{{
+			Pos:     ue.Pos(),
+			End:     ue.End(),
+			NewText: []byte("new(" + types.ExprString(cl.Type) + ")"),
+		}},
+	}}
+}
+
+// fieldTypeFixes returns a fix for each immutable template in the package
+// whose underlying type is identical to the type of the field f, replacing
+// the field's type with a pointer to the corresponding immutable type.
+func (iv *immutableVetter) fieldTypeFixes(f *types.Var) []analysis.SuggestedFix {
+	typ := iv.fieldTypeExpr(f)
+	if typ == nil {
+		return nil
+	}
+
+	var names []string
+
+	for t := range iv.immTmpls {
+		n := t.(*types.Named)
+		if !types.Identical(n.Underlying(), f.Type()) {
+			continue
+		}
+
+		names = append(names, strings.TrimPrefix(n.Obj().Name(), immutable.ImmTypeTmplPrefix))
+	}
+
+	// iv.immTmpls is a map; sort the candidates for a stable order of fixes
+	sort.Strings(names)
+
+	var res []analysis.SuggestedFix
+
+	for _, name := range names {
+		res = append(res, analysis.SuggestedFix{
+			Message: "Use *" + name,
+			TextEdits: []analysis.TextEdit{{
+				Pos:     typ.Pos(),
+				End:     typ.End(),
+				NewText: []byte("*" + name),
+			}},
+		})
+	}
+
+	return res
+}
+
+// fieldTypeExpr returns the type expression of the struct field f.
+func (iv *immutableVetter) fieldTypeExpr(f *types.Var) ast.Expr {
+	file := iv.fileOf(f.Pos())
+	if file == nil {
+		return nil
+	}
+
+	var res ast.Expr
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		if res != nil {
+			return false
+		}
+
+		fld, ok := n.(*ast.Field)
+		if !ok {
+			return true
+		}
+
+		if len(fld.Names) == 0 {
+			// embedded field; the position of the field is that of its type
+			if fld.Type.Pos() == f.Pos() {
+				res = fld.Type
+			}
+		}
+
+		for _, n := range fld.Names {
+			if n.Pos() == f.Pos() {
+				res = fld.Type
+			}
+		}
+
+		return true
+	})
+
+	return res
+}
+
+// rangeFixes returns a fix for a misused call to the Range() method of an
+// immutable slice type, copying the result of the call via append. Where id
+// is not the Range() method of a slice type, no fix is returned: there is no
+// expression that copies a map.
+func (iv *immutableVetter) rangeFixes(id *ast.Ident) []analysis.SuggestedFix {
+	file := iv.fileOf(id.Pos())
+	if file == nil {
+		return nil
+	}
+
+	var call *ast.CallExpr
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		if call != nil {
+			return false
+		}
+
+		ce, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+
+		if se, ok := ce.Fun.(*ast.SelectorExpr); ok && se.Sel == id {
+			call = ce
+		}
+
+		return true
+	})
+
+	if call == nil {
+		return nil
+	}
+
+	st, ok := iv.info.TypeOf(call).(*types.Slice)
+	if !ok {
+		return nil
+	}
+
+	typ, ok := iv.typeString(st, file)
+	if !ok {
+		return nil
+	}
+
+	return []analysis.SuggestedFix{{
+		Message: "Copy the result of Range()",
+		TextEdits: []analysis.TextEdit{
+			{
+				Pos:     call.Pos(),
+				End:     call.Pos(),
+				NewText: []byte("append(" + typ + "(nil), "),
+			},
+			{
+				Pos:     call.End(),
+				End:     call.End(),
+				NewText: []byte("...)"),
+			},
+		},
+	}}
+}
+
+// typeString returns t formatted for use in file, and false if t refers to a
+// package not imported by file.
+func (iv *immutableVetter) typeString(t types.Type, file *ast.File) (string, bool) {
+	ok := true
+
+	qual := func(p *types.Package) string {
+		if p == iv.pass.Pkg {
+			return ""
+		}
+
+		for _, is := range file.Imports {
+			path, err := strconv.Unquote(is.Path.Value)
+			if err != nil || path != p.Path() {
+				continue
+			}
+
+			if is.Name == nil {
+				return p.Name()
+			}
+
+			switch is.Name.Name {
+			case "_":
+			case ".":
+				return ""
+			default:
+				return is.Name.Name
+			}
+		}
+
+		ok = false
+		return p.Name()
+	}
+
+	res := types.TypeString(t, qual)
+
+	return res, ok
+}
+
+// fileOf returns the file of the package being analysed that contains pos.
+func (iv *immutableVetter) fileOf(pos token.Pos) *ast.File {
+	tf := iv.pass.Fset.File(pos)
+
+	for _, f := range iv.pass.Files {
+		if iv.pass.Fset.File(f.Pos()) == tf {
+			return f
+		}
+	}
+
+	return nil
+}
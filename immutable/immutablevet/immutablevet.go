@@ -0,0 +1,574 @@
+// Copyright (c) 2016 Paul Jolly <paul@myitcv.org.uk>, all rights reserved.
+// Use of this document is governed by a license found in the LICENSE document.
+
+// Package immutablevet defines an Analyzer that checks the correct use of the
+// immutable types generated by myitcv.io/immutable/cmd/immutableGen.
+//
+// The Analyzer can be run standalone via myitcv.io/immutable/cmd/immutableVet,
+// as a vet tool via go vet -vettool=$(which immutableVet), or alongside other
+// analyzers via a multichecker or gopls.
+package immutablevet
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"myitcv.io/gogenerate"
+	"myitcv.io/immutable"
+	"myitcv.io/immutable/util"
+)
+
+const (
+	skipFileComment = "//" + immutable.CmdImmutableVet + ":skipFile"
+)
+
+const doc = `check the correct use of immutable types
+
+The immutableVet analysis checks that:
+
+* the fields of immutable struct templates are of immutable types
+* immutable types are only ever referred to via pointers
+* templates are only used by immutableGen-generated code
+* unexported fields of immutable types are not used
+* the result of Range() is only ranged over or copied, and never written to
+* mutable values do not escape WithMutable callbacks and are not stored in
+  immutable values`
+
+// Analyzer checks the correct use of immutable types.
+var Analyzer = &analysis.Analyzer{
+	Name:      immutable.CmdImmutableVet,
+	Doc:       doc,
+	Run:       run,
+	FactTypes: []analysis.Fact{new(ImmTypeFact)},
+}
+
+// ImmTypeFact is exported for each immutable type declared in a package,
+// i.e. each type generated from an _Imm_ template. Kind is one of "struct",
+// "map", "set" or "slice".
+type ImmTypeFact struct {
+	Kind string
+}
+
+func (*ImmTypeFact) AFact() {}
+
+func (f *ImmTypeFact) String() string {
+	return "immutable " + f.Kind
+}
+
+// immIntf is the myitcv.io/immutable.Immutable interface. We construct it
+// rather than look it up so that packages that do not (transitively) import
+// myitcv.io/immutable can be analysed.
+var immIntf = func() *types.Interface {
+	b := types.Typ[types.Bool]
+	seen := types.NewMap(types.NewInterfaceType(nil, nil), b)
+
+	methods := []*types.Func{
+		types.NewFunc(token.NoPos, nil, "Mutable", types.NewSignature(nil, nil, types.NewTuple(types.NewVar(token.NoPos, nil, "", b)), false)),
+		types.NewFunc(token.NoPos, nil, "IsDeeplyNonMutable", types.NewSignature(nil,
+			types.NewTuple(types.NewVar(token.NoPos, nil, "seen", seen)),
+			types.NewTuple(types.NewVar(token.NoPos, nil, "", b)), false)),
+	}
+
+	return types.NewInterfaceType(methods, nil).Complete()
+}()
+
+type immutableVetter struct {
+	pass *analysis.Pass
+
+	skipFiles map[string]bool
+
+	info *types.Info
+
+	// immTmpls is the set of immutable template types in the package
+	// being analysed
+	immTmpls map[types.Type]bool
+
+	// helper field used to hold Range() method calls on immutable types
+	rngs map[*ast.Ident]bool
+
+	// valid composite literals
+	vcls map[*ast.CompositeLit]bool
+
+	typesCache map[string]bool
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	iv := &immutableVetter{
+		pass:      pass,
+		info:      pass.TypesInfo,
+		skipFiles: make(map[string]bool),
+		immTmpls:  make(map[types.Type]bool),
+		rngs:      make(map[*ast.Ident]bool),
+		vcls:      make(map[*ast.CompositeLit]bool),
+		typesCache: map[string]bool{
+			"time.Time": true,
+		},
+	}
+
+	iv.exportFacts()
+
+	for _, f := range pass.Files {
+		for _, d := range f.Decls {
+			gd, ok := d.(*ast.GenDecl)
+			if !ok {
+				continue
+			}
+
+			if gd.Tok != token.TYPE {
+				continue
+			}
+
+			for _, s := range gd.Specs {
+				ts := s.(*ast.TypeSpec)
+
+				_, ok := util.IsImmTmpl(ts)
+				if !ok {
+					continue
+				}
+
+				o := iv.info.ObjectOf(ts.Name)
+				iv.immTmpls[o.Type()] = true
+			}
+		}
+	}
+
+	for t := range iv.immTmpls {
+		st, ok := t.(*types.Named).Underlying().(*types.Struct)
+		if !ok {
+			continue
+		}
+
+		for i := 0; i < st.NumFields(); i++ {
+			f := st.Field(i)
+			if !iv.isImmType(f.Type()) {
+				iv.report(f.Pos(), iv.fieldTypeFixes(f), "immutable struct field must be immutable type; %v is not", f.Type())
+			}
+		}
+	}
+
+	for _, f := range pass.Files {
+		ast.Walk(iv, f)
+	}
+
+	for _, f := range pass.Files {
+		if iv.skipFiles[iv.pass.Fset.Position(f.Pos()).Filename] {
+			continue
+		}
+
+		iv.checkMutations(f)
+	}
+
+	for exp, t := range iv.info.Types {
+		switch {
+		case t.IsType():
+			typ := t.Type
+
+			if !iv.isImmTmpl(typ) {
+				continue
+			}
+
+			fn := pass.Fset.Position(exp.Pos()).Filename
+
+			if !gogenerate.FileGeneratedBy(fn, immutable.CmdImmutableGen) {
+				iv.errorf(exp.Pos(), "template type %v should never get used", typ)
+			}
+
+		case t.IsValue():
+			p := types.NewPointer(t.Type)
+			switch iv.immType(p).(type) {
+			case util.ImmTypeMap:
+			case util.ImmTypeSet:
+			case util.ImmTypeSlice:
+			case util.ImmTypeStruct:
+			default:
+				continue
+			}
+
+			fn := pass.Fset.Position(exp.Pos()).Filename
+
+			if !iv.skipFiles[fn] {
+				iv.errorf(exp.Pos(), "non-pointer value of immutable type %v found", p)
+			}
+		}
+	}
+
+	// find selector exprs which access properties of Immutable types
+	for exp, sel := range iv.info.Selections {
+		isField := sel.Kind() == types.FieldVal
+		if !isField {
+			continue
+		}
+
+		if iv.immType(sel.Recv()) == nil {
+			continue
+		}
+
+		if iv.skipFiles[pass.Fset.Position(exp.X.Pos()).Filename] {
+			continue
+		}
+
+		oname := sel.Obj().Name()
+		iv.errorf(exp.X.Pos(), "should not be using %v of %v immutable type", oname, sel.Recv())
+	}
+
+	for k, v := range iv.rngs {
+		if v == false {
+			iv.report(k.NamePos, iv.rangeFixes(k), "Range() of immutable type must appear in a range statement or used with an ellipsis as the second argument to append")
+		}
+	}
+
+	return nil, nil
+}
+
+// exportFacts exports an ImmTypeFact for each immutable type declared in the
+// package being analysed.
+func (iv *immutableVetter) exportFacts() {
+	scope := iv.pass.Pkg.Scope()
+
+	for _, n := range scope.Names() {
+		tn, ok := scope.Lookup(n).(*types.TypeName)
+		if !ok || tn.IsAlias() {
+			continue
+		}
+
+		var kind string
+
+		switch util.IsImmType(types.NewPointer(tn.Type())).(type) {
+		case util.ImmTypeStruct:
+			kind = "struct"
+		case util.ImmTypeMap:
+			kind = "map"
+		case util.ImmTypeSet:
+			kind = "set"
+		case util.ImmTypeSlice:
+			kind = "slice"
+		default:
+			continue
+		}
+
+		iv.pass.ExportObjectFact(tn, &ImmTypeFact{Kind: kind})
+	}
+}
+
+// immType is the equivalent of util.IsImmType, but for pointers to named
+// types declared in other packages the ImmTypeFact exported for the type is
+// consulted first.
+func (iv *immutableVetter) immType(t types.Type) util.ImmType {
+	if p, ok := t.(*types.Pointer); ok {
+		if n, ok := p.Elem().(*types.Named); ok && n.Obj().Pkg() != nil && n.Obj().Pkg() != iv.pass.Pkg {
+			var f ImmTypeFact
+			if iv.pass.ImportObjectFact(n.Obj(), &f) {
+				switch f.Kind {
+				case "struct":
+					if st, ok := n.Underlying().(*types.Struct); ok {
+						return util.ImmTypeStruct{Struct: st}
+					}
+				case "map":
+					return util.ImmTypeMap{}
+				case "set":
+					return util.ImmTypeSet{}
+				case "slice":
+					return util.ImmTypeSlice{}
+				}
+			}
+		}
+	}
+
+	return util.IsImmType(t)
+}
+
+func (iv *immutableVetter) ensurePointerTyp(n ast.Node, typ ast.Expr) {
+	if ts, ok := n.(*ast.TypeSpec); ok {
+		if ts.Assign.IsValid() {
+			// we are an alias; this is fine in all cases
+			return
+		}
+	}
+	t := iv.info.Types[typ].Type
+	if t == nil {
+		return
+	}
+	p := types.NewPointer(t)
+	switch iv.immType(p).(type) {
+	case util.ImmTypeMap, util.ImmTypeSet, util.ImmTypeSlice, util.ImmTypeStruct:
+		var fixes []analysis.SuggestedFix
+		if _, ok := n.(*ast.CompositeLit); !ok {
+			fixes = pointerFix(typ)
+		}
+		iv.report(n.Pos(), fixes, "type should be %v", p)
+	}
+}
+
+func (iv *immutableVetter) Visit(node ast.Node) ast.Visitor {
+
+	switch node := node.(type) {
+	case *ast.File:
+		for _, cg := range node.Comments {
+			for _, c := range cg.List {
+				if c.Text == skipFileComment {
+					iv.skipFiles[iv.pass.Fset.Position(node.Pos()).Filename] = true
+					return nil
+				}
+			}
+		}
+	case *ast.ValueSpec:
+		iv.ensurePointerTyp(node, node.Type)
+	case *ast.ArrayType:
+		iv.ensurePointerTyp(node, node.Elt)
+	case *ast.MapType:
+		iv.ensurePointerTyp(node, node.Key)
+		iv.ensurePointerTyp(node, node.Value)
+	case *ast.Field:
+		iv.ensurePointerTyp(node, node.Type)
+	case *ast.UnaryExpr:
+		if node.Op != token.AND {
+			break
+		}
+
+		cl, ok := node.X.(*ast.CompositeLit)
+		if !ok {
+			break
+		}
+
+		t := iv.info.Types[cl.Type].Type
+		p := types.NewPointer(t)
+		switch iv.immType(p).(type) {
+		case util.ImmTypeMap, util.ImmTypeSet, util.ImmTypeSlice, util.ImmTypeStruct:
+			iv.report(node.Pos(), newFix(node, cl), "construct using new() or generated constructors")
+			iv.vcls[cl] = true
+		}
+	case *ast.CompositeLit:
+		if ok := iv.vcls[node]; ok {
+			break
+		}
+
+		iv.ensurePointerTyp(node, node.Type)
+	case *ast.TypeSpec:
+		iv.ensurePointerTyp(node, node.Type)
+	case *ast.SelectorExpr:
+		sel, ok := iv.info.Selections[node]
+		if !ok {
+			// this is fine... !ok implies a selector expression
+			// that is a qualified identifier as opposed to a method
+			// field selector
+			break
+		}
+
+		if !iv.isImmListOrMap(sel.Recv()) {
+			break
+		}
+
+		switch node.Sel.Name {
+		case "Range":
+			if _, ok := iv.rngs[node.Sel]; !ok {
+				iv.rngs[node.Sel] = false
+			}
+		}
+	case *ast.RangeStmt:
+		v := node.X
+		ce, ok := v.(*ast.CallExpr)
+		if !ok {
+			break
+		}
+
+		e := ce.Fun
+		se, ok := e.(*ast.SelectorExpr)
+		if !ok {
+			break
+		}
+
+		sel, ok := iv.info.Selections[se]
+		if !ok {
+			// then it must be a qualified identifier
+			break
+		}
+
+		if !iv.isImmListOrMap(sel.Recv()) {
+			break
+		}
+
+		if sel.Kind() != types.MethodVal {
+			break
+		}
+
+		ri := se.Sel
+		if ri.Name != "Range" {
+			break
+		}
+		iv.rngs[ri] = true
+	case *ast.CallExpr:
+		switch fun := node.Fun.(type) {
+		case *ast.Ident:
+			if fun.Name != "append" {
+				break
+			}
+
+			if len(node.Args) != 2 {
+				break
+			}
+
+			e := node.Args[1]
+			ce, ok := e.(*ast.CallExpr)
+			if !ok {
+				break
+			}
+
+			se, ok := ce.Fun.(*ast.SelectorExpr)
+			if !ok {
+				break
+			}
+
+			sel, ok := iv.info.Selections[se]
+			if !ok {
+				break
+			}
+
+			if !iv.isImmListOrMap(sel.Recv()) {
+				break
+			}
+
+			ri := se.Sel
+			if ri.Name != "Range" {
+				break
+			}
+
+			if node.Ellipsis == node.Args[1].End() {
+				iv.rngs[ri] = true
+			}
+		case *ast.SelectorExpr:
+			sel, ok := iv.info.Selections[fun]
+			if !ok {
+				// this is fine... !ok implies a selector expression
+				// that is a qualified identifier as opposed to a method
+				// field selector
+				break
+			}
+
+			if !iv.isImmListOrMap(sel.Recv()) {
+				break
+			}
+
+			if sel.Kind() != types.MethodVal {
+				break
+			}
+
+			ri := fun.Sel
+			if ri.Name != "Append" {
+				break
+			}
+
+			if len(node.Args) != 1 {
+				break
+			}
+
+			if node.Ellipsis == token.NoPos {
+				break
+			}
+
+			ace, ok := node.Args[0].(*ast.CallExpr)
+			if !ok {
+				break
+			}
+
+			{
+				se, ok := ace.Fun.(*ast.SelectorExpr)
+				if !ok {
+					break
+				}
+
+				sel, ok := iv.info.Selections[se]
+				if !ok {
+					// this is fine... !ok implies a selector expression
+					// that is a qualified identifier as opposed to a method
+					// field selector
+					break
+				}
+
+				if !iv.isImmListOrMap(sel.Recv()) {
+					break
+				}
+
+				if sel.Kind() != types.MethodVal {
+					break
+				}
+
+				ri := se.Sel
+				if ri.Name == "Range" {
+					iv.rngs[ri] = true
+				}
+			}
+		}
+	}
+	return iv
+}
+
+func (iv *immutableVetter) isImmListOrMap(t types.Type) bool {
+	switch iv.immType(t).(type) {
+	case util.ImmTypeMap, util.ImmTypeSet, util.ImmTypeSlice:
+		return true
+	}
+
+	return false
+}
+
+func (iv *immutableVetter) isImmTmpl(t types.Type) bool {
+	switch t := t.(type) {
+	case *types.Pointer:
+		return iv.isImmTmpl(t.Elem())
+	}
+
+	return iv.immTmpls[t]
+}
+
+func (iv *immutableVetter) isImmType(t types.Type) bool {
+	if v, ok := iv.typesCache[t.String()]; ok {
+		return v
+	}
+
+	switch t := t.(type) {
+	case *types.Named:
+
+		iv.typesCache[t.String()] = true
+
+		v := iv.isImmType(t.Underlying())
+		iv.typesCache[t.String()] = v
+
+		return v
+	case *types.Basic:
+		return true
+	case *types.Map, *types.Slice:
+		return false
+	case *types.Pointer:
+		return iv.immType(t) != nil
+	case *types.Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			f := t.Field(i)
+			if !iv.isImmType(f.Type()) {
+				return false
+			}
+		}
+
+		return true
+	case *types.Interface:
+		return types.Implements(t, immIntf)
+	case *types.Signature:
+		return false
+	default:
+		panic(fmt.Errorf("unable to handle type %T %v", t, t))
+	}
+}
+
+func (iv *immutableVetter) errorf(pos token.Pos, format string, args ...interface{}) {
+	iv.report(pos, nil, format, args...)
+}
+
+func (iv *immutableVetter) report(pos token.Pos, fixes []analysis.SuggestedFix, format string, args ...interface{}) {
+	iv.pass.Report(analysis.Diagnostic{
+		Pos:            pos,
+		Message:        fmt.Sprintf(format, args...),
+		SuggestedFixes: fixes,
+	})
+}
@@ -0,0 +1,19 @@
+// Copyright (c) 2016 Paul Jolly <paul@myitcv.org.uk>, all rights reserved.
+// Use of this document is governed by a license found in the LICENSE document.
+
+package immutablevet_test
+
+import (
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+	"myitcv.io/immutable/immutablevet"
+)
+
+func TestAnalyzer(t *testing.T) {
+	testdata := analysistest.TestData()
+
+	analysistest.RunWithSuggestedFixes(t, testdata, immutablevet.Analyzer, "a")
+	analysistest.Run(t, testdata, immutablevet.Analyzer, "b")
+	analysistest.Run(t, testdata, immutablevet.Analyzer, "c")
+}
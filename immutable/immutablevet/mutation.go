@@ -0,0 +1,502 @@
+package immutablevet
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"myitcv.io/immutable/util"
+)
+
+// mutationChecker performs a simple, flow-insensitive, dataflow analysis of
+// a function body in order to find:
+//
+// * mutable values escaping the callback passed to WithMutable
+// * mutable values being stored in immutable values
+// * writes to the result of Range()
+//
+// Variables are tracked in source order. A variable is considered mutable
+// from the point it is assigned a mutable value (the parameter of a
+// WithMutable callback, the result of AsMutable() or an alias of another
+// mutable value) until it is reassigned or AsImmutable is called on it.
+//
+// A mutable value escapes a WithMutable callback if it, or a value that
+// holds it (a composite literal or the result of append with it as an
+// element, or a function literal that captures it), is assigned to a
+// variable declared outside the callback, or to an element or field of such
+// a variable, is sent on a channel, or is passed to a function called in a
+// go statement.
+type mutationChecker struct {
+	iv *immutableVetter
+
+	// mutable is the set of variables currently holding mutable values
+	mutable map[types.Object]bool
+
+	// holders is the set of variables currently holding values that hold
+	// mutable values
+	holders map[types.Object]bool
+
+	// ranges is the set of variables holding the result of Range()
+	ranges map[types.Object]bool
+
+	// callbacks is the stack of WithMutable callbacks we are within
+	callbacks []*ast.FuncLit
+
+	// stack is the stack of nodes being visited
+	stack []ast.Node
+}
+
+func (iv *immutableVetter) checkMutations(f *ast.File) {
+	mc := &mutationChecker{
+		iv:      iv,
+		mutable: make(map[types.Object]bool),
+		holders: make(map[types.Object]bool),
+		ranges:  make(map[types.Object]bool),
+	}
+
+	ast.Inspect(f, mc.visit)
+}
+
+func (mc *mutationChecker) visit(n ast.Node) bool {
+	if n == nil {
+		if l, ok := mc.stack[len(mc.stack)-1].(*ast.FuncLit); ok {
+			if c := len(mc.callbacks); c > 0 && mc.callbacks[c-1] == l {
+				mc.callbacks = mc.callbacks[:c-1]
+			}
+		}
+		mc.stack = mc.stack[:len(mc.stack)-1]
+		return true
+	}
+
+	mc.stack = append(mc.stack, n)
+
+	switch n := n.(type) {
+	case *ast.AssignStmt:
+		mc.assign(n)
+	case *ast.ValueSpec:
+		for i, name := range n.Names {
+			if len(n.Values) == len(n.Names) {
+				mc.track(name, n.Values[i])
+			}
+		}
+	case *ast.IncDecStmt:
+		mc.checkRangeElemWrite(n.X)
+	case *ast.SendStmt:
+		if mc.holdsMutable(n.Value) && len(mc.callbacks) > 0 {
+			mc.iv.errorf(n.Value.Pos(), "mutable value escapes WithMutable callback")
+		}
+	case *ast.GoStmt:
+		if len(mc.callbacks) > 0 {
+			mc.goCall(n.Call)
+		}
+	case *ast.ExprStmt:
+		// x.AsImmutable(...) makes x immutable
+		if ce, ok := n.X.(*ast.CallExpr); ok {
+			if recv, name, ok := mc.immMethod(ce); ok && name == "AsImmutable" {
+				if id, ok := unparen(recv).(*ast.Ident); ok {
+					delete(mc.mutable, mc.iv.info.ObjectOf(id))
+				}
+			}
+		}
+	case *ast.CallExpr:
+		mc.call(n)
+	}
+
+	return true
+}
+
+func (mc *mutationChecker) assign(n *ast.AssignStmt) {
+	if len(n.Lhs) != len(n.Rhs) {
+		// multi-value assignments never involve values we track
+		for _, l := range n.Lhs {
+			mc.untrack(l)
+		}
+		return
+	}
+
+	// the right hand side is evaluated before any assignments happen
+	mutable := make([]bool, len(n.Rhs))
+	holds := make([]bool, len(n.Rhs))
+	ranges := make([]bool, len(n.Rhs))
+	for i, r := range n.Rhs {
+		mutable[i] = mc.isMutable(r)
+		holds[i] = mc.holdsMutable(r)
+		ranges[i] = mc.isRange(r)
+	}
+
+	for i, l := range n.Lhs {
+		mc.checkRangeElemWrite(l)
+
+		if !holds[i] || len(mc.callbacks) == 0 {
+			continue
+		}
+
+		if !mc.isLocal(l, mc.callbacks[len(mc.callbacks)-1]) {
+			mc.iv.errorf(n.Rhs[i].Pos(), "mutable value escapes WithMutable callback")
+		} else if _, ok := unparen(l).(*ast.Ident); !ok {
+			// an element or field of a local variable now holds a mutable
+			// value, and so therefore does the variable
+			if obj := mc.rootObject(l); obj != nil {
+				mc.holders[obj] = true
+			}
+		}
+	}
+
+	for i, l := range n.Lhs {
+		id, ok := l.(*ast.Ident)
+		if !ok {
+			continue
+		}
+
+		obj := mc.iv.info.ObjectOf(id)
+		if obj == nil {
+			continue
+		}
+
+		mc.set(obj, mutable[i], holds[i] && !mutable[i], ranges[i])
+	}
+}
+
+func (mc *mutationChecker) track(id *ast.Ident, v ast.Expr) {
+	if obj := mc.iv.info.ObjectOf(id); obj != nil {
+		mutable := mc.isMutable(v)
+		mc.set(obj, mutable, !mutable && mc.holdsMutable(v), mc.isRange(v))
+	}
+}
+
+func (mc *mutationChecker) untrack(e ast.Expr) {
+	if id, ok := e.(*ast.Ident); ok {
+		if obj := mc.iv.info.ObjectOf(id); obj != nil {
+			mc.set(obj, false, false, false)
+		}
+	}
+}
+
+func (mc *mutationChecker) set(obj types.Object, mutable, holder, rng bool) {
+	if mutable {
+		mc.mutable[obj] = true
+	} else {
+		delete(mc.mutable, obj)
+	}
+
+	if holder {
+		mc.holders[obj] = true
+	} else {
+		delete(mc.holders, obj)
+	}
+
+	if rng {
+		mc.ranges[obj] = true
+	} else {
+		delete(mc.ranges, obj)
+	}
+}
+
+func (mc *mutationChecker) call(ce *ast.CallExpr) {
+	if id, ok := ce.Fun.(*ast.Ident); ok {
+		if _, ok := mc.iv.info.ObjectOf(id).(*types.Builtin); ok && len(ce.Args) > 0 {
+			switch id.Name {
+			case "delete", "copy", "append":
+				mc.checkRangeWrite(ce.Args[0])
+			}
+		}
+		return
+	}
+
+	recv, name, ok := mc.immMethod(ce)
+	if !ok {
+		return
+	}
+
+	switch {
+	case name == "WithMutable":
+		if len(ce.Args) != 1 {
+			break
+		}
+
+		l, ok := ce.Args[0].(*ast.FuncLit)
+		if !ok {
+			break
+		}
+
+		for _, f := range l.Type.Params.List {
+			for _, n := range f.Names {
+				if obj := mc.iv.info.ObjectOf(n); obj != nil {
+					mc.mutable[obj] = true
+				}
+			}
+		}
+
+		mc.callbacks = append(mc.callbacks, l)
+
+	case strings.HasPrefix(name, "Set"), strings.HasPrefix(name, "set"), name == "Append", name == "Add":
+		for _, a := range ce.Args {
+			if mc.isMutable(a) && !sameExpr(mc.iv.info, a, recv) {
+				mc.iv.errorf(a.Pos(), "mutable value stored in immutable value; use AsImmutable or WithMutable")
+			}
+		}
+	}
+}
+
+// immMethod returns the receiver and name of the method called by ce, in
+// case ce is a call of a method on an immutable type.
+func (mc *mutationChecker) immMethod(ce *ast.CallExpr) (ast.Expr, string, bool) {
+	se, ok := ce.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return nil, "", false
+	}
+
+	sel, ok := mc.iv.info.Selections[se]
+	if !ok || sel.Kind() != types.MethodVal {
+		return nil, "", false
+	}
+
+	switch util.IsImmType(sel.Recv()).(type) {
+	case util.ImmTypeStruct, util.ImmTypeMap, util.ImmTypeSet, util.ImmTypeSlice:
+	default:
+		return nil, "", false
+	}
+
+	return se.X, se.Sel.Name, true
+}
+
+// isMutable returns whether e is known to be a mutable value
+func (mc *mutationChecker) isMutable(e ast.Expr) bool {
+	switch e := unparen(e).(type) {
+	case *ast.Ident:
+		return mc.mutable[mc.iv.info.ObjectOf(e)]
+	case *ast.CallExpr:
+		recv, name, ok := mc.immMethod(e)
+		if !ok {
+			return false
+		}
+
+		switch name {
+		case "AsMutable":
+			return true
+		case "AsImmutable", "WithMutable":
+			return false
+		}
+
+		// methods like Set return their receiver where it is mutable
+		if !mc.isMutable(recv) {
+			return false
+		}
+
+		rt := mc.iv.info.TypeOf(recv)
+		et := mc.iv.info.TypeOf(e)
+
+		return rt != nil && et != nil && types.Identical(rt, et)
+	}
+
+	return false
+}
+
+// holdsMutable returns whether e is a mutable value or holds one, i.e. is a
+// variable holding such a value, a composite literal or the result of append
+// with such a value as an element, (the address of) such a composite literal,
+// or a function literal that captures a mutable value.
+func (mc *mutationChecker) holdsMutable(e ast.Expr) bool {
+	if mc.isMutable(e) {
+		return true
+	}
+
+	switch e := unparen(e).(type) {
+	case *ast.Ident:
+		return mc.holders[mc.iv.info.ObjectOf(e)]
+	case *ast.UnaryExpr:
+		return e.Op == token.AND && mc.holdsMutable(e.X)
+	case *ast.CompositeLit:
+		for _, elt := range e.Elts {
+			if kv, ok := elt.(*ast.KeyValueExpr); ok {
+				elt = kv.Value
+			}
+			if mc.holdsMutable(elt) {
+				return true
+			}
+		}
+	case *ast.CallExpr:
+		if !mc.isBuiltin(e, "append") {
+			return false
+		}
+		for _, a := range e.Args {
+			if mc.holdsMutable(a) {
+				return true
+			}
+		}
+	case *ast.FuncLit:
+		return mc.captures(e)
+	}
+
+	return false
+}
+
+// captures returns whether the function literal fl refers to a variable,
+// declared outside of fl, that holds a mutable value
+func (mc *mutationChecker) captures(fl *ast.FuncLit) bool {
+	res := false
+
+	ast.Inspect(fl.Body, func(n ast.Node) bool {
+		id, ok := n.(*ast.Ident)
+		if !ok || res {
+			return !res
+		}
+
+		obj := mc.iv.info.Uses[id]
+		if obj != nil && (obj.Pos() < fl.Pos() || obj.Pos() >= fl.End()) {
+			res = mc.mutable[obj] || mc.holders[obj]
+		}
+
+		return true
+	})
+
+	return res
+}
+
+// goCall reports an error for each mutable value passed to, or captured by,
+// the function called by the go statement whose call is ce, as the function
+// may run after the enclosing WithMutable callback has returned.
+func (mc *mutationChecker) goCall(ce *ast.CallExpr) {
+	switch fun := unparen(ce.Fun).(type) {
+	case *ast.FuncLit:
+		if mc.captures(fun) {
+			mc.iv.errorf(fun.Pos(), "mutable value escapes WithMutable callback")
+		}
+	case *ast.SelectorExpr:
+		if sel, ok := mc.iv.info.Selections[fun]; ok && sel.Kind() == types.MethodVal && mc.holdsMutable(fun.X) {
+			mc.iv.errorf(fun.X.Pos(), "mutable value escapes WithMutable callback")
+		}
+	}
+
+	for _, a := range ce.Args {
+		if mc.holdsMutable(a) {
+			mc.iv.errorf(a.Pos(), "mutable value escapes WithMutable callback")
+		}
+	}
+}
+
+// isBuiltin returns whether ce is a call of the builtin function name
+func (mc *mutationChecker) isBuiltin(ce *ast.CallExpr, name string) bool {
+	id, ok := unparen(ce.Fun).(*ast.Ident)
+	if !ok || id.Name != name {
+		return false
+	}
+
+	_, ok = mc.iv.info.ObjectOf(id).(*types.Builtin)
+
+	return ok
+}
+
+// isRange returns whether e is the result of Range() on an immutable type,
+// or a variable holding such a result
+func (mc *mutationChecker) isRange(e ast.Expr) bool {
+	switch e := unparen(e).(type) {
+	case *ast.Ident:
+		return mc.ranges[mc.iv.info.ObjectOf(e)]
+	case *ast.CallExpr:
+		_, name, ok := mc.immMethod(e)
+		return ok && name == "Range"
+	}
+
+	return false
+}
+
+// checkRangeWrite reports an error if e, the target of a write, is (an
+// element of) the result of Range()
+func (mc *mutationChecker) checkRangeWrite(e ast.Expr) {
+	root := unparen(e)
+	for {
+		ie, ok := root.(*ast.IndexExpr)
+		if !ok {
+			break
+		}
+		root = unparen(ie.X)
+	}
+
+	if mc.isRange(root) {
+		mc.iv.errorf(e.Pos(), "result of Range() of immutable type must not be written to")
+	}
+}
+
+// checkRangeElemWrite reports an error if e, the target of an assignment, is
+// an element of the result of Range()
+func (mc *mutationChecker) checkRangeElemWrite(e ast.Expr) {
+	if _, ok := unparen(e).(*ast.IndexExpr); ok {
+		mc.checkRangeWrite(e)
+	}
+}
+
+// isLocal returns whether the target of the assignment l is local to the
+// function literal fl, i.e. is the blank identifier, a variable declared
+// within fl, or an element or field of such a variable.
+func (mc *mutationChecker) isLocal(l ast.Expr, fl *ast.FuncLit) bool {
+	if id, ok := unparen(l).(*ast.Ident); ok && id.Name == "_" {
+		return true
+	}
+
+	obj := mc.rootObject(l)
+	if obj == nil {
+		// an assignment via a pointer indirection might be to a value that
+		// is local, but we can't tell
+		return false
+	}
+
+	return obj.Pos() >= fl.Pos() && obj.Pos() < fl.End()
+}
+
+// rootObject returns the variable of which the target of the assignment l is
+// an element or field (or which is l itself), or nil if l involves a pointer
+// indirection.
+func (mc *mutationChecker) rootObject(l ast.Expr) types.Object {
+	for {
+		switch e := unparen(l).(type) {
+		case *ast.Ident:
+			return mc.iv.info.ObjectOf(e)
+		case *ast.IndexExpr:
+			if _, ok := mc.iv.info.TypeOf(e.X).Underlying().(*types.Pointer); ok {
+				return nil
+			}
+			l = e.X
+		case *ast.SelectorExpr:
+			sel, ok := mc.iv.info.Selections[e]
+			if !ok {
+				// a qualified identifier
+				return mc.iv.info.ObjectOf(e.Sel)
+			}
+			if sel.Indirect() {
+				return nil
+			}
+			if _, ok := mc.iv.info.TypeOf(e.X).Underlying().(*types.Pointer); ok {
+				return nil
+			}
+			l = e.X
+		default:
+			return nil
+		}
+	}
+}
+
+func sameExpr(info *types.Info, a, b ast.Expr) bool {
+	ai, ok := unparen(a).(*ast.Ident)
+	if !ok {
+		return false
+	}
+
+	bi, ok := unparen(b).(*ast.Ident)
+	if !ok {
+		return false
+	}
+
+	return info.ObjectOf(ai) == info.ObjectOf(bi)
+}
+
+func unparen(e ast.Expr) ast.Expr {
+	for {
+		p, ok := e.(*ast.ParenExpr)
+		if !ok {
+			return e
+		}
+		e = p.X
+	}
+}
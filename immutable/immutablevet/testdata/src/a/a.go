@@ -0,0 +1,22 @@
+package a
+
+type _Imm_Foo struct {
+	Name string
+}
+
+type _Imm_Bar struct {
+	foo  Foo   // want "immutable struct field must be immutable type; a.Foo is not" `type should be \*a.Foo`
+	Tags []int // want `immutable struct field must be immutable type; \[\]int is not`
+}
+
+type _Imm_ints []int
+
+var _ Foo // want `type should be \*a.Foo`
+
+func fn(l *ints) {
+	f := &Foo{} // want `construct using new\(\) or generated constructors` `non-pointer value of immutable type \*a.Foo found`
+	_ = f
+
+	r := l.Range() // want `Range\(\) of immutable type must appear in a range statement`
+	_ = r
+}
@@ -0,0 +1,8 @@
+package b
+
+import "a"
+
+var _ a.Foo // want `type should be \*a.Foo`
+
+func fn(l []a.Foo) { // want `type should be \*a.Foo`
+}
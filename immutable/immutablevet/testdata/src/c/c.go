@@ -0,0 +1,62 @@
+package c
+
+import "a"
+
+var (
+	escaped *a.Foo
+	foos    []*a.Foo
+	byName  map[string]*a.Foo
+	holder  struct{ foo *a.Foo }
+	get     func() *a.Foo
+)
+
+func use(f *a.Foo) {}
+
+func escapes(f *a.Foo) {
+	f.WithMutable(func(fm *a.Foo) {
+		escaped = fm // want `mutable value escapes WithMutable callback`
+
+		foos = append(foos, fm) // want `mutable value escapes WithMutable callback`
+		foos[0] = fm            // want `mutable value escapes WithMutable callback`
+		byName["fm"] = fm       // want `mutable value escapes WithMutable callback`
+		holder.foo = fm         // want `mutable value escapes WithMutable callback`
+		foos = []*a.Foo{fm}     // want `mutable value escapes WithMutable callback`
+		get = func() *a.Foo {   // want `mutable value escapes WithMutable callback`
+			return fm
+		}
+
+		go use(fm)  // want `mutable value escapes WithMutable callback`
+		go func() { // want `mutable value escapes WithMutable callback`
+			use(fm)
+		}()
+
+		// values held by local variables escape if those variables do
+		held := append([]*a.Foo{}, fm)
+		foos = held // want `mutable value escapes WithMutable callback`
+
+		getter := func() *a.Foo { return fm }
+		get = getter // want `mutable value escapes WithMutable callback`
+
+		var m map[string]*a.Foo
+		m["fm"] = fm
+		byName = m // want `mutable value escapes WithMutable callback`
+	})
+}
+
+func local(f *a.Foo) {
+	f.WithMutable(func(fm *a.Foo) {
+		l := []*a.Foo{fm}
+		l[0] = fm
+		l = append(l, fm)
+		_ = l
+
+		s := struct{ foo *a.Foo }{}
+		s.foo = fm
+		_ = s
+
+		g := func() *a.Foo { return fm }
+		use(g())
+
+		defer use(fm)
+	})
+}
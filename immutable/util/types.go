@@ -31,6 +31,13 @@ type (
 		Elem types.Type
 	}
 
+	// ImmTypeSet is used to indicate a type that is immutable by virtue of
+	// being a pointer to a struct type that was itself generated from an _Imm_
+	// map template with the set option.
+	ImmTypeSet struct {
+		Elem types.Type
+	}
+
 	// ImmTypeMap is used to indicate a type that is immutable by virtue of
 	// being a pointer to a struct type that was itself generated from an _Imm_
 	// slice template.
@@ -39,8 +46,8 @@ type (
 	}
 
 	// ImmTypeImplsIntf is used to indicate a type that is not an ImmTypeStruct,
-	// ImmTypeMap or ImmTypeSlice, but still satisfies the immutable "interface".
-	// See the docs for myitcv.io/immutable.Immutable.
+	// ImmTypeMap, ImmTypeSet or ImmTypeSlice, but still satisfies the immutable
+	// "interface". See the docs for myitcv.io/immutable.Immutable.
 	ImmTypeImplsIntf struct{}
 
 	// ImmTypeSimple is used to indiciate an interface type that extends the
@@ -51,6 +58,7 @@ type (
 func (i ImmTypeBasic) isImmType()     {}
 func (i ImmTypeStruct) isImmType()    {}
 func (i ImmTypeMap) isImmType()       {}
+func (i ImmTypeSet) isImmType()       {}
 func (i ImmTypeSlice) isImmType()     {}
 func (i ImmTypeImplsIntf) isImmType() {}
 func (i ImmTypeSimple) isImmType()    {}
@@ -272,15 +280,42 @@ func (i *immCache) lookup(tt types.Type) (v ImmType) {
 		case "__tmpl":
 			hasTmpl = true
 		case "theMap":
-			m := f.Type().(*types.Map)
-			v = ImmTypeMap{
-				Key:  m.Key(),
-				Elem: m.Elem(),
+			switch m := f.Type().(type) {
+			case *types.Map:
+				v = ImmTypeMap{
+					Key:  m.Key(),
+					Elem: m.Elem(),
+				}
+			default:
+				// a map backed by a persistent data structure, e.g.
+				// *hamt.Map[K, V]
+				if args := typeArgs(m); len(args) == 2 {
+					v = ImmTypeMap{
+						Key:  args[0],
+						Elem: args[1],
+					}
+				}
+			}
+		case "theSet":
+			if m, ok := f.Type().(*types.Map); ok {
+				v = ImmTypeSet{
+					Elem: m.Key(),
+				}
 			}
 		case "theSlice":
-			s := f.Type().(*types.Slice)
-			v = ImmTypeSlice{
-				Elem: s.Elem(),
+			switch s := f.Type().(type) {
+			case *types.Slice:
+				v = ImmTypeSlice{
+					Elem: s.Elem(),
+				}
+			default:
+				// a slice backed by a persistent data structure, e.g.
+				// *vector.Vector[T]
+				if args := typeArgs(s); len(args) == 1 {
+					v = ImmTypeSlice{
+						Elem: args[0],
+					}
+				}
 			}
 		}
 	}
@@ -294,9 +329,29 @@ func (i *immCache) lookup(tt types.Type) (v ImmType) {
 	return
 }
 
+// typeArgs returns the type arguments of t, or the type pointed to by t, in
+// case it is an instantiated generic type.
+func typeArgs(t types.Type) []types.Type {
+	if pt, ok := t.(*types.Pointer); ok {
+		t = pt.Elem()
+	}
+
+	nt, ok := t.(*types.Named)
+	if !ok || nt.TypeArgs() == nil {
+		return nil
+	}
+
+	res := make([]types.Type, nt.TypeArgs().Len())
+	for i := range res {
+		res[i] = nt.TypeArgs().At(i)
+	}
+
+	return res
+}
+
 // IsImmType determines whether the supplied type is an immutable type. In case
-// a type is immutable, a value of type ImmTypeStruct, ImmTypeSlice or
-// ImmTypeMap is returned. In case the type is immutable but neither of the
+// a type is immutable, a value of type ImmTypeStruct, ImmTypeSlice, ImmTypeMap
+// or ImmTypeSet is returned. In case the type is immutable but neither of the
 // aforementioned instances, ImmTypeUnknown is returned. If a type is not
 // immutable then nil is returned
 func IsImmType(t types.Type) ImmType {
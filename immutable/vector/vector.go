@@ -0,0 +1,425 @@
+// Copyright (c) 2016 Paul Jolly <paul@myitcv.org.uk>, all rights reserved.
+// Use of this document is governed by a license found in the LICENSE document.
+
+// Package vector provides a persistent vector. It is the representation used
+// by immutable slices generated by myitcv.io/immutable/cmd/immutableGen from
+// templates that carry the //immutableGen:vector marker.
+//
+// A Vector is a relaxed radix balanced (RRB) tree: a 32-way tree in which
+// every leaf is at the same depth, and each internal node records the
+// cumulative sizes of its children so that nodes need not be full. Get and
+// Set are O(log n), as are Append, Concat and Slice, all of which share
+// unchanged nodes with their operands.
+//
+// A *Vector is never modified once created. A nil *Vector is a valid, empty
+// vector.
+package vector
+
+import "sort"
+
+const (
+	// maxWidth is the maximum number of elements in a leaf or children in an
+	// internal node
+	maxWidth = 32
+
+	// minWidth is the occupancy below which nodes are rebalanced with their
+	// neighbour when two trees are joined
+	minWidth = maxWidth / 2
+)
+
+// Vector is a persistent sequence of values of type T.
+type Vector[T any] struct {
+	root *node[T]
+
+	// height is the number of internal levels above the leaves; a Vector
+	// whose root is a leaf has height 0
+	height int
+}
+
+// node is either a leaf, in which case elems holds its values, or an
+// internal node, in which case children holds its children and sizes[i] is
+// the total number of elements in children[:i+1]. Whether a node is a leaf
+// is determined by its height within the tree.
+type node[T any] struct {
+	elems    []T
+	children []*node[T]
+	sizes    []int
+}
+
+// New returns a Vector containing the values vs.
+func New[T any](vs ...T) *Vector[T] {
+	if len(vs) == 0 {
+		return nil
+	}
+
+	var level []*node[T]
+	for i := 0; i < len(vs); i += maxWidth {
+		j := i + maxWidth
+		if j > len(vs) {
+			j = len(vs)
+		}
+		l := &node[T]{elems: make([]T, j-i)}
+		copy(l.elems, vs[i:j])
+		level = append(level, l)
+	}
+
+	height := 0
+	for len(level) > 1 {
+		var next []*node[T]
+		for i := 0; i < len(level); i += maxWidth {
+			j := i + maxWidth
+			if j > len(level) {
+				j = len(level)
+			}
+			next = append(next, newInternal(height+1, level[i:j]...))
+		}
+		level = next
+		height++
+	}
+
+	return &Vector[T]{root: level[0], height: height}
+}
+
+// Len returns the number of elements in v.
+func (v *Vector[T]) Len() int {
+	if v == nil || v.root == nil {
+		return 0
+	}
+
+	return v.root.size(v.height)
+}
+
+// Get returns the element at index i. It panics if i is out of range.
+func (v *Vector[T]) Get(i int) T {
+	v.checkIndex(i)
+
+	n := v.root
+	for h := v.height; h > 0; h-- {
+		j := n.childIndex(i)
+		if j > 0 {
+			i -= n.sizes[j-1]
+		}
+		n = n.children[j]
+	}
+
+	return n.elems[i]
+}
+
+// Set returns a vector that is v with the element at index i set to x. It
+// panics if i is out of range.
+func (v *Vector[T]) Set(i int, x T) *Vector[T] {
+	v.checkIndex(i)
+
+	return &Vector[T]{
+		root:   v.root.set(v.height, i, x),
+		height: v.height,
+	}
+}
+
+// Append returns a vector that is v with the values xs appended.
+func (v *Vector[T]) Append(xs ...T) *Vector[T] {
+	return v.Concat(New(xs...))
+}
+
+// Concat returns a vector that is the concatenation of v and w.
+func (v *Vector[T]) Concat(w *Vector[T]) *Vector[T] {
+	if w.Len() == 0 {
+		return v
+	}
+	if v.Len() == 0 {
+		return w
+	}
+
+	var ns []*node[T]
+	height := v.height
+
+	if v.height >= w.height {
+		ns = appendTree(v.root, v.height, w.root, w.height)
+	} else {
+		ns = prependTree(w.root, w.height, v.root, v.height)
+		height = w.height
+	}
+
+	if len(ns) == 1 {
+		return &Vector[T]{root: ns[0], height: height}
+	}
+
+	return &Vector[T]{root: newInternal(height+1, ns...), height: height + 1}
+}
+
+// Slice returns a vector containing the elements of v in the range [i, j).
+// It panics if the range is invalid, as would slicing a Go slice of length
+// v.Len().
+func (v *Vector[T]) Slice(i, j int) *Vector[T] {
+	l := v.Len()
+	if i < 0 || j < i || j > l {
+		panic("vector: slice bounds out of range")
+	}
+
+	if i == 0 && j == l {
+		return v
+	}
+	if i == j {
+		return nil
+	}
+
+	root, height := v.root, v.height
+
+	if j < l {
+		root, _ = root.split(height, j)
+		root, height = trim(root, height)
+	}
+
+	if i > 0 {
+		_, root = root.split(height, i)
+		root, height = trim(root, height)
+	}
+
+	return &Vector[T]{root: root, height: height}
+}
+
+// Range calls f for each index and element in v in order, stopping if f
+// returns false.
+func (v *Vector[T]) Range(f func(i int, x T) bool) {
+	if v == nil || v.root == nil {
+		return
+	}
+
+	i := 0
+	v.root.rng(v.height, &i, f)
+}
+
+// ToSlice returns a newly allocated Go slice containing the elements of v.
+func (v *Vector[T]) ToSlice() []T {
+	res := make([]T, 0, v.Len())
+
+	v.Range(func(_ int, x T) bool {
+		res = append(res, x)
+		return true
+	})
+
+	return res
+}
+
+func (v *Vector[T]) checkIndex(i int) {
+	if i < 0 || i >= v.Len() {
+		panic("vector: index out of range")
+	}
+}
+
+// newInternal returns an internal node at the given height with the
+// supplied children.
+func newInternal[T any](height int, children ...*node[T]) *node[T] {
+	res := &node[T]{
+		children: make([]*node[T], len(children)),
+		sizes:    make([]int, len(children)),
+	}
+	copy(res.children, children)
+
+	total := 0
+	for i, c := range children {
+		total += c.size(height - 1)
+		res.sizes[i] = total
+	}
+
+	return res
+}
+
+func (n *node[T]) size(height int) int {
+	if height == 0 {
+		return len(n.elems)
+	}
+
+	return n.sizes[len(n.sizes)-1]
+}
+
+// width is the number of elements or children in n
+func (n *node[T]) width(height int) int {
+	if height == 0 {
+		return len(n.elems)
+	}
+
+	return len(n.children)
+}
+
+// childIndex returns the index of the child of n that contains element i.
+func (n *node[T]) childIndex(i int) int {
+	return sort.Search(len(n.sizes), func(j int) bool {
+		return n.sizes[j] > i
+	})
+}
+
+func (n *node[T]) set(height int, i int, x T) *node[T] {
+	if height == 0 {
+		res := &node[T]{elems: make([]T, len(n.elems))}
+		copy(res.elems, n.elems)
+		res.elems[i] = x
+
+		return res
+	}
+
+	j := n.childIndex(i)
+	if j > 0 {
+		i -= n.sizes[j-1]
+	}
+
+	res := &node[T]{
+		children: make([]*node[T], len(n.children)),
+		sizes:    n.sizes,
+	}
+	copy(res.children, n.children)
+	res.children[j] = n.children[j].set(height-1, i, x)
+
+	return res
+}
+
+// split returns the nodes, at the same height as n, that contain the
+// elements before and from index i respectively. Either result may be nil if
+// it would be empty.
+func (n *node[T]) split(height int, i int) (*node[T], *node[T]) {
+	if i == 0 {
+		return nil, n
+	}
+	if i == n.size(height) {
+		return n, nil
+	}
+
+	if height == 0 {
+		left := &node[T]{elems: make([]T, i)}
+		copy(left.elems, n.elems[:i])
+		right := &node[T]{elems: make([]T, len(n.elems)-i)}
+		copy(right.elems, n.elems[i:])
+
+		return left, right
+	}
+
+	j := n.childIndex(i)
+	if j > 0 {
+		i -= n.sizes[j-1]
+	}
+
+	cl, cr := n.children[j].split(height-1, i)
+
+	lcs := append([]*node[T](nil), n.children[:j]...)
+	if cl != nil {
+		lcs = append(lcs, cl)
+	}
+
+	var rcs []*node[T]
+	if cr != nil {
+		rcs = append(rcs, cr)
+	}
+	rcs = append(rcs, n.children[j+1:]...)
+
+	var left, right *node[T]
+	if len(lcs) > 0 {
+		left = newInternal(height, lcs...)
+	}
+	if len(rcs) > 0 {
+		right = newInternal(height, rcs...)
+	}
+
+	return left, right
+}
+
+// trim removes internal roots with a single child, returning the new root
+// and height.
+func trim[T any](n *node[T], height int) (*node[T], int) {
+	for height > 0 && len(n.children) == 1 {
+		n = n.children[0]
+		height--
+	}
+
+	return n, height
+}
+
+// appendTree joins the tree b, of height hb, to the right of the tree a, of
+// height ha >= hb. The result is one or two nodes of height ha.
+func appendTree[T any](a *node[T], ha int, b *node[T], hb int) []*node[T] {
+	if ha == hb {
+		return mergeNodes(a, b, ha)
+	}
+
+	last := len(a.children) - 1
+	cs := append([]*node[T](nil), a.children[:last]...)
+	cs = append(cs, appendTree(a.children[last], ha-1, b, hb)...)
+
+	return splitChildren(ha, cs)
+}
+
+// prependTree joins the tree a, of height ha, to the left of the tree b, of
+// height hb > ha. The result is one or two nodes of height hb.
+func prependTree[T any](b *node[T], hb int, a *node[T], ha int) []*node[T] {
+	if ha == hb {
+		return mergeNodes(a, b, hb)
+	}
+
+	cs := prependTree(b.children[0], hb-1, a, ha)
+	cs = append(cs, b.children[1:]...)
+
+	return splitChildren(hb, cs)
+}
+
+// splitChildren returns one internal node of the given height with children
+// cs, or two in case len(cs) > maxWidth.
+func splitChildren[T any](height int, cs []*node[T]) []*node[T] {
+	if len(cs) <= maxWidth {
+		return []*node[T]{newInternal(height, cs...)}
+	}
+
+	mid := len(cs) / 2
+
+	return []*node[T]{newInternal(height, cs[:mid]...), newInternal(height, cs[mid:]...)}
+}
+
+// mergeNodes joins the adjacent nodes a and b, both of the given height. If
+// their contents fit in a single node the result is that node. Otherwise the
+// result is two nodes, with the contents redistributed in case either of a
+// or b is less than half full.
+func mergeNodes[T any](a, b *node[T], height int) []*node[T] {
+	wa, wb := a.width(height), b.width(height)
+
+	if wa+wb > maxWidth && wa >= minWidth && wb >= minWidth {
+		return []*node[T]{a, b}
+	}
+
+	if height == 0 {
+		elems := make([]T, 0, wa+wb)
+		elems = append(elems, a.elems...)
+		elems = append(elems, b.elems...)
+
+		if len(elems) <= maxWidth {
+			return []*node[T]{{elems: elems}}
+		}
+
+		mid := len(elems) / 2
+
+		return []*node[T]{{elems: elems[:mid:mid]}, {elems: elems[mid:]}}
+	}
+
+	cs := make([]*node[T], 0, wa+wb)
+	cs = append(cs, a.children...)
+	cs = append(cs, b.children...)
+
+	return splitChildren(height, cs)
+}
+
+func (n *node[T]) rng(height int, i *int, f func(i int, x T) bool) bool {
+	if height == 0 {
+		for _, x := range n.elems {
+			if !f(*i, x) {
+				return false
+			}
+			*i++
+		}
+		return true
+	}
+
+	for _, c := range n.children {
+		if !c.rng(height-1, i, f) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2016 Paul Jolly <paul@myitcv.org.uk>, all rights reserved.
+// Use of this document is governed by a license found in the LICENSE document.
+
+package vector
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestNilVector(t *testing.T) {
+	var v *Vector[int]
+
+	if l := v.Len(); l != 0 {
+		t.Fatalf("expected length 0; got %v", l)
+	}
+
+	if l := v.Append(1).Len(); l != 1 {
+		t.Fatalf("expected length 1; got %v", l)
+	}
+
+	if l := v.Slice(0, 0).Len(); l != 0 {
+		t.Fatalf("expected length 0; got %v", l)
+	}
+}
+
+func TestPersistence(t *testing.T) {
+	v1 := New(1, 2, 3)
+	v2 := v1.Set(1, 42)
+	v3 := v2.Append(4)
+
+	check(t, v1, []int{1, 2, 3})
+	check(t, v2, []int{1, 42, 3})
+	check(t, v3, []int{1, 42, 3, 4})
+}
+
+func TestAppendOneAtATime(t *testing.T) {
+	var v *Vector[int]
+	var exp []int
+
+	for i := 0; i < 5000; i++ {
+		v = v.Append(i)
+		exp = append(exp, i)
+	}
+
+	check(t, v, exp)
+
+	if v.height > 4 {
+		t.Fatalf("expected height of at most 4; got %v", v.height)
+	}
+}
+
+func TestAgainstSlice(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	randVec := func() (*Vector[int], []int) {
+		n := r.Intn(3000)
+		s := make([]int, n)
+		for i := range s {
+			s[i] = r.Int()
+		}
+		return New(s...), s
+	}
+
+	v, exp := randVec()
+
+	for i := 0; i < 2000; i++ {
+		switch r.Intn(5) {
+		case 0:
+			if len(exp) == 0 {
+				continue
+			}
+			j, x := r.Intn(len(exp)), r.Int()
+			v = v.Set(j, x)
+			exp = append([]int(nil), exp...)
+			exp[j] = x
+		case 1:
+			x := r.Int()
+			v = v.Append(x)
+			exp = append(exp[:len(exp):len(exp)], x)
+		case 2:
+			w, ws := randVec()
+			if r.Intn(2) == 0 {
+				v = v.Concat(w)
+				exp = append(exp[:len(exp):len(exp)], ws...)
+			} else {
+				v = w.Concat(v)
+				exp = append(ws, exp...)
+			}
+		case 3, 4:
+			j := r.Intn(len(exp) + 1)
+			k := j + r.Intn(len(exp)-j+1)
+			v = v.Slice(j, k)
+			exp = exp[j:k]
+		}
+
+		check(t, v, exp)
+	}
+}
+
+func TestSliceBounds(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected panic")
+		}
+	}()
+
+	New(1, 2, 3).Slice(2, 4)
+}
+
+func check(t *testing.T, v *Vector[int], exp []int) {
+	t.Helper()
+
+	if l := v.Len(); l != len(exp) {
+		t.Fatalf("expected length %v; got %v", len(exp), l)
+	}
+
+	for i, x := range exp {
+		if g := v.Get(i); g != x {
+			t.Fatalf("expected Get(%v) to be %v; got %v", i, x, g)
+		}
+	}
+
+	got := v.ToSlice()
+	for i, x := range exp {
+		if got[i] != x {
+			t.Fatalf("expected Range to give %v at %v; got %v", x, i, got[i])
+		}
+	}
+}
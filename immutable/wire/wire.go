@@ -0,0 +1,319 @@
+// Copyright (c) 2016 Paul Jolly <paul@myitcv.org.uk>, all rights reserved.
+// Use of this document is governed by a license found in the LICENSE document.
+
+// Package wire implements the binary format used by the MarshalBinary and
+// UnmarshalBinary methods of immutable types generated by
+// myitcv.io/immutable/cmd/immutableGen from templates that carry the
+// //immutableGen:binary marker.
+//
+// A value is encoded as a sequence of fields. A field is encoded as its tag
+// and the length of its payload, both unsigned varints, followed by the
+// payload itself. Because every field carries its length, a Decoder can skip
+// fields whose tags it does not know, and a field that is absent decodes as
+// the zero value. Hence a value encoded from one version of a struct template
+// can be decoded by a later (or earlier) version that has had fields added or
+// removed, so long as the tags and types of the remaining fields are
+// unchanged.
+//
+// Payloads are encoded as follows:
+//
+//	bool                       a single byte, 1
+//	signed integers            a zig-zag varint
+//	unsigned integers          a varint
+//	floats                     8 bytes, the little-endian IEEE 754 bits
+//	string, []byte             the bytes themselves
+//	encoding.BinaryMarshaler   the result of MarshalBinary
+//	message                    a nested sequence of fields
+//	other types                an encoding/gob stream
+//
+// Fields of the basic kinds with a zero value are not encoded. The elements
+// of maps, sets and slices are each encoded as a message field with tag 1,
+// containing the element (tag 1) or, for maps, the key (tag 1) and value
+// (tag 2), so that zero elements are preserved.
+package wire
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Encoder encodes a sequence of fields. The zero value is an empty sequence
+// ready to use. The first error encountered is recorded and returned by
+// Result; subsequent calls after an error are no-ops.
+type Encoder struct {
+	buf []byte
+	err error
+}
+
+// Result returns the encoded fields, and the first error encountered.
+func (e *Encoder) Result() ([]byte, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+
+	return e.buf, nil
+}
+
+func (e *Encoder) field(tag uint64, payload []byte) {
+	if e.err != nil {
+		return
+	}
+
+	var b [binary.MaxVarintLen64]byte
+
+	n := binary.PutUvarint(b[:], tag)
+	e.buf = append(e.buf, b[:n]...)
+
+	n = binary.PutUvarint(b[:], uint64(len(payload)))
+	e.buf = append(e.buf, b[:n]...)
+
+	e.buf = append(e.buf, payload...)
+}
+
+// Bool encodes v as field tag, unless v is false.
+func (e *Encoder) Bool(tag uint64, v bool) {
+	if v {
+		e.field(tag, []byte{1})
+	}
+}
+
+// Int encodes v as field tag, unless v is 0.
+func (e *Encoder) Int(tag uint64, v int64) {
+	if v == 0 {
+		return
+	}
+
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(b[:], v)
+	e.field(tag, b[:n])
+}
+
+// Uint encodes v as field tag, unless v is 0.
+func (e *Encoder) Uint(tag uint64, v uint64) {
+	if v == 0 {
+		return
+	}
+
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	e.field(tag, b[:n])
+}
+
+// Float encodes v as field tag, unless v is (positive) 0.
+func (e *Encoder) Float(tag uint64, v float64) {
+	bits := math.Float64bits(v)
+	if bits == 0 {
+		return
+	}
+
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], bits)
+	e.field(tag, b[:])
+}
+
+// String encodes v as field tag, unless v is empty.
+func (e *Encoder) String(tag uint64, v string) {
+	if v != "" {
+		e.field(tag, []byte(v))
+	}
+}
+
+// Bytes encodes v as field tag, unless v is empty.
+func (e *Encoder) Bytes(tag uint64, v []byte) {
+	if len(v) != 0 {
+		e.field(tag, v)
+	}
+}
+
+// Message encodes the fields encoded by f as field tag. The field is encoded
+// even if f encodes no fields.
+func (e *Encoder) Message(tag uint64, f func(e *Encoder)) {
+	if e.err != nil {
+		return
+	}
+
+	var m Encoder
+	f(&m)
+
+	if m.err != nil {
+		e.err = m.err
+		return
+	}
+
+	e.field(tag, m.buf)
+}
+
+// Marshaler encodes the result of v.MarshalBinary() as field tag.
+func (e *Encoder) Marshaler(tag uint64, v encoding.BinaryMarshaler) {
+	if e.err != nil {
+		return
+	}
+
+	b, err := v.MarshalBinary()
+	if err != nil {
+		e.err = err
+		return
+	}
+
+	e.field(tag, b)
+}
+
+// Value encodes v using encoding/gob as field tag.
+func (e *Encoder) Value(tag uint64, v interface{}) {
+	if e.err != nil {
+		return
+	}
+
+	var b bytes.Buffer
+
+	if err := gob.NewEncoder(&b).Encode(v); err != nil {
+		e.err = fmt.Errorf("wire: failed to encode field %v: %v", tag, err)
+		return
+	}
+
+	e.field(tag, b.Bytes())
+}
+
+// Decoder decodes a sequence of fields. Next advances to the next field, the
+// payload of which is then decoded by one of the remaining methods. The first
+// error encountered, by the Decoder or any Decoder returned by Message, is
+// recorded and returned by Err; Next returns false once an error has been
+// encountered.
+type Decoder struct {
+	b []byte
+
+	tag uint64
+	val []byte
+
+	err *error
+}
+
+// NewDecoder returns a Decoder for the fields encoded in b.
+func NewDecoder(b []byte) *Decoder {
+	return &Decoder{
+		b:   b,
+		err: new(error),
+	}
+}
+
+var errTruncated = errors.New("wire: truncated field")
+
+// Next advances to the next field, returning false if there are no more
+// fields or an error has been encountered.
+func (d *Decoder) Next() bool {
+	if *d.err != nil || len(d.b) == 0 {
+		return false
+	}
+
+	tag, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		d.fail(errTruncated)
+		return false
+	}
+
+	l, m := binary.Uvarint(d.b[n:])
+	if m <= 0 || l > uint64(len(d.b)-n-m) {
+		d.fail(errTruncated)
+		return false
+	}
+
+	start := n + m
+	end := start + int(l)
+
+	d.tag = tag
+	d.val = d.b[start:end]
+	d.b = d.b[end:]
+
+	return true
+}
+
+// Tag returns the tag of the current field.
+func (d *Decoder) Tag() uint64 {
+	return d.tag
+}
+
+// Err returns the first error encountered.
+func (d *Decoder) Err() error {
+	return *d.err
+}
+
+func (d *Decoder) fail(err error) {
+	if *d.err == nil {
+		*d.err = err
+	}
+}
+
+// Bool decodes the current field as a bool.
+func (d *Decoder) Bool() bool {
+	return len(d.val) != 0 && d.val[0] != 0
+}
+
+// Int decodes the current field as a signed integer.
+func (d *Decoder) Int() int64 {
+	v, n := binary.Varint(d.val)
+	if n <= 0 {
+		d.fail(fmt.Errorf("wire: invalid integer in field %v", d.tag))
+	}
+
+	return v
+}
+
+// Uint decodes the current field as an unsigned integer.
+func (d *Decoder) Uint() uint64 {
+	v, n := binary.Uvarint(d.val)
+	if n <= 0 {
+		d.fail(fmt.Errorf("wire: invalid unsigned integer in field %v", d.tag))
+	}
+
+	return v
+}
+
+// Float decodes the current field as a float.
+func (d *Decoder) Float() float64 {
+	if len(d.val) != 8 {
+		d.fail(fmt.Errorf("wire: invalid float in field %v", d.tag))
+		return 0
+	}
+
+	return math.Float64frombits(binary.LittleEndian.Uint64(d.val))
+}
+
+// String decodes the current field as a string.
+func (d *Decoder) String() string {
+	return string(d.val)
+}
+
+// Bytes decodes the current field as a []byte. The result does not alias
+// the data being decoded.
+func (d *Decoder) Bytes() []byte {
+	return append([]byte(nil), d.val...)
+}
+
+// Message returns a Decoder for the fields of the message encoded in the
+// current field.
+func (d *Decoder) Message() *Decoder {
+	return &Decoder{
+		b:   d.val,
+		err: d.err,
+	}
+}
+
+// Unmarshaler decodes the current field by calling v.UnmarshalBinary.
+func (d *Decoder) Unmarshaler(v encoding.BinaryUnmarshaler) {
+	if err := v.UnmarshalBinary(d.val); err != nil {
+		d.fail(err)
+	}
+}
+
+// Value decodes the current field into v, which must be a pointer, using
+// encoding/gob.
+func (d *Decoder) Value(v interface{}) {
+	if err := gob.NewDecoder(bytes.NewReader(d.val)).Decode(v); err != nil {
+		d.fail(fmt.Errorf("wire: failed to decode field %v: %v", d.tag, err))
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2016 Paul Jolly <paul@myitcv.org.uk>, all rights reserved.
+// Use of this document is governed by a license found in the LICENSE document.
+
+package wire
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	var e Encoder
+
+	e.Bool(1, true)
+	e.Int(2, math.MinInt64)
+	e.Uint(3, math.MaxUint64)
+	e.Float(4, -0.5)
+	e.String(5, "hello")
+	e.Bytes(6, []byte{0, 1})
+	e.Message(7, func(e *Encoder) {
+		e.Int(1, -1)
+	})
+	e.Value(8, []string{"a"})
+
+	b, err := e.Result()
+	if err != nil {
+		t.Fatalf("failed to encode: %v", err)
+	}
+
+	d := NewDecoder(b)
+
+	var tags []uint64
+
+	for d.Next() {
+		tags = append(tags, d.Tag())
+
+		switch d.Tag() {
+		case 1:
+			if !d.Bool() {
+				t.Errorf("expected true")
+			}
+		case 2:
+			if v := d.Int(); v != math.MinInt64 {
+				t.Errorf("expected %v; got %v", int64(math.MinInt64), v)
+			}
+		case 3:
+			if v := d.Uint(); v != math.MaxUint64 {
+				t.Errorf("expected %v; got %v", uint64(math.MaxUint64), v)
+			}
+		case 4:
+			if v := d.Float(); v != -0.5 {
+				t.Errorf("expected -0.5; got %v", v)
+			}
+		case 5:
+			if v := d.String(); v != "hello" {
+				t.Errorf("expected hello; got %q", v)
+			}
+		case 6:
+			if v := d.Bytes(); len(v) != 2 || v[1] != 1 {
+				t.Errorf("expected [0 1]; got %v", v)
+			}
+		case 7:
+			m := d.Message()
+			if !m.Next() || m.Tag() != 1 || m.Int() != -1 || m.Next() {
+				t.Errorf("unexpected message")
+			}
+		case 8:
+			var v []string
+			d.Value(&v)
+			if len(v) != 1 || v[0] != "a" {
+				t.Errorf("expected [a]; got %v", v)
+			}
+		}
+	}
+
+	if err := d.Err(); err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+
+	if len(tags) != 8 {
+		t.Fatalf("expected 8 fields; got %v", tags)
+	}
+}
+
+func TestZeroValuesOmitted(t *testing.T) {
+	var e Encoder
+
+	e.Bool(1, false)
+	e.Int(2, 0)
+	e.Uint(3, 0)
+	e.Float(4, 0)
+	e.String(5, "")
+	e.Bytes(6, nil)
+
+	if b, _ := e.Result(); len(b) != 0 {
+		t.Fatalf("expected no fields; got %v", b)
+	}
+
+	// an empty message is not omitted
+	e.Message(1, func(e *Encoder) {})
+
+	if b, _ := e.Result(); len(b) != 2 {
+		t.Fatalf("expected an empty message field; got %v", b)
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	var e Encoder
+	e.Message(1, func(e *Encoder) {
+		e.String(1, "hello")
+	})
+
+	b, _ := e.Result()
+
+	// the error from a truncated nested message is reported by the outer
+	// Decoder
+	b[1]--
+	b = b[:len(b)-1]
+
+	d := NewDecoder(b)
+	for d.Next() {
+		m := d.Message()
+		for m.Next() {
+		}
+	}
+
+	if d.Err() == nil {
+		t.Fatalf("expected an error")
+	}
+}
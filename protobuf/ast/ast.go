@@ -46,19 +46,29 @@ type FileSet struct {
 // File represents a single proto file.
 type File struct {
 	Name    string // filename
-	Syntax  string // "proto2" or "proto3"
+	Syntax  string // "proto2", "proto3" or "editions"
+	Edition string // e.g. "2023"; only set if Syntax is "editions"
 	Package []string
-	Options [][2]string // slice of key/value pairs
+	Options []*Option
 
-	Imports       []string
-	PublicImports []int // list of indexes in the Imports slice
+	SyntaxPosition  Position // position of the "syntax" or "edition" token
+	SyntaxComments  Comments
+	PackagePosition Position // position of the "package" token
+	PackageComments Comments
+
+	Imports         []string
+	ImportPositions []Position // positions of the file names in Imports
+	ImportComments  []Comments // comments of the import statements in Imports
+	PublicImports   []int      // list of indexes in the Imports slice
+	WeakImports     []int      // list of indexes in the Imports slice
 
 	Messages   []*Message   // top-level messages
 	Enums      []*Enum      // top-level enums
 	Services   []*Service   // services
 	Extensions []*Extension // top-level extensions
 
-	Comments []*Comment // all the comments for this file, sorted by position
+	Comments    []*Comment // all the comments for this file, sorted by position
+	EndComments Comments   // comments after the last statement
 }
 
 var _ FileOrNode = &File{}
@@ -76,8 +86,9 @@ func (f *File) Nodes() []Node {
 	for _, v := range f.Services {
 		nodes = append(nodes, v)
 	}
-
-	// TODO support for Extensions? Probably not, it's proto2
+	for _, v := range f.Extensions {
+		nodes = append(nodes, v)
+	}
 
 	sort.Stable(NodeSort(nodes))
 
@@ -96,13 +107,23 @@ type Message struct {
 	Extensions     []*Extension
 	Oneofs         []*Oneof
 	ReservedFields []Reserved
-	Options        [][2]string
+	Options        []*Option
 
 	Messages []*Message // includes groups
 	Enums    []*Enum
 
 	ExtensionRanges [][2]int // extension ranges (inclusive at both ends)
 
+	// ReservedStatements and ExtensionRangeStatements are the statements
+	// from which ReservedFields and ExtensionRanges are read.
+	ReservedStatements       []*ReservedStatement
+	ExtensionRangeStatements []*ExtensionRangeStatement
+
+	// Comments are the comments of the message statement; for a group they
+	// are instead those of its Field. EndComments are the comments before
+	// the closing brace.
+	Comments, EndComments Comments
+
 	Up FileOrMessage // either *File or *Message
 }
 
@@ -116,11 +137,32 @@ func (m *Message) implFileOrMessage()      {}
 func (m *Message) implMessageOrExtension() {}
 func (m *Message) implMessageOrField()     {}
 
+// Reserved is a reserved name, or a range of reserved field or enum value
+// numbers (inclusive at both ends).
 type Reserved struct {
 	Name       string
 	Start, End int
 }
 
+// ReservedStatement is a reserved statement, e.g.
+//
+//	reserved 2, 15, 9 to 11;
+type ReservedStatement struct {
+	Position Position // position of the "reserved" token
+	Reserved []Reserved
+	Comments Comments
+}
+
+// ExtensionRangeStatement is an extensions statement, e.g.
+//
+//	extensions 100 to 199 [verification = UNVERIFIED];
+type ExtensionRangeStatement struct {
+	Position Position  // position of the "extensions" token
+	Ranges   [][2]int  // inclusive at both ends
+	Options  []*Option // options of the ranges
+	Comments Comments
+}
+
 // Nodes returns a slice of the Nodes contained within this message definition
 // i.e. all the fields, enums etc, sorted by their Position.Offset
 func (m *Message) Nodes() []Node {
@@ -129,8 +171,9 @@ func (m *Message) Nodes() []Node {
 	for _, v := range m.Fields {
 		nodes = append(nodes, v)
 	}
-
-	// TODO support for Extensions? Probably not, it's proto2
+	for _, v := range m.Extensions {
+		nodes = append(nodes, v)
+	}
 
 	for _, v := range m.Oneofs {
 		nodes = append(nodes, v)
@@ -168,6 +211,9 @@ func (m *Message) File() *File {
 type Oneof struct {
 	Position Position // position of "oneof" token
 	Name     string
+	Options  []*Option
+
+	Comments, EndComments Comments
 
 	Up *Message
 }
@@ -185,18 +231,22 @@ func (o *Oneof) File() *File {
 type Field struct {
 	Position Position // position of "required"/"optional"/"repeated"/type
 
-	// TypeName is the raw name parsed from the input.
+	// TypeName is the raw name parsed from the input, at TypeNamePosition.
 	// Type is set during resolution; it will be a FieldType, *Message or *Enum.
-	TypeName string
-	Type     interface{}
+	TypeName         string
+	TypeNamePosition Position
+	Type             interface{}
 
 	// For a map field, the TypeName/Type fields are the value type,
-	// and KeyTypeName/KeyType will be set.
-	KeyTypeName string
-	KeyType     FieldType
+	// and KeyTypeName/KeyTypeNamePosition/KeyType will be set.
+	KeyTypeName         string
+	KeyTypeNamePosition Position
+	KeyType             FieldType
 
-	// At most one of {required,repeated} is set.
+	// At most one of {required,optional,repeated} is set. Optional is
+	// only set for an explicit optional label.
 	Required bool
+	Optional bool
 	Repeated bool
 	Name     string
 	Tag      int
@@ -210,7 +260,15 @@ type Field struct {
 	HasDeprecated bool
 	Deprecated    bool
 
-	Options [][2]string // slice of key/value pairs
+	Options []*Option // options other than default, packed and deprecated
+
+	// DefaultComments, PackedComments and DeprecatedComments are the comments
+	// of the default, packed and deprecated options of the field, if set.
+	DefaultComments    *Comments
+	PackedComments     *Comments
+	DeprecatedComments *Comments
+
+	Comments Comments
 
 	Oneof *Oneof
 
@@ -289,6 +347,13 @@ type Enum struct {
 	Position Position // position of "enum" token
 	Name     string
 	Values   []*EnumValue
+	Reserved []Reserved
+	Options  []*Option
+
+	// ReservedStatements are the statements from which Reserved is read.
+	ReservedStatements []*ReservedStatement
+
+	Comments, EndComments Comments
 
 	Up FileOrMessage // either *File or *Message
 }
@@ -315,6 +380,8 @@ type EnumValue struct {
 	Position Position // position of Name
 	Name     string
 	Number   int32
+	Options  []*Option
+	Comments Comments
 
 	Up *Enum
 }
@@ -330,9 +397,12 @@ func (ev *EnumValue) File() *File   { return ev.Up.File() }
 type Service struct {
 	Position Position // position of the "service" token
 	Name     string
+	Options  []*Option
 
 	Methods []*Method
 
+	Comments, EndComments Comments
+
 	Up *File
 }
 
@@ -348,13 +418,20 @@ type Method struct {
 	Position Position // position of the "rpc" token
 	Name     string
 
-	// InTypeName/OutTypeName are the raw names parsed from the input.
+	// InTypeName/OutTypeName are the raw names parsed from the input, at
+	// InTypeNamePosition/OutTypeNamePosition.
 	// InType/OutType is set during resolution; it will be a *Message.
-	InTypeName, OutTypeName string
-	InType, OutType         interface{}
+	InTypeName, OutTypeName                 string
+	InTypeNamePosition, OutTypeNamePosition Position
+	InType, OutType                         interface{}
+
+	ClientStreaming, ServerStreaming bool
 
-	// TODO: support streaming methods
-	Options [][2]string // slice of key/value pairs
+	Options []*Option
+
+	// EndComments are the comments before the closing brace of the options
+	// of the method, if they are in braces.
+	Comments, EndComments Comments
 
 	Up *Service
 }
@@ -370,11 +447,14 @@ func (m *Method) File() *File   { return m.Up.Up }
 type Extension struct {
 	Position Position // position of the "extend" token
 
-	Extendee     string   // the thing being extended
-	ExtendeeType *Message // set during resolution
+	Extendee         string   // the thing being extended
+	ExtendeePosition Position // position of Extendee
+	ExtendeeType     *Message // set during resolution
 
 	Fields []*Field
 
+	Comments, EndComments Comments
+
 	Up FileOrMessage // either *File or *Message or ...
 }
 
@@ -397,10 +477,48 @@ func (e *Extension) File() *File {
 	panic("unreachable")
 }
 
-// Comment represents a comment.
+// Comment represents a group of comments, either on consecutive lines or
+// the same line, with no other tokens between them.
 type Comment struct {
 	Start, End Position // position of first and last "//"
 	Text       []string
+
+	// Source are the comments as they appear in the source, e.g. "// foo"
+	// or "/* foo */".
+	Source []string
+}
+
+// Comments are the comments attached to a statement, e.g. a field, an option
+// or a message, and the blank lines around it. The EndComments of a block,
+// e.g. a message, are those after its last statement.
+type Comments struct {
+	// Blank is set if a blank line precedes the statement, or its first
+	// comment.
+	Blank bool
+
+	// Detached are the comment groups before the statement that are
+	// separated from it by a blank line.
+	Detached []*Comment
+
+	// Leading is the comment group immediately before the statement.
+	Leading *Comment
+
+	// Trailing is the comment group on the line on which the statement
+	// ends, after it.
+	Trailing *Comment
+
+	// Open is, for a block, the comment group on the line of its opening
+	// brace, after it.
+	Open *Comment
+
+	// Inner are the comment groups within the statement, other than those
+	// of the statements in its block or the options in its option list.
+	Inner []*Comment
+}
+
+// IsEmpty reports whether there are no comments in c.
+func (c *Comments) IsEmpty() bool {
+	return len(c.Detached) == 0 && c.Leading == nil && c.Trailing == nil && c.Open == nil && len(c.Inner) == 0
 }
 
 func (c *Comment) implFileOrNode() {}
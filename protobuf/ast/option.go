@@ -0,0 +1,130 @@
+// Copyright (c) 2016 Paul Jolly <paul@myitcv.org.uk>, all rights reserved.
+// Use of this document is governed by a license found in the LICENSE document.
+
+package ast
+
+import (
+	"strings"
+)
+
+// Option represents an option, e.g. the option in
+//
+//	option (foo).bar = { a: 1 b: "x" };
+type Option struct {
+	Position Position // position of the "option" token, or of Name in a list
+	Name     []OptionNamePart
+	Value    *OptionValue
+
+	// Comments are the comments of an option statement, or of an option in
+	// an option list.
+	Comments Comments
+}
+
+// OptionNamePart is a part of the dot-separated name of an option. The name
+// (foo.bar).baz has the parts "foo.bar", an extension, and "baz".
+type OptionNamePart struct {
+	Name        string
+	IsExtension bool
+}
+
+// NameString returns the name of the option as it appears in a proto file,
+// e.g. (foo.bar).baz
+func (o *Option) NameString() string {
+	var parts []string
+	for _, p := range o.Name {
+		if p.IsExtension {
+			parts = append(parts, "("+p.Name+")")
+		} else {
+			parts = append(parts, p.Name)
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+func (o *Option) String() string {
+	return o.NameString() + " = " + o.Value.String()
+}
+
+type OptionValueKind int8
+
+const (
+	// IdentifierValue is an identifier, e.g. true or CODE_SIZE
+	IdentifierValue OptionValueKind = iota + 1
+
+	// IntValue is an integer, e.g. 1, -2 or 0x7F
+	IntValue
+
+	// FloatValue is a floating point number, e.g. 1.5, 1e10 or -inf
+	FloatValue
+
+	// StringValue is a string, made up of one or more adjacent string
+	// literals, e.g. "foo" 'bar'
+	StringValue
+
+	// AggregateValue is a message literal, e.g. { a: 1 b: "x" }
+	AggregateValue
+
+	// ListValue is a list of values, e.g. [1, 2], which can only appear as
+	// the value of a field in an AggregateValue
+	ListValue
+)
+
+// OptionValue is the value of an option.
+type OptionValue struct {
+	Kind OptionValueKind
+
+	// Source is the source text of an IdentifierValue, IntValue, FloatValue
+	// or StringValue; in the last case this includes quotes, and adjacent
+	// string literals are separated by a single space.
+	Source string
+
+	// Unquoted is the value of a StringValue, the concatenation of its
+	// string literals.
+	Unquoted string
+
+	Fields []*AggregateField // the fields of an AggregateValue
+	Values []*OptionValue    // the values of a ListValue
+}
+
+// AggregateField is a field in an AggregateValue.
+type AggregateField struct {
+	// Name is the name of the field, or, in square brackets, the name of an
+	// extension or the type URL of an Any, e.g. [foo.bar]
+	Name  string
+	Value *OptionValue
+}
+
+// String returns the text format representation of the value. For an
+// AggregateValue this is of the form { a: 1 b { c: "x" } }, with a colon
+// after the name of every field the value of which is not an AggregateValue.
+func (v *OptionValue) String() string {
+	switch v.Kind {
+	case AggregateValue:
+		if len(v.Fields) == 0 {
+			return "{}"
+		}
+		return "{ " + v.AggregateString() + " }"
+	case ListValue:
+		var vals []string
+		for _, e := range v.Values {
+			vals = append(vals, e.String())
+		}
+		return "[" + strings.Join(vals, ", ") + "]"
+	default:
+		return v.Source
+	}
+}
+
+// AggregateString returns the text format representation of the fields of
+// an AggregateValue, without the enclosing braces.
+func (v *OptionValue) AggregateString() string {
+	var fields []string
+	for _, f := range v.Fields {
+		if f.Value.Kind == AggregateValue {
+			fields = append(fields, f.Name+" "+f.Value.String())
+		} else {
+			fields = append(fields, f.Name+": "+f.Value.String())
+		}
+	}
+	return strings.Join(fields, " ")
+}
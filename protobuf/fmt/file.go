@@ -4,174 +4,476 @@
 package fmt
 
 import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
 	"strings"
 
 	"myitcv.io/protobuf/ast"
 )
 
-// TODO
-//
-// We lose spacing (or no spacing) between fields in a message; it should be at most 1 space
-// not an enforced 1 space;
+// maxFieldNumber is the largest field number, that of max in a range
+const maxFieldNumber = 1<<29 - 1
 
+// A stmt is a statement to be formatted, with its comments.
+type stmt struct {
+	pos      ast.Position
+	comments *ast.Comments
+
+	// fmt prints the statement, without a final newline
+	fmt func()
+}
+
+// FmtFile formats file. The syntax, package, options and imports of the file
+// come first, in that order, followed by the other statements of the file in
+// their original order. The comments of each statement move with it, and
+// single blank lines between statements are kept.
 func (f *Formatter) FmtFile(file *ast.File) {
-	f.fmtSyntax(file.Syntax)
-	f.fmtPackage(file.Package)
-	f.fmtOptions(file.Options)
-	f.fmtImports(file.Imports)
+	f.syntax = file.Syntax
+
+	var syntax, pkg, imports []stmt
+	if file.Syntax != "" {
+		syntax = append(syntax, stmt{file.SyntaxPosition, &file.SyntaxComments, func() {
+			if file.Syntax == "editions" {
+				f.printf("edition = \"%v\"", file.Edition)
+			} else {
+				f.printf("syntax = \"%v\"", file.Syntax)
+			}
+			f.fmtTerm(";")
+		}})
+	}
+	if len(file.Package) > 0 {
+		pkg = append(pkg, stmt{file.PackagePosition, &file.PackageComments, func() {
+			f.printf("package %v", strings.Join(file.Package, "."))
+			f.fmtTerm(";")
+		}})
+	}
+
+	kinds := make(map[int]string)
+	for _, i := range file.PublicImports {
+		kinds[i] = "public "
+	}
+	for _, i := range file.WeakImports {
+		kinds[i] = "weak "
+	}
+	for i, imp := range file.Imports {
+		i, imp := i, imp
+		s := stmt{fmt: func() {
+			f.printf("import %v\"%v\"", kinds[i], imp)
+			f.fmtTerm(";")
+		}}
+		if i < len(file.ImportPositions) {
+			s.pos = file.ImportPositions[i]
+		}
+		if i < len(file.ImportComments) {
+			s.comments = &file.ImportComments[i]
+		}
+		imports = append(imports, s)
+	}
+
+	for _, h := range [][]stmt{syntax, pkg, f.optionStmts(file.Options), imports} {
+		if len(h) > 0 {
+			f.fmtStmts(h)
+			f.println()
+		}
+	}
 
+	var body []stmt
 	for _, n := range file.Nodes() {
 		switch n := n.(type) {
 		case *ast.Message:
-			f.fmtMessage(n)
+			body = append(body, f.messageStmt(n))
 		case *ast.Enum:
-			f.fmtEnum(n)
+			body = append(body, f.enumStmt(n))
 		case *ast.Service:
-			f.fmtService(n)
+			body = append(body, f.serviceStmt(n))
+		case *ast.Extension:
+			body = append(body, f.extensionStmt(n))
 		}
 	}
+	f.fmtStmts(body)
+	f.fmtEndComments(&file.EndComments, len(body) > 0)
 }
 
-func (f *Formatter) fmtSyntax(syntax string) {
-	f.printf("syntax = \"%v\";\n", syntax)
-	f.println()
+// fmtStmts formats stmts in the order of their positions. A blank line
+// before a statement is kept, unless it is the first.
+func (f *Formatter) fmtStmts(stmts []stmt) {
+	sort.SliceStable(stmts, func(i, j int) bool {
+		return stmts[i].pos.Offset < stmts[j].pos.Offset
+	})
+	for i, s := range stmts {
+		f.fmtStmt(s, i == 0)
+	}
 }
 
-func (f *Formatter) fmtPackage(pkg []string) {
-	f.printf("package %v;\n", strings.Join(pkg, "."))
-
-	if len(pkg) > 0 {
+// fmtStmt formats s, with its comments, on a line of its own; first is set
+// if s is the first statement of its block.
+func (f *Formatter) fmtStmt(s stmt, first bool) {
+	c := s.comments
+	if c == nil {
+		c = new(ast.Comments)
+	}
+	if c.Blank && !first {
 		f.println()
 	}
-}
+	for _, g := range c.Detached {
+		f.fmtComment(g)
+		f.println()
+	}
+	if c.Leading != nil {
+		f.fmtComment(c.Leading)
+	}
 
-func (f *Formatter) fmtOptions(options [][2]string) {
-	for _, o := range options {
-		f.printf("option %v = %v;\n", o[0], o[1])
+	// comments within a statement can't be kept in place; /* */ comments
+	// are kept on its line, before the token that ends it or opens its
+	// block, the others are moved before it
+	outer := f.inline
+	f.inline = nil
+	for _, g := range c.Inner {
+		if isInline(g) {
+			f.inline = append(f.inline, g)
+		} else {
+			f.fmtComment(g)
+		}
 	}
+	s.fmt()
+	f.inline = outer
 
-	if len(options) > 0 {
-		f.println()
+	if c.Trailing != nil {
+		f.noIndentPrintf(" %v", strings.Join(c.Trailing.Source, " "))
 	}
+	f.noIndentPrintf("\n")
 }
 
-func (f *Formatter) fmtImports(imports []string) {
-	for _, i := range imports {
-		f.printf("import \"%v\";\n", i)
+// isInline reports whether the comment group g is made up of /* */ comments,
+// each on a single line, such that it can be kept within a line.
+func isInline(g *ast.Comment) bool {
+	for _, s := range g.Source {
+		if !strings.HasPrefix(s, "/*") || strings.Contains(s, "\n") {
+			return false
+		}
 	}
+	return true
+}
 
-	if len(imports) > 0 {
-		f.println()
+// fmtTerm prints term, the token that ends the statement being formatted or
+// opens its block, preceded by the /* */ comments within the statement.
+func (f *Formatter) fmtTerm(term string) {
+	for _, g := range f.inline {
+		f.noIndentPrintf(" %v", strings.Join(g.Source, " "))
 	}
+	f.inline = nil
+	f.noIndentPrintf("%v", term)
 }
 
-func (f *Formatter) fmtService(svc *ast.Service) {
-	f.printf("service %v {\n", svc.Name)
-	f.indent++
-
-	for _, m := range svc.Methods {
-		f.fmtMethod(m)
+// fmtEndComments formats the end comments of a block, the statements of
+// which have been formatted; nonEmpty is set if there are any.
+func (f *Formatter) fmtEndComments(c *ast.Comments, nonEmpty bool) {
+	groups := c.Detached
+	if c.Leading != nil {
+		groups = append(groups[:len(groups):len(groups)], c.Leading)
 	}
+	for i, g := range groups {
+		if i > 0 || c.Blank && nonEmpty {
+			f.println()
+		}
+		f.fmtComment(g)
+	}
+}
 
+// fmtBlock formats a block, the opening line of which has been printed up
+// to its opening brace: the brace, followed by the comment group open, if
+// any, the statements and end comments of the block, and its closing brace.
+func (f *Formatter) fmtBlock(open *ast.Comment, stmts []stmt, end *ast.Comments) {
+	f.fmtTerm(" {")
+	if open != nil {
+		f.noIndentPrintf(" %v", strings.Join(open.Source, " "))
+	}
+	f.noIndentPrintf("\n")
+	f.indent++
+	f.fmtStmts(stmts)
+	f.fmtEndComments(end, len(stmts) > 0)
 	f.indent--
-	f.println("}")
+	f.printf("}")
 }
 
-func (f *Formatter) fmtMethod(meth *ast.Method) {
-	f.printf("rpc %v (%v) returns (%v)", meth.Name, meth.InTypeName, meth.OutTypeName)
-	if len(meth.Options) > 0 {
-		f.noIndentPrintf(" {\n")
-		f.indent++
+// fmtComment formats the comment group c, each comment on its own line.
+func (f *Formatter) fmtComment(c *ast.Comment) {
+	for _, s := range c.Source {
+		f.printf("%v\n", s)
+	}
+}
 
-		for _, o := range meth.Options {
-			f.printf("option (%v) = %v;\n", o[0], o[1])
+func (f *Formatter) optionStmts(options []*ast.Option) []stmt {
+	var stmts []stmt
+	for _, o := range options {
+		o := o
+		stmts = append(stmts, stmt{o.Position, &o.Comments, func() {
+			f.printf("option %v", o)
+			f.fmtTerm(";")
+		}})
+	}
+	return stmts
+}
+
+func (f *Formatter) extensionStmt(ext *ast.Extension) stmt {
+	return stmt{ext.Position, &ext.Comments, func() {
+		f.printf("extend %v", ext.Extendee)
+		var stmts []stmt
+		for _, fld := range ext.Fields {
+			stmts = append(stmts, f.fieldStmt(fld))
 		}
+		f.fmtBlock(ext.Comments.Open, stmts, &ext.EndComments)
+	}}
+}
 
-		f.indent--
-		f.println("}")
-	} else {
-		f.noIndentPrintf(";\n")
-	}
+func (f *Formatter) serviceStmt(svc *ast.Service) stmt {
+	return stmt{svc.Position, &svc.Comments, func() {
+		f.printf("service %v", svc.Name)
+		stmts := f.optionStmts(svc.Options)
+		for _, m := range svc.Methods {
+			stmts = append(stmts, f.methodStmt(m))
+		}
+		f.fmtBlock(svc.Comments.Open, stmts, &svc.EndComments)
+	}}
 }
 
-func (f *Formatter) fmtMessage(message *ast.Message) {
-	f.printf("message %v {\n", message.Name)
-	f.indent++
+func (f *Formatter) methodStmt(meth *ast.Method) stmt {
+	return stmt{meth.Position, &meth.Comments, func() {
+		in, out := meth.InTypeName, meth.OutTypeName
+		if meth.ClientStreaming {
+			in = "stream " + in
+		}
+		if meth.ServerStreaming {
+			out = "stream " + out
+		}
+		f.printf("rpc %v (%v) returns (%v)", meth.Name, in, out)
+		if len(meth.Options) > 0 || !meth.EndComments.IsEmpty() || meth.Comments.Open != nil {
+			f.fmtBlock(meth.Comments.Open, f.optionStmts(meth.Options), &meth.EndComments)
+		} else {
+			f.fmtTerm(";")
+		}
+	}}
+}
 
-	for _, o := range message.Options {
-		f.printf("option (%v) = %v;\n", o[0], o[1])
+func (f *Formatter) messageStmt(message *ast.Message) stmt {
+	return stmt{message.Position, &message.Comments, func() {
+		f.printf("message %v", message.Name)
+		f.fmtBlock(message.Comments.Open, f.messageStmts(message), &message.EndComments)
+	}}
+}
 
+// messageStmts returns the statements in the body of message, or of a
+// group.
+func (f *Formatter) messageStmts(message *ast.Message) []stmt {
+	stmts := f.optionStmts(message.Options)
+	for _, fld := range message.Fields {
+		if fld.Oneof == nil {
+			stmts = append(stmts, f.fieldStmt(fld))
+		}
 	}
-
-	for _, n := range message.Nodes() {
-		switch n := n.(type) {
-		case *ast.Message:
-			f.fmtMessage(n)
-		case *ast.Enum:
-			f.fmtEnum(n)
-		case *ast.Field:
-			f.fmtField(n)
+	for _, o := range message.Oneofs {
+		stmts = append(stmts, f.oneofStmt(message, o))
+	}
+	for _, m := range message.Messages {
+		// a group is formatted with its field
+		if !m.Group {
+			stmts = append(stmts, f.messageStmt(m))
 		}
 	}
-
-	// TODO: hack; if a one-of field was the last field in a message
-	// we need to close out the one-of group
-	if f.oneOf != nil {
-		f.oneOf = nil
-		f.println("}")
+	for _, e := range message.Enums {
+		stmts = append(stmts, f.enumStmt(e))
+	}
+	for _, ext := range message.Extensions {
+		stmts = append(stmts, f.extensionStmt(ext))
 	}
+	for _, r := range message.ReservedStatements {
+		stmts = append(stmts, f.reservedStmt(r, maxFieldNumber))
+	}
+	for _, r := range message.ExtensionRangeStatements {
+		stmts = append(stmts, f.extensionRangeStmt(r))
+	}
+	return stmts
+}
 
-	f.indent--
-	f.println("}")
+func (f *Formatter) oneofStmt(message *ast.Message, oneof *ast.Oneof) stmt {
+	return stmt{oneof.Position, &oneof.Comments, func() {
+		f.printf("oneof %v", oneof.Name)
+		stmts := f.optionStmts(oneof.Options)
+		for _, fld := range message.Fields {
+			if fld.Oneof == oneof {
+				stmts = append(stmts, f.fieldStmt(fld))
+			}
+		}
+		f.fmtBlock(oneof.Comments.Open, stmts, &oneof.EndComments)
+	}}
 }
 
-func (f *Formatter) fmtEnum(enum *ast.Enum) {
-	f.printf("enum %v {\n", enum.Name)
-	f.indent++
+func (f *Formatter) reservedStmt(r *ast.ReservedStatement, max int) stmt {
+	return stmt{r.Position, &r.Comments, func() {
+		var vals []string
+		for _, v := range r.Reserved {
+			switch {
+			case v.Name != "" && f.syntax == "editions":
+				vals = append(vals, v.Name)
+			case v.Name != "":
+				vals = append(vals, strconv.Quote(v.Name))
+			default:
+				vals = append(vals, rangeString(v.Start, v.End, max))
+			}
+		}
+		f.printf("reserved %v", strings.Join(vals, ", "))
+		f.fmtTerm(";")
+	}}
+}
+
+func (f *Formatter) extensionRangeStmt(r *ast.ExtensionRangeStatement) stmt {
+	return stmt{r.Position, &r.Comments, func() {
+		var vals []string
+		for _, v := range r.Ranges {
+			vals = append(vals, rangeString(v[0], v[1], maxFieldNumber))
+		}
+		f.printf("extensions %v", strings.Join(vals, ", "))
+		f.fmtOptionList(listOptions(r.Options))
+		f.fmtTerm(";")
+	}}
+}
 
-	for _, v := range enum.Values {
-		f.printf("%v = %v;\n", v.Name, v.Number)
+// rangeString returns the range from start to end (inclusive) as it appears
+// in a reserved or extensions statement; max is the largest number allowed.
+func rangeString(start, end, max int) string {
+	switch end {
+	case start:
+		return strconv.Itoa(start)
+	case max:
+		return fmt.Sprintf("%v to max", start)
 	}
+	return fmt.Sprintf("%v to %v", start, end)
+}
 
-	f.indent--
-	f.println("}")
+func (f *Formatter) enumStmt(enum *ast.Enum) stmt {
+	return stmt{enum.Position, &enum.Comments, func() {
+		f.printf("enum %v", enum.Name)
+		stmts := f.optionStmts(enum.Options)
+		for _, v := range enum.Values {
+			v := v
+			stmts = append(stmts, stmt{v.Position, &v.Comments, func() {
+				f.printf("%v = %v", v.Name, v.Number)
+				f.fmtOptionList(listOptions(v.Options))
+				f.fmtTerm(";")
+			}})
+		}
+		for _, r := range enum.ReservedStatements {
+			stmts = append(stmts, f.reservedStmt(r, math.MaxInt32))
+		}
+		f.fmtBlock(enum.Comments.Open, stmts, &enum.EndComments)
+	}}
 }
 
-func (f *Formatter) fmtField(field *ast.Field) {
-	if field.Oneof != nil && f.oneOf == nil {
-		f.oneOf = field.Oneof
-		f.printf("oneof %v {\n", field.Oneof.Name)
-	} else if field.Oneof == nil && f.oneOf != nil {
-		f.oneOf = nil
-		f.println("}")
-	}
+func (f *Formatter) fieldStmt(field *ast.Field) stmt {
+	return stmt{field.Position, &field.Comments, func() {
+		var label string
+		switch {
+		case field.KeyTypeName != "":
+			// the label of a map field is implied
+		case field.Repeated:
+			label = "repeated "
+		case field.Required:
+			label = "required "
+		case field.Optional:
+			label = "optional "
+		}
 
-	if field.Oneof != nil {
-		f.indent++
-	}
+		if group := groupOf(field); group != nil {
+			f.printf("%vgroup %v = %v", label, field.Name, field.Tag)
+			f.fmtBlock(field.Comments.Open, f.messageStmts(group), &group.EndComments)
+			return
+		}
 
-	if field.KeyTypeName != "" {
-		f.printf("map<%v, %v> %v = %v", field.KeyTypeName, field.TypeName, field.Name, field.Tag)
-	} else if field.Repeated {
-		f.printf("repeated %v %v = %v", field.TypeName, field.Name, field.Tag)
-	} else {
-		f.printf("%v %v = %v", field.TypeName, field.Name, field.Tag)
-	}
+		if field.KeyTypeName != "" {
+			f.printf("map<%v, %v> %v = %v", field.KeyTypeName, field.TypeName, field.Name, field.Tag)
+		} else {
+			f.printf("%v%v %v = %v", label, field.TypeName, field.Name, field.Tag)
+		}
 
-	if len(field.Options) > 0 {
-		f.noIndentPrintf(" [")
-		for i, o := range field.Options {
-			if i > 0 {
-				f.noIndentPrintf(", ")
+		var opts []listOption
+		if field.HasDefault {
+			def := field.Default
+			if field.TypeName == "string" {
+				def = strconv.Quote(def)
 			}
-			f.noIndentPrintf("(%v)=%v", o[0], o[1])
+			opts = append(opts, listOption{"default=" + def, field.DefaultComments})
+		}
+		if field.HasPacked {
+			opts = append(opts, listOption{fmt.Sprintf("packed=%v", field.Packed), field.PackedComments})
+		}
+		if field.HasDeprecated {
+			opts = append(opts, listOption{fmt.Sprintf("deprecated=%v", field.Deprecated), field.DeprecatedComments})
+		}
+		f.fmtOptionList(append(opts, listOptions(field.Options)...))
+		f.fmtTerm(";")
+	}}
+}
+
+// groupOf returns the group message of field, or nil if it is not a group.
+func groupOf(field *ast.Field) *ast.Message {
+	msg, ok := field.Up.(*ast.Message)
+	if !ok {
+		return nil
+	}
+	for _, m := range msg.Messages {
+		if m.Group && m.Name == field.Name {
+			return m
 		}
-		f.noIndentPrintf("];\n")
-	} else {
-		f.noIndentPrintf(";\n")
 	}
+	return nil
+}
 
-	if field.Oneof != nil {
-		f.indent--
+// A listOption is an option in an option list, with its comments.
+type listOption struct {
+	opt      string
+	comments *ast.Comments
+}
+
+func listOptions(options []*ast.Option) []listOption {
+	var opts []listOption
+	for _, o := range options {
+		opts = append(opts, listOption{fmt.Sprintf("%v=%v", o.NameString(), o.Value), &o.Comments})
+	}
+	return opts
+}
+
+// fmtOptionList formats the list of options opts, if there are any, in
+// square brackets. If any of the options has comments, each option is
+// formatted with its comments on a line of its own.
+func (f *Formatter) fmtOptionList(opts []listOption) {
+	if len(opts) == 0 {
+		return
+	}
+	var strs []string
+	comments := false
+	for _, o := range opts {
+		strs = append(strs, o.opt)
+		comments = comments || o.comments != nil && !o.comments.IsEmpty()
 	}
+	if !comments {
+		f.noIndentPrintf(" [%v]", strings.Join(strs, ", "))
+		return
+	}
+
+	f.noIndentPrintf(" [\n")
+	f.indent++
+	for i, o := range opts {
+		o, sep := o, ","
+		if i == len(opts)-1 {
+			sep = ""
+		}
+		f.fmtStmt(stmt{comments: o.comments, fmt: func() {
+			f.printf("%v", o.opt)
+			f.fmtTerm(sep)
+		}}, i == 0)
+	}
+	f.indent--
+	f.printf("]")
 }
@@ -0,0 +1,61 @@
+// Copyright (c) 2016 Paul Jolly <paul@myitcv.org.uk>, all rights reserved.
+// Use of this document is governed by a license found in the LICENSE document.
+
+package fmt
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+var fUpdate = flag.Bool("update", false, "update the golden files in testdata")
+
+// TestGolden formats each testdata/*.proto file, comparing the result with
+// the corresponding .golden file, and checks that formatting the result
+// again doesn't change it.
+func TestGolden(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*.proto"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no testdata files")
+	}
+	for _, fn := range files {
+		src, err := ioutil.ReadFile(fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := Source(fn, src)
+		if err != nil {
+			t.Errorf("formatting %v: %v", fn, err)
+			continue
+		}
+
+		golden := fn[:len(fn)-len(".proto")] + ".golden"
+		if *fUpdate {
+			if err := ioutil.WriteFile(golden, got, 0666); err != nil {
+				t.Fatal(err)
+			}
+		}
+		want, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("formatting %v: got:\n%s\nwant:\n%s", fn, got, want)
+			continue
+		}
+
+		again, err := Source(golden, got)
+		if err != nil {
+			t.Errorf("formatting %v: %v", golden, err)
+			continue
+		}
+		if string(again) != string(got) {
+			t.Errorf("formatting %v is not idempotent: got:\n%s\nwant:\n%s", golden, again, got)
+		}
+	}
+}
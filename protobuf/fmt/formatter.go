@@ -4,6 +4,7 @@
 package fmt
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"strings"
@@ -15,10 +16,12 @@ import (
 type Formatter struct {
 	Output io.Writer
 
-	// TODO this is a bit gross - we can only be in one oneOf at any
-	// point in time... seems hacky to store the state here (for indenting)
 	indent int
-	oneOf  *ast.Oneof
+	syntax string // of the file being formatted
+
+	// inline are the /* */ comments within the statement being formatted,
+	// printed by fmtTerm
+	inline []*ast.Comment
 }
 
 func (f *Formatter) Fmt(files []string, importPaths []string) {
@@ -43,8 +46,27 @@ func (f *Formatter) Fmt(files []string, importPaths []string) {
 	}
 }
 
+// Source formats src, the source of the proto file filename, returning the
+// formatted source. Unlike Fmt it is purely syntactic: imports are not read
+// and type names are not resolved.
+func Source(filename string, src []byte) ([]byte, error) {
+	file, err := parser.ParseFile(filename, src, 0)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	f := &Formatter{
+		Output: &buf,
+	}
+	f.FmtFile(file)
+	return buf.Bytes(), nil
+}
+
 func (f *Formatter) println(a ...interface{}) {
-	fmt.Fprintf(f.Output, strings.Repeat("\t", f.indent))
+	if len(a) > 0 {
+		// a blank line is not indented
+		fmt.Fprintf(f.Output, strings.Repeat("\t", f.indent))
+	}
 	fmt.Fprintln(f.Output, a...)
 }
 
@@ -8,6 +8,7 @@ package gendesc
 
 import (
 	"fmt"
+	"math"
 	"sort"
 	"strconv"
 	"strings"
@@ -41,6 +42,10 @@ func genFile(f *ast.File) (*pb.FileDescriptorProto, error) {
 		fdp.PublicDependency = append(fdp.PublicDependency, int32(i))
 	}
 	sort.Sort(int32Slice(fdp.PublicDependency))
+	for _, i := range f.WeakImports {
+		fdp.WeakDependency = append(fdp.WeakDependency, int32(i))
+	}
+	sort.Sort(int32Slice(fdp.WeakDependency))
 	for _, m := range f.Messages {
 		dp, err := genMessage(m)
 		if err != nil {
@@ -69,37 +74,21 @@ func genFile(f *ast.File) (*pb.FileDescriptorProto, error) {
 		}
 		fdp.Extension = append(fdp.Extension, fdps...)
 	}
-	for _, opt := range f.Options {
-		if fdp.Options == nil {
-			fdp.Options = new(pb.FileOptions)
-		}
-		// TODO: interpret common options
-		uo := new(pb.UninterpretedOption)
-		for _, part := range strings.Split(opt[0], ".") {
-			// TODO: support IsExtension
-			uo.Name = append(uo.Name, &pb.UninterpretedOption_NamePart{
-				NamePart:    proto.String(part),
-				IsExtension: proto.Bool(false),
-			})
-			// TODO: need to handle more types
-			if strings.HasPrefix(opt[1], `"`) {
-				// TODO: doesn't handle single quote strings, etc.
-				unq, err := strconv.Unquote(opt[1])
-				if err != nil {
-					return nil, err
-				}
-				uo.StringValue = []byte(unq)
-			} else {
-				uo.IdentifierValue = proto.String(opt[1])
-			}
-		}
-		fdp.Options.UninterpretedOption = append(fdp.Options.UninterpretedOption, uo)
+	// TODO: interpret common options
+	uos, err := genOptions(f.Options)
+	if err != nil {
+		return nil, err
+	}
+	if uos != nil {
+		fdp.Options = &pb.FileOptions{UninterpretedOption: uos}
 	}
 	// TODO: SourceCodeInfo
 	switch f.Syntax {
 	case "proto2", "":
 		// "proto2" is considered the default; don't set anything.
 	default:
+		// TODO: set the edition of an editions file once the descriptor
+		// proto we use has the field
 		fdp.Syntax = proto.String(f.Syntax)
 	}
 
@@ -111,6 +100,12 @@ func genMessage(m *ast.Message) (*pb.DescriptorProto, error) {
 		Name: proto.String(m.Name),
 	}
 	for _, f := range m.Fields {
+		// A proto3 optional field belongs to a synthetic oneof of its own,
+		// which the descriptor proto we use cannot mark as such: it would be
+		// taken for a real one.
+		if f.Optional && m.File().Syntax == "proto3" {
+			return nil, fmt.Errorf("proto3 optional field %v.%v is not supported", m.Name, f.Name)
+		}
 		fdp, xdp, err := genField(f)
 		if err != nil {
 			return nil, err
@@ -148,10 +143,36 @@ func genMessage(m *ast.Message) (*pb.DescriptorProto, error) {
 			End:   proto.Int32(int32(r[1] + 1)),
 		})
 	}
+	for _, r := range m.ReservedFields {
+		if r.Name != "" {
+			dp.ReservedName = append(dp.ReservedName, r.Name)
+			continue
+		}
+		// DescriptorProto.ReservedRange uses a half-open interval.
+		dp.ReservedRange = append(dp.ReservedRange, &pb.DescriptorProto_ReservedRange{
+			Start: proto.Int32(int32(r.Start)),
+			End:   proto.Int32(int32(r.End + 1)),
+		})
+	}
 	for _, oo := range m.Oneofs {
-		dp.OneofDecl = append(dp.OneofDecl, &pb.OneofDescriptorProto{
+		odp := &pb.OneofDescriptorProto{
 			Name: proto.String(oo.Name),
-		})
+		}
+		uos, err := genOptions(oo.Options)
+		if err != nil {
+			return nil, err
+		}
+		if uos != nil {
+			odp.Options = &pb.OneofOptions{UninterpretedOption: uos}
+		}
+		dp.OneofDecl = append(dp.OneofDecl, odp)
+	}
+	uos, err := genOptions(m.Options)
+	if err != nil {
+		return nil, err
+	}
+	if uos != nil {
+		dp.Options = &pb.MessageOptions{UninterpretedOption: uos}
 	}
 	return dp, nil
 }
@@ -241,6 +262,13 @@ func genField(f *ast.Field) (*pb.FieldDescriptorProto, *pb.DescriptorProto, erro
 		}
 		fdp.OneofIndex = proto.Int(n)
 	}
+	uos, err := genOptions(f.Options)
+	if err != nil {
+		return nil, nil, err
+	}
+	if uos != nil {
+		fdp.Options = &pb.FieldOptions{UninterpretedOption: uos}
+	}
 
 	return fdp, nil, nil
 }
@@ -250,11 +278,38 @@ func genEnum(enum *ast.Enum) (*pb.EnumDescriptorProto, error) {
 		Name: proto.String(enum.Name),
 	}
 	for _, ev := range enum.Values {
-		edp.Value = append(edp.Value, &pb.EnumValueDescriptorProto{
+		evdp := &pb.EnumValueDescriptorProto{
 			Name:   proto.String(ev.Name),
 			Number: proto.Int32(ev.Number),
+		}
+		uos, err := genOptions(ev.Options)
+		if err != nil {
+			return nil, err
+		}
+		if uos != nil {
+			evdp.Options = &pb.EnumValueOptions{UninterpretedOption: uos}
+		}
+		edp.Value = append(edp.Value, evdp)
+	}
+	for _, r := range enum.Reserved {
+		if r.Name != "" {
+			edp.ReservedName = append(edp.ReservedName, r.Name)
+			continue
+		}
+		// EnumDescriptorProto.ReservedRange, unlike
+		// DescriptorProto.ReservedRange, is inclusive at both ends.
+		edp.ReservedRange = append(edp.ReservedRange, &pb.EnumDescriptorProto_EnumReservedRange{
+			Start: proto.Int32(int32(r.Start)),
+			End:   proto.Int32(int32(r.End)),
 		})
 	}
+	uos, err := genOptions(enum.Options)
+	if err != nil {
+		return nil, err
+	}
+	if uos != nil {
+		edp.Options = &pb.EnumOptions{UninterpretedOption: uos}
+	}
 	return edp, nil
 }
 
@@ -269,6 +324,13 @@ func genService(srv *ast.Service) (*pb.ServiceDescriptorProto, error) {
 		}
 		sdp.Method = append(sdp.Method, mdp)
 	}
+	uos, err := genOptions(srv.Options)
+	if err != nil {
+		return nil, err
+	}
+	if uos != nil {
+		sdp.Options = &pb.ServiceOptions{UninterpretedOption: uos}
+	}
 	return sdp, nil
 }
 
@@ -278,6 +340,19 @@ func genMethod(mth *ast.Method) (*pb.MethodDescriptorProto, error) {
 		InputType:  proto.String(qualifiedName(mth.InType)),
 		OutputType: proto.String(qualifiedName(mth.OutType)),
 	}
+	if mth.ClientStreaming {
+		mdp.ClientStreaming = proto.Bool(true)
+	}
+	if mth.ServerStreaming {
+		mdp.ServerStreaming = proto.Bool(true)
+	}
+	uos, err := genOptions(mth.Options)
+	if err != nil {
+		return nil, err
+	}
+	if uos != nil {
+		mdp.Options = &pb.MethodOptions{UninterpretedOption: uos}
+	}
 	return mdp, nil
 }
 
@@ -294,6 +369,72 @@ func genExtension(ext *ast.Extension) ([]*pb.FieldDescriptorProto, error) {
 	return fdps, nil
 }
 
+// genOptions returns the uninterpreted options corresponding to opts, or nil
+// if there are none.
+func genOptions(opts []*ast.Option) ([]*pb.UninterpretedOption, error) {
+	var uos []*pb.UninterpretedOption
+	for _, opt := range opts {
+		uo := new(pb.UninterpretedOption)
+		for _, part := range opt.Name {
+			if part.IsExtension {
+				uo.Name = append(uo.Name, &pb.UninterpretedOption_NamePart{
+					NamePart:    proto.String(part.Name),
+					IsExtension: proto.Bool(true),
+				})
+				continue
+			}
+			for _, p := range strings.Split(part.Name, ".") {
+				uo.Name = append(uo.Name, &pb.UninterpretedOption_NamePart{
+					NamePart:    proto.String(p),
+					IsExtension: proto.Bool(false),
+				})
+			}
+		}
+		v := opt.Value
+		switch v.Kind {
+		case ast.IdentifierValue:
+			uo.IdentifierValue = proto.String(v.Source)
+		case ast.IntValue:
+			if strings.HasPrefix(v.Source, "-") {
+				n, err := strconv.ParseInt(v.Source, 0, 64)
+				if err != nil {
+					return nil, fmt.Errorf("bad value for option %v: %v", opt.NameString(), err)
+				}
+				uo.NegativeIntValue = proto.Int64(n)
+			} else {
+				n, err := strconv.ParseUint(strings.TrimPrefix(v.Source, "+"), 0, 64)
+				if err != nil {
+					return nil, fmt.Errorf("bad value for option %v: %v", opt.NameString(), err)
+				}
+				uo.PositiveIntValue = proto.Uint64(n)
+			}
+		case ast.FloatValue:
+			var f float64
+			switch v.Source {
+			case "-inf":
+				f = math.Inf(-1)
+			case "-nan":
+				f = math.NaN()
+			default:
+				var err error
+				f, err = strconv.ParseFloat(v.Source, 64)
+				if err != nil {
+					return nil, fmt.Errorf("bad value for option %v: %v", opt.NameString(), err)
+				}
+			}
+			uo.DoubleValue = proto.Float64(f)
+		case ast.StringValue:
+			uo.StringValue = []byte(v.Unquoted)
+		case ast.AggregateValue:
+			uo.AggregateValue = proto.String(v.AggregateString())
+		default:
+			return nil, fmt.Errorf("internal error: bad value for option %v: %v", opt.NameString(), v)
+		}
+		uos = append(uos, uo)
+	}
+	return uos, nil
+}
+
 // qualifiedName returns the fully-qualified name of x,
 // which must be either *ast.Message or *ast.Enum.
 func qualifiedName(x interface{}) string {
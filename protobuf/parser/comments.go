@@ -0,0 +1,273 @@
+// Copyright (c) 2016 Paul Jolly <paul@myitcv.org.uk>, all rights reserved.
+// Use of this document is governed by a license found in the LICENSE document.
+
+package parser
+
+// This file implements the attachment of comments to the statements in which,
+// or around which, they appear.
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"myitcv.io/protobuf/ast"
+)
+
+// A span is a statement that has been read, to which comments are attached.
+type span struct {
+	start, end int // offsets of the first token, and just past the last
+
+	// open and close are, for a block, the offsets just past its opening
+	// brace and of its closing brace; for a statement with an option list,
+	// those of its brackets.
+	open, close int
+
+	comments    *ast.Comments
+	endComments *ast.Comments // only set for a block
+
+	children []*span // statements in the block, or options in the list, in order
+}
+
+// addSpan records the statement that starts at start and ends with the last
+// token read.
+func (p *parser) addSpan(start int, comments *ast.Comments) *span {
+	s := &span{
+		start:    start,
+		end:      p.end(),
+		comments: comments,
+	}
+	p.spans = append(p.spans, s)
+	return s
+}
+
+// addBlock is like addSpan, for a statement with a block, e.g. a message.
+func (p *parser) addBlock(start, open, close int, comments, endComments *ast.Comments) {
+	s := p.addSpan(start, comments)
+	s.open, s.close = open, close
+	s.endComments = endComments
+}
+
+// attacher attaches comments to the spans of a file.
+type attacher struct {
+	lines []int          // offsets of the start of each line
+	all   []*ast.Comment // all the comment groups made
+}
+
+// attachComments attaches p.comments to the statements recorded in p.spans,
+// and sets the Comments of f to all the comment groups.
+func (p *parser) attachComments(f *ast.File) {
+	a := &attacher{lines: []int{0}}
+	for i := 0; i < len(p.src); i++ {
+		if p.src[i] == '\n' {
+			a.lines = append(a.lines, i+1)
+		}
+	}
+
+	// Make the tree of spans; a span, a block or a statement with an option
+	// list, only contains those that start after it and end before it.
+	sort.SliceStable(p.spans, func(i, j int) bool {
+		if p.spans[i].start != p.spans[j].start {
+			return p.spans[i].start < p.spans[j].start
+		}
+		return p.spans[i].end > p.spans[j].end
+	})
+	root := &span{
+		end:         len(p.src),
+		close:       len(p.src),
+		endComments: &f.EndComments,
+	}
+	stack := []*span{root}
+	for _, s := range p.spans {
+		for len(stack) > 1 && s.start >= stack[len(stack)-1].end {
+			stack = stack[:len(stack)-1]
+		}
+		parent := stack[len(stack)-1]
+		parent.children = append(parent.children, s)
+		if s.close > 0 {
+			stack = append(stack, s)
+		}
+	}
+
+	a.block(root, p.comments, 0)
+
+	sort.SliceStable(a.all, func(i, j int) bool {
+		return a.all[i].Start.Offset < a.all[j].Start.Offset
+	})
+	f.Comments = a.all
+}
+
+// line returns the line of offset.
+func (a *attacher) line(offset int) int {
+	return sort.Search(len(a.lines), func(i int) bool {
+		return a.lines[i] > offset
+	})
+}
+
+// endLine returns the line on which c ends.
+func (a *attacher) endLine(c comment) int {
+	return a.line(c.end - 1)
+}
+
+// block attaches cs, the comments in the body of the block b, to the
+// statements in the block. lastLine is the line of the opening brace, or 0
+// for a file. b may also be a statement with an option list, in which case
+// its options are the statements and its brackets the braces.
+func (a *attacher) block(b *span, cs []comment, lastLine int) {
+	if b.endComments != nil && lastLine > 0 {
+		n := 0
+		for n < len(cs) && cs[n].line == lastLine && (len(b.children) == 0 || cs[n].offset < b.children[0].start) {
+			n++
+		}
+		if n > 0 {
+			b.comments.Open = a.group(cs[:n])
+			cs = cs[n:]
+		}
+	}
+
+	var prev *span
+	for _, s := range b.children {
+		n := 0
+		for n < len(cs) && cs[n].offset < s.start {
+			n++
+		}
+		pre := a.trailing(prev, cs[:n], &lastLine)
+		a.leading(s.comments, pre, lastLine, a.line(s.start))
+		cs = cs[n:]
+
+		n = 0
+		for n < len(cs) && cs[n].offset < s.end {
+			n++
+		}
+		a.inner(s, cs[:n])
+		cs = cs[n:]
+
+		prev = s
+		lastLine = a.line(s.end - 1)
+	}
+	rest := a.trailing(prev, cs, &lastLine)
+	if b.endComments == nil {
+		// an option list has no end comments
+		for _, g := range a.groups(rest) {
+			b.comments.Inner = append(b.comments.Inner, a.group(g))
+		}
+		return
+	}
+	a.leading(b.endComments, rest, lastLine, a.line(b.close))
+}
+
+// trailing attaches those of cs on the line on which prev ends to prev,
+// updating lastLine accordingly, and returns the rest.
+func (a *attacher) trailing(prev *span, cs []comment, lastLine *int) []comment {
+	if prev == nil {
+		return cs
+	}
+	n := 0
+	for n < len(cs) && cs[n].line == a.line(prev.end-1) {
+		n++
+	}
+	if n > 0 {
+		prev.comments.Trailing = a.group(cs[:n])
+		*lastLine = a.endLine(cs[n-1])
+	}
+	return cs[n:]
+}
+
+// leading attaches cs, which precede the statement that starts on nextLine,
+// or the closing brace of a block on nextLine, to c. lastLine is the line on
+// which the preceding statement, or its trailing comment, ends.
+func (a *attacher) leading(c *ast.Comments, cs []comment, lastLine, nextLine int) {
+	first := nextLine
+	if len(cs) > 0 {
+		first = cs[0].line
+	}
+	c.Blank = first > lastLine+1
+
+	groups := a.groups(cs)
+	for i, g := range groups {
+		cg := a.group(g)
+		if i == len(groups)-1 && nextLine <= a.endLine(g[len(g)-1])+1 {
+			c.Leading = cg
+		} else {
+			c.Detached = append(c.Detached, cg)
+		}
+	}
+}
+
+// inner attaches cs, the comments in the statement s, to s, or, for those in
+// the body of a block or an option list, to the statements or options in it.
+func (a *attacher) inner(s *span, cs []comment) {
+	var in, body []comment
+	for _, c := range cs {
+		if s.close > 0 && s.open <= c.offset && c.offset < s.close {
+			body = append(body, c)
+		} else {
+			in = append(in, c)
+		}
+	}
+	for _, g := range a.groups(in) {
+		s.comments.Inner = append(s.comments.Inner, a.group(g))
+	}
+	if s.close > 0 {
+		a.block(s, body, a.line(s.open-1))
+	}
+}
+
+// groups splits cs at blank lines.
+func (a *attacher) groups(cs []comment) [][]comment {
+	var gs [][]comment
+	for i, c := range cs {
+		if i == 0 || c.line > a.endLine(cs[i-1])+1 {
+			gs = append(gs, nil)
+		}
+		gs[len(gs)-1] = append(gs[len(gs)-1], c)
+	}
+	return gs
+}
+
+// group returns the comment group of cs, recording it.
+func (a *attacher) group(cs []comment) *ast.Comment {
+	c := &ast.Comment{
+		Start: ast.Position{
+			Line:   cs[0].line,
+			Offset: cs[0].offset,
+		},
+		End: ast.Position{
+			Line:   cs[len(cs)-1].line,
+			Offset: cs[len(cs)-1].offset,
+		},
+	}
+	for _, comm := range cs {
+		c.Text = append(c.Text, comm.text)
+		c.Source = append(c.Source, comm.src)
+	}
+
+	// Strip common whitespace prefix and any whitespace suffix.
+	// TODO: this is a bodgy implementation of Longest Common Prefix,
+	// and also doesn't do tabs vs. spaces well.
+	var prefix string
+	for i, line := range c.Text {
+		line = strings.TrimRightFunc(line, unicode.IsSpace)
+		c.Text[i] = line
+		trim := len(line) - len(strings.TrimLeftFunc(line, unicode.IsSpace))
+		if i == 0 {
+			prefix = line[:trim]
+		} else {
+			// Check how much of prefix is in common.
+			for !strings.HasPrefix(line, prefix) {
+				prefix = prefix[:len(prefix)-1]
+			}
+		}
+		if prefix == "" {
+			break
+		}
+	}
+	if prefix != "" {
+		for i, line := range c.Text {
+			c.Text[i] = strings.TrimPrefix(line, prefix)
+		}
+	}
+
+	a.all = append(a.all, c)
+	return c
+}
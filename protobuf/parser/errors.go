@@ -0,0 +1,96 @@
+// Copyright (c) 2016 Paul Jolly <paul@myitcv.org.uk>, all rights reserved.
+// Use of this document is governed by a license found in the LICENSE document.
+
+package parser
+
+import (
+	"fmt"
+	"sort"
+
+	"myitcv.io/protobuf/ast"
+)
+
+// ErrorCode classifies an Error.
+type ErrorCode int
+
+const (
+	// ErrSyntax is a syntax error
+	ErrSyntax ErrorCode = iota + 1
+
+	// ErrFile is a file that could not be found or read
+	ErrFile
+
+	// ErrUnresolved is a type name that could not be resolved
+	ErrUnresolved
+
+	// ErrInvalid is a type that is invalid where it is used, e.g. an
+	// extendee that is not a message
+	ErrInvalid
+)
+
+var errorCodes = map[ErrorCode]string{
+	ErrSyntax:     "syntax",
+	ErrFile:       "file",
+	ErrUnresolved: "unresolved",
+	ErrInvalid:    "invalid",
+}
+
+func (c ErrorCode) String() string {
+	if s, ok := errorCodes[c]; ok {
+		return s
+	}
+	return fmt.Sprintf("ErrorCode(%d)", int(c))
+}
+
+// Error is an error in a proto file. Pos and End are the positions of the
+// start and the end (exclusive) of the input in error; they are invalid for
+// a file that could not be found or read, other than an import.
+type Error struct {
+	Filename string
+	Pos, End ast.Position
+	Code     ErrorCode
+	Msg      string
+}
+
+func (e *Error) Error() string {
+	switch {
+	case e == nil:
+		return "<nil>"
+	case !e.Pos.IsValid():
+		return fmt.Sprintf("%s: %v", e.Filename, e.Msg)
+	case e.Pos.Line == 1:
+		return fmt.Sprintf("%s:1.%d: %v", e.Filename, e.Pos.Offset, e.Msg)
+	}
+	return fmt.Sprintf("%s:%d: %v", e.Filename, e.Pos.Line, e.Msg)
+}
+
+// ErrorList is a list of errors, as returned by ParseFilesMode in AllErrors
+// mode, sorted by file name and position.
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+}
+
+// Err returns l as an error, or nil if l is empty.
+func (l ErrorList) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
+
+func (l ErrorList) sort() {
+	sort.SliceStable(l, func(i, j int) bool {
+		if l[i].Filename != l[j].Filename {
+			return l[i].Filename < l[j].Filename
+		}
+		return l[i].Pos.Offset < l[j].Pos.Offset
+	})
+}
@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -27,7 +28,27 @@ func debugf(format string, args ...interface{}) {
 	}
 }
 
+// A Mode is a set of flags that control parsing.
+type Mode uint
+
+const (
+	// AllErrors causes the parser to recover from errors, skipping to the end
+	// of the statement in error, and to report all the errors found. The
+	// FileSet returned is then partial: it omits the fields, enum values and
+	// methods in error, and any unresolved types.
+	AllErrors Mode = 1 << iota
+)
+
+// ParseFiles parses the named files, and the files they import, which are
+// found relative to paths. It returns the first error found, if any.
 func ParseFiles(filenames []string, paths []string) (*ast.FileSet, error) {
+	return ParseFilesMode(filenames, paths, 0)
+}
+
+// ParseFilesMode is like ParseFiles, but mode controls the parsing. In
+// AllErrors mode the error returned, if any, is an ErrorList, and the FileSet
+// is returned with it.
+func ParseFilesMode(filenames []string, paths []string, mode Mode) (*ast.FileSet, error) {
 	// Force importPaths to have at least one element.
 	if len(paths) == 0 {
 		paths = []string{"."}
@@ -47,6 +68,22 @@ func ParseFiles(filenames []string, paths []string) (*ast.FileSet, error) {
 
 	index := make(map[string]int) // filename => index in fset.Files
 
+	// importedAt is where each imported file is first imported, for errors
+	// in finding or reading it
+	importedAt := make(map[string]Error)
+
+	var errs ErrorList
+
+	fileError := func(filename string, err error) {
+		e := importedAt[filename]
+		if e.Filename == "" {
+			e.Filename = filename
+		}
+		e.Code = ErrFile
+		e.Msg = err.Error()
+		errs = append(errs, &e)
+	}
+
 	for len(filenames) > 0 {
 		filename := filenames[0]
 		filenames = filenames[1:]
@@ -58,95 +95,117 @@ func ParseFiles(filenames []string, paths []string) (*ast.FileSet, error) {
 		index[filename] = len(fset.Files)
 		fset.Files = append(fset.Files, f)
 
-		// Read the first existing file relative to an element of importPaths.
-		var buf []byte
-		for _, impPath := range absImportPaths {
-			if !filepath.IsAbs(filename) {
-				// try and join the filename to the import path
-				b, err := ioutil.ReadFile(filepath.Join(impPath, filename))
-				if err != nil {
-					if !os.IsNotExist(err) {
-						return nil, err
-					}
-				} else {
-					buf = b
-				}
-			}
-			if buf == nil {
-				absFilename, err := filepath.Abs(filename)
-				if err != nil {
-					return nil, err
-				}
-				rel, err := filepath.Rel(impPath, absFilename)
-				if err != nil || strings.HasPrefix(rel, ".") {
-					// in this case we either couldn't make it relative
-					// or this import path does not 'contain' the file
-					continue
-				}
-
-				// otherwise this file exists within the import path
-				// read it
-				b, err := ioutil.ReadFile(absFilename)
-				if err != nil {
-					if os.IsNotExist(err) {
-						continue
-					}
-					return nil, err
-				}
-				buf = b
-			}
-			if buf != nil {
-				break
+		buf, err := readFile(filename, paths, absImportPaths)
+		if err != nil {
+			if mode&AllErrors == 0 {
+				return nil, err
 			}
-		}
-		if buf == nil {
-			return nil, fmt.Errorf("file not found in import paths: %s, paths %v", filename, paths)
+			fileError(filename, err)
+			continue
 		}
 
 		p := newParser(filename, string(buf))
+		p.allErrors = mode&AllErrors != 0
 		if pe := p.readFile(f); pe != nil {
 			return nil, pe
 		}
 		if p.s != "" {
 			return nil, p.errorf("input was not all consumed")
 		}
+		errs = append(errs, p.errs...)
 
 		// enqueue unparsed imports
-		for _, imp := range f.Imports {
+		for i, imp := range f.Imports {
 			if _, ok := index[imp]; !ok {
 				filenames = append(filenames, imp)
 			}
+			if _, ok := importedAt[imp]; !ok {
+				pos := f.ImportPositions[i]
+				importedAt[imp] = Error{
+					Filename: filename,
+					Pos:      pos,
+					End:      ast.Position{Line: pos.Line, Offset: pos.Offset + len(strconv.Quote(imp))},
+				}
+			}
 		}
 	}
 
-	if err := resolveSymbols(fset); err != nil {
-		return nil, err
+	rerrs := resolveSymbols(fset)
+	if mode&AllErrors == 0 {
+		if len(rerrs) > 0 {
+			return nil, rerrs[0]
+		}
+		return fset, nil
 	}
-	return fset, nil
+	errs = append(errs, rerrs...)
+	errs.sort()
+	return fset, errs.Err()
 }
 
-type parseError struct {
-	message  string
-	filename string
-	line     int // 1-based line number
-	offset   int // 0-based byte offset from start of input
+// ParseFile parses the source of the single proto file filename. It is purely
+// syntactic: imports are neither parsed nor checked, and type names are not
+// resolved. As with ParseFilesMode, in AllErrors mode the error returned, if
+// any, is an ErrorList, and the File is returned with it.
+func ParseFile(filename string, src []byte, mode Mode) (*ast.File, error) {
+	f := &ast.File{Name: filename}
+	p := newParser(filename, string(src))
+	p.allErrors = mode&AllErrors != 0
+	if pe := p.readFile(f); pe != nil {
+		return nil, pe
+	}
+	if p.s != "" {
+		return nil, p.errorf("input was not all consumed")
+	}
+	if mode&AllErrors != 0 {
+		return f, p.errs.Err()
+	}
+	return f, nil
 }
 
-func (pe *parseError) Error() string {
-	if pe == nil {
-		return "<nil>"
-	}
-	if pe.line == 1 {
-		return fmt.Sprintf("%s:1.%d: %v", pe.filename, pe.offset, pe.message)
+// readFile reads the first existing file relative to an element of
+// absImportPaths.
+func readFile(filename string, paths, absImportPaths []string) ([]byte, error) {
+	for _, impPath := range absImportPaths {
+		if !filepath.IsAbs(filename) {
+			// try and join the filename to the import path
+			b, err := ioutil.ReadFile(filepath.Join(impPath, filename))
+			if err == nil {
+				return b, nil
+			}
+			if !os.IsNotExist(err) {
+				return nil, err
+			}
+		}
+		absFilename, err := filepath.Abs(filename)
+		if err != nil {
+			return nil, err
+		}
+		rel, err := filepath.Rel(impPath, absFilename)
+		if err != nil || strings.HasPrefix(rel, ".") {
+			// in this case we either couldn't make it relative
+			// or this import path does not 'contain' the file
+			continue
+		}
+
+		// otherwise this file exists within the import path
+		// read it
+		b, err := ioutil.ReadFile(absFilename)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		return b, nil
 	}
-	return fmt.Sprintf("%s:%d: %v", pe.filename, pe.line, pe.message)
+	return nil, fmt.Errorf("file not found in import paths: %s, paths %v", filename, paths)
 }
 
-var eof = &parseError{message: "EOF"}
+var eof = &Error{Code: ErrSyntax, Msg: "EOF"}
 
 type token struct {
 	value        string
-	err          *parseError
+	err          *Error
 	line, offset int
 	unquoted     string // unquoted version of value
 }
@@ -166,214 +225,216 @@ type parser struct {
 	offset, line int
 	cur          token
 
+	// syntax is the syntax of the file, "proto2", "proto3" or "editions",
+	// once read; it is empty before then
+	syntax string
+
+	// depth is the number of braces opened, and not yet closed, up to and
+	// including the current token
+	depth int
+
+	// allErrors is set if the parser recovers from errors, which it then
+	// records in errs
+	allErrors   bool
+	errs        ErrorList
+	eofReported bool // whether an unexpected EOF is in errs
+
+	src     string // the whole input
+	prevEnd int    // offset just past the token before cur
+
 	comments []comment // accumulated during parse
+	spans    []*span   // statements read, to which comments are attached
+
+	// importComments are the comments of the import statements read, which
+	// become the ImportComments of the file
+	importComments []*ast.Comments
 }
 
 type comment struct {
 	text         string
+	src          string // the comment as it appears in the input
 	line, offset int
+	end          int // offset just past the comment
 }
 
 func newParser(filename, s string) *parser {
 	return &parser{
 		filename: filename,
 		s:        s,
+		src:      s,
 		line:     1,
 		cur:      token{line: 1},
 	}
 }
 
-func (p *parser) readFile(f *ast.File) *parseError {
+func (p *parser) readFile(f *ast.File) *Error {
 	// Parse top-level things.
 	for !p.done {
 		tok := p.next()
 		if tok.err == eof {
 			break
-		} else if tok.err != nil {
-			return tok.err
 		}
-		// TODO: enforce ordering? package, imports, remainder
-		switch tok.value {
-		case "package":
-			if f.Package != nil {
-				return p.errorf("duplicate package statement")
-			}
-			var pkg string
-			for {
-				tok := p.next()
-				if tok.err != nil {
-					return tok.err
-				}
-				if tok.value == ";" {
-					break
-				}
-				if tok.value == "." {
-					// okay if we already have at least one package component,
-					// and didn't just read a dot.
-					if pkg == "" || strings.HasSuffix(pkg, ".") {
-						return p.errorf(`got ".", want package name`)
-					}
-				} else {
-					// okay if we don't have a package component,
-					// or just read a dot.
-					if pkg != "" && !strings.HasSuffix(pkg, ".") {
-						return p.errorf(`got %q, want "." or ";"`, tok.value)
-					}
-					// TODO: validate more
-				}
-				pkg += tok.value
-			}
-			f.Package = strings.Split(pkg, ".")
-		case "option":
-			tok := p.next()
-			if tok.err != nil {
-				return tok.err
-			}
-			key := tok.value
-			if err := p.readToken("="); err != nil {
+		if err := p.readFileStatement(f, tok); err != nil {
+			if err := p.recover(err, 0); err != nil {
 				return err
 			}
-			tok = p.next()
+		}
+	}
+
+	p.attachComments(f)
+	for _, c := range p.importComments {
+		f.ImportComments = append(f.ImportComments, *c)
+	}
+
+	return nil
+}
+
+// readFileStatement reads a top-level statement, which starts with tok.
+func (p *parser) readFileStatement(f *ast.File, tok *token) *Error {
+	if tok.err != nil {
+		return tok.err
+	}
+	start, pos := tok.offset, tok.astPosition()
+	// TODO: enforce ordering? package, imports, remainder
+	switch tok.value {
+	case "package":
+		if f.Package != nil {
+			return p.errorf("duplicate package statement")
+		}
+		var pkg string
+		for {
+			tok := p.next()
 			if tok.err != nil {
 				return tok.err
 			}
-			value := tok.value
-			if err := p.readToken(";"); err != nil {
-				return err
-			}
-			f.Options = append(f.Options, [2]string{key, value})
-		case "syntax":
-			if f.Syntax != "" {
-				return p.errorf("duplicate syntax statement")
-			}
-			if err := p.readToken("="); err != nil {
-				return err
-			}
-			tok, err := p.readString()
-			if err != nil {
-				return err
-			}
-			switch s := tok.unquoted; s {
-			case "proto2", "proto3":
-				f.Syntax = s
-			default:
-				return p.errorf("invalid syntax value %q", s)
-			}
-			if err := p.readToken(";"); err != nil {
-				return err
+			if tok.value == ";" {
+				break
 			}
-		case "import":
-			if err := p.readToken("public"); err == nil {
-				f.PublicImports = append(f.PublicImports, len(f.Imports))
+			if tok.value == "." {
+				// okay if we already have at least one package component,
+				// and didn't just read a dot.
+				if pkg == "" || strings.HasSuffix(pkg, ".") {
+					return p.errorf(`got ".", want package name`)
+				}
 			} else {
-				p.back()
-			}
-			tok, err := p.readString()
-			if err != nil {
-				return err
-			}
-			f.Imports = append(f.Imports, tok.unquoted)
-			if err := p.readToken(";"); err != nil {
-				return err
-			}
-		case "message":
-			p.back()
-			msg := new(ast.Message)
-			f.Messages = append(f.Messages, msg)
-			if err := p.readMessage(msg); err != nil {
-				return err
-			}
-			msg.Up = f
-		case "enum":
-			p.back()
-			enum := new(ast.Enum)
-			f.Enums = append(f.Enums, enum)
-			if err := p.readEnum(enum); err != nil {
-				return err
-			}
-			enum.Up = f
-		case "service":
-			p.back()
-			srv := new(ast.Service)
-			f.Services = append(f.Services, srv)
-			if err := p.readService(srv); err != nil {
-				return err
-			}
-			srv.Up = f
-		case "extend":
-			p.back()
-			ext := new(ast.Extension)
-			f.Extensions = append(f.Extensions, ext)
-			if err := p.readExtension(ext); err != nil {
-				return err
+				// okay if we don't have a package component,
+				// or just read a dot.
+				if pkg != "" && !strings.HasSuffix(pkg, ".") {
+					return p.errorf(`got %q, want "." or ";"`, tok.value)
+				}
+				// TODO: validate more
 			}
-			ext.Up = f
+			pkg += tok.value
+		}
+		f.Package = strings.Split(pkg, ".")
+		f.PackagePosition = pos
+		p.addSpan(start, &f.PackageComments)
+	case "option":
+		o, err := p.readOptionStatement()
+		if err != nil {
+			return err
+		}
+		f.Options = append(f.Options, o)
+	case "syntax", "edition":
+		kind := tok.value
+		if f.Syntax != "" {
+			return p.errorf("duplicate syntax or edition statement")
+		}
+		if err := p.readToken("="); err != nil {
+			return err
+		}
+		tok, err := p.readString()
+		if err != nil {
+			return err
+		}
+		switch s := tok.unquoted; {
+		case kind == "syntax" && (s == "proto2" || s == "proto3"):
+			f.Syntax = s
+		case kind == "syntax":
+			return p.errorf("invalid syntax value %q", s)
+		case s == "2023":
+			f.Syntax = "editions"
+			f.Edition = s
 		default:
-			return p.errorf("unknown top-level thing %q", tok.value)
+			return p.errorf("unsupported edition %q", s)
 		}
-	}
-
-	// Handle comments.
-	for len(p.comments) > 0 {
-		n := 1
-		for ; n < len(p.comments); n++ {
-			if p.comments[n].line != p.comments[n-1].line+1 {
-				break
-			}
+		p.syntax = f.Syntax
+		if err := p.readToken(";"); err != nil {
+			return err
 		}
-		c := &ast.Comment{
-			Start: ast.Position{
-				Line:   p.comments[0].line,
-				Offset: p.comments[0].offset,
-			},
-			End: ast.Position{
-				Line:   p.comments[n-1].line,
-				Offset: p.comments[n-1].offset,
-			},
-		}
-		for _, comm := range p.comments[:n] {
-			c.Text = append(c.Text, comm.text)
-		}
-		p.comments = p.comments[n:]
-
-		// Strip common whitespace prefix and any whitespace suffix.
-		// TODO: this is a bodgy implementation of Longest Common Prefix,
-		// and also doesn't do tabs vs. spaces well.
-		var prefix string
-		for i, line := range c.Text {
-			line = strings.TrimRightFunc(line, unicode.IsSpace)
-			c.Text[i] = line
-			trim := len(line) - len(strings.TrimLeftFunc(line, unicode.IsSpace))
-			if i == 0 {
-				prefix = line[:trim]
-			} else {
-				// Check how much of prefix is in common.
-				for !strings.HasPrefix(line, prefix) {
-					prefix = prefix[:len(prefix)-1]
-				}
-			}
-			if prefix == "" {
-				break
-			}
+		f.SyntaxPosition = pos
+		p.addSpan(start, &f.SyntaxComments)
+	case "import":
+		tok := p.next()
+		if tok.err != nil {
+			return tok.err
 		}
-		if prefix != "" {
-			for i, line := range c.Text {
-				c.Text[i] = strings.TrimPrefix(line, prefix)
-			}
+		switch tok.value {
+		case "public":
+			f.PublicImports = append(f.PublicImports, len(f.Imports))
+		case "weak":
+			f.WeakImports = append(f.WeakImports, len(f.Imports))
+		default:
+			p.back()
 		}
-
-		f.Comments = append(f.Comments, c)
+		tok, err := p.readString()
+		if err != nil {
+			return err
+		}
+		f.Imports = append(f.Imports, tok.unquoted)
+		f.ImportPositions = append(f.ImportPositions, tok.astPosition())
+		if err := p.readToken(";"); err != nil {
+			return err
+		}
+		c := new(ast.Comments)
+		p.importComments = append(p.importComments, c)
+		p.addSpan(start, c)
+	case "message":
+		p.back()
+		msg := new(ast.Message)
+		f.Messages = append(f.Messages, msg)
+		msg.Up = f
+		if err := p.readMessage(msg); err != nil {
+			return err
+		}
+	case "enum":
+		p.back()
+		enum := new(ast.Enum)
+		f.Enums = append(f.Enums, enum)
+		enum.Up = f
+		if err := p.readEnum(enum); err != nil {
+			return err
+		}
+	case "service":
+		p.back()
+		srv := new(ast.Service)
+		f.Services = append(f.Services, srv)
+		srv.Up = f
+		if err := p.readService(srv); err != nil {
+			return err
+		}
+	case "extend":
+		p.back()
+		ext := new(ast.Extension)
+		f.Extensions = append(f.Extensions, ext)
+		ext.Up = f
+		if err := p.readExtension(ext); err != nil {
+			return err
+		}
+	case ";":
+		// empty statement
+	default:
+		return p.errorf("unknown top-level thing %q", tok.value)
 	}
-	// No need to sort comments; they are already in source order.
-
 	return nil
 }
 
-func (p *parser) readMessage(msg *ast.Message) *parseError {
+func (p *parser) readMessage(msg *ast.Message) *Error {
 	if err := p.readToken("message"); err != nil {
 		return err
 	}
 	msg.Position = p.cur.astPosition()
+	start := p.cur.offset
 
 	tok := p.next()
 	if tok.err != nil {
@@ -384,154 +445,191 @@ func (p *parser) readMessage(msg *ast.Message) *parseError {
 	if err := p.readToken("{"); err != nil {
 		return err
 	}
+	open := p.end()
 
 	if err := p.readMessageContents(msg); err != nil {
 		return err
 	}
 
-	return p.readToken("}")
+	if err := p.readToken("}"); err != nil {
+		return err
+	}
+	p.addBlock(start, open, p.cur.offset, &msg.Comments, &msg.EndComments)
+	return nil
 }
 
-func (p *parser) readMessageContents(msg *ast.Message) *parseError {
+func (p *parser) readMessageContents(msg *ast.Message) *Error {
 	// Parse message fields and other things inside a message.
+	depth := p.depth
 	var oneof *ast.Oneof // set while inside a oneof
+	var oneofStart, oneofOpen int
 	for !p.done {
 		tok := p.next()
-		if tok.err != nil {
+		if tok.err == eof {
 			return tok.err
 		}
-		switch tok.value {
-		case "extend":
-			// extension
-			p.back()
-			ext := new(ast.Extension)
-			msg.Extensions = append(msg.Extensions, ext)
-			if err := p.readExtension(ext); err != nil {
-				return err
-			}
-			ext.Up = msg
-		case "oneof":
-			// oneof
-			if oneof != nil {
-				return p.errorf("nested oneof not permitted")
-			}
-			oneof = new(ast.Oneof)
-			msg.Oneofs = append(msg.Oneofs, oneof)
-			oneof.Position = p.cur.astPosition()
-
-			tok := p.next()
-			if tok.err != nil {
-				return tok.err
-			}
-			oneof.Name = tok.value // TODO: validate
-			oneof.Up = msg
-
-			if err := p.readToken("{"); err != nil {
-				return err
-			}
-		case "message":
-			// nested message
-			p.back()
-			nmsg := new(ast.Message)
-			msg.Messages = append(msg.Messages, nmsg)
-			if err := p.readMessage(nmsg); err != nil {
-				return err
-			}
-			nmsg.Up = msg
-		case "option":
-			// message option
-			if err := p.readToken("("); err != nil {
-				return err
-			}
-			tok := p.next()
-			if tok.err != nil {
-				return tok.err
-			}
-			key := tok.value
-			if err := p.readToken(")"); err != nil {
-				return err
-			}
-			if err := p.readToken("="); err != nil {
-				return err
-			}
-			tok = p.next()
-			if tok.err != nil {
-				return tok.err
-			}
-			value := tok.value
-			if err := p.readToken(";"); err != nil {
-				return err
-			}
-			msg.Options = append(msg.Options, [2]string{key, value})
-		case "enum":
-			// nested enum
-			p.back()
-			ne := new(ast.Enum)
-			msg.Enums = append(msg.Enums, ne)
-			if err := p.readEnum(ne); err != nil {
-				return err
-			}
-			ne.Up = msg
-		case "extensions":
-			// extension range
-			p.back()
-			r, err := p.readExtensionRange()
-			if err != nil {
-				return err
-			}
-			msg.ExtensionRanges = append(msg.ExtensionRanges, r...)
-		case "reserved":
-			// reserved field name/tag list
-			p.back()
-			r, err := p.readReservedRange()
-			if err != nil {
-				return err
-			}
-			msg.ReservedFields = append(msg.ReservedFields, r...)
-		default:
-			// field; this token is required/optional/repeated,
-			// a primitive type, or a named type.
-			p.back()
-			field := new(ast.Field)
-			msg.Fields = append(msg.Fields, field)
-			field.Oneof = oneof
-			field.Up = msg // p.readField uses this
-			if err := p.readField(field); err != nil {
-				return err
-			}
-		case "}":
+		var err *Error
+		switch {
+		case tok.err != nil:
+			err = tok.err
+		case tok.value == "}":
 			if oneof != nil {
 				// end of oneof
+				p.addBlock(oneofStart, oneofOpen, tok.offset, &oneof.Comments, &oneof.EndComments)
 				oneof = nil
 				continue
 			}
 			// end of message
 			p.back()
 			return nil
+		case tok.value == "oneof":
+			if oneof != nil {
+				err = p.errorf("nested oneof not permitted")
+				break
+			}
+			oneofStart = tok.offset
+			oneof, err = p.readOneof(msg)
+			oneofOpen = p.end()
+		default:
+			err = p.readMessageStatement(msg, oneof, tok)
+		}
+		if err != nil {
+			d := depth
+			if oneof != nil {
+				d++
+			}
+			if err := p.recover(err, d); err != nil {
+				return err
+			}
 		}
 	}
 	return p.errorf("unexpected EOF while parsing message")
 }
 
-func (p *parser) readField(f *ast.Field) *parseError {
-	_, inMsg := f.Up.(*ast.Message)
-
-	// TODO: enforce type limitations if f.Oneof != nil
+// readOneof reads the start of a oneof in msg, up to and including its
+// opening brace, the oneof token of which has already been read.
+func (p *parser) readOneof(msg *ast.Message) (*ast.Oneof, *Error) {
+	oneof := &ast.Oneof{
+		Position: p.cur.astPosition(),
+		Up:       msg,
+	}
 
-	// look for required/optional/repeated
 	tok := p.next()
 	if tok.err != nil {
-		return tok.err
+		return nil, tok.err
 	}
-	f.Position = p.cur.astPosition()
+	oneof.Name = tok.value // TODO: validate
+
+	if err := p.readToken("{"); err != nil {
+		return nil, err
+	}
+	msg.Oneofs = append(msg.Oneofs, oneof)
+	return oneof, nil
+}
+
+// readMessageStatement reads a statement in msg, or in oneof if it is not
+// nil, which starts with tok.
+func (p *parser) readMessageStatement(msg *ast.Message, oneof *ast.Oneof, tok *token) *Error {
 	switch tok.value {
-	case "required":
-		f.Required = true
-	case "optional":
-		// nothing to do
-	case "repeated":
-		f.Repeated = true
-	case "map":
+	case "extend":
+		// extension
+		p.back()
+		ext := new(ast.Extension)
+		msg.Extensions = append(msg.Extensions, ext)
+		ext.Up = msg
+		if err := p.readExtension(ext); err != nil {
+			return err
+		}
+	case "message":
+		// nested message
+		p.back()
+		nmsg := new(ast.Message)
+		msg.Messages = append(msg.Messages, nmsg)
+		nmsg.Up = msg
+		if err := p.readMessage(nmsg); err != nil {
+			return err
+		}
+	case "option":
+		// message or oneof option
+		o, err := p.readOptionStatement()
+		if err != nil {
+			return err
+		}
+		if oneof != nil {
+			oneof.Options = append(oneof.Options, o)
+		} else {
+			msg.Options = append(msg.Options, o)
+		}
+	case "enum":
+		// nested enum
+		p.back()
+		ne := new(ast.Enum)
+		msg.Enums = append(msg.Enums, ne)
+		ne.Up = msg
+		if err := p.readEnum(ne); err != nil {
+			return err
+		}
+	case "extensions":
+		// extension range
+		p.back()
+		r, err := p.readExtensionRange()
+		if err != nil {
+			return err
+		}
+		msg.ExtensionRanges = append(msg.ExtensionRanges, r.Ranges...)
+		msg.ExtensionRangeStatements = append(msg.ExtensionRangeStatements, r)
+	case "reserved":
+		// reserved field name/tag list
+		p.back()
+		r, err := p.readReservedRange(false)
+		if err != nil {
+			return err
+		}
+		msg.ReservedFields = append(msg.ReservedFields, r.Reserved...)
+		msg.ReservedStatements = append(msg.ReservedStatements, r)
+	case ";":
+		// empty statement
+	default:
+		// field; this token is required/optional/repeated,
+		// a primitive type, or a named type.
+		p.back()
+		field := new(ast.Field)
+		field.Oneof = oneof
+		field.Up = msg // p.readField uses this
+		if err := p.readField(field); err != nil {
+			return err
+		}
+		msg.Fields = append(msg.Fields, field)
+	}
+	return nil
+}
+
+func (p *parser) readField(f *ast.Field) *Error {
+	_, inMsg := f.Up.(*ast.Message)
+
+	// TODO: enforce type limitations if f.Oneof != nil
+
+	// look for required/optional/repeated
+	tok := p.next()
+	if tok.err != nil {
+		return tok.err
+	}
+	f.Position = p.cur.astPosition()
+	start := tok.offset
+	switch tok.value {
+	case "required":
+		if p.syntax == "proto3" || p.syntax == "editions" {
+			return p.errorf(`label "required" is not allowed in %v files`, p.syntax)
+		}
+		f.Required = true
+	case "optional":
+		if p.syntax == "editions" {
+			return p.errorf(`label "optional" is not allowed in editions files`)
+		}
+		f.Optional = true
+	case "repeated":
+		f.Repeated = true
+	case "map":
 		// map < Key , Value >
 		if err := p.readToken("<"); err != nil {
 			return err
@@ -541,6 +639,7 @@ func (p *parser) readField(f *ast.Field) *parseError {
 			return tok.err
 		}
 		f.KeyTypeName = tok.value // checked during resolution
+		f.KeyTypeNamePosition = tok.astPosition()
 		if err := p.readToken(","); err != nil {
 			return err
 		}
@@ -549,6 +648,7 @@ func (p *parser) readField(f *ast.Field) *parseError {
 			return tok.err
 		}
 		f.TypeName = tok.value // checked during resolution
+		f.TypeNamePosition = tok.astPosition()
 		if err := p.readToken(">"); err != nil {
 			return err
 		}
@@ -564,6 +664,7 @@ func (p *parser) readField(f *ast.Field) *parseError {
 		return tok.err
 	}
 	f.TypeName = tok.value // checked during resolution
+	f.TypeNamePosition = tok.astPosition()
 
 parseFromFieldName:
 	tok = p.next()
@@ -583,9 +684,13 @@ parseFromFieldName:
 	f.Tag = tag
 
 	if f.TypeName == "group" && inMsg {
+		if p.syntax == "proto3" || p.syntax == "editions" {
+			return p.errorf("groups are not allowed in %v files", p.syntax)
+		}
 		if err := p.readToken("{"); err != nil {
 			return err
 		}
+		open := p.end()
 
 		group := &ast.Message{
 			// the current parse position is probably good enough
@@ -603,16 +708,20 @@ parseFromFieldName:
 		if err := p.readToken("}"); err != nil {
 			return err
 		}
+		close := p.cur.offset
 		// A semicolon after a group is optional.
 		if err := p.readToken(";"); err != nil {
 			p.back()
 		}
+		p.addBlock(start, open, close, &f.Comments, &group.EndComments)
 		return nil
 	}
 
+	var open, close int
 	if err := p.readToken("["); err == nil {
 		p.back()
-		if err := p.readFieldOptions(f); err != nil {
+		open, close, err = p.readFieldOptions(f)
+		if err != nil {
 			return err
 		}
 	} else {
@@ -622,100 +731,71 @@ parseFromFieldName:
 	if err := p.readToken(";"); err != nil {
 		return err
 	}
+	s := p.addSpan(start, &f.Comments)
+	s.open, s.close = open, close
 	return nil
 }
 
-func (p *parser) readFieldOptions(f *ast.Field) *parseError {
-	if err := p.readToken("["); err != nil {
-		return err
+// readFieldOptions reads the option list of f, returning the offsets of its
+// brackets as readOptionList does.
+func (p *parser) readFieldOptions(f *ast.Field) (int, int, *Error) {
+	opts, open, close, err := p.readOptionList()
+	if err != nil {
+		return 0, 0, err
 	}
-	for !p.done {
-		tok := p.next()
-		if tok.err != nil {
-			return tok.err
+	for _, o := range opts {
+		if len(o.Name) != 1 || o.Name[0].IsExtension {
+			f.Options = append(f.Options, o)
+			continue
 		}
-		// TODO: support more options than just default and packed
-		switch tok.value {
+		switch o.Name[0].Name {
 		case "default":
 			f.HasDefault = true
-			if err := p.readToken("="); err != nil {
-				return err
-			}
-			tok := p.next()
-			if tok.err != nil {
-				return tok.err
-			}
+			f.DefaultComments = &o.Comments
 			// TODO: check type
-			switch f.TypeName {
-			case "string":
-				f.Default = tok.unquoted
+			switch o.Value.Kind {
+			case ast.AggregateValue:
+				return 0, 0, p.errorf("default value for %v must be a scalar", f.Name)
+			case ast.StringValue:
+				if f.TypeName == "string" {
+					f.Default = o.Value.Unquoted
+					break
+				}
+				fallthrough
 			default:
-				f.Default = tok.value
+				f.Default = o.Value.Source
 			}
 		case "packed":
 			f.HasPacked = true
-			if err := p.readToken("="); err != nil {
-				return err
-			}
-			packed, err := p.readBool()
+			f.PackedComments = &o.Comments
+			packed, err := p.optionBool(o)
 			if err != nil {
-				return err
+				return 0, 0, err
 			}
 			f.Packed = packed
 		case "deprecated":
 			f.HasDeprecated = true
-			if err := p.readToken("="); err != nil {
-				return err
-			}
-			deprecated, err := p.readBool()
+			f.DeprecatedComments = &o.Comments
+			deprecated, err := p.optionBool(o)
 			if err != nil {
-				return err
+				return 0, 0, err
 			}
 			f.Deprecated = deprecated
-		case "(":
-			// TODO test cases needed here
-			tok := p.next()
-			if tok.err != nil {
-				return tok.err
-			}
-			key := tok.value
-			if err := p.readToken(")"); err != nil {
-				return err
-			}
-			if err := p.readToken("="); err != nil {
-				return err
-			}
-			tok = p.next()
-			if tok.err != nil {
-				return tok.err
-			}
-			val := tok.value
-			f.Options = append(f.Options, [2]string{key, val})
 		default:
-			return p.errorf(`got %q, want "default" or "packed"`, tok.value)
-		}
-		// next should be a comma or ]
-		tok = p.next()
-		if tok.err != nil {
-			return tok.err
-		}
-		if tok.value == "," {
-			continue
-		}
-		if tok.value == "]" {
-			return nil
+			f.Options = append(f.Options, o)
 		}
-		return p.errorf(`got %q, want "," or "]"`, tok.value)
 	}
-	return p.errorf("unexpected EOF while parsing field options")
+	return open, close, nil
 }
 
-func (p *parser) readExtensionRange() ([][2]int, *parseError) {
+func (p *parser) readExtensionRange() (*ast.ExtensionRangeStatement, *Error) {
 	if err := p.readToken("extensions"); err != nil {
 		return nil, err
 	}
+	st := &ast.ExtensionRangeStatement{Position: p.cur.astPosition()}
+	stStart := p.cur.offset
+	var open, close int
 
-	var rs [][2]int
 	for {
 		// next token must be a number,
 		// followed by a comma, semicolon or "to".
@@ -726,7 +806,7 @@ func (p *parser) readExtensionRange() ([][2]int, *parseError) {
 		end := start
 		tok := p.next()
 		if tok.err != nil {
-			return nil, err
+			return nil, tok.err
 		}
 		if tok.value == "to" {
 			end, err = p.readTagNumber(true) // allow "max"
@@ -738,32 +818,56 @@ func (p *parser) readExtensionRange() ([][2]int, *parseError) {
 			}
 			tok = p.next()
 			if tok.err != nil {
+				return nil, tok.err
+			}
+		}
+		st.Ranges = append(st.Ranges, [2]int{start, end})
+		if tok.value == "[" {
+			p.back()
+			opts, o, c, err := p.readOptionList()
+			if err != nil {
+				return nil, err
+			}
+			st.Options = opts
+			open, close = o, c
+			if err := p.readToken(";"); err != nil {
 				return nil, err
 			}
+			break
 		}
-		rs = append(rs, [2]int{start, end})
 		if tok.value != "," && tok.value != ";" {
-			return nil, p.errorf(`got %q, want ",", ";" or "to"`, tok.value)
+			return nil, p.errorf(`got %q, want ",", ";", "[" or "to"`, tok.value)
 		}
 		if tok.value == ";" {
 			break
 		}
 	}
-	return rs, nil
+	s := p.addSpan(stStart, &st.Comments)
+	s.open, s.close = open, close
+	return st, nil
 }
 
-func (p *parser) readReservedRange() ([]ast.Reserved, *parseError) {
+// readReservedRange reads a reserved statement, of field numbers and names,
+// or, if inEnum is true, of enum value numbers and names.
+func (p *parser) readReservedRange(inEnum bool) (*ast.ReservedStatement, *Error) {
 	if err := p.readToken("reserved"); err != nil {
 		return nil, err
 	}
+	st := &ast.ReservedStatement{Position: p.cur.astPosition()}
+	stStart := p.cur.offset
+
+	max := int64(1<<29 - 1)
+	if inEnum {
+		max = math.MaxInt32
+	}
 
 	first := true
 	tagList := false
-	var rs []ast.Reserved
 
 	for {
 		// sequence of reserved values must be either all tags (ints)
-		// or all names (string). Tags may be ranges
+		// or all names (strings, or identifiers in editions files).
+		// Tags may be ranges
 		nameOrTag := p.next()
 		if nameOrTag.err != nil {
 			return nil, nameOrTag.err
@@ -778,10 +882,20 @@ func (p *parser) readReservedRange() ([]ast.Reserved, *parseError) {
 				return nil, p.errorf("reserved lists must be all tags or all names, not a mix")
 			}
 		}
-
-		end := start
 		first = false
 
+		var r ast.Reserved
+		switch v := nameOrTag.value; {
+		case tagList:
+			r.Start, r.End = int(start), int(start)
+		case p.syntax == "editions" && isIdent(v):
+			r.Name = v
+		case p.syntax != "editions" && (v[0] == '"' || v[0] == '\''):
+			r.Name = nameOrTag.unquoted
+		default:
+			return nil, p.errorf("bad reserved name %v", v)
+		}
+
 		tok := p.next()
 		if tok.err != nil {
 			return nil, tok.err
@@ -796,292 +910,722 @@ func (p *parser) readReservedRange() ([]ast.Reserved, *parseError) {
 			if tok.err != nil {
 				return nil, tok.err
 			}
-			end, err := strconv.ParseInt(tok.value, 10, 32)
-			if err != nil {
-				return nil, p.errorf("reserved range does not end with number")
+			end := max
+			if tok.value != "max" {
+				end, err = strconv.ParseInt(tok.value, 10, 32)
+				if err != nil {
+					return nil, p.errorf("reserved range does not end with number")
+				}
 			}
 
 			if start > end {
 				return nil, p.errorf("bad reserved range order: %d > %d", start, end)
 			}
+			r.End = int(end)
 
 			tok = p.next()
 			if tok.err != nil {
 				return nil, tok.err
 			}
 		}
-		if tagList {
-			rs = append(rs, ast.Reserved{Start: int(start), End: int(end)})
-		} else {
-			rs = append(rs, ast.Reserved{Name: nameOrTag.value})
-		}
-		if tok.value != "," && tok.value != ";" {
-			return nil, p.errorf(`got %q, want ",", ";" or "to"`, tok.value)
-		}
-		if tok.value == ";" {
-			break
+		st.Reserved = append(st.Reserved, r)
+		if tok.value != "," && tok.value != ";" {
+			return nil, p.errorf(`got %q, want ",", ";" or "to"`, tok.value)
+		}
+		if tok.value == ";" {
+			break
+		}
+	}
+	p.addSpan(stStart, &st.Comments)
+	return st, nil
+}
+
+func (p *parser) readTagNumber(allowMax bool) (int, *Error) {
+	tok := p.next()
+	if tok.err != nil {
+		return 0, tok.err
+	}
+	if allowMax && tok.value == "max" {
+		return 1<<29 - 1, nil
+	}
+	n, err := strconv.ParseInt(tok.value, 10, 32)
+	if err != nil {
+		return 0, p.errorf("bad field number %q: %v", tok.value, err)
+	}
+	if n < 1 || n >= 1<<29 {
+		return 0, p.errorf("field number %v out of range", n)
+	}
+	if 19000 <= n && n <= 19999 { // TODO: still relevant?
+		return 0, p.errorf("field number %v in reserved range [19000, 19999]", n)
+	}
+	return int(n), nil
+}
+
+func (p *parser) readEnum(enum *ast.Enum) *Error {
+	if err := p.readToken("enum"); err != nil {
+		return err
+	}
+	enum.Position = p.cur.astPosition()
+	start := p.cur.offset
+
+	tok := p.next()
+	if tok.err != nil {
+		return tok.err
+	}
+	enum.Name = tok.value // TODO: validate
+
+	if err := p.readToken("{"); err != nil {
+		return err
+	}
+
+	// Parse enum values
+	open := p.end()
+	depth := p.depth
+	for !p.done {
+		tok := p.next()
+		if tok.err == eof {
+			return tok.err
+		}
+		if tok.err == nil && tok.value == "}" {
+			// end of enum
+			close := tok.offset
+			// A semicolon after an enum is optional.
+			if err := p.readToken(";"); err != nil {
+				p.back()
+			}
+			p.addBlock(start, open, close, &enum.Comments, &enum.EndComments)
+			return nil
+		}
+		if err := p.readEnumStatement(enum, tok); err != nil {
+			if err := p.recover(err, depth); err != nil {
+				return err
+			}
+		}
+	}
+
+	return p.errorf("unexpected EOF while parsing enum")
+}
+
+// readEnumStatement reads a statement in enum, which starts with tok.
+func (p *parser) readEnumStatement(enum *ast.Enum, tok *token) *Error {
+	if tok.err != nil {
+		return tok.err
+	}
+	start := tok.offset
+	switch tok.value {
+	case ";":
+		// empty statement
+		return nil
+	case "option":
+		o, err := p.readOptionStatement()
+		if err != nil {
+			return err
+		}
+		enum.Options = append(enum.Options, o)
+		return nil
+	case "reserved":
+		p.back()
+		r, err := p.readReservedRange(true)
+		if err != nil {
+			return err
+		}
+		enum.Reserved = append(enum.Reserved, r.Reserved...)
+		enum.ReservedStatements = append(enum.ReservedStatements, r)
+		return nil
+	}
+	// TODO: verify tok.value is a valid enum value name.
+	ev := new(ast.EnumValue)
+	ev.Position = tok.astPosition()
+	ev.Name = tok.value // TODO: validate
+	ev.Up = enum
+
+	if err := p.readToken("="); err != nil {
+		return err
+	}
+
+	tok = p.next()
+	if tok.err != nil {
+		return tok.err
+	}
+	// TODO: check that tok.value is a valid enum value number.
+	num, err := strconv.ParseInt(tok.value, 10, 32)
+	if err != nil {
+		return p.errorf("bad enum number %q: %v", tok.value, err)
+	}
+	ev.Number = int32(num) // TODO: validate
+
+	tok = p.next()
+	if tok.err != nil {
+		return tok.err
+	}
+	p.back()
+	var open, close int
+	if tok.value == "[" {
+		opts, o, c, err := p.readOptionList()
+		if err != nil {
+			return err
+		}
+		ev.Options = opts
+		open, close = o, c
+	}
+
+	if err := p.readToken(";"); err != nil {
+		return err
+	}
+	s := p.addSpan(start, &ev.Comments)
+	s.open, s.close = open, close
+	enum.Values = append(enum.Values, ev)
+	return nil
+}
+
+func (p *parser) readService(srv *ast.Service) *Error {
+	if err := p.readToken("service"); err != nil {
+		return err
+	}
+	srv.Position = p.cur.astPosition()
+	start := p.cur.offset
+
+	tok := p.next()
+	if tok.err != nil {
+		return tok.err
+	}
+	srv.Name = tok.value // TODO: validate
+
+	if err := p.readToken("{"); err != nil {
+		return err
+	}
+
+	// Parse methods
+	open := p.end()
+	depth := p.depth
+	for !p.done {
+		tok := p.next()
+		if tok.err == eof {
+			return tok.err
+		}
+		if tok.err == nil && tok.value == "}" {
+			// end of service
+			p.addBlock(start, open, tok.offset, &srv.Comments, &srv.EndComments)
+			return nil
+		}
+		if err := p.readServiceStatement(srv, tok); err != nil {
+			if err := p.recover(err, depth); err != nil {
+				return err
+			}
+		}
+	}
+
+	return p.errorf("unexpected EOF while parsing service")
+}
+
+// readServiceStatement reads a statement in srv, which starts with tok.
+func (p *parser) readServiceStatement(srv *ast.Service, tok *token) *Error {
+	if tok.err != nil {
+		return tok.err
+	}
+	start := tok.offset
+	switch tok.value {
+	case ";":
+		// empty statement
+		return nil
+	case "option":
+		o, err := p.readOptionStatement()
+		if err != nil {
+			return err
+		}
+		srv.Options = append(srv.Options, o)
+		return nil
+	case "rpc":
+		// handled below
+	default:
+		return p.errorf(`got %q, want "option", "rpc" or "}"`, tok.value)
+	}
+
+	tok = p.next()
+	if tok.err != nil {
+		return tok.err
+	}
+	if !isIdent(tok.value) {
+		return p.errorf("got %q, want method name", tok.value)
+	}
+	mth := new(ast.Method)
+	mth.Position = tok.astPosition()
+	mth.Name = tok.value
+	mth.Up = srv
+
+	var err *Error
+	mth.ClientStreaming, mth.InTypeName, mth.InTypeNamePosition, err = p.readMethodType()
+	if err != nil {
+		return err
+	}
+	if err := p.readToken("returns"); err != nil {
+		return err
+	}
+	mth.ServerStreaming, mth.OutTypeName, mth.OutTypeNamePosition, err = p.readMethodType()
+	if err != nil {
+		return err
+	}
+	tok = p.next()
+	if tok.err != nil {
+		return tok.err
+	}
+	if tok.value == "{" {
+		p.back()
+		open := p.cur.offset + len("{")
+		if err := p.readMethodOptions(mth); err != nil {
+			return err
+		}
+		p.addBlock(start, open, p.cur.offset, &mth.Comments, &mth.EndComments)
+	} else if tok.value == ";" {
+		p.addSpan(start, &mth.Comments)
+	} else {
+		return p.errorf("unexpected %v while parsing Method", tok.value)
+	}
+	srv.Methods = append(srv.Methods, mth)
+	return nil
+}
+
+// readMethodType reads the parenthesised input or output type of a method,
+// returning whether the type is preceded by stream, and the name and
+// position of the type.
+func (p *parser) readMethodType() (bool, string, ast.Position, *Error) {
+	if err := p.readToken("("); err != nil {
+		return false, "", ast.Position{}, err
+	}
+	tok := p.next()
+	if tok.err != nil {
+		return false, "", ast.Position{}, tok.err
+	}
+	stream := false
+	name, pos := tok.value, tok.astPosition() // TODO: validate
+	if name == "stream" {
+		// unless this is a type named stream
+		tok := p.next()
+		if tok.err != nil {
+			return false, "", ast.Position{}, tok.err
+		}
+		if tok.value == ")" {
+			p.back()
+		} else {
+			stream = true
+			name, pos = tok.value, tok.astPosition()
+		}
+	}
+	if err := p.readToken(")"); err != nil {
+		return false, "", ast.Position{}, err
+	}
+	return stream, name, pos, nil
+}
+
+func (p *parser) readMethodOptions(mth *ast.Method) *Error {
+	if err := p.readToken("{"); err != nil {
+		return err
+	}
+	depth := p.depth
+	for !p.done {
+		tok := p.next()
+		if tok.err == eof {
+			return tok.err
+		}
+		var err *Error
+		switch {
+		case tok.err != nil:
+			err = tok.err
+		case tok.value == "}":
+			// End of Options
+			return nil
+		case tok.value == ";":
+			// empty statement
+			continue
+		case tok.value == "option":
+			var o *ast.Option
+			if o, err = p.readOptionStatement(); err == nil {
+				mth.Options = append(mth.Options, o)
+			}
+		default:
+			err = p.errorf(`got %q, want "option" or "}"`, tok.value)
+		}
+		if err != nil {
+			if err := p.recover(err, depth); err != nil {
+				return err
+			}
+		}
+	}
+	return p.errorf("unexpected EOF while parsing method options")
+}
+
+func (p *parser) readExtension(ext *ast.Extension) *Error {
+	if err := p.readToken("extend"); err != nil {
+		return err
+	}
+	ext.Position = p.cur.astPosition()
+	start := p.cur.offset
+
+	tok := p.next()
+	if tok.err != nil {
+		return tok.err
+	}
+	ext.Extendee = tok.value // checked during resolution
+	ext.ExtendeePosition = tok.astPosition()
+
+	if err := p.readToken("{"); err != nil {
+		return err
+	}
+
+	open := p.end()
+	depth := p.depth
+	for !p.done {
+		tok := p.next()
+		if tok.err == eof {
+			return tok.err
+		}
+		err := tok.err
+		if err == nil {
+			if tok.value == "}" {
+				// end of extension
+				p.addBlock(start, open, tok.offset, &ext.Comments, &ext.EndComments)
+				return nil
+			}
+			p.back()
+			field := new(ast.Field)
+			field.Up = ext // p.readFile uses this
+			if err = p.readField(field); err == nil {
+				ext.Fields = append(ext.Fields, field)
+			}
+		}
+		if err != nil {
+			if err := p.recover(err, depth); err != nil {
+				return err
+			}
+		}
+	}
+	return p.errorf("unexpected EOF while parsing extension")
+}
+
+// readOptionStatement reads an option statement, the option token of which
+// has already been read, e.g. the remainder of
+//
+//	option (foo).bar = 1;
+func (p *parser) readOptionStatement() (*ast.Option, *Error) {
+	start, pos := p.cur.offset, p.cur.astPosition()
+	o, err := p.readOption()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.readToken(";"); err != nil {
+		return nil, err
+	}
+	o.Position = pos
+	p.addSpan(start, &o.Comments)
+	return o, nil
+}
+
+// readOptionList reads a bracketed list of options, e.g.
+//
+//	[deprecated = true, (foo) = { a: 1 }]
+//
+// It also returns the offsets just past the opening bracket and of the
+// closing bracket.
+func (p *parser) readOptionList() ([]*ast.Option, int, int, *Error) {
+	if err := p.readToken("["); err != nil {
+		return nil, 0, 0, err
+	}
+	open := p.end()
+	var opts []*ast.Option
+	for {
+		o, err := p.readOption()
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		p.addSpan(o.Position.Offset, &o.Comments)
+		opts = append(opts, o)
+		// next should be a comma or ]
+		tok := p.next()
+		if tok.err != nil {
+			return nil, 0, 0, tok.err
+		}
+		switch tok.value {
+		case ",":
+			continue
+		case "]":
+			return opts, open, tok.offset, nil
+		}
+		return nil, 0, 0, p.errorf(`got %q, want "," or "]"`, tok.value)
+	}
+}
+
+// readOption reads an option of the form name = value.
+func (p *parser) readOption() (*ast.Option, *Error) {
+	tok := p.next()
+	if tok.err != nil {
+		return nil, tok.err
+	}
+	pos := tok.astPosition()
+	p.back()
+	name, err := p.readOptionName()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.readToken("="); err != nil {
+		return nil, err
+	}
+	val, err := p.readOptionValue()
+	if err != nil {
+		return nil, err
+	}
+	return &ast.Option{Position: pos, Name: name, Value: val}, nil
+}
+
+// readOptionName reads the name of an option, a dot-separated list of
+// identifiers and parenthesised extension names, e.g. (foo.bar).baz
+func (p *parser) readOptionName() ([]ast.OptionNamePart, *Error) {
+	var parts []ast.OptionNamePart
+	needPart := true
+	for {
+		tok := p.next()
+		if tok.err != nil {
+			return nil, tok.err
+		}
+		v := tok.value
+		switch {
+		case needPart && v == "(":
+			tok := p.next()
+			if tok.err != nil {
+				return nil, tok.err
+			}
+			if !isFullIdent(tok.value) {
+				return nil, p.errorf("got %q, want extension name", tok.value)
+			}
+			parts = append(parts, ast.OptionNamePart{Name: tok.value, IsExtension: true})
+			if err := p.readToken(")"); err != nil {
+				return nil, err
+			}
+			needPart = false
+			continue
+		case needPart:
+		case strings.HasPrefix(v, "."):
+			// the name continues
+			v = v[1:]
+			if v == "" {
+				needPart = true
+				continue
+			}
+		default:
+			p.back()
+			return parts, nil
+		}
+		// v is a dot-separated list of identifiers, followed by a dot if
+		// the name continues with an extension name
+		needPart = strings.HasSuffix(v, ".")
+		for _, id := range strings.Split(strings.TrimSuffix(v, "."), ".") {
+			if !isIdent(id) {
+				return nil, p.errorf("got %q, want option name", tok.value)
+			}
+			parts = append(parts, ast.OptionNamePart{Name: id})
 		}
 	}
-	return rs, nil
 }
 
-func (p *parser) readTagNumber(allowMax bool) (int, *parseError) {
+// readOptionValue reads the value of an option, a scalar or an aggregate.
+func (p *parser) readOptionValue() (*ast.OptionValue, *Error) {
 	tok := p.next()
 	if tok.err != nil {
-		return 0, tok.err
-	}
-	if allowMax && tok.value == "max" {
-		return 1<<29 - 1, nil
-	}
-	n, err := strconv.ParseInt(tok.value, 10, 32)
-	if err != nil {
-		return 0, p.errorf("bad field number %q: %v", tok.value, err)
-	}
-	if n < 1 || n >= 1<<29 {
-		return 0, p.errorf("field number %v out of range", n)
+		return nil, tok.err
 	}
-	if 19000 <= n && n <= 19999 { // TODO: still relevant?
-		return 0, p.errorf("field number %v in reserved range [19000, 19999]", n)
+	if tok.value == "{" || tok.value == "<" {
+		p.back()
+		return p.readAggregate()
 	}
-	return int(n), nil
+	return p.scalarValue(tok)
 }
 
-func (p *parser) readEnum(enum *ast.Enum) *parseError {
-	if err := p.readToken("enum"); err != nil {
-		return err
-	}
-	enum.Position = p.cur.astPosition()
-
-	tok := p.next()
+// scalarValue returns the scalar option value that starts with tok, the
+// current token; a string value continues with any adjacent strings.
+func (p *parser) scalarValue(tok *token) (*ast.OptionValue, *Error) {
 	if tok.err != nil {
-		return tok.err
-	}
-	enum.Name = tok.value // TODO: validate
-
-	if err := p.readToken("{"); err != nil {
-		return err
+		return nil, tok.err
 	}
-
-	// Parse enum values
-	for !p.done {
-		tok := p.next()
-		if tok.err != nil {
-			return tok.err
-		}
-		if tok.value == "}" {
-			// end of enum
-			// A semicolon after an enum is optional.
-			if err := p.readToken(";"); err != nil {
+	v := &ast.OptionValue{Source: tok.value}
+	switch {
+	case isString(tok.value):
+		v.Kind = ast.StringValue
+		v.Unquoted = tok.unquoted
+		for {
+			tok := p.next()
+			if tok.err != nil && tok.err != eof {
+				return nil, tok.err
+			}
+			if tok.err == eof || !isString(tok.value) {
 				p.back()
+				return v, nil
 			}
-			return nil
-		}
-		// TODO: verify tok.value is a valid enum value name.
-		ev := new(ast.EnumValue)
-		enum.Values = append(enum.Values, ev)
-		ev.Position = tok.astPosition()
-		ev.Name = tok.value // TODO: validate
-		ev.Up = enum
-
-		if err := p.readToken("="); err != nil {
-			return err
-		}
-
-		tok = p.next()
-		if tok.err != nil {
-			return tok.err
-		}
-		// TODO: check that tok.value is a valid enum value number.
-		num, err := strconv.ParseInt(tok.value, 10, 32)
-		if err != nil {
-			return p.errorf("bad enum number %q: %v", tok.value, err)
+			v.Source += " " + tok.value
+			v.Unquoted += tok.unquoted
 		}
-		ev.Number = int32(num) // TODO: validate
-
-		if err := p.readToken(";"); err != nil {
-			return err
+	case isIdent(tok.value):
+		v.Kind = ast.IdentifierValue
+	case tok.value == "-inf" || tok.value == "-nan":
+		v.Kind = ast.FloatValue
+	default:
+		if _, err := strconv.ParseInt(tok.value, 0, 64); err == nil {
+			v.Kind = ast.IntValue
+		} else if _, err := strconv.ParseUint(tok.value, 0, 64); err == nil {
+			v.Kind = ast.IntValue
+		} else if _, err := strconv.ParseFloat(tok.value, 64); err == nil {
+			v.Kind = ast.FloatValue
+		} else {
+			return nil, p.errorf("got %q, want option value", tok.value)
 		}
 	}
-
-	return p.errorf("unexpected EOF while parsing enum")
+	return v, nil
 }
 
-func (p *parser) readService(srv *ast.Service) *parseError {
-	if err := p.readToken("service"); err != nil {
-		return err
-	}
-	srv.Position = p.cur.astPosition()
-
+// readAggregate reads an aggregate option value, a message literal in text
+// format delimited by braces or angle brackets, e.g. { a: 1 b: "x" }
+func (p *parser) readAggregate() (*ast.OptionValue, *Error) {
 	tok := p.next()
 	if tok.err != nil {
-		return tok.err
+		return nil, tok.err
 	}
-	srv.Name = tok.value // TODO: validate
-
-	if err := p.readToken("{"); err != nil {
-		return err
+	var end string
+	switch tok.value {
+	case "{":
+		end = "}"
+	case "<":
+		end = ">"
+	default:
+		return nil, p.errorf(`got %q, want "{" or "<"`, tok.value)
 	}
-
-	// Parse methods
-	for !p.done {
+	v := &ast.OptionValue{Kind: ast.AggregateValue}
+	for {
 		tok := p.next()
 		if tok.err != nil {
-			return tok.err
+			return nil, tok.err
 		}
-		switch tok.value {
-		case "}":
-			// end of service
-			return nil
-		case "rpc":
-			// handled below
-		default:
-			return p.errorf(`got %q, want "rpc" or "}"`, tok.value)
+		if tok.value == end {
+			return v, nil
 		}
-
-		tok = p.next()
-		if tok.err != nil {
-			return tok.err
+		f := new(ast.AggregateField)
+		switch {
+		case tok.value == "[":
+			// extension name or Any type URL
+			name := "["
+			for {
+				tok := p.next()
+				if tok.err != nil {
+					return nil, tok.err
+				}
+				name += tok.value
+				if tok.value == "]" {
+					break
+				}
+			}
+			f.Name = name
+		case isIdent(tok.value):
+			f.Name = tok.value
+		default:
+			return nil, p.errorf("got %q, want field name or %q", tok.value, end)
 		}
-		mth := new(ast.Method)
-		srv.Methods = append(srv.Methods, mth)
-		mth.Position = tok.astPosition()
-		mth.Name = tok.value // TODO: validate
-		mth.Up = srv
-
-		if err := p.readToken("("); err != nil {
-			return err
+		colon := true
+		if err := p.readToken(":"); err != nil {
+			p.back()
+			colon = false
 		}
-
 		tok = p.next()
 		if tok.err != nil {
-			return tok.err
-		}
-		mth.InTypeName = tok.value // TODO: validate
-		if err := p.readToken(")"); err != nil {
-			return err
-		}
-		if err := p.readToken("returns"); err != nil {
-			return err
-		}
-		if err := p.readToken("("); err != nil {
-			return err
+			return nil, tok.err
 		}
-		tok = p.next()
-		if tok.err != nil {
-			return tok.err
+		var err *Error
+		switch tok.value {
+		case "{", "<":
+			p.back()
+			f.Value, err = p.readAggregate()
+		case "[":
+			f.Value, err = p.readList()
+		default:
+			if !colon {
+				return nil, p.errorf(`got %q, want ":"`, tok.value)
+			}
+			f.Value, err = p.scalarValue(tok)
 		}
-		mth.OutTypeName = tok.value // TODO: validate
-
-		if err := p.readToken(")"); err != nil {
-			return err
+		if err != nil {
+			return nil, err
 		}
+		v.Fields = append(v.Fields, f)
+		// fields can be separated by a comma or semicolon
 		tok = p.next()
 		if tok.err != nil {
-			return tok.err
+			return nil, tok.err
 		}
-		if tok.value == "{" {
+		if tok.value != "," && tok.value != ";" {
 			p.back()
-			if err := p.readMethodOptions(mth); err != nil {
-				return err
-			}
-		} else if tok.value != ";" {
-			return p.errorf("unexpected %v while parsing Method", tok.value)
 		}
 	}
-
-	return p.errorf("unexpected EOF while parsing service")
 }
 
-func (p *parser) readMethodOptions(mth *ast.Method) *parseError {
-	if err := p.readToken("{"); err != nil {
-		return err
+// readList reads the remainder of a list value, the opening bracket of
+// which has already been read, e.g. 1, 2]
+func (p *parser) readList() (*ast.OptionValue, *Error) {
+	v := &ast.OptionValue{Kind: ast.ListValue}
+	if err := p.readToken("]"); err == nil {
+		return v, nil
 	}
-	for !p.done {
+	p.back()
+	for {
 		tok := p.next()
-		switch tok.value {
-		case "}":
-			// End of Options
-			return nil
-		case "option":
-			//Handled below
-		default:
-			return p.errorf(`got %q, want "option" or "}"`, tok.value)
-		}
-		if err := p.readToken("("); err != nil {
-			return err
-		}
-		tok = p.next()
 		if tok.err != nil {
-			return tok.err
+			return nil, tok.err
 		}
-		key := tok.value
-		if err := p.readToken(")"); err != nil {
-			return err
+		var e *ast.OptionValue
+		var err *Error
+		if tok.value == "{" || tok.value == "<" {
+			p.back()
+			e, err = p.readAggregate()
+		} else {
+			e, err = p.scalarValue(tok)
 		}
-		if err := p.readToken("="); err != nil {
-			return err
+		if err != nil {
+			return nil, err
 		}
+		v.Values = append(v.Values, e)
 		tok = p.next()
 		if tok.err != nil {
-			return tok.err
+			return nil, tok.err
 		}
-		val := tok.value
-		if err := p.readToken(";"); err != nil {
-			return err
+		switch tok.value {
+		case ",":
+			continue
+		case "]":
+			return v, nil
 		}
-		mth.Options = append(mth.Options, [2]string{key, val})
+		return nil, p.errorf(`got %q, want "," or "]"`, tok.value)
 	}
-	return nil
 }
 
-func (p *parser) readExtension(ext *ast.Extension) *parseError {
-	if err := p.readToken("extend"); err != nil {
-		return err
-	}
-	ext.Position = p.cur.astPosition()
-
-	tok := p.next()
-	if tok.err != nil {
-		return tok.err
-	}
-	ext.Extendee = tok.value // checked during resolution
-
-	if err := p.readToken("{"); err != nil {
-		return err
-	}
-
-	for !p.done {
-		tok := p.next()
-		if tok.err != nil {
-			return tok.err
-		}
-		if tok.value == "}" {
-			// end of extension
-			return nil
-		}
-		p.back()
-		field := new(ast.Field)
-		ext.Fields = append(ext.Fields, field)
-		field.Up = ext // p.readFile uses this
-		if err := p.readField(field); err != nil {
-			return err
-		}
+// optionBool returns the value of an option that must be true or false.
+func (p *parser) optionBool(o *ast.Option) (bool, *Error) {
+	switch o.Value.String() {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	default:
+		return false, p.errorf(`got %q, want "true" or "false"`, o.Value.String())
 	}
-	return p.errorf("unexpected EOF while parsing extension")
 }
 
-func (p *parser) readString() (*token, *parseError) {
+func (p *parser) readString() (*token, *Error) {
 	tok := p.next()
 	if tok.err != nil {
 		return nil, tok.err
 	}
-	if tok.value[0] != '"' {
+	if !isString(tok.value) {
 		return nil, p.errorf("got %q, want string", tok.value)
 	}
 	return tok, nil
 }
 
-func (p *parser) readBool() (bool, *parseError) {
+func (p *parser) readBool() (bool, *Error) {
 	tok := p.next()
 	if tok.err != nil {
 		return false, tok.err
@@ -1097,7 +1641,7 @@ func (p *parser) readBool() (bool, *parseError) {
 	}
 }
 
-func (p *parser) readToken(want string) *parseError {
+func (p *parser) readToken(want string) *Error {
 	tok := p.next()
 	if tok.err != nil {
 		return tok.err
@@ -1121,6 +1665,14 @@ func (p *parser) back() {
 	}
 }
 
+// end returns the offset just past the last token read, and not backed off.
+func (p *parser) end() int {
+	if p.backed {
+		return p.prevEnd
+	}
+	return p.cur.offset + len(p.cur.value)
+}
+
 // Advances the parser and returns the new current token.
 func (p *parser) next() *token {
 	if p.backed || p.done {
@@ -1145,13 +1697,22 @@ func (p *parser) advance() {
 	}
 
 	// Start of non-whitespace
+	p.prevEnd = p.cur.offset + len(p.cur.value)
 	p.cur.err = nil
 	p.cur.offset, p.cur.line = p.offset, p.line
 	switch p.s[0] {
 	// TODO: more cases, like punctuation.
-	case ';', '{', '}', '=', '[', ']', ',', '<', '>', '(', ')':
+	case ';', '{', '}', '=', '[', ']', ',', '<', '>', '(', ')', ':', '/':
 		// Single symbol
 		p.cur.value, p.s = p.s[:1], p.s[1:]
+		switch p.cur.value {
+		case "{":
+			p.depth++
+		case "}":
+			if p.depth > 0 {
+				p.depth--
+			}
+		}
 	case '"', '\'':
 		// Quoted string
 		i := 1
@@ -1163,14 +1724,14 @@ func (p *parser) advance() {
 			i++
 		}
 		if i >= len(p.s) {
+			p.cur.value, p.s = p.s, ""
+			p.offset += len(p.cur.value)
 			p.errorf("encountered EOF inside string")
 			return
 		}
 		i++
 		p.cur.value, p.s = p.s[:i], p.s[i:]
-		// TODO: This doesn't work for single quote strings;
-		// quotes will be mangled.
-		unq, err := strconv.Unquote(p.cur.value)
+		unq, err := unquote(p.cur.value)
 		if err != nil {
 			p.errorf("invalid quoted string [%s]: %v", p.cur.value, err)
 		}
@@ -1181,7 +1742,9 @@ func (p *parser) advance() {
 			i++
 		}
 		if i == 0 {
-			p.errorf("unexpected byte 0x%02x (%q)", p.s[0], string(p.s[:1]))
+			p.cur.value, p.s = p.s[:1], p.s[1:]
+			p.offset++
+			p.errorf("unexpected byte 0x%02x (%q)", p.cur.value[0], p.cur.value)
 			return
 		}
 		p.cur.value, p.s = p.s[:i], p.s[i:]
@@ -1208,6 +1771,8 @@ func (p *parser) skipWhitespaceAndComments() {
 				i++
 			}
 			c.text = p.s[si:i]
+			c.src = strings.TrimRightFunc(p.s[si-2:i], unicode.IsSpace)
+			c.end = p.offset + i
 			p.comments = append(p.comments, c)
 			if i < len(p.s) {
 				// end of line; keep going
@@ -1232,10 +1797,16 @@ func (p *parser) skipWhitespaceAndComments() {
 				i++
 			}
 			if !found {
+				p.cur.value = ""
+				p.cur.line, p.cur.offset = c.line, c.offset
+				p.offset += len(p.s)
+				p.s = ""
 				p.errorf("encountered EOF inside multi-line comment")
 				return
 			}
 			c.text = p.s[si:i]
+			c.src = p.s[si-2 : i+len("*/")]
+			c.end = p.offset + i + len("*/")
 			p.comments = append(p.comments, c)
 
 			//
@@ -1251,18 +1822,136 @@ func (p *parser) skipWhitespaceAndComments() {
 	}
 }
 
-func (p *parser) errorf(format string, a ...interface{}) *parseError {
-	pe := &parseError{
-		message:  fmt.Sprintf(format, a...),
-		filename: p.filename,
-		line:     p.cur.line,
-		offset:   p.cur.offset,
+func (p *parser) errorf(format string, a ...interface{}) *Error {
+	pe := &Error{
+		Filename: p.filename,
+		Pos:      p.cur.astPosition(),
+		End: ast.Position{
+			Line:   p.cur.line + strings.Count(p.cur.value, "\n"),
+			Offset: p.cur.offset + len(p.cur.value),
+		},
+		Code: ErrSyntax,
+		Msg:  fmt.Sprintf(format, a...),
 	}
 	p.cur.err = pe
 	p.done = true
 	return pe
 }
 
+// recover returns err, unless the parser recovers from errors; an EOF error
+// is first made an unexpected EOF at the end of the input. If the parser
+// recovers from errors, recover records err and skips to the end of the
+// statement in error, returning nil. The statement ends with the next ";" or
+// "}" that leaves depth braces open, which is consumed, or with the "}" that
+// closes the block at depth, which is not.
+func (p *parser) recover(err *Error, depth int) *Error {
+	if err == eof {
+		if p.eofReported {
+			p.cur.err = eof
+			p.back()
+			return nil
+		}
+		// report the end of the input
+		p.cur.value, p.cur.line, p.cur.offset = "", p.line, p.offset
+		err = p.errorf("unexpected EOF")
+		if !p.allErrors {
+			return err
+		}
+		p.eofReported = true
+		p.errs = append(p.errs, err)
+		p.cur.err = eof
+		p.back()
+		return nil
+	}
+	if !p.allErrors {
+		return err
+	}
+	p.errs = append(p.errs, err)
+	for {
+		tok := &p.cur
+		switch {
+		case (tok.value == ";" || tok.value == "}") && p.depth == depth:
+			tok.err = nil
+			p.done, p.backed = false, false
+			return nil
+		case tok.value == "}" && p.depth < depth:
+			p.back()
+			return nil
+		}
+		tok.err = nil
+		p.done, p.backed = false, false
+		tok = p.next()
+		if tok.err == eof {
+			p.back()
+			return nil
+		}
+		if tok.err != nil {
+			// an error reading the token
+			p.errs = append(p.errs, tok.err)
+		}
+	}
+}
+
+// unquote returns the value of a string literal, which is quoted with either
+// double or single quotes.
+func unquote(s string) (string, error) {
+	if s[0] == '\'' {
+		// rewrite as a double-quoted literal
+		body := s[1 : len(s)-1]
+		var b strings.Builder
+		b.WriteByte('"')
+		for i := 0; i < len(body); i++ {
+			switch c := body[i]; {
+			case c == '\\' && i+1 < len(body):
+				i++
+				if body[i] != '\'' {
+					b.WriteByte(c)
+				}
+				b.WriteByte(body[i])
+			case c == '"':
+				b.WriteString(`\"`)
+			default:
+				b.WriteByte(c)
+			}
+		}
+		b.WriteByte('"')
+		s = b.String()
+	}
+	return strconv.Unquote(s)
+}
+
+func isString(s string) bool {
+	return s != "" && (s[0] == '"' || s[0] == '\'')
+}
+
+// isIdent reports whether s is an identifier, e.g. foo_bar
+func isIdent(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', c == '_':
+		case '0' <= c && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// isFullIdent reports whether s is a dot-separated list of identifiers,
+// optionally with a leading dot, e.g. .foo.bar
+func isFullIdent(s string) bool {
+	for _, id := range strings.Split(strings.TrimPrefix(s, "."), ".") {
+		if !isIdent(id) {
+			return false
+		}
+	}
+	return true
+}
+
 func isWhitespace(c byte) bool {
 	// TODO: do more accurately
 	return unicode.IsSpace(rune(c))
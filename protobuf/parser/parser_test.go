@@ -4,6 +4,11 @@
 package parser
 
 import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/golang/protobuf/proto"
@@ -65,12 +70,18 @@ var parseTests = []parseTest{
 	{
 		"MessageOptions",
 		"message TestMessage {\n option (map_entry) = true;\n}\n",
-		`message_type { name: "TestMessage" }`,
+		`message_type { name: "TestMessage" options { uninterpreted_option { name { name_part: "map_entry" is_extension: true } identifier_value: "true" } } }`,
 	},
 	{
 		"ReservedFields",
-		"message TestMessage {\n  reserved 2, 15, 9 to 11;\nreserved \"foo\", \"bar\";\n}\n",
-		`message_type { name: "TestMessage" }`,
+		"message TestMessage {\n  reserved 2, 15, 9 to 11, 100 to max;\nreserved \"foo\", 'bar';\n}\n",
+		`message_type { name: "TestMessage" ` +
+			`  reserved_range { start:2   end:3         }` +
+			`  reserved_range { start:15  end:16        }` +
+			`  reserved_range { start:9   end:12        }` +
+			`  reserved_range { start:100 end:536870912 }` +
+			`  reserved_name: "foo" reserved_name: "bar"` +
+			`}`,
 	},
 	{
 		"ImplicitSyntaxIdentifier",
@@ -148,10 +159,10 @@ var parseTests = []parseTest{
 		  required double foo = 1 [default= inf ];
 		  required double foo = 1 [default=-inf ];
 		  required double foo = 1 [default= nan ];
-		  // TODO: uncomment these when the string parser handles them.
-		  //required string foo = 1 [default='13\\001'];
-		  //required string foo = 1 [default='a' "b"
-		  //"c"];
+		  required string foo = 1 [default='13\\001'];
+		  required string foo = 1 [default='a' "b"
+		  "c"];
+		  // TODO: uncomment these when bytes defaults are escaped.
 		  //required bytes  foo = 1 [default='14\\002'];
 		  //required bytes  foo = 1 [default='a' "b"
 		  //'c'];
@@ -183,10 +194,10 @@ var parseTests = []parseTest{
 		  field { type:TYPE_DOUBLE  default_value:"inf"       ` + fieldDefaultsEtc + ` }
 		  field { type:TYPE_DOUBLE  default_value:"-inf"      ` + fieldDefaultsEtc + ` }
 		  field { type:TYPE_DOUBLE  default_value:"nan"       ` + fieldDefaultsEtc + ` }
+		  field { type:TYPE_STRING  default_value:"13\\001"   ` + fieldDefaultsEtc + ` }
+		  field { type:TYPE_STRING  default_value:"abc"       ` + fieldDefaultsEtc + ` }
 		  ` +
 			/*
-			  field { type:TYPE_STRING  default_value:"13\\001"   ` + fieldDefaultsEtc + ` }
-			  field { type:TYPE_STRING  default_value:"abc"       ` + fieldDefaultsEtc + ` }
 			  field { type:TYPE_BYTES   default_value:"14\\\\002" ` + fieldDefaultsEtc + ` }
 			*/
 			`
@@ -213,7 +224,46 @@ var parseTests = []parseTest{
 		}
 		`,
 	},
-	// TODO: FieldOptions
+	{
+		"FieldOptions",
+		"message TestMessage {\n  optional int32 foo = 1 [deprecated=true, json_name=\"Foo\", (bar).baz = -2, (.a.b) = { x: 1.5 y <> z: [1, 2] }];\n}\n",
+		`message_type {
+		   name: "TestMessage"
+		   field {
+		     name:"foo" label:LABEL_OPTIONAL type:TYPE_INT32 number:1
+		     options {
+		       uninterpreted_option { name { name_part: "json_name" is_extension: false } string_value: "Foo" }
+		       uninterpreted_option { name { name_part: "bar" is_extension: true } name { name_part: "baz" is_extension: false } negative_int_value: -2 }
+		       uninterpreted_option { name { name_part: ".a.b" is_extension: true } aggregate_value: "x: 1.5 y {} z: [1, 2]" }
+		     }
+		   }
+		 }`,
+	},
+	{
+		"AggregateOptions",
+		`option (foo) = { a: 1 b: "x" c { d: inf } [ext.e]: 'y' 'z', f: -inf };
+		 option (bar).(baz) = 0x10;
+		 message TestMessage {
+		   option (m) = < a: true >;
+		   oneof o {
+		     option (n) = 1e3;
+		     int32 foo = 1;
+		   }
+		 }`,
+		`options {
+		   uninterpreted_option { name { name_part: "foo" is_extension: true } aggregate_value: "a: 1 b: \"x\" c { d: inf } [ext.e]: 'y' 'z' f: -inf" }
+		   uninterpreted_option { name { name_part: "bar" is_extension: true } name { name_part: "baz" is_extension: true } positive_int_value: 16 }
+		 }
+		 message_type {
+		   name: "TestMessage"
+		   field { name:"foo" label:LABEL_OPTIONAL type:TYPE_INT32 number:1 oneof_index:0 }
+		   oneof_decl {
+		     name: "o"
+		     options { uninterpreted_option { name { name_part: "n" is_extension: true } double_value: 1000 } }
+		   }
+		   options { uninterpreted_option { name { name_part: "m" is_extension: true } aggregate_value: "a: true" } }
+		 }`,
+	},
 	{
 		"Oneof",
 		"message TestMessage {\n  oneof foo {\n    int32 a = 1;\n    string b = 2;\n    TestMessage c = 3;\n    group D = 4 { optional int32 i = 5; }\n  }\n}\n",
@@ -329,24 +379,59 @@ var parseTests = []parseTest{
 			`message_type{name:"TestMessage"}`,
 	},
 	{
-		"OptionalOptionalLabelProto3",
-		"syntax = \"proto3\";\nmessage TestMessage {\n  int32 foo = 1;\n  optional int32 bar = 2;\n}\n",
-		`syntax: "proto3" message_type { name: "TestMessage" ` +
-			`  field { name:"foo" label:LABEL_OPTIONAL type:TYPE_INT32 number:1 }` +
-			`  field { name:"bar" label:LABEL_OPTIONAL type:TYPE_INT32 number:2 }` +
-			`}`,
+		"Editions",
+		"edition = \"2023\";\noption features.field_presence = IMPLICIT;\nmessage TestMessage {\n  int32 foo = 1 [features.field_presence = EXPLICIT];\n  reserved bar, baz;\n}\n",
+		`syntax: "editions"
+		 options { uninterpreted_option { name { name_part: "features" is_extension: false } name { name_part: "field_presence" is_extension: false } identifier_value: "IMPLICIT" } }
+		 message_type {
+		   name: "TestMessage"
+		   field {
+		     name:"foo" label:LABEL_OPTIONAL type:TYPE_INT32 number:1
+		     options { uninterpreted_option { name { name_part: "features" is_extension: false } name { name_part: "field_presence" is_extension: false } identifier_value: "EXPLICIT" } }
+		   }
+		   reserved_name: "bar" reserved_name: "baz"
+		 }`,
 	},
 	{
 		"EnumValues",
 		"enum TestEnum {\n  FOO = 13;\n  BAR = -10;\n  BAZ = 500;\n}\n",
 		`enum_type { name: "TestEnum" value { name:"FOO" number:13 } value { name:"BAR" number:-10 } value { name:"BAZ" number:500 } }`,
 	},
+	{
+		"EnumReservedAndOptions",
+		"enum TestEnum {\n  option allow_alias = true;\n  FOO = 0;\n  BAR = 0 [deprecated = true];\n  reserved -2, 5 to 7, 10 to max;\n  reserved \"BAZ\";\n}\n",
+		`enum_type {
+		   name: "TestEnum"
+		   value { name:"FOO" number:0 }
+		   value { name:"BAR" number:0 options { uninterpreted_option { name { name_part: "deprecated" is_extension: false } identifier_value: "true" } } }
+		   reserved_range { start:-2 end:-2 }
+		   reserved_range { start:5 end:7 }
+		   reserved_range { start:10 end:2147483647 }
+		   reserved_name: "BAZ"
+		   options { uninterpreted_option { name { name_part: "allow_alias" is_extension: false } identifier_value: "true" } }
+		 }`,
+	},
 	{
 		"SimpleService",
 		"service TestService {\n  rpc Foo(In) returns (Out);\n}\n message In{} message Out{}",
 		`service { name: "TestService" method { name:"Foo" input_type:".In" output_type:".Out" } }` +
 			`message_type:{name:"In"} message_type:{name:"Out"}`,
 	},
+	{
+		"StreamingService",
+		"service TestService {\n  option (s) = \"x\";\n  rpc Foo(stream In) returns (Out);\n  rpc Bar(In) returns (stream Out) { option deprecated = true; }\n  rpc Baz(stream stream) returns (stream);\n}\n message In{} message Out{} message stream{}",
+		`service {
+		   name: "TestService"
+		   method { name:"Foo" input_type:".In" output_type:".Out" client_streaming: true }
+		   method {
+		     name:"Bar" input_type:".In" output_type:".Out" server_streaming: true
+		     options { uninterpreted_option { name { name_part: "deprecated" is_extension: false } identifier_value: "true" } }
+		   }
+		   method { name:"Baz" input_type:".stream" output_type:".stream" client_streaming: true }
+		   options { uninterpreted_option { name { name_part: "s" is_extension: true } string_value: "x" } }
+		 }` +
+			`message_type:{name:"In"} message_type:{name:"Out"} message_type:{name:"stream"}`,
+	},
 	{
 		"ParseImport",
 		"import \"foo/bar/baz.proto\";\n",
@@ -372,6 +457,11 @@ var parseTests = []parseTest{
 		"import \"foo.proto\";\nimport public \"bar.proto\";\nimport \"baz.proto\";\nimport public \"qux.proto\";\n",
 		`dependency: "foo.proto" dependency: "bar.proto" dependency: "baz.proto" dependency: "qux.proto" public_dependency: 1 public_dependency: 3`,
 	},
+	{
+		"ParseWeakImports",
+		"import \"foo.proto\";\nimport weak \"bar.proto\";\n",
+		`dependency: "foo.proto" dependency: "bar.proto" weak_dependency: 1`,
+	},
 }
 
 func TestParsing(t *testing.T) {
@@ -380,3 +470,380 @@ func TestParsing(t *testing.T) {
 		tryParse(t, pt.input, pt.expected)
 	}
 }
+
+// generateErrorTests are inputs that parse, but for which a
+// FileDescriptorSet cannot be generated.
+var generateErrorTests = []parseTest{
+	{
+		"Proto3Optional",
+		"syntax = \"proto3\";\nmessage TestMessage {\n  int32 foo = 1;\n  optional int32 bar = 2;\n}\n",
+		"proto3 optional field TestMessage.bar is not supported",
+	},
+}
+
+func TestGenerateErrors(t *testing.T) {
+	for _, pt := range generateErrorTests {
+		p := newParser("-", pt.input)
+		f := new(ast.File)
+		if pe := p.readFile(f); pe != nil {
+			t.Errorf("[ %v ] failed parsing input: %v", pt.name, pe)
+			continue
+		}
+		fset := &ast.FileSet{Files: []*ast.File{f}}
+		if err := resolveSymbols(fset); err != nil {
+			t.Errorf("[ %v ] resolving symbols: %v", pt.name, err)
+			continue
+		}
+		_, err := gendesc.Generate(fset)
+		if err == nil {
+			t.Errorf("[ %v ] unexpected success", pt.name)
+			continue
+		}
+		if got := err.Error(); got != pt.expected {
+			t.Errorf("[ %v ] got error %q, want %q", pt.name, got, pt.expected)
+		}
+	}
+}
+
+var parseErrorTests = []parseTest{
+	{
+		"RequiredProto3",
+		"syntax = \"proto3\";\nmessage TestMessage {\n  required int32 foo = 1;\n}\n",
+		`-:3: label "required" is not allowed in proto3 files`,
+	},
+	{
+		"OptionalEditions",
+		"edition = \"2023\";\nmessage TestMessage {\n  optional int32 foo = 1;\n}\n",
+		`-:3: label "optional" is not allowed in editions files`,
+	},
+	{
+		"UnsupportedEdition",
+		"edition = \"1999\";\n",
+		`-:1.10: unsupported edition "1999"`,
+	},
+	{
+		"ReservedStringNameEditions",
+		"edition = \"2023\";\nenum TestEnum {\n  reserved \"FOO\";\n}\n",
+		`-:3: bad reserved name "FOO"`,
+	},
+	{
+		"BadOptionName",
+		"option 1foo = 1;\n",
+		`-:1.7: got "1foo", want option name`,
+	},
+	{
+		"BadAggregateField",
+		"option (foo) = { a: 1 = 2 };\n",
+		`-:1.22: got "=", want field name or "}"`,
+	},
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, pt := range parseErrorTests {
+		p := newParser("-", pt.input)
+		f := new(ast.File)
+		pe := p.readFile(f)
+		if pe == nil {
+			t.Errorf("[ %v ] unexpected success", pt.name)
+			continue
+		}
+		if got := pe.Error(); got != pt.expected {
+			t.Errorf("[ %v ] got error %q, want %q", pt.name, got, pt.expected)
+		}
+	}
+}
+
+// allErrorsTests are inputs parsed in AllErrors mode; the expected output is
+// the errors reported, one per line, with their codes and the offsets of
+// their start and end.
+var allErrorsTests = []parseTest{
+	{
+		"SyntaxErrors",
+		`syntax = "proto3";
+message A {
+  int32 a = x;
+  int32 b = 2;
+  string c = 3 [;
+  bool d = 4;
+}
+enum E {
+  FOO = bar;
+  BAZ = 1;
+}
+service S {
+  rpc M(A) returns A;
+  rpc N(A) returns (A);
+}
+`,
+		`syntax: -:3: bad field number "x": strconv.ParseInt: parsing "x": invalid syntax (43-44)
+syntax: -:5: got ";", want option name (77-78)
+syntax: -:9: bad enum number "bar": strconv.ParseInt: parsing "bar": invalid syntax (112-115)
+syntax: -:13: got "A", want "(" (161-162)`,
+	},
+	{
+		"NestedBlocks",
+		`message A {
+  message B {
+    int32 a = ;
+  }
+  int32 b = 2
+}
+message C {
+  oneof o {
+    int32 c = 1 2;
+    int32 d = 2;
+  }
+}
+`,
+		`syntax: -:3: bad field number ";": strconv.ParseInt: parsing ";": invalid syntax (40-41)
+syntax: -:6: got "}", want ";" (60-61)
+syntax: -:9: got "2", want ";" (102-103)`,
+	},
+	{
+		"TopLevel",
+		`foo bar;
+message A { int32 a = 1; }
+{ nonsense }
+$
+message B { int32 b = 1; }
+`,
+		`syntax: -:1.0: unknown top-level thing "foo" (0-3)
+syntax: -:3: unknown top-level thing "{" (36-37)
+syntax: -:4: unexpected byte 0x24 ("$") (49-50)`,
+	},
+	{
+		"UnexpectedEOF",
+		`message A {
+  int32 a = 1;
+  message B {
+    int32 b = 2
+`,
+		`syntax: -:5: unexpected EOF (57-57)`,
+	},
+	{
+		"Resolution",
+		`message A {
+  B b = 1;
+  map<float, A> m = 2;
+}
+enum E { X = 0; }
+extend E { int32 x = 100; }
+extend F { int32 y = 101; }
+service S {
+  rpc M(A) returns (C);
+}
+`,
+		`unresolved: -:2: failed to resolve name "B" (14-15)
+invalid: -:3: invalid map key type "float" (29-34)
+unresolved: -:9: failed to resolve name "C" (154-155)
+invalid: -:6: extendee "E" resolved to non-message *ast.Enum (73-74)
+unresolved: -:7: failed to resolve name "F" (101-102)`,
+	},
+}
+
+func TestAllErrors(t *testing.T) {
+	for _, pt := range allErrorsTests {
+		p := newParser("-", pt.input)
+		p.allErrors = true
+		f := &ast.File{Name: "-"}
+		if pe := p.readFile(f); pe != nil {
+			t.Errorf("[ %v ] unexpected error: %v", pt.name, pe)
+			continue
+		}
+		errs := append(p.errs, resolveSymbols(&ast.FileSet{Files: []*ast.File{f}})...)
+		var lines []string
+		for _, e := range errs {
+			lines = append(lines, fmt.Sprintf("%v: %v (%d-%d)", e.Code, e, e.Pos.Offset, e.End.Offset))
+		}
+		if got := strings.Join(lines, "\n"); got != pt.expected {
+			t.Errorf("[ %v ] got errors:\n%v\nwant:\n%v", pt.name, got, pt.expected)
+		}
+	}
+}
+
+func TestAllErrorsPartial(t *testing.T) {
+	p := newParser("-", `message A {
+  int32 a = 1;
+  int32 b = ;
+  C c = 3;
+  int32 d = 4;
+}
+enum E {
+  X = 0;
+  Y = ;
+  Z = 2;
+}
+`)
+	p.allErrors = true
+	f := new(ast.File)
+	if pe := p.readFile(f); pe != nil {
+		t.Fatalf("unexpected error: %v", pe)
+	}
+	if n := len(p.errs); n != 2 {
+		t.Errorf("got %d syntax errors, want 2: %v", n, p.errs)
+	}
+	if errs := resolveSymbols(&ast.FileSet{Files: []*ast.File{f}}); len(errs) != 1 {
+		t.Errorf("got resolution errors %v, want 1", errs)
+	}
+
+	var fields []string
+	for _, fld := range f.Messages[0].Fields {
+		fields = append(fields, fld.Name)
+	}
+	if got, want := strings.Join(fields, " "), "a c d"; got != want {
+		t.Errorf("got fields %q, want %q", got, want)
+	}
+	var values []string
+	for _, v := range f.Enums[0].Values {
+		values = append(values, v.Name)
+	}
+	if got, want := strings.Join(values, " "), "X Z"; got != want {
+		t.Errorf("got enum values %q, want %q", got, want)
+	}
+}
+
+func TestAllErrorsPartialService(t *testing.T) {
+	p := newParser("-", `service S {
+  rpc }
+message C {
+  int32 c = 1;
+}
+`)
+	p.allErrors = true
+	f := new(ast.File)
+	if pe := p.readFile(f); pe != nil {
+		t.Fatalf("unexpected error: %v", pe)
+	}
+	if n := len(p.errs); n != 1 {
+		t.Errorf("got %d syntax errors, want 1: %v", n, p.errs)
+	}
+	if n := len(f.Services); n != 1 || len(f.Services[0].Methods) != 0 {
+		t.Errorf("got services %v, want S with no methods", f.Services)
+	}
+	if len(f.Messages) != 1 || f.Messages[0].Name != "C" {
+		t.Fatalf("got messages %v, want C", f.Messages)
+	}
+	if n := len(f.Messages[0].Fields); n != 1 {
+		t.Errorf("got %d fields in C, want 1", n)
+	}
+}
+
+func TestParseFilesModeAllErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"a.proto": "import \"b.proto\";\nimport \"missing.proto\";\nmessage A { B b = 1; int32 x = ; }\n",
+		"b.proto": "message B { int32 y = 1 }\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fset, err := ParseFilesMode([]string{"a.proto"}, []string{dir}, AllErrors)
+	if fset == nil {
+		t.Fatalf("got nil FileSet")
+	}
+	errs, ok := err.(ErrorList)
+	if !ok {
+		t.Fatalf("got error %v (%T), want ErrorList", err, err)
+	}
+	var lines []string
+	for _, e := range errs {
+		lines = append(lines, fmt.Sprintf("%v: %v (%d-%d)", e.Code, e, e.Pos.Offset, e.End.Offset))
+	}
+	want := fmt.Sprintf(`file: a.proto:2: file not found in import paths: missing.proto, paths [%v] (25-40)
+syntax: a.proto:3: bad field number ";": strconv.ParseInt: parsing ";": invalid syntax (73-74)
+syntax: b.proto:1.24: got "}", want ";" (24-25)`, dir)
+	if got := strings.Join(lines, "\n"); got != want {
+		t.Errorf("got errors:\n%v\nwant:\n%v", got, want)
+	}
+	if n := len(fset.Files[0].Messages[0].Fields); n != 1 {
+		t.Errorf("got %d fields in A, want 1", n)
+	}
+
+	// the default mode reports the first error only
+	if _, err := ParseFiles([]string{"a.proto"}, []string{dir}); err == nil {
+		t.Errorf("ParseFiles: unexpected success")
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	// imports are not read, and names are not resolved
+	src := "import \"missing.proto\";\nmessage A { B b = 1; }\n"
+	f, err := ParseFile("a.proto", []byte(src), 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := f.Messages[0].Fields[0].TypeName; got != "B" {
+		t.Errorf("got type name %q, want %q", got, "B")
+	}
+
+	_, err = ParseFile("a.proto", []byte("message A {\n  int32 a = 1;\n"), 0)
+	if got, want := fmt.Sprint(err), "a.proto:3: unexpected EOF"; got != want {
+		t.Errorf("got error %q, want %q", got, want)
+	}
+}
+
+func TestComments(t *testing.T) {
+	src := `// detached
+
+// leading
+message A { // first
+  int32 a = 1 /* inner */; // trailing
+  int32 b = 2 [
+    // between
+    packed = true, // packed
+    (foo) = 1
+  ];
+
+  // end
+}
+`
+	f, err := ParseFile("a.proto", []byte(src), 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	text := func(c *ast.Comment) string {
+		if c == nil {
+			return "<nil>"
+		}
+		return strings.Join(c.Source, "|")
+	}
+
+	m := f.Messages[0]
+	if got := len(m.Comments.Detached); got != 1 {
+		t.Fatalf("got %d detached comments, want 1", got)
+	}
+	fld, fld2 := m.Fields[0], m.Fields[1]
+	tests := []struct {
+		what      string
+		got, want string
+	}{
+		{"message detached", text(m.Comments.Detached[0]), "// detached"},
+		{"message leading", text(m.Comments.Leading), "// leading"},
+		{"message trailing", text(m.Comments.Trailing), "<nil>"},
+		{"message open", text(m.Comments.Open), "// first"},
+		{"field leading", text(fld.Comments.Leading), "<nil>"},
+		{"field trailing", text(fld.Comments.Trailing), "// trailing"},
+		{"field inner", fmt.Sprint(len(fld.Comments.Inner)), "1"},
+		{"packed leading", text(fld2.PackedComments.Leading), "// between"},
+		{"packed trailing", text(fld2.PackedComments.Trailing), "// packed"},
+		{"option comments", fmt.Sprint(fld2.Options[0].Comments.IsEmpty()), "true"},
+		{"field2 inner", fmt.Sprint(len(fld2.Comments.Inner)), "0"},
+		{"message end", text(m.EndComments.Leading), "// end"},
+		{"message end blank", fmt.Sprint(m.EndComments.Blank), "true"},
+		{"field blank", fmt.Sprint(fld.Comments.Blank), "false"},
+		{"all comments", fmt.Sprint(len(f.Comments)), "8"},
+	}
+	for _, test := range tests {
+		if test.got != test.want {
+			t.Errorf("%v: got %q, want %q", test.what, test.got, test.want)
+		}
+	}
+}
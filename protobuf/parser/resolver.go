@@ -13,16 +13,16 @@ import (
 	"myitcv.io/protobuf/ast"
 )
 
-func resolveSymbols(fset *ast.FileSet) error {
+// resolveSymbols resolves the type names in fset, returning the errors found
+// in the order in which they were found.
+func resolveSymbols(fset *ast.FileSet) ErrorList {
 	r := &resolver{fset: fset}
 	s := new(scope)
 	s.push(fset)
 	for _, f := range fset.Files {
-		if err := r.resolveFile(s, f); err != nil {
-			return err
-		}
+		r.resolveFile(s, f)
 	}
-	return nil
+	return r.errs
 }
 
 // A scope represents the context of the traversal.
@@ -129,36 +129,42 @@ func (s *scope) fullName() string {
 
 type resolver struct {
 	fset *ast.FileSet
+	file *ast.File // the file being resolved
+	errs ErrorList
+}
+
+// errorf records an error in resolving name, which is at pos.
+func (r *resolver) errorf(pos ast.Position, name string, code ErrorCode, format string, args ...interface{}) {
+	r.errs = append(r.errs, &Error{
+		Filename: r.file.Name,
+		Pos:      pos,
+		End:      ast.Position{Line: pos.Line, Offset: pos.Offset + len(name)},
+		Code:     code,
+		Msg:      fmt.Sprintf(format, args...),
+	})
 }
 
-func (r *resolver) resolveFile(s *scope, f *ast.File) error {
+func (r *resolver) resolveFile(s *scope, f *ast.File) {
+	r.file = f
 	fs := s.dup()
 	fs.push(f)
 
 	// Resolve messages.
 	for _, msg := range f.Messages {
-		if err := r.resolveMessage(fs, msg); err != nil {
-			return fmt.Errorf("(%v): %v", msg.Name, err)
-		}
+		r.resolveMessage(fs, msg)
 	}
 	// Resolve messages in services.
 	for _, srv := range f.Services {
 		for _, mth := range srv.Methods {
-			if err := r.resolveMethod(fs, mth); err != nil {
-				return fmt.Errorf("(%s.%s): %v", srv.Name, mth.Name, err)
-			}
+			r.resolveMethod(fs, mth)
 		}
 	}
 	// Resolve types in extensions.
 	for _, ext := range f.Extensions {
-		if err := r.resolveExtension(fs, ext); err != nil {
-			return fmt.Errorf("(ext %s): %v", ext.Extendee, err)
-		}
+		r.resolveExtension(fs, ext)
 	}
 
 	// TODO: resolve other types.
-
-	return nil
 }
 
 var fieldTypeInverseMap = make(map[string]ast.FieldType)
@@ -184,38 +190,39 @@ var validMapKeyTypes = map[string]bool{
 	"sint64":   true,
 }
 
-func (r *resolver) resolveMessage(s *scope, msg *ast.Message) error {
+func (r *resolver) resolveMessage(s *scope, msg *ast.Message) {
 	ms := s.dup()
 	ms.push(msg)
 
 	// Resolve fields.
 	for _, field := range msg.Fields {
-		ft, ok := r.resolveFieldTypeName(ms, field.TypeName)
-		if !ok {
-			return fmt.Errorf("failed to resolve name %q", field.TypeName)
-		}
-		field.Type = ft
+		r.resolveField(ms, field)
 
 		if ktn := field.KeyTypeName; ktn != "" {
 			if !validMapKeyTypes[ktn] {
-				return fmt.Errorf("invalid map key type %q", ktn)
+				r.errorf(field.KeyTypeNamePosition, ktn, ErrInvalid, "invalid map key type %q", ktn)
+				continue
 			}
 			field.KeyType = fieldTypeInverseMap[ktn]
 		}
 	}
 	// Resolve types in extensions.
 	for _, ext := range msg.Extensions {
-		if err := r.resolveExtension(ms, ext); err != nil {
-			return err
-		}
+		r.resolveExtension(ms, ext)
 	}
 	// Resolve nested types.
 	for _, nmsg := range msg.Messages {
-		if err := r.resolveMessage(ms, nmsg); err != nil {
-			return err
-		}
+		r.resolveMessage(ms, nmsg)
 	}
-	return nil
+}
+
+func (r *resolver) resolveField(s *scope, field *ast.Field) {
+	ft, ok := r.resolveFieldTypeName(s, field.TypeName)
+	if !ok {
+		r.errorf(field.TypeNamePosition, field.TypeName, ErrUnresolved, "failed to resolve name %q", field.TypeName)
+		return
+	}
+	field.Type = ft
 }
 
 func (r *resolver) resolveFieldTypeName(s *scope, name string) (interface{}, bool) {
@@ -232,43 +239,34 @@ func (r *resolver) resolveFieldTypeName(s *scope, name string) (interface{}, boo
 	return nil, false
 }
 
-func (r *resolver) resolveMethod(s *scope, mth *ast.Method) error {
-	o := r.resolveName(s, mth.InTypeName)
-	if o == nil {
-		return fmt.Errorf("failed to resolve name %q", mth.InTypeName)
+func (r *resolver) resolveMethod(s *scope, mth *ast.Method) {
+	if o := r.resolveName(s, mth.InTypeName); o != nil {
+		mth.InType = o.last()
+	} else {
+		r.errorf(mth.InTypeNamePosition, mth.InTypeName, ErrUnresolved, "failed to resolve name %q", mth.InTypeName)
 	}
-	mth.InType = o.last()
 
-	o = r.resolveName(s, mth.OutTypeName)
-	if o == nil {
-		return fmt.Errorf("failed to resolve name %q", mth.OutTypeName)
+	if o := r.resolveName(s, mth.OutTypeName); o != nil {
+		mth.OutType = o.last()
+	} else {
+		r.errorf(mth.OutTypeNamePosition, mth.OutTypeName, ErrUnresolved, "failed to resolve name %q", mth.OutTypeName)
 	}
-	mth.OutType = o.last()
-
-	return nil
 }
 
-func (r *resolver) resolveExtension(s *scope, ext *ast.Extension) error {
-	o := r.resolveName(s, ext.Extendee)
-	if o == nil {
-		return fmt.Errorf("failed to resolve name %q", ext.Extendee)
-	}
-	m, ok := o.last().(*ast.Message)
-	if !ok {
-		return fmt.Errorf("extendee %q resolved to non-message %T", ext.Extendee, o.last())
+func (r *resolver) resolveExtension(s *scope, ext *ast.Extension) {
+	if o := r.resolveName(s, ext.Extendee); o == nil {
+		r.errorf(ext.ExtendeePosition, ext.Extendee, ErrUnresolved, "failed to resolve name %q", ext.Extendee)
+	} else if m, ok := o.last().(*ast.Message); !ok {
+		r.errorf(ext.ExtendeePosition, ext.Extendee, ErrInvalid, "extendee %q resolved to non-message %T", ext.Extendee, o.last())
+	} else {
+		ext.ExtendeeType = m
 	}
-	ext.ExtendeeType = m
 	// Resolve fields.
 	for _, field := range ext.Fields {
-		ft, ok := r.resolveFieldTypeName(s, field.TypeName)
-		if !ok {
-			return fmt.Errorf("failed to resolve name %q", field.TypeName)
-		}
-		field.Type = ft
+		r.resolveField(s, field)
 
 		// TODO: Map fields should be forbidden?
 	}
-	return nil
 }
 
 func (r *resolver) resolveName(s *scope, name string) *scope {
@@ -5,14 +5,13 @@ package imm
 //immutableVet:skipFile
 
 import (
+	"encoding/json"
 	"myitcv.io/immutable"
 )
 
-//
 // strEntrySelect is an immutable type and has the following template:
 //
-// 	map[string]Label
-//
+//	map[string]Label
 type strEntrySelect struct {
 	theMap  map[string]Label
 	mutable bool
@@ -163,11 +162,122 @@ func (s *strEntrySelect) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	return true
 }
 
-//
+// strEntrySelectDiff is the change set between two strEntrySelect values, as returned by
+// strEntrySelect.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set.
+type strEntrySelectDiff struct {
+	Replaced bool
+	Value    *strEntrySelect
+
+	// Set holds the entries that were added or changed
+	Set map[string]Label
+
+	// Del holds the keys of the entries that were deleted, in no particular
+	// order
+	Del []string
+}
+
+// Diff returns the change set required to turn m into other, or nil if there
+// is no difference.
+func (m *strEntrySelect) Diff(other *strEntrySelect) *strEntrySelectDiff {
+	if m == other {
+		return nil
+	}
+
+	if m == nil || other == nil {
+		return &strEntrySelectDiff{Replaced: true, Value: other}
+	}
+
+	res := &strEntrySelectDiff{
+		Set: make(map[string]Label),
+	}
+
+	for k := range m.Range() {
+		if _, ok := other.Get(k); !ok {
+			res.Del = append(res.Del, k)
+		}
+	}
+
+	for k, ov := range other.Range() {
+		v, ok := m.Get(k)
+		if !ok {
+			res.Set[k] = ov
+			continue
+		}
+
+		if v != ov {
+			res.Set[k] = ov
+		}
+	}
+
+	if len(res.Set) == 0 && len(res.Del) == 0 {
+		return nil
+	}
+
+	return res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to m.
+func (m *strEntrySelect) Patch(d *strEntrySelectDiff) *strEntrySelect {
+	if d == nil {
+		return m
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if m == nil {
+		m = newStrEntrySelect()
+	}
+
+	return m.WithMutable(func(mi *strEntrySelect) {
+		for _, k := range d.Del {
+			mi.Del(k)
+		}
+
+		for k, v := range d.Set {
+			mi.Set(k, v)
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. m is marshalled as a JSON object.
+func (m *strEntrySelect) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+
+	if m.Len() == 0 {
+		return []byte("{}"), nil
+	}
+
+	return json.Marshal(m.Range())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. m is set to an immutable map
+// containing the unmarshalled entries. Because *m is replaced, m should be a
+// new value rather than one shared with other code.
+func (m *strEntrySelect) UnmarshalJSON(b []byte) error {
+	var v map[string]Label
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*m = *newStrEntrySelect(func(mi *strEntrySelect) {
+		for k, e := range v {
+			mi.Set(k, e)
+		}
+	})
+
+	return nil
+}
+
 // LabelEntries is an immutable type and has the following template:
 //
-// 	[]Label
-//
+//	[]Label
 type LabelEntries struct {
 	theSlice []Label
 	mutable  bool
@@ -300,6 +410,26 @@ func (m *LabelEntries) Append(v ...Label) *LabelEntries {
 
 	return res
 }
+
+func (m *LabelEntries) Slice(i, j int) *LabelEntries {
+	if m.mutable {
+		m.theSlice = m.theSlice[i:j]
+		return m
+	}
+
+	resSlice := make([]Label, j-i)
+	copy(resSlice, m.theSlice[i:j])
+
+	res := &LabelEntries{
+		theSlice: resSlice,
+	}
+
+	return res
+}
+
+func (m *LabelEntries) Concat(o *LabelEntries) *LabelEntries {
+	return m.Append(o.Range()...)
+}
 func (s *LabelEntries) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	if s == nil {
 		return true
@@ -311,11 +441,117 @@ func (s *LabelEntries) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	return true
 }
 
-//
+// LabelEntriesDiff is the change set between two LabelEntries values, as returned by
+// LabelEntries.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set.
+type LabelEntriesDiff struct {
+	Replaced bool
+	Value    *LabelEntries
+
+	// Len is the length of the new value; elements beyond Len are truncated
+	// and elements are appended to reach Len as required
+	Len int
+
+	// Set holds the elements, by index, that were added or changed
+	Set map[int]Label
+}
+
+// Diff returns the change set required to turn m into other, or nil if there
+// is no difference. Elements are compared by index.
+func (m *LabelEntries) Diff(other *LabelEntries) *LabelEntriesDiff {
+	if m == other {
+		return nil
+	}
+
+	if m == nil || other == nil {
+		return &LabelEntriesDiff{Replaced: true, Value: other}
+	}
+
+	res := &LabelEntriesDiff{
+		Len: other.Len(),
+		Set: make(map[int]Label),
+	}
+
+	for i := 0; i < other.Len(); i++ {
+		ov := other.Get(i)
+		if i >= m.Len() {
+			res.Set[i] = ov
+			continue
+		}
+
+		v := m.Get(i)
+
+		if v != ov {
+			res.Set[i] = ov
+		}
+	}
+
+	if m.Len() == other.Len() && len(res.Set) == 0 {
+		return nil
+	}
+
+	return res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to m.
+func (m *LabelEntries) Patch(d *LabelEntriesDiff) *LabelEntries {
+	if d == nil {
+		return m
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if m == nil {
+		m = new(LabelEntries)
+	}
+
+	return m.WithMutable(func(mi *LabelEntries) {
+		if l := mi.Len(); l > d.Len {
+			mi.Slice(0, d.Len)
+		} else if l < d.Len {
+			mi.Append(make([]Label, d.Len-l)...)
+		}
+
+		for i, v := range d.Set {
+			mi.Set(i, v)
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. m is marshalled as a JSON array.
+func (m *LabelEntries) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+
+	if m.Len() == 0 {
+		return []byte("[]"), nil
+	}
+
+	return json.Marshal(m.Range())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. m is set to an immutable slice
+// containing the unmarshalled elements. Because *m is replaced, m should be a
+// new value rather than one shared with other code.
+func (m *LabelEntries) UnmarshalJSON(b []byte) error {
+	var v []Label
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*m = *NewLabelEntries(v...)
+
+	return nil
+}
+
 // entriesKeysSelect is an immutable type and has the following template:
 //
-// 	[]entryKey
-//
+//	[]entryKey
 type entriesKeysSelect struct {
 	theSlice []entryKey
 	mutable  bool
@@ -448,6 +684,26 @@ func (m *entriesKeysSelect) Append(v ...entryKey) *entriesKeysSelect {
 
 	return res
 }
+
+func (m *entriesKeysSelect) Slice(i, j int) *entriesKeysSelect {
+	if m.mutable {
+		m.theSlice = m.theSlice[i:j]
+		return m
+	}
+
+	resSlice := make([]entryKey, j-i)
+	copy(resSlice, m.theSlice[i:j])
+
+	res := &entriesKeysSelect{
+		theSlice: resSlice,
+	}
+
+	return res
+}
+
+func (m *entriesKeysSelect) Concat(o *entriesKeysSelect) *entriesKeysSelect {
+	return m.Append(o.Range()...)
+}
 func (s *entriesKeysSelect) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	if s == nil {
 		return true
@@ -458,3 +714,111 @@ func (s *entriesKeysSelect) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	}
 	return true
 }
+
+// entriesKeysSelectDiff is the change set between two entriesKeysSelect values, as returned by
+// entriesKeysSelect.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set.
+type entriesKeysSelectDiff struct {
+	Replaced bool
+	Value    *entriesKeysSelect
+
+	// Len is the length of the new value; elements beyond Len are truncated
+	// and elements are appended to reach Len as required
+	Len int
+
+	// Set holds the elements, by index, that were added or changed
+	Set map[int]entryKey
+}
+
+// Diff returns the change set required to turn m into other, or nil if there
+// is no difference. Elements are compared by index.
+func (m *entriesKeysSelect) Diff(other *entriesKeysSelect) *entriesKeysSelectDiff {
+	if m == other {
+		return nil
+	}
+
+	if m == nil || other == nil {
+		return &entriesKeysSelectDiff{Replaced: true, Value: other}
+	}
+
+	res := &entriesKeysSelectDiff{
+		Len: other.Len(),
+		Set: make(map[int]entryKey),
+	}
+
+	for i := 0; i < other.Len(); i++ {
+		ov := other.Get(i)
+		if i >= m.Len() {
+			res.Set[i] = ov
+			continue
+		}
+
+		v := m.Get(i)
+
+		if v != ov {
+			res.Set[i] = ov
+		}
+	}
+
+	if m.Len() == other.Len() && len(res.Set) == 0 {
+		return nil
+	}
+
+	return res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to m.
+func (m *entriesKeysSelect) Patch(d *entriesKeysSelectDiff) *entriesKeysSelect {
+	if d == nil {
+		return m
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if m == nil {
+		m = new(entriesKeysSelect)
+	}
+
+	return m.WithMutable(func(mi *entriesKeysSelect) {
+		if l := mi.Len(); l > d.Len {
+			mi.Slice(0, d.Len)
+		} else if l < d.Len {
+			mi.Append(make([]entryKey, d.Len-l)...)
+		}
+
+		for i, v := range d.Set {
+			mi.Set(i, v)
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. m is marshalled as a JSON array.
+func (m *entriesKeysSelect) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+
+	if m.Len() == 0 {
+		return []byte("[]"), nil
+	}
+
+	return json.Marshal(m.Range())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. m is set to an immutable slice
+// containing the unmarshalled elements. Because *m is replaced, m should be a
+// new value rather than one shared with other code.
+func (m *entriesKeysSelect) UnmarshalJSON(b []byte) error {
+	var v []entryKey
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*m = *newEntriesKeysSelect(v...)
+
+	return nil
+}
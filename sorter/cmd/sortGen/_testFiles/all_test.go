@@ -2,6 +2,8 @@ package main
 
 import (
 	"fmt"
+	"reflect"
+	"sort"
 	"testing"
 )
 
@@ -37,4 +39,89 @@ func TestSort(t *testing.T) {
 
 	sortOtherMySlice(nil)
 	stableSortOtherMySlice(nil)
+
+	sortByNameFunc(nil)
+	stableSortByNameFunc(nil)
+
+	SortByAgeFunc(nil)
+	StableSortByAgeFunc(nil)
+
+	vs := []string{"b", "c", "a"}
+	sortGeneric(vs)
+	stableSortGeneric([]int{3, 1, 2})
+	sortGenericFunc(vs)
+	stableSortGenericFunc(vs)
+
+	sortPeople(nil)
+	stableSortPeople(nil)
+	sortPeopleFunc(nil)
+	stableSortPeopleFunc(nil)
+
+	r := &ring{vs: []int{3, 1, 2}}
+	sortRing(r)
+	stableSortRing(r)
+	if !sort.IntsAreSorted(r.vs) {
+		t.Errorf("ring not sorted: %v", r.vs)
+	}
+
+	sortTestNames(nil)
+	stableSortTestNames(nil)
+	sortTestNamesFunc(nil)
+	stableSortTestNamesFunc(nil)
+}
+
+func TestHelpers(t *testing.T) {
+	check := func(what string, got, want interface{}) {
+		t.Helper()
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: got %v, want %v", what, got, want)
+		}
+	}
+
+	vs := []int{5, 1, 4, 2, 3}
+
+	check("topK", topKGeneric(vs, 3), []int{1, 2, 3})
+	check("topK 0", topKGeneric(vs, 0), []int(nil))
+	check("topK all", topKGeneric(vs, 10), []int{1, 2, 3, 4, 5})
+
+	check("isSorted", isSortedGeneric(vs), false)
+	sortGeneric(vs)
+	check("isSorted", isSortedGeneric(vs), true)
+
+	check("search", searchGeneric(vs, 3), 2)
+	check("search missing", searchGeneric(vs, 6), 5)
+	check("insert", insertGeneric(vs, 0), []int{0, 1, 2, 3, 4, 5})
+	check("merge", mergeGeneric([]int{1, 3, 5}, []int{2, 4}), []int{1, 2, 3, 4, 5})
+
+	reverseSortGeneric(vs)
+	check("reverse", vs, []int{5, 4, 3, 2, 1})
+
+	// stability: elements that are equal in the order keep their relative
+	// order
+	people := []person{{"Sarah", 60}, {"Jill", 34}, {"Paul", 25}}
+	StableSortByAge(people)
+
+	check("insert stable", InsertByAge(people, person{"Bob", 34}),
+		[]person{{"Paul", 25}, {"Jill", 34}, {"Bob", 34}, {"Sarah", 60}})
+	check("merge stable", MergeByAge([]person{{"Jill", 34}}, []person{{"Bob", 34}}),
+		[]person{{"Jill", 34}, {"Bob", 34}})
+	check("topK stable", TopKByAge([]person{{"Jill", 34}, {"Bob", 34}}, 1),
+		[]person{{"Jill", 34}})
+
+	reverseStableSortByName(people)
+	check("reverse stable", people, []person{{"Sarah", 60}, {"Paul", 25}, {"Jill", 34}})
+
+	ms := NewMySlice("cherry", "apple", "banana")
+
+	check("imm topK", topKMySlice(ms, 2).Range(), []string{"apple", "banana"})
+	check("imm isSorted", isSortedMySlice(ms), false)
+
+	ms = sortMySlice(ms)
+
+	check("imm isSorted", isSortedMySlice(ms), true)
+	check("imm search", searchMySlice(ms, "banana"), 1)
+	check("imm insert", insertMySlice(ms, "apricot").Range(), []string{"apple", "apricot", "banana", "cherry"})
+	check("imm merge", mergeMySlice(ms, NewMySlice("date")).Range(), []string{"apple", "banana", "cherry", "date"})
+	check("imm reverse", reverseSortMySlice(ms).Range(), []string{"cherry", "banana", "apple"})
+	check("imm unchanged", ms.Range(), []string{"apple", "banana", "cherry"})
 }
@@ -2,8 +2,11 @@
 
 package main
 
-import "sort"
-import "myitcv.io/sorter"
+import (
+	"sort"
+
+	"myitcv.io/sorter"
+)
 
 func SortByAge(vs []person) {
 	sort.Sort(&sorter.Wrapper{
@@ -18,6 +21,24 @@ func SortByAge(vs []person) {
 		},
 	})
 }
+func SortByAgeFunc(vs []person) {
+	sort.Slice(vs, func(i, j int) bool {
+		return bool(OrderByAge(vs, i, j))
+	})
+}
+func ReverseSortByAge(vs []person) {
+	sort.Sort(sort.Reverse(&sorter.Wrapper{
+		LenFunc: func() int {
+			return len(vs)
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(OrderByAge(vs, i, j))
+		},
+		SwapFunc: func(i, j int) {
+			vs[i], vs[j] = vs[j], vs[i]
+		},
+	}))
+}
 func StableSortByAge(vs []person) {
 	sort.Stable(&sorter.Wrapper{
 		LenFunc: func() int {
@@ -31,3 +52,97 @@ func StableSortByAge(vs []person) {
 		},
 	})
 }
+func StableSortByAgeFunc(vs []person) {
+	sort.SliceStable(vs, func(i, j int) bool {
+		return bool(OrderByAge(vs, i, j))
+	})
+}
+func ReverseStableSortByAge(vs []person) {
+	sort.Stable(sort.Reverse(&sorter.Wrapper{
+		LenFunc: func() int {
+			return len(vs)
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(OrderByAge(vs, i, j))
+		},
+		SwapFunc: func(i, j int) {
+			vs[i], vs[j] = vs[j], vs[i]
+		},
+	}))
+}
+func SearchByAge(vs []person, x person) int {
+	return sort.Search(len(vs), func(i int) bool {
+		return !bool(OrderByAge([]person{vs[i], x}, 0, 1))
+	})
+}
+
+func InsertByAge(vs []person, x person) []person {
+	i := sort.Search(len(vs), func(i int) bool {
+		return bool(OrderByAge([]person{x, vs[i]}, 0, 1))
+	})
+
+	var zero person
+	vs = append(vs, zero)
+	copy(vs[i+1:], vs[i:])
+	vs[i] = x
+
+	return vs
+}
+
+func IsSortedByAge(vs []person) bool {
+	return sort.IsSorted(&sorter.Wrapper{
+		LenFunc: func() int {
+			return len(vs)
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(OrderByAge(vs, i, j))
+		},
+	})
+}
+
+func MergeByAge(a, b []person) []person {
+	res := make([]person, 0, len(a)+len(b))
+
+	for len(a) > 0 && len(b) > 0 {
+		if bool(OrderByAge([]person{b[0], a[0]}, 0, 1)) {
+			res = append(res, b[0])
+			b = b[1:]
+		} else {
+			res = append(res, a[0])
+			a = a[1:]
+		}
+	}
+
+	res = append(res, a...)
+
+	return append(res, b...)
+}
+
+func TopKByAge(vs []person, k int) []person {
+	if k <= 0 {
+		return nil
+	}
+
+	res := make([]person, 0, k+1)
+
+	for _, v := range vs {
+		if len(res) == k && !bool(OrderByAge([]person{v, res[k-1]}, 0, 1)) {
+			continue
+		}
+
+		i := sort.Search(len(res), func(i int) bool {
+			return bool(OrderByAge([]person{v, res[i]}, 0, 1))
+		})
+
+		var zero person
+		res = append(res, zero)
+		copy(res[i+1:], res[i:])
+		res[i] = v
+
+		if len(res) > k {
+			res = res[:k]
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,320 @@
+// Code generated by sortGen. DO NOT EDIT.
+
+package main
+
+import (
+	"sort"
+
+	"myitcv.io/sorter"
+)
+
+func sortGeneric[T ordered](vs []T) {
+	sort.Sort(&sorter.Wrapper{
+		LenFunc: func() int {
+			return len(vs)
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(orderGeneric(vs, i, j))
+		},
+		SwapFunc: func(i, j int) {
+			vs[i], vs[j] = vs[j], vs[i]
+		},
+	})
+}
+func sortGenericFunc[T ordered](vs []T) {
+	sort.Slice(vs, func(i, j int) bool {
+		return bool(orderGeneric(vs, i, j))
+	})
+}
+func reverseSortGeneric[T ordered](vs []T) {
+	sort.Sort(sort.Reverse(&sorter.Wrapper{
+		LenFunc: func() int {
+			return len(vs)
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(orderGeneric(vs, i, j))
+		},
+		SwapFunc: func(i, j int) {
+			vs[i], vs[j] = vs[j], vs[i]
+		},
+	}))
+}
+func stableSortGeneric[T ordered](vs []T) {
+	sort.Stable(&sorter.Wrapper{
+		LenFunc: func() int {
+			return len(vs)
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(orderGeneric(vs, i, j))
+		},
+		SwapFunc: func(i, j int) {
+			vs[i], vs[j] = vs[j], vs[i]
+		},
+	})
+}
+func stableSortGenericFunc[T ordered](vs []T) {
+	sort.SliceStable(vs, func(i, j int) bool {
+		return bool(orderGeneric(vs, i, j))
+	})
+}
+func reverseStableSortGeneric[T ordered](vs []T) {
+	sort.Stable(sort.Reverse(&sorter.Wrapper{
+		LenFunc: func() int {
+			return len(vs)
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(orderGeneric(vs, i, j))
+		},
+		SwapFunc: func(i, j int) {
+			vs[i], vs[j] = vs[j], vs[i]
+		},
+	}))
+}
+func searchGeneric[T ordered](vs []T, x T) int {
+	return sort.Search(len(vs), func(i int) bool {
+		return !bool(orderGeneric([]T{vs[i], x}, 0, 1))
+	})
+}
+
+func insertGeneric[T ordered](vs []T, x T) []T {
+	i := sort.Search(len(vs), func(i int) bool {
+		return bool(orderGeneric([]T{x, vs[i]}, 0, 1))
+	})
+
+	var zero T
+	vs = append(vs, zero)
+	copy(vs[i+1:], vs[i:])
+	vs[i] = x
+
+	return vs
+}
+
+func isSortedGeneric[T ordered](vs []T) bool {
+	return sort.IsSorted(&sorter.Wrapper{
+		LenFunc: func() int {
+			return len(vs)
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(orderGeneric(vs, i, j))
+		},
+	})
+}
+
+func mergeGeneric[T ordered](a, b []T) []T {
+	res := make([]T, 0, len(a)+len(b))
+
+	for len(a) > 0 && len(b) > 0 {
+		if bool(orderGeneric([]T{b[0], a[0]}, 0, 1)) {
+			res = append(res, b[0])
+			b = b[1:]
+		} else {
+			res = append(res, a[0])
+			a = a[1:]
+		}
+	}
+
+	res = append(res, a...)
+
+	return append(res, b...)
+}
+
+func topKGeneric[T ordered](vs []T, k int) []T {
+	if k <= 0 {
+		return nil
+	}
+
+	res := make([]T, 0, k+1)
+
+	for _, v := range vs {
+		if len(res) == k && !bool(orderGeneric([]T{v, res[k-1]}, 0, 1)) {
+			continue
+		}
+
+		i := sort.Search(len(res), func(i int) bool {
+			return bool(orderGeneric([]T{v, res[i]}, 0, 1))
+		})
+
+		var zero T
+		res = append(res, zero)
+		copy(res[i+1:], res[i:])
+		res[i] = v
+
+		if len(res) > k {
+			res = res[:k]
+		}
+	}
+
+	return res
+}
+func sortPeople(vs people) {
+	sort.Sort(&sorter.Wrapper{
+		LenFunc: func() int {
+			return len(vs)
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(orderPeople(vs, i, j))
+		},
+		SwapFunc: func(i, j int) {
+			vs[i], vs[j] = vs[j], vs[i]
+		},
+	})
+}
+func sortPeopleFunc(vs people) {
+	sort.Slice(vs, func(i, j int) bool {
+		return bool(orderPeople(vs, i, j))
+	})
+}
+func reverseSortPeople(vs people) {
+	sort.Sort(sort.Reverse(&sorter.Wrapper{
+		LenFunc: func() int {
+			return len(vs)
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(orderPeople(vs, i, j))
+		},
+		SwapFunc: func(i, j int) {
+			vs[i], vs[j] = vs[j], vs[i]
+		},
+	}))
+}
+func stableSortPeople(vs people) {
+	sort.Stable(&sorter.Wrapper{
+		LenFunc: func() int {
+			return len(vs)
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(orderPeople(vs, i, j))
+		},
+		SwapFunc: func(i, j int) {
+			vs[i], vs[j] = vs[j], vs[i]
+		},
+	})
+}
+func stableSortPeopleFunc(vs people) {
+	sort.SliceStable(vs, func(i, j int) bool {
+		return bool(orderPeople(vs, i, j))
+	})
+}
+func reverseStableSortPeople(vs people) {
+	sort.Stable(sort.Reverse(&sorter.Wrapper{
+		LenFunc: func() int {
+			return len(vs)
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(orderPeople(vs, i, j))
+		},
+		SwapFunc: func(i, j int) {
+			vs[i], vs[j] = vs[j], vs[i]
+		},
+	}))
+}
+func searchPeople(vs people, x person) int {
+	return sort.Search(len(vs), func(i int) bool {
+		return !bool(orderPeople(people{vs[i], x}, 0, 1))
+	})
+}
+
+func insertPeople(vs people, x person) people {
+	i := sort.Search(len(vs), func(i int) bool {
+		return bool(orderPeople(people{x, vs[i]}, 0, 1))
+	})
+
+	var zero person
+	vs = append(vs, zero)
+	copy(vs[i+1:], vs[i:])
+	vs[i] = x
+
+	return vs
+}
+
+func isSortedPeople(vs people) bool {
+	return sort.IsSorted(&sorter.Wrapper{
+		LenFunc: func() int {
+			return len(vs)
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(orderPeople(vs, i, j))
+		},
+	})
+}
+
+func mergePeople(a, b people) people {
+	res := make(people, 0, len(a)+len(b))
+
+	for len(a) > 0 && len(b) > 0 {
+		if bool(orderPeople(people{b[0], a[0]}, 0, 1)) {
+			res = append(res, b[0])
+			b = b[1:]
+		} else {
+			res = append(res, a[0])
+			a = a[1:]
+		}
+	}
+
+	res = append(res, a...)
+
+	return append(res, b...)
+}
+
+func topKPeople(vs people, k int) people {
+	if k <= 0 {
+		return nil
+	}
+
+	res := make(people, 0, k+1)
+
+	for _, v := range vs {
+		if len(res) == k && !bool(orderPeople(people{v, res[k-1]}, 0, 1)) {
+			continue
+		}
+
+		i := sort.Search(len(res), func(i int) bool {
+			return bool(orderPeople(people{v, res[i]}, 0, 1))
+		})
+
+		var zero person
+		res = append(res, zero)
+		copy(res[i+1:], res[i:])
+		res[i] = v
+
+		if len(res) > k {
+			res = res[:k]
+		}
+	}
+
+	return res
+}
+func sortRing(vs *ring) {
+	sort.Sort(&sorter.Wrapper{
+		LenFunc: func() int {
+			return vs.Len()
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(orderRing(vs, i, j))
+		},
+		SwapFunc: func(i, j int) {
+			jPrev := vs.Get(j)
+			iPrev := vs.Get(i)
+
+			vs.Set(j, iPrev)
+			vs.Set(i, jPrev)
+		},
+	})
+}
+func stableSortRing(vs *ring) {
+	sort.Stable(&sorter.Wrapper{
+		LenFunc: func() int {
+			return vs.Len()
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(orderRing(vs, i, j))
+		},
+		SwapFunc: func(i, j int) {
+			jPrev := vs.Get(j)
+			iPrev := vs.Get(i)
+
+			vs.Set(j, iPrev)
+			vs.Set(i, jPrev)
+		},
+	})
+}
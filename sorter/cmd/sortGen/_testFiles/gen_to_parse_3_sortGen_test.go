@@ -0,0 +1,148 @@
+// Code generated by sortGen. DO NOT EDIT.
+
+package main
+
+import (
+	"sort"
+
+	"myitcv.io/sorter"
+)
+
+func sortTestNames(vs []string) {
+	sort.Sort(&sorter.Wrapper{
+		LenFunc: func() int {
+			return len(vs)
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(orderTestNames(vs, i, j))
+		},
+		SwapFunc: func(i, j int) {
+			vs[i], vs[j] = vs[j], vs[i]
+		},
+	})
+}
+func sortTestNamesFunc(vs []string) {
+	sort.Slice(vs, func(i, j int) bool {
+		return bool(orderTestNames(vs, i, j))
+	})
+}
+func reverseSortTestNames(vs []string) {
+	sort.Sort(sort.Reverse(&sorter.Wrapper{
+		LenFunc: func() int {
+			return len(vs)
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(orderTestNames(vs, i, j))
+		},
+		SwapFunc: func(i, j int) {
+			vs[i], vs[j] = vs[j], vs[i]
+		},
+	}))
+}
+func stableSortTestNames(vs []string) {
+	sort.Stable(&sorter.Wrapper{
+		LenFunc: func() int {
+			return len(vs)
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(orderTestNames(vs, i, j))
+		},
+		SwapFunc: func(i, j int) {
+			vs[i], vs[j] = vs[j], vs[i]
+		},
+	})
+}
+func stableSortTestNamesFunc(vs []string) {
+	sort.SliceStable(vs, func(i, j int) bool {
+		return bool(orderTestNames(vs, i, j))
+	})
+}
+func reverseStableSortTestNames(vs []string) {
+	sort.Stable(sort.Reverse(&sorter.Wrapper{
+		LenFunc: func() int {
+			return len(vs)
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(orderTestNames(vs, i, j))
+		},
+		SwapFunc: func(i, j int) {
+			vs[i], vs[j] = vs[j], vs[i]
+		},
+	}))
+}
+func searchTestNames(vs []string, x string) int {
+	return sort.Search(len(vs), func(i int) bool {
+		return !bool(orderTestNames([]string{vs[i], x}, 0, 1))
+	})
+}
+
+func insertTestNames(vs []string, x string) []string {
+	i := sort.Search(len(vs), func(i int) bool {
+		return bool(orderTestNames([]string{x, vs[i]}, 0, 1))
+	})
+
+	var zero string
+	vs = append(vs, zero)
+	copy(vs[i+1:], vs[i:])
+	vs[i] = x
+
+	return vs
+}
+
+func isSortedTestNames(vs []string) bool {
+	return sort.IsSorted(&sorter.Wrapper{
+		LenFunc: func() int {
+			return len(vs)
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(orderTestNames(vs, i, j))
+		},
+	})
+}
+
+func mergeTestNames(a, b []string) []string {
+	res := make([]string, 0, len(a)+len(b))
+
+	for len(a) > 0 && len(b) > 0 {
+		if bool(orderTestNames([]string{b[0], a[0]}, 0, 1)) {
+			res = append(res, b[0])
+			b = b[1:]
+		} else {
+			res = append(res, a[0])
+			a = a[1:]
+		}
+	}
+
+	res = append(res, a...)
+
+	return append(res, b...)
+}
+
+func topKTestNames(vs []string, k int) []string {
+	if k <= 0 {
+		return nil
+	}
+
+	res := make([]string, 0, k+1)
+
+	for _, v := range vs {
+		if len(res) == k && !bool(orderTestNames([]string{v, res[k-1]}, 0, 1)) {
+			continue
+		}
+
+		i := sort.Search(len(res), func(i int) bool {
+			return bool(orderTestNames([]string{v, res[i]}, 0, 1))
+		})
+
+		var zero string
+		res = append(res, zero)
+		copy(res[i+1:], res[i:])
+		res[i] = v
+
+		if len(res) > k {
+			res = res[:k]
+		}
+	}
+
+	return res
+}
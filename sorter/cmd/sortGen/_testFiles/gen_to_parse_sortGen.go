@@ -2,11 +2,13 @@
 
 package main
 
-import "sort"
-import "myitcv.io/sorter"
+import (
+	"bytes"
+	"sort"
 
-import "bytes"
-import "myitcv.io/sorter/cmd/sortGen/_testFiles/internal/other"
+	"myitcv.io/sorter"
+	"myitcv.io/sorter/cmd/sortGen/_testFiles/internal/other"
+)
 
 func sortByName(vs []person) {
 	sort.Sort(&sorter.Wrapper{
@@ -21,6 +23,24 @@ func sortByName(vs []person) {
 		},
 	})
 }
+func sortByNameFunc(vs []person) {
+	sort.Slice(vs, func(i, j int) bool {
+		return bool(orderByName(vs, i, j))
+	})
+}
+func reverseSortByName(vs []person) {
+	sort.Sort(sort.Reverse(&sorter.Wrapper{
+		LenFunc: func() int {
+			return len(vs)
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(orderByName(vs, i, j))
+		},
+		SwapFunc: func(i, j int) {
+			vs[i], vs[j] = vs[j], vs[i]
+		},
+	}))
+}
 func stableSortByName(vs []person) {
 	sort.Stable(&sorter.Wrapper{
 		LenFunc: func() int {
@@ -34,6 +54,100 @@ func stableSortByName(vs []person) {
 		},
 	})
 }
+func stableSortByNameFunc(vs []person) {
+	sort.SliceStable(vs, func(i, j int) bool {
+		return bool(orderByName(vs, i, j))
+	})
+}
+func reverseStableSortByName(vs []person) {
+	sort.Stable(sort.Reverse(&sorter.Wrapper{
+		LenFunc: func() int {
+			return len(vs)
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(orderByName(vs, i, j))
+		},
+		SwapFunc: func(i, j int) {
+			vs[i], vs[j] = vs[j], vs[i]
+		},
+	}))
+}
+func searchByName(vs []person, x person) int {
+	return sort.Search(len(vs), func(i int) bool {
+		return !bool(orderByName([]person{vs[i], x}, 0, 1))
+	})
+}
+
+func insertByName(vs []person, x person) []person {
+	i := sort.Search(len(vs), func(i int) bool {
+		return bool(orderByName([]person{x, vs[i]}, 0, 1))
+	})
+
+	var zero person
+	vs = append(vs, zero)
+	copy(vs[i+1:], vs[i:])
+	vs[i] = x
+
+	return vs
+}
+
+func isSortedByName(vs []person) bool {
+	return sort.IsSorted(&sorter.Wrapper{
+		LenFunc: func() int {
+			return len(vs)
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(orderByName(vs, i, j))
+		},
+	})
+}
+
+func mergeByName(a, b []person) []person {
+	res := make([]person, 0, len(a)+len(b))
+
+	for len(a) > 0 && len(b) > 0 {
+		if bool(orderByName([]person{b[0], a[0]}, 0, 1)) {
+			res = append(res, b[0])
+			b = b[1:]
+		} else {
+			res = append(res, a[0])
+			a = a[1:]
+		}
+	}
+
+	res = append(res, a...)
+
+	return append(res, b...)
+}
+
+func topKByName(vs []person, k int) []person {
+	if k <= 0 {
+		return nil
+	}
+
+	res := make([]person, 0, k+1)
+
+	for _, v := range vs {
+		if len(res) == k && !bool(orderByName([]person{v, res[k-1]}, 0, 1)) {
+			continue
+		}
+
+		i := sort.Search(len(res), func(i int) bool {
+			return bool(orderByName([]person{v, res[i]}, 0, 1))
+		})
+
+		var zero person
+		res = append(res, zero)
+		copy(res[i+1:], res[i:])
+		res[i] = v
+
+		if len(res) > k {
+			res = res[:k]
+		}
+	}
+
+	return res
+}
 func sortMySlice(vs *MySlice) *MySlice {
 	theVs := vs.AsMutable()
 
@@ -55,6 +169,27 @@ func sortMySlice(vs *MySlice) *MySlice {
 
 	return theVs.AsImmutable(vs)
 }
+func reverseSortMySlice(vs *MySlice) *MySlice {
+	theVs := vs.AsMutable()
+
+	sort.Sort(sort.Reverse(&sorter.Wrapper{
+		LenFunc: func() int {
+			return theVs.Len()
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(orderMySlice(theVs, i, j))
+		},
+		SwapFunc: func(i, j int) {
+			jPrev := theVs.Get(j)
+			iPrev := theVs.Get(i)
+
+			theVs.Set(j, iPrev)
+			theVs.Set(i, jPrev)
+		},
+	}))
+
+	return theVs.AsImmutable(vs)
+}
 func stableSortMySlice(vs *MySlice) *MySlice {
 	theVs := vs.AsMutable()
 
@@ -76,6 +211,103 @@ func stableSortMySlice(vs *MySlice) *MySlice {
 
 	return theVs.AsImmutable(vs)
 }
+func reverseStableSortMySlice(vs *MySlice) *MySlice {
+	theVs := vs.AsMutable()
+
+	sort.Stable(sort.Reverse(&sorter.Wrapper{
+		LenFunc: func() int {
+			return theVs.Len()
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(orderMySlice(theVs, i, j))
+		},
+		SwapFunc: func(i, j int) {
+			jPrev := theVs.Get(j)
+			iPrev := theVs.Get(i)
+
+			theVs.Set(j, iPrev)
+			theVs.Set(i, jPrev)
+		},
+	}))
+
+	return theVs.AsImmutable(vs)
+}
+func searchMySlice(vs *MySlice, x string) int {
+	return sort.Search(vs.Len(), func(i int) bool {
+		return !bool(orderMySlice(new(MySlice).Append(vs.Get(i), x), 0, 1))
+	})
+}
+
+func insertMySlice(vs *MySlice, x string) *MySlice {
+	i := sort.Search(vs.Len(), func(i int) bool {
+		return bool(orderMySlice(new(MySlice).Append(x, vs.Get(i)), 0, 1))
+	})
+
+	res := make([]string, 0, vs.Len()+1)
+	res = append(res, vs.Range()[:i]...)
+	res = append(res, x)
+	res = append(res, vs.Range()[i:]...)
+
+	return new(MySlice).Append(res...)
+}
+
+func isSortedMySlice(vs *MySlice) bool {
+	return sort.IsSorted(&sorter.Wrapper{
+		LenFunc: func() int {
+			return vs.Len()
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(orderMySlice(vs, i, j))
+		},
+	})
+}
+
+func mergeMySlice(a, b *MySlice) *MySlice {
+	av, bv := a.Range(), b.Range()
+	res := make([]string, 0, len(av)+len(bv))
+
+	for len(av) > 0 && len(bv) > 0 {
+		if bool(orderMySlice(new(MySlice).Append(bv[0], av[0]), 0, 1)) {
+			res = append(res, bv[0])
+			bv = bv[1:]
+		} else {
+			res = append(res, av[0])
+			av = av[1:]
+		}
+	}
+
+	res = append(res, av...)
+	res = append(res, bv...)
+
+	return new(MySlice).Append(res...)
+}
+
+func topKMySlice(vs *MySlice, k int) *MySlice {
+	var res []string
+
+	if k > 0 {
+		for _, v := range vs.Range() {
+			if len(res) == k && !bool(orderMySlice(new(MySlice).Append(v, res[k-1]), 0, 1)) {
+				continue
+			}
+
+			i := sort.Search(len(res), func(i int) bool {
+				return bool(orderMySlice(new(MySlice).Append(v, res[i]), 0, 1))
+			})
+
+			var zero string
+			res = append(res, zero)
+			copy(res[i+1:], res[i:])
+			res[i] = v
+
+			if len(res) > k {
+				res = res[:k]
+			}
+		}
+	}
+
+	return new(MySlice).Append(res...)
+}
 func sortOtherMySlice(vs *other.MySlice) *other.MySlice {
 	theVs := vs.AsMutable()
 
@@ -97,6 +329,27 @@ func sortOtherMySlice(vs *other.MySlice) *other.MySlice {
 
 	return theVs.AsImmutable(vs)
 }
+func reverseSortOtherMySlice(vs *other.MySlice) *other.MySlice {
+	theVs := vs.AsMutable()
+
+	sort.Sort(sort.Reverse(&sorter.Wrapper{
+		LenFunc: func() int {
+			return theVs.Len()
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(orderOtherMySlice(theVs, i, j))
+		},
+		SwapFunc: func(i, j int) {
+			jPrev := theVs.Get(j)
+			iPrev := theVs.Get(i)
+
+			theVs.Set(j, iPrev)
+			theVs.Set(i, jPrev)
+		},
+	}))
+
+	return theVs.AsImmutable(vs)
+}
 func stableSortOtherMySlice(vs *other.MySlice) *other.MySlice {
 	theVs := vs.AsMutable()
 
@@ -118,6 +371,103 @@ func stableSortOtherMySlice(vs *other.MySlice) *other.MySlice {
 
 	return theVs.AsImmutable(vs)
 }
+func reverseStableSortOtherMySlice(vs *other.MySlice) *other.MySlice {
+	theVs := vs.AsMutable()
+
+	sort.Stable(sort.Reverse(&sorter.Wrapper{
+		LenFunc: func() int {
+			return theVs.Len()
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(orderOtherMySlice(theVs, i, j))
+		},
+		SwapFunc: func(i, j int) {
+			jPrev := theVs.Get(j)
+			iPrev := theVs.Get(i)
+
+			theVs.Set(j, iPrev)
+			theVs.Set(i, jPrev)
+		},
+	}))
+
+	return theVs.AsImmutable(vs)
+}
+func searchOtherMySlice(vs *other.MySlice, x string) int {
+	return sort.Search(vs.Len(), func(i int) bool {
+		return !bool(orderOtherMySlice(new(other.MySlice).Append(vs.Get(i), x), 0, 1))
+	})
+}
+
+func insertOtherMySlice(vs *other.MySlice, x string) *other.MySlice {
+	i := sort.Search(vs.Len(), func(i int) bool {
+		return bool(orderOtherMySlice(new(other.MySlice).Append(x, vs.Get(i)), 0, 1))
+	})
+
+	res := make([]string, 0, vs.Len()+1)
+	res = append(res, vs.Range()[:i]...)
+	res = append(res, x)
+	res = append(res, vs.Range()[i:]...)
+
+	return new(other.MySlice).Append(res...)
+}
+
+func isSortedOtherMySlice(vs *other.MySlice) bool {
+	return sort.IsSorted(&sorter.Wrapper{
+		LenFunc: func() int {
+			return vs.Len()
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(orderOtherMySlice(vs, i, j))
+		},
+	})
+}
+
+func mergeOtherMySlice(a, b *other.MySlice) *other.MySlice {
+	av, bv := a.Range(), b.Range()
+	res := make([]string, 0, len(av)+len(bv))
+
+	for len(av) > 0 && len(bv) > 0 {
+		if bool(orderOtherMySlice(new(other.MySlice).Append(bv[0], av[0]), 0, 1)) {
+			res = append(res, bv[0])
+			bv = bv[1:]
+		} else {
+			res = append(res, av[0])
+			av = av[1:]
+		}
+	}
+
+	res = append(res, av...)
+	res = append(res, bv...)
+
+	return new(other.MySlice).Append(res...)
+}
+
+func topKOtherMySlice(vs *other.MySlice, k int) *other.MySlice {
+	var res []string
+
+	if k > 0 {
+		for _, v := range vs.Range() {
+			if len(res) == k && !bool(orderOtherMySlice(new(other.MySlice).Append(v, res[k-1]), 0, 1)) {
+				continue
+			}
+
+			i := sort.Search(len(res), func(i int) bool {
+				return bool(orderOtherMySlice(new(other.MySlice).Append(v, res[i]), 0, 1))
+			})
+
+			var zero string
+			res = append(res, zero)
+			copy(res[i+1:], res[i:])
+			res[i] = v
+
+			if len(res) > k {
+				res = res[:k]
+			}
+		}
+	}
+
+	return new(other.MySlice).Append(res...)
+}
 func sortPointerByName(vs []*person) {
 	sort.Sort(&sorter.Wrapper{
 		LenFunc: func() int {
@@ -131,6 +481,24 @@ func sortPointerByName(vs []*person) {
 		},
 	})
 }
+func sortPointerByNameFunc(vs []*person) {
+	sort.Slice(vs, func(i, j int) bool {
+		return bool(orderPointerByName(vs, i, j))
+	})
+}
+func reverseSortPointerByName(vs []*person) {
+	sort.Sort(sort.Reverse(&sorter.Wrapper{
+		LenFunc: func() int {
+			return len(vs)
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(orderPointerByName(vs, i, j))
+		},
+		SwapFunc: func(i, j int) {
+			vs[i], vs[j] = vs[j], vs[i]
+		},
+	}))
+}
 func stableSortPointerByName(vs []*person) {
 	sort.Stable(&sorter.Wrapper{
 		LenFunc: func() int {
@@ -144,6 +512,100 @@ func stableSortPointerByName(vs []*person) {
 		},
 	})
 }
+func stableSortPointerByNameFunc(vs []*person) {
+	sort.SliceStable(vs, func(i, j int) bool {
+		return bool(orderPointerByName(vs, i, j))
+	})
+}
+func reverseStableSortPointerByName(vs []*person) {
+	sort.Stable(sort.Reverse(&sorter.Wrapper{
+		LenFunc: func() int {
+			return len(vs)
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(orderPointerByName(vs, i, j))
+		},
+		SwapFunc: func(i, j int) {
+			vs[i], vs[j] = vs[j], vs[i]
+		},
+	}))
+}
+func searchPointerByName(vs []*person, x *person) int {
+	return sort.Search(len(vs), func(i int) bool {
+		return !bool(orderPointerByName([]*person{vs[i], x}, 0, 1))
+	})
+}
+
+func insertPointerByName(vs []*person, x *person) []*person {
+	i := sort.Search(len(vs), func(i int) bool {
+		return bool(orderPointerByName([]*person{x, vs[i]}, 0, 1))
+	})
+
+	var zero *person
+	vs = append(vs, zero)
+	copy(vs[i+1:], vs[i:])
+	vs[i] = x
+
+	return vs
+}
+
+func isSortedPointerByName(vs []*person) bool {
+	return sort.IsSorted(&sorter.Wrapper{
+		LenFunc: func() int {
+			return len(vs)
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(orderPointerByName(vs, i, j))
+		},
+	})
+}
+
+func mergePointerByName(a, b []*person) []*person {
+	res := make([]*person, 0, len(a)+len(b))
+
+	for len(a) > 0 && len(b) > 0 {
+		if bool(orderPointerByName([]*person{b[0], a[0]}, 0, 1)) {
+			res = append(res, b[0])
+			b = b[1:]
+		} else {
+			res = append(res, a[0])
+			a = a[1:]
+		}
+	}
+
+	res = append(res, a...)
+
+	return append(res, b...)
+}
+
+func topKPointerByName(vs []*person, k int) []*person {
+	if k <= 0 {
+		return nil
+	}
+
+	res := make([]*person, 0, k+1)
+
+	for _, v := range vs {
+		if len(res) == k && !bool(orderPointerByName([]*person{v, res[k-1]}, 0, 1)) {
+			continue
+		}
+
+		i := sort.Search(len(res), func(i int) bool {
+			return bool(orderPointerByName([]*person{v, res[i]}, 0, 1))
+		})
+
+		var zero *person
+		res = append(res, zero)
+		copy(res[i+1:], res[i:])
+		res[i] = v
+
+		if len(res) > k {
+			res = res[:k]
+		}
+	}
+
+	return res
+}
 func sortBufferByContents(vs []bytes.Buffer) {
 	sort.Sort(&sorter.Wrapper{
 		LenFunc: func() int {
@@ -157,6 +619,24 @@ func sortBufferByContents(vs []bytes.Buffer) {
 		},
 	})
 }
+func sortBufferByContentsFunc(vs []bytes.Buffer) {
+	sort.Slice(vs, func(i, j int) bool {
+		return bool(orderBufferByContents(vs, i, j))
+	})
+}
+func reverseSortBufferByContents(vs []bytes.Buffer) {
+	sort.Sort(sort.Reverse(&sorter.Wrapper{
+		LenFunc: func() int {
+			return len(vs)
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(orderBufferByContents(vs, i, j))
+		},
+		SwapFunc: func(i, j int) {
+			vs[i], vs[j] = vs[j], vs[i]
+		},
+	}))
+}
 func stableSortBufferByContents(vs []bytes.Buffer) {
 	sort.Stable(&sorter.Wrapper{
 		LenFunc: func() int {
@@ -170,6 +650,100 @@ func stableSortBufferByContents(vs []bytes.Buffer) {
 		},
 	})
 }
+func stableSortBufferByContentsFunc(vs []bytes.Buffer) {
+	sort.SliceStable(vs, func(i, j int) bool {
+		return bool(orderBufferByContents(vs, i, j))
+	})
+}
+func reverseStableSortBufferByContents(vs []bytes.Buffer) {
+	sort.Stable(sort.Reverse(&sorter.Wrapper{
+		LenFunc: func() int {
+			return len(vs)
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(orderBufferByContents(vs, i, j))
+		},
+		SwapFunc: func(i, j int) {
+			vs[i], vs[j] = vs[j], vs[i]
+		},
+	}))
+}
+func searchBufferByContents(vs []bytes.Buffer, x bytes.Buffer) int {
+	return sort.Search(len(vs), func(i int) bool {
+		return !bool(orderBufferByContents([]bytes.Buffer{vs[i], x}, 0, 1))
+	})
+}
+
+func insertBufferByContents(vs []bytes.Buffer, x bytes.Buffer) []bytes.Buffer {
+	i := sort.Search(len(vs), func(i int) bool {
+		return bool(orderBufferByContents([]bytes.Buffer{x, vs[i]}, 0, 1))
+	})
+
+	var zero bytes.Buffer
+	vs = append(vs, zero)
+	copy(vs[i+1:], vs[i:])
+	vs[i] = x
+
+	return vs
+}
+
+func isSortedBufferByContents(vs []bytes.Buffer) bool {
+	return sort.IsSorted(&sorter.Wrapper{
+		LenFunc: func() int {
+			return len(vs)
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(orderBufferByContents(vs, i, j))
+		},
+	})
+}
+
+func mergeBufferByContents(a, b []bytes.Buffer) []bytes.Buffer {
+	res := make([]bytes.Buffer, 0, len(a)+len(b))
+
+	for len(a) > 0 && len(b) > 0 {
+		if bool(orderBufferByContents([]bytes.Buffer{b[0], a[0]}, 0, 1)) {
+			res = append(res, b[0])
+			b = b[1:]
+		} else {
+			res = append(res, a[0])
+			a = a[1:]
+		}
+	}
+
+	res = append(res, a...)
+
+	return append(res, b...)
+}
+
+func topKBufferByContents(vs []bytes.Buffer, k int) []bytes.Buffer {
+	if k <= 0 {
+		return nil
+	}
+
+	res := make([]bytes.Buffer, 0, k+1)
+
+	for _, v := range vs {
+		if len(res) == k && !bool(orderBufferByContents([]bytes.Buffer{v, res[k-1]}, 0, 1)) {
+			continue
+		}
+
+		i := sort.Search(len(res), func(i int) bool {
+			return bool(orderBufferByContents([]bytes.Buffer{v, res[i]}, 0, 1))
+		})
+
+		var zero bytes.Buffer
+		res = append(res, zero)
+		copy(res[i+1:], res[i:])
+		res[i] = v
+
+		if len(res) > k {
+			res = res[:k]
+		}
+	}
+
+	return res
+}
 func sortMap(vs []map[string]bool) {
 	sort.Sort(&sorter.Wrapper{
 		LenFunc: func() int {
@@ -183,6 +757,24 @@ func sortMap(vs []map[string]bool) {
 		},
 	})
 }
+func sortMapFunc(vs []map[string]bool) {
+	sort.Slice(vs, func(i, j int) bool {
+		return bool(orderMap(vs, i, j))
+	})
+}
+func reverseSortMap(vs []map[string]bool) {
+	sort.Sort(sort.Reverse(&sorter.Wrapper{
+		LenFunc: func() int {
+			return len(vs)
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(orderMap(vs, i, j))
+		},
+		SwapFunc: func(i, j int) {
+			vs[i], vs[j] = vs[j], vs[i]
+		},
+	}))
+}
 func stableSortMap(vs []map[string]bool) {
 	sort.Stable(&sorter.Wrapper{
 		LenFunc: func() int {
@@ -196,6 +788,100 @@ func stableSortMap(vs []map[string]bool) {
 		},
 	})
 }
+func stableSortMapFunc(vs []map[string]bool) {
+	sort.SliceStable(vs, func(i, j int) bool {
+		return bool(orderMap(vs, i, j))
+	})
+}
+func reverseStableSortMap(vs []map[string]bool) {
+	sort.Stable(sort.Reverse(&sorter.Wrapper{
+		LenFunc: func() int {
+			return len(vs)
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(orderMap(vs, i, j))
+		},
+		SwapFunc: func(i, j int) {
+			vs[i], vs[j] = vs[j], vs[i]
+		},
+	}))
+}
+func searchMap(vs []map[string]bool, x map[string]bool) int {
+	return sort.Search(len(vs), func(i int) bool {
+		return !bool(orderMap([]map[string]bool{vs[i], x}, 0, 1))
+	})
+}
+
+func insertMap(vs []map[string]bool, x map[string]bool) []map[string]bool {
+	i := sort.Search(len(vs), func(i int) bool {
+		return bool(orderMap([]map[string]bool{x, vs[i]}, 0, 1))
+	})
+
+	var zero map[string]bool
+	vs = append(vs, zero)
+	copy(vs[i+1:], vs[i:])
+	vs[i] = x
+
+	return vs
+}
+
+func isSortedMap(vs []map[string]bool) bool {
+	return sort.IsSorted(&sorter.Wrapper{
+		LenFunc: func() int {
+			return len(vs)
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(orderMap(vs, i, j))
+		},
+	})
+}
+
+func mergeMap(a, b []map[string]bool) []map[string]bool {
+	res := make([]map[string]bool, 0, len(a)+len(b))
+
+	for len(a) > 0 && len(b) > 0 {
+		if bool(orderMap([]map[string]bool{b[0], a[0]}, 0, 1)) {
+			res = append(res, b[0])
+			b = b[1:]
+		} else {
+			res = append(res, a[0])
+			a = a[1:]
+		}
+	}
+
+	res = append(res, a...)
+
+	return append(res, b...)
+}
+
+func topKMap(vs []map[string]bool, k int) []map[string]bool {
+	if k <= 0 {
+		return nil
+	}
+
+	res := make([]map[string]bool, 0, k+1)
+
+	for _, v := range vs {
+		if len(res) == k && !bool(orderMap([]map[string]bool{v, res[k-1]}, 0, 1)) {
+			continue
+		}
+
+		i := sort.Search(len(res), func(i int) bool {
+			return bool(orderMap([]map[string]bool{v, res[i]}, 0, 1))
+		})
+
+		var zero map[string]bool
+		res = append(res, zero)
+		copy(res[i+1:], res[i:])
+		res[i] = v
+
+		if len(res) > k {
+			res = res[:k]
+		}
+	}
+
+	return res
+}
 func (e *example) sortBanana(vs []string) {
 	sort.Sort(&sorter.Wrapper{
 		LenFunc: func() int {
@@ -209,6 +895,24 @@ func (e *example) sortBanana(vs []string) {
 		},
 	})
 }
+func (e *example) sortBananaFunc(vs []string) {
+	sort.Slice(vs, func(i, j int) bool {
+		return bool(e.orderBanana(vs, i, j))
+	})
+}
+func (e *example) reverseSortBanana(vs []string) {
+	sort.Sort(sort.Reverse(&sorter.Wrapper{
+		LenFunc: func() int {
+			return len(vs)
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(e.orderBanana(vs, i, j))
+		},
+		SwapFunc: func(i, j int) {
+			vs[i], vs[j] = vs[j], vs[i]
+		},
+	}))
+}
 func (e *example) stableSortBanana(vs []string) {
 	sort.Stable(&sorter.Wrapper{
 		LenFunc: func() int {
@@ -222,3 +926,97 @@ func (e *example) stableSortBanana(vs []string) {
 		},
 	})
 }
+func (e *example) stableSortBananaFunc(vs []string) {
+	sort.SliceStable(vs, func(i, j int) bool {
+		return bool(e.orderBanana(vs, i, j))
+	})
+}
+func (e *example) reverseStableSortBanana(vs []string) {
+	sort.Stable(sort.Reverse(&sorter.Wrapper{
+		LenFunc: func() int {
+			return len(vs)
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(e.orderBanana(vs, i, j))
+		},
+		SwapFunc: func(i, j int) {
+			vs[i], vs[j] = vs[j], vs[i]
+		},
+	}))
+}
+func (e *example) searchBanana(vs []string, x string) int {
+	return sort.Search(len(vs), func(i int) bool {
+		return !bool(e.orderBanana([]string{vs[i], x}, 0, 1))
+	})
+}
+
+func (e *example) insertBanana(vs []string, x string) []string {
+	i := sort.Search(len(vs), func(i int) bool {
+		return bool(e.orderBanana([]string{x, vs[i]}, 0, 1))
+	})
+
+	var zero string
+	vs = append(vs, zero)
+	copy(vs[i+1:], vs[i:])
+	vs[i] = x
+
+	return vs
+}
+
+func (e *example) isSortedBanana(vs []string) bool {
+	return sort.IsSorted(&sorter.Wrapper{
+		LenFunc: func() int {
+			return len(vs)
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(e.orderBanana(vs, i, j))
+		},
+	})
+}
+
+func (e *example) mergeBanana(a, b []string) []string {
+	res := make([]string, 0, len(a)+len(b))
+
+	for len(a) > 0 && len(b) > 0 {
+		if bool(e.orderBanana([]string{b[0], a[0]}, 0, 1)) {
+			res = append(res, b[0])
+			b = b[1:]
+		} else {
+			res = append(res, a[0])
+			a = a[1:]
+		}
+	}
+
+	res = append(res, a...)
+
+	return append(res, b...)
+}
+
+func (e *example) topKBanana(vs []string, k int) []string {
+	if k <= 0 {
+		return nil
+	}
+
+	res := make([]string, 0, k+1)
+
+	for _, v := range vs {
+		if len(res) == k && !bool(e.orderBanana([]string{v, res[k-1]}, 0, 1)) {
+			continue
+		}
+
+		i := sort.Search(len(res), func(i int) bool {
+			return bool(e.orderBanana([]string{v, res[i]}, 0, 1))
+		})
+
+		var zero string
+		res = append(res, zero)
+		copy(res[i+1:], res[i:])
+		res[i] = v
+
+		if len(res) > k {
+			res = res[:k]
+		}
+	}
+
+	return res
+}
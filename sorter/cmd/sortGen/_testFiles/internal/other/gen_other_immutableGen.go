@@ -5,14 +5,13 @@ package other
 //immutableVet:skipFile
 
 import (
+	"encoding/json"
 	"myitcv.io/immutable"
 )
 
-//
 // MySlice is an immutable type and has the following template:
 //
-// 	[]string
-//
+//	[]string
 type MySlice struct {
 	theSlice []string
 	mutable  bool
@@ -145,6 +144,26 @@ func (m *MySlice) Append(v ...string) *MySlice {
 
 	return res
 }
+
+func (m *MySlice) Slice(i, j int) *MySlice {
+	if m.mutable {
+		m.theSlice = m.theSlice[i:j]
+		return m
+	}
+
+	resSlice := make([]string, j-i)
+	copy(resSlice, m.theSlice[i:j])
+
+	res := &MySlice{
+		theSlice: resSlice,
+	}
+
+	return res
+}
+
+func (m *MySlice) Concat(o *MySlice) *MySlice {
+	return m.Append(o.Range()...)
+}
 func (s *MySlice) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	if s == nil {
 		return true
@@ -155,3 +174,111 @@ func (s *MySlice) IsDeeplyNonMutable(seen map[interface{}]bool) bool {
 	}
 	return true
 }
+
+// MySliceDiff is the change set between two MySlice values, as returned by
+// MySlice.Diff. If Replaced is true then the value was replaced wholesale by
+// Value (which may be nil) and no other fields are set.
+type MySliceDiff struct {
+	Replaced bool
+	Value    *MySlice
+
+	// Len is the length of the new value; elements beyond Len are truncated
+	// and elements are appended to reach Len as required
+	Len int
+
+	// Set holds the elements, by index, that were added or changed
+	Set map[int]string
+}
+
+// Diff returns the change set required to turn m into other, or nil if there
+// is no difference. Elements are compared by index.
+func (m *MySlice) Diff(other *MySlice) *MySliceDiff {
+	if m == other {
+		return nil
+	}
+
+	if m == nil || other == nil {
+		return &MySliceDiff{Replaced: true, Value: other}
+	}
+
+	res := &MySliceDiff{
+		Len: other.Len(),
+		Set: make(map[int]string),
+	}
+
+	for i := 0; i < other.Len(); i++ {
+		ov := other.Get(i)
+		if i >= m.Len() {
+			res.Set[i] = ov
+			continue
+		}
+
+		v := m.Get(i)
+
+		if v != ov {
+			res.Set[i] = ov
+		}
+	}
+
+	if m.Len() == other.Len() && len(res.Set) == 0 {
+		return nil
+	}
+
+	return res
+}
+
+// Patch returns the result of applying the change set d, as returned by Diff,
+// to m.
+func (m *MySlice) Patch(d *MySliceDiff) *MySlice {
+	if d == nil {
+		return m
+	}
+
+	if d.Replaced {
+		return d.Value
+	}
+
+	if m == nil {
+		m = new(MySlice)
+	}
+
+	return m.WithMutable(func(mi *MySlice) {
+		if l := mi.Len(); l > d.Len {
+			mi.Slice(0, d.Len)
+		} else if l < d.Len {
+			mi.Append(make([]string, d.Len-l)...)
+		}
+
+		for i, v := range d.Set {
+			mi.Set(i, v)
+		}
+	})
+}
+
+// MarshalJSON implements json.Marshaler. m is marshalled as a JSON array.
+func (m *MySlice) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+
+	if m.Len() == 0 {
+		return []byte("[]"), nil
+	}
+
+	return json.Marshal(m.Range())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. m is set to an immutable slice
+// containing the unmarshalled elements. Because *m is replaced, m should be a
+// new value rather than one shared with other code.
+func (m *MySlice) UnmarshalJSON(b []byte) error {
+	var v []string
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*m = *NewMySlice(v...)
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import "myitcv.io/sorter"
+
+type ordered interface {
+	~int | ~string
+}
+
+// MATCH - generic
+func orderGeneric[T ordered](vs []T, i, j int) sorter.Ordered {
+	return vs[i] < vs[j]
+}
+
+type people []person
+
+// MATCH - named slice type
+func orderPeople(vs people, i, j int) sorter.Ordered {
+	return vs[i].age < vs[j].age
+}
+
+type ring struct {
+	vs []int
+}
+
+func (r *ring) Len() int {
+	return len(r.vs)
+}
+
+func (r *ring) Get(i int) int {
+	return r.vs[i]
+}
+
+func (r *ring) Set(i int, v int) {
+	r.vs[i] = v
+}
+
+// MATCH - custom container
+func orderRing(r *ring, i, j int) sorter.Ordered {
+	return r.Get(i) < r.Get(j)
+}
+
+// fail - value receiver does not have the methods
+func orderRingValue(r ring, i, j int) sorter.Ordered {
+	return r.vs[i] < r.vs[j]
+}
@@ -0,0 +1,8 @@
+package main
+
+import "myitcv.io/sorter"
+
+// MATCH - test file
+func orderTestNames(vs []string, i, j int) sorter.Ordered {
+	return vs[i] < vs[j]
+}
@@ -5,29 +5,22 @@ package main
 
 import (
 	"bytes"
-	"flag"
 	"fmt"
 	"go/ast"
-	"go/build"
-	"go/parser"
 	"go/printer"
-	"go/token"
-	"html/template"
-	"io/ioutil"
-	"log"
-	"os"
-	"path"
+	"go/types"
+	"path/filepath"
 	"regexp"
-	"runtime"
 	"sort"
+	"strconv"
 	"strings"
+	"text/template"
 	"unicode"
 	"unicode/utf8"
 
-	"golang.org/x/tools/imports"
+	"golang.org/x/tools/go/packages"
 
 	"myitcv.io/gogenerate"
-	"myitcv.io/immutable/util"
 	"myitcv.io/sorter"
 )
 
@@ -39,17 +32,18 @@ const (
 
 // matching related vars
 var (
-	orderFnRegex    *regexp.Regexp
-	lowerOrder      string
-	upperOrder      string
-	invalidFileChar *regexp.Regexp
+	orderFnRegex *regexp.Regexp
+	lowerOrder   string
+	upperOrder   string
 )
 
-// flags
-var (
-	fLicenseFile = gogenerate.LicenseFileFlag(flag.CommandLine)
-	fGoGenLog    = gogenerate.LogFlag(flag.CommandLine)
-)
+var sortGen = &gogenerate.Generator{
+	Name:       sortGenCmd,
+	ImportPath: sortGenCmdImportPath,
+
+	// order functions can be declared in test files
+	Tests: true,
+}
 
 func init() {
 	r, n := utf8.DecodeRuneInString(orderPrefix)
@@ -68,100 +62,30 @@ func init() {
 	orderFunctionPattern := `^[` + l + u + `]` + suffix + `[[:word:]]+`
 	orderFnRegex = regexp.MustCompile(orderFunctionPattern)
 
-	invalidFileChar = regexp.MustCompile(`[[:^word:]]`)
+	sortGen.Generate = gen
 }
 
 func main() {
-	log.SetFlags(0)
-	log.SetPrefix(sortGenCmd + ": ")
-
-	flag.Parse()
-
-	gogenerate.DefaultLogLevel(fGoGenLog, gogenerate.LogFatal)
-
-	envFile, ok := os.LookupEnv(gogenerate.GOFILE)
-	if !ok {
-		fatalf("env not correct; missing %v", gogenerate.GOFILE)
-	}
-
-	wd, err := os.Getwd()
-	if err != nil {
-		fatalf("unable to get working directory: %v", err)
-	}
-
-	tags := make(map[string]bool)
-
-	goos := os.Getenv("GOOS")
-	if goos == "" {
-		goos = runtime.GOOS
-	}
-	tags[goos] = true
-
-	goarch := os.Getenv("GOARCH")
-	if goarch == "" {
-		goarch = runtime.GOARCH
-	}
-	tags[goarch] = true
-
-	pathDirFiles, err := gogenerate.FilesContainingCmd(wd, sortGenCmdImportPath, tags)
-	if err != nil {
-		fatalf("could not determine if we are the first file: %v", err)
-	}
-
-	if pathDirFiles == nil {
-		fatalf("cannot find any files containing the %v or %v directive", sortGenCmdImportPath, sortGenCmd)
-	}
-
-	if pathDirFiles[envFile] > 1 {
-		fatalf("expected a single occurrence of %v directive in %v. Got: %v", sortGenCmdImportPath, envFile, pathDirFiles)
-	}
-
-	license, err := gogenerate.CommentLicenseHeader(fLicenseFile)
-	if err != nil {
-		fatalf("could not comment license file: %v", err)
-	}
-
-	// if we get here, we know we are the first file...
-
-	gen(wd, license)
+	sortGen.Main()
 }
 
-func gen(dir, license string) {
-	bpkg, err := build.ImportDir(dir, 0)
-	if err != nil {
-		fatalf("could not load package in dir %v: %v", dir, err)
-	}
-
-	g := &generator{
-		pkg:      bpkg,
-		pkgCache: map[string]*build.Package{bpkg.ImportPath: bpkg},
-		typCache: make(map[string]map[string]bool),
-		license:  license,
-	}
-
-	g.fset = token.NewFileSet()
-
-	// do this early
-	g.findImmSlices(g.pkg)
-
-	notGenByUs := func(fi os.FileInfo) bool {
-		return !gogenerate.FileGeneratedBy(fi.Name(), sortGenCmd)
-	}
-
-	pkgs, err := parser.ParseDir(g.fset, g.pkg.Dir, notGenByUs, 0)
-	if err != nil {
-		fatalf("could not parse dir %v: %v", g.pkg.Dir, err)
-	}
-
-	// we are only interested in the package itself, not the exported test package (xtest)
-	pkg, ok := pkgs[g.pkg.Name]
-	if !ok {
-		fatalf("unable to resolve parsed pkg %v", g.pkg.Name)
-	}
+func gen(pkg *packages.Package) (res []gogenerate.File, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if se, ok := r.(sortGenError); ok {
+				err = se
+				return
+			}
+			panic(r)
+		}
+	}()
 
-	for _, f := range pkg.Files {
-		g.buf = bytes.NewBuffer(nil)
-		g.file = f
+	for _, f := range pkg.Syntax {
+		g := &generator{
+			pkg:  pkg,
+			file: f,
+			buf:  bytes.NewBuffer(nil),
+		}
 
 		matches := g.getMatches()
 
@@ -172,29 +96,17 @@ func gen(dir, license string) {
 		toGen, importMap := g.createToGen(matches)
 
 		if len(toGen) > 0 {
-			g.genMatches(toGen, importMap)
+			res = append(res, g.genMatches(toGen, importMap))
 		}
 	}
+
+	return res, nil
 }
 
-// a generator is the generator for a given package
+// a generator is the generator for a given file in a package
 type generator struct {
 	// the package in which we are generating
-	pkg *build.Package
-
-	license string
-
-	fset *token.FileSet
-
-	// a cache of build packages that maps a package import path to
-	// its corresponding *build.Package
-	pkgCache map[string]*build.Package
-
-	// a cache within this generator instances that maps
-	// packages import path to the list of immutable slices defined within
-	// that package (that is the type name, not the imm type templ
-	// name)
-	typCache map[string]map[string]bool
+	pkg *packages.Package
 
 	// the current file being analysed
 	file *ast.File
@@ -203,116 +115,44 @@ type generator struct {
 	buf *bytes.Buffer
 }
 
-type toGen struct {
-	orderFn string
-	typ     string
-
-	recvVar string
-	recvTyp string
-
-	imm bool
-}
-
-func (g *generator) findImmSlices(bp *build.Package) map[string]bool {
-	fset := token.NewFileSet()
-
-	// we are interested in Go files and test files; we won't get the resolution exactly right here
-	// in the case that someone is referring to a test type outside the package, but the compiler
-	// will catch that case
-	interestFile := func(fi os.FileInfo) bool {
-		var toCheck []string
-		toCheck = append(toCheck, bp.GoFiles...)
-		toCheck = append(toCheck, bp.TestGoFiles...)
+// kind is the kind of container sorted by an order function
+type kind int
 
-		for _, v := range toCheck {
-			if fi.Name() == v {
-				return true
-			}
-		}
-
-		return false
-	}
-
-	pkgs, err := parser.ParseDir(fset, bp.Dir, interestFile, 0)
-	if err != nil {
-		fatalf("could not parse package %v in dir %v: %v", bp.ImportPath, bp.Dir, err)
-	}
-
-	pkg, ok := pkgs[bp.Name]
-	if !ok {
-		fatalf("could not resolved parsed package %v in dir %v", bp.Name, bp.Dir)
-	}
-
-	immSlices := make(map[string]bool)
-
-	for _, f := range pkg.Files {
-
-	Decls:
-		for _, d := range f.Decls {
-			gd, ok := d.(*ast.GenDecl)
-			if !ok {
-				continue Decls
-			}
-
-			if gd.Tok != token.TYPE {
-				continue Decls
-			}
-
-		Specs:
-			for _, s := range gd.Specs {
-				ts := s.(*ast.TypeSpec)
+const (
+	// kindSlice is a slice type, or a named type the underlying type of
+	// which is a slice type
+	kindSlice kind = iota
 
-				name, isit := util.IsImmTmpl(ts)
+	// kindImmSlice is an immutable slice type, as generated by immutableGen
+	kindImmSlice
 
-				if !isit {
-					continue Specs
-				}
+	// kindContainer is any other type with Len, Get and Set methods
+	kindContainer
+)
 
-				at, ok := ts.Type.(*ast.ArrayType)
+type toGen struct {
+	orderFn string
+	typ     string
 
-				if !ok {
-					continue Specs
-				}
+	// typeParams are the type parameters of a generic order function, for
+	// example [T any], else the empty string
+	typeParams string
 
-				if at.Len != nil {
-					// it's an array and not a slice
-					continue Specs
-				}
+	// elem is the element type of a slice or immutable slice
+	elem string
 
-				immSlices[name] = true
-			}
-		}
-	}
+	// immBase is the named type of an immutable slice, the type of which is
+	// a pointer to immBase, else the empty string
+	immBase string
 
-	g.typCache[bp.ImportPath] = immSlices
+	recvVar string
+	recvTyp string
 
-	return immSlices
+	kind kind
 }
 
-// getMatches returns a map[string]fileMatches where the string is the file path where
-// the matches were found
+// getMatches returns the order functions/methods in the current file
 func (g *generator) getMatches() []match {
-
-	// see whether it imports the sorter package
-	theImport := ""
-
-	for _, s := range g.file.Imports {
-		if s.Path.Value == `"`+sorter.PkgName+`"` {
-			if s.Name != nil {
-				theImport = s.Name.Name
-			} else {
-				naked := strings.Trim(s.Path.Value, `"`)
-				parts := strings.Split(naked, "/")
-				theImport = parts[len(parts)-1]
-			}
-		}
-	}
-
-	if theImport == "" {
-		// if it's not import we can't have any templates in here...
-		return nil
-	}
-
 	var matches []match
 
 Decls:
@@ -322,42 +162,36 @@ Decls:
 			continue
 		}
 
-		fn := fun.Name.Name
-
-		if !orderFnRegex.MatchString(fn) {
-			continue
-		}
-
-		if fun.Type.Results == nil || len(fun.Type.Results.List) != 1 {
+		if !orderFnRegex.MatchString(fun.Name.Name) {
 			continue
 		}
 
-		typ, ok := fun.Type.Results.List[0].Type.(*ast.SelectorExpr)
+		obj, ok := g.pkg.TypesInfo.Defs[fun.Name].(*types.Func)
 		if !ok {
 			continue
 		}
 
-		id, ok := typ.X.(*ast.Ident)
-		if !ok {
-			continue
-		}
+		sig := obj.Type().(*types.Signature)
 
-		if id.Name != theImport {
+		if sig.Results().Len() != 1 || !isOrdered(sig.Results().At(0).Type()) {
 			continue
 		}
 
-		if typ.Sel.Name != sorter.OrderedName {
+		if sig.Params().Len() != 3 {
 			continue
 		}
 
-		if fun.Type.Params == nil {
-			continue
+		for i := 1; i < sig.Params().Len(); i++ {
+			if !types.Identical(sig.Params().At(i).Type(), types.Typ[types.Int]) {
+				continue Decls
+			}
 		}
 
-		// we need to gather the number of params....
+		// we need the expression of the first param in order that the
+		// generated function declares the parameter in the same way
 		var paramList []ast.Expr
 		for _, f := range fun.Type.Params.List {
-			for _ = range f.Names {
+			for range f.Names {
 				paramList = append(paramList, f.Type)
 			}
 		}
@@ -367,30 +201,26 @@ Decls:
 		}
 
 		m := match{
-			fun: fun,
-		}
-
-		if g.isSliceExpr(paramList[0]) {
-			m.orderTyp = paramList[0]
-			m.isImmSlice = false
+			fun:      fun,
+			orderTyp: paramList[0],
 		}
 
-		if g.isImmSliceExpr(paramList[0]) {
-			m.orderTyp = paramList[0]
-			m.isImmSlice = true
-		}
-
-		if m.orderTyp == nil {
+		typ := sig.Params().At(0).Type()
+
+		switch {
+		case g.isSlice(typ):
+			m.kind = kindSlice
+			m.elem = typ.Underlying().(*types.Slice).Elem()
+		case g.isImmSlice(typ):
+			m.kind = kindImmSlice
+			m.elem = g.method(typ, "Get").Results().At(0).Type()
+		case g.isContainer(typ):
+			m.kind = kindContainer
+		default:
 			continue
 		}
 
-		for i := 1; i < len(paramList); i++ {
-			if id, ok := paramList[i].(*ast.Ident); !ok || id.Name != "int" {
-				continue Decls
-			}
-		}
-
-		infof("found a match at %v", g.fset.Position(fun.Pos()))
+		sortGen.Infof("found a match at %v", g.pkg.Fset.Position(fun.Pos()))
 
 		matches = append(matches, m)
 	}
@@ -403,56 +233,75 @@ func (g *generator) createToGen(matches []match) ([]toGen, map[string]bool) {
 	var funs []toGen
 	importMap := make(map[string]bool)
 
-	// we need to union the list of functions
-	for _, match := range matches {
-		var buf bytes.Buffer
+	addImports := func(e ast.Expr) {
+		for i := range findImports(e, g.file.Imports) {
+			importName := i.Path.Value
+			if i.Name != nil {
+				importName = i.Name.Name + " " + importName
+			}
 
-		if err := printer.Fprint(&buf, g.fset, match.orderTyp); err != nil {
-			fatalf("could not ast print type: %v", err)
+			importMap[importName] = true
 		}
+	}
 
-		sliceIdent := buf.String()
+	// we need to union the list of functions
+	for _, match := range matches {
+		sliceIdent := g.print(match.orderTyp)
 
 		recv := ""
 		recvVar := ""
 
 		if match.fun.Recv != nil {
-			var buf bytes.Buffer
-
 			// we know at this point we have a valid method...
 			recvVar = match.fun.Recv.List[0].Names[0].Name
 
-			buf.WriteString("(")
-			buf.WriteString(recvVar)
+			recv = "(" + recvVar + " " + g.print(match.fun.Recv.List[0].Type) + ")"
+		}
 
-			if err := printer.Fprint(&buf, g.fset, match.fun.Recv.List[0].Type); err != nil {
-				fatalf("could not ast print recv: %v", err)
-			}
+		// we need to calculate the required imports
+		addImports(match.orderTyp)
 
-			buf.WriteString(")")
+		var typeParams string
 
-			recv = buf.String()
+		if tps := match.fun.Type.TypeParams; tps != nil {
+			var params []string
+			for _, f := range tps.List {
+				var names []string
+				for _, n := range f.Names {
+					names = append(names, n.Name)
+				}
+				params = append(params, strings.Join(names, ", ")+" "+g.print(f.Type))
+				addImports(f.Type)
+			}
+			typeParams = "[" + strings.Join(params, ", ") + "]"
 		}
 
-		// we need to calculate the required imports
-		importMatches := findImports(match.orderTyp, g.file.Imports)
+		var elem, immBase string
 
-		for i := range importMatches {
-			importName := i.Path.Value
-			if i.Name != nil {
-				importName = i.Name.Name + " " + importName
+		switch match.kind {
+		case kindSlice:
+			if at, ok := match.orderTyp.(*ast.ArrayType); ok {
+				elem = g.print(at.Elt)
+			} else {
+				elem = types.TypeString(match.elem, g.qualifier(importMap))
+			}
+		case kindImmSlice:
+			elem = types.TypeString(match.elem, g.qualifier(importMap))
+			if se, ok := match.orderTyp.(*ast.StarExpr); ok {
+				immBase = g.print(se.X)
 			}
-
-			importMap[importName] = true
 		}
 
 		funs = append(funs, toGen{
-			orderFn: match.fun.Name.Name,
-			typ:     sliceIdent,
-			recvTyp: recv,
-			recvVar: recvVar,
-
-			imm: match.isImmSlice,
+			orderFn:    match.fun.Name.Name,
+			typ:        sliceIdent,
+			typeParams: typeParams,
+			elem:       elem,
+			immBase:    immBase,
+			recvTyp:    recv,
+			recvVar:    recvVar,
+
+			kind: match.kind,
 		})
 	}
 
@@ -464,108 +313,130 @@ type match struct {
 	// the actual function/method that has matched
 	fun *ast.FuncDecl
 
-	// the "type" of the slice parameter (the first one);
-	// for a slice this is the ArrayType; for an imm slice
-	// this will be a pointer to an imm slice
+	// the "type" of the container parameter (the first one)
 	orderTyp ast.Expr
 
-	// whether the type is an immutable slice or not (i.e. a regular
-	// slice)
-	isImmSlice bool
-}
-
-func (g *generator) isSliceExpr(e ast.Expr) bool {
-	// this type must be either an array type, specifically
-	// with Len == nil (which implies a slice)
-	at, ok := e.(*ast.ArrayType)
-	if !ok || at.Len != nil {
-		return false
-	}
+	// the kind of the container
+	kind kind
 
-	return true
+	// the element type of a slice or immutable slice
+	elem types.Type
 }
 
-func (g *generator) isImmSliceExpr(e ast.Expr) bool {
-	// must be a pointer to an immutable slice
-	// could be defined in this package or another package
-
-	ste, ok := e.(*ast.StarExpr)
+// isOrdered returns whether t is sorter.Ordered
+func isOrdered(t types.Type) bool {
+	n, ok := t.(*types.Named)
 	if !ok {
 		return false
 	}
 
-	var ip string
-	var typ string
+	obj := n.Obj()
+
+	return obj.Pkg() != nil && obj.Pkg().Path() == sorter.PkgName && obj.Name() == sorter.OrderedName
+}
 
-	if se, ok := ste.X.(*ast.SelectorExpr); ok {
-		// X must be simple ident.ident
-		// this is safe to do because the definition of order functions can only be top level
-		// hence there can be no variable/anything in a package that compiles that shadows and
-		// import name; hence a selctor expression where the X matches an import
+func (g *generator) isSlice(t types.Type) bool {
+	_, ok := t.Underlying().(*types.Slice)
+	return ok
+}
 
-		pid, ok := se.X.(*ast.Ident)
-		if !ok {
+// isImmSlice returns whether t is an immutable slice type, as generated by
+// immutableGen, which is typically a pointer to a named type.
+func (g *generator) isImmSlice(t types.Type) bool {
+	for _, m := range []string{"AsMutable", "AsImmutable", "Len", "Get", "Set"} {
+		if g.method(t, m) == nil {
 			return false
 		}
+	}
 
-		// resolve this ident to a package import path
-		for _, is := range g.file.Imports {
-
-			p := strings.Trim(is.Path.Value, "\"")
+	return true
+}
 
-			if (is.Name != nil && is.Name.Name == pid.Name) || path.Base(p) == pid.Name {
-				ip = p
-				break
-			}
-		}
+// isContainer returns whether t has the methods:
+//
+//	Len() int
+//	Get(i int) E
+//	Set(i int, v E)
+//
+// for some element type E.
+func (g *generator) isContainer(t types.Type) bool {
+	isInt := func(t types.Type) bool {
+		return types.Identical(t, types.Typ[types.Int])
+	}
 
-		typ = se.Sel.Name
-	} else if i, ok := ste.X.(*ast.Ident); ok {
-		// this package
-		ip = g.pkg.ImportPath
-		typ = i.Name
-	} else {
+	l := g.method(t, "Len")
+	if l == nil || l.Params().Len() != 0 || l.Results().Len() != 1 || !isInt(l.Results().At(0).Type()) {
 		return false
 	}
 
-	if ip == "" || typ == "" {
+	gt := g.method(t, "Get")
+	if gt == nil || gt.Params().Len() != 1 || !isInt(gt.Params().At(0).Type()) || gt.Results().Len() != 1 {
 		return false
 	}
 
-	if matches, ok := g.typCache[ip]; ok {
-		return matches[typ]
+	s := g.method(t, "Set")
+	if s == nil || s.Params().Len() != 2 || !isInt(s.Params().At(0).Type()) || s.Results().Len() != 0 {
+		return false
 	}
 
-	bp, ok := g.pkgCache[ip]
-	if !ok {
-		p, err := build.Import(ip, g.pkg.Dir, 0)
-		if err != nil {
-			fatalf("could not load package %q with rel dir %q: %v", ip, g.pkg.Dir, err)
-		}
+	return types.Identical(gt.Results().At(0).Type(), s.Params().At(1).Type())
+}
+
+// method returns the signature of the method name in the method set of t,
+// else nil
+func (g *generator) method(t types.Type, name string) *types.Signature {
+	obj, _, _ := types.LookupFieldOrMethod(t, false, g.pkg.Types, name)
 
-		g.pkgCache[ip] = p
-		bp = p
+	f, ok := obj.(*types.Func)
+	if !ok {
+		return nil
 	}
 
-	return g.findImmSlices(bp)[typ]
+	return f.Type().(*types.Signature)
 }
 
-func (g *generator) genMatches(funs []toGen, imps map[string]bool) {
+// qualifier returns a types.Qualifier that qualifies types by the names with
+// which their packages are imported in the current file, adding to importMap
+// any packages that are not imported
+func (g *generator) qualifier(importMap map[string]bool) types.Qualifier {
+	return func(p *types.Package) string {
+		if p == g.pkg.Types {
+			return ""
+		}
 
-	// we need to generate one file for non-test matches... and one for test matches
+		for _, is := range g.file.Imports {
+			if path, _ := strconv.Unquote(is.Path.Value); path == p.Path() {
+				if is.Name != nil {
+					return is.Name.Name
+				}
+				return p.Name()
+			}
+		}
 
-	fn := g.fset.Position(g.file.Pos()).Filename
+		importMap[strconv.Quote(p.Path())] = true
 
-	ofName, ok := gogenerate.NameFileFromFile(fn, sortGenCmd)
-	if !ok {
-		fatalf("could not name generated file from %v", fn)
+		return p.Name()
 	}
+}
 
-	g.pf(`// Code generated by sortGen. DO NOT EDIT.
+func (g *generator) print(e ast.Expr) string {
+	var buf bytes.Buffer
 
-		`)
+	if err := printer.Fprint(&buf, g.pkg.Fset, e); err != nil {
+		fatalf("could not ast print %T: %v", e, err)
+	}
+
+	return buf.String()
+}
+
+func (g *generator) genMatches(funs []toGen, imps map[string]bool) gogenerate.File {
 
-	g.pf(g.license)
+	// the generated file corresponds to the file in which the matches were
+	// found, test or otherwise
+	fn := g.pkg.Fset.Position(g.file.Pos()).Filename
+	name := strings.TrimSuffix(filepath.Base(fn), ".go")
+	test := strings.HasSuffix(name, "_test")
+	name = strings.TrimSuffix(name, "_test")
 
 	g.pf(`package %v
 
@@ -585,31 +456,44 @@ func (g *generator) genMatches(funs []toGen, imps map[string]bool) {
 	}
 
 	for _, toGen := range funs {
-		tmpl := struct {
-			Recv  string
-			Sort  string
-			Name  string
-			Typ   string
-			Order string
-		}{
-			Recv:  toGen.recvTyp,
-			Typ:   toGen.typ,
-			Order: toGen.orderFn,
+		tmpl := genTmpl{
+			Recv:       toGen.recvTyp,
+			TypeParams: toGen.typeParams,
+			Typ:        toGen.typ,
+			Elem:       toGen.elem,
+			Base:       toGen.immBase,
+			Order:      toGen.orderFn,
+
+			Search:   funcName(toGen.orderFn, "search"),
+			Insert:   funcName(toGen.orderFn, "insert"),
+			IsSorted: funcName(toGen.orderFn, "isSorted"),
+			Merge:    funcName(toGen.orderFn, "merge"),
+			TopK:     funcName(toGen.orderFn, "topK"),
 		}
 
 		if toGen.recvTyp != "" {
 			tmpl.Order = toGen.recvVar + "." + toGen.orderFn
 		}
 
+		// the helpers below are generated for slices and immutable slices,
+		// the latter only where the type is a pointer to a named type, so
+		// that we can create values of the type
+		helpers := toGen.kind == kindSlice || toGen.kind == kindImmSlice && toGen.immBase != ""
+
 		sortFns := sortFunctions(toGen.orderFn)
+		reverseFns := []string{
+			funcName(toGen.orderFn, "reverseSort"),
+			funcName(toGen.orderFn, "reverseStableSort"),
+		}
 
 		for i, sn := range []string{"Sort", "Stable"} {
 			tmpl.Sort = sn
 			tmpl.Name = sortFns[i]
 
-			if toGen.imm {
+			switch toGen.kind {
+			case kindImmSlice:
 				g.pt(`
-					func {{.Recv}} {{.Name}}(vs {{.Typ}}) {{.Typ}}{
+					func {{.Recv}} {{.Name}}{{.TypeParams}}(vs {{.Typ}}) {{.Typ}}{
 						theVs := vs.AsMutable()
 
 						sort.{{.Sort}}(&sorter.Wrapper{
@@ -631,9 +515,29 @@ func (g *generator) genMatches(funs []toGen, imps map[string]bool) {
 						return theVs.AsImmutable(vs)
 					}
 					`, tmpl)
-			} else {
+			case kindContainer:
+				g.pt(`
+					func {{.Recv}} {{.Name}}{{.TypeParams}}(vs {{.Typ}}) {
+						sort.{{.Sort}}(&sorter.Wrapper{
+							LenFunc: func() int {
+								return vs.Len()
+							},
+							LessFunc: func(i, j int) bool {
+								return bool({{.Order}}(vs, i, j))
+							},
+							SwapFunc: func(i, j int) {
+								jPrev := vs.Get(j)
+								iPrev := vs.Get(i)
+
+								vs.Set(j, iPrev)
+								vs.Set(i, jPrev)
+							},
+						})
+					}
+					`, tmpl)
+			default:
 				g.pt(`
-					func {{.Recv}} {{.Name}}(vs {{.Typ}}) {
+					func {{.Recv}} {{.Name}}{{.TypeParams}}(vs {{.Typ}}) {
 						sort.{{.Sort}}(&sorter.Wrapper{
 							LenFunc: func() int {
 								return len(vs)
@@ -647,21 +551,274 @@ func (g *generator) genMatches(funs []toGen, imps map[string]bool) {
 						})
 					}
 					`, tmpl)
+
+				// sort.Slice and sort.SliceStable sort vs in place, so the
+				// order function is called directly on the indices compared
+				tmpl.Sort = map[string]string{"Sort": "Slice", "Stable": "SliceStable"}[sn]
+				tmpl.Name += "Func"
+
+				g.pt(`
+					func {{.Recv}} {{.Name}}{{.TypeParams}}(vs {{.Typ}}) {
+						sort.{{.Sort}}(vs, func(i, j int) bool {
+							return bool({{.Order}}(vs, i, j))
+						})
+					}
+					`, tmpl)
 			}
 
+			if helpers {
+				tmpl.Sort = sn
+				tmpl.Name = reverseFns[i]
+				g.genReverse(toGen.kind, tmpl)
+			}
 		}
-	}
 
-	toWrite := g.buf.Bytes()
+		if helpers {
+			g.genHelpers(toGen.kind, tmpl)
+		}
+	}
 
-	res, err := imports.Process(ofName, toWrite, nil)
-	if err == nil {
-		toWrite = res
+	return gogenerate.File{
+		Name:     name,
+		Test:     test,
+		Contents: g.buf.Bytes(),
 	}
+}
+
+// genTmpl is the value with which the templates of generated functions are
+// executed
+type genTmpl struct {
+	Recv       string
+	Sort       string
+	Name       string
+	TypeParams string
+	Typ        string
+	Elem       string
+	Base       string
+	Order      string
+
+	// the names of the helper functions
+	Search   string
+	Insert   string
+	IsSorted string
+	Merge    string
+	TopK     string
+}
+
+// genReverse generates a function that sorts a slice or immutable slice in
+// the reverse of the order given by the order function
+func (g *generator) genReverse(k kind, tmpl genTmpl) {
+	if k == kindImmSlice {
+		g.pt(`
+			func {{.Recv}} {{.Name}}{{.TypeParams}}(vs {{.Typ}}) {{.Typ}} {
+				theVs := vs.AsMutable()
+
+				sort.{{.Sort}}(sort.Reverse(&sorter.Wrapper{
+					LenFunc: func() int {
+						return theVs.Len()
+					},
+					LessFunc: func(i, j int) bool {
+						return bool({{.Order}}(theVs, i, j))
+					},
+					SwapFunc: func(i, j int) {
+						jPrev := theVs.Get(j)
+						iPrev := theVs.Get(i)
+
+						theVs.Set(j, iPrev)
+						theVs.Set(i, jPrev)
+					},
+				}))
+
+				return theVs.AsImmutable(vs)
+			}
+			`, tmpl)
+		return
+	}
+
+	g.pt(`
+		func {{.Recv}} {{.Name}}{{.TypeParams}}(vs {{.Typ}}) {
+			sort.{{.Sort}}(sort.Reverse(&sorter.Wrapper{
+				LenFunc: func() int {
+					return len(vs)
+				},
+				LessFunc: func(i, j int) bool {
+					return bool({{.Order}}(vs, i, j))
+				},
+				SwapFunc: func(i, j int) {
+					vs[i], vs[j] = vs[j], vs[i]
+				},
+			}))
+		}
+		`, tmpl)
+}
+
+// genHelpers generates the search, insert, isSorted, merge and topK functions
+// for a slice or immutable slice. These compare an element with another by
+// calling the order function with a container of just the two elements.
+// Where elements are equal in the order, insert inserts after the existing
+// elements, merge takes from its first argument first, and topK keeps the
+// earliest elements; hence all three are stable.
+func (g *generator) genHelpers(k kind, tmpl genTmpl) {
+	if k == kindImmSlice {
+		g.pt(`
+			func {{.Recv}} {{.Search}}{{.TypeParams}}(vs {{.Typ}}, x {{.Elem}}) int {
+				return sort.Search(vs.Len(), func(i int) bool {
+					return !bool({{.Order}}(new({{.Base}}).Append(vs.Get(i), x), 0, 1))
+				})
+			}
+
+			func {{.Recv}} {{.Insert}}{{.TypeParams}}(vs {{.Typ}}, x {{.Elem}}) {{.Typ}} {
+				i := sort.Search(vs.Len(), func(i int) bool {
+					return bool({{.Order}}(new({{.Base}}).Append(x, vs.Get(i)), 0, 1))
+				})
+
+				res := make([]{{.Elem}}, 0, vs.Len()+1)
+				res = append(res, vs.Range()[:i]...)
+				res = append(res, x)
+				res = append(res, vs.Range()[i:]...)
+
+				return new({{.Base}}).Append(res...)
+			}
+
+			func {{.Recv}} {{.IsSorted}}{{.TypeParams}}(vs {{.Typ}}) bool {
+				return sort.IsSorted(&sorter.Wrapper{
+					LenFunc: func() int {
+						return vs.Len()
+					},
+					LessFunc: func(i, j int) bool {
+						return bool({{.Order}}(vs, i, j))
+					},
+				})
+			}
+
+			func {{.Recv}} {{.Merge}}{{.TypeParams}}(a, b {{.Typ}}) {{.Typ}} {
+				av, bv := a.Range(), b.Range()
+				res := make([]{{.Elem}}, 0, len(av)+len(bv))
+
+				for len(av) > 0 && len(bv) > 0 {
+					if bool({{.Order}}(new({{.Base}}).Append(bv[0], av[0]), 0, 1)) {
+						res = append(res, bv[0])
+						bv = bv[1:]
+					} else {
+						res = append(res, av[0])
+						av = av[1:]
+					}
+				}
+
+				res = append(res, av...)
+				res = append(res, bv...)
+
+				return new({{.Base}}).Append(res...)
+			}
+
+			func {{.Recv}} {{.TopK}}{{.TypeParams}}(vs {{.Typ}}, k int) {{.Typ}} {
+				var res []{{.Elem}}
+
+				if k > 0 {
+					for _, v := range vs.Range() {
+						if len(res) == k && !bool({{.Order}}(new({{.Base}}).Append(v, res[k-1]), 0, 1)) {
+							continue
+						}
+
+						i := sort.Search(len(res), func(i int) bool {
+							return bool({{.Order}}(new({{.Base}}).Append(v, res[i]), 0, 1))
+						})
+
+						var zero {{.Elem}}
+						res = append(res, zero)
+						copy(res[i+1:], res[i:])
+						res[i] = v
+
+						if len(res) > k {
+							res = res[:k]
+						}
+					}
+				}
 
-	if err := ioutil.WriteFile(ofName, toWrite, 0644); err != nil {
-		fatalf("could not write %v: %v", ofName, err)
+				return new({{.Base}}).Append(res...)
+			}
+			`, tmpl)
+		return
 	}
+
+	g.pt(`
+		func {{.Recv}} {{.Search}}{{.TypeParams}}(vs {{.Typ}}, x {{.Elem}}) int {
+			return sort.Search(len(vs), func(i int) bool {
+				return !bool({{.Order}}({{.Typ}}{vs[i], x}, 0, 1))
+			})
+		}
+
+		func {{.Recv}} {{.Insert}}{{.TypeParams}}(vs {{.Typ}}, x {{.Elem}}) {{.Typ}} {
+			i := sort.Search(len(vs), func(i int) bool {
+				return bool({{.Order}}({{.Typ}}{x, vs[i]}, 0, 1))
+			})
+
+			var zero {{.Elem}}
+			vs = append(vs, zero)
+			copy(vs[i+1:], vs[i:])
+			vs[i] = x
+
+			return vs
+		}
+
+		func {{.Recv}} {{.IsSorted}}{{.TypeParams}}(vs {{.Typ}}) bool {
+			return sort.IsSorted(&sorter.Wrapper{
+				LenFunc: func() int {
+					return len(vs)
+				},
+				LessFunc: func(i, j int) bool {
+					return bool({{.Order}}(vs, i, j))
+				},
+			})
+		}
+
+		func {{.Recv}} {{.Merge}}{{.TypeParams}}(a, b {{.Typ}}) {{.Typ}} {
+			res := make({{.Typ}}, 0, len(a)+len(b))
+
+			for len(a) > 0 && len(b) > 0 {
+				if bool({{.Order}}({{.Typ}}{b[0], a[0]}, 0, 1)) {
+					res = append(res, b[0])
+					b = b[1:]
+				} else {
+					res = append(res, a[0])
+					a = a[1:]
+				}
+			}
+
+			res = append(res, a...)
+
+			return append(res, b...)
+		}
+
+		func {{.Recv}} {{.TopK}}{{.TypeParams}}(vs {{.Typ}}, k int) {{.Typ}} {
+			if k <= 0 {
+				return nil
+			}
+
+			res := make({{.Typ}}, 0, k+1)
+
+			for _, v := range vs {
+				if len(res) == k && !bool({{.Order}}({{.Typ}}{v, res[k-1]}, 0, 1)) {
+					continue
+				}
+
+				i := sort.Search(len(res), func(i int) bool {
+					return bool({{.Order}}({{.Typ}}{v, res[i]}, 0, 1))
+				})
+
+				var zero {{.Elem}}
+				res = append(res, zero)
+				copy(res[i+1:], res[i:])
+				res[i] = v
+
+				if len(res) > k {
+					res = res[:k]
+				}
+			}
+
+			return res
+		}
+		`, tmpl)
 }
 
 func (g *generator) pf(format string, args ...interface{}) {
@@ -689,8 +846,14 @@ func (g *generator) pt(tmpl string, val interface{}) {
 }
 
 func sortFunctions(orderFn string) []string {
-	// TODO this can be improved
+	return []string{funcName(orderFn, "sort"), funcName(orderFn, "stableSort")}
+}
 
+// funcName returns the name of the function for the order function orderFn
+// that is the result of replacing the order prefix with verb, following the
+// capitalisation of orderFn. For example, the name for the order function
+// OrderByName and the verb search is SearchByName.
+func funcName(orderFn, verb string) string {
 	lower := false
 	split := ""
 
@@ -704,10 +867,12 @@ func sortFunctions(orderFn string) []string {
 	parts := strings.SplitAfterN(orderFn, split, 2)
 
 	if lower {
-		return []string{"sort" + parts[1], "stableSort" + parts[1]}
+		return verb + parts[1]
 	}
 
-	return []string{"Sort" + parts[1], "StableSort" + parts[1]}
+	r, n := utf8.DecodeRuneInString(verb)
+
+	return string(unicode.ToUpper(r)) + verb[n:] + parts[1]
 }
 
 type importFinder struct {
@@ -750,12 +915,11 @@ func findImports(exp ast.Expr, imports []*ast.ImportSpec) map[*ast.ImportSpec]bo
 	return finder.matches
 }
 
-func fatalf(format string, args ...interface{}) {
-	panic(fmt.Errorf(format, args...))
+// sortGenError is the type of panic value used by fatalf, recovered by gen
+type sortGenError struct {
+	error
 }
 
-func infof(format string, args ...interface{}) {
-	if *fGoGenLog == string(gogenerate.LogInfo) {
-		log.Printf(format, args...)
-	}
+func fatalf(format string, args ...interface{}) {
+	panic(sortGenError{fmt.Errorf(format, args...)})
 }
@@ -5,8 +5,11 @@
 
 package main
 
-import "sort"
-import "myitcv.io/sorter"
+import (
+	"sort"
+
+	"myitcv.io/sorter"
+)
 
 func sortByName(vs []person) {
 	sort.Sort(&sorter.Wrapper{
@@ -21,6 +24,24 @@ func sortByName(vs []person) {
 		},
 	})
 }
+func sortByNameFunc(vs []person) {
+	sort.Slice(vs, func(i, j int) bool {
+		return bool(orderByName(vs, i, j))
+	})
+}
+func reverseSortByName(vs []person) {
+	sort.Sort(sort.Reverse(&sorter.Wrapper{
+		LenFunc: func() int {
+			return len(vs)
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(orderByName(vs, i, j))
+		},
+		SwapFunc: func(i, j int) {
+			vs[i], vs[j] = vs[j], vs[i]
+		},
+	}))
+}
 func stableSortByName(vs []person) {
 	sort.Stable(&sorter.Wrapper{
 		LenFunc: func() int {
@@ -34,6 +55,100 @@ func stableSortByName(vs []person) {
 		},
 	})
 }
+func stableSortByNameFunc(vs []person) {
+	sort.SliceStable(vs, func(i, j int) bool {
+		return bool(orderByName(vs, i, j))
+	})
+}
+func reverseStableSortByName(vs []person) {
+	sort.Stable(sort.Reverse(&sorter.Wrapper{
+		LenFunc: func() int {
+			return len(vs)
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(orderByName(vs, i, j))
+		},
+		SwapFunc: func(i, j int) {
+			vs[i], vs[j] = vs[j], vs[i]
+		},
+	}))
+}
+func searchByName(vs []person, x person) int {
+	return sort.Search(len(vs), func(i int) bool {
+		return !bool(orderByName([]person{vs[i], x}, 0, 1))
+	})
+}
+
+func insertByName(vs []person, x person) []person {
+	i := sort.Search(len(vs), func(i int) bool {
+		return bool(orderByName([]person{x, vs[i]}, 0, 1))
+	})
+
+	var zero person
+	vs = append(vs, zero)
+	copy(vs[i+1:], vs[i:])
+	vs[i] = x
+
+	return vs
+}
+
+func isSortedByName(vs []person) bool {
+	return sort.IsSorted(&sorter.Wrapper{
+		LenFunc: func() int {
+			return len(vs)
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(orderByName(vs, i, j))
+		},
+	})
+}
+
+func mergeByName(a, b []person) []person {
+	res := make([]person, 0, len(a)+len(b))
+
+	for len(a) > 0 && len(b) > 0 {
+		if bool(orderByName([]person{b[0], a[0]}, 0, 1)) {
+			res = append(res, b[0])
+			b = b[1:]
+		} else {
+			res = append(res, a[0])
+			a = a[1:]
+		}
+	}
+
+	res = append(res, a...)
+
+	return append(res, b...)
+}
+
+func topKByName(vs []person, k int) []person {
+	if k <= 0 {
+		return nil
+	}
+
+	res := make([]person, 0, k+1)
+
+	for _, v := range vs {
+		if len(res) == k && !bool(orderByName([]person{v, res[k-1]}, 0, 1)) {
+			continue
+		}
+
+		i := sort.Search(len(res), func(i int) bool {
+			return bool(orderByName([]person{v, res[i]}, 0, 1))
+		})
+
+		var zero person
+		res = append(res, zero)
+		copy(res[i+1:], res[i:])
+		res[i] = v
+
+		if len(res) > k {
+			res = res[:k]
+		}
+	}
+
+	return res
+}
 func (m *myStruct) sortByAge(vs []person) {
 	sort.Sort(&sorter.Wrapper{
 		LenFunc: func() int {
@@ -47,6 +162,24 @@ func (m *myStruct) sortByAge(vs []person) {
 		},
 	})
 }
+func (m *myStruct) sortByAgeFunc(vs []person) {
+	sort.Slice(vs, func(i, j int) bool {
+		return bool(m.orderByAge(vs, i, j))
+	})
+}
+func (m *myStruct) reverseSortByAge(vs []person) {
+	sort.Sort(sort.Reverse(&sorter.Wrapper{
+		LenFunc: func() int {
+			return len(vs)
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(m.orderByAge(vs, i, j))
+		},
+		SwapFunc: func(i, j int) {
+			vs[i], vs[j] = vs[j], vs[i]
+		},
+	}))
+}
 func (m *myStruct) stableSortByAge(vs []person) {
 	sort.Stable(&sorter.Wrapper{
 		LenFunc: func() int {
@@ -60,3 +193,97 @@ func (m *myStruct) stableSortByAge(vs []person) {
 		},
 	})
 }
+func (m *myStruct) stableSortByAgeFunc(vs []person) {
+	sort.SliceStable(vs, func(i, j int) bool {
+		return bool(m.orderByAge(vs, i, j))
+	})
+}
+func (m *myStruct) reverseStableSortByAge(vs []person) {
+	sort.Stable(sort.Reverse(&sorter.Wrapper{
+		LenFunc: func() int {
+			return len(vs)
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(m.orderByAge(vs, i, j))
+		},
+		SwapFunc: func(i, j int) {
+			vs[i], vs[j] = vs[j], vs[i]
+		},
+	}))
+}
+func (m *myStruct) searchByAge(vs []person, x person) int {
+	return sort.Search(len(vs), func(i int) bool {
+		return !bool(m.orderByAge([]person{vs[i], x}, 0, 1))
+	})
+}
+
+func (m *myStruct) insertByAge(vs []person, x person) []person {
+	i := sort.Search(len(vs), func(i int) bool {
+		return bool(m.orderByAge([]person{x, vs[i]}, 0, 1))
+	})
+
+	var zero person
+	vs = append(vs, zero)
+	copy(vs[i+1:], vs[i:])
+	vs[i] = x
+
+	return vs
+}
+
+func (m *myStruct) isSortedByAge(vs []person) bool {
+	return sort.IsSorted(&sorter.Wrapper{
+		LenFunc: func() int {
+			return len(vs)
+		},
+		LessFunc: func(i, j int) bool {
+			return bool(m.orderByAge(vs, i, j))
+		},
+	})
+}
+
+func (m *myStruct) mergeByAge(a, b []person) []person {
+	res := make([]person, 0, len(a)+len(b))
+
+	for len(a) > 0 && len(b) > 0 {
+		if bool(m.orderByAge([]person{b[0], a[0]}, 0, 1)) {
+			res = append(res, b[0])
+			b = b[1:]
+		} else {
+			res = append(res, a[0])
+			a = a[1:]
+		}
+	}
+
+	res = append(res, a...)
+
+	return append(res, b...)
+}
+
+func (m *myStruct) topKByAge(vs []person, k int) []person {
+	if k <= 0 {
+		return nil
+	}
+
+	res := make([]person, 0, k+1)
+
+	for _, v := range vs {
+		if len(res) == k && !bool(m.orderByAge([]person{v, res[k-1]}, 0, 1)) {
+			continue
+		}
+
+		i := sort.Search(len(res), func(i int) bool {
+			return bool(m.orderByAge([]person{v, res[i]}, 0, 1))
+		})
+
+		var zero person
+		res = append(res, zero)
+		copy(res[i+1:], res[i:])
+		res[i] = v
+
+		if len(res) > k {
+			res = res[:k]
+		}
+	}
+
+	return res
+}